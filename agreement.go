@@ -0,0 +1,50 @@
+package smolcert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// SharedKeySize is the size of keys derived by DeriveSharedKey in bytes
+const SharedKeySize = 32
+
+// agreementContext is used as HKDF info prefix when deriving shared keys
+var agreementContext = []byte("smolcert key agreement")
+
+// DeriveSharedKey derives a symmetric key shared between the holder of priv and the holder of peer.
+// Both ed25519 keys are converted to X25519 for a static Diffie-Hellman key agreement, the
+// result is passed through HKDF-SHA256 with both public keys bound in. Both sides derive the same
+// key regardless of which one is local. info allows to derive independent keys for different
+// purposes and may be nil.
+// As the key is static, callers need to make sure to use unique nonces or derive new keys via info
+// for every session.
+func DeriveSharedKey(priv ed25519.PrivateKey, peer *Certificate, info []byte) ([]byte, error) {
+	peerKey, err := X25519PublicKey(peer.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(X25519PrivateKey(priv), peerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The public keys are ordered, so that both sides use the same salt
+	localPub := priv.Public().(ed25519.PublicKey)
+	first, second := []byte(localPub), []byte(peer.PubKey)
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+	salt := append(append([]byte{}, first...), second...)
+
+	key := make([]byte, SharedKeySize)
+	kdf := hkdf.New(sha256.New, shared, salt, append(append([]byte{}, agreementContext...), info...))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
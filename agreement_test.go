@@ -0,0 +1,37 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveSharedKey(t *testing.T) {
+	aliceCert, aliceKey, err := SelfSignedCertificate("alice", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	bobCert, bobKey, err := SelfSignedCertificate("bob", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	eveCert, eveKey, err := SelfSignedCertificate("eve", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	aliceShared, err := DeriveSharedKey(aliceKey, bobCert, nil)
+	require.NoError(t, err)
+	bobShared, err := DeriveSharedKey(bobKey, aliceCert, nil)
+	require.NoError(t, err)
+	assert.Len(t, aliceShared, SharedKeySize)
+	assert.Equal(t, aliceShared, bobShared)
+
+	eveShared, err := DeriveSharedKey(eveKey, aliceCert, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, aliceShared, eveShared)
+	eveShared, err = DeriveSharedKey(aliceKey, eveCert, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, aliceShared, eveShared)
+
+	// Different purposes lead to different keys
+	purposeShared, err := DeriveSharedKey(aliceKey, bobCert, []byte("purpose"))
+	require.NoError(t, err)
+	assert.NotEqual(t, aliceShared, purposeShared)
+}
@@ -0,0 +1,52 @@
+package smolcert
+
+import "fmt"
+
+// Algorithm identifies the algorithm of the signature of a certificate. The values are the
+// algorithm identifiers of the COSE registry (RFC 8152), so further algorithms can be added
+// without changing the certificate format.
+type Algorithm int64
+
+const (
+	// AlgorithmEd25519 is EdDSA with ed25519 keys
+	AlgorithmEd25519 Algorithm = -8
+	// AlgorithmX509 marks trust anchors converted from self signed X.509 certificates via FromX509.
+	// The value is from the private use range of the COSE registry.
+	AlgorithmX509 Algorithm = -65539
+)
+
+// String returns the name of the algorithm
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmEd25519:
+		return "Ed25519"
+	case AlgorithmEd25519ph:
+		return "Ed25519ph"
+	case AlgorithmMLDSA44:
+		return "ML-DSA-44"
+	case AlgorithmMLDSA65:
+		return "ML-DSA-65"
+	case AlgorithmMLDSA87:
+		return "ML-DSA-87"
+	case AlgorithmHybrid:
+		return "Hybrid"
+	case AlgorithmX509:
+		return "X.509"
+	default:
+		return fmt.Sprintf("Unknown algorithm (%d)", int64(a))
+	}
+}
+
+// IsSupported is true if certificates signed with this algorithm can be validated
+func (a Algorithm) IsSupported() bool {
+	return a == AlgorithmEd25519 || a == AlgorithmEd25519ph || a == AlgorithmHybrid ||
+		(a == AlgorithmX509 && x509Supported)
+}
+
+func checkAlgorithm(cert *Certificate) error {
+	if !cert.SignatureAlgorithm.IsSupported() {
+		return newValidationError(ErrUnsupportedAlgorithm, cert, "Certificate is signed with the unsupported algorithm %s",
+			cert.SignatureAlgorithm)
+	}
+	return nil
+}
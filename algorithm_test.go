@@ -0,0 +1,52 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureAlgorithm(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, rootCert.SignatureAlgorithm)
+	cert, _, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, cert.SignatureAlgorithm)
+
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519, parsed.SignatureAlgorithm)
+
+	pool := NewCertPool(rootCert)
+	require.NoError(t, pool.Validate(parsed))
+
+	// Unknown algorithms are rejected before the signature is checked
+	parsed = parsed.Copy()
+	parsed.SignatureAlgorithm = Algorithm(-1000)
+	err = pool.Validate(parsed)
+	assert.True(t, errors.Is(err, ErrUnsupportedAlgorithm))
+	report := pool.ValidateReport(parsed)
+	require.Len(t, report.Problems, 1)
+	assert.True(t, errors.Is(report.Err(), ErrUnsupportedAlgorithm))
+	assert.Equal(t, "Unknown algorithm (-1000)", parsed.SignatureAlgorithm.String())
+	assert.Equal(t, "Ed25519", AlgorithmEd25519.String())
+}
+
+func TestSignatureAlgorithmIsSigned(t *testing.T) {
+	rootCert, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	tbs1, err := rootCert.TBSBytes()
+	require.NoError(t, err)
+
+	c := rootCert.Copy()
+	c.SignatureAlgorithm = 0
+	tbs2, err := c.TBSBytes()
+	require.NoError(t, err)
+	assert.NotEqual(t, tbs1, tbs2)
+}
@@ -0,0 +1,97 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ErrHolderMismatch indicates that an attribute certificate belongs to another certificate
+var ErrHolderMismatch = errors.New("attribute certificate belongs to another holder")
+
+// HolderExtension creates a critical Holder Extension, binding an attribute certificate to the
+// certificate with the public key identified by holder
+func HolderExtension(holder Pin) Extension {
+	return Extension{
+		OID:      OIDHolder,
+		Critical: true,
+		Value:    append([]byte{}, holder[:]...),
+	}
+}
+
+// Holder returns the Pin of the public key the attribute certificate c belongs to. If c has no
+// Holder extension, ErrorExtensionNotFound is returned.
+func (c *Certificate) Holder() (Pin, error) {
+	var holder Pin
+	err := RequiresExtension(c, OIDHolder, func(critical bool, val []byte) (err error) {
+		holder, err = parseHolder(val)
+		return
+	})
+	return holder, err
+}
+
+func parseHolder(val []byte) (Pin, error) {
+	var holder Pin
+	if len(val) != len(holder) {
+		return holder, fmt.Errorf("Unexpected length of Holder (expected %d bytes, got %d bytes)", len(holder), len(val))
+	}
+	copy(holder[:], val)
+	return holder, nil
+}
+
+// IsAttributeCertificate is true if c is an attribute certificate, which has no public key of its
+// own, but grants its attributes to its holder
+func (c *Certificate) IsAttributeCertificate() bool {
+	_, err := c.Holder()
+	return err == nil && len(c.PubKey) == 0
+}
+
+// AttributeCertificate creates an attribute certificate for holder, signed with issuerKey. The
+// attributes are carried by extensions, i.e. Capabilities or SubjectAttributes. Attribute
+// certificates have no public key, so they can be issued by an authority separate from the one
+// issuing identities, while the holder proves its identity with its own certificate.
+func AttributeCertificate(holder *Certificate, serialNumber uint64, notBefore, notAfter time.Time,
+	extensions []Extension, issuerKey ed25519.PrivateKey, issuer string) (*Certificate, error) {
+	if len(holder.PubKey) == 0 {
+		return nil, errors.New("The holder of an attribute certificate needs a public key")
+	}
+	cert := &Certificate{
+		Version:      LatestVersion,
+		SerialNumber: serialNumber,
+		Issuer:       issuer,
+		Validity:     NewMillisValidity(notBefore, notAfter),
+		Subject:      holder.Subject,
+		// The key is empty instead of null, as required by the format
+		PubKey:     []byte{},
+		Extensions: ensureExtension(extensions, HolderExtension(PinFromCertificate(holder))),
+	}
+	return SignCertificate(cert, issuerKey)
+}
+
+// ValidateAttributeCertificate validates the attribute certificate at the start of attrBundle,
+// followed by the certificates of its issuing authority, against the CertPool and checks that it
+// belongs to holder. The holder certificate itself needs to be validated separately, it may be
+// issued by another CertPool. The attribute certificate is returned on success.
+func (c *CertPool) ValidateAttributeCertificate(attrBundle []*Certificate, holder *Certificate) (*Certificate, error) {
+	if len(attrBundle) == 0 {
+		return nil, errors.New("Attribute certificate bundle is empty")
+	}
+	attrCert := attrBundle[0]
+	if !attrCert.IsAttributeCertificate() {
+		return nil, newValidationError(ErrMalformedCertificate, attrCert, "Certificate is no attribute certificate")
+	}
+	if _, err := NewBundle(attrBundle...).Validate(c); err != nil {
+		return nil, err
+	}
+	pin, err := attrCert.Holder()
+	if err != nil {
+		return nil, err
+	}
+	if pin != PinFromPublicKey(holder.PubKey) {
+		return nil, newValidationError(ErrHolderMismatch, attrCert,
+			"Attribute certificate belongs to %s, not to the key of '%s'", pin, holder.Subject)
+	}
+	return attrCert, nil
+}
@@ -0,0 +1,82 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeCertificate(t *testing.T) {
+	now := time.Now()
+	identityRoot, identityKey, err := SelfSignedCertificate("identity root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	holder, _, err := ClientCertificate("device", 1, time.Time{}, time.Time{}, nil, identityKey, "identity root")
+	require.NoError(t, err)
+	other, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, identityKey, "identity root")
+	require.NoError(t, err)
+
+	authorityRoot, authorityRootKey, err := SelfSignedCertificate("authority root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	caps, err := CapabilitiesExtension("door/*")
+	require.NoError(t, err)
+	authority, authorityKey, err := SignedCertificate("authority", 3, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}, caps}, authorityRootKey, "authority root")
+	require.NoError(t, err)
+
+	grant, err := CapabilitiesExtension("door/open")
+	require.NoError(t, err)
+	_, err = AttributeCertificate(&Certificate{Subject: "keyless"}, 4, now, now.Add(time.Hour), nil, authorityKey, "authority")
+	assert.Error(t, err)
+	attrCert, err := AttributeCertificate(holder, 4, now.Add(-time.Minute), now.Add(time.Hour),
+		[]Extension{grant}, authorityKey, "authority")
+	require.NoError(t, err)
+	assert.True(t, attrCert.IsAttributeCertificate())
+	assert.False(t, holder.IsAttributeCertificate())
+	assert.Equal(t, "device", attrCert.Subject)
+	pin, err := attrCert.Holder()
+	require.NoError(t, err)
+	assert.Equal(t, PinFromCertificate(holder), pin)
+	_, err = holder.Holder()
+	assert.True(t, errors.Is(err, ErrorExtensionNotFound))
+
+	buf, err := attrCert.Bytes()
+	require.NoError(t, err)
+	assert.NoError(t, CheckFormat(buf))
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	assert.True(t, parsed.IsAttributeCertificate())
+
+	// Identity and attributes are validated against separate pools
+	require.NoError(t, NewCertPool(identityRoot).Validate(holder))
+	authorities := NewCertPool(authorityRoot)
+	validated, err := authorities.ValidateAttributeCertificate([]*Certificate{parsed, authority}, holder)
+	require.NoError(t, err)
+	assert.NoError(t, Authorize(validated, "door/open"))
+	assert.True(t, errors.Is(Authorize(validated, "door/close"), ErrNotAuthorized))
+
+	_, err = authorities.ValidateAttributeCertificate([]*Certificate{parsed, authority}, other)
+	assert.True(t, errors.Is(err, ErrHolderMismatch))
+	_, err = authorities.ValidateAttributeCertificate([]*Certificate{parsed}, holder)
+	assert.True(t, errors.Is(err, ErrUnknownIssuer))
+	_, err = NewCertPool(identityRoot).ValidateAttributeCertificate([]*Certificate{holder}, holder)
+	assert.True(t, errors.Is(err, ErrMalformedCertificate))
+	_, err = authorities.ValidateAttributeCertificate(nil, holder)
+	assert.Error(t, err)
+
+	// The authority can only grant its own capabilities
+	tooMuch, err := CapabilitiesExtension("window/open")
+	require.NoError(t, err)
+	invalid, err := AttributeCertificate(holder, 5, time.Time{}, time.Time{}, []Extension{tooMuch}, authorityKey, "authority")
+	require.NoError(t, err)
+	_, err = authorities.ValidateAttributeCertificate([]*Certificate{invalid, authority}, holder)
+	assert.True(t, errors.Is(err, ErrCapabilityViolation))
+
+	// Attribute certificates can't issue certificates
+	issued, _, err := ClientCertificate("issued", 6, time.Time{}, time.Time{}, nil, authorityKey, "device")
+	require.NoError(t, err)
+	_, err = authorities.ValidateBundle([]*Certificate{issued, attrCert, authority})
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// AudienceRestriction is the Value of an Audience extension. It names the services a certificate
+// is intended for and the scopes it grants there, so a certificate issued for one service can't be
+// replayed at another. Services require it with the VerifyOption RequireAudience or CheckAudience.
+type AudienceRestriction struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Audiences are the identifiers of the services the certificate may be presented to
+	Audiences []string `cbor:"audiences"`
+	// Scopes are granted at all of the audiences, i.e. "read" or "orders:write"
+	Scopes []string `cbor:"scopes"`
+}
+
+// AudienceExtension creates a critical Audience Extension, so verifiers not checking the audience
+// reject the certificate instead of accepting it for any service
+func AudienceExtension(r *AudienceRestriction) (Extension, error) {
+	if len(r.Audiences) == 0 {
+		return Extension{}, errors.New("Audience extension needs at least one audience")
+	}
+	for _, audience := range r.Audiences {
+		if audience == "" {
+			return Extension{}, errors.New("Audiences can't be empty")
+		}
+	}
+	val, err := cborEm.Marshal(r)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDAudience,
+		Critical: true,
+		Value:    val,
+	}, nil
+}
+
+// ParseAudienceRestriction parses an AudienceRestriction from a byte slice, i.e. the Value of an
+// Extension
+func ParseAudienceRestriction(in []byte) (*AudienceRestriction, error) {
+	r := &AudienceRestriction{}
+	if err := cbor.Unmarshal(in, r); err != nil {
+		return nil, fmt.Errorf("Failed to parse Audience: %w", err)
+	}
+	return r, nil
+}
+
+// AudienceRestriction returns the AudienceRestriction of the certificate. If the certificate has
+// no Audience extension nil is returned.
+func (c *Certificate) AudienceRestriction() (*AudienceRestriction, error) {
+	var r *AudienceRestriction
+	err := RequiresExtension(c, OIDAudience, func(critical bool, val []byte) (err error) {
+		r, err = ParseAudienceRestriction(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return r, err
+}
+
+// CheckAudience checks that cert is intended for audience and grants all scopes. Certificates
+// without Audience extension are rejected, as they could have been issued for any service. The
+// certificate needs to be validated before.
+func CheckAudience(cert *Certificate, audience string, scopes ...string) error {
+	r, err := cert.AudienceRestriction()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Invalid Audience: %s", err)
+	}
+	if r == nil {
+		return newValidationError(ErrAudienceMismatch, cert, "Certificate has no Audience, but '%s' is required", audience)
+	}
+	if !containsString(r.Audiences, audience) {
+		return newValidationError(ErrAudienceMismatch, cert, "Certificate is not intended for '%s'", audience)
+	}
+	for _, scope := range scopes {
+		if !containsString(r.Scopes, scope) {
+			return newValidationError(ErrAudienceMismatch, cert, "Certificate doesn't grant the scope '%s' at '%s'",
+				scope, audience)
+		}
+	}
+	return nil
+}
+
+// RequireAudience rejects leaf certificates with ErrAudienceMismatch unless they are intended for
+// audience and grant all scopes, like CheckAudience. Intermediates and roots are not checked.
+func RequireAudience(audience string, scopes ...string) VerifyOption {
+	return func(o *verifyOptions) {
+		o.audience = audience
+		o.scopes = append([]string{}, scopes...)
+	}
+}
@@ -0,0 +1,61 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudience(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ext, err := AudienceExtension(&AudienceRestriction{
+		Audiences: []string{"orders", "billing"},
+		Scopes:    []string{"read", "write"},
+	})
+	require.NoError(t, err)
+	assert.True(t, ext.Critical)
+	scoped, _, err := ClientCertificate("service-a", 2, time.Time{}, time.Time{}, []Extension{ext}, rootKey, "root")
+	require.NoError(t, err)
+	unscoped, _, err := ClientCertificate("service-b", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	r, err := scoped.AudienceRestriction()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders", "billing"}, r.Audiences)
+	r, err = unscoped.AudienceRestriction()
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	assert.NoError(t, CheckAudience(scoped, "orders"))
+	assert.NoError(t, CheckAudience(scoped, "billing", "read", "write"))
+	assert.True(t, errors.Is(CheckAudience(scoped, "inventory"), ErrAudienceMismatch))
+	assert.True(t, errors.Is(CheckAudience(scoped, "orders", "admin"), ErrAudienceMismatch))
+	assert.True(t, errors.Is(CheckAudience(unscoped, "orders"), ErrAudienceMismatch))
+
+	validator := NewValidator(NewCertPool(rootCert))
+	validator.Options = []VerifyOption{RequireAudience("orders", "read")}
+	assert.NoError(t, validator.Validate(scoped))
+	_, err = validator.ValidateBundle([]*Certificate{scoped})
+	assert.NoError(t, err)
+	_, err = NewBundle(scoped).ValidateWith(validator)
+	assert.NoError(t, err)
+	err = validator.Validate(unscoped)
+	assert.True(t, errors.Is(err, ErrAudienceMismatch))
+	assert.Equal(t, "audience_mismatch", FailureReason(err))
+	_, err = NewBundle(unscoped).ValidateWith(validator)
+	assert.True(t, errors.Is(err, ErrAudienceMismatch))
+
+	// The certificate for the orders service is replayed at the inventory service
+	validator.Options = []VerifyOption{RequireAudience("inventory")}
+	_, err = validator.ValidateBundle([]*Certificate{scoped})
+	assert.True(t, errors.Is(err, ErrAudienceMismatch))
+
+	_, err = AudienceExtension(&AudienceRestriction{})
+	assert.Error(t, err)
+	_, err = ParseAudienceRestriction([]byte{0xff})
+	assert.Error(t, err)
+}
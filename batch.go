@@ -0,0 +1,191 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/ed25519"
+)
+
+type batchEntry struct {
+	pubKey    ed25519.PublicKey
+	message   []byte
+	sig       []byte
+	prehashed bool
+}
+
+func (e batchEntry) verify() bool {
+	if e.prehashed {
+		return verifyPrehashedSignature(e.pubKey, e.message, e.sig)
+	}
+	return verifySignature(e.pubKey, e.message, e.sig)
+}
+
+// BatchVerifier collects ed25519 signatures and verifies all of them at once. Verifying a batch
+// of signatures is considerably cheaper than verifying each signature on its own, which helps
+// servers validating many certificates or long certificate bundles.
+// A BatchVerifier is not safe for concurrent use.
+type BatchVerifier struct {
+	// Concurrency is the maximum number of goroutines verifying signatures. Large batches are
+	// split into smaller batches verified in parallel. Values below 2 verify sequentially.
+	Concurrency int
+	entries     []batchEntry
+}
+
+// minParallelBatch is the minimum number of signatures of a batch verified by one goroutine.
+// Smaller batches lose the advantage of batch verification.
+const minParallelBatch = 16
+
+// NewBatchVerifier creates a new empty BatchVerifier
+func NewBatchVerifier() *BatchVerifier {
+	return &BatchVerifier{}
+}
+
+// Add queues a signature for verification with the next call to Verify
+func (b *BatchVerifier) Add(pubKey ed25519.PublicKey, message, sig []byte) {
+	b.entries = append(b.entries, batchEntry{
+		pubKey:  pubKey,
+		message: message,
+		sig:     sig,
+	})
+}
+
+// AddPrehashed queues an Ed25519ph signature of message for verification
+func (b *BatchVerifier) AddPrehashed(pubKey ed25519.PublicKey, message, sig []byte) {
+	b.entries = append(b.entries, batchEntry{
+		pubKey:    pubKey,
+		message:   message,
+		sig:       sig,
+		prehashed: true,
+	})
+}
+
+// Len returns the number of queued signatures
+func (b *BatchVerifier) Len() int {
+	return len(b.entries)
+}
+
+// Verify returns true if all queued signatures are valid. An empty batch is valid.
+// Batch verification uses the cofactored verification equation, like every signature check of
+// this package, so the result doesn't depend on the size of the batch. Callers needing to know
+// which signature is invalid can use VerifyEach.
+func (b *BatchVerifier) Verify() bool {
+	if b.Concurrency < 2 || len(b.entries) < 2*minParallelBatch {
+		return verifyBatch(b.entries)
+	}
+	chunks := b.Concurrency
+	if max := len(b.entries) / minParallelBatch; chunks > max {
+		chunks = max
+	}
+	results := make([]bool, chunks)
+	parallel(chunks, chunks, func(i int) {
+		results[i] = verifyBatch(b.entries[i*len(b.entries)/chunks : (i+1)*len(b.entries)/chunks])
+	})
+	for _, ok := range results {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func verifyBatch(entries []batchEntry) bool {
+	switch len(entries) {
+	case 0:
+		return true
+	case 1:
+		return entries[0].verify()
+	}
+
+	// We check that [8](sum(z_i*R_i) + sum(z_i*k_i*A_i) - sum(z_i*s_i)*B) is the identity for
+	// random 128 bit scalars z_i.
+	scalars := make([]*edwards25519.Scalar, 0, 2*len(entries)+1)
+	points := make([]*edwards25519.Point, 0, 2*len(entries)+1)
+	bCoefficient := edwards25519.NewScalar()
+
+	for _, e := range entries {
+		if len(e.pubKey) != ed25519.PublicKeySize || len(e.sig) != ed25519.SignatureSize {
+			return false
+		}
+		A, err := new(edwards25519.Point).SetBytes(e.pubKey)
+		if err != nil {
+			return false
+		}
+		R, err := new(edwards25519.Point).SetBytes(e.sig[:32])
+		if err != nil {
+			return false
+		}
+		s, err := edwards25519.NewScalar().SetCanonicalBytes(e.sig[32:])
+		if err != nil {
+			return false
+		}
+
+		var k *edwards25519.Scalar
+		if e.prehashed {
+			digest := sha512.Sum512(e.message)
+			k, err = ed25519phChallenge(e.sig[:32], e.pubKey, digest[:])
+		} else {
+			k, err = ed25519Challenge(e.sig[:32], e.pubKey, e.message)
+		}
+		if err != nil {
+			return false
+		}
+
+		z, err := randomBatchScalar()
+		if err != nil {
+			return false
+		}
+
+		bCoefficient.MultiplyAdd(z, s, bCoefficient)
+		scalars = append(scalars, z, edwards25519.NewScalar().Multiply(z, k))
+		points = append(points, R, A)
+	}
+	scalars = append(scalars, bCoefficient.Negate(bCoefficient))
+	points = append(points, edwards25519.NewGeneratorPoint())
+
+	check := new(edwards25519.Point).VarTimeMultiScalarMult(scalars, points)
+	check.MultByCofactor(check)
+	return check.Equal(edwards25519.NewIdentityPoint()) == 1
+}
+
+// VerifyEach verifies every queued signature separately and returns the result per signature
+// in the order they were added. The signatures are verified by up to Concurrency goroutines.
+func (b *BatchVerifier) VerifyEach() []bool {
+	results := make([]bool, len(b.entries))
+	parallel(len(b.entries), b.Concurrency, func(i int) {
+		results[i] = b.entries[i].verify()
+	})
+	return results
+}
+
+// verifySignature verifies the ed25519 signature sig of message with the cofactored equation of
+// the BatchVerifier. It accepts all signatures created by ed25519.Sign, but unlike ed25519.Verify
+// also signatures whose R has a small order component.
+func verifySignature(pubKey ed25519.PublicKey, message, sig []byte) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	k, err := ed25519Challenge(sig[:32], pubKey, message)
+	if err != nil {
+		return false
+	}
+	return checkEd25519Signature(pubKey, sig, k)
+}
+
+// ed25519Challenge computes the scalar k = SHA-512(R || A || M)
+func ed25519Challenge(R []byte, pubKey ed25519.PublicKey, message []byte) (*edwards25519.Scalar, error) {
+	h := sha512.New()
+	h.Write(R)
+	h.Write(pubKey)
+	h.Write(message)
+	return edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+}
+
+func randomBatchScalar() (*edwards25519.Scalar, error) {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:16]); err != nil {
+		return nil, err
+	}
+	return edwards25519.NewScalar().SetCanonicalBytes(buf[:])
+}
@@ -0,0 +1,170 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"filippo.io/edwards25519"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestBatchVerifier(t *testing.T) {
+	b := NewBatchVerifier()
+	assert.True(t, b.Verify())
+
+	for i := 0; i < 10; i++ {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		msg := []byte(fmt.Sprintf("message %d", i))
+		b.Add(pub, msg, ed25519.Sign(priv, msg))
+	}
+	assert.Equal(t, 10, b.Len())
+	assert.True(t, b.Verify())
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	b.Add(pub, []byte("signed message"), ed25519.Sign(priv, []byte("other message")))
+	assert.False(t, b.Verify())
+
+	results := b.VerifyEach()
+	require.Len(t, results, 11)
+	for i := 0; i < 10; i++ {
+		assert.True(t, results[i])
+	}
+	assert.False(t, results[10])
+}
+
+func TestBatchVerifierRejectsMalformedInput(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	msg := []byte("message")
+
+	b := NewBatchVerifier()
+	b.Add(pub, msg, ed25519.Sign(priv, msg))
+	b.Add(ed25519.PublicKey{0x01, 0x02}, msg, ed25519.Sign(priv, msg))
+	assert.False(t, b.Verify())
+
+	b = NewBatchVerifier()
+	b.Add(pub, msg, ed25519.Sign(priv, msg))
+	b.Add(pub, msg, []byte{0x01})
+	assert.False(t, b.Verify())
+}
+
+func TestValidateCertificates(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+	_, otherKey, err := SelfSignedCertificate("other root", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+
+	valid1, _, err := ClientCertificate("client1", 2, now.Add(-time.Minute), now.Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+	forged, _, err := ClientCertificate("client2", 3, now.Add(-time.Minute), now.Add(time.Hour), nil, otherKey, "root")
+	require.NoError(t, err)
+	expired, _, err := ClientCertificate("client3", 4, now.Add(-time.Hour), now.Add(-time.Minute), nil, rootKey, "root")
+	require.NoError(t, err)
+	valid2, _, err := ClientCertificate("client4", 5, now.Add(-time.Minute), now.Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+
+	pool := NewCertPool(rootCert)
+	errs := pool.ValidateCertificates(valid1, forged, expired, valid2)
+	require.Len(t, errs, 4)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.Error(t, errs[2])
+	assert.NoError(t, errs[3])
+}
+
+func TestBatchVerifierConcurrency(t *testing.T) {
+	b := NewBatchVerifier()
+	b.Concurrency = 4
+	for i := 0; i < 100; i++ {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		msg := []byte(fmt.Sprintf("message %d", i))
+		b.Add(pub, msg, ed25519.Sign(priv, msg))
+	}
+	assert.True(t, b.Verify())
+
+	// An invalid signature in any of the parallel batches fails the whole batch
+	b.entries[42].message = []byte("other message")
+	assert.False(t, b.Verify())
+	results := b.VerifyEach()
+	require.Len(t, results, 100)
+	for i, ok := range results {
+		assert.Equal(t, i != 42, ok)
+	}
+}
+
+// torsionSignature signs message with priv, but adds a point of order two to R. The signature
+// only satisfies the cofactored verification equation.
+func torsionSignature(t *testing.T, priv ed25519.PrivateKey, message []byte) []byte {
+	h := sha512.Sum512(priv.Seed())
+	a, err := edwards25519.NewScalar().SetBytesWithClamping(h[:32])
+	require.NoError(t, err)
+	r, err := randomBatchScalar()
+	require.NoError(t, err)
+	order2, err := hex.DecodeString("ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f")
+	require.NoError(t, err)
+	T, err := new(edwards25519.Point).SetBytes(order2)
+	require.NoError(t, err)
+	R := new(edwards25519.Point).ScalarBaseMult(r)
+	R.Add(R, T)
+	k, err := ed25519Challenge(R.Bytes(), priv.Public().(ed25519.PublicKey), message)
+	require.NoError(t, err)
+	S := edwards25519.NewScalar().MultiplyAdd(k, a, r)
+	return append(R.Bytes(), S.Bytes()...)
+}
+
+func TestBatchVerifierConsistency(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	message := []byte("message")
+	sig := torsionSignature(t, priv, message)
+	require.False(t, ed25519.Verify(pub, message, sig))
+
+	// The signature is accepted alone, in batches of any size and by VerifyEach
+	assert.True(t, verifySignature(pub, message, sig))
+	for _, size := range []int{1, 2, 10} {
+		b := NewBatchVerifier()
+		b.Add(pub, message, sig)
+		for i := 1; i < size; i++ {
+			b.Add(pub, message, ed25519.Sign(priv, message))
+		}
+		assert.True(t, b.Verify(), "batch of %d", size)
+		for _, ok := range b.VerifyEach() {
+			assert.True(t, ok)
+		}
+	}
+
+	// Certificates validate the same way alone and as part of a bundle
+	root, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SelfSignedCertificate("intermediate", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate.Issuer = "root"
+	intermediate, err = SignCertificate(intermediate, rootKey)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("leaf", 2, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	tbs, err := leaf.TBSBytes()
+	require.NoError(t, err)
+	leaf.Signature = torsionSignature(t, intermediateKey, tbs)
+	pool := NewCertPool(root)
+	assert.NoError(t, validateCertificate(leaf, intermediate.PubKey))
+	_, err = pool.ValidateBundle([]*Certificate{leaf, intermediate})
+	assert.NoError(t, err)
+
+	sig[40] ^= 1
+	assert.False(t, verifySignature(pub, message, sig))
+	b := NewBatchVerifier()
+	b.Add(pub, message, sig)
+	b.Add(pub, message, ed25519.Sign(priv, message))
+	assert.False(t, b.Verify())
+}
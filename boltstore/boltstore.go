@@ -0,0 +1,220 @@
+// Package boltstore implements a smolcert.Store in a single bbolt database file, so that a small
+// CA can be operated without any external infrastructure.
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketCertificates = []byte("certificates")
+	bucketSubjects     = []byte("subjects")
+	bucketRevocations  = []byte("revocations")
+	bucketMeta         = []byte("meta")
+
+	keyCRLNumber = []byte("crl_number")
+)
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// Store is a smolcert.Store backed by a bbolt database. Certificates are indexed by serial number
+// and subject.
+type Store struct {
+	db *bolt.DB
+}
+
+var _ smolcert.BatchStore = &Store{}
+
+// Open opens or creates the database at path. The database file is locked while the Store is
+// open, so it can only be used by a single process at a time.
+func Open(path string, mode os.FileMode) (*Store, error) {
+	db, err := bolt.Open(path, mode, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open CA database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketCertificates, bucketSubjects, bucketRevocations, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Failed to initialize CA database: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func serialKey(serialNumber uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, serialNumber)
+	return key
+}
+
+// subjectPrefix returns the prefix of the index entries of subject. The entries are followed by
+// the serial numbers, which keeps them sorted.
+func subjectPrefix(subject string) []byte {
+	return append([]byte(subject), 0)
+}
+
+// StoreCertificate implements smolcert.Store
+func (s *Store) StoreCertificate(cert *smolcert.Certificate) error {
+	return s.StoreCertificates([]*smolcert.Certificate{cert})
+}
+
+// StoreCertificates implements smolcert.BatchStore. All certificates are stored in a single
+// transaction.
+func (s *Store) StoreCertificates(certs []*smolcert.Certificate) error {
+	bufs := make([][]byte, len(certs))
+	for i, cert := range certs {
+		buf, err := cert.Bytes()
+		if err != nil {
+			return err
+		}
+		bufs[i] = buf
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for i, cert := range certs {
+			if err := putCertificate(tx, cert, bufs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func putCertificate(tx *bolt.Tx, cert *smolcert.Certificate, buf []byte) error {
+	key := serialKey(cert.SerialNumber)
+	certs := tx.Bucket(bucketCertificates)
+	if existing := certs.Get(key); existing != nil {
+		// The existing certificate is only informational, so it is omitted if it can't be parsed
+		existingCert, _ := smolcert.ParseBuf(existing)
+		return &smolcert.SerialNumberCollisionError{Issuer: cert.Issuer, SerialNumber: cert.SerialNumber,
+			Existing: existingCert}
+	}
+	if err := certs.Put(key, buf); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketSubjects).Put(append(subjectPrefix(cert.Subject), key...), []byte{})
+}
+
+// Certificate implements smolcert.Store
+func (s *Store) Certificate(serialNumber uint64) (cert *smolcert.Certificate, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket(bucketCertificates).Get(serialKey(serialNumber))
+		if buf == nil {
+			return smolcert.ErrNotFound
+		}
+		cert, err = smolcert.ParseBuf(buf)
+		return err
+	})
+	return
+}
+
+// CertificatesBySubject implements smolcert.Store
+func (s *Store) CertificatesBySubject(subject string) ([]*smolcert.Certificate, error) {
+	certs := []*smolcert.Certificate{}
+	prefix := subjectPrefix(subject)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		certBucket := tx.Bucket(bucketCertificates)
+		c := tx.Bucket(bucketSubjects).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if len(k) != len(prefix)+8 {
+				// Subjects containing zero bytes may share the prefix
+				continue
+			}
+			buf := certBucket.Get(k[len(prefix):])
+			if buf == nil {
+				return fmt.Errorf("CA database is inconsistent, certificate %x is missing", k[len(prefix):])
+			}
+			cert, err := smolcert.ParseBuf(buf)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// StoreRevocation implements smolcert.Store
+func (s *Store) StoreRevocation(entry smolcert.RevokedCertificate) error {
+	buf, err := cborEm.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRevocations).Put(serialKey(entry.SerialNumber), buf)
+	})
+}
+
+// Revocation implements smolcert.Store
+func (s *Store) Revocation(serialNumber uint64) (entry smolcert.RevokedCertificate, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		buf := tx.Bucket(bucketRevocations).Get(serialKey(serialNumber))
+		if buf == nil {
+			return smolcert.ErrNotFound
+		}
+		return cbor.Unmarshal(buf, &entry)
+	})
+	return
+}
+
+// Revocations implements smolcert.Store
+func (s *Store) Revocations() ([]smolcert.RevokedCertificate, error) {
+	revoked := []smolcert.RevokedCertificate{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRevocations).ForEach(func(k, v []byte) error {
+			var entry smolcert.RevokedCertificate
+			if err := cbor.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			revoked = append(revoked, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// NextCRLNumber implements smolcert.Store
+func (s *Store) NextCRLNumber() (number uint64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(bucketMeta)
+		if buf := meta.Get(keyCRLNumber); buf != nil {
+			number = binary.BigEndian.Uint64(buf)
+		}
+		number++
+		return meta.Put(keyCRLNumber, serialKey(number))
+	})
+	return
+}
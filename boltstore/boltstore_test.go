@@ -0,0 +1,89 @@
+package boltstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.db")
+
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	store, err := Open(path, 0600)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCAWithStore(rootCert, rootKey, store)
+	require.NoError(t, err)
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	first, err := ca.Issue("device", pubKey, smolcert.WithSerialNumber(1<<40))
+	require.NoError(t, err)
+	second, err := ca.Issue("device", pubKey, smolcert.WithSerialNumber(7))
+	require.NoError(t, err)
+	other, err := ca.Issue("device\x00x", pubKey)
+	require.NoError(t, err)
+	_, err = ca.Issue("device", pubKey, smolcert.WithSerialNumber(7))
+	assert.True(t, errors.Is(err, smolcert.ErrSerialNumberExists))
+	dup, _, err := smolcert.ClientCertificate("device", 7, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	var collision *smolcert.SerialNumberCollisionError
+	require.True(t, errors.As(store.StoreCertificate(dup), &collision))
+	assert.Equal(t, second.Signature, collision.Existing.Signature)
+	// Batches are written in a single transaction, a collision discards the whole batch
+	batch, err := ca.IssueBatch([]smolcert.BatchRequest{{Subject: "batch", PubKey: pubKey}}, smolcert.WithSerialNumber(8))
+	require.NoError(t, err)
+	dup, _, err = smolcert.ClientCertificate("batch", 9, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	assert.True(t, errors.Is(store.StoreCertificates([]*smolcert.Certificate{dup, batch[0]}), smolcert.ErrSerialNumberExists))
+	_, err = store.Certificate(9)
+	assert.True(t, errors.Is(err, smolcert.ErrNotFound))
+	require.NoError(t, ca.Revoke(first.SerialNumber, smolcert.RevocationReasonKeyCompromise))
+	crl, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), crl.Number)
+	require.NoError(t, store.Close())
+
+	// All state survives reopening the database
+	store, err = Open(path, 0600)
+	require.NoError(t, err)
+	defer store.Close()
+	cert, err := store.Certificate(second.SerialNumber)
+	require.NoError(t, err)
+	assert.Equal(t, second, cert)
+	_, err = store.Certificate(3)
+	assert.True(t, errors.Is(err, smolcert.ErrNotFound))
+
+	certs, err := store.CertificatesBySubject("device")
+	require.NoError(t, err)
+	assert.Equal(t, []*smolcert.Certificate{second, first}, certs)
+	certs, err = store.CertificatesBySubject(other.Subject)
+	require.NoError(t, err)
+	assert.Equal(t, []*smolcert.Certificate{other}, certs)
+
+	entry, err := store.Revocation(first.SerialNumber)
+	require.NoError(t, err)
+	assert.Equal(t, smolcert.RevocationReasonKeyCompromise, entry.Reason)
+	_, err = store.Revocation(second.SerialNumber)
+	assert.True(t, errors.Is(err, smolcert.ErrNotFound))
+
+	ca, err = smolcert.NewCAWithStore(rootCert, rootKey, store)
+	require.NoError(t, err)
+	crl, err = ca.GenerateCRL()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), crl.Number)
+	require.NoError(t, crl.Verify(rootCert))
+	assert.True(t, errors.Is(crl.Check(first), smolcert.ErrRevoked))
+}
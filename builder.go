@@ -0,0 +1,211 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// CertificateBuilder constructs certificates via chained setters, i.e.
+//
+//	cert, err := NewCertificateBuilder().
+//		Subject("device").
+//		PublicKey(pub).
+//		IssuedBy(rootCert).
+//		ValidFor(time.Hour * 24).
+//		KeyUsage(KeyUsageClientIdentification).
+//		SignWith(rootKey)
+//
+// Errors of the setters are collected and returned by Build or SignWith.
+type CertificateBuilder struct {
+	cert         Certificate
+	serialSource func() (uint64, error)
+	notBefore    time.Time
+	notAfter     time.Time
+	validFor     time.Duration
+	now          time.Time
+	caller       string
+	rand         io.Reader
+	profile      *Profile
+	errs         []string
+}
+
+// NewCertificateBuilder creates a new CertificateBuilder
+func NewCertificateBuilder() *CertificateBuilder {
+	return &CertificateBuilder{}
+}
+
+// Version sets the version of the certificate format, Version1 is used if not set
+func (b *CertificateBuilder) Version(version uint64) *CertificateBuilder {
+	if version > LatestVersion {
+		b.errs = append(b.errs, "unsupported version")
+	}
+	b.cert.Version = version
+	return b
+}
+
+// SerialNumber sets the serial number, a random serial number is used if not set
+func (b *CertificateBuilder) SerialNumber(serialNumber uint64) *CertificateBuilder {
+	b.cert.SerialNumber = serialNumber
+	return b
+}
+
+// Subject sets the subject
+func (b *CertificateBuilder) Subject(subject string) *CertificateBuilder {
+	b.cert.Subject = subject
+	return b
+}
+
+// Issuer sets the name of the issuer
+func (b *CertificateBuilder) Issuer(issuer string) *CertificateBuilder {
+	b.cert.Issuer = issuer
+	return b
+}
+
+// IssuedBy sets the issuer to the subject of issuer
+func (b *CertificateBuilder) IssuedBy(issuer *Certificate) *CertificateBuilder {
+	return b.Issuer(issuer.Subject)
+}
+
+// SelfSigned sets the issuer to the subject, the certificate needs to be signed with its own key
+func (b *CertificateBuilder) SelfSigned() *CertificateBuilder {
+	return b.Issuer(b.cert.Subject)
+}
+
+// PublicKey sets the public key of the subject
+func (b *CertificateBuilder) PublicKey(pubKey ed25519.PublicKey) *CertificateBuilder {
+	if len(pubKey) != ed25519.PublicKeySize {
+		b.errs = append(b.errs, "invalid public key")
+	}
+	b.cert.PubKey = pubKey
+	return b
+}
+
+// NotBefore sets the start of the validity
+func (b *CertificateBuilder) NotBefore(t time.Time) *CertificateBuilder {
+	b.notBefore = t
+	return b
+}
+
+// NotAfter sets the end of the validity
+func (b *CertificateBuilder) NotAfter(t time.Time) *CertificateBuilder {
+	b.notAfter = t
+	b.validFor = 0
+	return b
+}
+
+// ValidFor sets the end of the validity relative to its start, which defaults to now
+func (b *CertificateBuilder) ValidFor(d time.Duration) *CertificateBuilder {
+	if d <= 0 {
+		b.errs = append(b.errs, "validity needs to be positive")
+	}
+	b.validFor = d
+	b.notAfter = time.Time{}
+	return b
+}
+
+// AddExtension adds an extension, replacing an existing extension with the same OID
+func (b *CertificateBuilder) AddExtension(ext Extension) *CertificateBuilder {
+	b.cert.Extensions = ensureExtension(b.cert.Extensions, ext)
+	return b
+}
+
+// KeyUsage sets the KeyUsage extension
+func (b *CertificateBuilder) KeyUsage(keyUsage KeyUsage) *CertificateBuilder {
+	return b.AddExtension(Extension{
+		OID:      OIDKeyUsage,
+		Critical: true,
+		Value:    keyUsage.ToBytes(),
+	})
+}
+
+// Profile applies p when building the certificate, the profile takes precedence over KeyUsage
+// and extensions set on the builder.
+func (b *CertificateBuilder) Profile(p *Profile) *CertificateBuilder {
+	b.profile = p
+	return b
+}
+
+// Build validates the required fields and returns the unsigned certificate
+func (b *CertificateBuilder) Build() (*Certificate, error) {
+	errs := append([]string{}, b.errs...)
+	if b.cert.Subject == "" {
+		errs = append(errs, "subject is required")
+	}
+	if b.cert.Issuer == "" {
+		errs = append(errs, "issuer is required")
+	}
+	if b.cert.PubKey == nil {
+		errs = append(errs, "public key is required")
+	}
+	notBefore, notAfter := b.notBefore, b.notAfter
+	if b.validFor > 0 {
+		if notBefore.IsZero() {
+			notBefore = b.issueTime()
+		}
+		notAfter = notBefore.Add(b.validFor)
+	}
+	if !notBefore.IsZero() && !notAfter.IsZero() && !notAfter.After(notBefore) {
+		errs = append(errs, "validity ends before it starts")
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Invalid certificate: %s", strings.Join(errs, ", "))
+	}
+
+	cert := b.cert.Copy()
+	// Without explicit validity the default validity of the profile is used
+	if b.profile == nil || !notBefore.IsZero() || !notAfter.IsZero() {
+		cert.Validity = NewMillisValidity(notBefore, notAfter)
+		if cert.Version < Version3 {
+			// Older versions can't encode the milliseconds
+			cert.Validity.NotBeforeMillis, cert.Validity.NotAfterMillis = 0, 0
+		}
+	}
+	if cert.Extensions == nil {
+		cert.Extensions = []Extension{}
+	}
+	if cert.SerialNumber == 0 {
+		serialSource := b.serialSource
+		if serialSource == nil {
+			serialSource = func() (uint64, error) {
+				return RandomSerialNumberFrom(b.rand)
+			}
+		}
+		serialNumber, err := serialSource()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create serial number: %w", err)
+		}
+		cert.SerialNumber = serialNumber
+	}
+	if b.profile != nil {
+		if err := b.profile.apply(cert, b.issueTime()); err != nil {
+			return nil, err
+		}
+	}
+	return cert, nil
+}
+
+// issueTime is the start of relative validities, the current time unless the builder has been
+// created for a batch
+func (b *CertificateBuilder) issueTime() time.Time {
+	if b.now.IsZero() {
+		return time.Now()
+	}
+	return b.now
+}
+
+// SignWith builds the certificate and signs it with issuerKey
+func (b *CertificateBuilder) SignWith(issuerKey ed25519.PrivateKey) (*Certificate, error) {
+	if len(issuerKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid issuer key")
+	}
+	cert, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return SignCertificate(cert, issuerKey)
+}
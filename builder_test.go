@@ -0,0 +1,79 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCertificateBuilder(t *testing.T) {
+	rootPub, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	root, err := NewCertificateBuilder().
+		Subject("root").
+		SelfSigned().
+		PublicKey(rootPub).
+		KeyUsage(KeyUsageSignCert).
+		SignWith(rootKey)
+	require.NoError(t, err)
+	assert.Equal(t, "root", root.Issuer)
+	assert.NotZero(t, root.SerialNumber)
+	assert.True(t, root.Validity.NotAfter.IsZero())
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := NewCertificateBuilder().
+		SerialNumber(42).
+		Subject("device").
+		PublicKey(pub).
+		IssuedBy(root).
+		ValidFor(time.Hour).
+		KeyUsage(KeyUsageClientIdentification).
+		SignWith(rootKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), cert.SerialNumber)
+	assert.Equal(t, "root", cert.Issuer)
+	assert.Equal(t, time.Hour, cert.Validity.NotAfter.StdTime().Sub(cert.Validity.NotBefore.StdTime()))
+	assert.NoError(t, NewCertPool(root).Validate(cert))
+	assert.NoError(t, RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageClientIdentification)))
+}
+
+func TestCertificateBuilderProfile(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := NewCertificateBuilder().Subject("server").Issuer("root").PublicKey(pub).Profile(ProfileServer).Build()
+	require.NoError(t, err)
+	assert.NoError(t, ProfileServer.Check(cert))
+	assert.False(t, cert.Validity.NotAfter.IsZero())
+	assert.Empty(t, cert.Signature)
+
+	// Explicit validity is checked against the profile
+	_, err = NewCertificateBuilder().Subject("server").Issuer("root").PublicKey(pub).
+		Profile(ProfileServer).ValidFor(time.Hour * 24 * 1000).Build()
+	assert.Error(t, err)
+}
+
+func TestCertificateBuilderValidation(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	now := time.Now()
+
+	for name, b := range map[string]*CertificateBuilder{
+		"no subject":     NewCertificateBuilder().Issuer("root").PublicKey(pub),
+		"no issuer":      NewCertificateBuilder().Subject("device").PublicKey(pub),
+		"no key":         NewCertificateBuilder().Subject("device").Issuer("root"),
+		"invalid key":    NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pub[:5]),
+		"negative":       NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pub).ValidFor(-time.Hour),
+		"reversed times": NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pub).NotBefore(now).NotAfter(now.Add(-time.Hour)),
+	} {
+		_, err := b.SignWith(priv)
+		assert.Error(t, err, name)
+	}
+
+	_, err = NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pub).SignWith(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+package smolcert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Bundle is an ordered certificate chain. The first certificate is the leaf, every following
+// certificate is the issuer of the certificate before it. The root certificate is usually not part
+// of the Bundle, it is expected to be found in the CertPool of the validating party.
+type Bundle struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Certificates contains the leaf certificate first, followed by the intermediates
+	Certificates []*Certificate `cbor:"certificates"`
+	// RootHints optionally contains the Pins of the roots the chain is issued by. During validation
+	// only the hinted roots of the CertPool are considered, if the CertPool contains any of them.
+	RootHints []Pin `cbor:"root_hints"`
+}
+
+// NewBundle creates a Bundle from the leaf certificate followed by its intermediates
+func NewBundle(certs ...*Certificate) *Bundle {
+	return &Bundle{Certificates: append([]*Certificate{}, certs...)}
+}
+
+// ParseBundle parses a Bundle from an io.Reader
+func ParseBundle(r io.Reader) (*Bundle, error) {
+	b := &Bundle{}
+	if err := cbor.NewDecoder(r).Decode(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ParseBundleBuf parses a Bundle from an existing byte buffer
+func ParseBundleBuf(buf []byte) (*Bundle, error) {
+	b := &Bundle{}
+	if err := cbor.Unmarshal(buf, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// SerializeBundle serializes a Bundle to an io.Writer
+func SerializeBundle(b *Bundle, w io.Writer) error {
+	return cborEm.NewEncoder(w).Encode(b.canonical())
+}
+
+// Bytes returns the canonical CBOR encoding of the Bundle
+func (b *Bundle) Bytes() ([]byte, error) {
+	return cborEm.Marshal(b.canonical())
+}
+
+// canonical returns the Bundle with empty instead of nil slices, so that equal bundles always
+// have the same encoding
+func (b *Bundle) canonical() *Bundle {
+	c := &Bundle{Certificates: b.Certificates, RootHints: b.RootHints}
+	if c.Certificates == nil {
+		c.Certificates = []*Certificate{}
+	}
+	if c.RootHints == nil {
+		c.RootHints = []Pin{}
+	}
+	return c
+}
+
+// Leaf returns the first certificate of the Bundle or nil if the Bundle is empty
+func (b *Bundle) Leaf() *Certificate {
+	if len(b.Certificates) == 0 {
+		return nil
+	}
+	return b.Certificates[0]
+}
+
+// Append adds certificates to the end of the chain. Every certificate needs to be the issuer of
+// the certificate before it. The names are compared under the most lenient NameMatching, whether
+// they match under the NameMatching of a Validator is checked during the validation.
+func (b *Bundle) Append(certs ...*Certificate) error {
+	for _, cert := range certs {
+		if last := len(b.Certificates) - 1; last >= 0 && indexKey(b.Certificates[last].Issuer) != indexKey(cert.Subject) {
+			return fmt.Errorf("Certificate '%s' is not the issuer '%s' of the last certificate of the bundle",
+				cert.Subject, b.Certificates[last].Issuer)
+		}
+		b.Certificates = append(b.Certificates, cert)
+	}
+	return nil
+}
+
+// AddRootHint adds the Pin of root to the RootHints of the Bundle
+func (b *Bundle) AddRootHint(root *Certificate) {
+	pin := PinFromCertificate(root)
+	for _, hint := range b.RootHints {
+		if hint == pin {
+			return
+		}
+	}
+	b.RootHints = append(b.RootHints, pin)
+}
+
+// Validate validates the Bundle against pool and returns the leaf certificate
+func (b *Bundle) Validate(pool *CertPool) (*Certificate, error) {
+	return pool.validateChain(b, &chainVerifier{ctx: context.Background()})
+}
+
+// ValidateWith validates the Bundle with the Validator v and returns the leaf certificate
+func (b *Bundle) ValidateWith(v *Validator) (*Certificate, error) {
+	return b.ValidateWithContext(context.Background(), v)
+}
+
+// ValidateWithContext validates the Bundle like ValidateWith, ctx is passed to the hooks of v
+func (b *Bundle) ValidateWithContext(ctx context.Context, v *Validator) (*Certificate, error) {
+	cv := v.chainVerifier(ctx)
+	leaf, err := v.Pool.validateChain(b, cv)
+	if err != nil {
+		return nil, err
+	}
+	if err := cv.opts.checkLeaf(leaf); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+func (c *CertPool) validateChain(b *Bundle, v *chainVerifier) (*Certificate, error) {
+	leaf := b.Leaf()
+	if leaf == nil {
+		return nil, errors.New("Certificate bundle is empty")
+	}
+	pool := c.hinted(b.RootHints)
+	if len(b.Certificates) == 1 {
+		if err := pool.validate(leaf, v); err != nil {
+			return nil, err
+		}
+		if err := v.finish(); err != nil {
+			return nil, err
+		}
+		return leaf, nil
+	}
+	validated, err := pool.validateBundle(b.Certificates, v)
+	if err != nil {
+		return nil, err
+	}
+	if validated != leaf {
+		return nil, errors.New("First certificate of the bundle is not the leaf certificate")
+	}
+	return leaf, nil
+}
+
+// hinted returns a CertPool containing only the roots of c with one of the given Pins. If c
+// doesn't contain any of them, c is returned.
+func (c *CertPool) hinted(hints []Pin) *CertPool {
+	p := &CertPool{}
+	for _, hint := range hints {
+		if cert, found := c.roots[hint.String()]; found {
+			p.add(cert)
+		}
+	}
+	if p.Len() == 0 {
+		return c
+	}
+	return p
+}
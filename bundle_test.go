@@ -0,0 +1,100 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundle(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	b := NewBundle(leaf)
+	assert.Error(t, b.Append(rootCert))
+	require.NoError(t, b.Append(intermediate))
+	b.AddRootHint(rootCert)
+	b.AddRootHint(rootCert)
+	assert.Len(t, b.RootHints, 1)
+	assert.Equal(t, leaf, b.Leaf())
+
+	validated, err := b.Validate(pool)
+	require.NoError(t, err)
+	assert.Equal(t, leaf, validated)
+
+	buf, err := b.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBundleBuf(buf)
+	require.NoError(t, err)
+	require.Len(t, parsed.Certificates, 2)
+	assert.Equal(t, b.RootHints, parsed.RootHints)
+	_, err = parsed.Validate(pool)
+	assert.NoError(t, err)
+
+	w := &bytes.Buffer{}
+	require.NoError(t, SerializeBundle(b, w))
+	assert.Equal(t, buf, w.Bytes())
+	parsed, err = ParseBundle(w)
+	require.NoError(t, err)
+	assert.Len(t, parsed.Certificates, 2)
+
+	// The leaf needs to be the first certificate
+	_, err = (&Bundle{Certificates: []*Certificate{intermediate, leaf}}).Validate(pool)
+	assert.Error(t, err)
+	_, err = NewBundle().Validate(pool)
+	assert.Error(t, err)
+	assert.Nil(t, NewBundle().Leaf())
+
+	emptyBuf, err := (&Bundle{}).Bytes()
+	require.NoError(t, err)
+	emptyBuf2, err := NewBundle().Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, emptyBuf, emptyBuf2)
+}
+
+func TestBundleRootHints(t *testing.T) {
+	oldRoot, oldKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	newRoot, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, oldKey, "root")
+	require.NoError(t, err)
+	pool := NewCertPool(oldRoot, newRoot)
+
+	b := NewBundle(leaf)
+	b.AddRootHint(oldRoot)
+	_, err = b.Validate(pool)
+	assert.NoError(t, err)
+
+	// Only the hinted roots are considered
+	b = NewBundle(leaf)
+	b.AddRootHint(newRoot)
+	_, err = b.Validate(pool)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+
+	// Hints for unknown roots are ignored
+	_, err = b.Validate(NewCertPool(oldRoot))
+	assert.NoError(t, err)
+}
+
+func TestBundleValidateWith(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	v := NewValidator(NewCertPool(rootCert), func(cert, issuer *Certificate) error {
+		return errors.New("Rejected")
+	})
+	_, err = NewBundle(leaf).ValidateWith(v)
+	assert.True(t, errors.Is(err, ErrRejectedByHook))
+}
@@ -0,0 +1,370 @@
+package smolcert
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Certificate types of C509 certificates as defined by draft-ietf-cose-cbor-encoded-cert
+const (
+	// C509TypeNative is the type of natively signed C509 certificates
+	C509TypeNative = 2
+	// C509TypeReencoded is the type of CBOR re-encoded X.509 certificates, which are not supported
+	C509TypeReencoded = 3
+)
+
+// Algorithm identifiers and extension IDs of the C509 registries used for the conversion
+const (
+	c509SigAlgEd25519    = 12
+	c509PubKeyAlgEd25519 = 10
+
+	c509AttributeCommonName = 1
+
+	c509ExtBasicConstraints = 4
+	c509ExtKeyUsage         = 2
+	c509ExtExtendedKeyUsage = 8
+
+	c509KeyUsageDigitalSignature = 1 << 0
+	c509KeyUsageKeyCertSign      = 1 << 5
+	c509KeyUsageCRLSign          = 1 << 6
+	c509BasicConstraintsCA       = -2
+
+	c509EKUServerAuth   = 1
+	c509EKUClientAuth   = 2
+	c509EKUTimeStamping = 8
+)
+
+// C509Certificate is a natively signed certificate in the C509 format of
+// draft-ietf-cose-cbor-encoded-cert, allowing to exchange certificates with other implementations
+// of CBOR encoded certificates. Only Ed25519 keys and the KeyUsage extension can be converted, the
+// KeyUsage is mapped to the keyUsage, extendedKeyUsage and basicConstraints extensions of C509.
+// As the signatures of both formats cover different encodings, every conversion needs to be
+// signed by the issuer again.
+type C509Certificate struct {
+	SerialNumber uint64
+	Issuer       string
+	Validity     Validity
+	Subject      string
+	PubKey       ed25519.PublicKey
+	// KeyUsage is zero if the certificate doesn't restrict the usage of its key
+	KeyUsage  KeyUsage
+	Signature []byte
+
+	tbs []byte
+}
+
+// NewC509Certificate converts cert into a C509 certificate signed with issuerKey
+func NewC509Certificate(cert *Certificate, issuerKey ed25519.PrivateKey) (*C509Certificate, error) {
+	if len(issuerKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid issuer key")
+	}
+	if cert.SignatureAlgorithm != AlgorithmEd25519 || len(cert.PubKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Certificates with %s keys can't be converted to C509", cert.SignatureAlgorithm)
+	}
+	c := &C509Certificate{
+		SerialNumber: cert.SerialNumber,
+		Issuer:       cert.Issuer,
+		Subject:      cert.Subject,
+		PubKey:       cert.PubKey,
+	}
+	if cert.Validity != nil {
+		c.Validity = *cert.Validity
+	}
+	for _, ext := range cert.Extensions {
+		if ext.OID != OIDKeyUsage {
+			return nil, fmt.Errorf("Extension 0x%x has no C509 equivalent", ext.OID)
+		}
+		keyUsage, err := ParseKeyUsage(ext.Value)
+		if err != nil {
+			return nil, err
+		}
+		c.KeyUsage = keyUsage
+	}
+	tbs, err := c.encodeTBS()
+	if err != nil {
+		return nil, err
+	}
+	c.tbs = tbs
+	c.Signature = ed25519.Sign(issuerKey, tbs)
+	return c, nil
+}
+
+func (c *C509Certificate) encodeTBS() ([]byte, error) {
+	serial := make([]byte, 8)
+	binary.BigEndian.PutUint64(serial, c.SerialNumber)
+	serial = bytes.TrimLeft(serial, "\x00")
+	var issuer interface{} = c.Issuer
+	if c.Issuer == c.Subject {
+		// A null issuer denotes a self issued certificate
+		issuer = nil
+	}
+	var notAfter interface{}
+	if !c.Validity.NotAfter.IsZero() {
+		notAfter = int64(c.Validity.NotAfter)
+	}
+	extensions, err := c.encodeExtensions()
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	for _, item := range []interface{}{
+		C509TypeNative,
+		serial,
+		c509SigAlgEd25519,
+		issuer,
+		int64(c.Validity.NotBefore),
+		notAfter,
+		c.Subject,
+		c509PubKeyAlgEd25519,
+		[]byte(c.PubKey),
+		extensions,
+	} {
+		encoded, err := cborEm.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeExtensions maps the KeyUsage to C509 extensions. Negative IDs mark critical extensions.
+func (c *C509Certificate) encodeExtensions() ([]interface{}, error) {
+	switch c.KeyUsage {
+	case 0:
+		return []interface{}{}, nil
+	case KeyUsageSignCert:
+		return []interface{}{
+			-c509ExtKeyUsage, c509KeyUsageKeyCertSign | c509KeyUsageCRLSign,
+			-c509ExtBasicConstraints, c509BasicConstraintsCA,
+		}, nil
+	case KeyUsageClientIdentification:
+		return endEntityExtensions(c509EKUClientAuth), nil
+	case KeyUsageServerIdentification:
+		return endEntityExtensions(c509EKUServerAuth), nil
+	case KeyUsageTimestamping:
+		return endEntityExtensions(c509EKUTimeStamping), nil
+	default:
+		return nil, fmt.Errorf("%s has no C509 equivalent", c.KeyUsage)
+	}
+}
+
+func endEntityExtensions(eku int) []interface{} {
+	return []interface{}{
+		-c509ExtKeyUsage, c509KeyUsageDigitalSignature,
+		-c509ExtExtendedKeyUsage, eku,
+	}
+}
+
+// Bytes returns the encoded C509 certificate
+func (c *C509Certificate) Bytes() ([]byte, error) {
+	if c.tbs == nil {
+		return nil, errors.New("C509 certificate is not signed")
+	}
+	sig, err := cborEm.Marshal(c.Signature)
+	if err != nil {
+		return nil, err
+	}
+	// The certificate is an array of the TBS fields followed by the signature
+	buf := []byte{0x8b}
+	buf = append(buf, c.tbs...)
+	return append(buf, sig...), nil
+}
+
+// ParseC509Certificate parses a natively signed C509 certificate with an Ed25519 key
+func ParseC509Certificate(buf []byte) (*C509Certificate, error) {
+	var items []cbor.RawMessage
+	if err := cbor.Unmarshal(buf, &items); err != nil {
+		return nil, fmt.Errorf("Failed to parse C509 certificate: %w", err)
+	}
+	if len(items) != 11 {
+		return nil, fmt.Errorf("C509 certificate needs to have 11 fields, not %d", len(items))
+	}
+	var certType int
+	if err := cbor.Unmarshal(items[0], &certType); err != nil {
+		return nil, fmt.Errorf("Invalid C509 certificate type: %w", err)
+	}
+	if certType != C509TypeNative {
+		return nil, fmt.Errorf("Unsupported C509 certificate type %d", certType)
+	}
+	c := &C509Certificate{}
+	var serial []byte
+	if err := cbor.Unmarshal(items[1], &serial); err != nil || len(serial) > 8 {
+		return nil, errors.New("Invalid C509 serial number")
+	}
+	for _, b := range serial {
+		c.SerialNumber = c.SerialNumber<<8 | uint64(b)
+	}
+	if err := expectC509Algorithm(items[2], c509SigAlgEd25519); err != nil {
+		return nil, err
+	}
+	subject, err := parseC509Name(items[6])
+	if err != nil {
+		return nil, err
+	}
+	c.Subject = subject
+	if bytes.Equal(items[3], []byte{0xf6}) {
+		c.Issuer = subject
+	} else if c.Issuer, err = parseC509Name(items[3]); err != nil {
+		return nil, err
+	}
+	var notBefore int64
+	var notAfter *int64
+	if err := cbor.Unmarshal(items[4], &notBefore); err != nil {
+		return nil, fmt.Errorf("Invalid C509 validity: %w", err)
+	}
+	if err := cbor.Unmarshal(items[5], &notAfter); err != nil {
+		return nil, fmt.Errorf("Invalid C509 validity: %w", err)
+	}
+	c.Validity.NotBefore = Time(notBefore)
+	if notAfter != nil {
+		c.Validity.NotAfter = Time(*notAfter)
+	}
+	if err := expectC509Algorithm(items[7], c509PubKeyAlgEd25519); err != nil {
+		return nil, err
+	}
+	var pubKey []byte
+	if err := cbor.Unmarshal(items[8], &pubKey); err != nil || len(pubKey) != ed25519.PublicKeySize {
+		return nil, errors.New("Invalid C509 public key")
+	}
+	c.PubKey = pubKey
+	if c.KeyUsage, err = parseC509Extensions(items[9]); err != nil {
+		return nil, err
+	}
+	if err := cbor.Unmarshal(items[10], &c.Signature); err != nil {
+		return nil, fmt.Errorf("Invalid C509 signature: %w", err)
+	}
+	for _, item := range items[:10] {
+		c.tbs = append(c.tbs, item...)
+	}
+	return c, nil
+}
+
+func expectC509Algorithm(raw cbor.RawMessage, expected int) error {
+	var alg int
+	if err := cbor.Unmarshal(raw, &alg); err != nil || alg != expected {
+		return errors.New("Only Ed25519 C509 certificates are supported")
+	}
+	return nil
+}
+
+// parseC509Name parses names consisting of a single common name, which is either encoded as text
+// or as attribute list
+func parseC509Name(raw cbor.RawMessage) (string, error) {
+	var name string
+	if err := cbor.Unmarshal(raw, &name); err == nil {
+		return name, nil
+	}
+	var attrs []cbor.RawMessage
+	if err := cbor.Unmarshal(raw, &attrs); err != nil || len(attrs) != 2 {
+		return "", errors.New("Only C509 names consisting of a common name are supported")
+	}
+	var attrType int
+	if err := cbor.Unmarshal(attrs[0], &attrType); err != nil ||
+		(attrType != c509AttributeCommonName && attrType != -c509AttributeCommonName) {
+		return "", errors.New("Only C509 names consisting of a common name are supported")
+	}
+	if err := cbor.Unmarshal(attrs[1], &name); err != nil {
+		return "", fmt.Errorf("Invalid C509 common name: %w", err)
+	}
+	return name, nil
+}
+
+func parseC509Extensions(raw cbor.RawMessage) (KeyUsage, error) {
+	var keyUsageBits int64
+	// A single integer is a shortcut for a non critical keyUsage extension
+	if err := cbor.Unmarshal(raw, &keyUsageBits); err == nil {
+		return c509KeyUsage(keyUsageBits, false, nil), nil
+	}
+	var items []cbor.RawMessage
+	if err := cbor.Unmarshal(raw, &items); err != nil || len(items)%2 != 0 {
+		return 0, errors.New("Invalid C509 extensions")
+	}
+	isCA := false
+	var ekus []int64
+	for i := 0; i < len(items); i += 2 {
+		var id int64
+		if err := cbor.Unmarshal(items[i], &id); err != nil {
+			return 0, errors.New("Only C509 extensions with integer IDs are supported")
+		}
+		critical := id < 0
+		if critical {
+			id = -id
+		}
+		switch id {
+		case c509ExtKeyUsage:
+			if err := cbor.Unmarshal(items[i+1], &keyUsageBits); err != nil {
+				return 0, fmt.Errorf("Invalid C509 keyUsage: %w", err)
+			}
+		case c509ExtBasicConstraints:
+			var pathLen int64
+			if err := cbor.Unmarshal(items[i+1], &pathLen); err != nil {
+				return 0, fmt.Errorf("Invalid C509 basicConstraints: %w", err)
+			}
+			isCA = pathLen != -1
+		case c509ExtExtendedKeyUsage:
+			var single int64
+			if err := cbor.Unmarshal(items[i+1], &single); err == nil {
+				ekus = []int64{single}
+			} else if err := cbor.Unmarshal(items[i+1], &ekus); err != nil {
+				return 0, fmt.Errorf("Invalid C509 extendedKeyUsage: %w", err)
+			}
+		default:
+			if critical {
+				return 0, fmt.Errorf("%w: C509 extension %d", ErrUnknownCriticalExtension, id)
+			}
+		}
+	}
+	return c509KeyUsage(keyUsageBits, isCA, ekus), nil
+}
+
+func c509KeyUsage(keyUsageBits int64, isCA bool, ekus []int64) KeyUsage {
+	if isCA || keyUsageBits&c509KeyUsageKeyCertSign != 0 {
+		return KeyUsageSignCert
+	}
+	for _, eku := range ekus {
+		switch eku {
+		case c509EKUClientAuth:
+			return KeyUsageClientIdentification
+		case c509EKUServerAuth:
+			return KeyUsageServerIdentification
+		case c509EKUTimeStamping:
+			return KeyUsageTimestamping
+		}
+	}
+	return 0
+}
+
+// Verify checks that the C509 certificate is signed by issuer
+func (c *C509Certificate) Verify(issuer *Certificate) error {
+	if c.Issuer != issuer.Subject {
+		return fmt.Errorf("C509 certificate has been issued by '%s', not by '%s'", c.Issuer, issuer.Subject)
+	}
+	if !verifySignature(issuer.PubKey, c.tbs, c.Signature) {
+		return &ValidationError{Reason: ErrBadSignature, Subject: c.Subject, Message: "Signature validation of C509 certificate failed"}
+	}
+	return nil
+}
+
+// Certificate converts the C509 certificate into a smolcert signed with issuerKey
+func (c *C509Certificate) Certificate(issuerKey ed25519.PrivateKey) (*Certificate, error) {
+	validity := c.Validity
+	cert := &Certificate{
+		SerialNumber:       c.SerialNumber,
+		Issuer:             c.Issuer,
+		Validity:           &validity,
+		Subject:            c.Subject,
+		PubKey:             c.PubKey,
+		Extensions:         []Extension{},
+		SignatureAlgorithm: AlgorithmEd25519,
+	}
+	if c.KeyUsage != 0 {
+		cert.Extensions = append(cert.Extensions, Extension{OID: OIDKeyUsage, Critical: true, Value: c.KeyUsage.ToBytes()})
+	}
+	return SignCertificate(cert, issuerKey)
+}
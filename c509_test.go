@@ -0,0 +1,93 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestC509Conversion(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Unix(1600000000, 0), time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 0x0102, time.Unix(1600000000, 0), time.Unix(4100000000, 0), nil,
+		rootKey, "root")
+	require.NoError(t, err)
+
+	c509, err := NewC509Certificate(leaf, rootKey)
+	require.NoError(t, err)
+	buf, err := c509.Bytes()
+	require.NoError(t, err)
+	// Array of 11, type 2, serial h'0102', Ed25519 signature algorithm 12, issuer "root"
+	assert.Equal(t, []byte{0x8b, 0x02, 0x42, 0x01, 0x02, 0x0c, 0x64, 'r', 'o', 'o', 't'}, buf[:11])
+
+	parsed, err := ParseC509Certificate(buf)
+	require.NoError(t, err)
+	require.NoError(t, parsed.Verify(rootCert))
+	assert.Equal(t, uint64(0x0102), parsed.SerialNumber)
+	assert.Equal(t, "root", parsed.Issuer)
+	assert.Equal(t, "device", parsed.Subject)
+	assert.Equal(t, leaf.PubKey, parsed.PubKey)
+	assert.Equal(t, *leaf.Validity, parsed.Validity)
+	assert.Equal(t, KeyUsageClientIdentification, parsed.KeyUsage)
+
+	converted, err := parsed.Certificate(rootKey)
+	require.NoError(t, err)
+	assert.NoError(t, NewCertPool(rootCert).Validate(converted))
+	assert.NoError(t, RequiresExtension(converted, OIDKeyUsage, ExpectKeyUsage(KeyUsageClientIdentification)))
+
+	// Self issued certificates have a null issuer and may have an unlimited validity
+	c509Root, err := NewC509Certificate(rootCert, rootKey)
+	require.NoError(t, err)
+	buf, err = c509Root.Bytes()
+	require.NoError(t, err)
+	parsedRoot, err := ParseC509Certificate(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "root", parsedRoot.Issuer)
+	assert.True(t, parsedRoot.Validity.NotAfter.IsZero())
+	assert.Equal(t, KeyUsageSignCert, parsedRoot.KeyUsage)
+	require.NoError(t, parsedRoot.Verify(rootCert))
+
+	buf[len(buf)-1] ^= 0xff
+	tampered, err := ParseC509Certificate(buf)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(tampered.Verify(rootCert), ErrBadSignature))
+}
+
+func TestC509UnsupportedInput(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ext, err := CapabilitiesExtension("read")
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 1, time.Time{}, time.Time{}, []Extension{ext}, rootKey, "root")
+	require.NoError(t, err)
+	_, err = NewC509Certificate(leaf, rootKey)
+	assert.Error(t, err)
+
+	serial := []byte{0x01}
+	encode := func(items ...interface{}) []byte {
+		buf, err := cborEm.Marshal(items)
+		require.NoError(t, err)
+		return buf
+	}
+	pubKey := []byte(rootCert.PubKey)
+	// Re-encoded X.509 certificates
+	_, err = ParseC509Certificate(encode(C509TypeReencoded, serial, 12, nil, 0, nil, "root", 10, pubKey, []int{}, []byte{}))
+	assert.Error(t, err)
+	// ECDSA signatures
+	_, err = ParseC509Certificate(encode(C509TypeNative, serial, 0, nil, 0, nil, "root", 10, pubKey, []int{}, []byte{}))
+	assert.Error(t, err)
+	// Unknown critical extensions
+	_, err = ParseC509Certificate(encode(C509TypeNative, serial, 12, nil, 0, nil, "root", 10, pubKey, []int{-3, 1}, []byte{}))
+	assert.True(t, errors.Is(err, ErrUnknownCriticalExtension))
+
+	// Names given as attribute list and the keyUsage shortcut
+	parsed, err := ParseC509Certificate(encode(C509TypeNative, serial, 12, []interface{}{1, "root"}, 0, nil,
+		[]interface{}{1, "device"}, 10, pubKey, 32, []byte{}))
+	require.NoError(t, err)
+	assert.Equal(t, "root", parsed.Issuer)
+	assert.Equal(t, "device", parsed.Subject)
+	assert.Equal(t, KeyUsageSignCert, parsed.KeyUsage)
+}
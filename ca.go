@@ -0,0 +1,510 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// CA is a stateful certificate authority. It issues certificates signed by its key, keeps track of
+// the issued certificates and revocations in a Store and creates CRLs.
+//
+// A CA is safe for concurrent use, certificates are signed one after the other. Its fields must
+// not be modified while it is in use.
+type CA struct {
+	// Certificate is the certificate of the CA, it needs to allow KeyUsageSignCert
+	Certificate *Certificate
+	// Options are applied to every issued certificate before the options passed to Issue
+	Options []IssueOption
+	// CRLValidity is the time generated CRLs and epoch statements are valid for, a day by default
+	CRLValidity time.Duration
+	// Rand is the source of randomness for serial numbers, by default the source set with
+	// SetRandomSource is used
+	Rand io.Reader
+	// Epoch is recorded in every issued certificate if it is not zero. Advancing the epoch and
+	// publishing an EpochStatement revokes all certificates of earlier epochs.
+	Epoch uint64
+	// ValidityPolicy limits the validity of issued certificates, by default it is unrestricted
+	ValidityPolicy ValidityPolicy
+	// OnIssue is called for every certificate before it is stored. If an error is returned the
+	// certificate is discarded.
+	OnIssue func(cert *Certificate) error
+	// OnRevoke is called for every revocation before it is recorded. If an error is returned the
+	// certificate is not revoked.
+	OnRevoke func(entry RevokedCertificate) error
+	// Linter optionally checks every certificate before it is signed. Certificates failing a lint
+	// with LintError severity are not issued, other failed lints are logged.
+	Linter *Linter
+	// RateLimiter optionally limits the rate of issuances, globally and per caller set with
+	// WithCaller. Rejected issuances fail with an error wrapping ErrRateLimited before anything
+	// is signed.
+	RateLimiter *RateLimiter
+	// Metrics optionally receives all issuances and revocations
+	Metrics Metrics
+	// Logger optionally receives an event for every issuance, revocation and CRL
+	Logger Logger
+
+	signer Signer
+	store  Store
+	lock   sync.Mutex
+}
+
+// ValidityPolicy limits the validity of the certificates a CA issues. A ceiling of zero disables
+// the limit. Certificates issued with a Profile which has a MaxValidity are only subject to the
+// limit of the profile, so profiles can override the ceilings of the CA in both directions.
+type ValidityPolicy struct {
+	// MaxValidity is the maximum validity of end entity certificates
+	MaxValidity time.Duration
+	// MaxCAValidity is the maximum validity of certificates allowing KeyUsageSignCert
+	MaxCAValidity time.Duration
+}
+
+// DefaultValidityPolicy is a reasonable ValidityPolicy, which limits end entity certificates to 90
+// days and CA certificates to 10 years
+var DefaultValidityPolicy = ValidityPolicy{
+	MaxValidity:   90 * 24 * time.Hour,
+	MaxCAValidity: 10 * 365 * 24 * time.Hour,
+}
+
+// Check returns an error if the validity of cert exceeds the ceiling of the policy. profile is
+// the Profile the certificate has been issued with and may be nil.
+func (p ValidityPolicy) Check(cert *Certificate, profile *Profile) error {
+	if profile != nil && profile.MaxValidity > 0 {
+		return nil
+	}
+	ceiling, kind := p.MaxValidity, "end entity"
+	if RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)) == nil {
+		ceiling, kind = p.MaxCAValidity, "CA"
+	}
+	if ceiling <= 0 {
+		return nil
+	}
+	if cert.Validity == nil || cert.Validity.NotBefore.IsZero() || cert.Validity.NotAfter.IsZero() {
+		return fmt.Errorf("Validity of %s certificates is limited to %s, but the validity is unbounded", kind, ceiling)
+	}
+	validity := cert.Validity.Duration()
+	if validity > ceiling {
+		return fmt.Errorf("Validity of %s exceeds the maximum of %s for %s certificates", validity, ceiling, kind)
+	}
+	return nil
+}
+
+// NewCA creates a CA issuing certificates with cert and the matching key. Its state is kept in a
+// MemoryStore.
+func NewCA(cert *Certificate, key ed25519.PrivateKey) (*CA, error) {
+	return NewCAWithStore(cert, key, NewMemoryStore())
+}
+
+// NewCAWithStore creates a CA issuing certificates with cert and the matching key, which persists
+// its state in store
+func NewCAWithStore(cert *Certificate, key ed25519.PrivateKey, store Store) (*CA, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid CA key")
+	}
+	return NewCAWithSigner(cert, Ed25519Key(key), store)
+}
+
+// NewCAWithSigner creates a CA issuing certificates with cert, which signs with signer instead of
+// a private key held in memory, i.e. with a key kept in a key management service. The CA persists
+// its state in store.
+func NewCAWithSigner(cert *Certificate, signer Signer, store Store) (*CA, error) {
+	if !bytes.Equal(signer.Public(), cert.PubKey) {
+		return nil, errors.New("CA key doesn't match the CA certificate")
+	}
+	if err := RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+		return nil, newValidationError(ErrInvalidKeyUsage, cert, "CA certificate is not allowed to sign certificates")
+	}
+	return &CA{
+		Certificate: cert,
+		CRLValidity: time.Hour * 24,
+		signer:      signer,
+		store:       store,
+	}, nil
+}
+
+// Store returns the Store of the CA
+func (ca *CA) Store() Store {
+	return ca.store
+}
+
+// Issue issues a certificate for subject and pubKey. Unless set via the options, the certificate
+// gets a random serial number, which hasn't been used by this CA before.
+func (ca *CA) Issue(subject string, pubKey ed25519.PublicKey, opts ...IssueOption) (cert *Certificate, err error) {
+	defer func() {
+		ca.observeIssuance(subject, cert, err)
+	}()
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	cert, caller, err := ca.prepare(subject, pubKey, opts, time.Time{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ca.RateLimiter != nil {
+		if err := ca.RateLimiter.Allow(caller); err != nil {
+			return nil, err
+		}
+	}
+	if cert, err = SignCertificateWithSigner(cert, ca.signer); err != nil {
+		return nil, err
+	}
+	if ca.OnIssue != nil {
+		if err := ca.OnIssue(cert); err != nil {
+			return nil, err
+		}
+	}
+	if err := ca.store.StoreCertificate(cert); err != nil {
+		return nil, ca.storeError(cert, err)
+	}
+	return cert, nil
+}
+
+// BatchRequest is an entry of a batch of certificates issued via CA.IssueBatch
+type BatchRequest struct {
+	Subject string
+	PubKey  ed25519.PublicKey
+	// Options are applied after the options of the batch, i.e. to add device specific extensions
+	Options []IssueOption
+}
+
+// IssueBatch issues a certificate for every request, i.e. to provision thousands of devices in a
+// factory. The opts are applied to all certificates of the batch and relative validities like
+// WithValidFor start at the same time for the whole batch.
+//
+// Either all certificates are issued or none: all of them are signed and passed to OnIssue before
+// the first one is stored. If the Store implements BatchStore, the certificates are stored with a
+// single write, otherwise a failing Store may leave a part of the batch stored. The whole batch is
+// charged to the RateLimiter of the CA before the first certificate is signed. If the global limit
+// or the quota of one of its callers is exhausted, no tokens are taken at all.
+func (ca *CA) IssueBatch(requests []BatchRequest, opts ...IssueOption) (certs []*Certificate, err error) {
+	defer func() {
+		if err == nil {
+			for _, cert := range certs {
+				ca.observeIssuance(cert.Subject, cert, nil)
+			}
+			return
+		}
+		// A failed batch is logged once instead of once per certificate
+		if ca.Metrics != nil {
+			for range requests {
+				ca.Metrics.ObserveIssuance(nil, err)
+			}
+		}
+		if ca.Logger != nil {
+			ca.Logger.Warn("Batch issuance failed", "certificates", len(requests), "error", err.Error())
+		}
+	}()
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	now := time.Now()
+	issued := make(map[uint64]*Certificate, len(requests))
+	var callers []string
+	perCaller := map[string]int{}
+	certs = make([]*Certificate, 0, len(requests))
+	for _, r := range requests {
+		cert, caller, err := ca.prepare(r.Subject, r.PubKey, append(append([]IssueOption{}, opts...), r.Options...),
+			now, issued)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to issue certificate for '%s': %w", r.Subject, err)
+		}
+		issued[cert.SerialNumber] = cert
+		certs = append(certs, cert)
+		if perCaller[caller] == 0 {
+			callers = append(callers, caller)
+		}
+		perCaller[caller]++
+	}
+	if ca.RateLimiter != nil {
+		quotas := make([]quota, 0, len(callers))
+		for _, caller := range callers {
+			quotas = append(quotas, quota{caller: caller, n: perCaller[caller]})
+		}
+		if err := ca.RateLimiter.allowQuotas(quotas); err != nil {
+			return nil, err
+		}
+	}
+	for i, cert := range certs {
+		if certs[i], err = SignCertificateWithSigner(cert, ca.signer); err != nil {
+			return nil, err
+		}
+	}
+	if ca.OnIssue != nil {
+		for _, cert := range certs {
+			if err := ca.OnIssue(cert); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if store, ok := ca.store.(BatchStore); ok {
+		if err := store.StoreCertificates(certs); err != nil {
+			return nil, err
+		}
+		return certs, nil
+	}
+	for _, cert := range certs {
+		if err := ca.store.StoreCertificate(cert); err != nil {
+			return nil, ca.storeError(cert, err)
+		}
+	}
+	return certs, nil
+}
+
+// prepare builds and checks a certificate and returns it unsigned together with the caller set
+// via WithCaller. now is the start of relative validities, unless it is zero. Serial numbers of
+// the certificates in issued are treated like already used ones.
+func (ca *CA) prepare(subject string, pubKey ed25519.PublicKey, opts []IssueOption, now time.Time,
+	issued map[uint64]*Certificate) (*Certificate, string, error) {
+	b := NewCertificateBuilder().Subject(subject).PublicKey(pubKey).Issuer(ca.Certificate.Subject)
+	b.now = now
+	allOpts := []IssueOption{WithSerialSource(func() (uint64, error) {
+		r := ca.Rand
+		if b.rand != nil {
+			r = b.rand
+		}
+		return ca.unusedSerialNumber(r, issued)
+	})}
+	if ca.Epoch > 0 {
+		ext, err := IssuanceEpochExtension(ca.Epoch)
+		if err != nil {
+			return nil, "", err
+		}
+		allOpts = append(allOpts, WithExtensions(ext))
+	}
+	allOpts = append(allOpts, ca.Options...)
+	for _, opt := range append(allOpts, opts...) {
+		opt(b)
+	}
+	cert, err := b.Build()
+	if err != nil {
+		return nil, "", err
+	}
+	// Check for collisions before signing, so custom serial sources can't produce two signed
+	// certificates with the same serial number
+	if existing, found := issued[cert.SerialNumber]; found {
+		return nil, "", &SerialNumberCollisionError{Issuer: ca.Certificate.Subject, SerialNumber: cert.SerialNumber,
+			Existing: existing}
+	}
+	if err := ca.checkUnusedSerialNumber(cert.SerialNumber); err != nil {
+		return nil, "", err
+	}
+	if err := ca.ValidityPolicy.Check(cert, b.profile); err != nil {
+		return nil, "", err
+	}
+	if err := checkIssuerPolicy(cert, ca.Certificate); err != nil {
+		return nil, "", err
+	}
+	if ca.Linter != nil {
+		results, err := ca.Linter.Check(cert)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, r := range results {
+			if ca.Logger != nil {
+				ca.Logger.Warn("Certificate failed lint", "subject", subject, "lint", r.Name, "error", r.Err.Error())
+			}
+		}
+	}
+	return cert, b.caller, nil
+}
+
+// storeError makes sure that collisions reported by the Store are *SerialNumberCollisionErrors
+func (ca *CA) storeError(cert *Certificate, err error) error {
+	var collision *SerialNumberCollisionError
+	if errors.Is(err, ErrSerialNumberExists) && !errors.As(err, &collision) {
+		return &SerialNumberCollisionError{Issuer: ca.Certificate.Subject, SerialNumber: cert.SerialNumber}
+	}
+	return err
+}
+
+func (ca *CA) observeIssuance(subject string, cert *Certificate, err error) {
+	if ca.Metrics != nil {
+		ca.Metrics.ObserveIssuance(cert, err)
+	}
+	if ca.Logger == nil {
+		return
+	}
+	if err != nil {
+		ca.Logger.Warn("Certificate issuance failed", "subject", subject, "error", err.Error())
+		return
+	}
+	ca.Logger.Info("Certificate issued", "subject", subject, "serial_number", cert.SerialNumber,
+		"cert_id", cert.ID().String())
+}
+
+// IssueFromCSR verifies csr and issues a certificate for its subject and public key. Extensions of
+// the request are not copied, they need to be passed as options.
+func (ca *CA) IssueFromCSR(csr *CertificateRequest, opts ...IssueOption) (*Certificate, error) {
+	if err := csr.Verify(); err != nil {
+		return nil, err
+	}
+	return ca.Issue(csr.Subject, csr.PubKey, opts...)
+}
+
+func (ca *CA) checkUnusedSerialNumber(serialNumber uint64) error {
+	existing, err := ca.store.Certificate(serialNumber)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &SerialNumberCollisionError{Issuer: ca.Certificate.Subject, SerialNumber: serialNumber, Existing: existing}
+}
+
+func (ca *CA) unusedSerialNumber(r io.Reader, issued map[uint64]*Certificate) (uint64, error) {
+	for i := 0; i < maxSerialNumberAttempts; i++ {
+		serialNumber, err := RandomSerialNumberFrom(r)
+		if err != nil {
+			return 0, err
+		}
+		if _, found := issued[serialNumber]; found {
+			continue
+		}
+		_, err = ca.store.Certificate(serialNumber)
+		if errors.Is(err, ErrNotFound) {
+			return serialNumber, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, errors.New("Random source only produces serial numbers which have been used before")
+}
+
+// Revoke revokes the certificate with serialNumber. Only certificates issued by this CA can be revoked.
+func (ca *CA) Revoke(serialNumber uint64, reason RevocationReason) error {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if _, err := ca.store.Certificate(serialNumber); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("Certificate with serial number %d has not been issued by this CA", serialNumber)
+		}
+		return err
+	}
+	_, err := ca.store.Revocation(serialNumber)
+	if err == nil {
+		return fmt.Errorf("Certificate with serial number %d has already been revoked", serialNumber)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	entry := RevokedCertificate{
+		SerialNumber: serialNumber,
+		RevokedAt:    NewTime(time.Now()),
+		Reason:       reason,
+	}
+	if ca.OnRevoke != nil {
+		if err := ca.OnRevoke(entry); err != nil {
+			return err
+		}
+	}
+	if err := ca.store.StoreRevocation(entry); err != nil {
+		return err
+	}
+	if ca.Metrics != nil {
+		ca.Metrics.ObserveRevocation(entry)
+	}
+	if ca.Logger != nil {
+		ca.Logger.Info("Certificate revoked", "serial_number", serialNumber,
+			"cert_id", CertID{Issuer: ca.Certificate.Subject, SerialNumber: serialNumber}.String(),
+			"reason", entry.Reason.String())
+	}
+	return nil
+}
+
+// IsRevoked is true if the certificate with serialNumber has been revoked
+func (ca *CA) IsRevoked(serialNumber uint64) (bool, error) {
+	_, err := ca.store.Revocation(serialNumber)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GenerateCRL creates a signed CRL of all revoked certificates. Every CRL gets a higher number
+// than the previous one.
+func (ca *CA) GenerateCRL() (*CRL, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	revoked, err := ca.store.Revocations()
+	if err != nil {
+		return nil, err
+	}
+	number, err := ca.store.NextCRLNumber()
+	if err != nil {
+		return nil, err
+	}
+	crl, err := newCRL(ca.Certificate, number, revoked, ca.CRLValidity, ca.signer)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Logger != nil {
+		ca.Logger.Info("CRL generated", "number", crl.Number, "revoked", len(crl.Revoked))
+	}
+	return crl, nil
+}
+
+// GenerateEpochStatement creates a signed EpochStatement announcing the Epoch of the CA
+func (ca *CA) GenerateEpochStatement() (*EpochStatement, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	s, err := newEpochStatement(ca.Certificate, ca.Epoch, ca.CRLValidity, ca.signer)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Logger != nil {
+		ca.Logger.Info("Epoch statement generated", "epoch", s.Epoch)
+	}
+	return s, nil
+}
+
+// GenerateDeltaCRL creates a signed DeltaCRL with the changes since base, which needs to be a CRL
+// generated by this CA
+func (ca *CA) GenerateDeltaCRL(base *CRL) (*DeltaCRL, error) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if base.Issuer != ca.Certificate.Subject {
+		return nil, fmt.Errorf("CRL of '%s' can't be the base of delta CRLs of '%s'", base.Issuer, ca.Certificate.Subject)
+	}
+	revoked, err := ca.store.Revocations()
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[uint64]bool, len(revoked))
+	added := []RevokedCertificate{}
+	for _, entry := range revoked {
+		current[entry.SerialNumber] = true
+		if _, listed := base.Lookup(entry.SerialNumber); !listed {
+			added = append(added, entry)
+		}
+	}
+	removed := []uint64{}
+	for _, entry := range base.Revoked {
+		if !current[entry.SerialNumber] {
+			removed = append(removed, entry.SerialNumber)
+		}
+	}
+	number, err := ca.store.NextCRLNumber()
+	if err != nil {
+		return nil, err
+	}
+	delta, err := newDeltaCRL(ca.Certificate, number, base.Number, added, removed, ca.CRLValidity, ca.signer)
+	if err != nil {
+		return nil, err
+	}
+	if ca.Logger != nil {
+		ca.Logger.Info("Delta CRL generated", "number", delta.Number, "base_number", delta.BaseNumber,
+			"added", len(delta.Added), "removed", len(delta.Removed))
+	}
+	return delta, nil
+}
@@ -0,0 +1,286 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCA(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.Options = []IssueOption{WithKeyUsage(KeyUsageClientIdentification), WithValidFor(time.Hour)}
+
+	var persisted []*Certificate
+	ca.OnIssue = func(cert *Certificate) error {
+		persisted = append(persisted, cert)
+		return nil
+	}
+
+	_, devKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	csr, err := NewCertificateRequest("device", nil, devKey)
+	require.NoError(t, err)
+	cert, err := ca.IssueFromCSR(csr)
+	require.NoError(t, err)
+	assert.Equal(t, "root", cert.Issuer)
+	assert.NotZero(t, cert.SerialNumber)
+	assert.Equal(t, []*Certificate{cert}, persisted)
+	pool := NewCertPool(rootCert)
+	require.NoError(t, pool.Validate(cert))
+	assert.NoError(t, RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageClientIdentification)))
+
+	issued, err := ca.Store().Certificate(cert.SerialNumber)
+	require.NoError(t, err)
+	assert.Equal(t, cert, issued)
+
+	_, err = ca.Issue("device2", devKey.Public().(ed25519.PublicKey), WithSerialNumber(cert.SerialNumber))
+	assert.True(t, errors.Is(err, ErrSerialNumberExists))
+	csr.Subject = "tampered"
+	_, err = ca.IssueFromCSR(csr)
+	assert.Error(t, err)
+
+	ca.OnIssue = func(cert *Certificate) error {
+		return errors.New("storage unavailable")
+	}
+	rejected, err := ca.Issue("device3", devKey.Public().(ed25519.PublicKey), WithSerialNumber(42))
+	assert.Error(t, err)
+	assert.Nil(t, rejected)
+	_, err = ca.Store().Certificate(42)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	ca.OnIssue = nil
+
+	other, err := ca.Issue("device4", devKey.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+
+	assert.Error(t, ca.Revoke(42, RevocationReasonUnspecified))
+	require.NoError(t, ca.Revoke(cert.SerialNumber, RevocationReasonKeyCompromise))
+	assert.Error(t, ca.Revoke(cert.SerialNumber, RevocationReasonKeyCompromise))
+	revoked, err := ca.IsRevoked(cert.SerialNumber)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = ca.IsRevoked(other.SerialNumber)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	crl, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	require.NoError(t, crl.Verify(rootCert))
+	assert.Equal(t, uint64(1), crl.Number)
+	assert.True(t, errors.Is(crl.Check(cert), ErrRevoked))
+	assert.NoError(t, crl.Check(other))
+
+	require.NoError(t, ca.Revoke(other.SerialNumber, RevocationReasonSuperseded))
+	crl, err = ca.GenerateCRL()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), crl.Number)
+	assert.Len(t, crl.Revoked, 2)
+}
+
+func TestCAWithSharedStore(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	store := NewMemoryStore()
+	ca1, err := NewCAWithStore(rootCert, rootKey, store)
+	require.NoError(t, err)
+	ca2, err := NewCAWithStore(rootCert, rootKey, store)
+	require.NoError(t, err)
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca1.Issue("device", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, ca2.Revoke(cert.SerialNumber, RevocationReasonCessationOfOperation))
+
+	crl1, err := ca1.GenerateCRL()
+	require.NoError(t, err)
+	crl2, err := ca2.GenerateCRL()
+	require.NoError(t, err)
+	assert.True(t, crl2.Number > crl1.Number)
+	assert.True(t, errors.Is(crl1.Check(cert), ErrRevoked))
+}
+
+func TestCASerialNumberCollision(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	// A broken serial source always returns the same serial number
+	ca.Options = []IssueOption{WithSerialSource(func() (uint64, error) { return 1234, nil })}
+	signed := 0
+	ca.OnIssue = func(cert *Certificate) error {
+		signed++
+		return nil
+	}
+	first, err := ca.Issue("device1", pubKey)
+	require.NoError(t, err)
+	_, err = ca.Issue("device2", pubKey)
+	assert.True(t, errors.Is(err, ErrSerialNumberExists))
+	var collision *SerialNumberCollisionError
+	require.True(t, errors.As(err, &collision))
+	assert.Equal(t, uint64(1234), collision.SerialNumber)
+	assert.Equal(t, "root", collision.Issuer)
+	assert.Equal(t, first, collision.Existing)
+	assert.Contains(t, err.Error(), "device1")
+	assert.Equal(t, 1, signed, "the colliding certificate must not reach OnIssue")
+}
+
+func TestNewCAChecksKey(t *testing.T) {
+	rootCert, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = NewCA(rootCert, otherKey)
+	assert.Error(t, err)
+
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, leafKey, err := ClientCertificate("device", 1, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	_, err = NewCA(leaf, leafKey)
+	assert.True(t, errors.Is(err, ErrInvalidKeyUsage))
+}
+
+func TestCAGenerateDeltaCRL(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	first, err := ca.Issue("first", pubKey)
+	require.NoError(t, err)
+	second, err := ca.Issue("second", pubKey)
+	require.NoError(t, err)
+
+	require.NoError(t, ca.Revoke(first.SerialNumber, RevocationReasonSuperseded))
+	base, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	delta, err := ca.GenerateDeltaCRL(base)
+	require.NoError(t, err)
+	assert.Empty(t, delta.Added)
+
+	require.NoError(t, ca.Revoke(second.SerialNumber, RevocationReasonKeyCompromise))
+	delta, err = ca.GenerateDeltaCRL(base)
+	require.NoError(t, err)
+	require.NoError(t, delta.Verify(rootCert))
+	assert.Equal(t, base.Number, delta.BaseNumber)
+	require.Len(t, delta.Added, 1)
+	assert.Equal(t, second.SerialNumber, delta.Added[0].SerialNumber)
+
+	merged, err := base.Apply(delta)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(merged.Check(first), ErrRevoked))
+	assert.True(t, errors.Is(merged.Check(second), ErrRevoked))
+}
+
+func TestCAValidityPolicy(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.ValidityPolicy = DefaultValidityPolicy
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	_, err = ca.Issue("device", pubKey, WithValidFor(30*24*time.Hour))
+	assert.NoError(t, err)
+	_, err = ca.Issue("device", pubKey, WithValidFor(365*24*time.Hour))
+	assert.Error(t, err)
+	_, err = ca.Issue("device", pubKey)
+	assert.Error(t, err)
+
+	// CA certificates have their own ceiling
+	_, err = ca.Issue("intermediate", pubKey, WithKeyUsage(KeyUsageSignCert), WithValidFor(5*365*24*time.Hour))
+	assert.NoError(t, err)
+	_, err = ca.Issue("intermediate", pubKey, WithKeyUsage(KeyUsageSignCert), WithValidFor(20*365*24*time.Hour))
+	assert.Error(t, err)
+
+	// The maximum validity of a profile overrides the policy of the CA
+	_, err = ca.Issue("server", pubKey, WithProfile(ProfileServer))
+	assert.NoError(t, err)
+	_, err = ca.Issue("server", pubKey, WithProfile(ProfileServer), WithValidFor(398*24*time.Hour))
+	assert.NoError(t, err)
+	_, err = ca.Issue("server", pubKey, WithProfile(ProfileServer), WithValidFor(400*24*time.Hour))
+	assert.Error(t, err)
+	_, err = ca.Issue("device", pubKey, WithProfile(ProfileDevice))
+	assert.Error(t, err)
+
+	issued, err := ca.Store().CertificatesBySubject("device")
+	require.NoError(t, err)
+	assert.Len(t, issued, 1)
+}
+
+// plainStore hides the BatchStore implementation of the MemoryStore
+type plainStore struct {
+	Store
+}
+
+func TestCAIssueBatch(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	var requests []BatchRequest
+	for i := 0; i < 50; i++ {
+		pubKey, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		requests = append(requests, BatchRequest{Subject: fmt.Sprintf("device-%d", i), PubKey: pubKey})
+	}
+	requests[3].Options = []IssueOption{WithKeyUsage(KeyUsageServerIdentification)}
+
+	for name, store := range map[string]Store{"batch": NewMemoryStore(), "plain": plainStore{NewMemoryStore()}} {
+		ca, err := NewCAWithStore(rootCert, rootKey, store)
+		require.NoError(t, err)
+		certs, err := ca.IssueBatch(requests, WithValidFor(time.Hour), WithKeyUsage(KeyUsageClientIdentification))
+		require.NoError(t, err, name)
+		require.Len(t, certs, len(requests), name)
+		for i, cert := range certs {
+			assert.Equal(t, requests[i].Subject, cert.Subject, name)
+			assert.Equal(t, certs[0].Validity, cert.Validity, "the batch shares its validity")
+			require.NoError(t, pool.Validate(cert), name)
+			stored, err := store.Certificate(cert.SerialNumber)
+			require.NoError(t, err, name)
+			assert.Equal(t, cert, stored, name)
+		}
+		assert.NoError(t, RequiresExtension(certs[3], OIDKeyUsage, ExpectKeyUsage(KeyUsageServerIdentification)))
+		assert.NoError(t, RequiresExtension(certs[4], OIDKeyUsage, ExpectKeyUsage(KeyUsageClientIdentification)))
+	}
+
+	// A failing entry aborts the whole batch before anything is stored
+	store := NewMemoryStore()
+	ca, err := NewCAWithStore(rootCert, rootKey, store)
+	require.NoError(t, err)
+	ca.OnIssue = func(cert *Certificate) error {
+		t.Fatal("no certificate of a failed batch must reach OnIssue")
+		return nil
+	}
+	invalid := append([]BatchRequest{}, requests[:3]...)
+	invalid = append(invalid, BatchRequest{Subject: "broken"})
+	_, err = ca.IssueBatch(invalid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	stored, err := store.CertificatesBySubject(requests[0].Subject)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+
+	// Serial numbers have to be unique within the batch
+	_, err = ca.IssueBatch(requests[:2], WithSerialNumber(42))
+	var collision *SerialNumberCollisionError
+	require.True(t, errors.As(err, &collision))
+	assert.Equal(t, requests[0].Subject, collision.Existing.Subject)
+
+	ca.OnIssue = nil
+	certs, err := ca.IssueBatch(nil)
+	require.NoError(t, err)
+	assert.Empty(t, certs)
+}
@@ -0,0 +1,165 @@
+package smolcert
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// ValidationCache remembers certificates and bundles which have been validated successfully by a
+// Validator, so repeated connections of the same device skip the validation of the chain. Entries
+// are evicted when the cache is full, starting with the least recently used one, after the TTL
+// or once a certificate of the chain expires. All results are dropped when the Pool of the
+// Validator is replaced, i.e. by the CertPool of a TrustBundleUpdater after an update. Cached
+// results don't reflect roots added to the CertPool in place or changes of CRLs, so Invalidate
+// needs to be called after such changes.
+// Hooks aren't invoked for cached results, so the cache must not be combined with hooks checking
+// the revocation status like RevocationHook or EpochHook.
+// A ValidationCache is safe for concurrent use, but must only be used by one Validator.
+type ValidationCache struct {
+	size int
+	ttl  time.Duration
+
+	lock sync.Mutex
+	// pool is the CertPool the cached results have been validated against
+	pool       *CertPool
+	generation uint64
+	entries    map[cacheKey]*list.Element
+	lru        *list.List
+}
+
+type cacheKey struct {
+	fingerprint [sha256.Size]byte
+	generation  uint64
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	expires time.Time
+	// leaf is the index of the validated leaf certificate in its bundle
+	leaf int
+	root *Certificate
+}
+
+// NewValidationCache creates a ValidationCache holding up to size results for at most ttl
+func NewValidationCache(size int, ttl time.Duration) *ValidationCache {
+	return &ValidationCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[cacheKey]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Invalidate drops all cached results. Validations running concurrently don't add their results
+// to the cache.
+func (c *ValidationCache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.invalidate()
+}
+
+func (c *ValidationCache) invalidate() {
+	c.generation++
+	c.entries = map[cacheKey]*list.Element{}
+	c.lru.Init()
+}
+
+// Len returns the number of cached results, including expired ones which haven't been evicted yet
+func (c *ValidationCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+// key returns the key of certs in the current generation. If pool isn't the CertPool of the cached
+// results, they are invalidated first. ok is false if a certificate can't be encoded.
+func (c *ValidationCache) key(pool *CertPool, certs []*Certificate) (key cacheKey, ok bool) {
+	h := sha256.New()
+	var length [8]byte
+	for _, cert := range certs {
+		tbs, err := cert.TBSBytes()
+		if err != nil {
+			return key, false
+		}
+		for _, field := range [][]byte{tbs, cert.Signature} {
+			binary.BigEndian.PutUint64(length[:], uint64(len(field)))
+			h.Write(length[:])
+			h.Write(field)
+		}
+	}
+	h.Sum(key.fingerprint[:0])
+	c.lock.Lock()
+	if pool != c.pool {
+		c.invalidate()
+		c.pool = pool
+	}
+	key.generation = c.generation
+	c.lock.Unlock()
+	return key, true
+}
+
+// lookup returns the cached result for key, if the certificates have been validated before
+func (c *ValidationCache) lookup(key cacheKey) (cacheEntry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, found := c.entries[key]
+	if !found {
+		return cacheEntry{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !time.Now().Before(entry.expires) {
+		c.remove(elem)
+		return cacheEntry{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return *entry, true
+}
+
+// add caches the result of a successful validation of certs, which has been started with key.
+// The result expires with the first certificate of the bundle or the root.
+func (c *ValidationCache) add(key cacheKey, certs []*Certificate, leaf int, root *Certificate) {
+	expires := time.Now().Add(c.ttl)
+	for _, cert := range certs {
+		expires = earliestExpiry(expires, cert)
+	}
+	expires = earliestExpiry(expires, root)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if key.generation != c.generation || c.size <= 0 {
+		return
+	}
+	if elem, found := c.entries[key]; found {
+		*elem.Value.(*cacheEntry) = cacheEntry{key: key, expires: expires, leaf: leaf, root: root}
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.size {
+		c.remove(c.lru.Back())
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, expires: expires, leaf: leaf, root: root})
+}
+
+// earliestExpiry returns the earlier of expires and the NotAfter time of cert
+func earliestExpiry(expires time.Time, cert *Certificate) time.Time {
+	if cert == nil || cert.Validity == nil || cert.Validity.NotAfter.IsZero() {
+		return expires
+	}
+	notAfter := cert.Validity.NotAfterTime()
+	if cert.Version < Version3 {
+		// Like during validation certificates with second precision expire at the end of the second
+		notAfter = notAfter.Add(time.Second)
+	}
+	if notAfter.Before(expires) {
+		return notAfter
+	}
+	return expires
+}
+
+func (c *ValidationCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
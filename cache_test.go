@@ -0,0 +1,125 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestValidationCache(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", now.Add(-time.Hour), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, now.Add(-time.Hour), now.Add(time.Hour),
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 3, now.Add(-time.Hour), now.Add(time.Hour), nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	other, _, err := ClientCertificate("other", 4, now.Add(-time.Hour), now.Add(time.Hour), nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	direct, _, err := ClientCertificate("direct", 5, now.Add(-time.Hour), now.Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+
+	calls := 0
+	v := NewValidator(NewCertPool(rootCert), func(cert, issuer *Certificate) error {
+		calls++
+		return nil
+	})
+	v.Cache = NewValidationCache(2, time.Hour)
+	bundle := []*Certificate{intermediate, leaf}
+
+	for i := 0; i < 2; i++ {
+		clientCert, err := v.ValidateBundle(bundle)
+		require.NoError(t, err)
+		assert.Equal(t, leaf, clientCert)
+	}
+	assert.Equal(t, 3, calls)
+	require.NoError(t, v.Validate(direct))
+	require.NoError(t, v.Validate(direct))
+	assert.Equal(t, 5, calls)
+	assert.Equal(t, 2, v.Cache.Len())
+
+	// The least recently used result is evicted
+	_, err = v.ValidateBundle([]*Certificate{intermediate, other})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v.Cache.Len())
+	calls = 0
+	require.NoError(t, v.Validate(direct))
+	assert.Equal(t, 0, calls)
+	_, err = v.ValidateBundle(bundle)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	v.Cache.Invalidate()
+	assert.Equal(t, 0, v.Cache.Len())
+	require.NoError(t, v.Validate(direct))
+	assert.Equal(t, 5, calls)
+
+	// Replacing the pool drops the cached results
+	calls = 0
+	require.NoError(t, v.Validate(direct))
+	assert.Equal(t, 0, calls)
+	v.Pool = NewCertPool(rootCert)
+	require.NoError(t, v.Validate(direct))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, v.Cache.Len())
+	v.Pool = NewCertPool()
+	assert.True(t, errors.Is(v.Validate(direct), ErrUnknownIssuer))
+	assert.Equal(t, 0, v.Cache.Len())
+	v.Pool = NewCertPool(rootCert)
+
+	// Failed validations are not cached
+	v.AddHook(func(cert, issuer *Certificate) error {
+		return errors.New("rejected")
+	})
+	v.Cache.Invalidate()
+	for i := 0; i < 2; i++ {
+		assert.True(t, errors.Is(v.Validate(direct), ErrRejectedByHook))
+	}
+	assert.Equal(t, 0, v.Cache.Len())
+}
+
+func TestValidationCacheExpiry(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 2, now.Add(-time.Hour), now.Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	expiring, err := NewCertificateBuilder().Version(LatestVersion).Subject("expiring").Issuer("root").PublicKey(pubKey).
+		NotBefore(now.Add(-time.Hour)).NotAfter(now.Add(50 * time.Millisecond)).KeyUsage(KeyUsageClientIdentification).
+		SignWith(rootKey)
+	require.NoError(t, err)
+
+	v := NewValidator(NewCertPool(rootCert))
+	v.Cache = NewValidationCache(10, 20*time.Millisecond)
+	require.NoError(t, v.Validate(cert))
+	require.NoError(t, v.Validate(expiring))
+	key, ok := v.Cache.key(v.Pool, []*Certificate{cert})
+	require.True(t, ok)
+	_, found := v.Cache.lookup(key)
+	assert.True(t, found)
+
+	time.Sleep(30 * time.Millisecond)
+	_, found = v.Cache.lookup(key)
+	assert.False(t, found)
+
+	// Results expire with the certificates
+	v.Cache = NewValidationCache(10, time.Hour)
+	require.NoError(t, v.Validate(expiring))
+	time.Sleep(time.Until(expiring.Validity.NotAfterTime()) + time.Millisecond)
+	assert.True(t, errors.Is(v.Validate(expiring), ErrExpired))
+
+	// Validations started before an invalidation are not cached
+	key, ok = v.Cache.key(v.Pool, []*Certificate{cert})
+	require.True(t, ok)
+	v.Cache.Invalidate()
+	v.Cache.add(key, []*Certificate{cert}, 0, rootCert)
+	assert.Equal(t, 0, v.Cache.Len())
+}
@@ -0,0 +1,133 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ErrNotAuthorized indicates that a valid certificate doesn't grant a requested capability
+var ErrNotAuthorized = errors.New("certificate doesn't grant the capability")
+
+// Capabilities is the Value of a Capabilities extension. It lists the rights granted to the
+// subject, i.e. the device commands it may execute, like "door/open". A capability ending in "/*"
+// grants all capabilities starting with the part before the asterisk, "*" grants everything.
+//
+// Capabilities can only be narrowed down the chain: every capability of a certificate needs to be
+// granted by the Capabilities of its issuer. Roots without Capabilities extension may grant any
+// capability, while intermediates without Capabilities extension can't grant any.
+type Capabilities []string
+
+// CapabilitiesExtension creates a critical Capabilities Extension
+func CapabilitiesExtension(capabilities ...string) (Extension, error) {
+	for _, capability := range capabilities {
+		if capability == "" {
+			return Extension{}, errors.New("Capabilities can't be empty")
+		}
+	}
+	val, err := cborEm.Marshal(Capabilities(append([]string{}, capabilities...)))
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDCapabilities,
+		Critical: true,
+		Value:    val,
+	}, nil
+}
+
+// ParseCapabilities parses Capabilities from a byte slice, i.e. the Value of an Extension
+func ParseCapabilities(in []byte) (Capabilities, error) {
+	caps := Capabilities{}
+	if err := cbor.Unmarshal(in, &caps); err != nil {
+		return nil, fmt.Errorf("Failed to parse Capabilities: %w", err)
+	}
+	return caps, nil
+}
+
+// Allows is true if capability is granted by c
+func (c Capabilities) Allows(capability string) bool {
+	for _, granted := range c {
+		if granted == capability || granted == "*" {
+			return true
+		}
+		if strings.HasSuffix(granted, "/*") && strings.HasPrefix(capability, granted[:len(granted)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers is true if every capability of other is granted by c
+func (c Capabilities) covers(other Capabilities) bool {
+	for _, capability := range other {
+		if !c.Allows(capability) {
+			return false
+		}
+	}
+	return true
+}
+
+// Capabilities returns the Capabilities of the certificate. If the certificate has no Capabilities
+// extension nil is returned.
+func (c *Certificate) Capabilities() (Capabilities, error) {
+	var caps Capabilities
+	err := RequiresExtension(c, OIDCapabilities, func(critical bool, val []byte) (err error) {
+		caps, err = ParseCapabilities(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return caps, err
+}
+
+// Authorize checks that cert grants capability. The certificate needs to be validated before, which
+// ensures that its Capabilities are granted by its issuers.
+func Authorize(cert *Certificate, capability string) error {
+	caps, err := cert.Capabilities()
+	if err != nil {
+		return err
+	}
+	if !caps.Allows(capability) {
+		return fmt.Errorf("%w: '%s' is not granted to '%s'", ErrNotAuthorized, capability, cert.Subject)
+	}
+	return nil
+}
+
+// checkCapabilities verifies that all Capabilities of cert are granted by its issuer
+func checkCapabilities(cert, issuer *Certificate) error {
+	if cert == issuer || (cert.Subject == issuer.Subject && bytes.Equal(cert.PubKey, issuer.PubKey)) {
+		// Roots may grant themselves anything
+		return nil
+	}
+	caps, err := cert.Capabilities()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Invalid Capabilities: %s", err)
+	}
+	if caps == nil {
+		return nil
+	}
+	issuerCaps, err := issuer.Capabilities()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, issuer, "Invalid Capabilities: %s", err)
+	}
+	if issuerCaps == nil {
+		if issuer.Issuer == issuer.Subject {
+			// Unrestricted root
+			return nil
+		}
+		return newValidationError(ErrCapabilityViolation, cert,
+			"Certificate has Capabilities, but its issuer '%s' doesn't hold any", issuer.Subject)
+	}
+	for _, capability := range caps {
+		if !issuerCaps.Allows(capability) {
+			return newValidationError(ErrCapabilityViolation, cert,
+				"Capability '%s' is not granted by issuer '%s'", capability, issuer.Subject)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newCapabilityCert(t *testing.T, subject string, serial uint64, keyUsage KeyUsage, caps []string,
+	issuerKey ed25519.PrivateKey, issuer string) (*Certificate, ed25519.PrivateKey) {
+	exts := []Extension{{OID: OIDKeyUsage, Critical: true, Value: keyUsage.ToBytes()}}
+	if caps != nil {
+		ext, err := CapabilitiesExtension(caps...)
+		require.NoError(t, err)
+		exts = append(exts, ext)
+	}
+	cert, key, err := SignedCertificate(subject, serial, time.Time{}, time.Time{}, exts, issuerKey, issuer)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestCapabilitiesAllows(t *testing.T) {
+	caps := Capabilities{"door/open", "light/*"}
+	assert.True(t, caps.Allows("door/open"))
+	assert.False(t, caps.Allows("door/close"))
+	assert.True(t, caps.Allows("light/on"))
+	assert.True(t, caps.Allows("light/kitchen/*"))
+	assert.False(t, caps.Allows("lights/on"))
+	assert.True(t, Capabilities{"*"}.Allows("anything"))
+	assert.False(t, Capabilities{}.Allows("door/open"))
+
+	_, err := CapabilitiesExtension("door/open", "")
+	assert.Error(t, err)
+}
+
+func TestCapabilityAttenuation(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	operator, operatorKey := newCapabilityCert(t, "operator", 2, KeyUsageSignCert,
+		[]string{"door/*", "light/on"}, rootKey, "root")
+
+	device, _ := newCapabilityCert(t, "device", 3, KeyUsageClientIdentification,
+		[]string{"door/open", "light/on"}, operatorKey, "operator")
+	leaf, err := pool.ValidateBundle([]*Certificate{device, operator})
+	require.NoError(t, err)
+	assert.NoError(t, Authorize(leaf, "door/open"))
+	assert.NoError(t, Authorize(leaf, "light/on"))
+	err = Authorize(leaf, "door/close")
+	assert.True(t, errors.Is(err, ErrNotAuthorized))
+	assert.True(t, pool.ValidateBundleReport([]*Certificate{device, operator}).Valid())
+
+	// Broadening capabilities is not allowed
+	greedy, _ := newCapabilityCert(t, "device", 4, KeyUsageClientIdentification,
+		[]string{"light/off"}, operatorKey, "operator")
+	_, err = pool.ValidateBundle([]*Certificate{greedy, operator})
+	assert.True(t, errors.Is(err, ErrCapabilityViolation))
+	assert.False(t, pool.ValidateBundleReport([]*Certificate{greedy, operator}).Valid())
+
+	// Intermediates without capabilities can't grant any
+	plain, plainKey := newCapabilityCert(t, "plain", 5, KeyUsageSignCert, nil, rootKey, "root")
+	fromPlain, _ := newCapabilityCert(t, "device", 6, KeyUsageClientIdentification,
+		[]string{"door/open"}, plainKey, "plain")
+	_, err = pool.ValidateBundle([]*Certificate{fromPlain, plain})
+	assert.True(t, errors.Is(err, ErrCapabilityViolation))
+
+	// Certificates without capabilities are still valid, but aren't authorized for anything
+	noCaps, _ := newCapabilityCert(t, "device", 7, KeyUsageClientIdentification, nil, operatorKey, "operator")
+	leaf, err = pool.ValidateBundle([]*Certificate{noCaps, operator})
+	require.NoError(t, err)
+	assert.True(t, errors.Is(Authorize(leaf, "door/open"), ErrNotAuthorized))
+}
+
+func TestCapabilitiesOfRoot(t *testing.T) {
+	ext, err := CapabilitiesExtension("door/*")
+	require.NoError(t, err)
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, []Extension{ext})
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	allowed, _ := newCapabilityCert(t, "device", 2, KeyUsageClientIdentification, []string{"door/open"}, rootKey, "root")
+	assert.NoError(t, pool.Validate(allowed))
+	denied, _ := newCapabilityCert(t, "device", 3, KeyUsageClientIdentification, []string{"light/on"}, rootKey, "root")
+	assert.True(t, errors.Is(pool.Validate(denied), ErrCapabilityViolation))
+	assert.False(t, pool.ValidateReport(denied).Valid())
+}
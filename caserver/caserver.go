@@ -0,0 +1,335 @@
+/*
+Package caserver exposes a smolcert.CA via HTTP, so that an internal CA service can be set up
+with a few lines of code:
+
+	ca, _ := smolcert.NewCA(caCert, caKey)
+	srv := &caserver.Server{CA: ca, Authenticate: caserver.BearerTokenAuthenticator(token)}
+	http.Handle("/ca/", http.StripPrefix("/ca", srv))
+
+All payloads are CBOR encoded smolcert structures:
+
+	GET  /cacerts                returns the CA certificates
+	POST /certificates           issues a certificate for the CertificateRequest in the body
+	GET  /certificates/{serial}  returns the certificate with the decimal serial number
+	GET  /crl                    returns the current CRL
+*/
+package caserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+)
+
+// Paths of the resources, relative to the mount point of the Server
+const (
+	PathCACertificates = "/cacerts"
+	PathCertificates   = "/certificates"
+	PathCRL            = "/crl"
+)
+
+// ContentType is the content type of all request and response bodies
+const ContentType = "application/cbor"
+
+// maxRequestSize limits the size of certificate requests
+const maxRequestSize = 64 * 1024
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// Authenticator decides if the client sending r is allowed to get a certificate for csr. The
+// returned options are applied in addition to the options of the CA, i.e. to select a profile or
+// to charge the quota of the client with smolcert.WithCaller.
+type Authenticator func(r *http.Request, csr *smolcert.CertificateRequest) ([]smolcert.IssueOption, error)
+
+// BearerTokenAuthenticator authenticates clients sending token in an Authorization header
+func BearerTokenAuthenticator(token string) Authenticator {
+	return func(r *http.Request, csr *smolcert.CertificateRequest) ([]smolcert.IssueOption, error) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+			return nil, errors.New("Invalid token")
+		}
+		return nil, nil
+	}
+}
+
+// Server is a http.Handler serving the resources of a CA
+type Server struct {
+	CA *smolcert.CA
+	// CACertificates are returned to clients requesting the CA certificates. By default only the
+	// certificate of the CA is returned.
+	CACertificates []*smolcert.Certificate
+	// Authenticate authenticates certificate requests, issuance is disabled if nil
+	Authenticate Authenticator
+	// CRLMaxAge is the time a generated CRL is served before a new one is created. By default a new
+	// CRL is created for every request.
+	CRLMaxAge time.Duration
+
+	crlLock    sync.Mutex
+	crl        []byte
+	crlCreated time.Time
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == PathCACertificates:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.caCertificates(w)
+	case r.URL.Path == PathCertificates:
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		s.issue(w, r)
+	case strings.HasPrefix(r.URL.Path, PathCertificates+"/"):
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.certificate(w, strings.TrimPrefix(r.URL.Path, PathCertificates+"/"))
+	case r.URL.Path == PathCRL:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		s.serveCRL(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) caCertificates(w http.ResponseWriter) {
+	certs := s.CACertificates
+	if certs == nil {
+		certs = []*smolcert.Certificate{s.CA.Certificate}
+	}
+	buf, err := cborEm.Marshal(certs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCBOR(w, http.StatusOK, buf)
+}
+
+func (s *Server) issue(w http.ResponseWriter, r *http.Request) {
+	if s.Authenticate == nil {
+		http.Error(w, "Issuance is disabled", http.StatusForbidden)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRequestSize {
+		http.Error(w, "Certificate request is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	csr, err := smolcert.ParseCertificateRequest(body)
+	if err != nil {
+		http.Error(w, "Invalid certificate request", http.StatusBadRequest)
+		return
+	}
+	if err := csr.Verify(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := s.Authenticate(r, csr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	cert, err := s.CA.IssueFromCSR(csr, opts...)
+	var rateLimited *smolcert.RateLimitError
+	if errors.As(err, &rateLimited) {
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(rateLimited.RetryAfter.Seconds())), 10))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf, err := cert.Bytes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", PathCertificates, cert.SerialNumber))
+	writeCBOR(w, http.StatusCreated, buf)
+}
+
+func (s *Server) certificate(w http.ResponseWriter, serial string) {
+	serialNumber, err := strconv.ParseUint(serial, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid serial number", http.StatusBadRequest)
+		return
+	}
+	cert, err := s.CA.Store().Certificate(serialNumber)
+	if errors.Is(err, smolcert.ErrNotFound) {
+		http.Error(w, "Certificate not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf, err := cert.Bytes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCBOR(w, http.StatusOK, buf)
+}
+
+func (s *Server) serveCRL(w http.ResponseWriter) {
+	buf, err := s.currentCRL()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeCBOR(w, http.StatusOK, buf)
+}
+
+func (s *Server) currentCRL() ([]byte, error) {
+	s.crlLock.Lock()
+	defer s.crlLock.Unlock()
+	if s.crl != nil && time.Since(s.crlCreated) < s.CRLMaxAge {
+		return s.crl, nil
+	}
+	crl, err := s.CA.GenerateCRL()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := crl.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	s.crl, s.crlCreated = buf, time.Now()
+	return buf, nil
+}
+
+func writeCBOR(w http.ResponseWriter, status int, buf []byte) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	w.Write(buf)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+// Client accesses a Server
+type Client struct {
+	// URL is the base URL the Server is mounted at
+	URL string
+	// HTTPClient is used to send requests, http.DefaultClient is used by default
+	HTTPClient *http.Client
+	// Token is sent as bearer token when requesting certificates, if set
+	Token string
+}
+
+// CACertificates fetches the CA certificates
+func (c *Client) CACertificates(ctx context.Context) ([]*smolcert.Certificate, error) {
+	buf, err := c.do(ctx, http.MethodGet, PathCACertificates, nil)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*smolcert.Certificate
+	if err := cbor.Unmarshal(buf, &certs); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// Issue requests a certificate for csr
+func (c *Client) Issue(ctx context.Context, csr *smolcert.CertificateRequest) (*smolcert.Certificate, error) {
+	csrBytes, err := csr.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := c.do(ctx, http.MethodPost, PathCertificates, csrBytes)
+	if err != nil {
+		return nil, err
+	}
+	return smolcert.ParseBuf(buf)
+}
+
+// Certificate fetches the certificate with serialNumber
+func (c *Client) Certificate(ctx context.Context, serialNumber uint64) (*smolcert.Certificate, error) {
+	buf, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d", PathCertificates, serialNumber), nil)
+	if err != nil {
+		return nil, err
+	}
+	return smolcert.ParseBuf(buf)
+}
+
+// CRL fetches the current CRL. It needs to be verified with the CA certificate before use.
+func (c *Client) CRL(ctx context.Context) (*smolcert.CRL, error) {
+	buf, err := c.do(ctx, http.MethodGet, PathCRL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return smolcert.ParseCRL(buf)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, payload []byte) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if payload != nil {
+		req.Header.Set("Content-Type", ContentType)
+		if c.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.Token)
+		}
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("CA request failed with %s: %s", resp.Status, strings.TrimSpace(string(buf)))
+	}
+	return buf, nil
+}
@@ -0,0 +1,151 @@
+package caserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	srv := &Server{
+		CA:           ca,
+		Authenticate: BearerTokenAuthenticator("secret"),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/ca/", http.StripPrefix("/ca", srv))
+	return srv, httptest.NewServer(mux)
+}
+
+func TestServer(t *testing.T) {
+	srv, httpSrv := newTestServer(t)
+	defer httpSrv.Close()
+	ctx := context.Background()
+	client := &Client{URL: httpSrv.URL + "/ca", Token: "secret"}
+
+	caCerts, err := client.CACertificates(ctx)
+	require.NoError(t, err)
+	require.Len(t, caCerts, 1)
+	assert.Equal(t, srv.CA.Certificate.Signature, caCerts[0].Signature)
+
+	_, devKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	csr, err := smolcert.NewCertificateRequest("device", nil, devKey)
+	require.NoError(t, err)
+	cert, err := client.Issue(ctx, csr)
+	require.NoError(t, err)
+	assert.Equal(t, "device", cert.Subject)
+	assert.NoError(t, smolcert.NewCertPool(caCerts...).Validate(cert))
+
+	fetched, err := client.Certificate(ctx, cert.SerialNumber)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Signature, fetched.Signature)
+	_, err = client.Certificate(ctx, cert.SerialNumber+1)
+	assert.Error(t, err)
+
+	require.NoError(t, srv.CA.Revoke(cert.SerialNumber, smolcert.RevocationReasonKeyCompromise))
+	crl, err := client.CRL(ctx)
+	require.NoError(t, err)
+	require.NoError(t, crl.Verify(caCerts[0]))
+	assert.True(t, errors.Is(crl.Check(cert), smolcert.ErrRevoked))
+}
+
+func TestServerRejectsRequests(t *testing.T) {
+	srv, httpSrv := newTestServer(t)
+	defer httpSrv.Close()
+	ctx := context.Background()
+
+	_, devKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	csr, err := smolcert.NewCertificateRequest("device", nil, devKey)
+	require.NoError(t, err)
+	_, err = (&Client{URL: httpSrv.URL + "/ca", Token: "wrong"}).Issue(ctx, csr)
+	assert.Error(t, err)
+	_, err = (&Client{URL: httpSrv.URL + "/ca"}).Issue(ctx, csr)
+	assert.Error(t, err)
+
+	csr.Subject = "tampered"
+	_, err = (&Client{URL: httpSrv.URL + "/ca", Token: "secret"}).Issue(ctx, csr)
+	assert.Error(t, err)
+
+	srv.Authenticate = nil
+	resp, err := http.Post(httpSrv.URL+"/ca/certificates", ContentType, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Post(httpSrv.URL+"/ca/crl", ContentType, nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Get(httpSrv.URL + "/ca/certificates/abc")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServerCachesCRL(t *testing.T) {
+	srv, httpSrv := newTestServer(t)
+	defer httpSrv.Close()
+	srv.CRLMaxAge = time.Hour
+	client := &Client{URL: httpSrv.URL + "/ca"}
+
+	first, err := client.CRL(context.Background())
+	require.NoError(t, err)
+	second, err := client.CRL(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first.Number, second.Number)
+
+	srv.CRLMaxAge = 0
+	third, err := client.CRL(context.Background())
+	require.NoError(t, err)
+	assert.True(t, third.Number > second.Number)
+}
+
+func TestServerRateLimit(t *testing.T) {
+	srv, httpSrv := newTestServer(t)
+	defer httpSrv.Close()
+	srv.CA.RateLimiter = smolcert.NewRateLimiter(smolcert.RateLimit{}, smolcert.RateLimit{Rate: 0.1, Burst: 1})
+	authenticate := srv.Authenticate
+	srv.Authenticate = func(r *http.Request, csr *smolcert.CertificateRequest) ([]smolcert.IssueOption, error) {
+		if _, err := authenticate(r, csr); err != nil {
+			return nil, err
+		}
+		return []smolcert.IssueOption{smolcert.WithCaller(csr.Subject)}, nil
+	}
+
+	_, devKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	csr, err := smolcert.NewCertificateRequest("device", nil, devKey)
+	require.NoError(t, err)
+	client := &Client{URL: httpSrv.URL + "/ca", Token: "secret"}
+	_, err = client.Issue(context.Background(), csr)
+	require.NoError(t, err)
+	_, err = client.Issue(context.Background(), csr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "429")
+
+	buf, err := csr.Bytes()
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, httpSrv.URL+"/ca/certificates", bytes.NewReader(buf))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+}
@@ -0,0 +1,237 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// ErrCaveatNotSatisfied indicates that a caveat of a certificate isn't satisfied by the context of a
+// request
+var ErrCaveatNotSatisfied = errors.New("certificate caveat is not satisfied")
+
+// Types of the caveats defined by this package
+const (
+	// CaveatExpiry restricts the use of a certificate to requests before a point in time
+	CaveatExpiry = "exp"
+	// CaveatAudience restricts the use of a certificate to a list of audiences
+	CaveatAudience = "aud"
+	// CaveatIPRange restricts the use of a certificate to requests from a list of IP ranges
+	CaveatIPRange = "ip"
+	// CaveatOperation restricts the use of a certificate to a list of operations
+	CaveatOperation = "op"
+)
+
+// Caveat is a condition restricting the use of a certificate, which is evaluated against the
+// context of a request. Caveats can be added by any certificate in a chain and all of them need
+// to be satisfied. The encoding of Value depends on the Type.
+type Caveat struct {
+	_ struct{} `cbor:",toarray"`
+
+	Type  string `cbor:"type"`
+	Value []byte `cbor:"value"`
+}
+
+// CaveatContext describes the request a certificate is used for
+type CaveatContext struct {
+	// Time is the time of the request, the current time is used if zero
+	Time time.Time
+	// Audience is the identifier of the service receiving the request
+	Audience string
+	// RemoteIP is the address the request originates from
+	RemoteIP net.IP
+	// Operation is the requested operation
+	Operation string
+	// Values may be used by custom CaveatCheckers
+	Values map[string]interface{}
+}
+
+func (ctx *CaveatContext) now() time.Time {
+	if ctx.Time.IsZero() {
+		return time.Now()
+	}
+	return ctx.Time
+}
+
+// CaveatChecker evaluates the Value of a caveat against ctx and returns an error if the caveat
+// isn't satisfied
+type CaveatChecker func(value []byte, ctx *CaveatContext) error
+
+var (
+	caveatCheckersLock sync.RWMutex
+	caveatCheckers     = map[string]CaveatChecker{
+		CaveatExpiry:    checkExpiryCaveat,
+		CaveatAudience:  checkAudienceCaveat,
+		CaveatIPRange:   checkIPRangeCaveat,
+		CaveatOperation: checkOperationCaveat,
+	}
+)
+
+// RegisterCaveatChecker registers the checker for caveats of the type caveatType. Certificates with
+// caveats of types without checker never satisfy their caveats.
+func RegisterCaveatChecker(caveatType string, checker CaveatChecker) {
+	caveatCheckersLock.Lock()
+	defer caveatCheckersLock.Unlock()
+	caveatCheckers[caveatType] = checker
+}
+
+func findCaveatChecker(caveatType string) CaveatChecker {
+	caveatCheckersLock.RLock()
+	defer caveatCheckersLock.RUnlock()
+	return caveatCheckers[caveatType]
+}
+
+func newCaveat(caveatType string, value interface{}) (Caveat, error) {
+	buf, err := cborEm.Marshal(value)
+	if err != nil {
+		return Caveat{}, err
+	}
+	return Caveat{Type: caveatType, Value: buf}, nil
+}
+
+// ExpiryCaveat creates a caveat which is satisfied by requests until notAfter
+func ExpiryCaveat(notAfter time.Time) (Caveat, error) {
+	return newCaveat(CaveatExpiry, NewTime(notAfter))
+}
+
+// AudienceCaveat creates a caveat which is satisfied by requests to one of the given audiences
+func AudienceCaveat(audiences ...string) (Caveat, error) {
+	return newCaveat(CaveatAudience, append([]string{}, audiences...))
+}
+
+// IPRangeCaveat creates a caveat which is satisfied by requests from one of the given networks
+func IPRangeCaveat(networks ...*net.IPNet) (Caveat, error) {
+	cidrs := make([]string, len(networks))
+	for i, network := range networks {
+		cidrs[i] = network.String()
+	}
+	return newCaveat(CaveatIPRange, cidrs)
+}
+
+// OperationCaveat creates a caveat which is satisfied by requests for one of the given operations
+func OperationCaveat(operations ...string) (Caveat, error) {
+	return newCaveat(CaveatOperation, append([]string{}, operations...))
+}
+
+func checkExpiryCaveat(value []byte, ctx *CaveatContext) error {
+	var notAfter Time
+	if err := cbor.Unmarshal(value, &notAfter); err != nil {
+		return err
+	}
+	if ctx.now().Unix() > int64(notAfter) {
+		return fmt.Errorf("Certificate may only be used until %s", notAfter.StdTime().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func checkAudienceCaveat(value []byte, ctx *CaveatContext) error {
+	var audiences []string
+	if err := cbor.Unmarshal(value, &audiences); err != nil {
+		return err
+	}
+	if !containsString(audiences, ctx.Audience) {
+		return fmt.Errorf("Certificate may not be used for the audience '%s'", ctx.Audience)
+	}
+	return nil
+}
+
+func checkIPRangeCaveat(value []byte, ctx *CaveatContext) error {
+	var cidrs []string
+	if err := cbor.Unmarshal(value, &cidrs); err != nil {
+		return err
+	}
+	if ctx.RemoteIP == nil {
+		return errors.New("Certificate may only be used from certain addresses, but the address is unknown")
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		if network.Contains(ctx.RemoteIP) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Certificate may not be used from %s", ctx.RemoteIP)
+}
+
+func checkOperationCaveat(value []byte, ctx *CaveatContext) error {
+	var operations []string
+	if err := cbor.Unmarshal(value, &operations); err != nil {
+		return err
+	}
+	if !containsString(operations, ctx.Operation) {
+		return fmt.Errorf("Certificate may not be used for the operation '%s'", ctx.Operation)
+	}
+	return nil
+}
+
+// CaveatsExtension creates a critical Caveats Extension
+func CaveatsExtension(caveats ...Caveat) (Extension, error) {
+	val, err := cborEm.Marshal(append([]Caveat{}, caveats...))
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDCaveats,
+		Critical: true,
+		Value:    val,
+	}, nil
+}
+
+// ParseCaveats parses a list of Caveats from a byte slice, i.e. the Value of an Extension
+func ParseCaveats(in []byte) ([]Caveat, error) {
+	caveats := []Caveat{}
+	if err := cbor.Unmarshal(in, &caveats); err != nil {
+		return nil, fmt.Errorf("Failed to parse Caveats: %w", err)
+	}
+	return caveats, nil
+}
+
+// Caveats returns the Caveats of the certificate. If the certificate has no Caveats extension nil
+// is returned.
+func (c *Certificate) Caveats() ([]Caveat, error) {
+	var caveats []Caveat
+	err := RequiresExtension(c, OIDCaveats, func(critical bool, val []byte) (err error) {
+		caveats, err = ParseCaveats(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return caveats, err
+}
+
+// CheckCaveats evaluates the caveats of all certificates in chain against ctx. The chain needs to be
+// validated before.
+func CheckCaveats(ctx *CaveatContext, chain ...*Certificate) error {
+	for _, cert := range chain {
+		caveats, err := cert.Caveats()
+		if err != nil {
+			return newValidationError(ErrMalformedCertificate, cert, "Invalid Caveats: %s", err)
+		}
+		for _, caveat := range caveats {
+			checker := findCaveatChecker(caveat.Type)
+			if checker == nil {
+				return fmt.Errorf("%w: caveat of unknown type '%s' in certificate '%s'",
+					ErrCaveatNotSatisfied, caveat.Type, cert.Subject)
+			}
+			if err := checker(caveat.Value, ctx); err != nil {
+				return fmt.Errorf("%w: %s", ErrCaveatNotSatisfied, err)
+			}
+		}
+	}
+	return nil
+}
+
+// CaveatHook returns a ValidationHook evaluating the caveats of every certificate of a chain
+// against ctx during validation
+func CaveatHook(ctx *CaveatContext) ValidationHook {
+	return func(cert, issuer *Certificate) error {
+		return CheckCaveats(ctx, cert)
+	}
+}
@@ -0,0 +1,71 @@
+package smolcert
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaveats(t *testing.T) {
+	now := time.Now()
+	expiry, err := ExpiryCaveat(now.Add(time.Hour))
+	require.NoError(t, err)
+	audience, err := AudienceCaveat("door-controller", "light-controller")
+	require.NoError(t, err)
+	_, network, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	ipRange, err := IPRangeCaveat(network)
+	require.NoError(t, err)
+	operation, err := OperationCaveat("open")
+	require.NoError(t, err)
+	ext, err := CaveatsExtension(expiry, audience, ipRange, operation)
+	require.NoError(t, err)
+
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, []Extension{ext}, rootKey, "root")
+	require.NoError(t, err)
+	caveats, err := cert.Caveats()
+	require.NoError(t, err)
+	assert.Len(t, caveats, 4)
+
+	ctx := &CaveatContext{Audience: "door-controller", RemoteIP: net.ParseIP("10.1.2.3"), Operation: "open"}
+	assert.NoError(t, CheckCaveats(ctx, cert, rootCert))
+	assert.NoError(t, NewValidator(NewCertPool(rootCert), CaveatHook(ctx)).Validate(cert))
+
+	for _, failing := range []*CaveatContext{
+		{Time: now.Add(time.Hour * 2), Audience: "door-controller", RemoteIP: net.ParseIP("10.1.2.3"), Operation: "open"},
+		{Audience: "garage", RemoteIP: net.ParseIP("10.1.2.3"), Operation: "open"},
+		{Audience: "door-controller", RemoteIP: net.ParseIP("192.168.1.1"), Operation: "open"},
+		{Audience: "door-controller", Operation: "open"},
+		{Audience: "door-controller", RemoteIP: net.ParseIP("10.1.2.3"), Operation: "close"},
+	} {
+		assert.True(t, errors.Is(CheckCaveats(failing, cert), ErrCaveatNotSatisfied))
+		err := NewValidator(NewCertPool(rootCert), CaveatHook(failing)).Validate(cert)
+		assert.True(t, errors.Is(err, ErrRejectedByHook))
+	}
+}
+
+func TestCustomCaveatChecker(t *testing.T) {
+	caveat := Caveat{Type: "test-tenant", Value: []byte("tenant-a")}
+	ext, err := CaveatsExtension(caveat)
+	require.NoError(t, err)
+	rootCert, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, []Extension{ext})
+	require.NoError(t, err)
+
+	ctx := &CaveatContext{Values: map[string]interface{}{"tenant": "tenant-a"}}
+	assert.True(t, errors.Is(CheckCaveats(ctx, rootCert), ErrCaveatNotSatisfied))
+
+	RegisterCaveatChecker("test-tenant", func(value []byte, ctx *CaveatContext) error {
+		if ctx.Values["tenant"] != string(value) {
+			return errors.New("Wrong tenant")
+		}
+		return nil
+	})
+	assert.NoError(t, CheckCaveats(ctx, rootCert))
+	assert.Error(t, CheckCaveats(&CaveatContext{}, rootCert))
+}
@@ -0,0 +1,107 @@
+package smolcert
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// certIDSeparator separates the issuer and the serial number in the string form of a CertID.
+// Serial numbers never contain it, so issuers may contain it as well.
+const certIDSeparator = "#"
+
+// CertID identifies a certificate by the subject of its issuer and its serial number, which
+// is unique per issuer. It is used as handle for a certificate in revocation and audit tooling,
+// without needing the certificate itself.
+type CertID struct {
+	Issuer       string
+	SerialNumber uint64
+}
+
+// ID returns the CertID of the certificate
+func (c *Certificate) ID() CertID {
+	return CertID{Issuer: c.Issuer, SerialNumber: c.SerialNumber}
+}
+
+// String returns the canonical form of the CertID, the issuer and the decimal serial number
+// separated by '#', i.e. "root#1234"
+func (id CertID) String() string {
+	return id.Issuer + certIDSeparator + strconv.FormatUint(id.SerialNumber, 10)
+}
+
+// ParseCertID parses a CertID from its canonical form
+func ParseCertID(s string) (CertID, error) {
+	i := strings.LastIndex(s, certIDSeparator)
+	if i < 0 {
+		return CertID{}, fmt.Errorf("Certificate ID '%s' has no serial number", s)
+	}
+	if i == 0 {
+		return CertID{}, fmt.Errorf("Certificate ID '%s' has no issuer", s)
+	}
+	serialNumber, err := strconv.ParseUint(s[i+1:], 10, 64)
+	if err != nil {
+		return CertID{}, fmt.Errorf("Certificate ID '%s' has an invalid serial number: %w", s, err)
+	}
+	return CertID{Issuer: s[:i], SerialNumber: serialNumber}, nil
+}
+
+// MarshalText encodes the CertID in its canonical form, i.e. for JSON audit logs
+func (id CertID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText decodes a CertID from its canonical form
+func (id *CertID) UnmarshalText(text []byte) error {
+	parsed, err := ParseCertID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Matches is true if cert has been issued by the issuer of the CertID with its serial number
+func (id CertID) Matches(cert *Certificate) bool {
+	return cert.SerialNumber == id.SerialNumber && cert.Issuer == id.Issuer
+}
+
+// Lookup returns the certificate of the pool identified by id or nil. As roots are self-signed,
+// the issuer of id is the subject of the root.
+func (c *CertPool) Lookup(id CertID) *Certificate {
+	for _, cert := range c.Certificates(id.Issuer) {
+		if id.Matches(cert) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// Lookup returns the certificate identified by id from the Store of the CA. Certificates of other
+// issuers are never found, an error wrapping ErrNotFound is returned instead.
+func (ca *CA) Lookup(id CertID) (*Certificate, error) {
+	if id.Issuer != ca.Certificate.Subject {
+		return nil, fmt.Errorf("%w: %s has not been issued by '%s'", ErrNotFound, id, ca.Certificate.Subject)
+	}
+	cert, err := ca.store.Certificate(id.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !id.Matches(cert) {
+		return nil, fmt.Errorf("%w: %s has not been issued by '%s'", ErrNotFound, id, ca.Certificate.Subject)
+	}
+	return cert, nil
+}
+
+// LookupID returns the entry of the certificate identified by id. Unlike Lookup, certificates of
+// other issuers are never reported as revoked.
+func (crl *CRL) LookupID(id CertID) (RevokedCertificate, bool) {
+	if crl.Issuer != id.Issuer {
+		return RevokedCertificate{}, false
+	}
+	return crl.Lookup(id.SerialNumber)
+}
+
+// ID returns the CertID of the certificate whose serial number is used already
+func (e *SerialNumberCollisionError) ID() CertID {
+	return CertID{Issuer: e.Issuer, SerialNumber: e.SerialNumber}
+}
@@ -0,0 +1,72 @@
+package smolcert
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCertIDString(t *testing.T) {
+	id := CertID{Issuer: "acme#ca", SerialNumber: 1234}
+	assert.Equal(t, "acme#ca#1234", id.String())
+	parsed, err := ParseCertID(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	for _, invalid := range []string{"", "root", "#1", "root#", "root#-1", "root#0x10", "root#18446744073709551616"} {
+		_, err := ParseCertID(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	buf, err := json.Marshal(map[string]CertID{"cert": id})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cert": "acme#ca#1234"}`, string(buf))
+	var decoded map[string]CertID
+	require.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.Equal(t, id, decoded["cert"])
+}
+
+func TestCertIDLookup(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	otherRoot, _, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert, otherRoot)
+	assert.Equal(t, rootCert, pool.Lookup(rootCert.ID()))
+	assert.Nil(t, pool.Lookup(CertID{Issuer: "root", SerialNumber: rootCert.SerialNumber + 1}))
+
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	assert.Equal(t, CertID{Issuer: "root", SerialNumber: cert.SerialNumber}, cert.ID())
+	assert.True(t, cert.ID().Matches(cert))
+	assert.False(t, CertID{Issuer: "other", SerialNumber: cert.SerialNumber}.Matches(cert))
+
+	found, err := ca.Lookup(cert.ID())
+	require.NoError(t, err)
+	assert.Equal(t, cert, found)
+	_, err = ca.Lookup(CertID{Issuer: "other", SerialNumber: cert.SerialNumber})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = ca.Lookup(CertID{Issuer: "root", SerialNumber: cert.SerialNumber + 1})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	require.NoError(t, ca.Revoke(cert.SerialNumber, RevocationReasonKeyCompromise))
+	crl, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	entry, revoked := crl.LookupID(cert.ID())
+	assert.True(t, revoked)
+	assert.Equal(t, RevocationReasonKeyCompromise, entry.Reason)
+	_, revoked = crl.LookupID(CertID{Issuer: "other", SerialNumber: cert.SerialNumber})
+	assert.False(t, revoked)
+
+	collision := &SerialNumberCollisionError{Issuer: "root", SerialNumber: cert.SerialNumber}
+	assert.Equal(t, cert.ID(), collision.ID())
+}
@@ -10,7 +10,9 @@ package smolcert
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"math"
 	"time"
 
 	"github.com/fxamacker/cbor/v2"
@@ -31,10 +33,32 @@ func init() {
 	}
 }
 
+// Versions of the certificate format
+const (
+	// Version1 appends the signature algorithm to the seven fields of the original format, both
+	// have no explicit version
+	Version1 uint64 = 1
+	// Version2 prepends the version to the fields of Version1
+	Version2 uint64 = 2
+	// Version3 encodes the validity with millisecond precision
+	Version3 uint64 = 3
+	// Version4 prefixes the signed bytes with signatureContext, so that signatures of certificates
+	// can't be confused with ed25519 signatures of other protocols made with the same key
+	Version4 uint64 = 4
+	// LatestVersion is the latest version of the format understood by this package
+	LatestVersion = Version4
+)
+
 // Certificate represents CBOR based certificates based on the provide spec.cddl
 type Certificate struct {
 	_ struct{} `cbor:",toarray"`
 
+	// Version is the version of the encoding of the certificate. Zero denotes Version1, which is
+	// used by default. Its eight fields are only understood by verifiers which know the signature
+	// algorithm, older verifiers can only parse the seven fields of the original format.
+	// Certificates of the original format are decoded with version zero as well and are encoded
+	// in their original form again.
+	Version      uint64 `cbor:"-"`
 	SerialNumber uint64 `cbor:"serial_number"`
 	Issuer       string `cbor:"issuer"`
 	// NotBefore and NotAfter might be 0 to indicate to be ignored during validation
@@ -42,7 +66,18 @@ type Certificate struct {
 	Subject    string            `cbor:"subject"`
 	PubKey     ed25519.PublicKey `cbor:"public_key"`
 	Extensions []Extension       `cbor:"extensions"`
-	Signature  []byte            `cbor:"signature"`
+	// SignatureAlgorithm is the algorithm of the signature, set by SignCertificate
+	SignatureAlgorithm Algorithm `cbor:"signature_algorithm"`
+	Signature          []byte    `cbor:"signature"`
+	// UnknownFields are the fields appended by newer versions of the format, which are not
+	// understood by this package. They are preserved when the certificate is encoded again, so
+	// that its signature can still be verified.
+	UnknownFields []cbor.RawMessage `cbor:"-"`
+
+	// legacy is set for certificates decoded from the seven fields of the original format,
+	// which had no signature algorithm. They are encoded in this form again, as long as the
+	// implied Ed25519 algorithm is kept.
+	legacy bool
 }
 
 // PublicKey returns the public key of this certificate as byte slice.
@@ -55,23 +90,66 @@ func (c *Certificate) PublicKey() []byte {
 // parts of the certificate, but need to continue working with an unaltered original.
 func (c *Certificate) Copy() *Certificate {
 	// Convert the public key to a byte slice and create a copy of this slice
-	p2 := append([]byte{}, []byte(c.PubKey)...)
+	p2 := copyBytes(c.PubKey)
+	var v2 *Validity
+	if c.Validity != nil {
+		v2 = &Validity{
+			NotBefore:       c.Validity.NotBefore,
+			NotAfter:        c.Validity.NotAfter,
+			NotBeforeMillis: c.Validity.NotBeforeMillis,
+			NotAfterMillis:  c.Validity.NotAfterMillis,
+		}
+	}
+	var e2 []Extension
+	if c.Extensions != nil {
+		e2 = append([]Extension{}, c.Extensions...)
+	}
 	c2 := &Certificate{
+		Version:      c.Version,
 		SerialNumber: c.SerialNumber,
 		Issuer:       c.Issuer,
-		Validity: &Validity{
-			NotBefore: c.Validity.NotBefore,
-			NotAfter:  c.Validity.NotAfter,
-		},
-		Subject: c.Subject,
+		Validity:     v2,
+		Subject:      c.Subject,
 		// Reconstruct a public key from the byte slice copy we have created above
-		PubKey:     ed25519.PublicKey(p2),
-		Extensions: append([]Extension{}, c.Extensions...),
-		Signature:  append([]byte{}, c.Signature...),
+		PubKey:             ed25519.PublicKey(p2),
+		Extensions:         e2,
+		SignatureAlgorithm: c.SignatureAlgorithm,
+		Signature:          copyBytes(c.Signature),
+		legacy:             c.legacy,
+	}
+	for _, field := range c.UnknownFields {
+		c2.UnknownFields = append(c2.UnknownFields, copyBytes(field))
 	}
 	return c2
 }
 
+// copyBytes copies b, preserving nil. A nil slice is encoded differently than an empty one, so the
+// copy of a certificate would otherwise have a different encoding.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+// RemainingValidity returns the time left until the certificate expires. The result is negative if
+// the certificate has already expired. Certificates without NotAfter never expire, in this case
+// the maximum possible duration is returned.
+func (c *Certificate) RemainingValidity() time.Duration {
+	if c.Validity == nil || c.Validity.NotAfter.IsZero() {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Until(c.Validity.NotAfterTime())
+}
+
+// ExpiresWithin is true if the certificate expires within d or has already expired
+func (c *Certificate) ExpiresWithin(d time.Duration) bool {
+	if c.Validity == nil || c.Validity.NotAfter.IsZero() {
+		return false
+	}
+	return c.RemainingValidity() <= d
+}
+
 // Bytes returns the CBOR encoded form of the certificate as byte slice
 func (c *Certificate) Bytes() ([]byte, error) {
 	buf := &bytes.Buffer{}
@@ -79,6 +157,66 @@ func (c *Certificate) Bytes() ([]byte, error) {
 	return buf.Bytes(), err
 }
 
+// MarshalBinary implements encoding.BinaryMarshaler with the CBOR encoding of the certificate,
+// so certificates can be used with encoding/gob and caches storing binary values
+func (c *Certificate) MarshalBinary() ([]byte, error) {
+	return c.Bytes()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. Unlike ParseBuf data after the
+// certificate is rejected. If the data is invalid, c is left unchanged.
+func (c *Certificate) UnmarshalBinary(data []byte) error {
+	parsed := new(Certificate)
+	if err := parsed.UnmarshalCBOR(data); err != nil {
+		return err
+	}
+	c.set(parsed)
+	return nil
+}
+
+// set replaces the fields of c with the ones of other
+func (c *Certificate) set(other *Certificate) {
+	c.Version = other.Version
+	c.SerialNumber = other.SerialNumber
+	c.Issuer = other.Issuer
+	c.Validity = other.Validity
+	c.Subject = other.Subject
+	c.PubKey = other.PubKey
+	c.Extensions = other.Extensions
+	c.SignatureAlgorithm = other.SignatureAlgorithm
+	c.Signature = other.Signature
+	c.UnknownFields = other.UnknownFields
+	c.legacy = other.legacy
+}
+
+// signatureContext is prepended to the to-be-signed encoding of certificates of Version4 and later
+var signatureContext = []byte("smolcert-v1")
+
+// TBSBytes returns the to-be-signed encoding of the certificate, which is the CBOR encoding
+// with an empty signature, prefixed with a fixed context starting with Version4. It is encoded
+// from the current fields on every call, so certificates modified after signing fail validation.
+func (c *Certificate) TBSBytes() ([]byte, error) {
+	tbsCert := &Certificate{
+		Version:      c.Version,
+		SerialNumber: c.SerialNumber,
+		Issuer:       c.Issuer,
+		Validity:     c.Validity,
+		Subject:      c.Subject,
+		PubKey:       c.PubKey,
+		Extensions:   c.Extensions,
+		// The algorithm is signed as well, so it can't be changed afterwards
+		SignatureAlgorithm: c.SignatureAlgorithm,
+		Signature:          nil,
+		UnknownFields:      c.UnknownFields,
+		legacy:             c.legacy,
+	}
+	buf, err := tbsCert.Bytes()
+	if err != nil || c.Version < Version4 {
+		return buf, err
+	}
+	return append(append([]byte{}, signatureContext...), buf...), nil
+}
+
 // Time is a type to represent int encoded time stamps based on the elapsed seconds since epoch
 type Time int64
 
@@ -109,6 +247,145 @@ type Validity struct {
 
 	NotBefore Time `cbor:"notBefore"`
 	NotAfter  Time `cbor:"notAfter"`
+	// NotBeforeMillis and NotAfterMillis are the milliseconds to add to NotBefore and NotAfter.
+	// They are only encoded by certificates of Version3 or later and dropped by older versions.
+	NotBeforeMillis uint16 `cbor:"-"`
+	NotAfterMillis  uint16 `cbor:"-"`
+}
+
+// NewMillisValidity creates a Validity with millisecond precision. Zero times leave the
+// respective constraint unset. The precision is only kept by certificates of Version3 or later.
+func NewMillisValidity(notBefore, notAfter time.Time) *Validity {
+	v := &Validity{}
+	if !notBefore.IsZero() {
+		v.NotBefore, v.NotBeforeMillis = splitMillis(unixMillis(notBefore))
+	}
+	if !notAfter.IsZero() {
+		v.NotAfter, v.NotAfterMillis = splitMillis(unixMillis(notAfter))
+	}
+	return v
+}
+
+// unixMillis returns t in milliseconds since the epoch. Unlike UnixNano it doesn't overflow
+// after 2262, i.e. for the NotAfter of X.509 certificates without expiry.
+func unixMillis(t time.Time) int64 {
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
+// NotBeforeTime returns NotBefore including the milliseconds
+func (v *Validity) NotBeforeTime() time.Time {
+	return v.NotBefore.StdTime().Add(time.Duration(v.NotBeforeMillis) * time.Millisecond)
+}
+
+// NotAfterTime returns NotAfter including the milliseconds
+func (v *Validity) NotAfterTime() time.Time {
+	return v.NotAfter.StdTime().Add(time.Duration(v.NotAfterMillis) * time.Millisecond)
+}
+
+// Duration returns the time between NotBefore and NotAfter with millisecond precision
+func (v *Validity) Duration() time.Duration {
+	return v.NotAfterTime().Sub(v.NotBeforeTime())
+}
+
+// millisValidity is the encoding of a Validity in Version3, both times are milliseconds since epoch
+type millisValidity struct {
+	_ struct{} `cbor:",toarray"`
+
+	NotBefore int64 `cbor:"notBefore"`
+	NotAfter  int64 `cbor:"notAfter"`
+}
+
+func splitMillis(millis int64) (Time, uint16) {
+	secs, rest := millis/1000, millis%1000
+	if rest < 0 {
+		secs, rest = secs-1, rest+1000
+	}
+	return Time(secs), uint16(rest)
+}
+
+// MarshalCBOR encodes the validity in seconds
+func (v Validity) MarshalCBOR() ([]byte, error) {
+	return v.appendCBOR(nil), nil
+}
+
+func (v *Validity) appendCBOR(buf []byte) []byte {
+	buf = appendHead(buf, majorArray, 2)
+	return appendInt(appendInt(buf, int64(v.NotBefore)), int64(v.NotAfter))
+}
+
+// UnmarshalCBOR decodes a validity in seconds
+func (v *Validity) UnmarshalCBOR(data []byte) error {
+	return decodeItem(data, func(d *cborDecoder) error {
+		var decoded *Validity
+		if err := d.validity(&decoded); err != nil {
+			return err
+		}
+		if decoded != nil {
+			*v = *decoded
+		}
+		return nil
+	})
+}
+
+func (d *cborDecoder) validity(v **Validity) error {
+	times, err := d.times()
+	if times != nil {
+		*v = &Validity{NotBefore: Time(times[0]), NotAfter: Time(times[1])}
+	} else {
+		*v = nil
+	}
+	return err
+}
+
+func (d *cborDecoder) millisValidity(m **millisValidity) error {
+	times, err := d.times()
+	if times != nil {
+		*m = &millisValidity{NotBefore: times[0], NotAfter: times[1]}
+	} else {
+		*m = nil
+	}
+	return err
+}
+
+// times decodes an array of two integers, null results in nil
+func (d *cborDecoder) times() ([]int64, error) {
+	items, null, err := d.array()
+	if err != nil || null {
+		return nil, err
+	}
+	if len(items) != 2 {
+		return nil, fmt.Errorf("cbor: cannot decode CBOR array of %d elements into a validity", len(items))
+	}
+	times := make([]int64, 2)
+	for i := range items {
+		if err := decodeItem(items[i], func(d *cborDecoder) (err error) {
+			times[i], err = d.int()
+			return
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return times, nil
+}
+
+func (v *Validity) toMillis() *millisValidity {
+	if v == nil {
+		return nil
+	}
+	return &millisValidity{
+		NotBefore: int64(v.NotBefore)*1000 + int64(v.NotBeforeMillis),
+		NotAfter:  int64(v.NotAfter)*1000 + int64(v.NotAfterMillis),
+	}
+}
+
+func (m *millisValidity) toValidity() *Validity {
+	if m == nil {
+		return nil
+	}
+	v := &Validity{}
+	v.NotBefore, v.NotBeforeMillis = splitMillis(m.NotBefore)
+	v.NotAfter, v.NotAfterMillis = splitMillis(m.NotAfter)
+	return v
 }
 
 // Parse parses a Certificate from an io.Reader
@@ -121,10 +398,170 @@ func Parse(r io.Reader) (cert *Certificate, err error) {
 // ParseBuf parses a certificate from an existing byte buffer
 func ParseBuf(buf []byte) (cert *Certificate, err error) {
 	cert = new(Certificate)
-	err = cbor.Unmarshal(buf, cert)
+	// Like the CBOR package data after the certificate is ignored
+	item, err := (&cborDecoder{data: buf}).raw()
+	if err != nil {
+		return cert, err
+	}
+	err = cert.UnmarshalCBOR(item)
 	return
 }
 
+// MarshalCBOR encodes the certificate in the format of its Version
+func (c *Certificate) MarshalCBOR() ([]byte, error) {
+	if c.Version <= Version1 && len(c.UnknownFields) > 0 {
+		return nil, fmt.Errorf("%w: version 1 certificates can't have additional fields", ErrUnsupportedVersion)
+	}
+	buf := make([]byte, 0, 128+len(c.Signature)+len(c.PubKey))
+	legacy := c.isLegacy()
+	switch {
+	case legacy:
+		buf = appendHead(buf, majorArray, 7)
+	case c.Version <= Version1:
+		buf = appendHead(buf, majorArray, 8)
+	default:
+		buf = appendHead(buf, majorArray, uint64(9+len(c.UnknownFields)))
+		buf = appendHead(buf, majorUint, c.Version)
+	}
+	buf = appendHead(buf, majorUint, c.SerialNumber)
+	buf = appendText(buf, c.Issuer)
+	switch {
+	case c.Validity == nil:
+		buf = append(buf, cborNull)
+	case c.Version >= Version3:
+		m := c.Validity.toMillis()
+		buf = appendInt(appendInt(appendHead(buf, majorArray, 2), m.NotBefore), m.NotAfter)
+	default:
+		buf = c.Validity.appendCBOR(buf)
+	}
+	buf = appendText(buf, c.Subject)
+	buf = appendBytes(buf, c.PubKey)
+	if c.Extensions == nil {
+		buf = append(buf, cborNull)
+	} else {
+		buf = appendHead(buf, majorArray, uint64(len(c.Extensions)))
+		for _, ext := range c.Extensions {
+			buf = ext.appendCBOR(buf)
+		}
+	}
+	if !legacy {
+		buf = appendInt(buf, int64(c.SignatureAlgorithm))
+	}
+	buf = appendBytes(buf, c.Signature)
+	for i, field := range c.UnknownFields {
+		if len(field) == 0 {
+			buf = append(buf, cborNull)
+			continue
+		}
+		if err := decodeItem(field, func(d *cborDecoder) error { return d.skip(0) }); err != nil {
+			return nil, fmt.Errorf("%w: unknown field %d is no single CBOR item: %s", ErrMalformedCertificate, i, err)
+		}
+		buf = append(buf, field...)
+	}
+	return buf, nil
+}
+
+// isLegacy is true if the certificate is encoded in the original format of seven fields
+func (c *Certificate) isLegacy() bool {
+	return c.legacy && c.Version <= Version1 && c.SignatureAlgorithm == AlgorithmEd25519
+}
+
+// UnmarshalCBOR decodes a certificate of any version. Arrays of seven fields are certificates of
+// the original format, which are signed with Ed25519, and arrays of eight fields are Version1
+// certificates. Both get the Version zero, otherwise the first field is the version. Fields after
+// the ones known for Version2 are kept as UnknownFields, even for versions newer than
+// LatestVersion. Whether such certificates are valid is decided during validation.
+func (c *Certificate) UnmarshalCBOR(data []byte) error {
+	var items []cbor.RawMessage
+	if err := decodeItem(data, func(d *cborDecoder) (err error) {
+		items, _, err = d.array()
+		return
+	}); err != nil {
+		return err
+	}
+	c.legacy = false
+	switch {
+	case len(items) == 7:
+		c.Version = 0
+		c.UnknownFields = nil
+		c.legacy = true
+		return c.unmarshalFields(items, false)
+	case len(items) == 8:
+		c.Version = 0
+		c.UnknownFields = nil
+		return c.unmarshalFields(items, false)
+	case len(items) < 9:
+		return fmt.Errorf("%w: certificate has %d fields, expected 7, 8 or at least 9", ErrMalformedCertificate, len(items))
+	}
+	var version uint64
+	if err := decodeItem(items[0], func(d *cborDecoder) (err error) {
+		version, err = d.uint()
+		return
+	}); err != nil {
+		return fmt.Errorf("%w: invalid version: %s", ErrMalformedCertificate, err)
+	}
+	if version < Version2 {
+		return fmt.Errorf("%w: version %d certificates have no explicit version", ErrMalformedCertificate, version)
+	}
+	c.Version = version
+	c.UnknownFields = nil
+	for _, field := range items[9:] {
+		c.UnknownFields = append(c.UnknownFields, append(cbor.RawMessage{}, field...))
+	}
+	return c.unmarshalFields(items[1:9], version >= Version3)
+}
+
+// unmarshalFields decodes the fields shared by all versions. With millis the validity is decoded
+// in milliseconds. Without the signature algorithm, as in the seven fields of the original format,
+// it is Ed25519.
+func (c *Certificate) unmarshalFields(items []cbor.RawMessage, millis bool) error {
+	validity := func(d *cborDecoder) error {
+		if !millis {
+			return d.validity(&c.Validity)
+		}
+		var m *millisValidity
+		if err := d.millisValidity(&m); err != nil {
+			return err
+		}
+		c.Validity = m.toValidity()
+		return nil
+	}
+	decoders := []func(d *cborDecoder) error{
+		func(d *cborDecoder) (err error) { c.SerialNumber, err = d.uint(); return },
+		func(d *cborDecoder) (err error) { c.Issuer, err = d.text(); return },
+		validity,
+		func(d *cborDecoder) (err error) { c.Subject, err = d.text(); return },
+		func(d *cborDecoder) (err error) { c.PubKey, err = d.bytes(); return },
+		func(d *cborDecoder) (err error) { c.Extensions, err = d.extensions(); return },
+		func(d *cborDecoder) error {
+			alg, err := d.int()
+			c.SignatureAlgorithm = Algorithm(alg)
+			return err
+		},
+		func(d *cborDecoder) (err error) { c.Signature, err = d.bytes(); return },
+	}
+	if len(items) == 7 {
+		c.SignatureAlgorithm = AlgorithmEd25519
+		decoders = append(decoders[:6], decoders[7])
+	}
+	for i, decode := range decoders {
+		if err := decodeItem(items[i], decode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkVersion rejects certificates of newer versions or with unknown fields, unless accept is set
+// with the VerifyOption AcceptUnknownFields
+func checkVersion(cert *Certificate, accept bool) error {
+	if accept || (cert.Version <= LatestVersion && len(cert.UnknownFields) == 0) {
+		return nil
+	}
+	return newValidationError(ErrUnsupportedVersion, cert,
+		"certificate has version %d with %d unknown fields", cert.Version, len(cert.UnknownFields))
+}
+
 // Serialize serializes a Certificate to an io.Writer
 func Serialize(cert *Certificate, w io.Writer) (err error) {
 	return cborEm.NewEncoder(w).Encode(cert)
@@ -3,10 +3,14 @@ package smolcert
 import (
 	"bytes"
 	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/stretchr/testify/require"
@@ -259,3 +263,358 @@ func TestCreateSignedCertificate(t *testing.T) {
 	require.NoError(t, err)
 	assert.NoError(t, rootCertPool.Validate(serverCert))
 }
+
+func TestModifiedCertificateFailsValidation(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	other, _, err := ClientCertificate("other", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	v := &Validator{Pool: pool, Cache: NewValidationCache(10, time.Hour)}
+	require.NoError(t, pool.Validate(cert))
+	require.NoError(t, v.Validate(cert))
+
+	// Changes made after a validation invalidate the signature
+	cert.Subject = "intermediate"
+	cert.Extensions = []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}
+	assert.True(t, errors.Is(pool.Validate(cert), ErrBadSignature))
+	_, err = pool.ValidateBundle([]*Certificate{cert})
+	assert.True(t, errors.Is(err, ErrBadSignature))
+	assert.True(t, errors.Is(v.Validate(cert), ErrBadSignature))
+
+	// Decoding into an existing certificate replaces all signed fields
+	buf, err := other.Bytes()
+	require.NoError(t, err)
+	require.NoError(t, cert.UnmarshalCBOR(buf))
+	tbs, err := cert.TBSBytes()
+	require.NoError(t, err)
+	expected, err := other.TBSBytes()
+	require.NoError(t, err)
+	assert.Equal(t, expected, tbs)
+	assert.NoError(t, pool.Validate(cert))
+}
+
+func TestRenewCertificate(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	cert, _, err := ClientCertificate("client", 12, now.Add(-time.Hour), now.Add(-time.Minute), nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	assert.Error(t, pool.Validate(cert))
+
+	renewed, err := Renew(cert, &Validity{
+		NotBefore: NewTime(now.Add(-time.Minute)),
+		NotAfter:  NewTime(now.Add(time.Hour)),
+	}, rootKey)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(renewed))
+
+	assert.NotEqual(t, cert.SerialNumber, renewed.SerialNumber)
+	assert.Equal(t, cert.Subject, renewed.Subject)
+	assert.Equal(t, cert.Issuer, renewed.Issuer)
+	assert.Equal(t, cert.PubKey, renewed.PubKey)
+	assert.Equal(t, cert.Extensions, renewed.Extensions)
+	// The original certificate is left untouched
+	assert.Error(t, pool.Validate(cert))
+}
+
+func TestExpiryHelpers(t *testing.T) {
+	now := time.Now()
+	cert, _, err := SelfSignedCertificate("root", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+
+	remaining := cert.RemainingValidity()
+	assert.True(t, remaining > time.Minute*59 && remaining <= time.Hour)
+	assert.True(t, cert.ExpiresWithin(time.Hour*2))
+	assert.False(t, cert.ExpiresWithin(time.Minute))
+
+	unbounded, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	assert.False(t, unbounded.ExpiresWithin(time.Hour*24*365*100))
+	assert.True(t, unbounded.RemainingValidity() > time.Hour*24*365*100)
+
+	expired, _, err := SelfSignedCertificate("root", now.Add(-time.Hour), now.Add(-time.Minute), nil)
+	require.NoError(t, err)
+	assert.True(t, expired.RemainingValidity() < 0)
+	assert.True(t, expired.ExpiresWithin(0))
+}
+
+func TestCopyPreservesEncoding(t *testing.T) {
+	cert := &Certificate{Issuer: "root", Subject: "device", Validity: &Validity{}}
+	buf1, err := cert.Bytes()
+	require.NoError(t, err)
+	buf2, err := cert.Copy().Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, buf1, buf2)
+}
+
+func TestCertificateVersions(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	v1Cert, err := Issue("device", pubKey, "root", rootKey)
+	require.NoError(t, err)
+	v2Cert, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version2))
+	require.NoError(t, err)
+
+	v1Bytes, err := v1Cert.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x88), v1Bytes[0])
+	v2Bytes, err := v2Cert.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x89, 0x02}, v2Bytes[:2])
+
+	parsed, err := ParseBuf(v1Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), parsed.Version)
+	parsed, err = ParseBuf(v2Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, Version2, parsed.Version)
+	pool := NewCertPool(rootCert)
+	require.NoError(t, pool.Validate(parsed))
+
+	// The version is covered by the signature
+	downgraded := parsed.Copy()
+	downgraded.Version = Version1
+	assert.True(t, errors.Is(pool.Validate(downgraded), ErrBadSignature))
+
+	_, err = ParseBuf([]byte{0x80})
+	assert.True(t, errors.Is(err, ErrMalformedCertificate))
+	// Arrays of nine or more fields need to start with a version of at least Version2
+	explicitV1 := append([]byte{0x89, 0x01}, v1Bytes[1:]...)
+	_, err = ParseBuf(explicitV1)
+	assert.True(t, errors.Is(err, ErrMalformedCertificate))
+	_, err = Issue("device", pubKey, "root", rootKey, WithVersion(LatestVersion+1))
+	assert.Error(t, err)
+}
+
+// Certificates encoded by the original format, an array of seven fields without version and
+// signature algorithm
+const (
+	legacyRootCert   = "870164726f6f7482000064726f6f74582003a107bff3ce10be1d70dd18e74bc09967e4d6309ba50d5f1ddc8664125531b8818310f541035840946e7eef10ca138aab93c184d16293a0c97daf80e850131b415b32dfb13f3cfc6cd7d3a23fd0f654297f5c6ef1927ce2256dc5ad46550b968f8098f69ff04304"
+	legacyClientCert = "870564726f6f74821a5f5e10001af4865700666465766963655820e5d3c39e6078593da1e5bf4aef25a7f296567a360b714f9a62235591d7050381818310f541015840ac3788280b9cc3f8c820edaf9b30105979d2cb021f18a85bfd402d2383c1d25829a7365c308bf27f37fac7a3bfd01a777f7f8f70df26d78b8d0424ef8a43550f"
+)
+
+func TestLegacyCertificates(t *testing.T) {
+	rootBytes, err := hex.DecodeString(legacyRootCert)
+	require.NoError(t, err)
+	clientBytes, err := hex.DecodeString(legacyClientCert)
+	require.NoError(t, err)
+
+	rootCert, err := ParseBuf(rootBytes)
+	require.NoError(t, err)
+	clientCert, err := ParseBuf(clientBytes)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), clientCert.Version)
+	assert.Equal(t, uint64(5), clientCert.SerialNumber)
+	assert.Equal(t, "device", clientCert.Subject)
+	assert.Equal(t, AlgorithmEd25519, clientCert.SignatureAlgorithm)
+
+	pool := NewCertPool(rootCert)
+	require.NoError(t, pool.Validate(clientCert))
+	assert.NoError(t, VerifyRaw(clientBytes, rootCert.PubKey))
+	assert.NoError(t, CheckFormat(clientBytes))
+
+	// Legacy certificates are encoded in their original form, so their signatures still verify
+	encoded, err := clientCert.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, clientBytes, encoded)
+	encoded, err = clientCert.Copy().Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, clientBytes, encoded)
+	require.NoError(t, pool.Validate(clientCert.Copy()))
+
+	// Other algorithms need the signature algorithm to be encoded
+	_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	resigned, err := SignCertificatePrehashed(clientCert.Copy(), Ed25519phKey(rootKey))
+	require.NoError(t, err)
+	encoded, err = resigned.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x88), encoded[0])
+}
+
+func TestCertificateUnknownFields(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	// A certificate of a future version with an additional field
+	newer, err := NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pubKey).Build()
+	require.NoError(t, err)
+	newer.Version = LatestVersion + 1
+	newer.UnknownFields = []cbor.RawMessage{{0x63, 'n', 'e', 'w'}}
+	newer, err = SignCertificate(newer, rootKey)
+	require.NoError(t, err)
+	buf, err := newer.Bytes()
+	require.NoError(t, err)
+
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	assert.Equal(t, LatestVersion+1, parsed.Version)
+	require.Len(t, parsed.UnknownFields, 1)
+	reencoded, err := parsed.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, buf, reencoded)
+	assert.Equal(t, parsed.UnknownFields, parsed.Copy().UnknownFields)
+
+	pool := NewCertPool(rootCert)
+	assert.True(t, errors.Is(pool.Validate(parsed), ErrUnsupportedVersion))
+	validator := NewValidator(pool)
+	assert.True(t, errors.Is(validator.Validate(parsed), ErrUnsupportedVersion))
+	// Accepting unknown fields only applies to validators with the option
+	accepting := NewValidator(pool)
+	accepting.Options = []VerifyOption{AcceptUnknownFields()}
+	require.NoError(t, accepting.Validate(parsed))
+	assert.True(t, errors.Is(validator.Validate(parsed), ErrUnsupportedVersion))
+
+	// The unknown fields are covered by the signature
+	tampered := parsed.Copy()
+	tampered.UnknownFields[0] = cbor.RawMessage{0x63, 'o', 'l', 'd'}
+	assert.True(t, errors.Is(accepting.Validate(tampered), ErrBadSignature))
+
+	// Version 1 certificates can't carry unknown fields
+	v1 := parsed.Copy()
+	v1.Version = Version1
+	_, err = v1.Bytes()
+	assert.Error(t, err)
+}
+
+func TestMillisecondValidity(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	notBefore := time.Unix(1700000000, 250*int64(time.Millisecond))
+	notAfter := notBefore.Add(1500 * time.Millisecond)
+
+	cert, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version3), WithValidity(notBefore, notAfter))
+	require.NoError(t, err)
+	assert.Equal(t, NewTime(notBefore), cert.Validity.NotBefore)
+	assert.Equal(t, uint16(250), cert.Validity.NotBeforeMillis)
+	assert.Equal(t, uint16(750), cert.Validity.NotAfterMillis)
+	assert.Equal(t, 1500*time.Millisecond, cert.Validity.Duration())
+
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	assert.Equal(t, Version3, parsed.Version)
+	assert.Equal(t, cert.Validity, parsed.Validity)
+	assert.True(t, notAfter.Equal(parsed.Validity.NotAfterTime()))
+
+	// The encoding contains the milliseconds since epoch
+	var items []cbor.RawMessage
+	require.NoError(t, cbor.Unmarshal(buf, &items))
+	var millis []int64
+	require.NoError(t, cbor.Unmarshal(items[3], &millis))
+	assert.Equal(t, []int64{1700000000250, 1700000001750}, millis)
+
+	// Older versions drop the milliseconds
+	v2Cert, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version2), WithValidity(notBefore, notAfter))
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0), v2Cert.Validity.NotAfterMillis)
+	assert.Equal(t, time.Second, v2Cert.Validity.Duration())
+
+	// Short lived credentials expire with millisecond precision
+	now := time.Now()
+	valid, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version3), WithValidity(now.Add(-time.Second), now.Add(time.Second)))
+	require.NoError(t, err)
+	assert.NoError(t, NewCertPool(rootCert).Validate(valid))
+	expired, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version3), WithValidity(now.Add(-time.Second), now.Add(-100*time.Millisecond)))
+	require.NoError(t, err)
+	assert.True(t, errors.Is(NewCertPool(rootCert).Validate(expired), ErrExpired))
+	notYetValid, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version3), WithValidity(now.Add(200*time.Millisecond), now.Add(time.Second)))
+	require.NoError(t, err)
+	assert.True(t, errors.Is(NewCertPool(rootCert).Validate(notYetValid), ErrNotYetValid))
+
+	// Times beyond the range of UnixNano, i.e. X.509 certificates without expiry
+	noExpiry := time.Date(9999, 12, 31, 23, 59, 59, 500*int(time.Millisecond), time.UTC)
+	v := NewMillisValidity(notBefore, noExpiry)
+	assert.True(t, noExpiry.Equal(v.NotAfterTime()))
+	assert.Equal(t, uint16(500), v.NotAfterMillis)
+}
+
+func TestSignatureContext(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	v3Cert, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version3))
+	require.NoError(t, err)
+	v3TBS, err := v3Cert.TBSBytes()
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x89), v3TBS[0])
+
+	v4Cert, err := Issue("device", pubKey, "root", rootKey, WithVersion(Version4))
+	require.NoError(t, err)
+	v4TBS, err := v4Cert.TBSBytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("smolcert-v1"), v4TBS[:len(signatureContext)])
+	unsigned := v4Cert.Copy()
+	unsigned.Signature = nil
+	unsignedBytes, err := unsigned.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, unsignedBytes, v4TBS[len(signatureContext):])
+	assert.False(t, ed25519.Verify(rootCert.PubKey, unsignedBytes, v4Cert.Signature))
+
+	buf, err := v4Cert.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(parsed))
+
+	// The context is bound to the version, so a signature can't be reused for another version
+	downgraded := parsed.Copy()
+	downgraded.Version = Version3
+	assert.True(t, errors.Is(pool.Validate(downgraded), ErrBadSignature))
+}
+
+func TestCertificateBinary(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 42, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	other, _, err := ClientCertificate("other", 43, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	buf, err := cert.MarshalBinary()
+	require.NoError(t, err)
+	expected, err := cert.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf)
+
+	// Certificates can be sent with gob, also as part of other values
+	type message struct {
+		Cert  *Certificate
+		Chain []*Certificate
+	}
+	encoded := &bytes.Buffer{}
+	require.NoError(t, gob.NewEncoder(encoded).Encode(message{Cert: cert, Chain: []*Certificate{cert, rootCert}}))
+	var decoded message
+	require.NoError(t, gob.NewDecoder(encoded).Decode(&decoded))
+	assert.Equal(t, cert.Signature, decoded.Cert.Signature)
+	require.Len(t, decoded.Chain, 2)
+	assert.NoError(t, pool.Validate(decoded.Chain[0]))
+	assert.NoError(t, pool.Validate(decoded.Chain[1]))
+
+	// Unmarshaling replaces a validated certificate
+	target := other.Copy()
+	require.NoError(t, pool.Validate(target))
+	require.NoError(t, target.UnmarshalBinary(buf))
+	assert.Equal(t, "device", target.Subject)
+	assert.NoError(t, pool.Validate(target))
+
+	assert.Error(t, target.UnmarshalBinary(append(buf, 0x00)), "trailing data is rejected")
+	assert.Error(t, target.UnmarshalBinary(buf[:len(buf)-1]))
+	assert.Error(t, target.UnmarshalBinary(nil))
+	assert.Equal(t, "device", target.Subject, "invalid data leaves the certificate unchanged")
+}
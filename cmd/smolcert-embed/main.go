@@ -0,0 +1,56 @@
+/*
+Command smolcert-embed generates a Go file embedding root certificates as CertPool, so binaries
+can compile in their trust anchors. It is meant to be run by go generate:
+
+	//go:generate go run github.com/smolcert/smolcert/cmd/smolcert-embed -o roots.go root.cert backup-root.cert
+
+The arguments are certificate files as written by smolcert.WriteCertFile. The package name
+defaults to the package running go generate, the generated function is named Roots unless set
+with -func.
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/smolcert/smolcert"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "smolcert-embed: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	output := flag.String("o", "", "file to write, stdout if empty")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	fn := flag.String("func", "Roots", "name of the generated function returning the CertPool")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		return fmt.Errorf("usage: smolcert-embed [-o file] [-package name] [-func name] cert-file...")
+	}
+
+	var roots []*smolcert.Certificate
+	for _, filename := range flag.Args() {
+		certs, err := smolcert.LoadCertFile(filename)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		roots = append(roots, certs...)
+	}
+	buf := &bytes.Buffer{}
+	opts := smolcert.GoSourceOptions{Package: *pkg, Func: *fn, Generator: "smolcert-embed"}
+	if err := smolcert.WriteGoSource(buf, opts, roots...); err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return smolcert.WriteFileAtomic(*output, buf.Bytes(), 0644)
+}
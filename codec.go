@@ -0,0 +1,353 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// The certificate types are encoded and decoded by the hand-written codec in this file instead of
+// the reflection based encoding of the CBOR package. Certificates are decoded on every
+// validation, so this keeps the hot paths free of reflection. The encoding is identical to the
+// canonical encoding of the CBOR package, decoding accepts the same input.
+
+const (
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+
+	cborFalse     = 0xf4
+	cborTrue      = 0xf5
+	cborNull      = 0xf6
+	cborUndefined = 0xf7
+	cborBreak     = 0xff
+
+	// maxNestingLevel limits the depth of nested arrays and maps in skipped items
+	maxNestingLevel = 32
+)
+
+var errUnexpectedEnd = errors.New("cbor: unexpected end of data")
+
+func appendHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(buf, major|25, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return append(buf, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return append(buf, major|27, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+			byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+func appendInt(buf []byte, n int64) []byte {
+	if n < 0 {
+		return appendHead(buf, majorNegint, uint64(-(n + 1)))
+	}
+	return appendHead(buf, majorUint, uint64(n))
+}
+
+// appendBytes encodes b as byte string, nil is encoded as null like by the CBOR package
+func appendBytes(buf, b []byte) []byte {
+	if b == nil {
+		return append(buf, cborNull)
+	}
+	return append(appendHead(buf, majorBytes, uint64(len(b))), b...)
+}
+
+func appendText(buf []byte, s string) []byte {
+	return append(appendHead(buf, majorText, uint64(len(s))), s...)
+}
+
+func appendBool(buf []byte, b bool) []byte {
+	if b {
+		return append(buf, cborTrue)
+	}
+	return append(buf, cborFalse)
+}
+
+// cborDecoder decodes single items from data
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads the initial byte and argument of an item. For indefinite lengths indefinite is true.
+func (d *cborDecoder) head() (major byte, arg uint64, indefinite bool, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, false, errUnexpectedEnd
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), false, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(d.data)-d.pos < size {
+			return 0, 0, false, errUnexpectedEnd
+		}
+		for _, b := range d.data[d.pos : d.pos+size] {
+			arg = arg<<8 | uint64(b)
+		}
+		d.pos += size
+		return major, arg, false, nil
+	case info == 31 && major >= majorBytes && major != majorTag:
+		return major, 0, true, nil
+	default:
+		return 0, 0, false, fmt.Errorf("cbor: invalid additional information %d for type %d", info, major)
+	}
+}
+
+// skipTags skips all tags in front of the next item, like the CBOR package does when decoding
+// into types without tag support
+func (d *cborDecoder) skipTags() error {
+	for d.pos < len(d.data) && d.data[d.pos]>>5 == majorTag {
+		if _, _, _, err := d.head(); err != nil {
+			return err
+		}
+	}
+	if d.pos >= len(d.data) {
+		return errUnexpectedEnd
+	}
+	return nil
+}
+
+// null consumes null or undefined, which decode to the zero value of every type
+func (d *cborDecoder) null() (bool, error) {
+	if err := d.skipTags(); err != nil {
+		return false, err
+	}
+	if d.data[d.pos] == cborNull || d.data[d.pos] == cborUndefined {
+		d.pos++
+		return true, nil
+	}
+	return false, nil
+}
+
+// breakCode consumes the break code terminating an indefinite length item
+func (d *cborDecoder) breakCode() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == cborBreak {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func typeError(major byte, expected string) error {
+	names := []string{"positive integer", "negative integer", "byte string", "UTF-8 text string",
+		"array", "map", "tag", "primitives"}
+	return fmt.Errorf("cbor: cannot unmarshal %s into %s", names[major], expected)
+}
+
+func (d *cborDecoder) uint() (uint64, error) {
+	if null, err := d.null(); null || err != nil {
+		return 0, err
+	}
+	major, arg, _, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if major != majorUint {
+		return 0, typeError(major, "uint64")
+	}
+	return arg, nil
+}
+
+func (d *cborDecoder) int() (int64, error) {
+	if null, err := d.null(); null || err != nil {
+		return 0, err
+	}
+	major, arg, _, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if major != majorUint && major != majorNegint {
+		return 0, typeError(major, "int64")
+	}
+	if arg > math.MaxInt64 {
+		return 0, fmt.Errorf("cbor: integer %d overflows int64", arg)
+	}
+	if major == majorNegint {
+		return -1 - int64(arg), nil
+	}
+	return int64(arg), nil
+}
+
+func (d *cborDecoder) bool() (bool, error) {
+	if null, err := d.null(); null || err != nil {
+		return false, err
+	}
+	switch d.data[d.pos] {
+	case cborFalse:
+		d.pos++
+		return false, nil
+	case cborTrue:
+		d.pos++
+		return true, nil
+	default:
+		return false, typeError(d.data[d.pos]>>5, "bool")
+	}
+}
+
+// string reads a byte or text string of the given major type, indefinite strings are joined
+func (d *cborDecoder) string(major byte) ([]byte, error) {
+	m, n, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if m != major {
+		return nil, typeError(m, typeName(major))
+	}
+	if !indefinite {
+		if uint64(len(d.data)-d.pos) < n {
+			return nil, errUnexpectedEnd
+		}
+		s := append([]byte{}, d.data[d.pos:d.pos+int(n)]...)
+		d.pos += int(n)
+		return s, nil
+	}
+	s := []byte{}
+	for !d.breakCode() {
+		chunk, err := d.string(major)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, chunk...)
+	}
+	return s, nil
+}
+
+func typeName(major byte) string {
+	if major == majorText {
+		return "string"
+	}
+	return "[]uint8"
+}
+
+func (d *cborDecoder) bytes() ([]byte, error) {
+	if null, err := d.null(); null || err != nil {
+		return nil, err
+	}
+	return d.string(majorBytes)
+}
+
+func (d *cborDecoder) text() (string, error) {
+	if null, err := d.null(); null || err != nil {
+		return "", err
+	}
+	s, err := d.string(majorText)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(s) {
+		return "", errors.New("cbor: invalid UTF-8 string")
+	}
+	return string(s), nil
+}
+
+// array reads the items of an array without decoding them. null is true for null or undefined.
+func (d *cborDecoder) array() (items []cbor.RawMessage, null bool, err error) {
+	if null, err := d.null(); null || err != nil {
+		return nil, null, err
+	}
+	major, n, indefinite, err := d.head()
+	if err != nil {
+		return nil, false, err
+	}
+	if major != majorArray {
+		return nil, false, typeError(major, "array")
+	}
+	items = []cbor.RawMessage{}
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite && d.breakCode() {
+			break
+		}
+		item, err := d.raw()
+		if err != nil {
+			return nil, false, err
+		}
+		items = append(items, item)
+	}
+	return items, false, nil
+}
+
+// raw returns the complete next item including its tags
+func (d *cborDecoder) raw() (cbor.RawMessage, error) {
+	start := d.pos
+	if err := d.skip(0); err != nil {
+		return nil, err
+	}
+	return cbor.RawMessage(d.data[start:d.pos:d.pos]), nil
+}
+
+func (d *cborDecoder) skip(level int) error {
+	if level > maxNestingLevel {
+		return fmt.Errorf("cbor: exceeded max nested level %d", maxNestingLevel)
+	}
+	major, arg, indefinite, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case majorBytes, majorText:
+		if indefinite {
+			for !d.breakCode() {
+				if d.pos < len(d.data) && d.data[d.pos]>>5 != major {
+					return errors.New("cbor: wrong type of indefinite length string chunk")
+				}
+				if err := d.skip(level + 1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if uint64(len(d.data)-d.pos) < arg {
+			return errUnexpectedEnd
+		}
+		d.pos += int(arg)
+	case majorArray, majorMap:
+		if major == majorMap && !indefinite {
+			if arg > math.MaxUint64/2 {
+				return errUnexpectedEnd
+			}
+			arg *= 2
+		}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.breakCode() {
+				return nil
+			}
+			if err := d.skip(level + 1); err != nil {
+				return err
+			}
+		}
+	case majorTag:
+		return d.skip(level + 1)
+	case majorSimple:
+		if indefinite {
+			return errors.New("cbor: unexpected break code")
+		}
+	}
+	return nil
+}
+
+// decodeItem decodes data with decode and makes sure that data is consumed completely
+func decodeItem(data []byte, decode func(d *cborDecoder) error) error {
+	d := &cborDecoder{data: data}
+	if err := decode(d); err != nil {
+		return err
+	}
+	if d.pos != len(data) {
+		return errors.New("cbor: unexpected data after item")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reflectionEncoding encodes cert with the reflection based encoder of the CBOR package
+func reflectionEncoding(t *testing.T, cert *Certificate) []byte {
+	type extension struct {
+		_        struct{} `cbor:",toarray"`
+		OID      uint64
+		Critical bool
+		Value    []byte
+	}
+	var extensions []extension
+	if cert.Extensions != nil {
+		extensions = []extension{}
+	}
+	for _, ext := range cert.Extensions {
+		extensions = append(extensions, extension{OID: ext.OID, Critical: ext.Critical, Value: ext.Value})
+	}
+	var validity interface{}
+	if cert.Validity != nil {
+		validity = []int64{int64(cert.Validity.NotBefore), int64(cert.Validity.NotAfter)}
+	}
+	buf, err := cborEm.Marshal([]interface{}{cert.SerialNumber, cert.Issuer, validity, cert.Subject,
+		[]byte(cert.PubKey), extensions, int64(cert.SignatureAlgorithm), cert.Signature})
+	require.NoError(t, err)
+	return buf
+}
+
+func TestCodecMatchesCBORPackage(t *testing.T) {
+	cert, _, err := SelfSignedCertificate("rööt", time.Unix(0, 0).Add(-time.Hour), time.Unix(1<<40, 0),
+		[]Extension{{OID: 1 << 33, Critical: false, Value: make([]byte, 300)}, {OID: 23, Critical: true, Value: []byte{}}})
+	require.NoError(t, err)
+	cert.SerialNumber = 1<<64 - 1
+	empty := &Certificate{}
+	empty.SignatureAlgorithm = AlgorithmHybrid
+	for _, c := range []*Certificate{cert, empty} {
+		buf, err := c.Bytes()
+		require.NoError(t, err)
+		assert.Equal(t, reflectionEncoding(t, c), buf)
+
+		parsed, err := ParseBuf(buf)
+		require.NoError(t, err)
+		var reflected struct {
+			_                  struct{} `cbor:",toarray"`
+			SerialNumber       uint64
+			Issuer             string
+			Validity           []int64
+			Subject            string
+			PubKey             []byte
+			Extensions         []cbor.RawMessage
+			SignatureAlgorithm int64
+			Signature          []byte
+		}
+		require.NoError(t, cbor.Unmarshal(buf, &reflected))
+		assert.Equal(t, reflected.SerialNumber, parsed.SerialNumber)
+		assert.Equal(t, reflected.Issuer, parsed.Issuer)
+		assert.Equal(t, reflected.PubKey, []byte(parsed.PubKey))
+		assert.Equal(t, len(reflected.Extensions), len(parsed.Extensions))
+		assert.Equal(t, reflected.SignatureAlgorithm, int64(parsed.SignatureAlgorithm))
+		assert.Equal(t, reflected.Signature, parsed.Signature)
+		assert.Equal(t, c.Validity, parsed.Validity)
+		assert.Equal(t, c.Extensions, parsed.Extensions)
+	}
+
+	// Validity and Extension can be used on their own
+	buf, err := cborEm.Marshal(cert.Extensions)
+	require.NoError(t, err)
+	var extensions []Extension
+	require.NoError(t, cbor.Unmarshal(buf, &extensions))
+	assert.Equal(t, cert.Extensions, extensions)
+	buf, err = cborEm.Marshal(cert.Validity)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x82, 0x39, 0x0e, 0x0f}, buf[:4])
+	validity := &Validity{}
+	require.NoError(t, cbor.Unmarshal(buf, validity))
+	assert.Equal(t, cert.Validity, validity)
+}
+
+func TestCodecDecoding(t *testing.T) {
+	// null and undefined decode to zero values, tags are ignored, indefinite lengths are supported
+	cert, err := ParseBuf([]byte{0x9f, 0xc1, 0x05, 0x7f, 0x61, 'a', 0x61, 'b', 0xff, 0xf7, 0x60, 0x5f, 0x41, 0x01, 0xff,
+		0x9f, 0x83, 0x01, 0xf5, 0xf6, 0xff, 0x27, 0xf6, 0xff, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), cert.SerialNumber)
+	assert.Equal(t, "ab", cert.Issuer)
+	assert.Nil(t, cert.Validity)
+	assert.Equal(t, []byte{0x01}, []byte(cert.PubKey))
+	assert.Equal(t, []Extension{{OID: 1, Critical: true}}, cert.Extensions)
+	assert.Equal(t, AlgorithmEd25519, cert.SignatureAlgorithm)
+	assert.Nil(t, cert.Signature)
+
+	valid := []byte{0x88, 0x00, 0x60, 0x82, 0x00, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40}
+	_, err = ParseBuf(valid)
+	require.NoError(t, err)
+	for i := 1; i < len(valid); i++ {
+		_, err = ParseBuf(valid[:i])
+		assert.Error(t, err, "truncated after %d bytes", i)
+	}
+	invalid := map[string][]byte{
+		"null":              {0xf6},
+		"negative serial":   {0x88, 0x20, 0x60, 0x82, 0x00, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40},
+		"invalid UTF-8":     {0x88, 0x00, 0x61, 0xff, 0x82, 0x00, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40},
+		"time overflow":     {0x88, 0x00, 0x60, 0x82, 0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40},
+		"float time":        {0x88, 0x00, 0x60, 0x82, 0xf9, 0x00, 0x00, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40},
+		"short validity":    {0x88, 0x00, 0x60, 0x81, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40},
+		"text public key":   {0x88, 0x00, 0x60, 0x82, 0x00, 0x00, 0x60, 0x60, 0x80, 0x27, 0x40},
+		"int critical flag": {0x88, 0x00, 0x60, 0x82, 0x00, 0x00, 0x60, 0x40, 0x81, 0x83, 0x01, 0x01, 0x40, 0x27, 0x40},
+		"reserved info":     {0x88, 0x1c, 0x60, 0x82, 0x00, 0x00, 0x60, 0x40, 0x80, 0x27, 0x40},
+		"unexpected break":  {0x88, 0x00, 0x60, 0x82, 0x00, 0x00, 0x60, 0x40, 0x80, 0x27, 0xff},
+	}
+	for name, buf := range invalid {
+		_, err := ParseBuf(buf)
+		assert.Error(t, err, name)
+	}
+
+	nested := []byte{0x89, 0x02}
+	nested = append(nested, valid[1:]...)
+	for i := 0; i < 40; i++ {
+		nested = append(nested, 0x81)
+	}
+	nested[0] = 0x8a
+	_, err = ParseBuf(append(nested, 0x00))
+	assert.Error(t, err)
+
+	var unknown Certificate
+	unknown.Version = Version2
+	unknown.UnknownFields = []cbor.RawMessage{{0x82, 0x01}}
+	_, err = unknown.Bytes()
+	assert.True(t, errors.Is(err, ErrMalformedCertificate))
+}
@@ -0,0 +1,164 @@
+package smolcert
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBOR major types
+const (
+	majorUint   = 0
+	majorNegint = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+)
+
+// CheckFormat checks that buf is a single certificate conforming to the schema in spec.cddl. In
+// contrast to ParseBuf, which tolerates some deviations, i.e. a missing validity, it reports every
+// violation of the schema, so other implementations can check their encoders against it. The
+// signature and the semantics of the fields are not checked. Violations are reported as
+// ErrMalformedCertificate.
+func CheckFormat(buf []byte) error {
+	var items []cbor.RawMessage
+	dec := cbor.NewDecoder(bytes.NewReader(buf))
+	if err := dec.Decode(&items); err != nil {
+		return formatError("certificate", "is no CBOR array: %s", err)
+	}
+	if dec.NumBytesRead() != len(buf) {
+		return formatError("certificate", "is followed by %d extraneous bytes", len(buf)-dec.NumBytesRead())
+	}
+	fields := items
+	switch {
+	case len(items) == 7:
+		// certificate_legacy, which lacks the signature_algorithm
+		fields = append(append([]cbor.RawMessage{}, items[:6]...), nil, items[6])
+	case len(items) == 8:
+		// certificate_v1
+	case len(items) >= 9:
+		if err := checkMajorType("version", items[0], majorUint); err != nil {
+			return err
+		}
+		var version uint64
+		if err := cbor.Unmarshal(items[0], &version); err != nil {
+			return formatError("version", "is invalid: %s", err)
+		}
+		if version < Version2 {
+			return formatError("version", "needs to be at least %d, not %d", Version2, version)
+		}
+		fields = items[1:9]
+	default:
+		return formatError("certificate", "has %d fields, expected 7, 8 or at least 9", len(items))
+	}
+
+	checks := []struct {
+		name  string
+		check func(name string, item cbor.RawMessage) error
+	}{
+		{"serial_number", majorTypeCheck(majorUint)},
+		{"issuer", checkText},
+		{"validity", checkValidityFormat},
+		{"subject", checkText},
+		{"public_key", majorTypeCheck(majorBytes)},
+		{"extensions", checkExtensionsFormat},
+		{"signature_algorithm", majorTypeCheck(majorUint, majorNegint)},
+		{"signature", majorTypeCheck(majorBytes)},
+	}
+	for i, c := range checks {
+		if fields[i] == nil {
+			continue
+		}
+		if err := c.check(c.name, fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkValidityFormat(name string, item cbor.RawMessage) error {
+	times, err := checkArray(name, item)
+	if err != nil {
+		return err
+	}
+	if len(times) != 2 {
+		return formatError(name, "has %d elements, expected 2", len(times))
+	}
+	if err := checkMajorType(name+".notBefore", times[0], majorUint, majorNegint); err != nil {
+		return err
+	}
+	return checkMajorType(name+".notAfter", times[1], majorUint, majorNegint)
+}
+
+func checkExtensionsFormat(name string, item cbor.RawMessage) error {
+	extensions, err := checkArray(name, item)
+	if err != nil {
+		return err
+	}
+	for i, ext := range extensions {
+		extName := fmt.Sprintf("%s[%d]", name, i)
+		fields, err := checkArray(extName, ext)
+		if err != nil {
+			return err
+		}
+		if len(fields) != 3 {
+			return formatError(extName, "has %d elements, expected 3", len(fields))
+		}
+		if err := checkMajorType(extName+".oid", fields[0], majorUint); err != nil {
+			return err
+		}
+		// false and true are the simple values 0xf4 and 0xf5
+		if len(fields[1]) != 1 || (fields[1][0] != 0xf4 && fields[1][0] != 0xf5) {
+			return formatError(extName+".critical", "is no bool")
+		}
+		if err := checkMajorType(extName+".value", fields[2], majorBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkArray(name string, item cbor.RawMessage) ([]cbor.RawMessage, error) {
+	if err := checkMajorType(name, item, majorArray); err != nil {
+		return nil, err
+	}
+	var items []cbor.RawMessage
+	if err := cbor.Unmarshal(item, &items); err != nil {
+		return nil, formatError(name, "is invalid: %s", err)
+	}
+	return items, nil
+}
+
+func checkText(name string, item cbor.RawMessage) error {
+	if err := checkMajorType(name, item, majorText); err != nil {
+		return err
+	}
+	// Decoding checks that the string is valid UTF-8
+	var s string
+	if err := cbor.Unmarshal(item, &s); err != nil {
+		return formatError(name, "is invalid: %s", err)
+	}
+	return nil
+}
+
+func majorTypeCheck(majorTypes ...byte) func(name string, item cbor.RawMessage) error {
+	return func(name string, item cbor.RawMessage) error {
+		return checkMajorType(name, item, majorTypes...)
+	}
+}
+
+func checkMajorType(name string, item cbor.RawMessage, majorTypes ...byte) error {
+	if len(item) > 0 {
+		for _, t := range majorTypes {
+			if item[0]>>5 == t {
+				return nil
+			}
+		}
+	}
+	return formatError(name, "has the wrong type")
+}
+
+func formatError(name, format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s %s", ErrMalformedCertificate, name, fmt.Sprintf(format, a...))
+}
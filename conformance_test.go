@@ -0,0 +1,168 @@
+package smolcert
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testVector is an entry of testdata/vectors.json
+type testVector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Encoded is the hex encoded certificate
+	Encoded string `json:"encoded"`
+	// ValidFormat is true if the certificate conforms to spec.cddl
+	ValidFormat bool `json:"valid_format"`
+	// IssuerPublicKey is the hex encoded key the signature is verified with
+	IssuerPublicKey string `json:"issuer_public_key,omitempty"`
+	// ValidSignature is true if the signature is valid for IssuerPublicKey
+	ValidSignature bool `json:"valid_signature"`
+	// Fields are the expected values of the decoded fields of certificates with a valid format
+	Fields *testVectorFields `json:"fields,omitempty"`
+}
+
+type testVectorFields struct {
+	// Version is the version of the format, 1 for certificates without explicit version
+	Version      uint64 `json:"version"`
+	SerialNumber uint64 `json:"serial_number"`
+	Issuer       string `json:"issuer"`
+	// NotBefore and NotAfter are the encoded values, milliseconds since epoch starting with version 3
+	NotBefore          int64                 `json:"not_before"`
+	NotAfter           int64                 `json:"not_after"`
+	Subject            string                `json:"subject"`
+	PublicKey          string                `json:"public_key"`
+	Extensions         []testVectorExtension `json:"extensions"`
+	SignatureAlgorithm int64                 `json:"signature_algorithm"`
+	Signature          string                `json:"signature"`
+}
+
+type testVectorExtension struct {
+	OID      uint64 `json:"oid"`
+	Critical bool   `json:"critical"`
+	Value    string `json:"value"`
+}
+
+func loadTestVectors(t *testing.T) []testVector {
+	buf, err := ioutil.ReadFile("testdata/vectors.json")
+	require.NoError(t, err)
+	var vectors []testVector
+	require.NoError(t, json.Unmarshal(buf, &vectors))
+	return vectors
+}
+
+func testVectorFieldsOf(cert *Certificate) *testVectorFields {
+	f := &testVectorFields{
+		Version:            cert.Version,
+		SerialNumber:       cert.SerialNumber,
+		Issuer:             cert.Issuer,
+		Subject:            cert.Subject,
+		PublicKey:          hex.EncodeToString(cert.PubKey),
+		Extensions:         []testVectorExtension{},
+		SignatureAlgorithm: int64(cert.SignatureAlgorithm),
+		Signature:          hex.EncodeToString(cert.Signature),
+	}
+	if f.Version == 0 {
+		f.Version = Version1
+	}
+	if cert.Validity != nil {
+		f.NotBefore, f.NotAfter = int64(cert.Validity.NotBefore), int64(cert.Validity.NotAfter)
+		if cert.Version >= Version3 {
+			m := cert.Validity.toMillis()
+			f.NotBefore, f.NotAfter = m.NotBefore, m.NotAfter
+		}
+	}
+	for _, ext := range cert.Extensions {
+		f.Extensions = append(f.Extensions, testVectorExtension{OID: ext.OID, Critical: ext.Critical, Value: hex.EncodeToString(ext.Value)})
+	}
+	return f
+}
+
+func TestTestVectors(t *testing.T) {
+	vectors := loadTestVectors(t)
+	require.NotEmpty(t, vectors)
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			buf, err := hex.DecodeString(vector.Encoded)
+			require.NoError(t, err)
+			err = CheckFormat(buf)
+			if !vector.ValidFormat {
+				assert.True(t, errors.Is(err, ErrMalformedCertificate))
+				return
+			}
+			require.NoError(t, err)
+
+			cert, err := ParseBuf(buf)
+			require.NoError(t, err)
+			assert.Equal(t, vector.Fields, testVectorFieldsOf(cert))
+			encoded, err := cert.Bytes()
+			require.NoError(t, err)
+			assert.Equal(t, buf, encoded)
+
+			issuerKey, err := hex.DecodeString(vector.IssuerPublicKey)
+			require.NoError(t, err)
+			tbs, err := cert.TBSBytes()
+			require.NoError(t, err)
+			sig := issuerSignature{pubKey: issuerKey, signature: cert.Signature, prehashed: cert.SignatureAlgorithm == AlgorithmEd25519ph}
+			assert.Equal(t, vector.ValidSignature, sig.verify(tbs))
+		})
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	cert, _, err := SelfSignedCertificate("root", time.Now(), time.Now().Add(time.Hour),
+		[]Extension{{OID: OIDSubjectAttributes, Value: []byte{0x80}}})
+	require.NoError(t, err)
+	for _, version := range []uint64{0, Version2, Version3, Version4} {
+		c := cert.Copy()
+		c.Version = version
+		buf, err := c.Bytes()
+		require.NoError(t, err)
+		assert.NoError(t, CheckFormat(buf), "version %d", version)
+	}
+	validBuf, err := cert.Bytes()
+	require.NoError(t, err)
+	withUnknownFields := cert.Copy()
+	withUnknownFields.Version = LatestVersion + 1
+	withUnknownFields.UnknownFields = []cbor.RawMessage{{0x01}}
+	buf, err := withUnknownFields.Bytes()
+	require.NoError(t, err)
+	assert.NoError(t, CheckFormat(buf))
+
+	noValidity := cert.Copy()
+	noValidity.Validity = nil
+	buf, err = noValidity.Bytes()
+	require.NoError(t, err)
+	_, err = ParseBuf(buf)
+	assert.NoError(t, err)
+	assert.True(t, errors.Is(CheckFormat(buf), ErrMalformedCertificate))
+
+	invalid := map[string]interface{}{
+		"no array":             "certificate",
+		"too few fields":       []interface{}{uint64(1), "issuer"},
+		"version one":          []interface{}{uint64(1), uint64(1), "a", []int64{0, 0}, "b", []byte{}, []interface{}{}, int64(-8), []byte{}},
+		"negative serial":      []interface{}{int64(-1), "a", []int64{0, 0}, "b", []byte{}, []interface{}{}, int64(-8), []byte{}},
+		"binary issuer":        []interface{}{uint64(1), []byte("a"), []int64{0, 0}, "b", []byte{}, []interface{}{}, int64(-8), []byte{}},
+		"short validity":       []interface{}{uint64(1), "a", []int64{0}, "b", []byte{}, []interface{}{}, int64(-8), []byte{}},
+		"text public key":      []interface{}{uint64(1), "a", []int64{0, 0}, "b", "key", []interface{}{}, int64(-8), []byte{}},
+		"invalid extension":    []interface{}{uint64(1), "a", []int64{0, 0}, "b", []byte{}, []interface{}{[]interface{}{uint64(1), uint64(1), []byte{}}}, int64(-8), []byte{}},
+		"text algorithm":       []interface{}{uint64(1), "a", []int64{0, 0}, "b", []byte{}, []interface{}{}, "EdDSA", []byte{}},
+		"missing signature":    []interface{}{uint64(2), uint64(1), "a", []int64{0, 0}, "b", []byte{}, []interface{}{}, int64(-8)},
+		"extension as map":     []interface{}{uint64(1), "a", []int64{0, 0}, "b", []byte{}, map[string]int{"a": 1}, int64(-8), []byte{}},
+		"validity with floats": []interface{}{uint64(1), "a", []float64{0, 0}, "b", []byte{}, []interface{}{}, int64(-8), []byte{}},
+	}
+	for name, val := range invalid {
+		buf, err := cbor.Marshal(val)
+		require.NoError(t, err)
+		assert.True(t, errors.Is(CheckFormat(buf), ErrMalformedCertificate), name)
+	}
+	assert.Error(t, CheckFormat([]byte{0x88}))
+	assert.Error(t, CheckFormat(append(validBuf, 0x00)))
+}
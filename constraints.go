@@ -0,0 +1,165 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// NameConstraints is the Value of a NameConstraints extension. It restricts the names of the
+// certificates an issuer may sign, which are the subject and all SubjectAltNames. A name is
+// permitted if it starts with one of the PermittedPrefixes or ends with one of the
+// PermittedSuffixes. DNS names and URIs are matched as they are, IP addresses in their textual
+// form and names of unknown types in the form of SubjectAltName.String.
+// Certificates which can sign certificates themselves need to carry NameConstraints which are at
+// least as restrictive as the NameConstraints of their issuer, so the constraints apply to the
+// whole subtree below an issuer.
+type NameConstraints struct {
+	_ struct{} `cbor:",toarray"`
+
+	PermittedPrefixes []string `cbor:"permitted_prefixes"`
+	PermittedSuffixes []string `cbor:"permitted_suffixes"`
+}
+
+// NameConstraintsExtension creates a critical NameConstraints Extension
+func NameConstraintsExtension(nc *NameConstraints) (Extension, error) {
+	val, err := cborEm.Marshal(nc)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDNameConstraints,
+		Critical: true,
+		Value:    val,
+	}, nil
+}
+
+// ParseNameConstraints parses NameConstraints from a byte slice, i.e. the Value of an Extension
+func ParseNameConstraints(in []byte) (*NameConstraints, error) {
+	nc := &NameConstraints{}
+	if err := cbor.Unmarshal(in, nc); err != nil {
+		return nil, fmt.Errorf("Failed to parse NameConstraints: %w", err)
+	}
+	return nc, nil
+}
+
+// Permits is true if name is permitted by the constraints
+func (nc *NameConstraints) Permits(name string) bool {
+	for _, prefix := range nc.PermittedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range nc.PermittedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers is true if every name permitted by other is also permitted by nc
+func (nc *NameConstraints) covers(other *NameConstraints) bool {
+	for _, prefix := range other.PermittedPrefixes {
+		if !nc.coversPrefix(prefix) {
+			return false
+		}
+	}
+	for _, suffix := range other.PermittedSuffixes {
+		if !nc.coversSuffix(suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (nc *NameConstraints) coversPrefix(prefix string) bool {
+	for _, p := range nc.PermittedPrefixes {
+		if strings.HasPrefix(prefix, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (nc *NameConstraints) coversSuffix(suffix string) bool {
+	for _, s := range nc.PermittedSuffixes {
+		if strings.HasSuffix(suffix, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func findNameConstraints(cert *Certificate) (*NameConstraints, error) {
+	var nc *NameConstraints
+	err := RequiresExtension(cert, OIDNameConstraints, func(critical bool, val []byte) (err error) {
+		nc, err = ParseNameConstraints(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return nc, err
+}
+
+// constrainedNames returns all names of cert which are subject to NameConstraints
+func constrainedNames(cert *Certificate) ([]string, error) {
+	sans, err := cert.SubjectAltNames()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{cert.Subject}
+	for _, san := range sans {
+		switch {
+		case san.Type == SANDNSName || san.Type == SANURI:
+			names = append(names, string(san.Value))
+		case san.Type == SANIPAddress && san.check() == nil:
+			names = append(names, net.IP(san.Value).String())
+		default:
+			names = append(names, san.String())
+		}
+	}
+	return names, nil
+}
+
+// checkNameConstraints verifies that cert doesn't violate the NameConstraints of issuer
+func checkNameConstraints(cert, issuer *Certificate) error {
+	if cert == issuer || (cert.Subject == issuer.Subject && bytes.Equal(cert.PubKey, issuer.PubKey)) {
+		// Constraints don't apply to self signed certificates
+		return nil
+	}
+	nc, err := findNameConstraints(issuer)
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, issuer, "Invalid NameConstraints: %s", err)
+	}
+	if nc == nil {
+		return nil
+	}
+	names, err := constrainedNames(cert)
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Invalid SubjectAltNames: %s", err)
+	}
+	for _, name := range names {
+		if !nc.Permits(name) {
+			return newValidationError(ErrNameConstraintViolation, cert, "Name '%s' is not permitted for issuer '%s'",
+				name, issuer.Subject)
+		}
+	}
+	if RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)) == nil {
+		childNC, err := findNameConstraints(cert)
+		if err != nil {
+			return newValidationError(ErrMalformedCertificate, cert, "Invalid NameConstraints: %s", err)
+		}
+		if childNC == nil || !nc.covers(childNC) {
+			return newValidationError(ErrNameConstraintViolation, cert,
+				"Certificate can issue certificates, but its NameConstraints are not within the constraints of issuer '%s'",
+				issuer.Subject)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package smolcert
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newConstrainedCA(t *testing.T, subject string, serial uint64, nc *NameConstraints, issuerKey ed25519.PrivateKey,
+	issuer string) (*Certificate, ed25519.PrivateKey) {
+	exts := []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}
+	if nc != nil {
+		ext, err := NameConstraintsExtension(nc)
+		require.NoError(t, err)
+		exts = append(exts, ext)
+	}
+	cert, key, err := SignedCertificate(subject, serial, time.Time{}, time.Time{}, exts, issuerKey, issuer)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestNameConstraints(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	siteA, siteAKey := newConstrainedCA(t, "site-a", 2, &NameConstraints{
+		PermittedPrefixes: []string{"site-a/"},
+		PermittedSuffixes: []string{".site-a.example.com"},
+	}, rootKey, "root")
+
+	for _, name := range []string{"site-a/device1", "gateway.site-a.example.com"} {
+		cert, _, err := ClientCertificate(name, 3, time.Time{}, time.Time{}, nil, siteAKey, "site-a")
+		require.NoError(t, err)
+		_, err = pool.ValidateBundle([]*Certificate{cert, siteA})
+		assert.NoError(t, err, name)
+		assert.True(t, pool.ValidateBundleReport([]*Certificate{cert, siteA}).Valid(), name)
+	}
+	for _, name := range []string{"site-b/device1", "gateway.site-b.example.com", "site-a.example.com"} {
+		cert, _, err := ClientCertificate(name, 3, time.Time{}, time.Time{}, nil, siteAKey, "site-a")
+		require.NoError(t, err)
+		_, err = pool.ValidateBundle([]*Certificate{cert, siteA})
+		assert.True(t, errors.Is(err, ErrNameConstraintViolation), name)
+		assert.False(t, pool.ValidateBundleReport([]*Certificate{cert, siteA}).Valid(), name)
+	}
+}
+
+func TestNameConstraintsApplyToSubjectAltNames(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	siteA, siteAKey := newConstrainedCA(t, "site-a", 2, &NameConstraints{
+		PermittedPrefixes: []string{"site-a/", "spiffe://site-a.example.com/", "10.1."},
+		PermittedSuffixes: []string{".site-a.example.com"},
+	}, rootKey, "root")
+
+	issue := func(names ...SubjectAltName) *Certificate {
+		// Encoded directly, so that names of unknown types can be included
+		val, err := cborEm.Marshal(names)
+		require.NoError(t, err)
+		ext := Extension{OID: OIDSubjectAltNames, Value: val}
+		cert, _, err := ClientCertificate("site-a/device", 3, time.Time{}, time.Time{}, []Extension{ext}, siteAKey, "site-a")
+		require.NoError(t, err)
+		return cert
+	}
+
+	permitted := issue(DNSName("gateway.site-a.example.com"), URIName("spiffe://site-a.example.com/device"),
+		IPAddress(net.ParseIP("10.1.0.7")))
+	_, err = pool.ValidateBundle([]*Certificate{permitted, siteA})
+	assert.NoError(t, err)
+
+	for _, name := range []SubjectAltName{
+		DNSName("gateway.site-b.example.com"),
+		URIName("spiffe://site-b.example.com/device"),
+		IPAddress(net.ParseIP("10.2.0.7")),
+		{Type: SubjectAltNameType(42), Value: []byte("site-a/device")},
+	} {
+		cert := issue(DNSName("gateway.site-a.example.com"), name)
+		_, err = pool.ValidateBundle([]*Certificate{cert, siteA})
+		assert.True(t, errors.Is(err, ErrNameConstraintViolation), name.String())
+		assert.False(t, pool.ValidateBundleReport([]*Certificate{cert, siteA}).Valid(), name.String())
+	}
+}
+
+func TestNameConstraintsApplyToSubtree(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	siteA, siteAKey := newConstrainedCA(t, "site-a", 2, &NameConstraints{PermittedPrefixes: []string{"site-a/"}}, rootKey, "root")
+
+	// Sub CAs need to be constrained at least as much as their issuer
+	unconstrained, unconstrainedKey := newConstrainedCA(t, "site-a/sub", 3, nil, siteAKey, "site-a")
+	widened, _ := newConstrainedCA(t, "site-a/sub", 4, &NameConstraints{PermittedPrefixes: []string{"site-"}}, siteAKey, "site-a")
+	narrowed, narrowedKey := newConstrainedCA(t, "site-a/sub", 5,
+		&NameConstraints{PermittedPrefixes: []string{"site-a/sub/"}}, siteAKey, "site-a")
+
+	leaf, _, err := ClientCertificate("site-b/device", 6, time.Time{}, time.Time{}, nil, unconstrainedKey, "site-a/sub")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{leaf, unconstrained, siteA})
+	assert.True(t, errors.Is(err, ErrNameConstraintViolation))
+	_, err = pool.ValidateBundle([]*Certificate{widened, siteA})
+	assert.True(t, errors.Is(err, ErrNameConstraintViolation))
+
+	leaf, _, err = ClientCertificate("site-a/sub/device", 7, time.Time{}, time.Time{}, nil, narrowedKey, "site-a/sub")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{leaf, narrowed, siteA})
+	assert.NoError(t, err)
+}
+
+func TestNameConstraintsOnRoot(t *testing.T) {
+	ext, err := NameConstraintsExtension(&NameConstraints{PermittedSuffixes: []string{".example.com"}})
+	require.NoError(t, err)
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, []Extension{ext})
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	cert, _, err := ServerCertificate("www.example.com", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(cert))
+	cert, _, err = ServerCertificate("www.example.org", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	assert.True(t, errors.Is(pool.Validate(cert), ErrNameConstraintViolation))
+}
@@ -0,0 +1,237 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// crlContext is prepended to signed CRLs, so that CRL signatures can't be mistaken for signatures
+// of other structures.
+var crlContext = []byte("smolcert revocation list")
+
+// RevocationReason describes why a certificate has been revoked
+type RevocationReason uint8
+
+// Defined RevocationReasons, matching the reason codes of X.509 CRLs
+const (
+	RevocationReasonUnspecified          RevocationReason = 0
+	RevocationReasonKeyCompromise        RevocationReason = 1
+	RevocationReasonCACompromise         RevocationReason = 2
+	RevocationReasonAffiliationChanged   RevocationReason = 3
+	RevocationReasonSuperseded           RevocationReason = 4
+	RevocationReasonCessationOfOperation RevocationReason = 5
+)
+
+// String returns a String representation for logging and debugging
+func (r RevocationReason) String() string {
+	switch r {
+	case RevocationReasonUnspecified:
+		return "Unspecified"
+	case RevocationReasonKeyCompromise:
+		return "KeyCompromise"
+	case RevocationReasonCACompromise:
+		return "CACompromise"
+	case RevocationReasonAffiliationChanged:
+		return "AffiliationChanged"
+	case RevocationReasonSuperseded:
+		return "Superseded"
+	case RevocationReasonCessationOfOperation:
+		return "CessationOfOperation"
+	default:
+		return fmt.Sprintf("RevocationReason(%d)", uint8(r))
+	}
+}
+
+// RevokedCertificate is an entry of a CRL
+type RevokedCertificate struct {
+	_ struct{} `cbor:",toarray"`
+
+	SerialNumber uint64           `cbor:"serial_number"`
+	RevokedAt    Time             `cbor:"revoked_at"`
+	Reason       RevocationReason `cbor:"reason"`
+}
+
+// CRL is a list of revoked certificates signed by their issuer. The CRL Number increases with
+// every CRL of an issuer.
+type CRL struct {
+	_ struct{} `cbor:",toarray"`
+
+	Issuer     string               `cbor:"issuer"`
+	Number     uint64               `cbor:"number"`
+	ThisUpdate Time                 `cbor:"this_update"`
+	NextUpdate Time                 `cbor:"next_update"`
+	Revoked    []RevokedCertificate `cbor:"revoked"`
+	Signature  []byte               `cbor:"signature"`
+}
+
+// NewCRL creates a CRL of issuer listing revoked, which is valid for validFor, and signs it with
+// issuerKey. The entries are sorted by serial number.
+func NewCRL(issuer *Certificate, number uint64, revoked []RevokedCertificate, validFor time.Duration,
+	issuerKey ed25519.PrivateKey) (*CRL, error) {
+	return newCRL(issuer, number, revoked, validFor, Ed25519Key(issuerKey))
+}
+
+func newCRL(issuer *Certificate, number uint64, revoked []RevokedCertificate, validFor time.Duration,
+	signer Signer) (*CRL, error) {
+	now := time.Now()
+	entries := append([]RevokedCertificate{}, revoked...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SerialNumber < entries[j].SerialNumber
+	})
+	crl := &CRL{
+		Issuer:     issuer.Subject,
+		Number:     number,
+		ThisUpdate: NewTime(now),
+		NextUpdate: NewTime(now.Add(validFor)),
+		Revoked:    entries,
+	}
+	tbs, err := crl.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if crl.Signature, err = signWith(signer, tbs); err != nil {
+		return nil, err
+	}
+	return crl, nil
+}
+
+// ParseCRL parses a CRL from an existing byte buffer
+func ParseCRL(buf []byte) (*CRL, error) {
+	crl := &CRL{}
+	if err := cbor.Unmarshal(buf, crl); err != nil {
+		return nil, err
+	}
+	return crl, nil
+}
+
+// Bytes returns the CBOR encoded form of the CRL
+func (crl *CRL) Bytes() ([]byte, error) {
+	return cborEm.Marshal(crl)
+}
+
+func (crl *CRL) tbsBytes() ([]byte, error) {
+	revoked := crl.Revoked
+	if revoked == nil {
+		revoked = []RevokedCertificate{}
+	}
+	buf, err := cborEm.Marshal(&CRL{
+		Issuer:     crl.Issuer,
+		Number:     crl.Number,
+		ThisUpdate: crl.ThisUpdate,
+		NextUpdate: crl.NextUpdate,
+		Revoked:    revoked,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, crlContext...), buf...), nil
+}
+
+// Verify checks that the CRL is current and signed by issuer
+func (crl *CRL) Verify(issuer *Certificate) error {
+	if crl.Issuer != issuer.Subject {
+		return fmt.Errorf("CRL has been issued by '%s', not by '%s'", crl.Issuer, issuer.Subject)
+	}
+	nowUnix := time.Now().Unix()
+	if int64(crl.ThisUpdate) > nowUnix {
+		return fmt.Errorf("%w: CRL produced in the future at %s", ErrStaleRevocationStatus,
+			crl.ThisUpdate.StdTime().Format(time.RFC3339))
+	}
+	if int64(crl.NextUpdate) < nowUnix {
+		return fmt.Errorf("%w: CRL expired at %s", ErrStaleRevocationStatus, crl.NextUpdate.StdTime().Format(time.RFC3339))
+	}
+	tbs, err := crl.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(issuer.PubKey, tbs, crl.Signature) {
+		return newValidationError(ErrBadSignature, issuer, "Signature validation of CRL failed")
+	}
+	return nil
+}
+
+// Lookup returns the entry of the certificate with the given serial number
+func (crl *CRL) Lookup(serialNumber uint64) (RevokedCertificate, bool) {
+	i := sort.Search(len(crl.Revoked), func(i int) bool {
+		return crl.Revoked[i].SerialNumber >= serialNumber
+	})
+	if i < len(crl.Revoked) && crl.Revoked[i].SerialNumber == serialNumber {
+		return crl.Revoked[i], true
+	}
+	return RevokedCertificate{}, false
+}
+
+// Check returns an error wrapping ErrRevoked if cert is listed in the CRL. The CRL needs to be
+// verified before.
+func (crl *CRL) Check(cert *Certificate) error {
+	if crl.Issuer != cert.Issuer {
+		return fmt.Errorf("CRL of '%s' doesn't cover certificates issued by '%s'", crl.Issuer, cert.Issuer)
+	}
+	if entry, revoked := crl.Lookup(cert.SerialNumber); revoked {
+		return newValidationError(ErrRevoked, cert, "certificate has been revoked at %s (%s)",
+			entry.RevokedAt.StdTime().Format(time.RFC3339), entry.Reason)
+	}
+	return nil
+}
+
+// CRLDistributionPointsExtension creates an Extension listing the URLs the CRL covering the
+// certificate can be fetched from, i.e. https or coap URLs.
+func CRLDistributionPointsExtension(urls ...string) (Extension, error) {
+	if len(urls) == 0 {
+		return Extension{}, errors.New("CRL distribution points can't be empty")
+	}
+	for _, u := range urls {
+		if err := checkDistributionPoint(u); err != nil {
+			return Extension{}, err
+		}
+	}
+	val, err := cborEm.Marshal(urls)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDCRLDistributionPoints,
+		Critical: false,
+		Value:    val,
+	}, nil
+}
+
+func checkDistributionPoint(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return fmt.Errorf("Invalid CRL distribution point: %w", err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("CRL distribution point '%s' is not an absolute URL", u)
+	}
+	return nil
+}
+
+// ParseCRLDistributionPoints parses the URLs of a CRLDistributionPoints extension
+func ParseCRLDistributionPoints(in []byte) ([]string, error) {
+	var urls []string
+	if err := cbor.Unmarshal(in, &urls); err != nil {
+		return nil, fmt.Errorf("Failed to parse CRL distribution points: %w", err)
+	}
+	return urls, nil
+}
+
+// CRLDistributionPoints returns the URLs the CRL covering the certificate can be fetched from. If
+// the certificate has no CRLDistributionPoints extension nil is returned.
+func (c *Certificate) CRLDistributionPoints() ([]string, error) {
+	var urls []string
+	err := RequiresExtension(c, OIDCRLDistributionPoints, func(critical bool, val []byte) (err error) {
+		urls, err = ParseCRLDistributionPoints(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return urls, err
+}
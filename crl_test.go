@@ -0,0 +1,76 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCRL(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	revokedCert, _, err := ClientCertificate("revoked", 7, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	validCert, _, err := ClientCertificate("valid", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	crl, err := NewCRL(rootCert, 1, []RevokedCertificate{
+		{SerialNumber: 9, RevokedAt: NewTime(time.Now()), Reason: RevocationReasonSuperseded},
+		{SerialNumber: 7, RevokedAt: NewTime(time.Now()), Reason: RevocationReasonKeyCompromise},
+	}, time.Hour, rootKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), crl.Revoked[0].SerialNumber)
+
+	buf, err := crl.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseCRL(buf)
+	require.NoError(t, err)
+	require.NoError(t, parsed.Verify(rootCert))
+
+	err = parsed.Check(revokedCert)
+	assert.True(t, errors.Is(err, ErrRevoked))
+	assert.Contains(t, err.Error(), "KeyCompromise")
+	assert.NoError(t, parsed.Check(validCert))
+	entry, found := parsed.Lookup(9)
+	assert.True(t, found)
+	assert.Equal(t, RevocationReasonSuperseded, entry.Reason)
+
+	otherCert, otherKey, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	assert.Error(t, parsed.Verify(otherCert))
+	foreignCert, _, err := ClientCertificate("revoked", 7, time.Time{}, time.Time{}, nil, otherKey, "other")
+	require.NoError(t, err)
+	assert.Error(t, parsed.Check(foreignCert))
+
+	parsed.Number = 2
+	assert.True(t, errors.Is(parsed.Verify(rootCert), ErrBadSignature))
+
+	expired, err := NewCRL(rootCert, 1, nil, -time.Minute, rootKey)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(expired.Verify(rootCert), ErrStaleRevocationStatus))
+}
+
+func TestCRLDistributionPoints(t *testing.T) {
+	ext, err := CRLDistributionPointsExtension("https://ca.example.com/crl", "coap://ca.example.com/crl")
+	require.NoError(t, err)
+	assert.False(t, ext.Critical)
+	cert, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, []Extension{ext})
+	require.NoError(t, err)
+	urls, err := cert.CRLDistributionPoints()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://ca.example.com/crl", "coap://ca.example.com/crl"}, urls)
+
+	plain, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	urls, err = plain.CRLDistributionPoints()
+	assert.NoError(t, err)
+	assert.Nil(t, urls)
+
+	_, err = CRLDistributionPointsExtension()
+	assert.Error(t, err)
+	_, err = CRLDistributionPointsExtension("/crl")
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// deltaCRLContext is prepended to signed delta CRLs, so that they can't be mistaken for full CRLs
+var deltaCRLContext = []byte("smolcert delta revocation list")
+
+// DeltaCRL contains the changes to the revocations of an issuer since the base CRL with the number
+// BaseNumber. Clients holding the base CRL only need to fetch the usually much smaller delta to
+// learn about new revocations. Deltas are cumulative, every delta contains all changes since its
+// base CRL.
+type DeltaCRL struct {
+	_ struct{} `cbor:",toarray"`
+
+	Issuer     string `cbor:"issuer"`
+	Number     uint64 `cbor:"number"`
+	BaseNumber uint64 `cbor:"base_number"`
+	ThisUpdate Time   `cbor:"this_update"`
+	NextUpdate Time   `cbor:"next_update"`
+	// Added are the certificates revoked since the base CRL
+	Added []RevokedCertificate `cbor:"added"`
+	// Removed are the serial numbers which are no longer listed since the base CRL, i.e. because
+	// the certificates have expired
+	Removed   []uint64 `cbor:"removed"`
+	Signature []byte   `cbor:"signature"`
+}
+
+// NewDeltaCRL creates a DeltaCRL of issuer relative to the CRL with baseNumber, which is valid for
+// validFor, and signs it with issuerKey
+func NewDeltaCRL(issuer *Certificate, number, baseNumber uint64, added []RevokedCertificate, removed []uint64,
+	validFor time.Duration, issuerKey ed25519.PrivateKey) (*DeltaCRL, error) {
+	return newDeltaCRL(issuer, number, baseNumber, added, removed, validFor, Ed25519Key(issuerKey))
+}
+
+func newDeltaCRL(issuer *Certificate, number, baseNumber uint64, added []RevokedCertificate, removed []uint64,
+	validFor time.Duration, signer Signer) (*DeltaCRL, error) {
+	if number <= baseNumber {
+		return nil, errors.New("Delta CRL needs a higher number than its base CRL")
+	}
+	now := time.Now()
+	entries := append([]RevokedCertificate{}, added...)
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SerialNumber < entries[j].SerialNumber
+	})
+	removedSerials := append([]uint64{}, removed...)
+	sort.Slice(removedSerials, func(i, j int) bool {
+		return removedSerials[i] < removedSerials[j]
+	})
+	delta := &DeltaCRL{
+		Issuer:     issuer.Subject,
+		Number:     number,
+		BaseNumber: baseNumber,
+		ThisUpdate: NewTime(now),
+		NextUpdate: NewTime(now.Add(validFor)),
+		Added:      entries,
+		Removed:    removedSerials,
+	}
+	tbs, err := delta.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if delta.Signature, err = signWith(signer, tbs); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// ParseDeltaCRL parses a DeltaCRL from an existing byte buffer
+func ParseDeltaCRL(buf []byte) (*DeltaCRL, error) {
+	delta := &DeltaCRL{}
+	if err := cbor.Unmarshal(buf, delta); err != nil {
+		return nil, err
+	}
+	return delta, nil
+}
+
+// Bytes returns the CBOR encoded form of the DeltaCRL
+func (d *DeltaCRL) Bytes() ([]byte, error) {
+	return cborEm.Marshal(d)
+}
+
+func (d *DeltaCRL) tbsBytes() ([]byte, error) {
+	added, removed := d.Added, d.Removed
+	if added == nil {
+		added = []RevokedCertificate{}
+	}
+	if removed == nil {
+		removed = []uint64{}
+	}
+	buf, err := cborEm.Marshal(&DeltaCRL{
+		Issuer:     d.Issuer,
+		Number:     d.Number,
+		BaseNumber: d.BaseNumber,
+		ThisUpdate: d.ThisUpdate,
+		NextUpdate: d.NextUpdate,
+		Added:      added,
+		Removed:    removed,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, deltaCRLContext...), buf...), nil
+}
+
+// Verify checks that the DeltaCRL is current and signed by issuer
+func (d *DeltaCRL) Verify(issuer *Certificate) error {
+	if d.Issuer != issuer.Subject {
+		return fmt.Errorf("Delta CRL has been issued by '%s', not by '%s'", d.Issuer, issuer.Subject)
+	}
+	nowUnix := time.Now().Unix()
+	if int64(d.ThisUpdate) > nowUnix {
+		return fmt.Errorf("%w: delta CRL produced in the future at %s", ErrStaleRevocationStatus,
+			d.ThisUpdate.StdTime().Format(time.RFC3339))
+	}
+	if int64(d.NextUpdate) < nowUnix {
+		return fmt.Errorf("%w: delta CRL expired at %s", ErrStaleRevocationStatus, d.NextUpdate.StdTime().Format(time.RFC3339))
+	}
+	tbs, err := d.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(issuer.PubKey, tbs, d.Signature) {
+		return newValidationError(ErrBadSignature, issuer, "Signature validation of delta CRL failed")
+	}
+	return nil
+}
+
+// Apply merges delta into the base CRL crl and returns the resulting CRL, which takes number and
+// validity from delta. The result is not signed, both CRLs need to be verified before. As deltas
+// are cumulative, the base CRL should be kept to apply later deltas.
+func (crl *CRL) Apply(delta *DeltaCRL) (*CRL, error) {
+	if delta.Issuer != crl.Issuer {
+		return nil, fmt.Errorf("Delta CRL of '%s' doesn't apply to the CRL of '%s'", delta.Issuer, crl.Issuer)
+	}
+	if delta.BaseNumber != crl.Number {
+		return nil, fmt.Errorf("Delta CRL is based on CRL %d, not on CRL %d", delta.BaseNumber, crl.Number)
+	}
+	entries := map[uint64]RevokedCertificate{}
+	for _, entry := range crl.Revoked {
+		entries[entry.SerialNumber] = entry
+	}
+	for _, serialNumber := range delta.Removed {
+		delete(entries, serialNumber)
+	}
+	for _, entry := range delta.Added {
+		entries[entry.SerialNumber] = entry
+	}
+	merged := &CRL{
+		Issuer:     crl.Issuer,
+		Number:     delta.Number,
+		ThisUpdate: delta.ThisUpdate,
+		NextUpdate: delta.NextUpdate,
+		Revoked:    make([]RevokedCertificate, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		merged.Revoked = append(merged.Revoked, entry)
+	}
+	sort.Slice(merged.Revoked, func(i, j int) bool {
+		return merged.Revoked[i].SerialNumber < merged.Revoked[j].SerialNumber
+	})
+	return merged, nil
+}
@@ -0,0 +1,56 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltaCRL(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	now := NewTime(time.Now())
+	base, err := NewCRL(rootCert, 1, []RevokedCertificate{
+		{SerialNumber: 1, RevokedAt: now},
+		{SerialNumber: 2, RevokedAt: now},
+	}, time.Hour, rootKey)
+	require.NoError(t, err)
+
+	delta, err := NewDeltaCRL(rootCert, 2, 1, []RevokedCertificate{
+		{SerialNumber: 3, RevokedAt: now, Reason: RevocationReasonKeyCompromise},
+	}, []uint64{1}, time.Hour, rootKey)
+	require.NoError(t, err)
+	buf, err := delta.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseDeltaCRL(buf)
+	require.NoError(t, err)
+	require.NoError(t, parsed.Verify(rootCert))
+
+	merged, err := base.Apply(parsed)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), merged.Number)
+	serials := []uint64{}
+	for _, entry := range merged.Revoked {
+		serials = append(serials, entry.SerialNumber)
+	}
+	assert.Equal(t, []uint64{2, 3}, serials)
+	assert.Len(t, base.Revoked, 2)
+
+	wrongBase, err := NewDeltaCRL(rootCert, 5, 4, nil, nil, time.Hour, rootKey)
+	require.NoError(t, err)
+	_, err = base.Apply(wrongBase)
+	assert.Error(t, err)
+	_, err = NewDeltaCRL(rootCert, 1, 1, nil, nil, time.Hour, rootKey)
+	assert.Error(t, err)
+
+	parsed.Removed = nil
+	assert.True(t, errors.Is(parsed.Verify(rootCert), ErrBadSignature))
+
+	// Delta CRLs can't be mistaken for full CRLs
+	full := &CRL{Issuer: delta.Issuer, Number: delta.Number, ThisUpdate: delta.ThisUpdate,
+		NextUpdate: delta.NextUpdate, Revoked: delta.Added, Signature: delta.Signature}
+	assert.Error(t, full.Verify(rootCert))
+}
@@ -0,0 +1,136 @@
+package smolcert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CRLTransport fetches the encoded CRL published at a URL
+type CRLTransport interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+type cachedCRL struct {
+	crl       *CRL
+	fetchedAt time.Time
+}
+
+// CRLFetcher fetches CRLs from the distribution points of certificates and caches them until
+// their NextUpdate.
+type CRLFetcher struct {
+	// Transports fetch CRLs by URL scheme. By default http and https URLs are supported. Other
+	// transports (i.e. the CoAP transport of package enroll/coap) can be added before the
+	// CRLFetcher is used.
+	Transports map[string]CRLTransport
+	// MaxAge optionally limits the time a CRL is cached, even if it would still be current
+	MaxAge time.Duration
+	// RequireDistributionPoint rejects certificates without CRLDistributionPoints extension
+	RequireDistributionPoint bool
+	// OnUpdate is optionally called when a CRL replaces a cached CRL with another Number, i.e. to
+	// invalidate a ValidationCache
+	OnUpdate func(crl *CRL)
+
+	lock  sync.Mutex
+	cache map[string]cachedCRL
+}
+
+// NewCRLFetcher creates a CRLFetcher supporting http and https distribution points. TinyGo builds
+// have no default transports.
+func NewCRLFetcher() *CRLFetcher {
+	return &CRLFetcher{
+		Transports: defaultCRLTransports(),
+		cache:      map[string]cachedCRL{},
+	}
+}
+
+// CRL returns the current CRL of issuer published at u. Cached CRLs are used while they are
+// current, otherwise the CRL is fetched and verified.
+func (f *CRLFetcher) CRL(ctx context.Context, u string, issuer *Certificate) (*CRL, error) {
+	key := u + "#" + PinFromCertificate(issuer).String()
+	f.lock.Lock()
+	cached, found := f.cache[key]
+	f.lock.Unlock()
+	if found && (f.MaxAge <= 0 || time.Since(cached.fetchedAt) < f.MaxAge) {
+		if err := cached.crl.Verify(issuer); err == nil {
+			return cached.crl, nil
+		}
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CRL distribution point: %w", err)
+	}
+	transport, found := f.Transports[strings.ToLower(parsed.Scheme)]
+	if !found {
+		return nil, fmt.Errorf("No transport for CRL distribution point '%s'", u)
+	}
+	buf, err := transport.Fetch(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	crl, err := ParseCRL(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CRL at %s: %w", u, err)
+	}
+	if err := crl.Verify(issuer); err != nil {
+		return nil, err
+	}
+
+	f.lock.Lock()
+	if f.cache == nil {
+		f.cache = map[string]cachedCRL{}
+	}
+	previous, updated := f.cache[key]
+	f.cache[key] = cachedCRL{crl: crl, fetchedAt: time.Now()}
+	f.lock.Unlock()
+	if updated && previous.crl.Number != crl.Number && f.OnUpdate != nil {
+		f.OnUpdate(crl)
+	}
+	return crl, nil
+}
+
+// Check checks that cert has not been revoked by issuer, according to the CRL of the first
+// distribution point of cert which can be fetched. Certificates without distribution points are
+// accepted unless RequireDistributionPoint is set.
+func (f *CRLFetcher) Check(ctx context.Context, cert, issuer *Certificate) error {
+	urls, err := cert.CRLDistributionPoints()
+	if err != nil {
+		return err
+	}
+	if len(urls) == 0 {
+		if f.RequireDistributionPoint {
+			return errors.New("Certificate doesn't contain CRL distribution points")
+		}
+		return nil
+	}
+	var lastErr error
+	for _, u := range urls {
+		crl, err := f.CRL(ctx, u, issuer)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return crl.Check(cert)
+	}
+	return fmt.Errorf("Revocation status of certificate is unknown: %w", lastErr)
+}
+
+// RevocationHook returns a ContextValidationHook checking every certificate of a chain, except
+// for self signed roots, against the CRLs of its distribution points
+func RevocationHook(f *CRLFetcher) ContextValidationHook {
+	return func(ctx context.Context, cert, issuer *Certificate) error {
+		if cert == issuer || (cert.Subject == issuer.Subject && bytes.Equal(cert.PubKey, issuer.PubKey)) {
+			return nil
+		}
+		return f.Check(ctx, cert, issuer)
+	}
+}
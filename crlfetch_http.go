@@ -0,0 +1,57 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxCRLSize limits the size of fetched CRLs
+const maxCRLSize = 4 * 1024 * 1024
+
+// HTTPCRLTransport fetches CRLs via HTTP GET requests
+type HTTPCRLTransport struct {
+	// Client is used to send requests, http.DefaultClient is used by default
+	Client *http.Client
+}
+
+// Fetch implements CRLTransport
+func (t *HTTPCRLTransport) Fetch(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching CRL from %s failed with %s", u, resp.Status)
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCRLSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) > maxCRLSize {
+		return nil, fmt.Errorf("CRL at %s is too large", u)
+	}
+	return buf, nil
+}
+
+func defaultCRLTransports() map[string]CRLTransport {
+	httpTransport := &HTTPCRLTransport{}
+	return map[string]CRLTransport{
+		"http":  httpTransport,
+		"https": httpTransport,
+	}
+}
@@ -0,0 +1,78 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCRLFetcher(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+
+	var fetches int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		crl, err := ca.GenerateCRL()
+		require.NoError(t, err)
+		buf, err := crl.Bytes()
+		require.NoError(t, err)
+		w.Write(buf)
+	}))
+	defer srv.Close()
+
+	dp, err := CRLDistributionPointsExtension("unknown://ca.example.com/crl", srv.URL+"/crl")
+	require.NoError(t, err)
+	ca.Options = []IssueOption{WithExtensions(dp)}
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	other, err := ca.Issue("other", pubKey)
+	require.NoError(t, err)
+	ca.Options = nil
+	undistributed, err := ca.Issue("undistributed", pubKey)
+	require.NoError(t, err)
+
+	fetcher := NewCRLFetcher()
+	var updates int32
+	fetcher.OnUpdate = func(crl *CRL) { atomic.AddInt32(&updates, 1) }
+	v := NewValidator(NewCertPool(rootCert))
+	v.AddContextHook(RevocationHook(fetcher))
+	require.NoError(t, v.Validate(cert))
+	require.NoError(t, v.Validate(other))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	require.NoError(t, ca.Revoke(cert.SerialNumber, RevocationReasonKeyCompromise))
+	// The cached CRL is still current
+	require.NoError(t, v.Validate(cert))
+	fetcher.MaxAge = time.Nanosecond
+	assert.True(t, errors.Is(v.Validate(cert), ErrRevoked))
+	assert.NoError(t, v.Validate(other))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&fetches))
+	// Every CRL generated by the CA has a new number
+	assert.Equal(t, int32(2), atomic.LoadInt32(&updates))
+
+	assert.NoError(t, v.Validate(undistributed))
+	fetcher.RequireDistributionPoint = true
+	assert.True(t, errors.Is(v.Validate(undistributed), ErrRejectedByHook))
+
+	// CRLs signed by another key are not accepted
+	otherRoot, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, err = fetcher.CRL(context.Background(), srv.URL+"/crl", otherRoot)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+}
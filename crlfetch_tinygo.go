@@ -0,0 +1,8 @@
+//go:build tinygo
+// +build tinygo
+
+package smolcert
+
+func defaultCRLTransports() map[string]CRLTransport {
+	return map[string]CRLTransport{}
+}
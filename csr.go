@@ -0,0 +1,79 @@
+package smolcert
+
+import (
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// csrContext is prepended to signed certificate requests, so that the signature proving the
+// possession of a key can't be mistaken for a signature of other structures.
+var csrContext = []byte("smolcert certificate request")
+
+// CertificateRequest is a request to issue a certificate for a subject and public key. It is
+// signed with the private key belonging to the requested public key to prove its possession.
+type CertificateRequest struct {
+	_ struct{} `cbor:",toarray"`
+
+	Subject    string            `cbor:"subject"`
+	PubKey     ed25519.PublicKey `cbor:"public_key"`
+	Extensions []Extension       `cbor:"extensions"`
+	Signature  []byte            `cbor:"signature"`
+}
+
+// NewCertificateRequest creates a CertificateRequest for the public key of priv and signs it
+func NewCertificateRequest(subject string, extensions []Extension, priv ed25519.PrivateKey) (*CertificateRequest, error) {
+	if extensions == nil {
+		extensions = []Extension{}
+	}
+	req := &CertificateRequest{
+		Subject:    subject,
+		PubKey:     priv.Public().(ed25519.PublicKey),
+		Extensions: extensions,
+	}
+	tbs, err := req.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	req.Signature = ed25519.Sign(priv, tbs)
+	return req, nil
+}
+
+// ParseCertificateRequest parses a CertificateRequest from an existing byte buffer
+func ParseCertificateRequest(buf []byte) (*CertificateRequest, error) {
+	req := &CertificateRequest{}
+	if err := cbor.Unmarshal(buf, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Bytes returns the CBOR encoded form of the CertificateRequest
+func (r *CertificateRequest) Bytes() ([]byte, error) {
+	return cborEm.Marshal(r)
+}
+
+func (r *CertificateRequest) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&CertificateRequest{
+		Subject:    r.Subject,
+		PubKey:     r.PubKey,
+		Extensions: r.Extensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, csrContext...), buf...), nil
+}
+
+// Verify checks that the CertificateRequest is signed with the key of the requested public key
+func (r *CertificateRequest) Verify() error {
+	tbs, err := r.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(r.PubKey, tbs, r.Signature) {
+		return errors.New("Signature of certificate request is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCertificateRequest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	req, err := NewCertificateRequest("device", nil, priv)
+	require.NoError(t, err)
+	assert.Equal(t, pub, req.PubKey)
+
+	buf, err := req.Bytes()
+	require.NoError(t, err)
+	req, err = ParseCertificateRequest(buf)
+	require.NoError(t, err)
+	assert.NoError(t, req.Verify())
+	assert.Equal(t, "device", req.Subject)
+
+	// Signatures are bound to the certificate request context
+	tbs, err := req.tbsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, csrContext, tbs[:len(csrContext)])
+	bare := *req
+	bare.Signature = ed25519.Sign(priv, tbs[len(csrContext):])
+	assert.Error(t, bare.Verify())
+
+	req.Subject = "other device"
+	assert.Error(t, req.Verify())
+}
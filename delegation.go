@@ -0,0 +1,139 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Delegation is the Value of a Delegation extension. It allows the holder of a certificate to issue
+// short lived delegated credentials for its own workloads, so that the long term key can be kept
+// offline.
+type Delegation struct {
+	_ struct{} `cbor:",toarray"`
+
+	// MaxValidity is the maximum validity of delegated credentials in seconds
+	MaxValidity uint64 `cbor:"max_validity"`
+}
+
+// DelegationExtension creates an Extension allowing the subject to issue delegated credentials
+// which are valid for at most maxValidity.
+func DelegationExtension(maxValidity time.Duration) (Extension, error) {
+	val, err := cborEm.Marshal(&Delegation{MaxValidity: uint64(maxValidity / time.Second)})
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDDelegation,
+		Critical: false,
+		Value:    val,
+	}, nil
+}
+
+// ParseDelegation parses a Delegation from a byte slice, i.e. the Value of an Extension
+func ParseDelegation(in []byte) (*Delegation, error) {
+	d := &Delegation{}
+	if err := cbor.Unmarshal(in, d); err != nil {
+		return nil, fmt.Errorf("Failed to parse Delegation: %w", err)
+	}
+	return d, nil
+}
+
+// MaxValidityDuration returns the maximum validity of delegated credentials as time.Duration
+func (d *Delegation) MaxValidityDuration() time.Duration {
+	return time.Duration(d.MaxValidity) * time.Second
+}
+
+func findDelegation(cert *Certificate) (*Delegation, error) {
+	var d *Delegation
+	err := RequiresExtension(cert, OIDDelegation, func(critical bool, val []byte) (err error) {
+		d, err = ParseDelegation(val)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DelegatedCredential issues a short lived credential for subject, signed by the holder of a
+// certificate with a Delegation extension. The credential inherits the KeyUsage of the holder and
+// is valid from now for validFor, limited by the Delegation and the validity of the holder. Every
+// credential gets a random serial number, so it can be revoked independently of the holder.
+func DelegatedCredential(holder *Certificate, holderKey ed25519.PrivateKey, subject string,
+	validFor time.Duration) (*Certificate, ed25519.PrivateKey, error) {
+	d, err := findDelegation(holder)
+	if err != nil {
+		return nil, ed25519.PrivateKey{}, fmt.Errorf("Holder certificate is not allowed to delegate credentials: %w", err)
+	}
+	if validFor <= 0 || validFor > d.MaxValidityDuration() {
+		return nil, ed25519.PrivateKey{}, fmt.Errorf("Requested validity of %s exceeds the allowed delegation validity of %s",
+			validFor, d.MaxValidityDuration())
+	}
+
+	notBefore := time.Now()
+	notAfter := notBefore.Add(validFor)
+	if holder.Validity != nil && !holder.Validity.NotAfter.IsZero() && notAfter.After(holder.Validity.NotAfter.StdTime()) {
+		notAfter = holder.Validity.NotAfter.StdTime()
+	}
+
+	var extensions []Extension
+	for _, ext := range holder.Extensions {
+		if ext.OID == OIDKeyUsage {
+			extensions = append(extensions, ext)
+		}
+	}
+	serialNumber, err := RandomSerialNumber()
+	if err != nil {
+		return nil, ed25519.PrivateKey{}, err
+	}
+	return SignedCertificate(subject, serialNumber, notBefore, notAfter, extensions, holderKey, holder.Subject)
+}
+
+// ValidateDelegatedCredential validates a delegated credential issued by holder. The holder
+// certificate is validated against the CertPool and needs to allow delegation. The credential
+// needs to have a bounded validity within the limits of the Delegation and the same KeyUsage
+// as the holder.
+func (c *CertPool) ValidateDelegatedCredential(credential, holder *Certificate) error {
+	if err := c.Validate(holder); err != nil {
+		return fmt.Errorf("Error validating holder of delegated credential: %w", err)
+	}
+	d, err := findDelegation(holder)
+	if err != nil {
+		return fmt.Errorf("Holder certificate is not allowed to delegate credentials: %w", err)
+	}
+	if credential.Issuer != holder.Subject {
+		return errors.New("Delegated credential is not issued by the holder certificate")
+	}
+	if credential.Validity == nil || credential.Validity.NotBefore.IsZero() || credential.Validity.NotAfter.IsZero() {
+		return errors.New("Delegated credentials need to have a bounded validity")
+	}
+	if validFor := credential.Validity.Duration(); validFor > d.MaxValidityDuration() {
+		return fmt.Errorf("Validity of delegated credential (%s) exceeds the allowed delegation validity of %s",
+			validFor, d.MaxValidityDuration())
+	}
+	if holder.Validity != nil && !holder.Validity.NotAfter.IsZero() &&
+		credential.Validity.NotAfterTime().After(holder.Validity.NotAfterTime()) {
+		return errors.New("Delegated credential is valid longer than its holder certificate")
+	}
+	if err := validateCertificate(credential, holder.PubKey); err != nil {
+		return err
+	}
+	err = RequiresExtension(credential, OIDKeyUsage, func(critical bool, val []byte) error {
+		usage, err := ParseKeyUsage(val)
+		if err != nil {
+			return err
+		}
+		if err := RequiresExtension(holder, OIDKeyUsage, ExpectKeyUsage(usage)); err != nil {
+			return fmt.Errorf("Delegated credential has a different KeyUsage than its holder: %w", err)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return fmt.Errorf("Delegated credential needs the KeyUsage of its holder: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelegatedCredential(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	delegation, err := DelegationExtension(time.Hour)
+	require.NoError(t, err)
+	holder, holderKey, err := ServerCertificate("server", 2, now.Add(-time.Minute), now.Add(time.Hour*24*365),
+		[]Extension{delegation}, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	credential, _, err := DelegatedCredential(holder, holderKey, "server workload", time.Minute*10)
+	require.NoError(t, err)
+	assert.NoError(t, pool.ValidateDelegatedCredential(credential, holder))
+	assert.NoError(t, RequiresExtension(credential, OIDKeyUsage, ExpectKeyUsage(KeyUsageServerIdentification)))
+
+	// Every credential has its own serial number, distinct from the holder
+	other, _, err := DelegatedCredential(holder, holderKey, "server workload", time.Minute*10)
+	require.NoError(t, err)
+	assert.NotEqual(t, holder.SerialNumber, credential.SerialNumber)
+	assert.NotEqual(t, credential.SerialNumber, other.SerialNumber)
+	assert.NotEqual(t, credential.ID(), other.ID())
+
+	// Delegated credentials are not accepted as normal certificates
+	assert.Error(t, pool.Validate(credential))
+	_, err = pool.ValidateBundle([]*Certificate{holder, credential})
+	assert.Error(t, err)
+
+	_, _, err = DelegatedCredential(holder, holderKey, "server workload", time.Hour*2)
+	assert.Error(t, err)
+
+	// Credentials exceeding the delegation are rejected
+	tooLong, _, err := SignedCertificate("server workload", 2, now.Add(-time.Minute), now.Add(time.Hour*2),
+		credential.Extensions, holderKey, holder.Subject)
+	require.NoError(t, err)
+	assert.Error(t, pool.ValidateDelegatedCredential(tooLong, holder))
+
+	unbounded, _, err := SignedCertificate("server workload", 2, time.Time{}, time.Time{},
+		credential.Extensions, holderKey, holder.Subject)
+	require.NoError(t, err)
+	assert.Error(t, pool.ValidateDelegatedCredential(unbounded, holder))
+
+	otherUsage, _, err := ClientCertificate("server workload", 2, now.Add(-time.Minute), now.Add(time.Minute),
+		nil, holderKey, holder.Subject)
+	require.NoError(t, err)
+	assert.Error(t, pool.ValidateDelegatedCredential(otherUsage, holder))
+
+	// The KeyUsage can't be left out
+	var withoutUsage []Extension
+	for _, ext := range credential.Extensions {
+		if ext.OID != OIDKeyUsage {
+			withoutUsage = append(withoutUsage, ext)
+		}
+	}
+	noUsage, _, err := SignedCertificate("server workload", 3, now.Add(-time.Minute), now.Add(time.Minute),
+		withoutUsage, holderKey, holder.Subject)
+	require.NoError(t, err)
+	assert.Error(t, pool.ValidateDelegatedCredential(noUsage, holder))
+}
+
+func TestDelegationRequiresExtension(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	holder, holderKey, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	_, _, err = DelegatedCredential(holder, holderKey, "workload", time.Minute)
+	assert.Error(t, err)
+
+	credential, _, err := ClientCertificate("workload", 2, time.Now().Add(-time.Minute), time.Now().Add(time.Minute),
+		nil, holderKey, holder.Subject)
+	require.NoError(t, err)
+	assert.Error(t, NewCertPool(rootCert).ValidateDelegatedCredential(credential, holder))
+}
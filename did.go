@@ -0,0 +1,127 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// didKeyPrefix is the prefix of did:key identifiers
+const didKeyPrefix = "did:key:"
+
+// ed25519Multicodec is the multicodec varint prefix of ed25519 public keys
+var ed25519Multicodec = []byte{0xed, 0x01}
+
+// DIDDocument is a minimal DID document for a did:key identifier
+type DIDDocument struct {
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod"`
+	Authentication     []string             `json:"authentication"`
+	AssertionMethod    []string             `json:"assertionMethod"`
+}
+
+// VerificationMethod describes a public key in a DIDDocument
+type VerificationMethod struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+}
+
+// DIDKey returns the public key of the certificate as did:key identifier
+func (c *Certificate) DIDKey() string {
+	return DIDKeyFromPublicKey(c.PubKey)
+}
+
+// DIDDocument returns a minimal DID document for the public key of the certificate
+func (c *Certificate) DIDDocument() *DIDDocument {
+	did := c.DIDKey()
+	fingerprint := strings.TrimPrefix(did, didKeyPrefix)
+	methodID := did + "#" + fingerprint
+	return &DIDDocument{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/ed25519-2020/v1",
+		},
+		ID: did,
+		VerificationMethod: []VerificationMethod{
+			{
+				ID:                 methodID,
+				Type:               "Ed25519VerificationKey2020",
+				Controller:         did,
+				PublicKeyMultibase: fingerprint,
+			},
+		},
+		Authentication:  []string{methodID},
+		AssertionMethod: []string{methodID},
+	}
+}
+
+// DIDKeyFromPublicKey encodes an ed25519 public key as did:key identifier
+func DIDKeyFromPublicKey(pubKey ed25519.PublicKey) string {
+	buf := append(append([]byte{}, ed25519Multicodec...), pubKey...)
+	// z is the multibase prefix of base58btc
+	return didKeyPrefix + "z" + encodeBase58(buf)
+}
+
+// ParseDIDKey returns the ed25519 public key of a did:key identifier
+func ParseDIDKey(did string) (ed25519.PublicKey, error) {
+	if !strings.HasPrefix(did, didKeyPrefix+"z") {
+		return nil, errors.New("Only base58btc encoded did:key identifiers are supported")
+	}
+	buf, err := decodeBase58(strings.TrimPrefix(did, didKeyPrefix+"z"))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != len(ed25519Multicodec)+ed25519.PublicKeySize ||
+		buf[0] != ed25519Multicodec[0] || buf[1] != ed25519Multicodec[1] {
+		return nil, fmt.Errorf("did:key '%s' is not an ed25519 key", did)
+	}
+	return ed25519.PublicKey(buf[len(ed25519Multicodec):]), nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+var base58Radix = big.NewInt(58)
+
+func encodeBase58(buf []byte) string {
+	var out []byte
+	n := new(big.Int).SetBytes(buf)
+	mod := new(big.Int)
+	for n.Sign() > 0 {
+		n.DivMod(n, base58Radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	// Leading zero bytes are encoded as leading '1'
+	for _, b := range buf {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	zeros := 0
+	for i, c := range s {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			return nil, fmt.Errorf("Invalid base58 character '%c'", c)
+		}
+		if idx == 0 && zeros == i {
+			zeros++
+		}
+		n.Mul(n, base58Radix)
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
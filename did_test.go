@@ -0,0 +1,59 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDIDKey(t *testing.T) {
+	cert, _, err := SelfSignedCertificate("device", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	did := cert.DIDKey()
+	// All ed25519 did:key identifiers start with z6Mk due to the multicodec prefix
+	assert.Regexp(t, "^did:key:z6Mk", did)
+	pub, err := ParseDIDKey(did)
+	require.NoError(t, err)
+	assert.Equal(t, cert.PubKey, pub)
+
+	doc := cert.DIDDocument()
+	assert.Equal(t, did, doc.ID)
+	require.Len(t, doc.VerificationMethod, 1)
+	assert.Equal(t, did, doc.VerificationMethod[0].Controller)
+	assert.Equal(t, did[len("did:key:"):], doc.VerificationMethod[0].PublicKeyMultibase)
+	assert.Equal(t, []string{doc.VerificationMethod[0].ID}, doc.Authentication)
+}
+
+func TestParseInvalidDIDKey(t *testing.T) {
+	for _, did := range []string{
+		"",
+		"did:web:example.com",
+		"did:key:mAAAA",
+		"did:key:z0OIl",
+		// secp256k1 key
+		"did:key:zQ3shokFTS3brHcDQrn82RUDfCZESWL1ZdCEJwekUDPQiYBme",
+	} {
+		_, err := ParseDIDKey(did)
+		assert.Error(t, err, did)
+	}
+}
+
+func TestBase58(t *testing.T) {
+	assert.Equal(t, "StV1DL6CwTryKyV", encodeBase58([]byte("hello world")))
+	assert.Equal(t, "11StV1DL6CwTryKyV", encodeBase58(append([]byte{0, 0}, []byte("hello world")...)))
+	buf, err := decodeBase58("11StV1DL6CwTryKyV")
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0, 0}, []byte("hello world")...), buf)
+}
+
+func TestDIDKeyVector(t *testing.T) {
+	// Test vector from the did:key specification
+	jwk, err := ParseJWK([]byte(`{"kty":"OKP","crv":"Ed25519","x":"O2onvM62pC1io6jQKm8Nc2UyFXcd4kOmOsBIoYtZ2ik"}`))
+	require.NoError(t, err)
+	pub, err := jwk.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, "did:key:z6MkiTBz1ymuepAQ4HEHYSF1H8quG5GLVVQR3djdX3mDooWp", DIDKeyFromPublicKey(pub))
+}
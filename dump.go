@@ -0,0 +1,240 @@
+package smolcert
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// extensionDumper describes a known extension for Dump
+type extensionDumper struct {
+	name   string
+	format func(val []byte) ([]string, error)
+}
+
+var extensionDumpers = map[uint64]extensionDumper{
+	OIDKeyUsage: {"KeyUsage", func(val []byte) ([]string, error) {
+		k, err := ParseKeyUsage(val)
+		return []string{k.String()}, err
+	}},
+	OIDNextKey: {"NextKey", func(val []byte) ([]string, error) {
+		hash, err := ParseNextKey(val)
+		return []string{"SHA-256: " + hex.EncodeToString(hash)}, err
+	}},
+	OIDDelegation: {"Delegation", func(val []byte) ([]string, error) {
+		d, err := ParseDelegation(val)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"Max validity: " + d.MaxValidityDuration().String()}, nil
+	}},
+	OIDThreshold: {"ThresholdPolicy", func(val []byte) ([]string, error) {
+		p, err := ParseThresholdPolicy(val)
+		if err != nil {
+			return nil, err
+		}
+		lines := []string{fmt.Sprintf("Threshold: %d of %d keys", p.Threshold, len(p.Keys))}
+		for _, key := range p.Keys {
+			lines = append(lines, "Key: "+PinFromPublicKey(key).String())
+		}
+		return lines, nil
+	}},
+	OIDPostQuantumKey: {"PostQuantumKey", func(val []byte) ([]string, error) {
+		k, err := ParsePostQuantumKey(val)
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("%s key (%d bytes)", k.Algorithm, len(k.Key))}, nil
+	}},
+	OIDNameConstraints: {"NameConstraints", func(val []byte) ([]string, error) {
+		nc, err := ParseNameConstraints(val)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, prefix := range nc.PermittedPrefixes {
+			lines = append(lines, fmt.Sprintf("Permitted prefix: %q", prefix))
+		}
+		for _, suffix := range nc.PermittedSuffixes {
+			lines = append(lines, fmt.Sprintf("Permitted suffix: %q", suffix))
+		}
+		return lines, nil
+	}},
+	OIDSubjectAttributes: {"SubjectAttributes", func(val []byte) ([]string, error) {
+		attrs, err := ParseSubjectAttributes(val)
+		return []string{attrs.String()}, err
+	}},
+	OIDCapabilities: {"Capabilities", func(val []byte) ([]string, error) {
+		caps, err := ParseCapabilities(val)
+		return []string(caps), err
+	}},
+	OIDCaveats: {"Caveats", func(val []byte) ([]string, error) {
+		caveats, err := ParseCaveats(val)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, caveat := range caveats {
+			var v interface{}
+			if err := cbor.Unmarshal(caveat.Value, &v); err != nil {
+				lines = append(lines, fmt.Sprintf("%s: %s", caveat.Type, hex.EncodeToString(caveat.Value)))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %v", caveat.Type, v))
+		}
+		return lines, nil
+	}},
+	OIDCRLDistributionPoints: {"CRLDistributionPoints", func(val []byte) ([]string, error) {
+		return ParseCRLDistributionPoints(val)
+	}},
+	OIDHolder: {"Holder", func(val []byte) ([]string, error) {
+		holder, err := parseHolder(val)
+		return []string{holder.String()}, err
+	}},
+	OIDIssuanceEpoch: {"IssuanceEpoch", func(val []byte) ([]string, error) {
+		epoch, err := ParseIssuanceEpoch(val)
+		return []string{fmt.Sprintf("Epoch: %d", epoch)}, err
+	}},
+	OIDAudience: {"Audience", func(val []byte) ([]string, error) {
+		r, err := ParseAudienceRestriction(val)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, audience := range r.Audiences {
+			lines = append(lines, fmt.Sprintf("Audience: %q", audience))
+		}
+		for _, scope := range r.Scopes {
+			lines = append(lines, fmt.Sprintf("Scope: %q", scope))
+		}
+		return lines, nil
+	}},
+	OIDSubjectAltNames: {"SubjectAltNames", func(val []byte) ([]string, error) {
+		names, err := ParseSubjectAltNames(val)
+		if err != nil {
+			return nil, err
+		}
+		lines := make([]string, len(names))
+		for i, n := range names {
+			lines[i] = n.String()
+		}
+		return lines, nil
+	}},
+	OIDIssuerPolicy: {"IssuerPolicy", func(val []byte) ([]string, error) {
+		p, err := ParseIssuerPolicy(val)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, keyUsage := range p.AllowedKeyUsages {
+			lines = append(lines, "Allowed KeyUsage: "+keyUsage.String())
+		}
+		if p.MaxValidity > 0 {
+			lines = append(lines, "Max validity: "+p.MaxValidityDuration().String())
+		}
+		for _, pattern := range p.SubjectPatterns {
+			lines = append(lines, fmt.Sprintf("Subject pattern: %q", pattern))
+		}
+		return lines, nil
+	}},
+}
+
+// String returns a short description of the certificate for logging and debugging
+func (c *Certificate) String() string {
+	if c == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("'%s' issued by '%s' (serial %d, key %s)", c.Subject, c.Issuer, c.SerialNumber,
+		PinFromPublicKey(c.PubKey))
+}
+
+// Dump writes a detailed human readable description of the certificate to w, similar to
+// openssl x509 -text. Known extensions are decoded, unknown ones are printed as hex.
+func (c *Certificate) Dump(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	version := c.Version
+	if version == 0 {
+		version = Version1
+	}
+	fmt.Fprintf(buf, "Certificate:\n")
+	fmt.Fprintf(buf, "    Version: %d\n", version)
+	fmt.Fprintf(buf, "    Serial Number: %d\n", c.SerialNumber)
+	fmt.Fprintf(buf, "    Signature Algorithm: %s\n", c.SignatureAlgorithm)
+	fmt.Fprintf(buf, "    Issuer: %s\n", c.Issuer)
+	fmt.Fprintf(buf, "    Validity:\n")
+	notBefore, notAfter := "unbounded", "unbounded"
+	if c.Validity != nil {
+		notBefore, notAfter = dumpTime(c.Validity.NotBefore.IsZero(), c.Validity.NotBeforeTime()),
+			dumpTime(c.Validity.NotAfter.IsZero(), c.Validity.NotAfterTime())
+	}
+	fmt.Fprintf(buf, "        Not Before: %s\n", notBefore)
+	fmt.Fprintf(buf, "        Not After : %s\n", notAfter)
+	fmt.Fprintf(buf, "    Subject: %s\n", c.Subject)
+	fmt.Fprintf(buf, "    Public Key:\n")
+	fmt.Fprintf(buf, "        Pin: %s\n", PinFromPublicKey(c.PubKey))
+	dumpHex(buf, "        ", c.PubKey)
+	if len(c.Extensions) > 0 {
+		fmt.Fprintf(buf, "    Extensions:\n")
+	}
+	for _, ext := range c.Extensions {
+		dumpExtension(buf, ext)
+	}
+	if len(c.UnknownFields) > 0 {
+		fmt.Fprintf(buf, "    Unknown Fields: %d\n", len(c.UnknownFields))
+	}
+	fmt.Fprintf(buf, "    Signature:\n")
+	dumpHex(buf, "        ", c.Signature)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func dumpTime(zero bool, t time.Time) string {
+	if zero {
+		return "unbounded"
+	}
+	return t.UTC().Format(rfc3339Millis)
+}
+
+func dumpExtension(buf *bytes.Buffer, ext Extension) {
+	critical := ""
+	if ext.Critical {
+		critical = ", critical"
+	}
+	dumper, known := extensionDumpers[ext.OID]
+	if !known {
+		fmt.Fprintf(buf, "        Unknown (OID 0x%X)%s:\n", ext.OID, critical)
+		dumpHex(buf, "            ", ext.Value)
+		return
+	}
+	fmt.Fprintf(buf, "        %s (OID 0x%X)%s:\n", dumper.name, ext.OID, critical)
+	lines, err := dumper.format(ext.Value)
+	if err != nil {
+		fmt.Fprintf(buf, "            Invalid: %s\n", err)
+		dumpHex(buf, "            ", ext.Value)
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintf(buf, "            %s\n", line)
+	}
+}
+
+// dumpHex writes data as colon separated hex bytes, 16 bytes per line
+func dumpHex(buf *bytes.Buffer, indent string, data []byte) {
+	for len(data) > 0 {
+		n := 16
+		if len(data) < n {
+			n = len(data)
+		}
+		parts := make([]string, n)
+		for i, b := range data[:n] {
+			parts[i] = hex.EncodeToString([]byte{b})
+		}
+		fmt.Fprintf(buf, "%s%s\n", indent, strings.Join(parts, ":"))
+		data = data[n:]
+	}
+}
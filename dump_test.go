@@ -0,0 +1,74 @@
+package smolcert
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCertificateString(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 42, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	assert.Equal(t, fmt.Sprintf("'device' issued by 'root' (serial 42, key %s)", PinFromCertificate(cert)), cert.String())
+	assert.Equal(t, cert.String(), fmt.Sprint(cert))
+	var nilCert *Certificate
+	assert.Equal(t, "<nil>", nilCert.String())
+}
+
+func TestDump(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	capabilities, err := CapabilitiesExtension("door/open", "door/close")
+	require.NoError(t, err)
+	constraints, err := NameConstraintsExtension(&NameConstraints{PermittedPrefixes: []string{"device-"}})
+	require.NoError(t, err)
+	notBefore := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cert, err := SignCertificate(&Certificate{
+		Version:      Version4,
+		SerialNumber: 42,
+		Issuer:       "root",
+		Validity:     NewMillisValidity(notBefore, time.Time{}),
+		Subject:      "device",
+		PubKey:       rootKey.Public().(ed25519.PublicKey),
+		Extensions: []Extension{
+			capabilities,
+			constraints,
+			{OID: 0xFF, Value: []byte{0xde, 0xad}},
+			{OID: OIDKeyUsage, Critical: true, Value: []byte{1, 2}},
+		},
+	}, rootKey)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, cert.Dump(out))
+	dump := out.String()
+	assert.Contains(t, dump, "    Version: 4\n")
+	assert.Contains(t, dump, "    Serial Number: 42\n")
+	assert.Contains(t, dump, "    Signature Algorithm: Ed25519\n")
+	assert.Contains(t, dump, "    Issuer: root\n")
+	assert.Contains(t, dump, "    Subject: device\n")
+	assert.Contains(t, dump, "        Not Before: 2026-01-02T03:04:05Z\n        Not After : unbounded\n")
+	assert.Contains(t, dump, "        Pin: "+PinFromCertificate(cert).String()+"\n")
+	assert.Contains(t, dump, "        Capabilities (OID 0x17), critical:\n            door/open\n            door/close\n")
+	assert.Contains(t, dump, "        NameConstraints (OID 0x15), critical:\n            Permitted prefix: \"device-\"\n")
+	assert.Contains(t, dump, "        Unknown (OID 0xFF):\n            de:ad\n")
+	assert.Contains(t, dump, "        KeyUsage (OID 0x10), critical:\n            Invalid: ")
+	assert.Contains(t, dump, "    Signature:\n")
+	assert.Len(t, cert.Signature, 64)
+	// Signatures are printed with 16 bytes per line
+	assert.Contains(t, dump, "        "+hexLine(cert.Signature[48:])+"\n")
+}
+
+func hexLine(data []byte) string {
+	buf := &bytes.Buffer{}
+	dumpHex(buf, "", data)
+	return buf.String()[:buf.Len()-1]
+}
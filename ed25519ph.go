@@ -0,0 +1,137 @@
+package smolcert
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/ed25519"
+)
+
+// AlgorithmEd25519ph is Ed25519ph (RFC 8032) with an empty context, signing the SHA-512 digest of
+// the certificate instead of the certificate itself. The COSE registry has no identifier for it,
+// so the value is taken from the private use range.
+const AlgorithmEd25519ph Algorithm = -65538
+
+// ed25519phDom is dom2(1, "") of RFC 8032, prepended to all hashes of Ed25519ph
+var ed25519phDom = []byte("SigEd25519 no Ed25519 collisions\x01\x00")
+
+// PrehashSigner creates Ed25519ph signatures over the SHA-512 digest of a message. This allows
+// signers like HSMs to sign certificates with large extensions without processing the whole
+// certificate.
+type PrehashSigner interface {
+	// Public returns the public key of the signer
+	Public() ed25519.PublicKey
+	// SignPrehashed signs the SHA-512 digest of a message with Ed25519ph
+	SignPrehashed(digest []byte) ([]byte, error)
+}
+
+// Ed25519phKey is a PrehashSigner for an ed25519 private key held in memory
+type Ed25519phKey ed25519.PrivateKey
+
+// Public returns the public key of k
+func (k Ed25519phKey) Public() ed25519.PublicKey {
+	return ed25519.PrivateKey(k).Public().(ed25519.PublicKey)
+}
+
+// SignPrehashed signs digest with Ed25519ph
+func (k Ed25519phKey) SignPrehashed(digest []byte) ([]byte, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid ed25519 private key")
+	}
+	if len(digest) != sha512.Size {
+		return nil, errors.New("Ed25519ph requires a SHA-512 digest")
+	}
+	h := sha512.Sum512(ed25519.PrivateKey(k).Seed())
+	s, err := edwards25519.NewScalar().SetBytesWithClamping(h[:32])
+	if err != nil {
+		return nil, err
+	}
+
+	rHash := sha512.New()
+	rHash.Write(ed25519phDom)
+	rHash.Write(h[32:])
+	rHash.Write(digest)
+	r, err := edwards25519.NewScalar().SetUniformBytes(rHash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	R := new(edwards25519.Point).ScalarBaseMult(r).Bytes()
+
+	k2, err := ed25519phChallenge(R, k.Public(), digest)
+	if err != nil {
+		return nil, err
+	}
+	S := edwards25519.NewScalar().MultiplyAdd(k2, s, r)
+	return append(R, S.Bytes()...), nil
+}
+
+// ed25519phChallenge computes the scalar k = SHA-512(dom2 || R || A || PH(M))
+func ed25519phChallenge(R []byte, pubKey ed25519.PublicKey, digest []byte) (*edwards25519.Scalar, error) {
+	h := sha512.New()
+	h.Write(ed25519phDom)
+	h.Write(R)
+	h.Write(pubKey)
+	h.Write(digest)
+	return edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+}
+
+// SignCertificatePrehashed signs cert with Ed25519ph, so that signer only needs to process the
+// SHA-512 digest of the certificate
+func SignCertificatePrehashed(cert *Certificate, signer PrehashSigner) (*Certificate, error) {
+	cert.SignatureAlgorithm = AlgorithmEd25519ph
+	cert.Signature = nil
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return nil, err
+	}
+	digest := sha512.Sum512(certBytes)
+	sig, err := signer.SignPrehashed(digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create Ed25519ph signature: %w", err)
+	}
+	cert.Signature = sig
+	return cert, nil
+}
+
+// verifyPrehashedSignature verifies the Ed25519ph signature sig of message
+func verifyPrehashedSignature(pubKey ed25519.PublicKey, message, sig []byte) bool {
+	digest := sha512.Sum512(message)
+	return verifyPrehashedDigest(pubKey, digest[:], sig)
+}
+
+// verifyPrehashedDigest verifies the Ed25519ph signature sig of the SHA-512 digest of a message
+func verifyPrehashedDigest(pubKey ed25519.PublicKey, digest, sig []byte) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	k, err := ed25519phChallenge(sig[:32], pubKey, digest)
+	if err != nil {
+		return false
+	}
+	return checkEd25519Signature(pubKey, sig, k)
+}
+
+// checkEd25519Signature checks that sig is a signature with the challenge k, which is the same
+// for Ed25519 and Ed25519ph except for the hashed data. Like the BatchVerifier it uses the
+// cofactored equation [8]([S]B - [k]A - R) = 0, so a signature is valid regardless of whether it
+// is verified alone or as part of a batch.
+func checkEd25519Signature(pubKey ed25519.PublicKey, sig []byte, k *edwards25519.Scalar) bool {
+	A, err := new(edwards25519.Point).SetBytes(pubKey)
+	if err != nil {
+		return false
+	}
+	R, err := new(edwards25519.Point).SetBytes(sig[:32])
+	if err != nil {
+		return false
+	}
+	S, err := edwards25519.NewScalar().SetCanonicalBytes(sig[32:])
+	if err != nil {
+		return false
+	}
+	check := new(edwards25519.Point).VarTimeDoubleScalarBaseMult(edwards25519.NewScalar().Negate(k), A, S)
+	check.Subtract(check, R)
+	check.MultByCofactor(check)
+	return check.Equal(edwards25519.NewIdentityPoint()) == 1
+}
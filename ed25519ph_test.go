@@ -0,0 +1,85 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestEd25519phTestVector(t *testing.T) {
+	// Test vector 'abc' from RFC 8032, section 7.3
+	seed, _ := hex.DecodeString("833fe62409237b9d62ec77587520911e9a759cec1d19755b7da901b96dca3d42")
+	expectedPub, _ := hex.DecodeString("ec172b93ad5e563bf4932c70e1245034c35467ef2efd4d64ebf819683467e2bf")
+	expectedSig, _ := hex.DecodeString("98a70222f0b8121aa9d30f813d683f809e462b469c7ff87639499bb94e6dae41" +
+		"31f85042463c2a355a2003d062adf5aaa10b8c61e636062aaad11c2a26083406")
+
+	key := Ed25519phKey(ed25519.NewKeyFromSeed(seed))
+	assert.Equal(t, ed25519.PublicKey(expectedPub), key.Public())
+	digest := sha512.Sum512([]byte("abc"))
+	sig, err := key.SignPrehashed(digest[:])
+	require.NoError(t, err)
+	assert.Equal(t, expectedSig, sig)
+	assert.True(t, verifyPrehashedSignature(key.Public(), []byte("abc"), sig))
+	assert.False(t, verifyPrehashedSignature(key.Public(), []byte("abd"), sig))
+	// Ed25519ph signatures are no valid Ed25519 signatures and vice versa
+	assert.False(t, verifySignature(key.Public(), []byte("abc"), sig))
+	assert.False(t, verifyPrehashedSignature(key.Public(), []byte("abc"), ed25519.Sign(ed25519.PrivateKey(key), []byte("abc"))))
+
+	_, err = key.SignPrehashed([]byte("abc"))
+	assert.Error(t, err)
+}
+
+func TestSignCertificatePrehashed(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	blob := make([]byte, 1<<20)
+	_, err = rand.Read(blob)
+	require.NoError(t, err)
+
+	cert, err := NewCertificateBuilder().Subject("device").PublicKey(pubKey).Issuer("root").
+		AddExtension(Extension{OID: 0x8000, Value: blob}).Build()
+	require.NoError(t, err)
+	cert, err = SignCertificatePrehashed(cert, Ed25519phKey(rootKey))
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmEd25519ph, cert.SignatureAlgorithm)
+	assert.Equal(t, "Ed25519ph", cert.SignatureAlgorithm.String())
+
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	assert.NoError(t, pool.Validate(parsed))
+	_, err = pool.ValidateBundle([]*Certificate{parsed})
+	assert.NoError(t, err)
+	assert.True(t, pool.ValidateBundleReport([]*Certificate{parsed}).Valid())
+
+	// Batches can mix both signature modes
+	other, _, err := ClientCertificate("other", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	b := NewBatchVerifier()
+	tbs, err := parsed.TBSBytes()
+	require.NoError(t, err)
+	b.AddPrehashed(rootCert.PubKey, tbs, parsed.Signature)
+	otherTBS, err := other.TBSBytes()
+	require.NoError(t, err)
+	b.Add(rootCert.PubKey, otherTBS, other.Signature)
+	assert.True(t, b.Verify())
+	b.AddPrehashed(rootCert.PubKey, otherTBS, other.Signature)
+	assert.False(t, b.Verify())
+	assert.Equal(t, []bool{true, true, false}, b.VerifyEach())
+
+	// Flipping the algorithm invalidates the signature
+	tampered := parsed.Copy()
+	tampered.SignatureAlgorithm = AlgorithmEd25519
+	assert.True(t, errors.Is(pool.Validate(tampered), ErrBadSignature))
+}
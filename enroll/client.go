@@ -0,0 +1,123 @@
+package enroll
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Client implements the client side of the enrollment protocol
+type Client struct {
+	// BaseURL is the URL the Server is mounted at
+	BaseURL string
+	// HTTPClient is used to perform requests, http.DefaultClient is used if nil
+	HTTPClient *http.Client
+}
+
+// NewOrder creates a new Order for subject
+func (c *Client) NewOrder(ctx context.Context, subject string) (*Order, error) {
+	body, err := cborEm.Marshal(&NewOrderRequest{Subject: subject})
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, "/orders", body)
+}
+
+// Order fetches the current state of an Order
+func (c *Client) Order(ctx context.Context, id string) (*Order, error) {
+	return c.do(ctx, http.MethodGet, "/orders/"+id, nil)
+}
+
+// AnswerChallenge sends the answer to the challenge of an Order
+func (c *Client) AnswerChallenge(ctx context.Context, order *Order, payload []byte) (*Order, error) {
+	body, err := cborEm.Marshal(&ChallengeAnswer{Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, "/orders/"+order.ID+"/challenge", body)
+}
+
+// Finalize sends a certificate request for a ready Order and returns the issued certificate
+func (c *Client) Finalize(ctx context.Context, order *Order, csr *smolcert.CertificateRequest) (*smolcert.Certificate, error) {
+	body, err := csr.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	order, err = c.do(ctx, http.MethodPost, "/orders/"+order.ID+"/finalize", body)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != StatusValid || len(order.Certificate) == 0 {
+		return nil, fmt.Errorf("Order is %s, but no certificate has been issued", order.Status)
+	}
+	return smolcert.ParseBuf(order.Certificate)
+}
+
+// Enroll performs the complete enrollment protocol for subject and the key priv. answer is
+// called to create the answer to the challenge of the order.
+func (c *Client) Enroll(ctx context.Context, subject string, priv ed25519.PrivateKey,
+	answer func(order *Order) ([]byte, error)) (*smolcert.Certificate, error) {
+	order, err := c.NewOrder(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := answer(order)
+	if err != nil {
+		return nil, err
+	}
+	order, err = c.AnswerChallenge(ctx, order, payload)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := smolcert.NewCertificateRequest(subject, nil, priv)
+	if err != nil {
+		return nil, err
+	}
+	return c.Finalize(ctx, order, csr)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*Order, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", ContentType)
+	}
+	req.Header.Set("Accept", ContentType)
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := &ErrorResponse{}
+		if err := cbor.Unmarshal(respBody, errResp); err != nil || errResp.Message == "" {
+			return nil, fmt.Errorf("Enrollment request failed with status %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("Enrollment request failed with status %d: %s", resp.StatusCode, errResp.Message)
+	}
+	order := &Order{}
+	if err := cbor.Unmarshal(respBody, order); err != nil {
+		return nil, errors.New("Invalid response from enrollment server: " + err.Error())
+	}
+	return order, nil
+}
@@ -0,0 +1,59 @@
+package coap
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/smolcert/smolcert"
+)
+
+// DefaultPort is the default port of coap URLs
+const DefaultPort = "5683"
+
+// CRLTransport fetches CRLs from coap distribution points. Every request uses a new connection.
+// The CRL needs to fit into a single datagram, as block-wise transfers are not supported.
+type CRLTransport struct {
+	// AckTimeout is the initial retransmission timeout, defaults to DefaultAckTimeout
+	AckTimeout time.Duration
+	// MaxRetransmit is the maximum number of retransmissions, defaults to DefaultMaxRetransmit
+	MaxRetransmit int
+}
+
+var _ smolcert.CRLTransport = &CRLTransport{}
+
+// Fetch implements smolcert.CRLTransport
+func (t *CRLTransport) Fetch(ctx context.Context, u string) ([]byte, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "coap" {
+		return nil, fmt.Errorf("Unsupported scheme '%s'", parsed.Scheme)
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(strings.Trim(parsed.Hostname(), "[]"), DefaultPort)
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client := &Client{Conn: conn, AckTimeout: t.AckTimeout, MaxRetransmit: t.MaxRetransmit}
+
+	req := &Message{Code: GET}
+	req.SetPath(parsed.Path)
+	resp, err := client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Code.IsSuccess() {
+		return nil, fmt.Errorf("Fetching CRL from %s failed with %s: %s", u, resp.Code, string(resp.Payload))
+	}
+	return resp.Payload, nil
+}
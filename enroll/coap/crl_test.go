@@ -0,0 +1,58 @@
+package coap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestCRLTransport(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+
+	serverConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go Serve(serverConn, HandlerFunc(func(req *Message) *Message {
+		if req.Path() != "/crl" {
+			return errorResponse(NotFound, "Not found")
+		}
+		crl, err := ca.GenerateCRL()
+		if err != nil {
+			return errorResponse(InternalError, err.Error())
+		}
+		buf, _ := crl.Bytes()
+		return cborResponse(Content, buf)
+	}))
+
+	dp, err := smolcert.CRLDistributionPointsExtension("coap://" + serverConn.LocalAddr().String() + "/crl")
+	require.NoError(t, err)
+	ca.Options = []smolcert.IssueOption{smolcert.WithExtensions(dp)}
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+
+	fetcher := smolcert.NewCRLFetcher()
+	fetcher.Transports["coap"] = &CRLTransport{AckTimeout: 100 * time.Millisecond}
+	v := smolcert.NewValidator(smolcert.NewCertPool(rootCert))
+	v.AddContextHook(smolcert.RevocationHook(fetcher))
+	require.NoError(t, v.Validate(cert))
+
+	require.NoError(t, ca.Revoke(cert.SerialNumber, smolcert.RevocationReasonKeyCompromise))
+	fetcher.MaxAge = time.Nanosecond
+	assert.True(t, errors.Is(v.Validate(cert), smolcert.ErrRevoked))
+
+	_, err = (&CRLTransport{AckTimeout: 100 * time.Millisecond}).Fetch(context.Background(),
+		"coap://"+serverConn.LocalAddr().String()+"/missing")
+	assert.Error(t, err)
+}
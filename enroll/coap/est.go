@@ -0,0 +1,244 @@
+package coap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Paths of the EST resources
+const (
+	PathCACertificates = "/.well-known/est/crts"
+	PathSimpleEnroll   = "/.well-known/est/sen"
+	PathSimpleReenroll = "/.well-known/est/sren"
+)
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// EnrollRequest is the payload of a simple enrollment request
+type EnrollRequest struct {
+	_ struct{} `cbor:",toarray"`
+
+	// CertificateRequest is the encoded smolcert.CertificateRequest
+	CertificateRequest []byte `cbor:"certificate_request"`
+	// Credential authenticates the device, i.e. a MAC created with a pre shared key
+	Credential []byte `cbor:"credential"`
+}
+
+// Authenticator decides if a device is allowed to enroll with the given certificate request
+type Authenticator func(csr *smolcert.CertificateRequest, credential []byte) error
+
+// PreSharedKeyAuthenticator authenticates devices via credentials created with PreSharedKeyCredential.
+// keys returns the key shared with the device for a subject.
+func PreSharedKeyAuthenticator(keys func(subject string) ([]byte, bool)) Authenticator {
+	return func(csr *smolcert.CertificateRequest, credential []byte) error {
+		key, found := keys(csr.Subject)
+		if !found {
+			return errors.New("Unknown device")
+		}
+		expected, err := PreSharedKeyCredential(key, csr)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal(expected, credential) {
+			return errors.New("Invalid credential")
+		}
+		return nil
+	}
+}
+
+// PreSharedKeyCredential creates a credential for a certificate request with a key shared with the server
+func PreSharedKeyCredential(key []byte, csr *smolcert.CertificateRequest) ([]byte, error) {
+	csrBytes, err := csr.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(csrBytes)
+	return mac.Sum(nil), nil
+}
+
+// ESTServer implements the EST resources as Handler
+type ESTServer struct {
+	// CACertificates are returned to clients requesting the CA certificates
+	CACertificates []*smolcert.Certificate
+	// Issuer is the name of the issuer of issued certificates
+	Issuer string
+	// IssuerKey is used to sign issued certificates
+	IssuerKey ed25519.PrivateKey
+	// Validity is the validity of issued certificates
+	Validity time.Duration
+	// Extensions are added to every certificate issued via simple enrollment
+	Extensions []smolcert.Extension
+	// Authenticate authenticates simple enrollment requests
+	Authenticate Authenticator
+	// Reenroller handles simple re-enrollment requests, re-enrollment is disabled if nil
+	Reenroller *smolcert.Reenroller
+}
+
+// ServeCoAP implements Handler
+func (s *ESTServer) ServeCoAP(req *Message) *Message {
+	switch req.Path() {
+	case PathCACertificates:
+		if req.Code != GET {
+			return errorResponse(MethodNotAllowed, "Method not allowed")
+		}
+		buf, err := cborEm.Marshal(s.CACertificates)
+		if err != nil {
+			return errorResponse(InternalError, err.Error())
+		}
+		return cborResponse(Content, buf)
+	case PathSimpleEnroll:
+		if req.Code != POST {
+			return errorResponse(MethodNotAllowed, "Method not allowed")
+		}
+		return s.simpleEnroll(req)
+	case PathSimpleReenroll:
+		if req.Code != POST {
+			return errorResponse(MethodNotAllowed, "Method not allowed")
+		}
+		return s.simpleReenroll(req)
+	default:
+		return errorResponse(NotFound, "Not found")
+	}
+}
+
+func (s *ESTServer) simpleEnroll(req *Message) *Message {
+	enrollReq := &EnrollRequest{}
+	if err := cbor.Unmarshal(req.Payload, enrollReq); err != nil {
+		return errorResponse(BadRequest, "Invalid enrollment request")
+	}
+	csr, err := smolcert.ParseCertificateRequest(enrollReq.CertificateRequest)
+	if err != nil {
+		return errorResponse(BadRequest, "Invalid certificate request")
+	}
+	if err := csr.Verify(); err != nil {
+		return errorResponse(BadRequest, err.Error())
+	}
+	if s.Authenticate == nil {
+		return errorResponse(InternalError, "No authenticator configured")
+	}
+	if err := s.Authenticate(csr, enrollReq.Credential); err != nil {
+		return errorResponse(Unauthorized, err.Error())
+	}
+
+	cert, err := smolcert.Issue(csr.Subject, csr.PubKey, s.Issuer, s.IssuerKey,
+		smolcert.WithValidFor(s.Validity),
+		smolcert.WithExtensions(s.Extensions...))
+	if err != nil {
+		return errorResponse(InternalError, err.Error())
+	}
+	return certificateResponse(cert)
+}
+
+func (s *ESTServer) simpleReenroll(req *Message) *Message {
+	if s.Reenroller == nil {
+		return errorResponse(NotFound, "Re-enrollment is not supported")
+	}
+	renewalReq, err := smolcert.ParseRenewalRequest(req.Payload)
+	if err != nil {
+		return errorResponse(BadRequest, "Invalid renewal request")
+	}
+	cert, err := s.Reenroller.Renew(renewalReq)
+	if err != nil {
+		return errorResponse(Forbidden, err.Error())
+	}
+	return certificateResponse(cert)
+}
+
+func certificateResponse(cert *smolcert.Certificate) *Message {
+	buf, err := cert.Bytes()
+	if err != nil {
+		return errorResponse(InternalError, err.Error())
+	}
+	return cborResponse(Changed, buf)
+}
+
+func cborResponse(code Code, payload []byte) *Message {
+	resp := &Message{Code: code, Payload: payload}
+	resp.SetContentFormat(ContentFormatCBOR)
+	return resp
+}
+
+func errorResponse(code Code, message string) *Message {
+	return &Message{Code: code, Payload: []byte(message)}
+}
+
+// ESTClient performs enrollments against an ESTServer
+type ESTClient struct {
+	Client *Client
+}
+
+// CACertificates fetches the CA certificates from the server
+func (c *ESTClient) CACertificates(ctx context.Context) ([]*smolcert.Certificate, error) {
+	resp, err := c.do(ctx, GET, PathCACertificates, nil)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*smolcert.Certificate
+	if err := cbor.Unmarshal(resp.Payload, &certs); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+// SimpleEnroll requests a certificate for csr, authenticated by credential
+func (c *ESTClient) SimpleEnroll(ctx context.Context, csr *smolcert.CertificateRequest, credential []byte) (*smolcert.Certificate, error) {
+	csrBytes, err := csr.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := cborEm.Marshal(&EnrollRequest{CertificateRequest: csrBytes, Credential: credential})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, POST, PathSimpleEnroll, payload)
+	if err != nil {
+		return nil, err
+	}
+	return smolcert.ParseBuf(resp.Payload)
+}
+
+// SimpleReenroll requests a fresh certificate authenticated by the current certificate of the device
+func (c *ESTClient) SimpleReenroll(ctx context.Context, req *smolcert.RenewalRequest) (*smolcert.Certificate, error) {
+	payload, err := req.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, POST, PathSimpleReenroll, payload)
+	if err != nil {
+		return nil, err
+	}
+	return smolcert.ParseBuf(resp.Payload)
+}
+
+func (c *ESTClient) do(ctx context.Context, code Code, path string, payload []byte) (*Message, error) {
+	req := &Message{Code: code, Payload: payload}
+	req.SetPath(path)
+	if payload != nil {
+		req.SetContentFormat(ContentFormatCBOR)
+	}
+	resp, err := c.Client.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Code.IsSuccess() {
+		return nil, fmt.Errorf("EST request failed with %s: %s", resp.Code, string(resp.Payload))
+	}
+	return resp, nil
+}
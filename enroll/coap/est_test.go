@@ -0,0 +1,111 @@
+package coap
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestESTOverCoAP(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := smolcert.NewCertPool(rootCert)
+	psk := []byte("provisioning secret")
+
+	s := &ESTServer{
+		CACertificates: []*smolcert.Certificate{rootCert},
+		Issuer:         rootCert.Subject,
+		IssuerKey:      rootKey,
+		Validity:       time.Hour,
+		Extensions: []smolcert.Extension{
+			{OID: smolcert.OIDKeyUsage, Critical: true, Value: smolcert.KeyUsageClientIdentification.ToBytes()},
+		},
+		Authenticate: PreSharedKeyAuthenticator(func(subject string) ([]byte, bool) {
+			return psk, subject == "device"
+		}),
+		Reenroller: &smolcert.Reenroller{
+			Pool:      pool,
+			Issuer:    rootCert.Subject,
+			IssuerKey: rootKey,
+			Policy:    smolcert.RenewalPolicy{Validity: time.Hour * 2},
+		},
+	}
+
+	serverConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go func() {
+		_ = Serve(serverConn, s)
+	}()
+
+	conn, err := net.Dial("udp", serverConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &ESTClient{Client: &Client{Conn: conn, AckTimeout: time.Millisecond * 200}}
+	ctx := context.Background()
+
+	caCerts, err := c.CACertificates(ctx)
+	require.NoError(t, err)
+	require.Len(t, caCerts, 1)
+	assert.Equal(t, rootCert.PubKey, caCerts[0].PubKey)
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	csr, err := smolcert.NewCertificateRequest("device", nil, priv)
+	require.NoError(t, err)
+	credential, err := PreSharedKeyCredential(psk, csr)
+	require.NoError(t, err)
+
+	cert, err := c.SimpleEnroll(ctx, csr, credential)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(cert))
+	assert.Equal(t, "device", cert.Subject)
+
+	_, err = c.SimpleEnroll(ctx, csr, []byte("wrong"))
+	assert.Error(t, err)
+
+	renewalReq, err := smolcert.NewRenewalRequest(cert, priv, nil)
+	require.NoError(t, err)
+	renewed, err := c.SimpleReenroll(ctx, renewalReq)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(renewed))
+	assert.NotEqual(t, cert.SerialNumber, renewed.SerialNumber)
+}
+
+func TestClientRetransmits(t *testing.T) {
+	serverConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	var handled int32
+	go func() {
+		// Drop the first request to force a retransmission
+		buf := make([]byte, maxMessageSize)
+		_, _, _ = serverConn.ReadFrom(buf)
+		_ = Serve(serverConn, HandlerFunc(func(req *Message) *Message {
+			atomic.AddInt32(&handled, 1)
+			return &Message{Code: Content, Payload: []byte("pong")}
+		}))
+	}()
+
+	conn, err := net.Dial("udp", serverConn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &Client{Conn: conn, AckTimeout: time.Millisecond * 50}
+
+	req := &Message{Code: GET}
+	req.SetPath("/ping")
+	resp, err := c.Do(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, Content, resp.Code)
+	assert.Equal(t, []byte("pong"), resp.Payload)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&handled))
+}
@@ -0,0 +1,239 @@
+/*
+Package coap implements certificate enrollment for constrained devices over CoAP (RFC 7252), modeled
+after EST over CoAP (RFC 9148). It contains a minimal CoAP implementation supporting confirmable
+requests with piggybacked responses, which is sufficient for the small messages of the enrollment.
+
+The server offers the following resources below /.well-known/est:
+
+	crts  GET   returns the CA certificates as CBOR array
+	sen   POST  simple enrollment, expects an EnrollRequest
+	sren  POST  simple re-enrollment, expects a smolcert.RenewalRequest
+*/
+package coap
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type is the type of a CoAP message
+type Type uint8
+
+// CoAP message types
+const (
+	Confirmable     Type = 0
+	NonConfirmable  Type = 1
+	Acknowledgement Type = 2
+	Reset           Type = 3
+)
+
+// Code is the request method or response code of a CoAP message
+type Code uint8
+
+// CoAP methods and response codes used by this package
+const (
+	Empty Code = 0x00
+	GET   Code = 0x01
+	POST  Code = 0x02
+
+	Changed          Code = 0x44
+	Content          Code = 0x45
+	BadRequest       Code = 0x80
+	Unauthorized     Code = 0x81
+	Forbidden        Code = 0x83
+	NotFound         Code = 0x84
+	MethodNotAllowed Code = 0x85
+	InternalError    Code = 0xa0
+)
+
+// String returns the code in the common c.dd notation
+func (c Code) String() string {
+	return fmt.Sprintf("%d.%02d", c>>5, c&0x1f)
+}
+
+// IsSuccess is true for 2.xx response codes
+func (c Code) IsSuccess() bool {
+	return c>>5 == 2
+}
+
+// Option numbers used by this package
+const (
+	OptionURIPath       uint16 = 11
+	OptionContentFormat uint16 = 12
+)
+
+// ContentFormatCBOR is the CoAP content format of application/cbor
+const ContentFormatCBOR = 60
+
+// Option is a CoAP option
+type Option struct {
+	Number uint16
+	Value  []byte
+}
+
+// Message is a CoAP message
+type Message struct {
+	Type      Type
+	Code      Code
+	MessageID uint16
+	Token     []byte
+	Options   []Option
+	Payload   []byte
+}
+
+var errMessageFormat = errors.New("Invalid CoAP message format")
+
+// Path returns the URI path of the message
+func (m *Message) Path() string {
+	var segments []string
+	for _, o := range m.Options {
+		if o.Number == OptionURIPath {
+			segments = append(segments, string(o.Value))
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// SetPath sets the URI path options of the message
+func (m *Message) SetPath(path string) {
+	options := m.Options[:0]
+	for _, o := range m.Options {
+		if o.Number != OptionURIPath {
+			options = append(options, o)
+		}
+	}
+	m.Options = options
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if segment != "" {
+			m.Options = append(m.Options, Option{Number: OptionURIPath, Value: []byte(segment)})
+		}
+	}
+}
+
+// SetContentFormat sets the content format option of the message
+func (m *Message) SetContentFormat(format uint16) {
+	var val []byte
+	switch {
+	case format == 0:
+	case format < 256:
+		val = []byte{byte(format)}
+	default:
+		val = []byte{byte(format >> 8), byte(format)}
+	}
+	m.Options = append(m.Options, Option{Number: OptionContentFormat, Value: val})
+}
+
+// MarshalBinary encodes the message into the CoAP wire format
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if len(m.Token) > 8 {
+		return nil, errors.New("CoAP tokens can't be longer than 8 bytes")
+	}
+	buf := []byte{0x40 | byte(m.Type)<<4 | byte(len(m.Token)), byte(m.Code), 0, 0}
+	binary.BigEndian.PutUint16(buf[2:], m.MessageID)
+	buf = append(buf, m.Token...)
+
+	options := append([]Option{}, m.Options...)
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].Number < options[j].Number
+	})
+	last := uint16(0)
+	for _, o := range options {
+		delta := int(o.Number - last)
+		last = o.Number
+		deltaNibble, deltaExt := encodeOptionNibble(delta)
+		lengthNibble, lengthExt := encodeOptionNibble(len(o.Value))
+		buf = append(buf, deltaNibble<<4|lengthNibble)
+		buf = append(buf, deltaExt...)
+		buf = append(buf, lengthExt...)
+		buf = append(buf, o.Value...)
+	}
+	if len(m.Payload) > 0 {
+		buf = append(buf, 0xff)
+		buf = append(buf, m.Payload...)
+	}
+	return buf, nil
+}
+
+func encodeOptionNibble(v int) (byte, []byte) {
+	switch {
+	case v < 13:
+		return byte(v), nil
+	case v < 269:
+		return 13, []byte{byte(v - 13)}
+	default:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(v-269))
+		return 14, ext
+	}
+}
+
+// UnmarshalBinary decodes a message from the CoAP wire format
+func (m *Message) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 || data[0]>>6 != 1 {
+		return errMessageFormat
+	}
+	tokenLength := int(data[0] & 0x0f)
+	if tokenLength > 8 || len(data) < 4+tokenLength {
+		return errMessageFormat
+	}
+	m.Type = Type(data[0] >> 4 & 0x03)
+	m.Code = Code(data[1])
+	m.MessageID = binary.BigEndian.Uint16(data[2:4])
+	m.Token = append([]byte{}, data[4:4+tokenLength]...)
+	m.Options = nil
+	m.Payload = nil
+
+	data = data[4+tokenLength:]
+	number := 0
+	for len(data) > 0 {
+		if data[0] == 0xff {
+			if len(data) == 1 {
+				return errMessageFormat
+			}
+			m.Payload = append([]byte{}, data[1:]...)
+			return nil
+		}
+		deltaNibble, lengthNibble := int(data[0]>>4), int(data[0]&0x0f)
+		data = data[1:]
+		var delta, length int
+		var err error
+		if delta, data, err = decodeOptionNibble(deltaNibble, data); err != nil {
+			return err
+		}
+		if length, data, err = decodeOptionNibble(lengthNibble, data); err != nil {
+			return err
+		}
+		if len(data) < length {
+			return errMessageFormat
+		}
+		number += delta
+		if number > 0xffff {
+			return errMessageFormat
+		}
+		m.Options = append(m.Options, Option{Number: uint16(number), Value: append([]byte{}, data[:length]...)})
+		data = data[length:]
+	}
+	return nil
+}
+
+func decodeOptionNibble(nibble int, data []byte) (int, []byte, error) {
+	switch nibble {
+	case 13:
+		if len(data) < 1 {
+			return 0, nil, errMessageFormat
+		}
+		return int(data[0]) + 13, data[1:], nil
+	case 14:
+		if len(data) < 2 {
+			return 0, nil, errMessageFormat
+		}
+		return int(binary.BigEndian.Uint16(data)) + 269, data[2:], nil
+	case 15:
+		return 0, nil, errMessageFormat
+	default:
+		return nibble, data, nil
+	}
+}
@@ -0,0 +1,56 @@
+package coap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageEncoding(t *testing.T) {
+	m := &Message{
+		Type:      Confirmable,
+		Code:      POST,
+		MessageID: 0x1234,
+		Token:     []byte{0x01, 0x02, 0x03},
+		Payload:   []byte("payload"),
+	}
+	m.SetPath("/.well-known/est/sen")
+	m.SetContentFormat(ContentFormatCBOR)
+	m.Options = append(m.Options, Option{Number: 300, Value: make([]byte, 20)})
+
+	buf, err := m.MarshalBinary()
+	require.NoError(t, err)
+
+	m2 := &Message{}
+	require.NoError(t, m2.UnmarshalBinary(buf))
+	assert.Equal(t, m.Type, m2.Type)
+	assert.Equal(t, m.Code, m2.Code)
+	assert.Equal(t, m.MessageID, m2.MessageID)
+	assert.Equal(t, m.Token, m2.Token)
+	assert.Equal(t, m.Payload, m2.Payload)
+	assert.Equal(t, "/.well-known/est/sen", m2.Path())
+	assert.Len(t, m2.Options, 5)
+	assert.Equal(t, uint16(300), m2.Options[4].Number)
+}
+
+func TestMessageDecodingRejectsInvalidData(t *testing.T) {
+	for _, data := range [][]byte{
+		{},
+		{0x40, 0x01},
+		{0x00, 0x01, 0x00, 0x01},
+		{0x49, 0x01, 0x00, 0x01},
+		{0x40, 0x01, 0x00, 0x01, 0xff},
+		{0x40, 0x01, 0x00, 0x01, 0xd5},
+		{0x40, 0x01, 0x00, 0x01, 0x13, 0x01},
+	} {
+		assert.Error(t, (&Message{}).UnmarshalBinary(data), "%x", data)
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	assert.Equal(t, "2.05", Content.String())
+	assert.Equal(t, "4.04", NotFound.String())
+	assert.True(t, Changed.IsSuccess())
+	assert.False(t, BadRequest.IsSuccess())
+}
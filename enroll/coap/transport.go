@@ -0,0 +1,192 @@
+package coap
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxMessageSize is the maximum size of CoAP messages handled by this package
+const maxMessageSize = 1152 * 2
+
+// Default transmission parameters from RFC 7252
+const (
+	DefaultAckTimeout    = time.Second * 2
+	DefaultMaxRetransmit = 4
+)
+
+// Handler responds to CoAP requests. The returned message only needs to contain the response
+// code, options and payload, the remaining fields are set by the server.
+type Handler interface {
+	ServeCoAP(req *Message) *Message
+}
+
+// HandlerFunc allows to use ordinary functions as Handler
+type HandlerFunc func(req *Message) *Message
+
+// ServeCoAP calls f(req)
+func (f HandlerFunc) ServeCoAP(req *Message) *Message {
+	return f(req)
+}
+
+type exchangeKey struct {
+	addr      string
+	messageID uint16
+}
+
+// Serve reads requests from conn and answers them via h until conn is closed. Retransmitted
+// confirmable requests are answered with the cached response instead of being handled again.
+func Serve(conn net.PacketConn, h Handler) error {
+	var (
+		responses = make(map[exchangeKey][]byte)
+		order     []exchangeKey
+		messageID uint16
+	)
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		req := &Message{}
+		if err := req.UnmarshalBinary(buf[:n]); err != nil {
+			continue
+		}
+		if req.Code == Empty || req.Code>>5 != 0 || (req.Type != Confirmable && req.Type != NonConfirmable) {
+			// Only requests are handled, ping messages are answered with a reset
+			if req.Type == Confirmable && req.Code == Empty {
+				reset, _ := (&Message{Type: Reset, MessageID: req.MessageID}).MarshalBinary()
+				_, _ = conn.WriteTo(reset, addr)
+			}
+			continue
+		}
+
+		key := exchangeKey{addr: addr.String(), messageID: req.MessageID}
+		if cached, found := responses[key]; found {
+			_, _ = conn.WriteTo(cached, addr)
+			continue
+		}
+
+		resp := h.ServeCoAP(req)
+		if resp == nil {
+			resp = &Message{Code: InternalError}
+		}
+		resp.Token = req.Token
+		if req.Type == Confirmable {
+			resp.Type = Acknowledgement
+			resp.MessageID = req.MessageID
+		} else {
+			messageID++
+			resp.Type = NonConfirmable
+			resp.MessageID = messageID
+		}
+		respBytes, err := resp.MarshalBinary()
+		if err != nil {
+			continue
+		}
+
+		responses[key] = respBytes
+		order = append(order, key)
+		if len(order) > 256 {
+			delete(responses, order[0])
+			order = order[1:]
+		}
+		_, _ = conn.WriteTo(respBytes, addr)
+	}
+}
+
+// Client sends confirmable CoAP requests and waits for their responses. A Client performs only one
+// request at a time.
+type Client struct {
+	// Conn is a connected datagram connection to the server, i.e. created via net.Dial("udp", ...)
+	Conn net.Conn
+	// AckTimeout is the initial retransmission timeout, defaults to DefaultAckTimeout
+	AckTimeout time.Duration
+	// MaxRetransmit is the maximum number of retransmissions, defaults to DefaultMaxRetransmit
+	MaxRetransmit int
+
+	lock      sync.Mutex
+	messageID uint16
+}
+
+// Do sends req as confirmable request and returns the response
+func (c *Client) Do(ctx context.Context, req *Message) (*Message, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	c.messageID++
+	req.Type = Confirmable
+	req.MessageID = c.messageID
+	req.Token = token
+	reqBytes, err := req.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.AckTimeout
+	if timeout == 0 {
+		timeout = DefaultAckTimeout
+	}
+	maxRetransmit := c.MaxRetransmit
+	if maxRetransmit == 0 {
+		maxRetransmit = DefaultMaxRetransmit
+	}
+
+	acknowledged := false
+	buf := make([]byte, maxMessageSize)
+	for attempt := 0; attempt <= maxRetransmit; attempt++ {
+		if !acknowledged {
+			if _, err := c.Conn.Write(reqBytes); err != nil {
+				return nil, err
+			}
+		}
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := c.Conn.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		for {
+			n, err := c.Conn.Read(buf)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			resp := &Message{}
+			if err := resp.UnmarshalBinary(buf[:n]); err != nil {
+				continue
+			}
+			switch {
+			case resp.Type == Reset && resp.MessageID == req.MessageID:
+				return nil, errors.New("CoAP request has been rejected by the server")
+			case resp.Type == Acknowledgement && resp.MessageID == req.MessageID && resp.Code == Empty:
+				// The response will follow separately
+				acknowledged = true
+			case resp.Type == Acknowledgement && resp.MessageID == req.MessageID:
+				return resp, nil
+			case resp.Type != Acknowledgement && string(resp.Token) == string(token):
+				if resp.Type == Confirmable {
+					ack, _ := (&Message{Type: Acknowledgement, MessageID: resp.MessageID}).MarshalBinary()
+					_, _ = c.Conn.Write(ack)
+				}
+				return resp, nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		timeout *= 2
+	}
+	return nil, errors.New("CoAP request timed out")
+}
@@ -0,0 +1,134 @@
+/*
+Package enroll implements a simple ACME like protocol to automatically enroll devices and obtain
+smolcerts over HTTP(S).
+
+The protocol consists of three steps, all messages are CBOR encoded:
+
+ 1. The client creates an order for a subject (POST /orders) and receives a challenge
+ 2. The client answers the challenge (POST /orders/{id}/challenge), which is validated by a
+    pluggable ChallengeValidator on the server
+ 3. The client finalizes the order by sending a certificate request (POST /orders/{id}/finalize)
+    and receives the issued certificate
+*/
+package enroll
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+)
+
+// ContentType is the content type of all CBOR encoded protocol messages
+const ContentType = "application/cbor"
+
+// Status describes the state of an Order
+type Status string
+
+// Possible states of an Order
+const (
+	// StatusPending means the challenge of the order hasn't been answered yet
+	StatusPending Status = "pending"
+	// StatusReady means the challenge has been answered and the order can be finalized
+	StatusReady Status = "ready"
+	// StatusValid means the certificate has been issued
+	StatusValid Status = "valid"
+	// StatusInvalid means the challenge has failed, the order can't be used anymore
+	StatusInvalid Status = "invalid"
+)
+
+var (
+	cborEm cbor.EncMode
+
+	// ErrChallengeFailed is returned by ChallengeValidators if the answer to a challenge is wrong
+	ErrChallengeFailed = errors.New("challenge failed")
+)
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// NewOrderRequest is sent by the client to create a new Order
+type NewOrderRequest struct {
+	_ struct{} `cbor:",toarray"`
+
+	Subject string `cbor:"subject"`
+}
+
+// Challenge needs to be answered by the client to prove that it is allowed to obtain a certificate
+// for the subject of an Order.
+type Challenge struct {
+	_ struct{} `cbor:",toarray"`
+
+	Type  string `cbor:"type"`
+	Token []byte `cbor:"token"`
+}
+
+// ChallengeAnswer is the answer of the client to a Challenge
+type ChallengeAnswer struct {
+	_ struct{} `cbor:",toarray"`
+
+	Payload []byte `cbor:"payload"`
+}
+
+// Order represents the process of obtaining a certificate for a subject
+type Order struct {
+	_ struct{} `cbor:",toarray"`
+
+	ID        string        `cbor:"id"`
+	Subject   string        `cbor:"subject"`
+	Status    Status        `cbor:"status"`
+	Expires   smolcert.Time `cbor:"expires"`
+	Challenge Challenge     `cbor:"challenge"`
+	// Certificate contains the encoded certificate after the order has been finalized
+	Certificate []byte `cbor:"certificate"`
+}
+
+// ErrorResponse is returned by the server if a request fails
+type ErrorResponse struct {
+	_ struct{} `cbor:",toarray"`
+
+	Message string `cbor:"message"`
+}
+
+// ChallengeValidator validates the answer of a client to the challenge of an order. The order is
+// rejected if an error is returned.
+type ChallengeValidator func(order *Order, payload []byte) error
+
+// ChallengeTypePreSharedKey is the type of challenges answered with a key shared between device and server
+const ChallengeTypePreSharedKey = "psk-hmac-sha256"
+
+// PreSharedKeyValidator validates answers created via PreSharedKeyAnswer. keys returns the
+// key shared with the device for a subject, i.e. a provisioning secret installed during production.
+func PreSharedKeyValidator(keys func(subject string) ([]byte, bool)) ChallengeValidator {
+	return func(order *Order, payload []byte) error {
+		key, found := keys(order.Subject)
+		if !found {
+			return ErrChallengeFailed
+		}
+		if !hmac.Equal(payload, pskMAC(key, order.Subject, order.Challenge.Token)) {
+			return ErrChallengeFailed
+		}
+		return nil
+	}
+}
+
+// PreSharedKeyAnswer answers the challenge of order with a key shared with the server
+func PreSharedKeyAnswer(key []byte) func(order *Order) ([]byte, error) {
+	return func(order *Order) ([]byte, error) {
+		return pskMAC(key, order.Subject, order.Challenge.Token), nil
+	}
+}
+
+func pskMAC(key []byte, subject string, token []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(token)
+	mac.Write([]byte(subject))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,136 @@
+package enroll
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newTestServer(t *testing.T) (*smolcert.CertPool, *httptest.Server) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	keys := map[string][]byte{
+		"device1": []byte("provisioning secret"),
+	}
+	s := &Server{
+		Issuer:    rootCert.Subject,
+		IssuerKey: rootKey,
+		Validity:  time.Hour,
+		Extensions: []smolcert.Extension{
+			{OID: smolcert.OIDKeyUsage, Critical: true, Value: smolcert.KeyUsageClientIdentification.ToBytes()},
+		},
+		ChallengeType: ChallengeTypePreSharedKey,
+		ValidateChallenge: PreSharedKeyValidator(func(subject string) ([]byte, bool) {
+			key, found := keys[subject]
+			return key, found
+		}),
+	}
+	return smolcert.NewCertPool(rootCert), httptest.NewServer(s)
+}
+
+func TestEnrollment(t *testing.T) {
+	pool, srv := newTestServer(t)
+	defer srv.Close()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	c := &Client{BaseURL: srv.URL}
+	cert, err := c.Enroll(context.Background(), "device1", priv, PreSharedKeyAnswer([]byte("provisioning secret")))
+	require.NoError(t, err)
+	assert.Equal(t, "device1", cert.Subject)
+	assert.Equal(t, pub, cert.PubKey)
+	assert.NoError(t, pool.Validate(cert))
+	assert.NoError(t, smolcert.RequiresExtension(cert, smolcert.OIDKeyUsage,
+		smolcert.ExpectKeyUsage(smolcert.KeyUsageClientIdentification)))
+}
+
+func TestEnrollmentFailedChallenge(t *testing.T) {
+	_, srv := newTestServer(t)
+	defer srv.Close()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ctx := context.Background()
+	c := &Client{BaseURL: srv.URL}
+
+	_, err = c.Enroll(ctx, "device1", priv, PreSharedKeyAnswer([]byte("wrong secret")))
+	assert.Error(t, err)
+	_, err = c.Enroll(ctx, "device2", priv, PreSharedKeyAnswer([]byte("provisioning secret")))
+	assert.Error(t, err)
+
+	// Orders can't be finalized before the challenge has been answered
+	order, err := c.NewOrder(ctx, "device1")
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, order.Status)
+	csr, err := smolcert.NewCertificateRequest("device1", nil, priv)
+	require.NoError(t, err)
+	_, err = c.Finalize(ctx, order, csr)
+	assert.Error(t, err)
+
+	// Failed challenges invalidate the order
+	_, err = c.AnswerChallenge(ctx, order, []byte("wrong"))
+	assert.Error(t, err)
+	order, err = c.Order(ctx, order.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusInvalid, order.Status)
+}
+
+func TestEnrollmentSubjectMismatch(t *testing.T) {
+	_, srv := newTestServer(t)
+	defer srv.Close()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ctx := context.Background()
+	c := &Client{BaseURL: srv.URL}
+
+	order, err := c.NewOrder(ctx, "device1")
+	require.NoError(t, err)
+	payload, err := PreSharedKeyAnswer([]byte("provisioning secret"))(order)
+	require.NoError(t, err)
+	order, err = c.AnswerChallenge(ctx, order, payload)
+	require.NoError(t, err)
+	assert.Equal(t, StatusReady, order.Status)
+
+	csr, err := smolcert.NewCertificateRequest("device2", nil, priv)
+	require.NoError(t, err)
+	_, err = c.Finalize(ctx, order, csr)
+	assert.Error(t, err)
+}
+
+func TestEnrollmentLimitsOrders(t *testing.T) {
+	s := &Server{ChallengeType: ChallengeTypePreSharedKey, MaxOrders: 2}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	ctx := context.Background()
+	c := &Client{BaseURL: srv.URL}
+
+	for i := 0; i < 2; i++ {
+		_, err := c.NewOrder(ctx, "device1")
+		require.NoError(t, err)
+	}
+	_, err := c.NewOrder(ctx, "device1")
+	assert.Error(t, err)
+
+	// Expired orders make room for new ones
+	s.lock.Lock()
+	for _, order := range s.orders {
+		order.Expires = smolcert.NewTime(time.Now().Add(-time.Second))
+		break
+	}
+	s.lock.Unlock()
+	_, err = c.NewOrder(ctx, "device1")
+	assert.NoError(t, err)
+	s.lock.Lock()
+	assert.Len(t, s.orders, 2)
+	s.lock.Unlock()
+}
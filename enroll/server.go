@@ -0,0 +1,287 @@
+package enroll
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// maxRequestSize limits the size of request bodies accepted by the Server
+const maxRequestSize = 64 * 1024
+
+// defaultMaxOrders is the default of Server.MaxOrders
+const defaultMaxOrders = 10000
+
+// Server implements the server side of the enrollment protocol as http.Handler. It is expected to
+// be mounted at the root of a path, i.e. via http.StripPrefix.
+type Server struct {
+	// Issuer is the name of the issuer of issued certificates
+	Issuer string
+	// IssuerKey is used to sign issued certificates
+	IssuerKey ed25519.PrivateKey
+	// Validity is the validity of issued certificates
+	Validity time.Duration
+	// Extensions are added to every issued certificate, i.e. the KeyUsage. Extensions requested
+	// by clients are ignored.
+	Extensions []smolcert.Extension
+	// ChallengeType is announced to clients in new orders
+	ChallengeType string
+	// ValidateChallenge validates the answers of clients to their challenges
+	ValidateChallenge ChallengeValidator
+	// OrderLifetime is the time clients have to complete an order, defaults to 10 minutes
+	OrderLifetime time.Duration
+	// MaxOrders limits the number of orders kept until they expire, defaults to 10000. Orders
+	// can be created without authentication, so new orders are rejected once the limit is
+	// reached, instead of letting clients exhaust the memory of the server.
+	MaxOrders int
+
+	lock   sync.Mutex
+	orders map[string]*Order
+}
+
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func newHTTPError(status int, format string, a ...interface{}) error {
+	return &httpError{status: status, message: fmt.Sprintf(format, a...)}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "orders" {
+		writeError(w, newHTTPError(http.StatusNotFound, "Not found"))
+		return
+	}
+
+	var (
+		order *Order
+		err   error
+	)
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		order, err = s.newOrder(r)
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		order, err = s.order(parts[1])
+	case len(parts) == 3 && parts[2] == "challenge" && r.Method == http.MethodPost:
+		order, err = s.answerChallenge(parts[1], r)
+	case len(parts) == 3 && parts[2] == "finalize" && r.Method == http.MethodPost:
+		order, err = s.finalize(parts[1], r)
+	default:
+		err = newHTTPError(http.StatusNotFound, "Not found")
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeMessage(w, http.StatusOK, order)
+}
+
+func (s *Server) newOrder(r *http.Request) (*Order, error) {
+	req := &NewOrderRequest{}
+	if err := readMessage(r, req); err != nil {
+		return nil, err
+	}
+	if req.Subject == "" {
+		return nil, newHTTPError(http.StatusBadRequest, "Order needs to specify a subject")
+	}
+	id := make([]byte, 16)
+	token := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	lifetime := s.OrderLifetime
+	if lifetime == 0 {
+		lifetime = time.Minute * 10
+	}
+	order := &Order{
+		ID:      hex.EncodeToString(id),
+		Subject: req.Subject,
+		Status:  StatusPending,
+		Expires: smolcert.NewTime(time.Now().Add(lifetime)),
+		Challenge: Challenge{
+			Type:  s.ChallengeType,
+			Token: token,
+		},
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.orders == nil {
+		s.orders = make(map[string]*Order)
+	}
+	s.removeExpiredOrders()
+	maxOrders := s.MaxOrders
+	if maxOrders <= 0 {
+		maxOrders = defaultMaxOrders
+	}
+	if len(s.orders) >= maxOrders {
+		return nil, newHTTPError(http.StatusServiceUnavailable, "Too many pending orders, try again later")
+	}
+	s.orders[order.ID] = order
+	return copyOrder(order), nil
+}
+
+func (s *Server) order(id string) (*Order, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	order, err := s.lookupOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	return copyOrder(order), nil
+}
+
+func (s *Server) answerChallenge(id string, r *http.Request) (*Order, error) {
+	answer := &ChallengeAnswer{}
+	if err := readMessage(r, answer); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	order, err := s.lookupOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != StatusPending {
+		return nil, newHTTPError(http.StatusConflict, "Order is %s, the challenge can't be answered", order.Status)
+	}
+	if s.ValidateChallenge == nil {
+		return nil, newHTTPError(http.StatusInternalServerError, "No challenge validator configured")
+	}
+	if err := s.ValidateChallenge(copyOrder(order), answer.Payload); err != nil {
+		order.Status = StatusInvalid
+		return nil, newHTTPError(http.StatusForbidden, "Challenge failed: %s", err)
+	}
+	order.Status = StatusReady
+	return copyOrder(order), nil
+}
+
+func (s *Server) finalize(id string, r *http.Request) (*Order, error) {
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := smolcert.ParseCertificateRequest(body)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "Invalid certificate request: %s", err)
+	}
+	if err := csr.Verify(); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "%s", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	order, err := s.lookupOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != StatusReady {
+		return nil, newHTTPError(http.StatusConflict, "Order is %s and can't be finalized", order.Status)
+	}
+	if csr.Subject != order.Subject {
+		return nil, newHTTPError(http.StatusForbidden, "Certificate request for '%s' doesn't match the order subject '%s'",
+			csr.Subject, order.Subject)
+	}
+
+	cert, err := s.issue(csr)
+	if err != nil {
+		return nil, err
+	}
+	certBytes, err := cert.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	order.Certificate = certBytes
+	order.Status = StatusValid
+	return copyOrder(order), nil
+}
+
+func (s *Server) issue(csr *smolcert.CertificateRequest) (*smolcert.Certificate, error) {
+	return smolcert.Issue(csr.Subject, csr.PubKey, s.Issuer, s.IssuerKey,
+		smolcert.WithValidFor(s.Validity),
+		smolcert.WithExtensions(s.Extensions...))
+}
+
+// lookupOrder needs to be called with the lock held
+func (s *Server) lookupOrder(id string) (*Order, error) {
+	order, found := s.orders[id]
+	if !found || order.Expires.StdTime().Before(time.Now()) {
+		return nil, newHTTPError(http.StatusNotFound, "Order not found")
+	}
+	return order, nil
+}
+
+// removeExpiredOrders needs to be called with the lock held
+func (s *Server) removeExpiredOrders() {
+	now := time.Now()
+	for id, order := range s.orders {
+		if order.Expires.StdTime().Before(now) {
+			delete(s.orders, id)
+		}
+	}
+}
+
+func copyOrder(o *Order) *Order {
+	o2 := *o
+	return &o2
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestSize))
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "Failed to read request: %s", err)
+	}
+	return body, nil
+}
+
+func readMessage(r *http.Request, msg interface{}) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	if err := cbor.Unmarshal(body, msg); err != nil {
+		return newHTTPError(http.StatusBadRequest, "Invalid request: %s", err)
+	}
+	return nil
+}
+
+func writeMessage(w http.ResponseWriter, status int, msg interface{}) {
+	buf, err := cborEm.Marshal(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(buf)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var herr *httpError
+	if errors.As(err, &herr) {
+		status = herr.status
+	}
+	writeMessage(w, status, &ErrorResponse{Message: err.Error()})
+}
@@ -0,0 +1,51 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	randomSourceLock sync.RWMutex
+	randomSource     io.Reader = rand.Reader
+)
+
+// SetRandomSource sets the source of randomness used for keys, serial numbers and nonces which are
+// generated without an explicit source, i.e. a hardware RNG on embedded targets. nil restores
+// crypto/rand. It should be configured once during the initialization of the application. The
+// source needs to be safe for concurrent use and must never be deterministic outside of tests.
+func SetRandomSource(r io.Reader) {
+	randomSourceLock.Lock()
+	defer randomSourceLock.Unlock()
+	if r == nil {
+		r = rand.Reader
+	}
+	randomSource = r
+}
+
+func currentRandomSource() io.Reader {
+	randomSourceLock.RLock()
+	defer randomSourceLock.RUnlock()
+	return randomSource
+}
+
+// readRandom fills buf from r, or from the current random source if r is nil
+func readRandom(r io.Reader, buf []byte) error {
+	if r == nil {
+		r = currentRandomSource()
+	}
+	_, err := io.ReadFull(r, buf)
+	return err
+}
+
+// GenerateKey generates an ed25519 key pair from r, or from the random source set with
+// SetRandomSource if r is nil
+func GenerateKey(r io.Reader) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if r == nil {
+		r = currentRandomSource()
+	}
+	return ed25519.GenerateKey(r)
+}
@@ -0,0 +1,102 @@
+package smolcert
+
+import (
+	"bytes"
+	mathrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// zeroReader is a broken random source
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestGenerateKeyFromSource(t *testing.T) {
+	pub1, priv1, err := GenerateKey(mathrand.New(mathrand.NewSource(1)))
+	require.NoError(t, err)
+	pub2, priv2, err := GenerateKey(mathrand.New(mathrand.NewSource(1)))
+	require.NoError(t, err)
+	assert.Equal(t, pub1, pub2)
+	assert.Equal(t, priv1, priv2)
+	pub3, _, err := GenerateKey(nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, pub1, pub3)
+}
+
+func TestRandomSerialNumberFrom(t *testing.T) {
+	serial, err := RandomSerialNumberFrom(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2}))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0x0102), serial)
+	_, err = RandomSerialNumberFrom(bytes.NewReader([]byte{1, 2, 3}))
+	assert.Error(t, err)
+	_, err = RandomSerialNumberFrom(zeroReader{})
+	assert.Error(t, err)
+}
+
+func TestSetRandomSource(t *testing.T) {
+	defer SetRandomSource(nil)
+	create := func() (*Certificate, *Challenge) {
+		SetRandomSource(mathrand.New(mathrand.NewSource(42)))
+		cert, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+		require.NoError(t, err)
+		challenge, err := NewChallenge()
+		require.NoError(t, err)
+		return cert, challenge
+	}
+	cert1, challenge1 := create()
+	cert2, challenge2 := create()
+	assert.Equal(t, cert1.PubKey, cert2.PubKey)
+	assert.Equal(t, challenge1.Nonce, challenge2.Nonce)
+
+	SetRandomSource(nil)
+	cert3, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, cert1.PubKey, cert3.PubKey)
+}
+
+func TestCARandomSource(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	issue := func(opts ...IssueOption) []uint64 {
+		ca, err := NewCA(rootCert, rootKey)
+		require.NoError(t, err)
+		ca.Rand = mathrand.New(mathrand.NewSource(7))
+		var serials []uint64
+		for i := 0; i < 3; i++ {
+			cert, err := ca.Issue("device", pubKey, opts...)
+			require.NoError(t, err)
+			serials = append(serials, cert.SerialNumber)
+		}
+		return serials
+	}
+	assert.Equal(t, issue(), issue())
+	assert.NotEqual(t, issue(), issue(WithRandomSource(mathrand.New(mathrand.NewSource(8)))))
+
+	// A source repeating the same serial number fails instead of looping forever
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.Rand = zeroReader{}
+	_, err = ca.Issue("device", pubKey)
+	assert.Error(t, err)
+	ca.Options = []IssueOption{WithRandomSource(bytes.NewReader(bytes.Repeat([]byte{0, 0, 0, 0, 0, 0, 0, 1}, 20)))}
+	_, err = ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	_, err = ca.Issue("device", pubKey)
+	assert.Error(t, err)
+
+	cert, err := Issue("device", pubKey, "root", rootKey, WithRandomSource(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0, 9})))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), cert.SerialNumber)
+}
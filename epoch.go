@@ -0,0 +1,188 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// epochContext is prepended to signed epoch statements, so that their signatures can't be
+// mistaken for signatures of other structures.
+var epochContext = []byte("smolcert epoch statement")
+
+// IssuanceEpochExtension creates an Extension recording the epoch a certificate has been issued
+// in. Issuers advance their epoch to revoke all certificates of earlier epochs at once, without
+// listing their serial numbers in a CRL. The extension is not critical, verifiers which don't
+// check EpochStatements simply ignore it.
+func IssuanceEpochExtension(epoch uint64) (Extension, error) {
+	val, err := cborEm.Marshal(epoch)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDIssuanceEpoch,
+		Critical: false,
+		Value:    val,
+	}, nil
+}
+
+// ParseIssuanceEpoch parses the epoch from a byte slice, i.e. the Value of an Extension
+func ParseIssuanceEpoch(in []byte) (uint64, error) {
+	var epoch uint64
+	if err := cbor.Unmarshal(in, &epoch); err != nil {
+		return 0, fmt.Errorf("Failed to parse IssuanceEpoch: %w", err)
+	}
+	return epoch, nil
+}
+
+// IssuanceEpoch returns the epoch the certificate has been issued in. Certificates without
+// IssuanceEpoch extension belong to epoch 0.
+func (c *Certificate) IssuanceEpoch() (uint64, error) {
+	var epoch uint64
+	err := RequiresExtension(c, OIDIssuanceEpoch, func(critical bool, val []byte) (err error) {
+		epoch, err = ParseIssuanceEpoch(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return 0, nil
+	}
+	return epoch, err
+}
+
+// EpochStatement is signed by an issuer to announce its current epoch. Certificates of the issuer
+// which have been issued in an earlier epoch are revoked.
+type EpochStatement struct {
+	_ struct{} `cbor:",toarray"`
+
+	Issuer     string `cbor:"issuer"`
+	Epoch      uint64 `cbor:"epoch"`
+	ThisUpdate Time   `cbor:"this_update"`
+	NextUpdate Time   `cbor:"next_update"`
+	Signature  []byte `cbor:"signature"`
+}
+
+// NewEpochStatement creates an EpochStatement of issuer announcing epoch, which is valid for
+// validFor, and signs it with issuerKey
+func NewEpochStatement(issuer *Certificate, epoch uint64, validFor time.Duration,
+	issuerKey ed25519.PrivateKey) (*EpochStatement, error) {
+	return newEpochStatement(issuer, epoch, validFor, Ed25519Key(issuerKey))
+}
+
+func newEpochStatement(issuer *Certificate, epoch uint64, validFor time.Duration, signer Signer) (*EpochStatement, error) {
+	now := time.Now()
+	s := &EpochStatement{
+		Issuer:     issuer.Subject,
+		Epoch:      epoch,
+		ThisUpdate: NewTime(now),
+		NextUpdate: NewTime(now.Add(validFor)),
+	}
+	tbs, err := s.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if s.Signature, err = signWith(signer, tbs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseEpochStatement parses an EpochStatement from an existing byte buffer
+func ParseEpochStatement(buf []byte) (*EpochStatement, error) {
+	s := &EpochStatement{}
+	if err := cbor.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Bytes returns the CBOR encoded form of the EpochStatement
+func (s *EpochStatement) Bytes() ([]byte, error) {
+	return cborEm.Marshal(s)
+}
+
+func (s *EpochStatement) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&EpochStatement{
+		Issuer:     s.Issuer,
+		Epoch:      s.Epoch,
+		ThisUpdate: s.ThisUpdate,
+		NextUpdate: s.NextUpdate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, epochContext...), buf...), nil
+}
+
+// Verify checks that the EpochStatement is current and signed by issuer
+func (s *EpochStatement) Verify(issuer *Certificate) error {
+	if s.Issuer != issuer.Subject {
+		return fmt.Errorf("Epoch statement has been issued by '%s', not by '%s'", s.Issuer, issuer.Subject)
+	}
+	nowUnix := time.Now().Unix()
+	if int64(s.ThisUpdate) > nowUnix {
+		return fmt.Errorf("%w: epoch statement produced in the future at %s", ErrStaleRevocationStatus,
+			s.ThisUpdate.StdTime().Format(time.RFC3339))
+	}
+	if int64(s.NextUpdate) < nowUnix {
+		return fmt.Errorf("%w: epoch statement expired at %s", ErrStaleRevocationStatus,
+			s.NextUpdate.StdTime().Format(time.RFC3339))
+	}
+	tbs, err := s.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(issuer.PubKey, tbs, s.Signature) {
+		return newValidationError(ErrBadSignature, issuer, "Signature validation of epoch statement failed")
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrRevoked if cert has been issued before the epoch of the
+// statement. The statement needs to be verified before.
+func (s *EpochStatement) Check(cert *Certificate) error {
+	if s.Issuer != cert.Issuer {
+		return fmt.Errorf("Epoch statement of '%s' doesn't cover certificates issued by '%s'", s.Issuer, cert.Issuer)
+	}
+	epoch, err := cert.IssuanceEpoch()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Invalid IssuanceEpoch: %s", err)
+	}
+	if epoch < s.Epoch {
+		return newValidationError(ErrRevoked, cert, "certificate has been issued in epoch %d, but '%s' is in epoch %d",
+			epoch, s.Issuer, s.Epoch)
+	}
+	return nil
+}
+
+// EpochHook returns a ValidationHook rejecting certificates issued before the current epoch of
+// their issuer. Every statement is verified against the issuer of the certificates it applies to,
+// certificates of issuers without statement are not restricted.
+func EpochHook(statements ...*EpochStatement) ValidationHook {
+	statements = append([]*EpochStatement{}, statements...)
+	return func(cert, issuer *Certificate) error {
+		if cert == issuer || (cert.Subject == issuer.Subject && bytes.Equal(cert.PubKey, issuer.PubKey)) {
+			// Roots can't be revoked by themselves
+			return nil
+		}
+		for _, s := range statements {
+			if s.Issuer != issuer.Subject {
+				continue
+			}
+			if err := s.Verify(issuer); err != nil {
+				if errors.Is(err, ErrBadSignature) {
+					// Statement of another issuer with the same name
+					continue
+				}
+				return err
+			}
+			if err := s.Check(cert); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
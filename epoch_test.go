@@ -0,0 +1,98 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestEpochRevocation(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	legacy, err := ca.Issue("legacy", pubKey)
+	require.NoError(t, err)
+	epoch, err := legacy.IssuanceEpoch()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), epoch)
+	ca.Epoch = 1
+	first, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	epoch, err = first.IssuanceEpoch()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), epoch)
+
+	statement, err := ca.GenerateEpochStatement()
+	require.NoError(t, err)
+	require.NoError(t, statement.Verify(rootCert))
+	validator := NewValidator(NewCertPool(rootCert), EpochHook(statement))
+	assert.NoError(t, validator.Validate(first))
+	assert.True(t, errors.Is(validator.Validate(legacy), ErrRevoked))
+
+	// Advancing the epoch revokes all certificates of the previous epochs
+	ca.Epoch = 2
+	second, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	statement, err = ca.GenerateEpochStatement()
+	require.NoError(t, err)
+	buf, err := statement.Bytes()
+	require.NoError(t, err)
+	statement, err = ParseEpochStatement(buf)
+	require.NoError(t, err)
+	validator = NewValidator(NewCertPool(rootCert), EpochHook(statement))
+	assert.NoError(t, validator.Validate(second))
+	assert.True(t, errors.Is(validator.Validate(first), ErrRevoked))
+	assert.NoError(t, statement.Check(second))
+	assert.True(t, errors.Is(statement.Check(first), ErrRevoked))
+
+	// Statements of other issuers don't apply, even if they have the same name
+	otherCert, otherKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	forged, err := NewEpochStatement(otherCert, 5, time.Hour, otherKey)
+	require.NoError(t, err)
+	assert.Error(t, forged.Verify(rootCert))
+	validator = NewValidator(NewCertPool(rootCert), EpochHook(forged))
+	assert.NoError(t, validator.Validate(second))
+}
+
+func TestEpochStatementVerify(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	otherCert, _, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	statement, err := NewEpochStatement(rootCert, 1, time.Hour, rootKey)
+	require.NoError(t, err)
+	assert.NoError(t, statement.Verify(rootCert))
+	assert.Error(t, statement.Verify(otherCert))
+
+	expired, err := NewEpochStatement(rootCert, 1, -time.Hour, rootKey)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(expired.Verify(rootCert), ErrStaleRevocationStatus))
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := Issue("device", pubKey, "root", rootKey)
+	require.NoError(t, err)
+	err = NewValidator(NewCertPool(rootCert), EpochHook(expired)).Validate(cert)
+	assert.True(t, errors.Is(err, ErrRejectedByHook))
+
+	statement.Epoch = 0
+	assert.True(t, errors.Is(statement.Verify(rootCert), ErrBadSignature))
+
+	ext, err := IssuanceEpochExtension(7)
+	require.NoError(t, err)
+	assert.False(t, ext.Critical)
+	epoch, err := ParseIssuanceEpoch(ext.Value)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), epoch)
+	_, err = ParseIssuanceEpoch([]byte{0x60})
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reasons why the validation of a certificate can fail. Errors returned by the validation functions
+// can be checked against these via errors.Is.
+var (
+	// ErrExpired indicates that the NotAfter time of a certificate has passed
+	ErrExpired = errors.New("certificate has expired")
+	// ErrNotYetValid indicates that the NotBefore time of a certificate is in the future
+	ErrNotYetValid = errors.New("certificate is not yet valid")
+	// ErrUnknownIssuer indicates that the issuer of a certificate couldn't be found
+	ErrUnknownIssuer = errors.New("certificate is signed by an unknown issuer")
+	// ErrBadSignature indicates that the signature of a certificate is invalid
+	ErrBadSignature = errors.New("certificate has an invalid signature")
+	// ErrUntrustedRoot indicates that a chain of certificates doesn't end in a trusted root certificate
+	ErrUntrustedRoot = errors.New("certificate chain has no trusted root")
+	// ErrInvalidKeyUsage indicates that a certificate is used for something its KeyUsage doesn't allow
+	ErrInvalidKeyUsage = errors.New("certificate has an invalid KeyUsage")
+	// ErrDuplicateExtension indicates that a certificate contains the same extension more than once
+	ErrDuplicateExtension = errors.New("certificate contains a repeated extension")
+	// ErrUnknownCriticalExtension indicates that a certificate contains a critical extension which is
+	// not understood
+	ErrUnknownCriticalExtension = errors.New("certificate contains an unknown critical extension")
+	// ErrMalformedCertificate indicates that a certificate can't be encoded or decoded
+	ErrMalformedCertificate = errors.New("certificate is malformed")
+	// ErrNameConstraintViolation indicates that a certificate has a name its issuer is not allowed to sign
+	ErrNameConstraintViolation = errors.New("certificate violates the name constraints of its issuer")
+	// ErrCapabilityViolation indicates that a certificate has Capabilities its issuer doesn't hold
+	ErrCapabilityViolation = errors.New("certificate has capabilities not granted by its issuer")
+	// ErrRevoked indicates that a certificate has been revoked by its issuer
+	ErrRevoked = errors.New("certificate has been revoked")
+	// ErrUnboundedValidity indicates that a certificate has no NotBefore or NotAfter time, although
+	// the validation requires a bounded validity
+	ErrUnboundedValidity = errors.New("certificate has an unbounded validity")
+	// ErrUnsupportedVersion indicates that a certificate uses a version of the format which is not
+	// supported by this package
+	ErrUnsupportedVersion = errors.New("certificate has an unsupported version")
+	// ErrRejectedByHook indicates that a ValidationHook vetoed a certificate
+	ErrRejectedByHook = errors.New("certificate has been rejected by a validation hook")
+	// ErrUnsupportedAlgorithm indicates that a certificate is signed with an algorithm which is not supported
+	ErrUnsupportedAlgorithm = errors.New("certificate is signed with an unsupported algorithm")
+	// ErrThresholdNotMet indicates that a certificate has fewer valid signatures than required by
+	// the ThresholdPolicy of its issuer
+	ErrThresholdNotMet = errors.New("certificate has not been signed by enough issuer keys")
+	// ErrPolicyViolation indicates that a certificate violates the IssuerPolicy of an issuer above it
+	ErrPolicyViolation = errors.New("certificate violates the policy of its issuer")
+	// ErrAudienceMismatch indicates that a certificate is not intended for the verifying service
+	ErrAudienceMismatch = errors.New("certificate is not intended for this audience")
+	// ErrNameMismatch indicates that a certificate is not valid for the address or name of a peer
+	ErrNameMismatch = errors.New("certificate is not valid for this name")
+)
+
+// ValidationError is returned if a certificate fails validation. Reason is one of the Err* errors
+// of this package, so callers can use errors.Is to branch on the cause and errors.As to find out
+// which certificate failed.
+type ValidationError struct {
+	// Reason is the cause of the validation failure
+	Reason error
+	// Subject is the subject of the certificate failing validation
+	Subject string
+	// Message is a human readable description of the failure
+	Message string
+}
+
+func newValidationError(reason error, cert *Certificate, format string, a ...interface{}) *ValidationError {
+	return &ValidationError{
+		Reason:  reason,
+		Subject: cert.Subject,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+// Error returns the description of the validation failure
+func (v *ValidationError) Error() string {
+	return v.Message
+}
+
+// Unwrap returns the Reason of this ValidationError
+func (v *ValidationError) Unwrap() error {
+	return v.Reason
+}
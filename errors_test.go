@@ -0,0 +1,61 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationErrorReasons(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, otherKey, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	expired, _, err := ClientCertificate("expired", 2, now.Add(-time.Hour), now.Add(-time.Minute), nil, rootKey, "root")
+	require.NoError(t, err)
+	notYetValid, _, err := ClientCertificate("future", 3, now.Add(time.Minute), now.Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+	unknownIssuer, _, err := ClientCertificate("unknown", 4, time.Time{}, time.Time{}, nil, otherKey, "other")
+	require.NoError(t, err)
+	badSignature, _, err := ClientCertificate("forged", 5, time.Time{}, time.Time{}, nil, otherKey, "root")
+	require.NoError(t, err)
+
+	testTable := []struct {
+		cert   *Certificate
+		reason error
+	}{
+		{cert: expired, reason: ErrExpired},
+		{cert: notYetValid, reason: ErrNotYetValid},
+		{cert: unknownIssuer, reason: ErrUnknownIssuer},
+		{cert: badSignature, reason: ErrBadSignature},
+	}
+
+	for _, tt := range testTable {
+		err := pool.Validate(tt.cert)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, tt.reason), "expected %v, got %v", tt.reason, err)
+
+		var validationErr *ValidationError
+		require.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, tt.cert.Subject, validationErr.Subject)
+	}
+}
+
+func TestValidationErrorUntrustedRoot(t *testing.T) {
+	rootCert, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, imKey, err := SelfSignedCertificate("intermediate", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	clientCert, _, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, imKey, intermediate.Subject)
+	require.NoError(t, err)
+
+	_, err = NewCertPool(rootCert).ValidateBundle([]*Certificate{intermediate, clientCert})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrUntrustedRoot))
+}
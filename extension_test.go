@@ -121,4 +121,16 @@ func TestEnsureExtension(t *testing.T) {
 		extensions := ensureExtension(tt.extensions, tt.ensured)
 		assert.Len(t, extensions, tt.expectedCount, "Test iteration %d", i)
 	}
+
+	// The extensions of the caller are not modified
+	extensions := []Extension{{OID: OIDKeyUsage, Critical: false, Value: KeyUsageClientIdentification.ToBytes()}}
+	ensured := ensureExtension(extensions, Extension{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()})
+	assert.Equal(t, KeyUsageSignCert.ToBytes(), ensured[0].Value)
+	assert.False(t, extensions[0].Critical)
+	assert.Equal(t, KeyUsageClientIdentification.ToBytes(), extensions[0].Value)
+
+	withCapacity := make([]Extension, 1, 2)
+	withCapacity[0] = Extension{OID: 12}
+	ensureExtension(withCapacity, Extension{OID: 14})
+	assert.Equal(t, Extension{}, withCapacity[:2][1])
 }
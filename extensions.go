@@ -3,11 +3,40 @@ package smolcert
 import (
 	"errors"
 	"fmt"
+	"sync"
 )
 
 const (
 	// OIDKeyUsage specifies a KeyUsage extension. The ID right is arbitrary, we need to find a system...
 	OIDKeyUsage uint64 = 0x10
+	// OIDNextKey specifies a NextKey extension, announcing the key the subject will use next
+	OIDNextKey uint64 = 0x11
+	// OIDDelegation specifies a Delegation extension, allowing the subject to issue short lived credentials
+	OIDDelegation uint64 = 0x12
+	// OIDThreshold specifies a ThresholdPolicy extension, requiring k of n keys to sign issued certificates
+	OIDThreshold uint64 = 0x13
+	// OIDPostQuantumKey specifies a PostQuantumKey extension, carrying a post-quantum key for hybrid signatures
+	OIDPostQuantumKey uint64 = 0x14
+	// OIDNameConstraints specifies a NameConstraints extension, restricting the names an issuer may sign
+	OIDNameConstraints uint64 = 0x15
+	// OIDSubjectAttributes specifies a SubjectAttributes extension, carrying a structured subject
+	OIDSubjectAttributes uint64 = 0x16
+	// OIDCapabilities specifies a Capabilities extension, listing the rights granted to the subject
+	OIDCapabilities uint64 = 0x17
+	// OIDCaveats specifies a Caveats extension, restricting the use of a certificate to certain requests
+	OIDCaveats uint64 = 0x18
+	// OIDCRLDistributionPoints specifies a CRLDistributionPoints extension, listing where the CRL of the issuer can be fetched
+	OIDCRLDistributionPoints uint64 = 0x19
+	// OIDHolder specifies a Holder extension, naming the certificate an attribute certificate belongs to
+	OIDHolder uint64 = 0x1A
+	// OIDIssuerPolicy specifies an IssuerPolicy extension, restricting all certificates below an issuer
+	OIDIssuerPolicy uint64 = 0x1B
+	// OIDAudience specifies an Audience extension, naming the services a certificate is intended for
+	OIDAudience uint64 = 0x1C
+	// OIDIssuanceEpoch specifies an IssuanceEpoch extension, recording the epoch of the issuer a certificate belongs to
+	OIDIssuanceEpoch uint64 = 0x1D
+	// OIDSubjectAltNames specifies a SubjectAltNames extension, carrying additional names of the subject
+	OIDSubjectAltNames uint64 = 0x1E
 )
 
 // Extension represents a Certificate Extension as specified for X.509 certificates
@@ -19,6 +48,57 @@ type Extension struct {
 	Value    []byte `cbor:"value"`
 }
 
+// MarshalCBOR encodes the extension as array of OID, critical flag and value
+func (e Extension) MarshalCBOR() ([]byte, error) {
+	return e.appendCBOR(nil), nil
+}
+
+func (e *Extension) appendCBOR(buf []byte) []byte {
+	buf = appendHead(buf, majorArray, 3)
+	buf = appendHead(buf, majorUint, e.OID)
+	buf = appendBool(buf, e.Critical)
+	return appendBytes(buf, e.Value)
+}
+
+// UnmarshalCBOR decodes an extension encoded by MarshalCBOR
+func (e *Extension) UnmarshalCBOR(data []byte) error {
+	return decodeItem(data, e.decode)
+}
+
+func (e *Extension) decode(d *cborDecoder) error {
+	items, null, err := d.array()
+	if err != nil || null {
+		return err
+	}
+	if len(items) != 3 {
+		return fmt.Errorf("cbor: cannot decode CBOR array of %d elements into an extension", len(items))
+	}
+	for i, decode := range []func(d *cborDecoder) error{
+		func(d *cborDecoder) (err error) { e.OID, err = d.uint(); return },
+		func(d *cborDecoder) (err error) { e.Critical, err = d.bool(); return },
+		func(d *cborDecoder) (err error) { e.Value, err = d.bytes(); return },
+	} {
+		if err := decodeItem(items[i], decode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *cborDecoder) extensions() ([]Extension, error) {
+	items, null, err := d.array()
+	if err != nil || null {
+		return nil, err
+	}
+	extensions := make([]Extension, len(items))
+	for i := range items {
+		if err := decodeItem(items[i], extensions[i].decode); err != nil {
+			return nil, err
+		}
+	}
+	return extensions, nil
+}
+
 // KeyUsage limits for what the public key in a certificate can be used. Certain KeyUsages may be
 // required for certain tasks (i.e. certificate validation, client identification etc.), but every
 // Certificate can only specify one KeyUsage.
@@ -29,6 +109,7 @@ const (
 	KeyUsageClientIdentification KeyUsage = 0x01
 	KeyUsageServerIdentification KeyUsage = 0x02
 	KeyUsageSignCert             KeyUsage = 0x03
+	KeyUsageTimestamping         KeyUsage = 0x04
 )
 
 // ToBytes returns the byte representation of a KeyUsage to be used as Value in an Extension
@@ -45,6 +126,8 @@ func (k KeyUsage) String() string {
 		return "KeyUsageServerIdentification"
 	case KeyUsageSignCert:
 		return "KeyUsageSignCert"
+	case KeyUsageTimestamping:
+		return "KeyUsageTimestamping"
 	default:
 		return "Unknown KeyUsage"
 	}
@@ -64,6 +147,35 @@ var (
 	ErrorExtensionNotFound = errors.New("Required extension not found")
 )
 
+var (
+	criticalExtensionsLock sync.RWMutex
+	criticalExtensions     = map[uint64]bool{
+		OIDKeyUsage:        true,
+		OIDThreshold:       true,
+		OIDNameConstraints: true,
+		OIDCapabilities:    true,
+		OIDCaveats:         true,
+		OIDHolder:          true,
+		OIDIssuerPolicy:    true,
+		OIDAudience:        true,
+	}
+)
+
+// RegisterCriticalExtension declares that the extension with the given OID is understood by the
+// application and may be marked critical. Certificates containing critical extensions which are
+// neither defined by this package nor registered fail validation.
+func RegisterCriticalExtension(oid uint64) {
+	criticalExtensionsLock.Lock()
+	defer criticalExtensionsLock.Unlock()
+	criticalExtensions[oid] = true
+}
+
+func isKnownCriticalExtension(oid uint64) bool {
+	criticalExtensionsLock.RLock()
+	defer criticalExtensionsLock.RUnlock()
+	return criticalExtensions[oid]
+}
+
 // ValidateExtension is the definition of functions which can't be used with RequiresExtension to validate the
 // the Value and Critical flag of an Extension
 type ValidateExtension func(critical bool, val []byte) error
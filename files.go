@@ -0,0 +1,136 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Permissions of the files written by this package
+const (
+	// CertFileMode is the mode of certificate and pool files, which may be read by everyone
+	CertFileMode os.FileMode = 0644
+	// KeyFileMode is the mode of private key files, which may only be read by their owner
+	KeyFileMode os.FileMode = 0600
+)
+
+// WriteFileAtomic writes data to filename, so that filename contains either its previous content
+// or data, even if the process or system crashes. data is written to a temporary file in the same
+// directory, which is synced to disk and then renamed to filename. The file gets the mode perm.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	// The temporary file is created with mode 0600, so the data is never readable by others
+	f, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), filename); err != nil {
+		return err
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir syncs the directory entries of dir, so a rename survives a crash. Not all platforms
+// support this, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// WriteCertFile atomically writes a single certificate, or a Bundle of the certificate followed by
+// its intermediates, to filename with CertFileMode
+func WriteCertFile(filename string, certs ...*Certificate) error {
+	var data []byte
+	var err error
+	switch len(certs) {
+	case 0:
+		return errors.New("No certificate to write")
+	case 1:
+		data, err = certs[0].Bytes()
+	default:
+		data, err = NewBundle(certs...).Bytes()
+	}
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, data, CertFileMode)
+}
+
+// LoadCertFile reads a file written by WriteCertFile. It returns the certificate followed by its
+// intermediates, if the file contains a Bundle.
+func LoadCertFile(filename string) ([]*Certificate, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseCertFile(buf)
+}
+
+func parseCertFile(buf []byte) ([]*Certificate, error) {
+	cert, err := ParseBuf(buf)
+	if err == nil {
+		return []*Certificate{cert}, nil
+	}
+	bundle, bundleErr := ParseBundleBuf(buf)
+	if bundleErr != nil {
+		return nil, fmt.Errorf("Failed to parse certificate file: %w", err)
+	}
+	if len(bundle.Certificates) == 0 {
+		return nil, errors.New("Certificate file contains no certificate")
+	}
+	return bundle.Certificates, nil
+}
+
+// WriteKeyFile atomically writes the PEM encoded private key to filename with KeyFileMode. The key
+// can be read with LoadPrivateKey.
+func WriteKeyFile(filename string, key ed25519.PrivateKey) error {
+	data, err := MarshalPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, data, KeyFileMode)
+}
+
+// WritePoolFile atomically writes the certificates of pool to filename with CertFileMode. The pool
+// can be read with LoadPool.
+func WritePoolFile(filename string, pool *CertPool) error {
+	buf := &bytes.Buffer{}
+	if err := pool.Save(buf); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, buf.Bytes(), CertFileMode)
+}
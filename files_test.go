@@ -0,0 +1,98 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smolcert-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "data")
+
+	require.NoError(t, WriteFileAtomic(filename, []byte("first"), 0640))
+	require.NoError(t, WriteFileAtomic(filename, []byte("second"), 0640))
+	data, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(data))
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filename)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+
+	// No temporary files are left behind, also if writing fails
+	assert.Error(t, WriteFileAtomic(filepath.Join(dir, "missing", "data"), []byte("data"), 0640))
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "data", entries[0].Name())
+}
+
+func TestWriteCertAndKeyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smolcert-files")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	deviceCert, deviceKey, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+
+	require.NoError(t, WriteCertFile(filepath.Join(dir, "root.cbor"), rootCert))
+	certs, err := LoadCertFile(filepath.Join(dir, "root.cbor"))
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	assert.Equal(t, rootCert.Signature, certs[0].Signature)
+
+	require.NoError(t, WriteCertFile(filepath.Join(dir, "device.cbor"), deviceCert, intermediate))
+	certs, err = LoadCertFile(filepath.Join(dir, "device.cbor"))
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	_, err = NewBundle(certs...).Validate(NewCertPool(rootCert))
+	assert.NoError(t, err)
+	assert.Error(t, WriteCertFile(filepath.Join(dir, "empty.cbor")))
+
+	require.NoError(t, WriteKeyFile(filepath.Join(dir, "device.pem"), deviceKey))
+	key, err := LoadPrivateKey(filepath.Join(dir, "device.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, deviceKey, key)
+
+	require.NoError(t, WritePoolFile(filepath.Join(dir, "pool.cbor"), NewCertPool(rootCert)))
+	f, err := os.Open(filepath.Join(dir, "pool.cbor"))
+	require.NoError(t, err)
+	defer f.Close()
+	pool, err := LoadPool(f)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(intermediate))
+
+	if runtime.GOOS != "windows" {
+		for filename, mode := range map[string]os.FileMode{
+			"device.cbor": CertFileMode,
+			"device.pem":  KeyFileMode,
+			"pool.cbor":   CertFileMode,
+		} {
+			info, err := os.Stat(filepath.Join(dir, filename))
+			require.NoError(t, err)
+			assert.Equal(t, mode, info.Mode().Perm(), filename)
+		}
+	}
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "invalid.cbor"), []byte{0x80}, 0644))
+	_, err = LoadCertFile(filepath.Join(dir, "invalid.cbor"))
+	assert.Error(t, err)
+}
@@ -3,7 +3,12 @@ module github.com/smolcert/smolcert
 go 1.13
 
 require (
+	filippo.io/edwards25519 v1.1.0
 	github.com/fxamacker/cbor/v2 v2.2.0
+	github.com/prometheus/client_golang v1.7.1
 	github.com/stretchr/testify v1.4.0
+	go.etcd.io/bbolt v1.3.6
 	golang.org/x/crypto v0.0.0-20191122220453-ac88ee75c92c
+	golang.org/x/text v0.3.7
+	google.golang.org/grpc v1.29.1
 )
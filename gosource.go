@@ -0,0 +1,114 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GoSourceOptions configure the Go source generated by WriteGoSource
+type GoSourceOptions struct {
+	// Package is the name of the package of the generated file
+	Package string
+	// Func is the name of the generated function returning the CertPool, "Roots" by default
+	Func string
+	// Generator is named in the header of the generated file, "smolcert" by default. It may only
+	// contain printable characters.
+	Generator string
+}
+
+// WriteGoSource writes a Go source file to w, which embeds the root certificates and exposes
+// them as CertPool, so firmware and CLI binaries can compile in their trust anchors:
+//
+//	//go:generate go run github.com/smolcert/smolcert/cmd/smolcert-embed -o roots.go root.cert
+//
+// The generated function returns a new CertPool on every call, so callers may modify it. The
+// output only depends on the certificates, not on their order, so it can be checked in.
+func WriteGoSource(w io.Writer, opts GoSourceOptions, roots ...*Certificate) error {
+	if opts.Func == "" {
+		opts.Func = "Roots"
+	}
+	if opts.Generator == "" {
+		opts.Generator = "smolcert"
+	}
+	if !token.IsIdentifier(opts.Package) {
+		return fmt.Errorf("Invalid package name '%s'", opts.Package)
+	}
+	if !token.IsIdentifier(opts.Func) {
+		return fmt.Errorf("Invalid function name '%s'", opts.Func)
+	}
+	if !utf8.ValidString(opts.Generator) || strings.IndexFunc(opts.Generator, isNotPrint) >= 0 {
+		// A line break would end the header comment and inject code into the generated file
+		return fmt.Errorf("Invalid generator name %q", opts.Generator)
+	}
+	if len(roots) == 0 {
+		return errors.New("At least one root certificate is required")
+	}
+	pool := &CertPool{}
+	for _, root := range roots {
+		if !pool.Add(root) {
+			return fmt.Errorf("Certificate '%s' is not allowed to sign certificates", root.Subject)
+		}
+	}
+	encoded := &bytes.Buffer{}
+	if err := pool.Save(encoded); err != nil {
+		return err
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "// Code generated by %s. DO NOT EDIT.\n\npackage %s\n\n", opts.Generator, opts.Package)
+	fmt.Fprintf(src, "import (\n\t\"bytes\"\n\n\t\"github.com/smolcert/smolcert\"\n)\n\n")
+	fmt.Fprintf(src, "// %s returns a new CertPool of the embedded root certificates:\n//\n", opts.Func)
+	for _, root := range pool.All() {
+		fmt.Fprintf(src, "//\t%q (%s, %s)\n", root.Subject, PinFromCertificate(root), describeExpiry(root))
+	}
+	fmt.Fprintf(src, "func %s() *smolcert.CertPool {\n", opts.Func)
+	fmt.Fprintf(src, "\tpool, err := smolcert.LoadPool(bytes.NewReader(%sCBOR))\n", lowerFirst(opts.Func))
+	fmt.Fprintf(src, "\tif err != nil {\n\t\tpanic(\"Embedded root certificates are invalid: \" + err.Error())\n\t}\n")
+	fmt.Fprintf(src, "\treturn pool\n}\n\n")
+	fmt.Fprintf(src, "var %sCBOR = []byte{", lowerFirst(opts.Func))
+	for i, b := range encoded.Bytes() {
+		if i%16 == 0 {
+			src.WriteString("\n\t")
+		} else {
+			src.WriteString(" ")
+		}
+		fmt.Fprintf(src, "0x%02x,", b)
+	}
+	src.WriteString("\n}\n")
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return fmt.Errorf("Failed to format generated source: %w", err)
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+func isNotPrint(r rune) bool {
+	return !unicode.IsPrint(r)
+}
+
+func describeExpiry(cert *Certificate) string {
+	if cert.Validity == nil || cert.Validity.NotAfter.IsZero() {
+		return "never expires"
+	}
+	return "expires " + cert.Validity.NotAfter.StdTime().UTC().Format(time.RFC3339)
+}
+
+func lowerFirst(s string) string {
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		return s
+	}
+	return string(s[0]+'a'-'A') + s[1:]
+}
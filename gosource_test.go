@@ -0,0 +1,89 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// embeddedBytes extracts the byte slice literal assigned to name from the generated source
+func embeddedBytes(t *testing.T, src []byte, name string) []byte {
+	f, err := parser.ParseFile(token.NewFileSet(), "roots.go", src, parser.ParseComments)
+	require.NoError(t, err)
+	var buf []byte
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || spec.Names[0].Name != name {
+			return true
+		}
+		for _, elt := range spec.Values[0].(*ast.CompositeLit).Elts {
+			b, err := strconv.ParseUint(elt.(*ast.BasicLit).Value, 0, 8)
+			require.NoError(t, err)
+			buf = append(buf, byte(b))
+		}
+		return false
+	})
+	require.NotNil(t, buf, "%s not found", name)
+	return buf
+}
+
+func TestWriteGoSource(t *testing.T) {
+	root1, _, err := SelfSignedCertificate("root\n1", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	root2, _, err := SelfSignedCertificate("root2", time.Now(), time.Now().Add(time.Hour), nil)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteGoSource(buf, GoSourceOptions{Package: "trust", Func: "TrustAnchors"}, root1, root2))
+	src := buf.String()
+	assert.Contains(t, src, "// Code generated by smolcert. DO NOT EDIT.")
+	assert.Contains(t, src, "package trust")
+	assert.Contains(t, src, "func TrustAnchors() *smolcert.CertPool")
+	assert.Contains(t, src, PinFromCertificate(root2).String())
+	assert.Contains(t, src, `"root\n1"`)
+
+	pool, err := LoadPool(bytes.NewReader(embeddedBytes(t, buf.Bytes(), "trustAnchorsCBOR")))
+	require.NoError(t, err)
+	assert.Equal(t, NewCertPool(root1, root2), pool)
+
+	// The output doesn't depend on the order of the roots
+	reordered := &bytes.Buffer{}
+	require.NoError(t, WriteGoSource(reordered, GoSourceOptions{Package: "trust", Func: "TrustAnchors"}, root2, root1))
+	assert.Equal(t, buf.String(), reordered.String())
+
+	buf.Reset()
+	require.NoError(t, WriteGoSource(buf, GoSourceOptions{Package: "main"}, root1))
+	assert.Contains(t, buf.String(), "func Roots() *smolcert.CertPool")
+	buf.Reset()
+	require.NoError(t, WriteGoSource(buf, GoSourceOptions{Package: "main", Generator: "smolcert-embed v1.2"}, root1))
+	assert.Contains(t, buf.String(), "// Code generated by smolcert-embed v1.2. DO NOT EDIT.")
+
+	_, leafKey, err := SelfSignedCertificate("ca", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, leafKey, "ca")
+	require.NoError(t, err)
+	for name, c := range map[string]struct {
+		opts  GoSourceOptions
+		roots []*Certificate
+	}{
+		"package":  {GoSourceOptions{Package: "my-package"}, []*Certificate{root1}},
+		"func":     {GoSourceOptions{Package: "trust", Func: "1roots"}, []*Certificate{root1}},
+		"newline":  {GoSourceOptions{Package: "trust", Generator: "gen\nfunc init() { panic(1) }"}, []*Certificate{root1}},
+		"control":  {GoSourceOptions{Package: "trust", Generator: "gen\r"}, []*Certificate{root1}},
+		"utf8":     {GoSourceOptions{Package: "trust", Generator: "gen\xff"}, []*Certificate{root1}},
+		"no roots": {GoSourceOptions{Package: "trust"}, nil},
+		"leaf":     {GoSourceOptions{Package: "trust"}, []*Certificate{root1, leaf}},
+	} {
+		assert.Error(t, WriteGoSource(&bytes.Buffer{}, c.opts, c.roots...), name)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Package grpccreds provides gRPC transport credentials authenticating both peers with smolcerts.
+
+The credentials perform a TLS handshake with the smolcerts embedded into X.509 certificates as
+implemented by package tlscert. The validated certificate of the peer is available to handlers
+and interceptors via the AuthInfo of the peer:
+
+	creds := grpccreds.NewServerCredentials(tlsCert, pool)
+	server := grpc.NewServer(grpc.Creds(creds))
+
+	func (s *service) Call(ctx context.Context, req *Request) (*Response, error) {
+		cert, err := grpccreds.PeerCertificate(ctx)
+		...
+	}
+*/
+package grpccreds
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+
+	"github.com/smolcert/smolcert"
+	"github.com/smolcert/smolcert/tlscert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// AuthType is the AuthType of connections authenticated with smolcerts
+const AuthType = "smolcert"
+
+// AuthInfo is the AuthInfo of connections authenticated with smolcerts
+type AuthInfo struct {
+	credentials.TLSInfo
+	// Certificate is the validated certificate of the peer
+	Certificate *smolcert.Certificate
+}
+
+// AuthType implements credentials.AuthInfo
+func (a AuthInfo) AuthType() string {
+	return AuthType
+}
+
+type transportCredentials struct {
+	credentials.TransportCredentials
+}
+
+// NewServerCredentials creates credentials for a gRPC server authenticating itself with cert and
+// requiring clients to present smolcerts valid in pool. cert is usually created via
+// tlscert.Certificate.
+func NewServerCredentials(cert tls.Certificate, pool *smolcert.CertPool) credentials.TransportCredentials {
+	return &transportCredentials{credentials.NewTLS(tlscert.ServerConfig(cert, pool))}
+}
+
+// NewClientCredentials creates credentials for a gRPC client authenticating itself with cert and
+// requiring the server to present a smolcert valid in pool.
+func NewClientCredentials(cert tls.Certificate, pool *smolcert.CertPool) credentials.TransportCredentials {
+	return &transportCredentials{credentials.NewTLS(tlscert.ClientConfig(cert, pool))}
+}
+
+func (c *transportCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ClientHandshake(ctx, authority, rawConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return authenticated(conn, info)
+}
+
+func (c *transportCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	conn, info, err := c.TransportCredentials.ServerHandshake(rawConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return authenticated(conn, info)
+}
+
+func (c *transportCredentials) Clone() credentials.TransportCredentials {
+	return &transportCredentials{c.TransportCredentials.Clone()}
+}
+
+// authenticated replaces the TLSInfo of a connection with an AuthInfo carrying the certificate of
+// the peer. The certificate has already been validated by the VerifyPeerCertificate hook during
+// the handshake.
+func authenticated(conn net.Conn, info credentials.AuthInfo) (net.Conn, credentials.AuthInfo, error) {
+	tlsInfo, ok := info.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.PeerCertificates) == 0 {
+		conn.Close()
+		return nil, nil, errors.New("Peer didn't present a certificate")
+	}
+	bundle, err := tlscert.Bundle(tlsInfo.State.PeerCertificates[0])
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, AuthInfo{TLSInfo: tlsInfo, Certificate: bundle[0]}, nil
+}
+
+// PeerCertificate returns the validated certificate of the peer of a call, i.e. to be used in
+// handlers or authorization interceptors
+func PeerCertificate(ctx context.Context) (*smolcert.Certificate, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, errors.New("Context contains no peer information")
+	}
+	info, ok := p.AuthInfo.(AuthInfo)
+	if !ok {
+		return nil, errors.New("Peer isn't authenticated with a smolcert")
+	}
+	return info.Certificate, nil
+}
@@ -0,0 +1,97 @@
+package grpccreds
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/smolcert/smolcert/tlscert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func newTLSCert(t *testing.T, issue func(string, uint64, time.Time, time.Time, []smolcert.Extension,
+	ed25519.PrivateKey, string) (*smolcert.Certificate, ed25519.PrivateKey, error),
+	subject string, serial uint64, rootKey ed25519.PrivateKey) tls.Certificate {
+	cert, key, err := issue(subject, serial, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	tlsCert, err := tlscert.Certificate([]*smolcert.Certificate{cert}, key)
+	require.NoError(t, err)
+	return tlsCert
+}
+
+func startServer(t *testing.T, creds grpc.ServerOption) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer(creds, grpc.UnaryInterceptor(func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		cert, err := PeerCertificate(ctx)
+		if err != nil || cert.Subject != "client" {
+			return nil, status.Error(codes.PermissionDenied, "Access denied")
+		}
+		return handler(ctx, req)
+	}))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(l)
+	return l.Addr().String(), server.Stop
+}
+
+func check(addr string, creds grpc.DialOption) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, creds)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestCredentials(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := smolcert.NewCertPool(rootCert)
+
+	serverTLSCert := newTLSCert(t, smolcert.ServerCertificate, "server", 2, rootKey)
+	addr, stop := startServer(t, grpc.Creds(NewServerCredentials(serverTLSCert, pool)))
+	defer stop()
+
+	clientTLSCert := newTLSCert(t, smolcert.ClientCertificate, "client", 3, rootKey)
+	assert.NoError(t, check(addr, grpc.WithTransportCredentials(NewClientCredentials(clientTLSCert, pool))))
+
+	// Authenticated, but not authorized by the interceptor
+	otherTLSCert := newTLSCert(t, smolcert.ClientCertificate, "other", 4, rootKey)
+	err = check(addr, grpc.WithTransportCredentials(NewClientCredentials(otherTLSCert, pool)))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestUntrustedClient(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	otherRoot, otherKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	serverTLSCert := newTLSCert(t, smolcert.ServerCertificate, "server", 2, rootKey)
+	addr, stop := startServer(t, grpc.Creds(NewServerCredentials(serverTLSCert, smolcert.NewCertPool(rootCert))))
+	defer stop()
+
+	// The client trusts the server, but the server doesn't trust the client
+	clientTLSCert := newTLSCert(t, smolcert.ClientCertificate, "client", 3, otherKey)
+	clientPool := smolcert.NewCertPool(rootCert, otherRoot)
+	assert.Error(t, check(addr, grpc.WithTransportCredentials(NewClientCredentials(clientTLSCert, clientPool))))
+}
+
+func TestPeerCertificateWithoutPeer(t *testing.T) {
+	_, err := PeerCertificate(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,166 @@
+package smolcert
+
+import (
+	"context"
+	"time"
+)
+
+// ValidationHook is invoked for every certificate of a chain during validation, including the
+// root certificate, which is its own issuer. Returning an error vetoes the chain, i.e. if a device
+// is not found in an inventory or a subject violates a naming policy. Hooks are only called for
+// chains which passed all other checks, after their signatures have been verified. If a hook
+// vetoes a chain of a bundle, alternative chains of the bundle are tried and passed to the hooks
+// in turn. Errors are reported as ErrRejectedByHook, unless the hook returns a ValidationError,
+// i.e. with the Reason ErrRevoked.
+type ValidationHook func(cert, issuer *Certificate) error
+
+// ContextValidationHook is a ValidationHook receiving the context of the validation, i.e. to
+// query a revocation service with the deadline of the request. Returning the error of the context
+// aborts the validation with that error.
+type ContextValidationHook func(ctx context.Context, cert, issuer *Certificate) error
+
+func (hook ValidationHook) withContext() ContextValidationHook {
+	return func(ctx context.Context, cert, issuer *Certificate) error {
+		return hook(cert, issuer)
+	}
+}
+
+// Validator validates certificates against a CertPool like the validation functions of CertPool,
+// additionally calling the registered ValidationHooks for every certificate.
+type Validator struct {
+	Pool *CertPool
+	// Metrics optionally receives the results of all validations
+	Metrics Metrics
+	// Logger optionally receives an event for every validation
+	Logger Logger
+	// Options enable additional checks for the certificates of a chain
+	Options []VerifyOption
+	// Cache optionally remembers successful validations of Validate and ValidateBundle. Hooks
+	// aren't invoked for cached results, so it must not be combined with revocation hooks.
+	Cache *ValidationCache
+	hooks []ContextValidationHook
+}
+
+// NewValidator creates a new Validator for pool with the given hooks
+func NewValidator(pool *CertPool, hooks ...ValidationHook) *Validator {
+	v := &Validator{Pool: pool}
+	for _, hook := range hooks {
+		v.AddHook(hook)
+	}
+	return v
+}
+
+// AddHook registers an additional hook. A Validator must not be modified while it is in use.
+func (v *Validator) AddHook(hook ValidationHook) {
+	v.hooks = append(v.hooks, hook.withContext())
+}
+
+// AddContextHook registers an additional hook receiving the context of the validation
+func (v *Validator) AddContextHook(hook ContextValidationHook) {
+	v.hooks = append(v.hooks, hook)
+}
+
+// Validate validates cert like CertPool.Validate
+func (v *Validator) Validate(cert *Certificate) error {
+	return v.ValidateContext(context.Background(), cert)
+}
+
+// ValidateContext validates cert like CertPool.Validate. ctx is passed to the hooks, the
+// validation is aborted with the error of ctx once it is done.
+func (v *Validator) ValidateContext(ctx context.Context, cert *Certificate) (err error) {
+	cv := v.chainVerifier(ctx)
+	defer v.observe(time.Now(), cert, 1, cv, &err)
+	_, err = v.validateCached([]*Certificate{cert}, cv, func() (*Certificate, error) {
+		if err := v.Pool.validate(cert, cv); err != nil {
+			return nil, err
+		}
+		return cert, cv.finish()
+	})
+	if err != nil {
+		return err
+	}
+	return cv.opts.checkLeaf(cert)
+}
+
+// ValidateBundle validates a bundle of certificates like CertPool.ValidateBundle
+func (v *Validator) ValidateBundle(certBundle []*Certificate) (*Certificate, error) {
+	return v.ValidateBundleContext(context.Background(), certBundle)
+}
+
+// ValidateBundleContext validates a bundle of certificates like CertPool.ValidateBundle. ctx is
+// passed to the hooks, the validation is aborted with the error of ctx once it is done.
+func (v *Validator) ValidateBundleContext(ctx context.Context, certBundle []*Certificate) (clientCert *Certificate, err error) {
+	start, cv := time.Now(), v.chainVerifier(ctx)
+	var leaf *Certificate
+	if len(certBundle) > 0 {
+		leaf = certBundle[0]
+	}
+	defer func() {
+		if clientCert != nil {
+			leaf = clientCert
+		}
+		v.observe(start, leaf, len(certBundle), cv, &err)
+	}()
+	clientCert, err = v.validateCached(certBundle, cv, func() (*Certificate, error) {
+		return v.Pool.validateBundle(certBundle, cv)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cv.opts.checkLeaf(clientCert); err != nil {
+		return nil, err
+	}
+	return clientCert, nil
+}
+
+// validateCached returns the cached result for certs or validates them with validate
+func (v *Validator) validateCached(certs []*Certificate, cv *chainVerifier, validate func() (*Certificate, error)) (*Certificate, error) {
+	if v.Cache == nil {
+		return validate()
+	}
+	key, ok := v.Cache.key(v.Pool, certs)
+	if !ok {
+		return validate()
+	}
+	if entry, found := v.Cache.lookup(key); found {
+		cv.root = entry.root
+		return certs[entry.leaf], nil
+	}
+	clientCert, err := validate()
+	if err != nil {
+		return nil, err
+	}
+	for i, cert := range certs {
+		if cert == clientCert {
+			v.Cache.add(key, certs, i, cv.root)
+			break
+		}
+	}
+	return clientCert, nil
+}
+
+func (v *Validator) chainVerifier(ctx context.Context) *chainVerifier {
+	cv := &chainVerifier{ctx: ctx, hooks: v.hooks, opts: newVerifyOptions(v.Options)}
+	cv.batch.Concurrency = cv.opts.concurrency
+	return cv
+}
+
+func (v *Validator) observe(start time.Time, cert *Certificate, bundleSize int, cv *chainVerifier, err *error) {
+	if v.Metrics != nil {
+		v.Metrics.ObserveValidation(time.Since(start), *err)
+	}
+	if v.Logger == nil {
+		return
+	}
+	subject := ""
+	if cert != nil {
+		subject = cert.Subject
+	}
+	if *err != nil {
+		v.Logger.Warn("Certificate rejected", "subject", subject, "bundle_size", bundleSize,
+			"reason", FailureReason(*err), "error", (*err).Error())
+		return
+	}
+	v.Logger.Debug("Certificate validated", "subject", subject, "issuer", cert.Issuer, "bundle_size", bundleSize,
+		"root", cv.root.Subject, "root_pin", PinFromCertificate(cv.root).String())
+}
@@ -0,0 +1,141 @@
+package smolcert
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationHooks(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	interCert, interKey, err := SignedCertificate("site-a", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	known, _, err := ClientCertificate("site-a/device1", 3, time.Time{}, time.Time{}, nil, interKey, "site-a")
+	require.NoError(t, err)
+	unknown, _, err := ClientCertificate("site-a/device2", 4, time.Time{}, time.Time{}, nil, interKey, "site-a")
+	require.NoError(t, err)
+
+	inventory := map[string]bool{"site-a/device1": true}
+	var seen []string
+	v := NewValidator(NewCertPool(rootCert), func(cert, issuer *Certificate) error {
+		seen = append(seen, cert.Subject+"<"+issuer.Subject)
+		return nil
+	})
+	v.AddHook(func(cert, issuer *Certificate) error {
+		if issuer.Subject == "site-a" && !inventory[cert.Subject] {
+			return errors.New("device is not in the inventory")
+		}
+		return nil
+	})
+
+	leaf, err := v.ValidateBundle([]*Certificate{known, interCert})
+	require.NoError(t, err)
+	assert.Equal(t, known, leaf)
+	assert.Contains(t, seen, "site-a/device1<site-a")
+	assert.Contains(t, seen, "site-a<root")
+	assert.Contains(t, seen, "root<root")
+
+	_, err = v.ValidateBundle([]*Certificate{unknown, interCert})
+	assert.True(t, errors.Is(err, ErrRejectedByHook))
+	assert.True(t, strings.Contains(err.Error(), "inventory"))
+
+	// Without hooks the pool accepts the certificate
+	_, err = v.Pool.ValidateBundle([]*Certificate{unknown, interCert})
+	assert.NoError(t, err)
+
+	// Hooks also apply to certificates issued directly by a root
+	direct, _, err := ClientCertificate("other", 5, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	assert.NoError(t, v.Validate(direct))
+	v.AddHook(func(cert, issuer *Certificate) error {
+		if cert.Subject == "other" {
+			return errors.New("naming policy violated")
+		}
+		return nil
+	})
+	assert.True(t, errors.Is(v.Validate(direct), ErrRejectedByHook))
+}
+
+func TestValidationHooksOnlySeeVerifiedChains(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, forgedKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ku := []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}
+	interCert, interKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{}, ku, rootKey, "root")
+	require.NoError(t, err)
+	// Same subject and key, but not signed by the root
+	forgedInter, err := CrossSignCertificate(interCert, 3, "root", forgedKey)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 4, time.Time{}, time.Time{}, nil, interKey, "intermediate")
+	require.NoError(t, err)
+
+	var seen []*Certificate
+	v := NewValidator(NewCertPool(rootCert), func(cert, issuer *Certificate) error {
+		seen = append(seen, cert)
+		return nil
+	})
+	clientCert, err := v.ValidateBundle([]*Certificate{leaf, forgedInter, interCert})
+	require.NoError(t, err)
+	assert.Equal(t, leaf, clientCert)
+	assert.ElementsMatch(t, []*Certificate{leaf, interCert, rootCert}, seen)
+
+	// Chains with invalid signatures never reach the hooks
+	seen = nil
+	_, err = v.ValidateBundle([]*Certificate{leaf, forgedInter})
+	assert.True(t, errors.Is(err, ErrBadSignature))
+	assert.Empty(t, seen)
+}
+
+func TestContextValidationHooks(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	type traceKey struct{}
+	var traces []interface{}
+	v := NewValidator(NewCertPool(rootCert))
+	v.AddContextHook(func(ctx context.Context, cert, issuer *Certificate) error {
+		traces = append(traces, ctx.Value(traceKey{}))
+		return nil
+	})
+	ctx := context.WithValue(context.Background(), traceKey{}, "trace-1")
+	require.NoError(t, v.ValidateContext(ctx, leaf))
+	assert.Equal(t, []interface{}{"trace-1", "trace-1"}, traces)
+	clientCert, err := v.ValidateBundleContext(ctx, []*Certificate{leaf})
+	require.NoError(t, err)
+	assert.Equal(t, leaf, clientCert)
+	_, err = NewBundle(leaf).ValidateWithContext(ctx, v)
+	require.NoError(t, err)
+
+	// A hook running into the deadline aborts the validation with the error of the context
+	v.AddContextHook(func(ctx context.Context, cert, issuer *Certificate) error {
+		<-ctx.Done()
+		return errors.New("revocation service unreachable")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = v.ValidateContext(ctx, leaf)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Plain hooks are called while the context is not done
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	called := false
+	v = NewValidator(NewCertPool(rootCert), func(cert, issuer *Certificate) error {
+		called = true
+		return nil
+	})
+	assert.True(t, errors.Is(v.ValidateContext(ctx, leaf), context.Canceled))
+	assert.False(t, called)
+	assert.NoError(t, v.Validate(leaf))
+	assert.True(t, called)
+}
@@ -0,0 +1,161 @@
+/*
+Package httpauth provides net/http middleware authenticating clients with smolcerts.
+
+The certificate of a client is taken from the TLS handshake, if the server uses the configurations
+of package tlscert, or from a request header set by a trusted reverse proxy terminating TLS. After
+validation the certificate is available to handlers via FromContext:
+
+	auth := &httpauth.Middleware{Pool: pool}
+	http.Handle("/", auth.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, _ := httpauth.FromContext(r.Context())
+		fmt.Fprintf(w, "Hello %s", cert.Subject)
+	})))
+*/
+package httpauth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"github.com/smolcert/smolcert/tlscert"
+)
+
+// DefaultHeader is the conventional header to forward certificate bundles in
+const DefaultHeader = "Smolcert-Bundle"
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the authenticated certificate cert
+func NewContext(ctx context.Context, cert *smolcert.Certificate) context.Context {
+	return context.WithValue(ctx, contextKey{}, cert)
+}
+
+// FromContext returns the authenticated certificate of the client stored in ctx
+func FromContext(ctx context.Context) (*smolcert.Certificate, bool) {
+	cert, ok := ctx.Value(contextKey{}).(*smolcert.Certificate)
+	return cert, ok && cert != nil
+}
+
+// HeaderValue encodes a certificate bundle for the use in a request header. The certificate of the
+// client comes first, followed by intermediates if necessary.
+func HeaderValue(bundle []*smolcert.Certificate) (string, error) {
+	buf, err := cborEm.Marshal(bundle)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// ParseHeaderValue decodes a certificate bundle encoded via HeaderValue
+func ParseHeaderValue(value string) ([]*smolcert.Certificate, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid encoding of certificate bundle: %w", err)
+	}
+	var bundle []*smolcert.Certificate
+	if err := cbor.Unmarshal(buf, &bundle); err != nil {
+		return nil, fmt.Errorf("Invalid certificate bundle: %w", err)
+	}
+	if len(bundle) == 0 {
+		return nil, errors.New("Certificate bundle is empty")
+	}
+	return bundle, nil
+}
+
+// Middleware authenticates requests with smolcerts. Requests without a valid certificate are
+// rejected, the certificate of accepted requests is stored in the request context.
+type Middleware struct {
+	// Pool is used to validate the certificates of clients
+	Pool *smolcert.CertPool
+	// Validator is used instead of Pool if set, i.e. to apply ValidationHooks
+	Validator *smolcert.Validator
+	// Header is the request header to read the certificate bundle from, if no certificate has been
+	// presented during a TLS handshake. Headers can't prove the possession of the private key,
+	// so Header must only be set if the header is set by a trusted reverse proxy.
+	Header string
+	// KeyUsage is the KeyUsage required for client certificates. If zero,
+	// smolcert.KeyUsageClientIdentification is required.
+	KeyUsage smolcert.KeyUsage
+	// CheckRevocation is called for every validated certificate, returning an error rejects the
+	// request
+	CheckRevocation func(cert *smolcert.Certificate) error
+	// ErrorHandler is called for rejected requests. By default the request is answered with
+	// 401 Unauthorized.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// Handler wraps next, which is called for authenticated requests only
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cert, err := m.Authenticate(r)
+		if err != nil {
+			if m.ErrorHandler != nil {
+				m.ErrorHandler(w, r, err)
+			} else {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), cert)))
+	})
+}
+
+// Authenticate returns the validated certificate of the client of r
+func (m *Middleware) Authenticate(r *http.Request) (*smolcert.Certificate, error) {
+	bundle, err := m.bundle(r)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := m.validate(bundle)
+	if err != nil {
+		return nil, err
+	}
+	keyUsage := m.KeyUsage
+	if keyUsage == 0 {
+		keyUsage = smolcert.KeyUsageClientIdentification
+	}
+	if err := smolcert.RequiresExtension(cert, smolcert.OIDKeyUsage, smolcert.ExpectKeyUsage(keyUsage)); err != nil {
+		return nil, fmt.Errorf("Client certificate has an invalid KeyUsage: %w", err)
+	}
+	if m.CheckRevocation != nil {
+		if err := m.CheckRevocation(cert); err != nil {
+			return nil, fmt.Errorf("Client certificate has been revoked: %w", err)
+		}
+	}
+	return cert, nil
+}
+
+func (m *Middleware) bundle(r *http.Request) ([]*smolcert.Certificate, error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return tlscert.Bundle(r.TLS.PeerCertificates[0])
+	}
+	if m.Header != "" {
+		if value := r.Header.Get(m.Header); value != "" {
+			return ParseHeaderValue(value)
+		}
+	}
+	return nil, errors.New("Client didn't present a certificate")
+}
+
+func (m *Middleware) validate(bundle []*smolcert.Certificate) (*smolcert.Certificate, error) {
+	validator := m.Validator
+	if validator == nil {
+		validator = smolcert.NewValidator(m.Pool)
+	}
+	return smolcert.NewBundle(bundle...).ValidateWith(validator)
+}
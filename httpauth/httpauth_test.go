@@ -0,0 +1,133 @@
+package httpauth
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/smolcert/smolcert/tlscert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var helloHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	cert, ok := FromContext(r.Context())
+	if !ok {
+		http.Error(w, "No certificate", http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte("Hello " + cert.Subject))
+})
+
+func serve(t *testing.T, m *Middleware, bundle []*smolcert.Certificate) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if bundle != nil {
+		value, err := HeaderValue(bundle)
+		require.NoError(t, err)
+		req.Header.Set(DefaultHeader, value)
+	}
+	w := httptest.NewRecorder()
+	m.Handler(helloHandler).ServeHTTP(w, req)
+	return w
+}
+
+func TestHeaderAuthentication(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := smolcert.SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]smolcert.Extension{{OID: smolcert.OIDKeyUsage, Critical: true, Value: smolcert.KeyUsageSignCert.ToBytes()}},
+		rootKey, "root")
+	require.NoError(t, err)
+	clientCert, _, err := smolcert.ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	deviceCert, _, err := smolcert.ClientCertificate("device", 4, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	serverCert, _, err := smolcert.ServerCertificate("server", 5, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	m := &Middleware{Pool: smolcert.NewCertPool(rootCert), Header: DefaultHeader}
+
+	w := serve(t, m, []*smolcert.Certificate{clientCert})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello client", w.Body.String())
+	w = serve(t, m, []*smolcert.Certificate{deviceCert, intermediate})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Hello device", w.Body.String())
+
+	assert.Equal(t, http.StatusUnauthorized, serve(t, m, nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(t, m, []*smolcert.Certificate{deviceCert}).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(t, m, []*smolcert.Certificate{intermediate, deviceCert}).Code)
+	// Server certificates can't be used to authenticate clients
+	assert.Equal(t, http.StatusUnauthorized, serve(t, m, []*smolcert.Certificate{serverCert}).Code)
+
+	m.CheckRevocation = func(cert *smolcert.Certificate) error {
+		if cert.SerialNumber == clientCert.SerialNumber {
+			return errors.New("Serial number is revoked")
+		}
+		return nil
+	}
+	m.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+	assert.Equal(t, http.StatusForbidden, serve(t, m, []*smolcert.Certificate{clientCert}).Code)
+	assert.Equal(t, http.StatusOK, serve(t, m, []*smolcert.Certificate{deviceCert, intermediate}).Code)
+
+	// Headers are ignored unless configured
+	m.Header = ""
+	assert.Equal(t, http.StatusForbidden, serve(t, m, []*smolcert.Certificate{deviceCert, intermediate}).Code)
+}
+
+func TestValidationHooks(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	clientCert, _, err := smolcert.ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	m := &Middleware{
+		Validator: smolcert.NewValidator(smolcert.NewCertPool(rootCert), func(cert, issuer *smolcert.Certificate) error {
+			if cert.Subject == "client" {
+				return errors.New("Unknown device")
+			}
+			return nil
+		}),
+		Header: DefaultHeader,
+	}
+	assert.Equal(t, http.StatusUnauthorized, serve(t, m, []*smolcert.Certificate{clientCert}).Code)
+}
+
+func TestTLSAuthentication(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := smolcert.NewCertPool(rootCert)
+	serverCert, serverKey, err := smolcert.ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	clientCert, clientKey, err := smolcert.ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	serverTLSCert, err := tlscert.Certificate([]*smolcert.Certificate{serverCert}, serverKey)
+	require.NoError(t, err)
+	clientTLSCert, err := tlscert.Certificate([]*smolcert.Certificate{clientCert}, clientKey)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer((&Middleware{Pool: pool}).Handler(helloHandler))
+	srv.TLS = tlscert.ServerConfig(serverTLSCert, pool)
+	srv.StartTLS()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlscert.ClientConfig(clientTLSCert, pool)}}
+	resp, err := client.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Hello client", string(body))
+}
+
+func TestParseHeaderValue(t *testing.T) {
+	_, err := ParseHeaderValue("not base64!")
+	assert.Error(t, err)
+	_, err = ParseHeaderValue("gA")
+	assert.Error(t, err)
+}
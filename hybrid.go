@@ -0,0 +1,179 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Post-quantum algorithms which can be combined with ed25519 in hybrid signatures. Whether
+// signatures of these algorithms are verified depends on the registered PostQuantumVerifiers.
+const (
+	// AlgorithmMLDSA44 is ML-DSA-44 (FIPS 204)
+	AlgorithmMLDSA44 Algorithm = -48
+	// AlgorithmMLDSA65 is ML-DSA-65 (FIPS 204)
+	AlgorithmMLDSA65 Algorithm = -49
+	// AlgorithmMLDSA87 is ML-DSA-87 (FIPS 204)
+	AlgorithmMLDSA87 Algorithm = -50
+
+	// AlgorithmHybrid marks certificates carrying an ed25519 and a post-quantum signature. The value
+	// is taken from the COSE private use range.
+	AlgorithmHybrid Algorithm = -65537
+)
+
+// HybridSignature is the Signature of certificates with the SignatureAlgorithm AlgorithmHybrid. The
+// post-quantum signature is created with the key from the PostQuantumKey extension of the issuer.
+type HybridSignature struct {
+	_ struct{} `cbor:",toarray"`
+
+	Classical            []byte    `cbor:"classical"`
+	PostQuantumAlgorithm Algorithm `cbor:"post_quantum_algorithm"`
+	PostQuantum          []byte    `cbor:"post_quantum"`
+}
+
+// PostQuantumKey is the Value of a PostQuantumKey extension, carrying the post-quantum public key
+// of the subject in addition to its ed25519 key
+type PostQuantumKey struct {
+	_ struct{} `cbor:",toarray"`
+
+	Algorithm Algorithm `cbor:"algorithm"`
+	Key       []byte    `cbor:"key"`
+}
+
+// PostQuantumSigner creates post-quantum signatures for hybrid certificates
+type PostQuantumSigner interface {
+	// Algorithm returns the algorithm of the signatures
+	Algorithm() Algorithm
+	// Public returns the encoded public key
+	Public() []byte
+	// Sign signs message
+	Sign(message []byte) ([]byte, error)
+}
+
+// PostQuantumVerifier verifies post-quantum signatures
+type PostQuantumVerifier func(pubKey, message, sig []byte) bool
+
+var (
+	postQuantumVerifiersLock sync.RWMutex
+	postQuantumVerifiers     = make(map[Algorithm]PostQuantumVerifier)
+)
+
+// RegisterPostQuantumVerifier registers the verifier for the post-quantum algorithm alg. Hybrid
+// signatures of issuers with keys of algorithms without verifier are validated via their ed25519
+// signature only.
+func RegisterPostQuantumVerifier(alg Algorithm, verify PostQuantumVerifier) {
+	postQuantumVerifiersLock.Lock()
+	defer postQuantumVerifiersLock.Unlock()
+	postQuantumVerifiers[alg] = verify
+}
+
+func postQuantumVerifier(alg Algorithm) PostQuantumVerifier {
+	postQuantumVerifiersLock.RLock()
+	defer postQuantumVerifiersLock.RUnlock()
+	return postQuantumVerifiers[alg]
+}
+
+// PostQuantumKeyExtension creates an Extension announcing the post-quantum key of signer
+func PostQuantumKeyExtension(signer PostQuantumSigner) (Extension, error) {
+	val, err := cborEm.Marshal(&PostQuantumKey{Algorithm: signer.Algorithm(), Key: signer.Public()})
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID: OIDPostQuantumKey,
+		// Not critical, so that verifiers without post-quantum support can use the certificate
+		Critical: false,
+		Value:    val,
+	}, nil
+}
+
+// ParsePostQuantumKey parses a PostQuantumKey from a byte slice, i.e. the Value of an Extension
+func ParsePostQuantumKey(in []byte) (*PostQuantumKey, error) {
+	k := &PostQuantumKey{}
+	if err := cbor.Unmarshal(in, k); err != nil {
+		return nil, fmt.Errorf("Failed to parse PostQuantumKey: %w", err)
+	}
+	return k, nil
+}
+
+// ParseHybridSignature parses the HybridSignature of a certificate signed with AlgorithmHybrid
+func ParseHybridSignature(cert *Certificate) (*HybridSignature, error) {
+	if cert.SignatureAlgorithm != AlgorithmHybrid {
+		return nil, errors.New("Certificate doesn't carry a hybrid signature")
+	}
+	s := &HybridSignature{}
+	if err := cbor.Unmarshal(cert.Signature, s); err != nil {
+		return nil, fmt.Errorf("Failed to parse HybridSignature: %w", err)
+	}
+	return s, nil
+}
+
+// SignCertificateHybrid signs cert with the ed25519 key priv as well as with pqSigner. The issuer
+// certificate needs to contain the PostQuantumKey extension for pqSigner.
+func SignCertificateHybrid(cert *Certificate, priv ed25519.PrivateKey, pqSigner PostQuantumSigner) (*Certificate, error) {
+	cert.SignatureAlgorithm = AlgorithmHybrid
+	cert.Signature = nil
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return nil, err
+	}
+	pqSig, err := pqSigner.Sign(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create post-quantum signature: %w", err)
+	}
+	sig, err := cborEm.Marshal(&HybridSignature{
+		Classical:            ed25519.Sign(priv, certBytes),
+		PostQuantumAlgorithm: pqSigner.Algorithm(),
+		PostQuantum:          pqSig,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cert.Signature = sig
+	return cert, nil
+}
+
+// classicalSignature returns the ed25519 signature of cert. For hybrid certificates the post-quantum
+// signature is verified against the PostQuantumKey of issuer, if a verifier for the algorithm of this
+// key has been registered. The algorithm of the signature itself isn't signed, so it only needs to
+// match the one of the key.
+func classicalSignature(cert, issuer *Certificate) ([]byte, error) {
+	if cert.SignatureAlgorithm != AlgorithmHybrid {
+		return cert.Signature, nil
+	}
+	sig, err := ParseHybridSignature(cert)
+	if err != nil {
+		return nil, newValidationError(ErrMalformedCertificate, cert, "%s", err)
+	}
+
+	var key *PostQuantumKey
+	err = RequiresExtension(issuer, OIDPostQuantumKey, func(critical bool, val []byte) (err error) {
+		key, err = ParsePostQuantumKey(val)
+		return
+	})
+	if err != nil {
+		return nil, newValidationError(ErrMalformedCertificate, issuer, "Issuer of hybrid certificate has no valid post-quantum key: %s", err)
+	}
+	verify := postQuantumVerifier(key.Algorithm)
+	if verify == nil {
+		return sig.Classical, nil
+	}
+	if sig.PostQuantumAlgorithm != key.Algorithm {
+		return nil, newValidationError(ErrBadSignature, cert, "Post-quantum signature algorithm %s doesn't match the issuer key (%s)",
+			sig.PostQuantumAlgorithm, key.Algorithm)
+	}
+	if len(sig.PostQuantum) == 0 {
+		return nil, newValidationError(ErrBadSignature, cert, "Post-quantum signature is missing")
+	}
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return nil, newValidationError(ErrMalformedCertificate, cert, "Failed to serialize certificate for validation")
+	}
+	if !verify(key.Key, certBytes, sig.PostQuantum) {
+		return nil, newValidationError(ErrBadSignature, cert, "Post-quantum signature validation failed")
+	}
+	return sig.Classical, nil
+}
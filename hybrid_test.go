@@ -0,0 +1,157 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testAlgorithm stands in for a post-quantum algorithm, using ed25519 keys
+const testAlgorithm Algorithm = -70000
+
+type testSigner struct {
+	priv ed25519.PrivateKey
+}
+
+func (s *testSigner) Algorithm() Algorithm { return testAlgorithm }
+func (s *testSigner) Public() []byte       { return s.priv.Public().(ed25519.PublicKey) }
+func (s *testSigner) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.priv, message), nil
+}
+
+func init() {
+	RegisterPostQuantumVerifier(testAlgorithm, func(pubKey, message, sig []byte) bool {
+		return verifySignature(pubKey, message, sig)
+	})
+}
+
+func newHybridRoot(t *testing.T, pqSigner PostQuantumSigner) (*Certificate, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ext, err := PostQuantumKeyExtension(pqSigner)
+	require.NoError(t, err)
+	root := &Certificate{
+		SerialNumber: 1,
+		Issuer:       "root",
+		Subject:      "root",
+		Validity:     &Validity{},
+		PubKey:       pub,
+		Extensions: []Extension{
+			{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()},
+			ext,
+		},
+	}
+	root, err = SignCertificateHybrid(root, priv, pqSigner)
+	require.NoError(t, err)
+	return root, priv
+}
+
+func TestHybridSignature(t *testing.T) {
+	_, pqPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pqSigner := &testSigner{priv: pqPriv}
+	root, rootKey := newHybridRoot(t, pqSigner)
+	pool := NewCertPool(root)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := SignCertificateHybrid(&Certificate{
+		SerialNumber: 2,
+		Issuer:       "root",
+		Subject:      "device",
+		Validity:     &Validity{},
+		PubKey:       pub,
+	}, rootKey, pqSigner)
+	require.NoError(t, err)
+	assert.Equal(t, AlgorithmHybrid, cert.SignatureAlgorithm)
+
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+	cert, err = ParseBuf(buf)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(cert))
+	assert.True(t, pool.ValidateReport(cert).Valid())
+
+	// Both signatures need to be valid
+	sig, err := ParseHybridSignature(cert)
+	require.NoError(t, err)
+	sig.PostQuantum[0] ^= 0xff
+	broken := cert.Copy()
+	broken.Signature, err = cborEm.Marshal(sig)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(pool.Validate(broken), ErrBadSignature))
+
+	sig.PostQuantum[0] ^= 0xff
+	sig.Classical[0] ^= 0xff
+	broken.Signature, err = cborEm.Marshal(sig)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(pool.Validate(broken), ErrBadSignature))
+
+	// The post-quantum signature can't be skipped by relabelling or removing it
+	sig.Classical[0] ^= 0xff
+	sig.PostQuantumAlgorithm = Algorithm(-70001)
+	broken.Signature, err = cborEm.Marshal(sig)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(pool.Validate(broken), ErrBadSignature))
+
+	sig.PostQuantumAlgorithm = testAlgorithm
+	sig.PostQuantum = nil
+	broken.Signature, err = cborEm.Marshal(sig)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(pool.Validate(broken), ErrBadSignature))
+
+	broken.Signature = []byte{0x01}
+	assert.True(t, errors.Is(pool.Validate(broken), ErrMalformedCertificate))
+}
+
+func TestHybridSignatureOfUnknownAlgorithm(t *testing.T) {
+	// Issuer keys of algorithms without verifier are skipped, the classical signature is still verified
+	_, pqPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pqSigner := &unknownSigner{testSigner{priv: pqPriv}}
+	root, rootKey := newHybridRoot(t, pqSigner)
+	pool := NewCertPool(root)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := SignCertificateHybrid(&Certificate{Issuer: "root", Subject: "device", Validity: &Validity{}, PubKey: pub},
+		rootKey, pqSigner)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(cert))
+
+	sig, err := ParseHybridSignature(cert)
+	require.NoError(t, err)
+	sig.Classical[0] ^= 0xff
+	cert.Signature, err = cborEm.Marshal(sig)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(pool.Validate(cert), ErrBadSignature))
+}
+
+// unknownSigner signs with an algorithm without registered verifier
+type unknownSigner struct {
+	testSigner
+}
+
+func (s *unknownSigner) Algorithm() Algorithm { return Algorithm(-70001) }
+
+func TestHybridSignatureWithoutIssuerKey(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, pqPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := SignCertificateHybrid(&Certificate{Issuer: "root", Subject: "device", Validity: &Validity{}, PubKey: pub},
+		rootKey, &testSigner{priv: pqPriv})
+	require.NoError(t, err)
+	assert.True(t, errors.Is(NewCertPool(rootCert).Validate(cert), ErrMalformedCertificate))
+
+	_, err = ParseHybridSignature(rootCert)
+	assert.Error(t, err)
+}
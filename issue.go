@@ -0,0 +1,89 @@
+package smolcert
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// IssueOption configures a certificate issued via Issue
+type IssueOption func(b *CertificateBuilder)
+
+// WithVersion sets the version of the certificate format
+func WithVersion(version uint64) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.Version(version)
+	}
+}
+
+// WithSerialNumber sets the serial number of the certificate
+func WithSerialNumber(serialNumber uint64) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.SerialNumber(serialNumber)
+	}
+}
+
+// WithSerialSource sets the function creating the serial number of the certificate, i.e. to use a
+// sequence from a database. Random serial numbers are used by default.
+func WithSerialSource(source func() (uint64, error)) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.serialSource = source
+	}
+}
+
+// WithRandomSource sets the source of randomness for random serial numbers, i.e. a hardware RNG
+// or a deterministic reader in tests. The source set with SetRandomSource is used by default.
+func WithRandomSource(r io.Reader) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.rand = r
+	}
+}
+
+// WithValidity sets the validity of the certificate, zero times are not restricted
+func WithValidity(notBefore, notAfter time.Time) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.NotBefore(notBefore).NotAfter(notAfter)
+	}
+}
+
+// WithValidFor sets the certificate to be valid from now on for d
+func WithValidFor(d time.Duration) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.ValidFor(d)
+	}
+}
+
+// WithExtensions adds extensions to the certificate
+func WithExtensions(extensions ...Extension) IssueOption {
+	return func(b *CertificateBuilder) {
+		for _, ext := range extensions {
+			b.AddExtension(ext)
+		}
+	}
+}
+
+// WithKeyUsage sets the KeyUsage of the certificate
+func WithKeyUsage(keyUsage KeyUsage) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.KeyUsage(keyUsage)
+	}
+}
+
+// WithProfile issues the certificate according to a Profile
+func WithProfile(p *Profile) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.Profile(p)
+	}
+}
+
+// Issue issues a certificate for subject and pubKey, signed by signer in the name of issuer. Without
+// options, the certificate gets a random serial number, no extensions and an unrestricted validity.
+func Issue(subject string, pubKey ed25519.PublicKey, issuer string, signer ed25519.PrivateKey,
+	opts ...IssueOption) (*Certificate, error) {
+	b := NewCertificateBuilder().Subject(subject).PublicKey(pubKey).Issuer(issuer)
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b.SignWith(signer)
+}
@@ -0,0 +1,70 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestIssue(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	cert, err := Issue("device", pub, "root", rootKey)
+	require.NoError(t, err)
+	assert.NotZero(t, cert.SerialNumber)
+	assert.Empty(t, cert.Extensions)
+	assert.NoError(t, pool.Validate(cert))
+
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	notAfter := notBefore.Add(time.Hour)
+	delegation, err := DelegationExtension(time.Hour)
+	require.NoError(t, err)
+	cert, err = Issue("device", pub, "root", rootKey,
+		WithSerialNumber(7),
+		WithValidity(notBefore, notAfter),
+		WithKeyUsage(KeyUsageServerIdentification),
+		WithExtensions(delegation))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), cert.SerialNumber)
+	assert.Equal(t, NewTime(notBefore), cert.Validity.NotBefore)
+	assert.Equal(t, NewTime(notAfter), cert.Validity.NotAfter)
+	assert.Len(t, cert.Extensions, 2)
+	assert.NoError(t, pool.Validate(cert))
+
+	cert, err = Issue("device", pub, "root", rootKey, WithProfile(ProfileDevice), WithValidFor(time.Hour))
+	require.NoError(t, err)
+	assert.NoError(t, ProfileDevice.Check(cert))
+	assert.True(t, cert.ExpiresWithin(time.Hour))
+}
+
+func TestIssueSerialSource(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	next := uint64(100)
+	source := func() (uint64, error) {
+		next++
+		return next, nil
+	}
+	for i := uint64(1); i <= 2; i++ {
+		cert, err := Issue("device", pub, "root", rootKey, WithSerialSource(source))
+		require.NoError(t, err)
+		assert.Equal(t, 100+i, cert.SerialNumber)
+	}
+
+	_, err = Issue("device", pub, "root", rootKey, WithSerialSource(func() (uint64, error) {
+		return 0, errors.New("sequence exhausted")
+	}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package smolcert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// JWK is an ed25519 key as JSON Web Key (RFC 8037)
+type JWK struct {
+	KeyType string `json:"kty"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	D       string `json:"d,omitempty"`
+	KeyID   string `json:"kid,omitempty"`
+	Use     string `json:"use,omitempty"`
+}
+
+// PublicJWK returns the public key of the certificate as JWK. The key id is set to the RFC 7638 thumbprint.
+func (c *Certificate) PublicJWK() *JWK {
+	return NewPublicJWK(c.PubKey)
+}
+
+// NewPublicJWK creates the JWK of an ed25519 public key
+func NewPublicJWK(pubKey ed25519.PublicKey) *JWK {
+	j := &JWK{
+		KeyType: "OKP",
+		Curve:   "Ed25519",
+		X:       base64.RawURLEncoding.EncodeToString(pubKey),
+	}
+	j.KeyID = j.Thumbprint()
+	return j
+}
+
+// NewPrivateJWK creates the JWK of an ed25519 private key
+func NewPrivateJWK(priv ed25519.PrivateKey) *JWK {
+	j := NewPublicJWK(priv.Public().(ed25519.PublicKey))
+	j.D = base64.RawURLEncoding.EncodeToString(priv.Seed())
+	return j
+}
+
+// ParseJWK parses a JSON encoded JWK, only OKP keys with the Ed25519 curve are accepted
+func ParseJWK(buf []byte) (*JWK, error) {
+	j := &JWK{}
+	if err := json.Unmarshal(buf, j); err != nil {
+		return nil, err
+	}
+	if _, err := j.PublicKey(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Thumbprint returns the base64url encoded SHA-256 thumbprint (RFC 7638) of the JWK
+func (j *JWK) Thumbprint() string {
+	// The members are required to be in lexicographic order without whitespace
+	buf, _ := json.Marshal(struct {
+		Curve   string `json:"crv"`
+		KeyType string `json:"kty"`
+		X       string `json:"x"`
+	}{j.Curve, j.KeyType, j.X})
+	hash := sha256.Sum256(buf)
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// PublicKey returns the ed25519 public key of the JWK
+func (j *JWK) PublicKey() (ed25519.PublicKey, error) {
+	if j.KeyType != "OKP" || j.Curve != "Ed25519" {
+		return nil, fmt.Errorf("Unsupported JWK of type '%s' with curve '%s'", j.KeyType, j.Curve)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid public key in JWK: %w", err)
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("Invalid length of public key in JWK")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// PrivateKey returns the ed25519 private key of the JWK
+func (j *JWK) PrivateKey() (ed25519.PrivateKey, error) {
+	pub, err := j.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if j.D == "" {
+		return nil, errors.New("JWK doesn't contain a private key")
+	}
+	d, err := base64.RawURLEncoding.DecodeString(j.D)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid private key in JWK: %w", err)
+	}
+	if len(d) != ed25519.SeedSize {
+		return nil, errors.New("Invalid length of private key in JWK")
+	}
+	priv := ed25519.NewKeyFromSeed(d)
+	if !bytes.Equal(priv[ed25519.SeedSize:], pub) {
+		return nil, errors.New("Public key doesn't match the private key in JWK")
+	}
+	return priv, nil
+}
+
+// Public returns a copy of the JWK without the private key
+func (j *JWK) Public() *JWK {
+	p := *j
+	p.D = ""
+	return &p
+}
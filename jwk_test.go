@@ -0,0 +1,72 @@
+package smolcert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateJWK(t *testing.T) {
+	cert, priv, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	jwk := cert.PublicJWK()
+	assert.Equal(t, "OKP", jwk.KeyType)
+	assert.Equal(t, "Ed25519", jwk.Curve)
+	assert.Empty(t, jwk.D)
+	assert.NotEmpty(t, jwk.KeyID)
+
+	buf, err := json.Marshal(jwk)
+	require.NoError(t, err)
+	parsed, err := ParseJWK(buf)
+	require.NoError(t, err)
+	pub, err := parsed.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, cert.PubKey, pub)
+	_, err = parsed.PrivateKey()
+	assert.Error(t, err)
+
+	privJWK := NewPrivateJWK(priv)
+	assert.Equal(t, jwk.KeyID, privJWK.KeyID)
+	buf, err = json.Marshal(privJWK)
+	require.NoError(t, err)
+	parsed, err = ParseJWK(buf)
+	require.NoError(t, err)
+	priv2, err := parsed.PrivateKey()
+	require.NoError(t, err)
+	assert.Equal(t, priv, priv2)
+	assert.Equal(t, jwk, parsed.Public())
+}
+
+func TestJWKThumbprint(t *testing.T) {
+	// Example from RFC 8037, Appendix A.3
+	jwk, err := ParseJWK([]byte(`{"kty":"OKP","crv":"Ed25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", jwk.Thumbprint())
+}
+
+func TestParseInvalidJWK(t *testing.T) {
+	for _, s := range []string{
+		`not json`,
+		`{"kty":"EC","crv":"P-256","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`,
+		`{"kty":"OKP","crv":"X25519","x":"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}`,
+		`{"kty":"OKP","crv":"Ed25519","x":"AAAA"}`,
+		`{"kty":"OKP","crv":"Ed25519","x":"not base64!"}`,
+	} {
+		_, err := ParseJWK([]byte(s))
+		assert.Error(t, err, s)
+	}
+
+	// Private keys need to match the public key
+	_, priv, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	other, _, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	jwk := NewPrivateJWK(priv)
+	jwk.X = other.PublicJWK().X
+	_, err = jwk.PrivateKey()
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+/*
+Package keychain stores smolcert private keys in the key store of the operating system, so
+desktop and edge agents don't need to keep plaintext key files.
+
+The keys are stored as generic secrets in the macOS Keychain, the Windows Credential Manager (the
+CNG key storage providers don't support ed25519 keys) and the Linux kernel keyring:
+
+	store, err := keychain.New("smolcert")
+	if err != nil {
+		// No key store on this platform, fall back to key files
+	}
+	err = store.Put("device", key)
+	key, err = store.Get("device")
+
+Keys are stored by their ed25519 seed, the private key is derived again when it is retrieved.
+Keys in the Linux kernel keyring don't survive a reboot, so it suits keys which are provisioned
+at boot, i.e. unsealed from a TPM.
+*/
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// ErrNotFound indicates that the key store has no key with the requested name
+	ErrNotFound = errors.New("key not found")
+	// ErrUnsupported indicates that the platform has no supported key store
+	ErrUnsupported = errors.New("platform key store is not supported")
+)
+
+// Store stores private keys by name. Implementations are safe for concurrent use.
+type Store interface {
+	// Put stores key under name, replacing an existing key with the same name
+	Put(name string, key ed25519.PrivateKey) error
+	// Get returns the key stored under name or ErrNotFound
+	Get(name string) (ed25519.PrivateKey, error)
+	// Delete removes the key stored under name, ErrNotFound is returned if there is none
+	Delete(name string) error
+}
+
+// New returns the Store of the platform, which keeps the keys of service separate from the keys of
+// other applications. ErrUnsupported is returned on platforms without a supported key store.
+func New(service string) (Store, error) {
+	if err := checkName(service); err != nil {
+		return nil, err
+	}
+	return newPlatformStore(service)
+}
+
+// checkName restricts names to characters which don't need to be escaped by any backend
+func checkName(name string) error {
+	if name == "" || len(name) > 128 {
+		return fmt.Errorf("Invalid key name '%s', names need to have 1 to 128 characters", name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("Invalid key name '%s', only letters, digits, '.', '-' and '_' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// keyFromSeed derives the private key from a seed read from a key store
+func keyFromSeed(seed []byte) (ed25519.PrivateKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("Unexpected length of stored key (expected %d bytes, got %d bytes)", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// wipe overwrites a copy of a seed, once it isn't needed anymore
+func wipe(seed []byte) {
+	for i := range seed {
+		seed[i] = 0
+	}
+}
+
+func checkKey(key ed25519.PrivateKey) error {
+	if len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("Unexpected length of private key (expected %d bytes, got %d bytes)", ed25519.PrivateKeySize, len(key))
+	}
+	return nil
+}
+
+// MemoryStore is a Store keeping keys in memory, i.e. for tests or as fallback on platforms
+// without key store
+type MemoryStore struct {
+	lock  sync.Mutex
+	seeds map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{seeds: map[string][]byte{}}
+}
+
+// Put implements Store
+func (s *MemoryStore) Put(name string, key ed25519.PrivateKey) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.seeds[name] = append([]byte{}, key.Seed()...)
+	return nil
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(name string) (ed25519.PrivateKey, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	seed, found := s.seeds[name]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return keyFromSeed(seed)
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, found := s.seeds[name]; !found {
+		return ErrNotFound
+	}
+	delete(s.seeds, name)
+	return nil
+}
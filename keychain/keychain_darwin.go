@@ -0,0 +1,99 @@
+package keychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// errItemNotFound is the exit status of the security tool for missing items
+const errItemNotFound = 44
+
+// KeychainStore is a Store keeping keys as generic passwords in the default macOS Keychain. It
+// uses the security tool, so no cgo is required.
+type KeychainStore struct {
+	service string
+	// Path is the path of the security tool
+	Path string
+}
+
+// NewKeychainStore creates a KeychainStore storing the keys of service
+func NewKeychainStore(service string) (*KeychainStore, error) {
+	if err := checkName(service); err != nil {
+		return nil, err
+	}
+	return &KeychainStore{service: service, Path: "/usr/bin/security"}, nil
+}
+
+func newPlatformStore(service string) (Store, error) {
+	return NewKeychainStore(service)
+}
+
+// Put implements Store
+func (s *KeychainStore) Put(name string, key ed25519.PrivateKey) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// The command is passed via stdin in interactive mode, so the key doesn't show up in the
+	// arguments of the process
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", s.service, name, hex.EncodeToString(key.Seed()))
+	_, err := s.run(strings.NewReader(cmd), "-i")
+	return err
+}
+
+// Get implements Store
+func (s *KeychainStore) Get(name string) (ed25519.PrivateKey, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	out, err := s.run(nil, "find-generic-password", "-s", s.service, "-a", name, "-w")
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(string(bytes.TrimSpace(out)))
+	wipe(out)
+	if err != nil {
+		return nil, fmt.Errorf("Stored key is not hex encoded: %w", err)
+	}
+	defer wipe(seed)
+	return keyFromSeed(seed)
+}
+
+// Delete implements Store
+func (s *KeychainStore) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	_, err := s.run(nil, "delete-generic-password", "-s", s.service, "-a", name)
+	return err
+}
+
+func (s *KeychainStore) run(stdin *strings.Reader, args ...string) ([]byte, error) {
+	cmd := exec.Command(s.Path, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == errItemNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("security %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	// In interactive mode errors are only reported on stderr
+	if err == nil && len(args) > 0 && args[0] == "-i" && stderr.Len() > 0 {
+		return nil, fmt.Errorf("security failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return out, err
+}
@@ -0,0 +1,138 @@
+package keychain
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Constants of the keyctl interface, see keyctl(2)
+const (
+	keySpecUserKeyring = -4
+
+	keyctlUnlink = 9
+	keyctlSearch = 10
+	keyctlRead   = 11
+)
+
+// KeyringStore is a Store keeping keys as "user" keys in a Linux kernel keyring
+type KeyringStore struct {
+	service string
+	// Keyring is the ID of the keyring the keys are linked to, the user keyring by default
+	Keyring int
+}
+
+// NewKeyringStore creates a KeyringStore storing the keys of service in the user keyring
+func NewKeyringStore(service string) (*KeyringStore, error) {
+	if err := checkName(service); err != nil {
+		return nil, err
+	}
+	return &KeyringStore{service: service, Keyring: keySpecUserKeyring}, nil
+}
+
+func newPlatformStore(service string) (Store, error) {
+	s, err := NewKeyringStore(service)
+	if err != nil {
+		return nil, err
+	}
+	// Kernels without keyring support or sandboxes filtering the keyctl calls
+	if _, err := s.search("probe"); err != nil && err != ErrNotFound {
+		return nil, ErrUnsupported
+	}
+	return s, nil
+}
+
+func (s *KeyringStore) description(name string) string {
+	return s.service + ":" + name
+}
+
+// Put implements Store
+func (s *KeyringStore) Put(name string, key ed25519.PrivateKey) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	keyType, err := syscall.BytePtrFromString("user")
+	if err != nil {
+		return err
+	}
+	description, err := syscall.BytePtrFromString(s.description(name))
+	if err != nil {
+		return err
+	}
+	seed := key.Seed()
+	defer wipe(seed)
+	// add_key replaces the payload of an existing key with the same description
+	_, _, errno := syscall.Syscall6(syscall.SYS_ADD_KEY, uintptr(unsafe.Pointer(keyType)),
+		uintptr(unsafe.Pointer(description)), uintptr(unsafe.Pointer(&seed[0])), uintptr(len(seed)),
+		uintptr(s.Keyring), 0)
+	if errno != 0 {
+		return fmt.Errorf("Failed to add key to keyring: %w", errno)
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *KeyringStore) Get(name string) (ed25519.PrivateKey, error) {
+	id, err := s.search(name)
+	if err != nil {
+		return nil, err
+	}
+	// The buffer is larger than a seed, so larger payloads are detected
+	seed := make([]byte, ed25519.SeedSize+1)
+	defer wipe(seed)
+	n, _, errno := syscall.Syscall6(syscall.SYS_KEYCTL, keyctlRead, id, uintptr(unsafe.Pointer(&seed[0])),
+		uintptr(len(seed)), 0, 0)
+	if errno != 0 {
+		return nil, mapErrno(errno)
+	}
+	// keyctl returns the size of the payload, which may exceed the buffer
+	if int(n) > len(seed) {
+		n = uintptr(len(seed))
+	}
+	return keyFromSeed(seed[:n])
+}
+
+// Delete implements Store
+func (s *KeyringStore) Delete(name string) error {
+	id, err := s.search(name)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_KEYCTL, keyctlUnlink, id, uintptr(s.Keyring), 0, 0, 0)
+	if errno != 0 {
+		return mapErrno(errno)
+	}
+	return nil
+}
+
+// search returns the ID of the key stored under name
+func (s *KeyringStore) search(name string) (uintptr, error) {
+	keyType, err := syscall.BytePtrFromString("user")
+	if err != nil {
+		return 0, err
+	}
+	description, err := syscall.BytePtrFromString(s.description(name))
+	if err != nil {
+		return 0, err
+	}
+	id, _, errno := syscall.Syscall6(syscall.SYS_KEYCTL, keyctlSearch, uintptr(s.Keyring),
+		uintptr(unsafe.Pointer(keyType)), uintptr(unsafe.Pointer(description)), 0, 0)
+	if errno != 0 {
+		return 0, mapErrno(errno)
+	}
+	return id, nil
+}
+
+func mapErrno(errno syscall.Errno) error {
+	switch errno {
+	case syscall.ENOKEY, syscall.EKEYREVOKED, syscall.EKEYEXPIRED:
+		return ErrNotFound
+	default:
+		return errno
+	}
+}
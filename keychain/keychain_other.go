@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package keychain
+
+func newPlatformStore(service string) (Store, error) {
+	return nil, ErrUnsupported
+}
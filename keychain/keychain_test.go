@@ -0,0 +1,69 @@
+package keychain
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func testStore(t *testing.T, s Store) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	_, err = s.Get("test-key")
+	assert.Equal(t, ErrNotFound, err)
+	require.NoError(t, s.Put("test-key", key))
+	defer s.Delete("test-key")
+	stored, err := s.Get("test-key")
+	require.NoError(t, err)
+	assert.Equal(t, key, stored)
+
+	// Keys are replaced
+	require.NoError(t, s.Put("test-key", otherKey))
+	stored, err = s.Get("test-key")
+	require.NoError(t, err)
+	assert.Equal(t, otherKey, stored)
+
+	require.NoError(t, s.Delete("test-key"))
+	_, err = s.Get("test-key")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, s.Delete("test-key"))
+
+	assert.Error(t, s.Put("test key", key))
+	assert.Error(t, s.Put("", key))
+	assert.Error(t, s.Put("test-key", key[:32]))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestPlatformStore(t *testing.T) {
+	// The Keychain and the Credential Manager are persistent, so they are only tested on request
+	if runtime.GOOS != "linux" && os.Getenv("SMOLCERT_KEYCHAIN_TEST") == "" {
+		t.Skip("Set SMOLCERT_KEYCHAIN_TEST to test the key store of the platform")
+	}
+	s, err := New("smolcert-test")
+	if err == ErrUnsupported {
+		t.Skip("The platform key store is not available")
+	}
+	require.NoError(t, err)
+	testStore(t, s)
+}
+
+func TestCheckName(t *testing.T) {
+	assert.NoError(t, checkName("smolcert"))
+	assert.NoError(t, checkName("device-1.identity_key"))
+	assert.Error(t, checkName(""))
+	assert.Error(t, checkName("a b"))
+	assert.Error(t, checkName("a'b"))
+	assert.Error(t, checkName("a:b"))
+	_, err := New("invalid service")
+	assert.Error(t, err)
+}
@@ -0,0 +1,136 @@
+package keychain
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Constants of the Credential Manager API, see wincred.h
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+// credential is CREDENTIALW
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// CredentialStore is a Store keeping keys as generic credentials in the Windows Credential Manager,
+// which protects them with the login of the user
+type CredentialStore struct {
+	service string
+}
+
+// NewCredentialStore creates a CredentialStore storing the keys of service
+func NewCredentialStore(service string) (*CredentialStore, error) {
+	if err := checkName(service); err != nil {
+		return nil, err
+	}
+	return &CredentialStore{service: service}, nil
+}
+
+func newPlatformStore(service string) (Store, error) {
+	return NewCredentialStore(service)
+}
+
+func (s *CredentialStore) target(name string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(s.service + ":" + name)
+}
+
+// Put implements Store
+func (s *CredentialStore) Put(name string, key ed25519.PrivateKey) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	target, err := s.target(name)
+	if err != nil {
+		return err
+	}
+	seed := key.Seed()
+	defer wipe(seed)
+	cred := &credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(seed)),
+		CredentialBlob:     &seed[0],
+		Persist:            credPersistLocalMachine,
+	}
+	if ok, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(cred)), 0); ok == 0 {
+		return fmt.Errorf("Failed to write credential: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store
+func (s *CredentialStore) Get(name string) (ed25519.PrivateKey, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	target, err := s.target(name)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	if ok, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0,
+		uintptr(unsafe.Pointer(&cred))); ok == 0 {
+		return nil, mapError(err)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	if cred.CredentialBlobSize != ed25519.SeedSize {
+		return nil, fmt.Errorf("Unexpected length of stored key (expected %d bytes, got %d bytes)",
+			ed25519.SeedSize, cred.CredentialBlobSize)
+	}
+	blob := (*[ed25519.SeedSize]byte)(unsafe.Pointer(cred.CredentialBlob))
+	key, err := keyFromSeed(blob[:])
+	wipe(blob[:])
+	return key, err
+}
+
+// Delete implements Store
+func (s *CredentialStore) Delete(name string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	target, err := s.target(name)
+	if err != nil {
+		return err
+	}
+	if ok, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ok == 0 {
+		return mapError(err)
+	}
+	return nil
+}
+
+func mapError(err error) error {
+	if err == errorNotFound {
+		return ErrNotFound
+	}
+	return err
+}
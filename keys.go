@@ -0,0 +1,40 @@
+package smolcert
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+var keyDerivationContext = []byte("smolcert key derivation")
+
+// MinSecretSize is the minimum size of secrets keys are derived from with DeriveKeyPair
+const MinSecretSize = 16
+
+// KeyPairFromSeed returns the ed25519 key pair for a seed of ed25519.SeedSize bytes. The same seed
+// always results in the same key pair.
+func KeyPairFromSeed(seed []byte) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, nil, fmt.Errorf("Seed needs to be %d bytes long, not %d", ed25519.SeedSize, len(seed))
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	return priv.Public().(ed25519.PublicKey), priv, nil
+}
+
+// DeriveKeyPair deterministically derives an ed25519 key pair from secret via HKDF-SHA256. Distinct
+// labels result in independent keys, so a device with a single burned-in secret can derive i.e. an
+// identity and an enrollment key from it.
+func DeriveKeyPair(secret []byte, label string) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if len(secret) < MinSecretSize {
+		return nil, nil, fmt.Errorf("Secret needs to be at least %d bytes long", MinSecretSize)
+	}
+	info := append(append([]byte{}, keyDerivationContext...), label...)
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, nil, info), seed); err != nil {
+		return nil, nil, err
+	}
+	return KeyPairFromSeed(seed)
+}
@@ -0,0 +1,78 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// ParsePrivateKey parses an ed25519 private key from PEM encoded data. Supported are PKCS#8
+// ("PRIVATE KEY", i.e. generated by openssl genpkey -algorithm ed25519) and unencrypted
+// OpenSSH ("OPENSSH PRIVATE KEY", i.e. generated by ssh-keygen -t ed25519) keys.
+func ParsePrivateKey(pemBytes []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("No PEM encoded key found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "OPENSSH PRIVATE KEY":
+		key, err = ssh.ParseRawPrivateKey(pemBytes)
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block type '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse private key: %w", err)
+	}
+
+	var priv ed25519.PrivateKey
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		priv = k
+	case *ed25519.PrivateKey:
+		priv = *k
+	default:
+		return nil, fmt.Errorf("Private key is of type %T, but only ed25519 is supported", key)
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid length of ed25519 private key")
+	}
+	// Make sure the public part matches the seed, so a corrupted key doesn't produce invalid signatures
+	derived := ed25519.NewKeyFromSeed(priv[:ed25519.SeedSize])
+	if !bytes.Equal(derived, priv) {
+		return nil, errors.New("Public key doesn't match the private key")
+	}
+	return derived, nil
+}
+
+// LoadPrivateKey reads a PEM encoded ed25519 private key from a file, see ParsePrivateKey for the
+// supported formats
+func LoadPrivateKey(filename string) (ed25519.PrivateKey, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(buf)
+}
+
+// MarshalPrivateKey encodes an ed25519 private key as PEM encoded PKCS#8 key
+func MarshalPrivateKey(priv ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,43 @@
+package smolcert
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestKeyPairFromSeed(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	pub, priv, err := KeyPairFromSeed(seed)
+	require.NoError(t, err)
+	assert.Equal(t, ed25519.NewKeyFromSeed(seed), priv)
+	assert.Equal(t, priv.Public(), pub)
+
+	_, _, err = KeyPairFromSeed(seed[:16])
+	assert.Error(t, err)
+}
+
+func TestDeriveKeyPair(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	pub, priv, err := DeriveKeyPair(secret, "identity")
+	require.NoError(t, err)
+	again, _, err := DeriveKeyPair(secret, "identity")
+	require.NoError(t, err)
+	assert.Equal(t, pub, again)
+	assert.Equal(t, priv.Public(), pub)
+	// Derived keys must never change, devices depend on getting the same identity
+	assert.Equal(t, "e1ae7b5d9f1e2eebb943d789cb773bf2d49c5aa07ac4ee3253c3137f305d6a1e", hex.EncodeToString(pub))
+
+	other, _, err := DeriveKeyPair(secret, "enrollment")
+	require.NoError(t, err)
+	assert.NotEqual(t, pub, other)
+	otherSecret, _, err := DeriveKeyPair([]byte("fedcba9876543210fedcba9876543210"), "identity")
+	require.NoError(t, err)
+	assert.NotEqual(t, pub, otherSecret)
+
+	_, _, err = DeriveKeyPair([]byte("short"), "identity")
+	assert.Error(t, err)
+}
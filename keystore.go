@@ -0,0 +1,133 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// KeyStoreEntry is a private key and its certificates stored in a KeyStore. Either may be missing,
+// i.e. entries of trusted roots only contain certificates.
+type KeyStoreEntry struct {
+	// Certificates contains the certificate of the key first, optionally followed by intermediates
+	Certificates []*Certificate
+	Key          ed25519.PrivateKey
+}
+
+// KeyStore stores private keys and certificates by name, so that CAs and clients don't need to
+// know where and how their credentials are kept. Implementations need to be safe for concurrent
+// use.
+type KeyStore interface {
+	// Get returns the entry stored under name or ErrNotFound
+	Get(name string) (*KeyStoreEntry, error)
+	// Put stores entry under name, replacing an existing entry with the same name
+	Put(name string, entry *KeyStoreEntry) error
+	// Delete removes the entry stored under name, ErrNotFound is returned if there is none
+	Delete(name string) error
+	// List returns the names of all entries in ascending order
+	List() ([]string, error)
+}
+
+// checkKeyStoreName restricts names to characters which can be used in file names on every
+// platform. Names can't start with '.', so they don't clash with hidden or temporary files.
+func checkKeyStoreName(name string) error {
+	if name == "" || len(name) > 128 {
+		return fmt.Errorf("Invalid key store name '%s', names need to have 1 to 128 characters", name)
+	}
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || (c == '.' && i > 0)) {
+			return fmt.Errorf("Invalid key store name '%s', only letters, digits, '.', '-' and '_' are allowed", name)
+		}
+	}
+	return nil
+}
+
+func checkKeyStoreEntry(entry *KeyStoreEntry) error {
+	if entry == nil || (len(entry.Certificates) == 0 && entry.Key == nil) {
+		return errors.New("Key store entry needs a key or a certificate")
+	}
+	for _, cert := range entry.Certificates {
+		if cert == nil {
+			return errors.New("Key store entry contains an empty certificate")
+		}
+	}
+	if entry.Key == nil {
+		return nil
+	}
+	if len(entry.Key) != ed25519.PrivateKeySize {
+		return errors.New("Invalid private key")
+	}
+	if len(entry.Certificates) > 0 && !bytes.Equal(entry.Key.Public().(ed25519.PublicKey), entry.Certificates[0].PubKey) {
+		return errors.New("Private key doesn't belong to the certificate")
+	}
+	return nil
+}
+
+// MemoryKeyStore is a KeyStore keeping all entries in memory, i.e. for tests
+type MemoryKeyStore struct {
+	lock    sync.RWMutex
+	entries map[string]*KeyStoreEntry
+}
+
+// NewMemoryKeyStore creates an empty MemoryKeyStore
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{entries: map[string]*KeyStoreEntry{}}
+}
+
+// Get implements KeyStore
+func (s *MemoryKeyStore) Get(name string) (*KeyStoreEntry, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	entry, found := s.entries[name]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &KeyStoreEntry{
+		Certificates: append([]*Certificate(nil), entry.Certificates...),
+		Key:          append(ed25519.PrivateKey(nil), entry.Key...),
+	}, nil
+}
+
+// Put implements KeyStore
+func (s *MemoryKeyStore) Put(name string, entry *KeyStoreEntry) error {
+	if err := checkKeyStoreName(name); err != nil {
+		return err
+	}
+	if err := checkKeyStoreEntry(entry); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.entries[name] = &KeyStoreEntry{
+		Certificates: append([]*Certificate(nil), entry.Certificates...),
+		Key:          append(ed25519.PrivateKey(nil), entry.Key...),
+	}
+	return nil
+}
+
+// Delete implements KeyStore
+func (s *MemoryKeyStore) Delete(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, found := s.entries[name]; !found {
+		return ErrNotFound
+	}
+	delete(s.entries, name)
+	return nil
+}
+
+// List implements KeyStore
+func (s *MemoryKeyStore) List() ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,164 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File extensions of the files of a DirKeyStore
+const (
+	dirKeyStoreCertExt = ".cbor"
+	dirKeyStoreKeyExt  = ".key"
+)
+
+// DirKeyStore is a KeyStore keeping every entry in up to two files of a directory: the
+// certificates in <name>.cbor as written by WriteCertFile and the PEM encoded key in <name>.key as
+// written by WriteKeyFile. The files can therefore also be used by a Reloader.
+type DirKeyStore struct {
+	dir  string
+	lock sync.RWMutex
+}
+
+// NewDirKeyStore creates a DirKeyStore in dir. dir is created with mode 0700 if it doesn't exist.
+func NewDirKeyStore(dir string) (*DirKeyStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirKeyStore{dir: dir}, nil
+}
+
+// CertFile returns the path of the certificate file of the entry name
+func (s *DirKeyStore) CertFile(name string) string {
+	return filepath.Join(s.dir, name+dirKeyStoreCertExt)
+}
+
+// KeyFile returns the path of the key file of the entry name
+func (s *DirKeyStore) KeyFile(name string) string {
+	return filepath.Join(s.dir, name+dirKeyStoreKeyExt)
+}
+
+// Get implements KeyStore
+func (s *DirKeyStore) Get(name string) (*KeyStoreEntry, error) {
+	if err := checkKeyStoreName(name); err != nil {
+		return nil, err
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	entry := &KeyStoreEntry{}
+	found := false
+	certs, err := LoadCertFile(s.CertFile(name))
+	if err == nil {
+		entry.Certificates = certs
+		found = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	keyData, err := ioutil.ReadFile(s.KeyFile(name))
+	if err == nil {
+		if entry.Key, err = ParsePrivateKey(keyData); err != nil {
+			return nil, fmt.Errorf("Failed to parse key file of '%s': %w", name, err)
+		}
+		found = true
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return entry, nil
+}
+
+// Put implements KeyStore. The key file is written before the certificate file, so a Reloader
+// watching the files picks up the complete entry.
+func (s *DirKeyStore) Put(name string, entry *KeyStoreEntry) error {
+	if err := checkKeyStoreName(name); err != nil {
+		return err
+	}
+	if err := checkKeyStoreEntry(entry); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if entry.Key != nil {
+		if err := WriteKeyFile(s.KeyFile(name), entry.Key); err != nil {
+			return err
+		}
+	} else if err := removeIfExists(s.KeyFile(name)); err != nil {
+		return err
+	}
+	if len(entry.Certificates) > 0 {
+		return WriteCertFile(s.CertFile(name), entry.Certificates...)
+	}
+	return removeIfExists(s.CertFile(name))
+}
+
+// Delete implements KeyStore
+func (s *DirKeyStore) Delete(name string) error {
+	if err := checkKeyStoreName(name); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	found := false
+	for _, filename := range []string{s.KeyFile(name), s.CertFile(name)} {
+		err := os.Remove(filename)
+		if err == nil {
+			found = true
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// List implements KeyStore
+func (s *DirKeyStore) List() ([]string, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	unique := map[string]bool{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		name := file.Name()
+		switch {
+		case strings.HasSuffix(name, dirKeyStoreCertExt):
+			name = strings.TrimSuffix(name, dirKeyStoreCertExt)
+		case strings.HasSuffix(name, dirKeyStoreKeyExt):
+			name = strings.TrimSuffix(name, dirKeyStoreKeyExt)
+		default:
+			continue
+		}
+		if checkKeyStoreName(name) == nil {
+			unique[name] = true
+		}
+	}
+	names := make([]string, 0, len(unique))
+	for name := range unique {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func removeIfExists(filename string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smolcert-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewDirKeyStore(filepath.Join(dir, "keys"))
+	require.NoError(t, err)
+	testKeyStore(t, s)
+
+	// Other files in the directory are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "keys", "README"), []byte("keys"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "keys", ".root.cbor.tmp123"), []byte{}, 0600))
+	names, err := s.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root", "signing.key"}, names)
+
+	// The files of entries can be used by a Reloader
+	root, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	server, serverKey, err := ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	require.NoError(t, s.Put("server", &KeyStoreEntry{Certificates: []*Certificate{server, root}, Key: serverKey}))
+	info, err := os.Stat(s.KeyFile("server"))
+	require.NoError(t, err)
+	assert.Equal(t, KeyFileMode, info.Mode().Perm())
+	r, err := NewReloader(s.CertFile("server"), s.KeyFile("server"), "")
+	require.NoError(t, err)
+	assert.Equal(t, server.Signature, r.Credentials().Certificates[0].Signature)
+
+	require.NoError(t, ioutil.WriteFile(s.KeyFile("broken"), []byte("invalid"), 0600))
+	_, err = s.Get("broken")
+	assert.Error(t, err)
+}
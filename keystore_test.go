@@ -0,0 +1,78 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKeyStore(t *testing.T, s KeyStore) {
+	root, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	client, clientKey, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	_, err = s.Get("client")
+	assert.Equal(t, ErrNotFound, err)
+	names, err := s.List()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	require.NoError(t, s.Put("client", &KeyStoreEntry{Certificates: []*Certificate{client, root}, Key: clientKey}))
+	require.NoError(t, s.Put("root", &KeyStoreEntry{Certificates: []*Certificate{root}}))
+	require.NoError(t, s.Put("signing.key", &KeyStoreEntry{Key: rootKey}))
+	names, err = s.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"client", "root", "signing.key"}, names)
+
+	entry, err := s.Get("client")
+	require.NoError(t, err)
+	require.Len(t, entry.Certificates, 2)
+	assert.Equal(t, client.Signature, entry.Certificates[0].Signature)
+	assert.Equal(t, root.Signature, entry.Certificates[1].Signature)
+	assert.Equal(t, clientKey, entry.Key)
+	entry, err = s.Get("root")
+	require.NoError(t, err)
+	assert.Len(t, entry.Certificates, 1)
+	assert.Nil(t, entry.Key)
+	entry, err = s.Get("signing.key")
+	require.NoError(t, err)
+	assert.Empty(t, entry.Certificates)
+	assert.Equal(t, rootKey, entry.Key)
+
+	// Entries are replaced completely
+	require.NoError(t, s.Put("client", &KeyStoreEntry{Certificates: []*Certificate{client}}))
+	entry, err = s.Get("client")
+	require.NoError(t, err)
+	assert.Len(t, entry.Certificates, 1)
+	assert.Nil(t, entry.Key)
+
+	require.NoError(t, s.Delete("client"))
+	_, err = s.Get("client")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, s.Delete("client"))
+	names, err = s.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root", "signing.key"}, names)
+
+	assert.Error(t, s.Put("client", &KeyStoreEntry{}))
+	assert.Error(t, s.Put("client", &KeyStoreEntry{Certificates: []*Certificate{client}, Key: rootKey}))
+	assert.Error(t, s.Put("../client", &KeyStoreEntry{Key: clientKey}))
+	assert.Error(t, s.Put(".client", &KeyStoreEntry{Key: clientKey}))
+	assert.Error(t, s.Put("", &KeyStoreEntry{Key: clientKey}))
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	testKeyStore(t, NewMemoryKeyStore())
+}
+
+func TestCheckKeyStoreName(t *testing.T) {
+	assert.NoError(t, checkKeyStoreName("device-1.identity_key"))
+	assert.Error(t, checkKeyStoreName("."))
+	assert.Error(t, checkKeyStoreName(".."))
+	assert.Error(t, checkKeyStoreName("a/b"))
+	assert.Error(t, checkKeyStoreName("a\\b"))
+	assert.Error(t, checkKeyStoreName("a b"))
+}
@@ -0,0 +1,242 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Constants of the AWS KMS API for ed25519 keys
+const (
+	awsKeySpecEd25519      = "ECC_NIST_EDWARDS25519"
+	awsSigningAlgorithm    = "ED25519_SHA_512"
+	awsMaxRawMessageSize   = 4096
+	awsTargetPrefix        = "TrentService."
+	awsContentType         = "application/x-amz-json-1.1"
+	awsSignatureV4         = "AWS4-HMAC-SHA256"
+	awsSignatureTimeFormat = "20060102T150405Z"
+)
+
+// AWSCredentials authenticate requests to AWS KMS
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only set for temporary credentials
+	SessionToken string
+}
+
+// AWSConfig configures the access to AWS KMS
+type AWSConfig struct {
+	// Region is the region of the key, i.e. eu-central-1
+	Region string
+	// Credentials returns the credentials for a request, so that temporary credentials can be
+	// refreshed. StaticAWSCredentials returns fixed credentials.
+	Credentials func(ctx context.Context) (AWSCredentials, error)
+	// Endpoint optionally overrides the endpoint https://kms.<Region>.amazonaws.com, i.e. for VPC
+	// endpoints
+	Endpoint string
+	// HTTPClient is used to send requests, by default a client with DefaultTimeout is used
+	HTTPClient *http.Client
+}
+
+// StaticAWSCredentials returns a function for AWSConfig.Credentials, which always returns creds
+func StaticAWSCredentials(creds AWSCredentials) func(ctx context.Context) (AWSCredentials, error) {
+	return func(ctx context.Context) (AWSCredentials, error) {
+		return creds, nil
+	}
+}
+
+// AWSSigner is a smolcert.Signer signing with an ed25519 key of AWS KMS
+type AWSSigner struct {
+	config *AWSConfig
+	keyID  string
+	public ed25519.PublicKey
+	// ctx is used for signing requests, as smolcert.Signer doesn't pass a context
+	ctx context.Context
+}
+
+// NewAWSSigner creates an AWSSigner for the key keyID, which may be a key ID, key ARN or alias. ctx
+// is used to read the public key and for all signing requests.
+func NewAWSSigner(ctx context.Context, config *AWSConfig, keyID string) (*AWSSigner, error) {
+	if config.Region == "" || config.Credentials == nil {
+		return nil, errors.New("AWS KMS needs a region and credentials")
+	}
+	s := &AWSSigner{config: config, keyID: keyID, ctx: ctx}
+	var resp struct {
+		KeySpec   string `json:"KeySpec"`
+		KeyUsage  string `json:"KeyUsage"`
+		PublicKey []byte `json:"PublicKey"`
+	}
+	if err := s.call(ctx, "GetPublicKey", map[string]string{"KeyId": keyID}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.KeySpec != awsKeySpecEd25519 || resp.KeyUsage != "SIGN_VERIFY" {
+		return nil, fmt.Errorf("AWS KMS key '%s' is a %s key for %s, not an ed25519 signing key", keyID,
+			resp.KeySpec, resp.KeyUsage)
+	}
+	public, err := parsePublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	s.public = public
+	return s, nil
+}
+
+// Public implements smolcert.Signer
+func (s *AWSSigner) Public() ed25519.PublicKey {
+	return s.public
+}
+
+// Sign implements smolcert.Signer. AWS KMS signs messages of up to 4096 bytes with ed25519.
+func (s *AWSSigner) Sign(message []byte) ([]byte, error) {
+	if len(message) > awsMaxRawMessageSize {
+		return nil, fmt.Errorf("AWS KMS only signs messages of up to %d bytes", awsMaxRawMessageSize)
+	}
+	var resp struct {
+		Signature []byte `json:"Signature"`
+	}
+	// []byte fields are encoded as base64, like AWS expects it
+	req := map[string]interface{}{
+		"KeyId":            s.keyID,
+		"Message":          message,
+		"MessageType":      "RAW",
+		"SigningAlgorithm": awsSigningAlgorithm,
+	}
+	if err := s.call(s.ctx, "Sign", req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) != ed25519.SignatureSize {
+		return nil, errors.New("AWS KMS returned an invalid signature")
+	}
+	return resp.Signature, nil
+}
+
+// call invokes the KMS operation with the JSON encoded request
+func (s *AWSSigner) call(ctx context.Context, operation string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	endpoint := s.config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + s.config.Region + ".amazonaws.com/"
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", awsContentType)
+	req.Header.Set("X-Amz-Target", awsTargetPrefix+operation)
+	creds, err := s.config.Credentials(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get AWS credentials: %w", err)
+	}
+	signAWSRequest(req, body, creds, s.config.Region, "kms", time.Now())
+	client := s.config.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	respBody, err := readResponse(resp, "AWS KMS "+operation)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("Invalid response of AWS KMS %s: %w", operation, err)
+	}
+	return nil
+}
+
+// signAWSRequest adds the headers of AWS Signature Version 4 to req. All headers set before are
+// signed.
+func signAWSRequest(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(awsSignatureTimeFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range names {
+		fmt.Fprintf(canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := awsSignatureV4 + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSignatureV4, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query with sorted keys and values, escaping spaces as %20
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := []string{}
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
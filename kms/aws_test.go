@@ -0,0 +1,91 @@
+package kms
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	// Example of the AWS Signature Version 4 documentation
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	now, err := time.Parse(awsSignatureTimeFormat, "20150830T123600Z")
+	require.NoError(t, err)
+	signAWSRequest(req, nil, AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		"us-east-1", "iam", now)
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"))
+}
+
+// fakeAWSKMS implements GetPublicKey and Sign of AWS KMS for a single ed25519 key
+func fakeAWSKMS(t *testing.T, key ed25519.PrivateKey) http.HandlerFunc {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req struct {
+			KeyID            string `json:"KeyId"`
+			Message          []byte `json:"Message"`
+			MessageType      string `json:"MessageType"`
+			SigningAlgorithm string `json:"SigningAlgorithm"`
+		}
+		if json.NewDecoder(r.Body).Decode(&req) != nil || req.KeyID != "alias/root" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"NotFoundException","message":"Key not found"}`))
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId": req.KeyID, "KeySpec": "ECC_NIST_EDWARDS25519", "KeyUsage": "SIGN_VERIFY", "PublicKey": der,
+			})
+		case "TrentService.Sign":
+			if req.MessageType != "RAW" || req.SigningAlgorithm != "ED25519_SHA_512" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Signature": ed25519.Sign(key, req.Message)})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAWSSigner(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	ts := httptest.NewServer(fakeAWSKMS(t, key))
+	defer ts.Close()
+	config := &AWSConfig{
+		Region:      "eu-central-1",
+		Credentials: StaticAWSCredentials(AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}),
+		Endpoint:    ts.URL,
+	}
+
+	_, err = NewAWSSigner(context.Background(), config, "alias/missing")
+	assert.Error(t, err)
+	_, err = NewAWSSigner(context.Background(), &AWSConfig{Endpoint: ts.URL}, "alias/root")
+	assert.Error(t, err)
+	signer, err := NewAWSSigner(context.Background(), config, "alias/root")
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), signer.Public())
+	testSigner(t, signer)
+
+	_, err = signer.Sign(make([]byte, 4097))
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// gcpAlgorithmEd25519 is the algorithm of Cloud KMS key versions for ed25519 signatures
+const gcpAlgorithmEd25519 = "EC_SIGN_ED25519"
+
+// DefaultGCPEndpoint is the endpoint of the Cloud KMS REST API
+const DefaultGCPEndpoint = "https://cloudkms.googleapis.com"
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// GCPConfig configures the access to Cloud KMS
+type GCPConfig struct {
+	// Token returns an OAuth 2.0 access token for a request, i.e. from an oauth2.TokenSource.
+	// StaticGCPToken returns a fixed token.
+	Token func(ctx context.Context) (string, error)
+	// Endpoint optionally overrides DefaultGCPEndpoint
+	Endpoint string
+	// HTTPClient is used to send requests, by default a client with DefaultTimeout is used
+	HTTPClient *http.Client
+}
+
+// StaticGCPToken returns a function for GCPConfig.Token, which always returns token
+func StaticGCPToken(token string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// GCPSigner is a smolcert.Signer signing with an ed25519 key version of Cloud KMS. The checksums
+// of requests and responses are verified, as recommended by Google.
+type GCPSigner struct {
+	config *GCPConfig
+	name   string
+	public ed25519.PublicKey
+	// ctx is used for signing requests, as smolcert.Signer doesn't pass a context
+	ctx context.Context
+}
+
+// NewGCPSigner creates a GCPSigner for the key version with the resource name
+// projects/*/locations/*/keyRings/*/cryptoKeys/*/cryptoKeyVersions/*. ctx is used to read the
+// public key and for all signing requests.
+func NewGCPSigner(ctx context.Context, config *GCPConfig, name string) (*GCPSigner, error) {
+	if config.Token == nil {
+		return nil, errors.New("Cloud KMS needs an access token")
+	}
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/cryptoKeyVersions/") {
+		return nil, fmt.Errorf("'%s' is not the resource name of a key version", name)
+	}
+	s := &GCPSigner{config: config, name: name, ctx: ctx}
+	var resp struct {
+		PEM       string `json:"pem"`
+		PEMCRC32C string `json:"pemCrc32c"`
+		Algorithm string `json:"algorithm"`
+	}
+	if err := s.call(ctx, http.MethodGet, "/publicKey", nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Algorithm != gcpAlgorithmEd25519 {
+		return nil, fmt.Errorf("Cloud KMS key version '%s' has the algorithm %s, not %s", name, resp.Algorithm,
+			gcpAlgorithmEd25519)
+	}
+	if !checkCRC32C([]byte(resp.PEM), resp.PEMCRC32C) {
+		return nil, errors.New("Public key returned by Cloud KMS has been corrupted")
+	}
+	public, err := parsePEMPublicKey([]byte(resp.PEM))
+	if err != nil {
+		return nil, err
+	}
+	s.public = public
+	return s, nil
+}
+
+// Public implements smolcert.Signer
+func (s *GCPSigner) Public() ed25519.PublicKey {
+	return s.public
+}
+
+// Sign implements smolcert.Signer
+func (s *GCPSigner) Sign(message []byte) ([]byte, error) {
+	req := map[string]interface{}{
+		"data":       message,
+		"dataCrc32c": strconv.FormatUint(uint64(crc32.Checksum(message, crc32c)), 10),
+	}
+	var resp struct {
+		Signature          []byte `json:"signature"`
+		SignatureCRC32C    string `json:"signatureCrc32c"`
+		VerifiedDataCRC32C bool   `json:"verifiedDataCrc32c"`
+	}
+	if err := s.call(s.ctx, http.MethodPost, ":asymmetricSign", req, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.VerifiedDataCRC32C || !checkCRC32C(resp.Signature, resp.SignatureCRC32C) {
+		return nil, errors.New("Message or signature have been corrupted in transit to Cloud KMS")
+	}
+	if len(resp.Signature) != ed25519.SignatureSize {
+		return nil, errors.New("Cloud KMS returned an invalid signature")
+	}
+	return resp.Signature, nil
+}
+
+func (s *GCPSigner) call(ctx context.Context, method, suffix string, request, response interface{}) error {
+	var body []byte
+	if request != nil {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			return err
+		}
+	}
+	endpoint := s.config.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultGCPEndpoint
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(endpoint, "/")+"/v1/"+s.name+suffix, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	token, err := s.config.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to get Cloud KMS access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	client := s.config.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	respBody, err := readResponse(resp, "Cloud KMS")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("Invalid response of Cloud KMS: %w", err)
+	}
+	return nil
+}
+
+// checkCRC32C checks data against the decimal CRC32C checksum of a Cloud KMS response
+func checkCRC32C(data []byte, checksum string) bool {
+	expected, err := strconv.ParseUint(checksum, 10, 32)
+	return err == nil && uint32(expected) == crc32.Checksum(data, crc32c)
+}
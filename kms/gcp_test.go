@@ -0,0 +1,86 @@
+package kms
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+const testKeyVersion = "projects/smolcert/locations/europe-west3/keyRings/ca/cryptoKeys/root/cryptoKeyVersions/1"
+
+func checksum(data []byte) string {
+	return strconv.FormatUint(uint64(crc32.Checksum(data, crc32c)), 10)
+}
+
+// fakeCloudKMS implements GetPublicKey and AsymmetricSign of Cloud KMS for a single key version.
+// corrupt modifies signatures after their checksum has been calculated.
+func fakeCloudKMS(t *testing.T, key ed25519.PrivateKey, corrupt *bool) http.HandlerFunc {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	require.NoError(t, err)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+testKeyVersion+"/publicKey":
+			json.NewEncoder(w).Encode(map[string]string{
+				"pem": string(pemKey), "pemCrc32c": checksum(pemKey), "algorithm": "EC_SIGN_ED25519",
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/"+testKeyVersion+":asymmetricSign":
+			var req struct {
+				Data       []byte `json:"data"`
+				DataCRC32C string `json:"dataCrc32c"`
+			}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			sig := ed25519.Sign(key, req.Data)
+			sigChecksum := checksum(sig)
+			if *corrupt {
+				sig[0] ^= 1
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"signature": sig, "signatureCrc32c": sigChecksum, "verifiedDataCrc32c": req.DataCRC32C == checksum(req.Data),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestGCPSigner(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	corrupt := false
+	ts := httptest.NewServer(fakeCloudKMS(t, key, &corrupt))
+	defer ts.Close()
+	config := &GCPConfig{Token: StaticGCPToken("test-token"), Endpoint: ts.URL}
+
+	_, err = NewGCPSigner(context.Background(), config, "root")
+	assert.Error(t, err)
+	_, err = NewGCPSigner(context.Background(), config, testKeyVersion[:len(testKeyVersion)-1]+"2")
+	assert.Error(t, err)
+	_, err = NewGCPSigner(context.Background(), &GCPConfig{Token: StaticGCPToken("invalid"), Endpoint: ts.URL}, testKeyVersion)
+	assert.Error(t, err)
+	signer, err := NewGCPSigner(context.Background(), config, testKeyVersion)
+	require.NoError(t, err)
+	assert.Equal(t, key.Public(), signer.Public())
+	testSigner(t, signer)
+
+	corrupt = true
+	_, err = signer.Sign([]byte("message"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+/*
+Package kms signs smolcerts with ed25519 keys held by cloud key management services, so hosted CAs
+never handle their private keys.
+
+AWSSigner uses AWS KMS keys with the key spec ECC_NIST_EDWARDS25519, GCPSigner uses Cloud KMS key
+versions with the algorithm EC_SIGN_ED25519. Both implement smolcert.Signer and talk to the REST
+APIs of the services directly, so no SDK is required:
+
+	signer, err := kms.NewAWSSigner(ctx, &kms.AWSConfig{
+		Region:      "eu-central-1",
+		Credentials: kms.StaticAWSCredentials(kms.AWSCredentials{AccessKeyID: id, SecretAccessKey: secret}),
+	}, "alias/smolcert-root")
+	ca, err := smolcert.NewCAWithSigner(rootCert, signer, store)
+
+The public key is read once when a signer is created and every signature is verified against it by
+the CA, so a misconfigured key can't produce invalid certificates.
+*/
+package kms
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// DefaultTimeout is the timeout of requests to a KMS, if no HTTP client is configured
+const DefaultTimeout = 30 * time.Second
+
+// maxResponseSize limits the size of responses read from a KMS
+const maxResponseSize = 1024 * 1024
+
+var defaultHTTPClient = &http.Client{Timeout: DefaultTimeout}
+
+// readResponse reads the body of resp, returning an error including the body for responses
+// indicating a failure
+func readResponse(resp *http.Response, service string) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s request failed with %s: %s", service, resp.Status, body)
+	}
+	return body, nil
+}
+
+// parsePublicKey parses an ed25519 public key encoded as DER SubjectPublicKeyInfo
+func parsePublicKey(der []byte) (ed25519.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse public key: %w", err)
+	}
+	// x/crypto/ed25519.PublicKey is an alias of the standard library type since Go 1.13
+	pubKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Key is a %T key, not an ed25519 key", key)
+	}
+	return pubKey, nil
+}
+
+// parsePEMPublicKey parses a PEM encoded ed25519 public key
+func parsePEMPublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("Failed to decode PEM encoded public key")
+	}
+	return parsePublicKey(block.Bytes)
+}
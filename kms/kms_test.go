@@ -0,0 +1,34 @@
+package kms
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// testSigner issues a root and a client certificate with a CA using signer
+func testSigner(t *testing.T, signer smolcert.Signer) {
+	rootCert, err := smolcert.NewCertificateBuilder().Subject("root").SelfSigned().PublicKey(signer.Public()).
+		KeyUsage(smolcert.KeyUsageSignCert).Build()
+	require.NoError(t, err)
+	rootCert, err = smolcert.SignCertificateWithSigner(rootCert, signer)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCAWithSigner(rootCert, signer, smolcert.NewMemoryStore())
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey, smolcert.WithValidFor(time.Hour))
+	require.NoError(t, err)
+	assert.NoError(t, smolcert.NewCertPool(rootCert).Validate(cert))
+}
+
+func TestParsePEMPublicKey(t *testing.T) {
+	_, err := parsePEMPublicKey([]byte("invalid"))
+	assert.Error(t, err)
+	_, err = parsePublicKey([]byte{0x30, 0x00})
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+/*
+Package libp2pid exposes smolcert identities as libp2p peer identities, for device meshes built
+with libp2p.
+
+The ed25519 key of a smolcert is used as the libp2p identity key, so the peer ID is derived from
+the certificate. The encodings of keys and peer IDs are those of libp2p, without depending on it:
+
+	id, _ := libp2pid.NewIdentity(bundle, key)
+	privKey, _ := crypto.UnmarshalPrivateKey(id.MarshalPrivateKey())
+	host, _ := libp2p.New(libp2p.Identity(privKey))
+
+The secure channel of libp2p authenticates the key behind the peer ID of the remote peer. Peers
+exchange their certificates, i.e. via the HandshakePayload sent on a dedicated protocol stream,
+and check them with VerifyPeer, which validates the chain and binds it to the peer ID.
+*/
+package libp2pid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	// keyTypeEd25519 is the libp2p KeyType of ed25519 keys
+	keyTypeEd25519 = 1
+	// multihashIdentity is the multihash code of the identity hash, used for peer IDs of small keys
+	multihashIdentity = 0x00
+)
+
+// ErrPeerMismatch indicates that the certificate of a peer doesn't belong to its peer ID
+var ErrPeerMismatch = errors.New("certificate doesn't belong to the peer ID")
+
+// PeerID is a libp2p peer ID in its binary form, like peer.ID of libp2p
+type PeerID string
+
+// IDFromPublicKey returns the peer ID of an ed25519 public key
+func IDFromPublicKey(pubKey ed25519.PublicKey) PeerID {
+	key := MarshalPublicKey(pubKey)
+	return PeerID(append([]byte{multihashIdentity, byte(len(key))}, key...))
+}
+
+// IDFromCertificate returns the peer ID of the key of cert
+func IDFromCertificate(cert *smolcert.Certificate) PeerID {
+	return IDFromPublicKey(cert.PubKey)
+}
+
+// ParsePeerID parses a peer ID from its base58 encoded textual form
+func ParsePeerID(s string) (PeerID, error) {
+	buf, err := decodeBase58(s)
+	if err != nil {
+		return "", err
+	}
+	id := PeerID(buf)
+	if _, err := id.PublicKey(); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// String returns the base58 encoded textual form of the peer ID
+func (id PeerID) String() string {
+	return encodeBase58([]byte(id))
+}
+
+// PublicKey returns the ed25519 public key embedded in the peer ID
+func (id PeerID) PublicKey() (ed25519.PublicKey, error) {
+	if len(id) < 2 || id[0] != multihashIdentity || int(id[1]) != len(id)-2 {
+		return nil, errors.New("Peer ID doesn't embed an ed25519 public key")
+	}
+	return UnmarshalPublicKey([]byte(id[2:]))
+}
+
+// MarshalPublicKey encodes pubKey like crypto.MarshalPublicKey of libp2p
+func MarshalPublicKey(pubKey ed25519.PublicKey) []byte {
+	return marshalKey(pubKey)
+}
+
+// UnmarshalPublicKey decodes an ed25519 public key encoded like by crypto.MarshalPublicKey of libp2p
+func UnmarshalPublicKey(buf []byte) (ed25519.PublicKey, error) {
+	data, err := unmarshalKey(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Unexpected length of ed25519 public key (expected %d bytes, got %d bytes)",
+			ed25519.PublicKeySize, len(data))
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// MarshalPrivateKey encodes key like crypto.MarshalPrivateKey of libp2p
+func MarshalPrivateKey(key ed25519.PrivateKey) []byte {
+	return marshalKey(key)
+}
+
+// marshalKey encodes the protobuf message of libp2p keys: the key type as field 1 and the key as
+// field 2. Both fit into a single byte varint for ed25519 keys.
+func marshalKey(data []byte) []byte {
+	return append([]byte{0x08, keyTypeEd25519, 0x12, byte(len(data))}, data...)
+}
+
+func unmarshalKey(buf []byte) ([]byte, error) {
+	if len(buf) < 4 || buf[0] != 0x08 || buf[2] != 0x12 {
+		return nil, errors.New("Invalid libp2p key encoding")
+	}
+	if buf[1] != keyTypeEd25519 {
+		return nil, fmt.Errorf("Unsupported libp2p key type %d", buf[1])
+	}
+	if int(buf[3]) != len(buf)-4 {
+		return nil, errors.New("Invalid length of libp2p key")
+	}
+	return buf[4:], nil
+}
+
+// Identity is the libp2p identity of a device with a smolcert
+type Identity struct {
+	// Certificates contains the certificate of the device first, followed by intermediates
+	Certificates []*smolcert.Certificate
+	Key          ed25519.PrivateKey
+}
+
+// NewIdentity creates the Identity of the owner of key, certified by bundle
+func NewIdentity(bundle []*smolcert.Certificate, key ed25519.PrivateKey) (*Identity, error) {
+	if len(bundle) == 0 {
+		return nil, errors.New("Identity needs a certificate")
+	}
+	if !bytes.Equal(bundle[0].PubKey, key.Public().(ed25519.PublicKey)) {
+		return nil, errors.New("Private key doesn't belong to the certificate")
+	}
+	return &Identity{Certificates: bundle, Key: key}, nil
+}
+
+// ID returns the peer ID of the identity
+func (i *Identity) ID() PeerID {
+	return IDFromCertificate(i.Certificates[0])
+}
+
+// MarshalPrivateKey encodes the key of the identity for crypto.UnmarshalPrivateKey of libp2p
+func (i *Identity) MarshalPrivateKey() []byte {
+	return MarshalPrivateKey(i.Key)
+}
+
+// HandshakePayload returns the encoded certificates of the identity, which are sent to remote
+// peers to be verified with VerifyPeer
+func (i *Identity) HandshakePayload() ([]byte, error) {
+	return smolcert.NewBundle(i.Certificates...).Bytes()
+}
+
+// VerifyPeer validates the certificates in payload, which have been received from the peer with
+// the given ID, with v and checks that the certificate belongs to the key of the peer. The
+// validated certificate of the peer is returned.
+func VerifyPeer(v *smolcert.Validator, id PeerID, payload []byte) (*smolcert.Certificate, error) {
+	bundle, err := smolcert.ParseBundleBuf(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid certificates of peer: %w", err)
+	}
+	cert, err := bundle.ValidateWith(v)
+	if err != nil {
+		return nil, err
+	}
+	if IDFromCertificate(cert) != id {
+		return nil, fmt.Errorf("%w: certificate of '%s' doesn't belong to %s", ErrPeerMismatch, cert.Subject, id)
+	}
+	return cert, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// encodeBase58 encodes buf with the bitcoin alphabet used by libp2p, leading zeros are encoded as '1'
+func encodeBase58(buf []byte) string {
+	n := new(big.Int).SetBytes(buf)
+	radix, mod := big.NewInt(58), new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range buf {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func decodeBase58(s string) ([]byte, error) {
+	n, radix := new(big.Int), big.NewInt(58)
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	for _, c := range []byte(s) {
+		digit := bytes.IndexByte([]byte(base58Alphabet), c)
+		if digit < 0 {
+			return nil, fmt.Errorf("Invalid base58 character '%c'", c)
+		}
+		n.Mul(n, radix).Add(n, big.NewInt(int64(digit)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
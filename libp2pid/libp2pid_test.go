@@ -0,0 +1,90 @@
+package libp2pid
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestBase58(t *testing.T) {
+	assert.Equal(t, "2NEpo7TZRRrLZSi2U", encodeBase58([]byte("Hello World!")))
+	assert.Equal(t, "11", encodeBase58([]byte{0, 0}))
+	for _, buf := range [][]byte{{}, {0}, {0, 0, 1}, []byte("Hello World!")} {
+		decoded, err := decodeBase58(encodeBase58(buf))
+		require.NoError(t, err)
+		assert.Equal(t, buf, decoded)
+	}
+	_, err := decodeBase58("0OIl")
+	assert.Error(t, err)
+}
+
+func TestPeerID(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	id := IDFromPublicKey(pubKey)
+	// Peer IDs of ed25519 keys embed the key and always start with the same characters
+	assert.True(t, strings.HasPrefix(id.String(), "12D3KooW"), id.String())
+	assert.Len(t, id, 38)
+	parsed, err := ParsePeerID(id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+	key, err := parsed.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, pubKey, key)
+
+	_, err = ParsePeerID("QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N")
+	assert.Error(t, err)
+
+	encoded := MarshalPrivateKey(privKey)
+	assert.Len(t, encoded, 68)
+	assert.Equal(t, []byte{0x08, 0x01, 0x12, 0x40}, encoded[:4])
+	decodedPub, err := UnmarshalPublicKey(MarshalPublicKey(pubKey))
+	require.NoError(t, err)
+	assert.Equal(t, pubKey, decodedPub)
+	_, err = UnmarshalPublicKey(encoded)
+	assert.Error(t, err)
+	_, err = UnmarshalPublicKey([]byte{0x08, 0x02, 0x12, 0x00})
+	assert.Error(t, err)
+}
+
+func TestVerifyPeer(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, key, err := smolcert.ClientCertificate("device", 1, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	other, otherKey, err := smolcert.ClientCertificate("other", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	_, err = NewIdentity([]*smolcert.Certificate{cert}, otherKey)
+	assert.Error(t, err)
+	_, err = NewIdentity(nil, key)
+	assert.Error(t, err)
+	identity, err := NewIdentity([]*smolcert.Certificate{cert}, key)
+	require.NoError(t, err)
+	assert.Equal(t, IDFromCertificate(cert), identity.ID())
+	assert.Equal(t, MarshalPrivateKey(key), identity.MarshalPrivateKey())
+	payload, err := identity.HandshakePayload()
+	require.NoError(t, err)
+
+	v := smolcert.NewValidator(smolcert.NewCertPool(rootCert))
+	verified, err := VerifyPeer(v, identity.ID(), payload)
+	require.NoError(t, err)
+	assert.Equal(t, "device", verified.Subject)
+
+	_, err = VerifyPeer(v, IDFromCertificate(other), payload)
+	assert.True(t, errors.Is(err, ErrPeerMismatch))
+	otherRoot, _, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, err = VerifyPeer(smolcert.NewValidator(smolcert.NewCertPool(otherRoot)), identity.ID(), payload)
+	assert.Error(t, err)
+	_, err = VerifyPeer(v, identity.ID(), []byte{0x01})
+	assert.Error(t, err)
+}
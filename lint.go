@@ -0,0 +1,166 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// ErrLintFailed is returned if a certificate fails a Lint with LintError severity
+var ErrLintFailed = errors.New("certificate failed linting")
+
+// LintSeverity decides whether a failed Lint prevents the issuance of a certificate
+type LintSeverity uint8
+
+// Defined LintSeverities
+const (
+	// LintWarning reports problems which are acceptable in some deployments
+	LintWarning LintSeverity = iota + 1
+	// LintError reports problems which need to prevent the issuance
+	LintError
+)
+
+// String returns a String representation for logging and debugging
+func (s LintSeverity) String() string {
+	switch s {
+	case LintWarning:
+		return "warning"
+	case LintError:
+		return "error"
+	default:
+		return fmt.Sprintf("LintSeverity(%d)", uint8(s))
+	}
+}
+
+// Lint is a check of certificates before they are signed, i.e. for violations of the issuance
+// policies of a deployment
+type Lint struct {
+	// Name identifies the lint in results, i.e. "validity_missing"
+	Name     string
+	Severity LintSeverity
+	// Check returns an error describing the problem if cert fails the lint
+	Check func(cert *Certificate) error
+}
+
+// LintResult is a failed Lint
+type LintResult struct {
+	Name     string
+	Severity LintSeverity
+	Err      error
+}
+
+// String returns a String representation for logging and debugging
+func (r LintResult) String() string {
+	return fmt.Sprintf("%s %s: %s", r.Severity, r.Name, r.Err)
+}
+
+// Names of the built-in lints
+const (
+	LintValidityMissing          = "validity_missing"
+	LintCAWithoutPathConstraints = "ca_without_path_constraints"
+	LintDuplicateExtension       = "duplicate_extension"
+	LintUnknownCriticalExtension = "unknown_critical_extension"
+	LintWeakSerialNumber         = "weak_serial_number"
+)
+
+// minSerialNumberBits is the minimum length of serial numbers, random 64 bit serial numbers are
+// shorter with a probability of 2^-32
+const minSerialNumberBits = 32
+
+// DefaultLints returns the built-in lints
+func DefaultLints() []Lint {
+	return []Lint{
+		{Name: LintValidityMissing, Severity: LintError, Check: lintValidity},
+		{Name: LintCAWithoutPathConstraints, Severity: LintWarning, Check: lintPathConstraints},
+		{Name: LintDuplicateExtension, Severity: LintError, Check: checkForDoubleExtensions},
+		{Name: LintUnknownCriticalExtension, Severity: LintError, Check: checkCriticalExtensions},
+		{Name: LintWeakSerialNumber, Severity: LintWarning, Check: lintSerialNumber},
+	}
+}
+
+func lintValidity(cert *Certificate) error {
+	if cert.Validity == nil || cert.Validity.NotBefore.IsZero() || cert.Validity.NotAfter.IsZero() {
+		return errors.New("Certificate has no bounded validity")
+	}
+	if cert.Validity.NotAfter.StdTime().Before(cert.Validity.NotBefore.StdTime()) {
+		return errors.New("Certificate expires before it becomes valid")
+	}
+	return nil
+}
+
+func lintPathConstraints(cert *Certificate) error {
+	if RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)) != nil {
+		return nil
+	}
+	for _, oid := range []uint64{OIDNameConstraints, OIDIssuerPolicy, OIDCapabilities} {
+		for _, ext := range cert.Extensions {
+			if ext.OID == oid {
+				return nil
+			}
+		}
+	}
+	return errors.New("CA certificate has neither NameConstraints, IssuerPolicy nor Capabilities")
+}
+
+func lintSerialNumber(cert *Certificate) error {
+	if bits.Len64(cert.SerialNumber) < minSerialNumberBits {
+		return fmt.Errorf("Serial number %d has less than %d bits, it doesn't seem to be random", cert.SerialNumber,
+			minSerialNumberBits)
+	}
+	return nil
+}
+
+// Linter runs lints against certificates. A CA with a Linter runs it before signing certificates.
+type Linter struct {
+	// Lints are run in order
+	Lints []Lint
+}
+
+// NewLinter creates a Linter running the DefaultLints followed by the custom lints
+func NewLinter(lints ...Lint) *Linter {
+	return &Linter{Lints: append(DefaultLints(), lints...)}
+}
+
+// Add appends lint. A Linter must not be modified while it is in use.
+func (l *Linter) Add(lint Lint) {
+	l.Lints = append(l.Lints, lint)
+}
+
+// Disable removes the lints with the given names, i.e. to accept serial numbers from a counter
+func (l *Linter) Disable(names ...string) {
+	lints := l.Lints[:0]
+	for _, lint := range l.Lints {
+		if !containsString(names, lint.Name) {
+			lints = append(lints, lint)
+		}
+	}
+	l.Lints = lints
+}
+
+// Run returns the results of all lints which cert fails
+func (l *Linter) Run(cert *Certificate) []LintResult {
+	var results []LintResult
+	for _, lint := range l.Lints {
+		if err := lint.Check(cert); err != nil {
+			results = append(results, LintResult{Name: lint.Name, Severity: lint.Severity, Err: err})
+		}
+	}
+	return results
+}
+
+// Check runs the lints and returns an error wrapping ErrLintFailed if cert fails any lint with
+// LintError severity. The failed lints are returned in either case, so warnings can be logged.
+func (l *Linter) Check(cert *Certificate) ([]LintResult, error) {
+	results := l.Run(cert)
+	var failed []string
+	for _, r := range results {
+		if r.Severity >= LintError {
+			failed = append(failed, r.String())
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("%w: %s", ErrLintFailed, strings.Join(failed, ", "))
+	}
+	return results, nil
+}
@@ -0,0 +1,105 @@
+package smolcert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type lintLogger struct {
+	warnings []string
+}
+
+func (l *lintLogger) Debug(msg string, args ...interface{}) {}
+
+func (l *lintLogger) Info(msg string, args ...interface{}) {}
+
+func (l *lintLogger) Warn(msg string, args ...interface{}) {
+	l.warnings = append(l.warnings, msg)
+}
+
+func lintNames(results []LintResult) []string {
+	names := []string{}
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestLinter(t *testing.T) {
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	now := time.Now()
+	linter := NewLinter()
+
+	good, err := NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pubKey).
+		SerialNumber(1<<40 + 1).NotBefore(now).NotAfter(now.Add(time.Hour)).KeyUsage(KeyUsageClientIdentification).Build()
+	require.NoError(t, err)
+	assert.Empty(t, linter.Run(good))
+
+	bad := &Certificate{
+		Subject:      "device",
+		Issuer:       "root",
+		SerialNumber: 2,
+		PubKey:       pubKey,
+		Extensions: []Extension{
+			{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()},
+			{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()},
+			{OID: 0xFFFF, Critical: true},
+		},
+	}
+	results, err := linter.Check(bad)
+	assert.True(t, errors.Is(err, ErrLintFailed))
+	assert.Equal(t, []string{LintValidityMissing, LintCAWithoutPathConstraints, LintDuplicateExtension,
+		LintUnknownCriticalExtension, LintWeakSerialNumber}, lintNames(results))
+	assert.Equal(t, LintWarning, results[1].Severity)
+	assert.True(t, strings.HasPrefix(results[0].String(), "error validity_missing: "))
+
+	// Warnings don't fail the check
+	linter.Disable(LintValidityMissing, LintDuplicateExtension, LintUnknownCriticalExtension)
+	results, err = linter.Check(bad)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{LintCAWithoutPathConstraints, LintWeakSerialNumber}, lintNames(results))
+
+	// Constrained CAs pass
+	ext, err := NameConstraintsExtension(&NameConstraints{PermittedPrefixes: []string{"site-a/"}})
+	require.NoError(t, err)
+	constrained := &Certificate{SerialNumber: 1 << 50, Extensions: []Extension{
+		{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}, ext}}
+	assert.Empty(t, linter.Run(constrained))
+
+	linter.Add(Lint{Name: "subject_prefix", Severity: LintError, Check: func(cert *Certificate) error {
+		if !strings.HasPrefix(cert.Subject, "site-a/") {
+			return errors.New("Subject needs to start with site-a/")
+		}
+		return nil
+	}})
+	_, err = linter.Check(good)
+	assert.True(t, errors.Is(err, ErrLintFailed))
+	assert.Contains(t, err.Error(), "subject_prefix")
+}
+
+func TestCALinter(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.Linter = NewLinter()
+	logger := &lintLogger{}
+	ca.Logger = logger
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	_, err = ca.Issue("device", pubKey)
+	assert.True(t, errors.Is(err, ErrLintFailed))
+	_, err = ca.Issue("device", pubKey, WithValidFor(time.Hour))
+	assert.NoError(t, err)
+	_, err = ca.Issue("sub-ca", pubKey, WithValidFor(time.Hour), WithKeyUsage(KeyUsageSignCert))
+	assert.NoError(t, err)
+	assert.Contains(t, logger.warnings, "Certificate failed lint")
+}
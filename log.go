@@ -0,0 +1,11 @@
+package smolcert
+
+// Logger receives structured events of Validators and CAs. The methods match those of
+// *slog.Logger, so a *slog.Logger can be used directly. The arguments are alternating keys and
+// values. Rejections are logged as warnings, successful validations as debug messages and CA
+// operations as info messages.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
@@ -0,0 +1,99 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// logEntry is an event recorded by testLogger
+type logEntry struct {
+	level string
+	msg   string
+	attrs map[string]interface{}
+}
+
+// testLogger records the events passed to it, like a *slog.Logger with a handler would
+type testLogger struct {
+	entries []logEntry
+}
+
+func (l *testLogger) log(level, msg string, args []interface{}) {
+	entry := logEntry{level: level, msg: msg, attrs: map[string]interface{}{}}
+	for i := 0; i+1 < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			continue
+		}
+		entry.attrs[key] = args[i+1]
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args) }
+
+// take returns the recorded entries and resets the logger
+func (l *testLogger) take() []logEntry {
+	entries := l.entries
+	l.entries = nil
+	return entries
+}
+
+func TestLogger(t *testing.T) {
+	logger := &testLogger{}
+
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.Logger = logger
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, ca.Revoke(cert.SerialNumber, RevocationReasonSuperseded))
+	_, err = ca.GenerateCRL()
+	require.NoError(t, err)
+
+	entries := logger.take()
+	require.Len(t, entries, 3)
+	assert.Equal(t, "INFO", entries[0].level)
+	assert.Equal(t, "Certificate issued", entries[0].msg)
+	assert.Equal(t, "device", entries[0].attrs["subject"])
+	assert.Equal(t, cert.ID().String(), entries[0].attrs["cert_id"])
+	assert.Equal(t, "Certificate revoked", entries[1].msg)
+	assert.Equal(t, "Superseded", entries[1].attrs["reason"])
+	assert.Equal(t, cert.ID().String(), entries[1].attrs["cert_id"])
+	assert.Equal(t, "CRL generated", entries[2].msg)
+
+	validator := NewValidator(NewCertPool(rootCert))
+	validator.Logger = logger
+	require.NoError(t, validator.Validate(cert))
+	entries = logger.take()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "DEBUG", entries[0].level)
+	assert.Equal(t, "root", entries[0].attrs["root"])
+	assert.Equal(t, PinFromCertificate(rootCert).String(), entries[0].attrs["root_pin"])
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	forged, _, err := ClientCertificate("device", 1, time.Time{}, time.Time{}, nil, otherKey, "root")
+	require.NoError(t, err)
+	_, err = validator.ValidateBundle([]*Certificate{forged})
+	require.Error(t, err)
+	entries = logger.take()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "WARN", entries[0].level)
+	assert.Equal(t, "bad_signature", entries[0].attrs["reason"])
+	assert.Equal(t, "device", entries[0].attrs["subject"])
+
+	// Without a logger nothing is logged
+	validator.Logger = nil
+	require.NoError(t, validator.Validate(cert))
+	assert.Empty(t, logger.take())
+}
@@ -0,0 +1,170 @@
+package smolcert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// manifestContext is prepended to signed manifests, so that manifest signatures can't be mistaken
+// for signatures of other structures.
+var manifestContext = []byte("smolcert firmware manifest")
+
+// ErrManifestNotApplicable indicates that a valid Manifest doesn't apply to the verifying device
+var ErrManifestNotApplicable = errors.New("manifest is not applicable to this device")
+
+// Manifest describes a firmware image (or any other artifact) and is signed by the holder of a
+// certificate. Devices verify the Manifest and the certificate chain of the signer before installing
+// the artifact.
+type Manifest struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Version is the human readable version of the artifact
+	Version string `cbor:"version"`
+	// SequenceNumber increases with every release and protects against rollbacks
+	SequenceNumber uint64 `cbor:"sequence_number"`
+	// ArtifactDigest is the SHA-256 hash of the artifact
+	ArtifactDigest []byte `cbor:"artifact_digest"`
+	// ArtifactSize is the size of the artifact in bytes
+	ArtifactSize uint64 `cbor:"artifact_size"`
+	// Model restricts the manifest to a device model, an empty Model applies to all models
+	Model string `cbor:"model"`
+	// Devices restricts the manifest to the devices with these subjects, if not empty
+	Devices []string `cbor:"devices"`
+	// Certificates contains the certificate of the signer first, followed by intermediates
+	Certificates []*Certificate `cbor:"certificates"`
+	Signature    []byte         `cbor:"signature"`
+}
+
+// DeviceInfo describes the device verifying a Manifest
+type DeviceInfo struct {
+	// Subject is the subject of the certificate of the device
+	Subject string
+	// Model is the model of the device
+	Model string
+	// SequenceNumber is the sequence number of the currently installed artifact
+	SequenceNumber uint64
+}
+
+// NewManifest creates an unsigned Manifest for the artifact read from r
+func NewManifest(version string, sequenceNumber uint64, r io.Reader) (*Manifest, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Version:        version,
+		SequenceNumber: sequenceNumber,
+		ArtifactDigest: h.Sum(nil),
+		ArtifactSize:   uint64(n),
+	}, nil
+}
+
+// ParseManifest parses a Manifest from an existing byte buffer
+func ParseManifest(buf []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := cbor.Unmarshal(buf, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Bytes returns the CBOR encoded form of the Manifest
+func (m *Manifest) Bytes() ([]byte, error) {
+	return cborEm.Marshal(m)
+}
+
+func (m *Manifest) tbsBytes() ([]byte, error) {
+	tbs := *m
+	tbs.Certificates = nil
+	tbs.Signature = nil
+	buf, err := cborEm.Marshal(&tbs)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, manifestContext...), buf...), nil
+}
+
+// Sign signs the Manifest with the private key of the first certificate of chain and embeds the chain
+func (m *Manifest) Sign(chain []*Certificate, priv ed25519.PrivateKey) error {
+	if len(chain) == 0 {
+		return errors.New("Manifest needs to be signed with a certificate")
+	}
+	if !bytes.Equal(priv.Public().(ed25519.PublicKey), chain[0].PubKey) {
+		return errors.New("Private key doesn't belong to the signing certificate")
+	}
+	tbs, err := m.tbsBytes()
+	if err != nil {
+		return err
+	}
+	m.Certificates = chain
+	m.Signature = ed25519.Sign(priv, tbs)
+	return nil
+}
+
+// Verify validates the embedded certificate chain against the CertPool, verifies the signature and
+// checks that the Manifest applies to device and is newer than the installed artifact. The signer
+// certificate is returned on success.
+func (m *Manifest) Verify(pool *CertPool, device DeviceInfo) (*Certificate, error) {
+	if len(m.Certificates) == 0 {
+		return nil, errors.New("Manifest doesn't contain a certificate")
+	}
+	signer, err := pool.ValidateBundle(m.Certificates)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signer certificate: %w", err)
+	}
+	if signer != m.Certificates[0] {
+		return nil, errors.New("The first certificate of the manifest is not the leaf certificate")
+	}
+	tbs, err := m.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(signer.PubKey, tbs, m.Signature) {
+		return nil, newValidationError(ErrBadSignature, signer, "Signature validation of manifest failed")
+	}
+
+	if m.Model != "" && m.Model != device.Model {
+		return nil, fmt.Errorf("%w: manifest is for model '%s'", ErrManifestNotApplicable, m.Model)
+	}
+	if len(m.Devices) > 0 && !containsString(m.Devices, device.Subject) {
+		return nil, fmt.Errorf("%w: device '%s' is not listed", ErrManifestNotApplicable, device.Subject)
+	}
+	if m.SequenceNumber <= device.SequenceNumber {
+		return nil, fmt.Errorf("%w: sequence number %d is not newer than the installed %d",
+			ErrManifestNotApplicable, m.SequenceNumber, device.SequenceNumber)
+	}
+	return signer, nil
+}
+
+// VerifyArtifact checks that the artifact read from r matches the digest and size of the Manifest
+func (m *Manifest) VerifyArtifact(r io.Reader) error {
+	h := sha256.New()
+	// Read at most one byte more than expected to detect oversized artifacts
+	n, err := io.Copy(h, io.LimitReader(r, int64(m.ArtifactSize)+1))
+	if err != nil {
+		return err
+	}
+	if uint64(n) != m.ArtifactSize {
+		return fmt.Errorf("Artifact size doesn't match the manifest (expected %d bytes)", m.ArtifactSize)
+	}
+	if !bytes.Equal(h.Sum(nil), m.ArtifactDigest) {
+		return errors.New("Artifact digest doesn't match the manifest")
+	}
+	return nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
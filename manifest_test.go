@@ -0,0 +1,80 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	interCert, interKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	signerCert, signerKey, err := ClientCertificate("release signer", 3, time.Time{}, time.Time{}, nil, interKey, "intermediate")
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	firmware := bytes.Repeat([]byte{0x42}, 4096)
+	m, err := NewManifest("1.2.0", 12, bytes.NewReader(firmware))
+	require.NoError(t, err)
+	m.Model = "sensor-v2"
+	require.NoError(t, m.Sign([]*Certificate{signerCert, interCert}, signerKey))
+
+	buf, err := m.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseManifest(buf)
+	require.NoError(t, err)
+
+	device := DeviceInfo{Subject: "device1", Model: "sensor-v2", SequenceNumber: 11}
+	signer, err := parsed.Verify(pool, device)
+	require.NoError(t, err)
+	assert.Equal(t, "release signer", signer.Subject)
+	assert.NoError(t, parsed.VerifyArtifact(bytes.NewReader(firmware)))
+	assert.Error(t, parsed.VerifyArtifact(bytes.NewReader(firmware[1:])))
+	assert.Error(t, parsed.VerifyArtifact(bytes.NewReader(append(firmware, 0))))
+	firmware[0] = 0
+	assert.Error(t, parsed.VerifyArtifact(bytes.NewReader(firmware)))
+
+	// Rollbacks and other models are rejected
+	device.SequenceNumber = 12
+	_, err = parsed.Verify(pool, device)
+	assert.True(t, errors.Is(err, ErrManifestNotApplicable))
+	device.SequenceNumber = 0
+	device.Model = "sensor-v1"
+	_, err = parsed.Verify(pool, device)
+	assert.True(t, errors.Is(err, ErrManifestNotApplicable))
+
+	// Modifications invalidate the signature
+	parsed.Model = ""
+	_, err = parsed.Verify(pool, device)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+
+	// Signers from other roots are not trusted
+	_, err = parsed.Verify(NewCertPool(), device)
+	assert.Error(t, err)
+}
+
+func TestManifestDevices(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	m, err := NewManifest("1.0.0", 1, bytes.NewReader([]byte("firmware")))
+	require.NoError(t, err)
+	signerCert, signerKey, err := ClientCertificate("release signer", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	m.Devices = []string{"device1"}
+	assert.Error(t, m.Sign(nil, signerKey))
+	assert.Error(t, m.Sign([]*Certificate{signerCert}, rootKey))
+	require.NoError(t, m.Sign([]*Certificate{signerCert}, signerKey))
+
+	pool := NewCertPool(rootCert)
+	_, err = m.Verify(pool, DeviceInfo{Subject: "device1"})
+	assert.NoError(t, err)
+	_, err = m.Verify(pool, DeviceInfo{Subject: "device2"})
+	assert.True(t, errors.Is(err, ErrManifestNotApplicable))
+}
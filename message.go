@@ -0,0 +1,134 @@
+package smolcert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// messageContext is prepended to signed messages, so that message signatures can't be mistaken
+// for signatures of other structures.
+var messageContext = []byte("smolcert signed message")
+
+// SignedMessage is a signed envelope for arbitrary payloads. The signature always covers the
+// SHA-256 digest of the payload, so the payload can either be embedded or be transported
+// separately (detached). The signer is referenced by issuer and serial number of its certificate,
+// which can optionally be embedded together with intermediate certificates.
+type SignedMessage struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Payload is nil for detached messages
+	Payload      []byte `cbor:"payload"`
+	Digest       []byte `cbor:"digest"`
+	Issuer       string `cbor:"issuer"`
+	SerialNumber uint64 `cbor:"serial_number"`
+	// Certificates optionally contains the certificate of the signer first, followed by intermediates
+	Certificates []*Certificate `cbor:"certificates"`
+	Signature    []byte         `cbor:"signature"`
+}
+
+// SignMessage creates a SignedMessage embedding payload, signed with the private key of signer
+func SignMessage(payload []byte, signer *Certificate, priv ed25519.PrivateKey) (*SignedMessage, error) {
+	m, err := SignDetached(payload, signer, priv)
+	if err != nil {
+		return nil, err
+	}
+	m.Payload = append([]byte{}, payload...)
+	return m, nil
+}
+
+// SignDetached creates a SignedMessage for payload without embedding it, signed with the private
+// key of signer
+func SignDetached(payload []byte, signer *Certificate, priv ed25519.PrivateKey) (*SignedMessage, error) {
+	if !bytes.Equal(priv.Public().(ed25519.PublicKey), signer.PubKey) {
+		return nil, errors.New("Private key doesn't belong to the signing certificate")
+	}
+	digest := sha256.Sum256(payload)
+	m := &SignedMessage{
+		Digest:       digest[:],
+		Issuer:       signer.Issuer,
+		SerialNumber: signer.SerialNumber,
+	}
+	tbs, err := m.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	m.Signature = ed25519.Sign(priv, tbs)
+	return m, nil
+}
+
+// ParseSignedMessage parses a SignedMessage from an existing byte buffer
+func ParseSignedMessage(buf []byte) (*SignedMessage, error) {
+	m := &SignedMessage{}
+	if err := cbor.Unmarshal(buf, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Bytes returns the CBOR encoded form of the SignedMessage
+func (m *SignedMessage) Bytes() ([]byte, error) {
+	return cborEm.Marshal(m)
+}
+
+// IsDetached is true if the payload is not embedded in the message
+func (m *SignedMessage) IsDetached() bool {
+	return m.Payload == nil
+}
+
+func (m *SignedMessage) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&SignedMessage{
+		Digest:       m.Digest,
+		Issuer:       m.Issuer,
+		SerialNumber: m.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, messageContext...), buf...), nil
+}
+
+// Verify checks that the message has been signed by signer. payload is required for detached
+// messages and is ignored otherwise. The certificate of the signer is not validated.
+func (m *SignedMessage) Verify(signer *Certificate, payload []byte) error {
+	if m.Issuer != signer.Issuer || m.SerialNumber != signer.SerialNumber {
+		return fmt.Errorf("Message has been signed by serial %d from '%s', not by the given certificate",
+			m.SerialNumber, m.Issuer)
+	}
+	if !m.IsDetached() {
+		payload = m.Payload
+	}
+	digest := sha256.Sum256(payload)
+	if !bytes.Equal(digest[:], m.Digest) {
+		return errors.New("Payload doesn't match the digest of the message")
+	}
+	tbs, err := m.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(signer.PubKey, tbs, m.Signature) {
+		return newValidationError(ErrBadSignature, signer, "Signature validation of message failed")
+	}
+	return nil
+}
+
+// VerifySignedMessage validates the certificates embedded in m against the CertPool and verifies
+// the signature of m. payload is required for detached messages. The certificate of the signer is
+// returned on success.
+func (c *CertPool) VerifySignedMessage(m *SignedMessage, payload []byte) (*Certificate, error) {
+	if len(m.Certificates) == 0 {
+		return nil, errors.New("Message doesn't contain the certificate of the signer")
+	}
+	signer, err := c.ValidateBundle(m.Certificates)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signer certificate: %w", err)
+	}
+	if err := m.Verify(signer, payload); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
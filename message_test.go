@@ -0,0 +1,65 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedMessage(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	signerCert, signerKey, err := ClientCertificate("signer", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	otherCert, _, err := ClientCertificate("signer", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	payload := []byte("hello world")
+	m, err := SignMessage(payload, signerCert, signerKey)
+	require.NoError(t, err)
+	assert.False(t, m.IsDetached())
+	m.Certificates = []*Certificate{signerCert}
+
+	buf, err := m.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseSignedMessage(buf)
+	require.NoError(t, err)
+	assert.Equal(t, payload, parsed.Payload)
+	assert.NoError(t, parsed.Verify(signerCert, nil))
+	assert.Error(t, parsed.Verify(otherCert, nil))
+
+	signer, err := NewCertPool(rootCert).VerifySignedMessage(parsed, nil)
+	require.NoError(t, err)
+	assert.Equal(t, signerCert.SerialNumber, signer.SerialNumber)
+	_, err = NewCertPool().VerifySignedMessage(parsed, nil)
+	assert.Error(t, err)
+
+	parsed.Payload = []byte("hello World")
+	assert.Error(t, parsed.Verify(signerCert, nil))
+	parsed.Payload = payload
+	parsed.Signature[0] ^= 0xff
+	assert.True(t, errors.Is(parsed.Verify(signerCert, nil), ErrBadSignature))
+}
+
+func TestDetachedSignedMessage(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	signerCert, signerKey, err := ClientCertificate("signer", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	_, err = SignDetached([]byte("payload"), signerCert, rootKey)
+	assert.Error(t, err)
+
+	m, err := SignDetached([]byte("payload"), signerCert, signerKey)
+	require.NoError(t, err)
+	assert.True(t, m.IsDetached())
+	assert.NoError(t, m.Verify(signerCert, []byte("payload")))
+	assert.Error(t, m.Verify(signerCert, []byte("other payload")))
+	assert.Error(t, m.Verify(signerCert, nil))
+
+	_, err = NewCertPool().VerifySignedMessage(m, []byte("payload"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package smolcert
+
+import (
+	"errors"
+	"time"
+)
+
+// Metrics receives events of Validators and CAs for monitoring, i.e. to export them to Prometheus.
+// Implementations need to be safe for concurrent use and should return quickly.
+type Metrics interface {
+	// ObserveValidation is called after every validation with its duration and result
+	ObserveValidation(duration time.Duration, err error)
+	// ObserveIssuance is called after every attempt to issue a certificate, cert is nil if
+	// issuance failed
+	ObserveIssuance(cert *Certificate, err error)
+	// ObserveRevocation is called after every successful revocation
+	ObserveRevocation(entry RevokedCertificate)
+}
+
+// failureReasons maps the validation errors of this package to short names
+var failureReasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrExpired, "expired"},
+	{ErrNotYetValid, "not_yet_valid"},
+	{ErrUnknownIssuer, "unknown_issuer"},
+	{ErrBadSignature, "bad_signature"},
+	{ErrUntrustedRoot, "untrusted_root"},
+	{ErrInvalidKeyUsage, "invalid_key_usage"},
+	{ErrDuplicateExtension, "duplicate_extension"},
+	{ErrUnknownCriticalExtension, "unknown_critical_extension"},
+	{ErrMalformedCertificate, "malformed_certificate"},
+	{ErrNameConstraintViolation, "name_constraint_violation"},
+	{ErrCapabilityViolation, "capability_violation"},
+	{ErrRevoked, "revoked"},
+	{ErrUnboundedValidity, "unbounded_validity"},
+	{ErrUnsupportedVersion, "unsupported_version"},
+	{ErrRejectedByHook, "rejected_by_hook"},
+	{ErrUnsupportedAlgorithm, "unsupported_algorithm"},
+	{ErrThresholdNotMet, "threshold_not_met"},
+	{ErrPolicyViolation, "policy_violation"},
+	{ErrAudienceMismatch, "audience_mismatch"},
+	{ErrNameMismatch, "name_mismatch"},
+}
+
+// FailureReason returns a short name for the cause of a validation error, suitable as metric
+// label. It returns "" for nil and "other" for errors not caused by one of the Err* errors of
+// this package.
+func FailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, r := range failureReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,84 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type recordingMetrics struct {
+	lock        sync.Mutex
+	validations []error
+	issued      []*Certificate
+	issueErrs   []error
+	revocations []RevokedCertificate
+}
+
+func (m *recordingMetrics) ObserveValidation(duration time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.validations = append(m.validations, err)
+}
+
+func (m *recordingMetrics) ObserveIssuance(cert *Certificate, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.issued = append(m.issued, cert)
+	m.issueErrs = append(m.issueErrs, err)
+}
+
+func (m *recordingMetrics) ObserveRevocation(entry RevokedCertificate) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.revocations = append(m.revocations, entry)
+}
+
+func TestFailureReason(t *testing.T) {
+	assert.Equal(t, "", FailureReason(nil))
+	assert.Equal(t, "expired", FailureReason(ErrExpired))
+	assert.Equal(t, "bad_signature", FailureReason(fmt.Errorf("wrapped: %w",
+		newValidationError(ErrBadSignature, &Certificate{}, "failed"))))
+	assert.Equal(t, "other", FailureReason(errors.New("something else")))
+}
+
+func TestMetrics(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	metrics := &recordingMetrics{}
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.Metrics = metrics
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	_, err = ca.Issue("", pubKey)
+	assert.Error(t, err)
+	assert.Equal(t, []*Certificate{cert, nil}, metrics.issued)
+	assert.NoError(t, metrics.issueErrs[0])
+	assert.Error(t, metrics.issueErrs[1])
+
+	validator := NewValidator(NewCertPool(rootCert))
+	validator.Metrics = metrics
+	assert.NoError(t, validator.Validate(cert))
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	forged, _, err := ClientCertificate("device", 1, time.Time{}, time.Time{}, nil, otherKey, "root")
+	require.NoError(t, err)
+	_, err = validator.ValidateBundle([]*Certificate{forged})
+	assert.Error(t, err)
+	require.Len(t, metrics.validations, 2)
+	assert.NoError(t, metrics.validations[0])
+	assert.Equal(t, "bad_signature", FailureReason(metrics.validations[1]))
+
+	require.NoError(t, ca.Revoke(cert.SerialNumber, RevocationReasonSuperseded))
+	require.Len(t, metrics.revocations, 1)
+	assert.Equal(t, cert.SerialNumber, metrics.revocations[0].SerialNumber)
+}
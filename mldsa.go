@@ -0,0 +1,56 @@
+//go:build go1.26 && !tinygo
+// +build go1.26,!tinygo
+
+package smolcert
+
+import (
+	"crypto/mldsa"
+)
+
+// mldsaOptions binds ML-DSA signatures to smolcert certificates
+var mldsaOptions = &mldsa.Options{Context: "smolcert"}
+
+var mldsaParameters = map[Algorithm]mldsa.Parameters{
+	AlgorithmMLDSA44: mldsa.MLDSA44(),
+	AlgorithmMLDSA65: mldsa.MLDSA65(),
+	AlgorithmMLDSA87: mldsa.MLDSA87(),
+}
+
+func init() {
+	for alg, params := range mldsaParameters {
+		params := params
+		RegisterPostQuantumVerifier(alg, func(pubKey, message, sig []byte) bool {
+			pk, err := mldsa.NewPublicKey(params, pubKey)
+			if err != nil {
+				return false
+			}
+			return mldsa.Verify(pk, message, sig, mldsaOptions) == nil
+		})
+	}
+}
+
+// MLDSASigner creates ML-DSA signatures for hybrid certificates. It is only available when
+// building with Go 1.26 or later.
+type MLDSASigner struct {
+	Key *mldsa.PrivateKey
+}
+
+// Algorithm implements PostQuantumSigner
+func (s *MLDSASigner) Algorithm() Algorithm {
+	for alg, params := range mldsaParameters {
+		if params == s.Key.PublicKey().Parameters() {
+			return alg
+		}
+	}
+	return Algorithm(0)
+}
+
+// Public implements PostQuantumSigner
+func (s *MLDSASigner) Public() []byte {
+	return s.Key.PublicKey().Bytes()
+}
+
+// Sign implements PostQuantumSigner
+func (s *MLDSASigner) Sign(message []byte) ([]byte, error) {
+	return s.Key.Sign(nil, message, mldsaOptions)
+}
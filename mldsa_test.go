@@ -0,0 +1,37 @@
+//go:build go1.26 && !tinygo
+// +build go1.26,!tinygo
+
+package smolcert
+
+import (
+	"crypto/mldsa"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestMLDSAHybridSignature(t *testing.T) {
+	key, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	require.NoError(t, err)
+	pqSigner := &MLDSASigner{Key: key}
+	assert.Equal(t, AlgorithmMLDSA65, pqSigner.Algorithm())
+	root, rootKey := newHybridRoot(t, pqSigner)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := SignCertificateHybrid(&Certificate{Issuer: "root", Subject: "device", Validity: &Validity{}, PubKey: pub},
+		rootKey, pqSigner)
+	require.NoError(t, err)
+	pool := NewCertPool(root)
+	assert.NoError(t, pool.Validate(cert))
+
+	// A signature of another ML-DSA key is rejected
+	other, err := mldsa.GenerateKey(mldsa.MLDSA65())
+	require.NoError(t, err)
+	cert, err = SignCertificateHybrid(cert, rootKey, &MLDSASigner{Key: other})
+	require.NoError(t, err)
+	assert.Error(t, pool.Validate(cert))
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd) || tinygo
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd tinygo
+
+package smolcert
+
+func lockMemory(buf []byte) error {
+	return ErrMemoryLockUnsupported
+}
+
+func unlockMemory(buf []byte) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build (linux || darwin || dragonfly || freebsd || netbsd || openbsd) && !tinygo
+// +build linux darwin dragonfly freebsd netbsd openbsd
+// +build !tinygo
+
+package smolcert
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func lockMemory(buf []byte) error {
+	if err := syscall.Mlock(buf); err != nil {
+		return fmt.Errorf("%w: %s", ErrMemoryLockUnsupported, err)
+	}
+	return nil
+}
+
+func unlockMemory(buf []byte) error {
+	return syscall.Munlock(buf)
+}
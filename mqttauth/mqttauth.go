@@ -0,0 +1,249 @@
+/*
+Package mqttauth authenticates MQTT clients with smolcerts when they connect to a broker.
+
+MQTT 3.1.1 has no challenge-response during CONNECT, so the client proves the possession of its
+private key with a signed, timestamped ConnectProof sent as password of the CONNECT packet. The
+proof carries the certificate bundle of the client, so the broker needs no further round trip:
+
+	username, password, _ := mqttauth.Credentials(mqttauth.ClientID(cert), bundle, key)
+
+	verifier := &mqttauth.Verifier{Pool: pool}
+	cert, err := verifier.Verify(clientID, username, password)
+
+Verifier.Authenticate is a callback for the connect hooks of broker auth plugins.
+*/
+package mqttauth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// DefaultMaxAge is the maximum age of a ConnectProof, if Verifier.MaxAge is not set
+const DefaultMaxAge = 5 * time.Minute
+
+// nonceSize is the size of the random nonce of a ConnectProof in bytes
+const nonceSize = 16
+
+// proofContext is prepended to signed proofs, so that they can't be mistaken for signatures of
+// other structures
+var proofContext = []byte("smolcert mqtt connect")
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// ClientID derives an MQTT client ID from the subject of cert, so it stays the same when the
+// certificate is renewed. The ID consists of 23 alphanumeric characters, the limit every MQTT
+// 3.1.1 broker has to accept.
+func ClientID(cert *smolcert.Certificate) string {
+	digest := sha256.Sum256([]byte(cert.Subject))
+	return "sc" + base32.StdEncoding.EncodeToString(digest[:])[:21]
+}
+
+// ConnectProof is sent as password of the MQTT CONNECT packet. It proves that the client holds the
+// private key of its certificate at the time it connects with ClientID.
+type ConnectProof struct {
+	_ struct{} `cbor:",toarray"`
+
+	ClientID string `cbor:"client_id"`
+	// Timestamp is the time of the connection attempt in milliseconds since epoch
+	Timestamp int64  `cbor:"timestamp"`
+	Nonce     []byte `cbor:"nonce"`
+	// Certificates contains the certificate of the client first, followed by intermediates
+	Certificates []*smolcert.Certificate `cbor:"certificates"`
+	Signature    []byte                  `cbor:"signature"`
+}
+
+// Credentials creates the username and password of an MQTT CONNECT packet for clientID. The
+// username is the subject of the certificate, the password an encoded ConnectProof signed with
+// key. bundle contains the certificate of the client first, followed by intermediates.
+func Credentials(clientID string, bundle []*smolcert.Certificate, key ed25519.PrivateKey) (string, []byte, error) {
+	p, err := NewConnectProof(clientID, bundle, key)
+	if err != nil {
+		return "", nil, err
+	}
+	password, err := p.Bytes()
+	if err != nil {
+		return "", nil, err
+	}
+	return bundle[0].Subject, password, nil
+}
+
+// NewConnectProof creates a ConnectProof for clientID, signed with key
+func NewConnectProof(clientID string, bundle []*smolcert.Certificate, key ed25519.PrivateKey) (*ConnectProof, error) {
+	if len(bundle) == 0 {
+		return nil, errors.New("Certificate bundle is empty")
+	}
+	if !bytes.Equal(bundle[0].PubKey, key.Public().(ed25519.PublicKey)) {
+		return nil, errors.New("Private key doesn't belong to the client certificate")
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	p := &ConnectProof{
+		ClientID:     clientID,
+		Timestamp:    time.Now().UnixNano() / int64(time.Millisecond),
+		Nonce:        nonce,
+		Certificates: bundle,
+	}
+	tbs, err := p.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	p.Signature = ed25519.Sign(key, tbs)
+	return p, nil
+}
+
+// ParseConnectProof parses a ConnectProof from an existing byte buffer
+func ParseConnectProof(buf []byte) (*ConnectProof, error) {
+	p := &ConnectProof{}
+	if err := cbor.Unmarshal(buf, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Bytes returns the CBOR encoded form of the ConnectProof
+func (p *ConnectProof) Bytes() ([]byte, error) {
+	return cborEm.Marshal(p)
+}
+
+// The certificates are not signed, they are validated on their own
+func (p *ConnectProof) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&ConnectProof{
+		ClientID:  p.ClientID,
+		Timestamp: p.Timestamp,
+		Nonce:     p.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, proofContext...), buf...), nil
+}
+
+// Verifier verifies the credentials of connecting MQTT clients
+type Verifier struct {
+	// Pool is used to validate the certificates of clients
+	Pool *smolcert.CertPool
+	// Validator is used instead of Pool if set, i.e. to apply ValidationHooks
+	Validator *smolcert.Validator
+	// MaxAge is the maximum difference between the timestamp of a proof and the time of the
+	// broker. DefaultMaxAge is used if zero.
+	MaxAge time.Duration
+	// RequireDerivedClientID rejects clients, which don't use the ClientID derived from their
+	// certificate
+	RequireDerivedClientID bool
+
+	lock sync.Mutex
+	// nonces contains the nonces of proofs accepted within MaxAge, to detect replayed proofs
+	nonces map[string]time.Time
+}
+
+// Verify verifies the credentials of a CONNECT packet and returns the validated certificate of
+// the client. Every proof is accepted only once.
+func (v *Verifier) Verify(clientID, username string, password []byte) (*smolcert.Certificate, error) {
+	p, err := ParseConnectProof(password)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid connect proof: %w", err)
+	}
+	if p.ClientID != clientID {
+		return nil, fmt.Errorf("Connect proof has been created for client ID '%s', not '%s'", p.ClientID, clientID)
+	}
+	maxAge := v.maxAge()
+	age := time.Since(time.Unix(0, p.Timestamp*int64(time.Millisecond)))
+	if age > maxAge || age < -maxAge {
+		return nil, fmt.Errorf("Connect proof timestamp differs by %s from the time of the broker", age)
+	}
+	if len(p.Nonce) < nonceSize {
+		return nil, errors.New("Connect proof nonce is too short")
+	}
+	if len(p.Certificates) == 0 {
+		return nil, errors.New("Connect proof doesn't contain the certificate of the client")
+	}
+
+	cert, err := smolcert.NewBundle(p.Certificates...).ValidateWith(v.validator())
+	if err != nil {
+		return nil, err
+	}
+	if err := smolcert.RequiresExtension(cert, smolcert.OIDKeyUsage,
+		smolcert.ExpectKeyUsage(smolcert.KeyUsageClientIdentification)); err != nil {
+		return nil, fmt.Errorf("Client certificate has an invalid KeyUsage: %w", err)
+	}
+	if username != "" && username != cert.Subject {
+		return nil, fmt.Errorf("Username '%s' doesn't match the certificate of '%s'", username, cert.Subject)
+	}
+	if v.RequireDerivedClientID && clientID != ClientID(cert) {
+		return nil, fmt.Errorf("Client ID '%s' isn't derived from the certificate of '%s'", clientID, cert.Subject)
+	}
+	tbs, err := p.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(cert.PubKey, tbs, p.Signature) {
+		return nil, errors.New("Signature of connect proof is invalid")
+	}
+	if !v.useNonce(p.Nonce, maxAge) {
+		return nil, errors.New("Connect proof has already been used")
+	}
+	return cert, nil
+}
+
+// Authenticate is true if the credentials of a CONNECT packet are valid. It is meant as callback
+// for the connect hooks of broker auth plugins.
+func (v *Verifier) Authenticate(clientID, username string, password []byte) bool {
+	_, err := v.Verify(clientID, username, password)
+	return err == nil
+}
+
+func (v *Verifier) maxAge() time.Duration {
+	if v.MaxAge > 0 {
+		return v.MaxAge
+	}
+	return DefaultMaxAge
+}
+
+func (v *Verifier) validator() *smolcert.Validator {
+	if v.Validator != nil {
+		return v.Validator
+	}
+	return smolcert.NewValidator(v.Pool)
+}
+
+// useNonce records nonce and returns false if it has been used before. Nonces are forgotten once
+// proofs with them can't be accepted anymore.
+func (v *Verifier) useNonce(nonce []byte, maxAge time.Duration) bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	now := time.Now()
+	if v.nonces == nil {
+		v.nonces = map[string]time.Time{}
+	}
+	for n, seen := range v.nonces {
+		if now.Sub(seen) > 2*maxAge {
+			delete(v.nonces, n)
+		}
+	}
+	if _, used := v.nonces[string(nonce)]; used {
+		return false
+	}
+	v.nonces[string(nonce)] = now
+	return true
+}
@@ -0,0 +1,142 @@
+package mqttauth
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestClientID(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := smolcert.ClientCertificate("device-1", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	renewed, _, err := smolcert.ClientCertificate("device-1", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	id := ClientID(cert)
+	assert.Regexp(t, regexp.MustCompile("^sc[A-Z2-7]{21}$"), id)
+	assert.Equal(t, id, ClientID(renewed))
+	assert.NotEqual(t, id, ClientID(rootCert))
+}
+
+func TestVerifier(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := smolcert.SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]smolcert.Extension{{OID: smolcert.OIDKeyUsage, Critical: true, Value: smolcert.KeyUsageSignCert.ToBytes()}},
+		rootKey, "root")
+	require.NoError(t, err)
+	deviceCert, deviceKey, err := smolcert.ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	serverCert, serverKey, err := smolcert.ServerCertificate("server", 4, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	bundle := []*smolcert.Certificate{deviceCert, intermediate}
+	v := &Verifier{Pool: smolcert.NewCertPool(rootCert)}
+
+	clientID := ClientID(deviceCert)
+	username, password, err := Credentials(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	assert.Equal(t, "device", username)
+	cert, err := v.Verify(clientID, username, password)
+	require.NoError(t, err)
+	assert.Equal(t, "device", cert.Subject)
+
+	// Proofs can't be replayed
+	_, err = v.Verify(clientID, username, password)
+	assert.Error(t, err)
+	assert.False(t, v.Authenticate(clientID, username, password))
+
+	_, password, err = Credentials(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	_, err = v.Verify("other", username, password)
+	assert.Error(t, err)
+	_, err = v.Verify(clientID, "server", password)
+	assert.Error(t, err)
+	// The username is optional
+	assert.True(t, v.Authenticate(clientID, "", password))
+
+	// The client ID may be chosen freely unless the derived one is required
+	_, password, err = Credentials("custom", bundle, deviceKey)
+	require.NoError(t, err)
+	assert.True(t, v.Authenticate("custom", username, password))
+	v.RequireDerivedClientID = true
+	_, password, err = Credentials("custom", bundle, deviceKey)
+	require.NoError(t, err)
+	assert.False(t, v.Authenticate("custom", username, password))
+	_, password, err = Credentials(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	assert.True(t, v.Authenticate(clientID, username, password))
+
+	// Server certificates can't be used to authenticate clients
+	_, password, err = Credentials(ClientID(serverCert), []*smolcert.Certificate{serverCert}, serverKey)
+	require.NoError(t, err)
+	assert.False(t, v.Authenticate(ClientID(serverCert), "server", password))
+
+	// The chain has to be complete
+	_, password, err = Credentials(clientID, []*smolcert.Certificate{deviceCert}, deviceKey)
+	require.NoError(t, err)
+	assert.False(t, v.Authenticate(clientID, username, password))
+
+	assert.False(t, v.Authenticate(clientID, username, []byte("password")))
+}
+
+func TestVerifierRejectsForgedProofs(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	deviceCert, deviceKey, err := smolcert.ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	bundle := []*smolcert.Certificate{deviceCert}
+	v := &Verifier{Pool: smolcert.NewCertPool(rootCert), MaxAge: time.Minute}
+	clientID := ClientID(deviceCert)
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = NewConnectProof(clientID, bundle, otherKey)
+	assert.Error(t, err)
+	_, err = NewConnectProof(clientID, nil, deviceKey)
+	assert.Error(t, err)
+
+	verify := func(p *ConnectProof) error {
+		password, err := p.Bytes()
+		require.NoError(t, err)
+		_, err = v.Verify(clientID, "device", password)
+		return err
+	}
+	sign := func(p *ConnectProof, key ed25519.PrivateKey) *ConnectProof {
+		tbs, err := p.tbsBytes()
+		require.NoError(t, err)
+		p.Signature = ed25519.Sign(key, tbs)
+		return p
+	}
+
+	p, err := NewConnectProof(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	p.Nonce[0] ^= 0xff
+	assert.Error(t, verify(p))
+	assert.Error(t, verify(sign(p, otherKey)))
+	assert.NoError(t, verify(sign(p, deviceKey)))
+
+	// Proofs from the past or future are rejected
+	p, err = NewConnectProof(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	p.Timestamp -= 2 * time.Minute.Milliseconds()
+	assert.Error(t, verify(sign(p, deviceKey)))
+	p.Timestamp += 4 * time.Minute.Milliseconds()
+	assert.Error(t, verify(sign(p, deviceKey)))
+
+	p, err = NewConnectProof(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	p.Nonce = p.Nonce[:4]
+	assert.Error(t, verify(sign(p, deviceKey)))
+
+	p, err = NewConnectProof(clientID, bundle, deviceKey)
+	require.NoError(t, err)
+	p.Certificates = nil
+	assert.Error(t, verify(p))
+}
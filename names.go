@@ -0,0 +1,50 @@
+package smolcert
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NameMatching controls how the issuer of a certificate is matched against the subjects of
+// the certificates in a CertPool or bundle. By default names are compared byte by byte, the
+// VerifyOption MatchNames configures the NameMatching of a Validator.
+type NameMatching uint8
+
+// Flags of NameMatching, which can be combined
+const (
+	// NameMatchExact compares names byte by byte
+	NameMatchExact NameMatching = 0
+	// NameMatchNFC compares names after applying Unicode normalization form C, so that i.e. a
+	// precomposed 'é' matches an 'e' followed by a combining accent
+	NameMatchNFC NameMatching = 1 << 0
+	// NameMatchFoldCase compares names case insensitively using Unicode case folding
+	NameMatchFoldCase NameMatching = 1 << 1
+)
+
+var caseFolder = cases.Fold()
+
+// CanonicalName returns name in the form used for comparisons under the NameMatching m
+func (m NameMatching) CanonicalName(name string) string {
+	if m&NameMatchNFC != 0 {
+		name = norm.NFC.String(name)
+	}
+	if m&NameMatchFoldCase != 0 {
+		// Folding may produce decomposed characters, so normalize again
+		name = norm.NFC.String(caseFolder.String(name))
+	}
+	return name
+}
+
+// Match is true if both names are equal under the NameMatching m
+func (m NameMatching) Match(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return m != NameMatchExact && m.CanonicalName(a) == m.CanonicalName(b)
+}
+
+// indexKey returns the key under which CertPools index subjects. It is the canonical form of the
+// most lenient NameMatching, so the roots matching a name under any NameMatching share its key.
+func indexKey(name string) string {
+	return (NameMatchNFC | NameMatchFoldCase).CanonicalName(name)
+}
@@ -0,0 +1,61 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameMatching(t *testing.T) {
+	composed, decomposed := "caf\u00e9", "cafe\u0301"
+	assert.False(t, NameMatchExact.Match(composed, decomposed))
+	assert.True(t, NameMatchNFC.Match(composed, decomposed))
+	assert.False(t, NameMatchNFC.Match("Root", "root"))
+	assert.True(t, NameMatchFoldCase.Match("Root", "root"))
+	assert.True(t, (NameMatchNFC|NameMatchFoldCase).Match("CAF\u00c9", decomposed))
+	assert.True(t, NameMatchFoldCase.Match("STRASSE", "straße"))
+	assert.Equal(t, "root", NameMatchFoldCase.CanonicalName("ROOT"))
+}
+
+func TestValidateWithNameMatching(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("Caf\u00e9 Root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("Intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "cafe\u0301 root")
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, intermediateKey, "INTERMEDIATE")
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	bundle := []*Certificate{leaf, intermediate}
+
+	err = pool.Validate(intermediate)
+	assert.True(t, errors.Is(err, ErrUnknownIssuer))
+	_, err = pool.ValidateBundle(bundle)
+	assert.Error(t, err)
+
+	nfc := NewValidator(pool)
+	nfc.Options = []VerifyOption{MatchNames(NameMatchNFC)}
+	_, err = nfc.ValidateBundle(bundle)
+	assert.Error(t, err)
+
+	// Validators with different policies can be used side by side
+	lenient := NewValidator(pool)
+	lenient.Options = []VerifyOption{MatchNames(NameMatchNFC | NameMatchFoldCase)}
+	assert.NoError(t, lenient.Validate(intermediate))
+	clientCert, err := lenient.ValidateBundle(bundle)
+	require.NoError(t, err)
+	assert.Equal(t, leaf, clientCert)
+	_, err = nfc.ValidateBundle(bundle)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(pool.Validate(intermediate), ErrUnknownIssuer))
+
+	b := &Bundle{}
+	require.NoError(t, b.Append(leaf, intermediate))
+	_, err = b.ValidateWith(lenient)
+	assert.NoError(t, err)
+	_, err = b.ValidateWith(nfc)
+	assert.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package smolcert
+
+import (
+	"runtime"
+	"sync"
+)
+
+// parallel calls fn for every index from 0 to n-1 using up to workers goroutines. With less than
+// two workers fn is called sequentially in the calling goroutine.
+func parallel(n, workers int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers < 2 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// defaultConcurrency is used for a concurrency below 1
+func defaultConcurrency(n int) int {
+	if n < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return n
+}
@@ -0,0 +1,35 @@
+package smolcert
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParallel(t *testing.T) {
+	for _, workers := range []int{0, 1, 4, 100} {
+		called := make([]int32, 50)
+		var running, maxRunning int32
+		parallel(len(called), workers, func(i int) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			atomic.AddInt32(&called[i], 1)
+			atomic.AddInt32(&running, -1)
+		})
+		for i := range called {
+			assert.Equal(t, int32(1), called[i], "workers %d, index %d", workers, i)
+		}
+		limit := int32(workers)
+		if limit < 1 {
+			limit = 1
+		}
+		assert.LessOrEqual(t, maxRunning, limit)
+	}
+	parallel(0, 4, func(i int) { t.Fail() })
+}
@@ -0,0 +1,100 @@
+package smolcert
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ErrPinMismatch indicates that the key of a certificate doesn't match any pinned key
+var ErrPinMismatch = errors.New("certificate key is not pinned")
+
+// pinPrefix is used in the textual representation of pins
+const pinPrefix = "sha256/"
+
+// Pin is the SHA-256 hash of a public key, used to pin certificates independently of CertPools
+type Pin [sha256.Size]byte
+
+// PinFromPublicKey creates the Pin of a public key
+func PinFromPublicKey(pubKey ed25519.PublicKey) Pin {
+	return Pin(sha256.Sum256(pubKey))
+}
+
+// PinFromCertificate creates the Pin of the public key of a certificate
+func PinFromCertificate(cert *Certificate) Pin {
+	return PinFromPublicKey(cert.PubKey)
+}
+
+// ParsePin parses a Pin from its textual representation as returned by Pin.String
+func ParsePin(s string) (Pin, error) {
+	var p Pin
+	if !strings.HasPrefix(s, pinPrefix) {
+		return p, fmt.Errorf("Pin needs to start with %s", pinPrefix)
+	}
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, pinPrefix))
+	if err != nil {
+		return p, err
+	}
+	if len(buf) != len(p) {
+		return p, fmt.Errorf("Unexpected length of pin (expected %d bytes, got %d bytes)", len(p), len(buf))
+	}
+	copy(p[:], buf)
+	return p, nil
+}
+
+// String returns the textual representation of the Pin, i.e. sha256/<base64 encoded hash>
+func (p Pin) String() string {
+	return pinPrefix + base64.StdEncoding.EncodeToString(p[:])
+}
+
+// PinSet is a set of pinned public keys
+type PinSet map[Pin]bool
+
+// NewPinSet creates a new PinSet from the given pins
+func NewPinSet(pins ...Pin) PinSet {
+	p := make(PinSet)
+	for _, pin := range pins {
+		p[pin] = true
+	}
+	return p
+}
+
+// Add adds a pin to the PinSet
+func (p PinSet) Add(pin Pin) {
+	p[pin] = true
+}
+
+// Contains is true if the key of cert is pinned
+func (p PinSet) Contains(cert *Certificate) bool {
+	return p[PinFromCertificate(cert)]
+}
+
+// Verify returns ErrPinMismatch if the key of cert is not pinned. The certificate itself is not validated.
+func (p PinSet) Verify(cert *Certificate) error {
+	if !p.Contains(cert) {
+		return newValidationError(ErrPinMismatch, cert, "Key of certificate '%s' is not pinned", cert.Subject)
+	}
+	return nil
+}
+
+// VerifyBundle is true if any certificate of a bundle, i.e. the leaf or one of its issuers, is pinned
+func (p PinSet) VerifyBundle(certBundle []*Certificate) error {
+	for _, cert := range certBundle {
+		if p.Contains(cert) {
+			return nil
+		}
+	}
+	return errors.New("None of the certificates in the bundle is pinned")
+}
+
+// ValidatePinned validates cert against the CertPool and requires its key to be pinned
+func (c *CertPool) ValidatePinned(cert *Certificate, pins PinSet) error {
+	if err := pins.Verify(cert); err != nil {
+		return err
+	}
+	return c.Validate(cert)
+}
@@ -0,0 +1,45 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinSet(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	serverCert, _, err := ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	otherCert, _, err := ServerCertificate("server", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	pin := PinFromCertificate(serverCert)
+	parsed, err := ParsePin(pin.String())
+	require.NoError(t, err)
+	assert.Equal(t, pin, parsed)
+
+	pins := NewPinSet(parsed)
+	assert.NoError(t, pins.Verify(serverCert))
+	assert.True(t, errors.Is(pins.Verify(otherCert), ErrPinMismatch))
+
+	pool := NewCertPool(rootCert)
+	assert.NoError(t, pool.ValidatePinned(serverCert, pins))
+	assert.Error(t, pool.ValidatePinned(otherCert, pins))
+
+	// Pinning the root accepts all certificates of the bundle
+	rootPins := NewPinSet()
+	rootPins.Add(PinFromCertificate(rootCert))
+	assert.NoError(t, rootPins.VerifyBundle([]*Certificate{otherCert, rootCert}))
+	assert.Error(t, rootPins.VerifyBundle([]*Certificate{otherCert}))
+}
+
+func TestParseInvalidPin(t *testing.T) {
+	for _, s := range []string{"", "sha1/AAAA", "sha256/not base64!", "sha256/AAAA"} {
+		_, err := ParsePin(s)
+		assert.Error(t, err, s)
+	}
+}
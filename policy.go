@@ -0,0 +1,204 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// IssuerPolicy is the Value of an IssuerPolicy extension. A CA embeds it to restrict all
+// certificates below it in the chain. Empty fields don't restrict anything.
+//
+// Like NameConstraints, the policy applies to the whole subtree below an issuer: certificates
+// which can sign certificates themselves need to carry an IssuerPolicy which is at least as
+// restrictive as the policy of their issuer.
+type IssuerPolicy struct {
+	_ struct{} `cbor:",toarray"`
+
+	// AllowedKeyUsages are the KeyUsages certificates may have. Intermediates are only permitted
+	// if KeyUsageSignCert is included.
+	AllowedKeyUsages []KeyUsage `cbor:"allowed_key_usages"`
+	// MaxValidity is the maximum validity of certificates in seconds
+	MaxValidity uint64 `cbor:"max_validity"`
+	// SubjectPatterns are the patterns subjects need to match. '*' matches any sequence of
+	// characters, i.e. "acme-*" permits "acme-door-1".
+	SubjectPatterns []string `cbor:"subject_patterns"`
+}
+
+// IssuerPolicyExtension creates a critical IssuerPolicy Extension
+func IssuerPolicyExtension(p *IssuerPolicy) (Extension, error) {
+	val, err := cborEm.Marshal(p)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDIssuerPolicy,
+		Critical: true,
+		Value:    val,
+	}, nil
+}
+
+// ParseIssuerPolicy parses an IssuerPolicy from a byte slice, i.e. the Value of an Extension
+func ParseIssuerPolicy(in []byte) (*IssuerPolicy, error) {
+	p := &IssuerPolicy{}
+	if err := cbor.Unmarshal(in, p); err != nil {
+		return nil, fmt.Errorf("Failed to parse IssuerPolicy: %w", err)
+	}
+	return p, nil
+}
+
+// MaxValidityDuration returns the maximum validity of certificates as time.Duration
+func (p *IssuerPolicy) MaxValidityDuration() time.Duration {
+	return time.Duration(p.MaxValidity) * time.Second
+}
+
+// Check returns an error if cert violates the policy. Certificates which can sign certificates
+// also need to carry an IssuerPolicy which is within p.
+func (p *IssuerPolicy) Check(cert *Certificate) error {
+	if len(p.AllowedKeyUsages) > 0 {
+		err := RequiresExtension(cert, OIDKeyUsage, func(critical bool, val []byte) error {
+			keyUsage, err := ParseKeyUsage(val)
+			if err != nil {
+				return err
+			}
+			if !p.allowsKeyUsage(keyUsage) {
+				return fmt.Errorf("KeyUsage %s is not allowed", keyUsage)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrorExtensionNotFound) {
+			return err
+		}
+	}
+	if p.MaxValidity > 0 {
+		if cert.Validity == nil || cert.Validity.NotBefore.IsZero() || cert.Validity.NotAfter.IsZero() {
+			return fmt.Errorf("Validity is limited to %s, but the validity is unbounded", p.MaxValidityDuration())
+		}
+		if validity := cert.Validity.Duration(); validity > p.MaxValidityDuration() {
+			return fmt.Errorf("Validity of %s exceeds the maximum of %s", validity, p.MaxValidityDuration())
+		}
+	}
+	if len(p.SubjectPatterns) > 0 && !p.permitsSubject(cert.Subject) {
+		return fmt.Errorf("Subject '%s' doesn't match the permitted patterns", cert.Subject)
+	}
+	if RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)) != nil {
+		return nil
+	}
+	child, err := findIssuerPolicy(cert)
+	if err != nil {
+		return err
+	}
+	if child == nil || !p.covers(child) {
+		return errors.New("Certificate can issue certificates, but its IssuerPolicy is not within the policy of its issuer")
+	}
+	return nil
+}
+
+func (p *IssuerPolicy) allowsKeyUsage(keyUsage KeyUsage) bool {
+	for _, allowed := range p.AllowedKeyUsages {
+		if allowed == keyUsage {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *IssuerPolicy) permitsSubject(subject string) bool {
+	for _, pattern := range p.SubjectPatterns {
+		if matchSubjectPattern(pattern, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers is true if every certificate permitted by other is also permitted by p
+func (p *IssuerPolicy) covers(other *IssuerPolicy) bool {
+	if len(p.AllowedKeyUsages) > 0 {
+		if len(other.AllowedKeyUsages) == 0 {
+			return false
+		}
+		for _, keyUsage := range other.AllowedKeyUsages {
+			if !p.allowsKeyUsage(keyUsage) {
+				return false
+			}
+		}
+	}
+	if p.MaxValidity > 0 && (other.MaxValidity == 0 || other.MaxValidity > p.MaxValidity) {
+		return false
+	}
+	if len(p.SubjectPatterns) > 0 {
+		if len(other.SubjectPatterns) == 0 {
+			return false
+		}
+		for _, pattern := range other.SubjectPatterns {
+			// Matching the pattern itself is sufficient, as every '*' of it can only be matched by
+			// a '*' of the patterns of p
+			if !p.permitsSubject(pattern) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchSubjectPattern matches name against pattern, in which '*' matches any sequence of characters
+func matchSubjectPattern(pattern, name string) bool {
+	// Greedy matching with backtracking to the last '*'
+	p, n := 0, 0
+	star, next := -1, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case p < len(pattern) && pattern[p] == name[n]:
+			p++
+			n++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func findIssuerPolicy(cert *Certificate) (*IssuerPolicy, error) {
+	var p *IssuerPolicy
+	err := RequiresExtension(cert, OIDIssuerPolicy, func(critical bool, val []byte) (err error) {
+		p, err = ParseIssuerPolicy(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// checkIssuerPolicy verifies that cert doesn't violate the IssuerPolicy of issuer
+func checkIssuerPolicy(cert, issuer *Certificate) error {
+	if cert == issuer || (cert.Subject == issuer.Subject && bytes.Equal(cert.PubKey, issuer.PubKey)) {
+		// The policy of a root doesn't apply to the root itself
+		return nil
+	}
+	p, err := findIssuerPolicy(issuer)
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, issuer, "Invalid IssuerPolicy: %s", err)
+	}
+	if p == nil {
+		return nil
+	}
+	if err := p.Check(cert); err != nil {
+		return newValidationError(ErrPolicyViolation, cert, "Certificate violates the policy of issuer '%s': %s",
+			issuer.Subject, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newPolicyCA(t *testing.T, subject string, serial uint64, p *IssuerPolicy, issuerKey ed25519.PrivateKey,
+	issuer string) (*Certificate, ed25519.PrivateKey) {
+	exts := []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}
+	if p != nil {
+		ext, err := IssuerPolicyExtension(p)
+		require.NoError(t, err)
+		exts = append(exts, ext)
+	}
+	now := time.Now()
+	cert, key, err := SignedCertificate(subject, serial, now, now.Add(24*time.Hour), exts, issuerKey, issuer)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestIssuerPolicy(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	acme, acmeKey := newPolicyCA(t, "acme", 2, &IssuerPolicy{
+		AllowedKeyUsages: []KeyUsage{KeyUsageClientIdentification, KeyUsageSignCert},
+		MaxValidity:      uint64((48 * time.Hour) / time.Second),
+		SubjectPatterns:  []string{"acme-*", "*.acme.example.com"},
+	}, rootKey, "root")
+	now := time.Now()
+
+	for _, name := range []string{"acme-door-1", "gateway.acme.example.com"} {
+		cert, _, err := ClientCertificate(name, 3, now, now.Add(time.Hour), nil, acmeKey, "acme")
+		require.NoError(t, err)
+		_, err = pool.ValidateBundle([]*Certificate{cert, acme})
+		assert.NoError(t, err, name)
+		assert.True(t, pool.ValidateBundleReport([]*Certificate{cert, acme}).Valid(), name)
+	}
+
+	invalid := map[string]*Certificate{}
+	var cert *Certificate
+	cert, _, err = ClientCertificate("other-door", 4, now, now.Add(time.Hour), nil, acmeKey, "acme")
+	require.NoError(t, err)
+	invalid["subject"] = cert
+	cert, _, err = ClientCertificate("acme-door", 5, now, now.Add(72*time.Hour), nil, acmeKey, "acme")
+	require.NoError(t, err)
+	invalid["validity"] = cert
+	cert, _, err = ClientCertificate("acme-door", 6, time.Time{}, time.Time{}, nil, acmeKey, "acme")
+	require.NoError(t, err)
+	invalid["unbounded"] = cert
+	cert, _, err = ServerCertificate("acme-server", 7, now, now.Add(time.Hour), nil, acmeKey, "acme")
+	require.NoError(t, err)
+	invalid["key usage"] = cert
+	for name, cert := range invalid {
+		_, err = pool.ValidateBundle([]*Certificate{cert, acme})
+		assert.True(t, errors.Is(err, ErrPolicyViolation), name)
+		assert.False(t, pool.ValidateBundleReport([]*Certificate{cert, acme}).Valid(), name)
+	}
+
+	// A CA refuses to issue certificates its own policy forbids
+	ca, err := NewCA(acme, acmeKey)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = ca.Issue("other-door", pubKey, WithValidFor(time.Hour))
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+	_, err = ca.Issue("acme-door", pubKey, WithValidFor(time.Hour))
+	assert.NoError(t, err)
+}
+
+func TestIssuerPolicyAppliesToSubtree(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	policy := &IssuerPolicy{
+		AllowedKeyUsages: []KeyUsage{KeyUsageClientIdentification, KeyUsageSignCert},
+		MaxValidity:      uint64((48 * time.Hour) / time.Second),
+		SubjectPatterns:  []string{"acme-*"},
+	}
+	acme, acmeKey := newPolicyCA(t, "acme-ca", 2, policy, rootKey, "root")
+
+	unrestricted, unrestrictedKey := newPolicyCA(t, "acme-sub", 3, nil, acmeKey, "acme-ca")
+	widened, _ := newPolicyCA(t, "acme-sub", 4, &IssuerPolicy{
+		AllowedKeyUsages: policy.AllowedKeyUsages,
+		MaxValidity:      policy.MaxValidity,
+		SubjectPatterns:  []string{"*"},
+	}, acmeKey, "acme-ca")
+	longer, _ := newPolicyCA(t, "acme-sub", 5, &IssuerPolicy{
+		AllowedKeyUsages: policy.AllowedKeyUsages,
+		SubjectPatterns:  policy.SubjectPatterns,
+	}, acmeKey, "acme-ca")
+	narrowed, narrowedKey := newPolicyCA(t, "acme-sub", 6, &IssuerPolicy{
+		AllowedKeyUsages: []KeyUsage{KeyUsageClientIdentification},
+		MaxValidity:      uint64(time.Hour / time.Second),
+		SubjectPatterns:  []string{"acme-sub-*"},
+	}, acmeKey, "acme-ca")
+
+	now := time.Now()
+	leaf, _, err := ClientCertificate("other", 7, now, now.Add(time.Hour), nil, unrestrictedKey, "acme-sub")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{leaf, unrestricted, acme})
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+	for _, sub := range []*Certificate{widened, longer} {
+		_, err = pool.ValidateBundle([]*Certificate{sub, acme})
+		assert.True(t, errors.Is(err, ErrPolicyViolation))
+	}
+
+	leaf, _, err = ClientCertificate("acme-sub-door", 8, now, now.Add(time.Hour), nil, narrowedKey, "acme-sub")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{leaf, narrowed, acme})
+	assert.NoError(t, err)
+	leaf, _, err = ClientCertificate("acme-sub-door", 9, now, now.Add(2*time.Hour), nil, narrowedKey, "acme-sub")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{leaf, narrowed, acme})
+	assert.True(t, errors.Is(err, ErrPolicyViolation))
+}
+
+func TestMatchSubjectPattern(t *testing.T) {
+	for _, c := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"acme-*", "acme-door", true},
+		{"acme-*", "acme-", true},
+		{"acme-*", "acm", false},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"exact", "exact", true},
+		{"exact", "exact2", false},
+		{"*", "", true},
+	} {
+		assert.Equal(t, c.match, matchSubjectPattern(c.pattern, c.name), "%s %s", c.pattern, c.name)
+	}
+
+	p := &IssuerPolicy{SubjectPatterns: []string{"acme-*"}}
+	assert.True(t, p.covers(&IssuerPolicy{SubjectPatterns: []string{"acme-sub-*", "acme-1"}}))
+	assert.False(t, p.covers(&IssuerPolicy{SubjectPatterns: []string{"*-acme"}}))
+	assert.False(t, p.covers(&IssuerPolicy{}))
+}
+
+func TestIssuerPolicyRoundTrip(t *testing.T) {
+	p := &IssuerPolicy{
+		AllowedKeyUsages: []KeyUsage{KeyUsageServerIdentification},
+		MaxValidity:      3600,
+		SubjectPatterns:  []string{"*.example.com"},
+	}
+	ext, err := IssuerPolicyExtension(p)
+	require.NoError(t, err)
+	assert.True(t, ext.Critical)
+	parsed, err := ParseIssuerPolicy(ext.Value)
+	require.NoError(t, err)
+	assert.Equal(t, p, parsed)
+	assert.Equal(t, time.Hour, parsed.MaxValidityDuration())
+	_, err = ParseIssuerPolicy([]byte{0xff})
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package smolcert
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Save writes all certificates of this CertPool as a CBOR array of certificates to w. The
+// certificates are sorted by subject and public key, so saving the same pool always results in
+// the same output.
+func (c *CertPool) Save(w io.Writer) error {
+	certs := c.All()
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].Subject != certs[j].Subject {
+			return certs[i].Subject < certs[j].Subject
+		}
+		return bytes.Compare(certs[i].PubKey, certs[j].PubKey) < 0
+	})
+	return cborEm.NewEncoder(w).Encode(certs)
+}
+
+// LoadPool reads a CertPool previously written by CertPool.Save. Like NewCertPool it
+// ignores certificates which are not allowed to sign certificates.
+func LoadPool(r io.Reader) (*CertPool, error) {
+	var certs []*Certificate
+	if err := cbor.NewDecoder(r).Decode(&certs); err != nil {
+		return nil, err
+	}
+	return NewCertPool(certs...), nil
+}
+
+// ExpiringCertificates returns all certificates of this CertPool which expire within window or
+// have already expired, sorted by their expiry.
+func (c *CertPool) ExpiringCertificates(window time.Duration) []*Certificate {
+	var expiring []*Certificate
+	for _, cert := range c.All() {
+		if cert.ExpiresWithin(window) {
+			expiring = append(expiring, cert)
+		}
+	}
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].Validity.NotAfter < expiring[j].Validity.NotAfter
+	})
+	return expiring
+}
@@ -0,0 +1,58 @@
+package smolcert
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoadPool(t *testing.T) {
+	now := time.Now()
+	root1, rootKey, err := SelfSignedCertificate("root1", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+	root2, _, err := SelfSignedCertificate("root2", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	pool := NewCertPool(root1, root2)
+	buf := &bytes.Buffer{}
+	require.NoError(t, pool.Save(buf))
+
+	pool2, err := LoadPool(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, 2, pool2.Len())
+	assert.Equal(t, root1.Signature, pool2.Certificates("root1")[0].Signature)
+	assert.Equal(t, root2.PubKey, pool2.Certificates("root2")[0].PubKey)
+
+	clientCert, _, err := ClientCertificate("client", 2, now.Add(-time.Minute), now.Add(time.Hour), nil, rootKey, "root1")
+	require.NoError(t, err)
+	assert.NoError(t, pool2.Validate(clientCert))
+
+	// Saving is deterministic
+	buf2 := &bytes.Buffer{}
+	require.NoError(t, pool2.Save(buf2))
+	assert.Equal(t, buf.Bytes(), buf2.Bytes())
+}
+
+func TestLoadPoolInvalidData(t *testing.T) {
+	_, err := LoadPool(bytes.NewReader([]byte{0x01, 0x02}))
+	assert.Error(t, err)
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	now := time.Now()
+	soon, _, err := SelfSignedCertificate("soon", now.Add(-time.Minute), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+	sooner, _, err := SelfSignedCertificate("sooner", now.Add(-time.Minute), now.Add(time.Minute), nil)
+	require.NoError(t, err)
+	later, _, err := SelfSignedCertificate("later", now.Add(-time.Minute), now.Add(time.Hour*24*30), nil)
+	require.NoError(t, err)
+	never, _, err := SelfSignedCertificate("never", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	pool := NewCertPool(soon, sooner, later, never)
+	assert.Equal(t, []*Certificate{sooner, soon}, pool.ExpiringCertificates(time.Hour*24))
+	assert.Empty(t, pool.ExpiringCertificates(-time.Hour))
+}
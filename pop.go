@@ -0,0 +1,119 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// popContext is prepended to every signed challenge, so that a challenge signature can't be
+// mistaken for a signature of some other protocol.
+var popContext = []byte("smolcert proof of possession")
+
+// ChallengeNonceSize is the size of the random nonce of a challenge in bytes
+const ChallengeNonceSize = 32
+
+// Challenge is sent by a verifier to a peer, which needs to prove that it holds the private key
+// for the certificate it presented.
+type Challenge struct {
+	_ struct{} `cbor:",toarray"`
+
+	Nonce     []byte `cbor:"nonce"`
+	Timestamp Time   `cbor:"timestamp"`
+}
+
+// ChallengeResponse is the answer of the peer to a Challenge, containing a signature of the
+// Challenge created with the private key of the peer.
+type ChallengeResponse struct {
+	_ struct{} `cbor:",toarray"`
+
+	Nonce     []byte `cbor:"nonce"`
+	Signature []byte `cbor:"signature"`
+}
+
+// NewChallenge creates a new Challenge with a random nonce
+func NewChallenge() (*Challenge, error) {
+	nonce := make([]byte, ChallengeNonceSize)
+	if err := readRandom(nil, nonce); err != nil {
+		return nil, err
+	}
+	return &Challenge{
+		Nonce:     nonce,
+		Timestamp: NewTime(time.Now()),
+	}, nil
+}
+
+// ParseChallenge parses a Challenge from an existing byte buffer
+func ParseChallenge(buf []byte) (*Challenge, error) {
+	ch := &Challenge{}
+	if err := cbor.Unmarshal(buf, ch); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Bytes returns the CBOR encoded form of the Challenge
+func (ch *Challenge) Bytes() ([]byte, error) {
+	return cborEm.Marshal(ch)
+}
+
+func (ch *Challenge) signedBytes() ([]byte, error) {
+	chBytes, err := ch.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, popContext...), chBytes...), nil
+}
+
+// ParseChallengeResponse parses a ChallengeResponse from an existing byte buffer
+func ParseChallengeResponse(buf []byte) (*ChallengeResponse, error) {
+	resp := &ChallengeResponse{}
+	if err := cbor.Unmarshal(buf, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Bytes returns the CBOR encoded form of the ChallengeResponse
+func (resp *ChallengeResponse) Bytes() ([]byte, error) {
+	return cborEm.Marshal(resp)
+}
+
+// RespondToChallenge signs the Challenge with the private key belonging to the certificate of the peer
+func RespondToChallenge(ch *Challenge, priv ed25519.PrivateKey) (*ChallengeResponse, error) {
+	msg, err := ch.signedBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &ChallengeResponse{
+		Nonce:     ch.Nonce,
+		Signature: ed25519.Sign(priv, msg),
+	}, nil
+}
+
+// VerifyChallengeResponse verifies that resp answers ch and has been signed with the private key
+// belonging to cert. Challenges older than maxAge are rejected, a maxAge of zero disables this check.
+// The certificate itself is not validated, this needs to be done separately via a CertPool.
+func VerifyChallengeResponse(ch *Challenge, resp *ChallengeResponse, cert *Certificate, maxAge time.Duration) error {
+	if len(ch.Nonce) < ChallengeNonceSize {
+		return errors.New("Challenge nonce is too short")
+	}
+	if !bytes.Equal(ch.Nonce, resp.Nonce) {
+		return errors.New("Response doesn't answer the challenge")
+	}
+	if maxAge > 0 && time.Since(ch.Timestamp.StdTime()) > maxAge {
+		return fmt.Errorf("Challenge is older than %s", maxAge)
+	}
+	msg, err := ch.signedBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(cert.PubKey, msg, resp.Signature) {
+		return newValidationError(ErrBadSignature, cert, "Signature of challenge response is invalid")
+	}
+	return nil
+}
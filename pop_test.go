@@ -0,0 +1,51 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProofOfPossession(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	clientCert, clientKey, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	otherCert, otherKey, err := ClientCertificate("other", 3, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	ch, err := NewChallenge()
+	require.NoError(t, err)
+	chBytes, err := ch.Bytes()
+	require.NoError(t, err)
+
+	// The peer receives the challenge and responds
+	peerCh, err := ParseChallenge(chBytes)
+	require.NoError(t, err)
+	resp, err := RespondToChallenge(peerCh, clientKey)
+	require.NoError(t, err)
+	respBytes, err := resp.Bytes()
+	require.NoError(t, err)
+
+	resp, err = ParseChallengeResponse(respBytes)
+	require.NoError(t, err)
+	assert.NoError(t, VerifyChallengeResponse(ch, resp, clientCert, time.Minute))
+	assert.Error(t, VerifyChallengeResponse(ch, resp, otherCert, time.Minute))
+
+	otherResp, err := RespondToChallenge(ch, otherKey)
+	require.NoError(t, err)
+	assert.Error(t, VerifyChallengeResponse(ch, otherResp, clientCert, time.Minute))
+
+	// Responses to other challenges are rejected
+	ch2, err := NewChallenge()
+	require.NoError(t, err)
+	assert.Error(t, VerifyChallengeResponse(ch2, resp, clientCert, time.Minute))
+
+	ch.Timestamp = NewTime(time.Now().Add(-time.Hour))
+	oldResp, err := RespondToChallenge(ch, clientKey)
+	require.NoError(t, err)
+	assert.Error(t, VerifyChallengeResponse(ch, oldResp, clientCert, time.Minute))
+	assert.NoError(t, VerifyChallengeResponse(ch, oldResp, clientCert, 0))
+}
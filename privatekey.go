@@ -0,0 +1,136 @@
+package smolcert
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// ErrKeyDestroyed is returned if a PrivateKey is used after it has been destroyed
+	ErrKeyDestroyed = errors.New("private key has been destroyed")
+	// ErrMemoryLockUnsupported is returned by PrivateKey.LockMemory on platforms without mlock
+	ErrMemoryLockUnsupported = errors.New("locking memory is not supported on this platform")
+)
+
+// PrivateKey holds an ed25519 private key, which is wiped from memory when the key is destroyed.
+// It implements Signer, so long running CAs created with NewCAWithSigner keep their key material
+// only in a single buffer, which can optionally be locked into memory to prevent it from being
+// swapped to disk. Keys which are garbage collected without being destroyed are wiped as well.
+// A PrivateKey is safe for concurrent use.
+type PrivateKey struct {
+	lock      sync.RWMutex
+	key       ed25519.PrivateKey
+	pub       ed25519.PublicKey
+	locked    bool
+	destroyed bool
+}
+
+var _ Signer = &PrivateKey{}
+
+// NewPrivateKey copies key into a new PrivateKey. The caller should wipe its own copy of the key
+// with Zeroize afterwards.
+func NewPrivateKey(key ed25519.PrivateKey) (*PrivateKey, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid private key")
+	}
+	k := &PrivateKey{
+		key: make(ed25519.PrivateKey, ed25519.PrivateKeySize),
+		pub: append(ed25519.PublicKey{}, key.Public().(ed25519.PublicKey)...),
+	}
+	copy(k.key, key)
+	runtime.SetFinalizer(k, (*PrivateKey).Destroy)
+	return k, nil
+}
+
+// GeneratePrivateKey generates a new PrivateKey from r, or from the random source set with
+// SetRandomSource if r is nil. No other copy of the key remains in memory.
+func GeneratePrivateKey(r io.Reader) (*PrivateKey, error) {
+	_, key, err := GenerateKey(r)
+	if err != nil {
+		return nil, err
+	}
+	defer Zeroize(key)
+	return NewPrivateKey(key)
+}
+
+// Public returns the public key, which remains available after the key has been destroyed
+func (k *PrivateKey) Public() ed25519.PublicKey {
+	return k.pub
+}
+
+// Sign signs message with the key. It fails with ErrKeyDestroyed if the key has been destroyed.
+func (k *PrivateKey) Sign(message []byte) ([]byte, error) {
+	var sig []byte
+	err := k.Use(func(key ed25519.PrivateKey) error {
+		sig = ed25519.Sign(key, message)
+		return nil
+	})
+	return sig, err
+}
+
+// Use calls f with the key material, i.e. for APIs requiring an ed25519.PrivateKey. f must not
+// retain the key. It fails with ErrKeyDestroyed if the key has been destroyed.
+func (k *PrivateKey) Use(f func(key ed25519.PrivateKey) error) error {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	if k.destroyed {
+		return ErrKeyDestroyed
+	}
+	return f(k.key)
+}
+
+// LockMemory locks the key material into memory, so it is never written to swap. It fails with
+// ErrMemoryLockUnsupported on platforms without mlock and if the limit of locked memory of the
+// process is exceeded.
+func (k *PrivateKey) LockMemory() error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.destroyed {
+		return ErrKeyDestroyed
+	}
+	if k.locked {
+		return nil
+	}
+	if err := lockMemory(k.key); err != nil {
+		return err
+	}
+	k.locked = true
+	return nil
+}
+
+// Destroy wipes the key material. Afterwards all operations using the key fail with
+// ErrKeyDestroyed. Destroying a key twice has no effect.
+func (k *PrivateKey) Destroy() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.destroyed {
+		return
+	}
+	Zeroize(k.key)
+	if k.locked {
+		// The key has been wiped, so failing to unlock only leaks locked memory
+		_ = unlockMemory(k.key)
+		k.locked = false
+	}
+	k.destroyed = true
+	runtime.SetFinalizer(k, nil)
+}
+
+// Destroyed is true if the key has been destroyed
+func (k *PrivateKey) Destroyed() bool {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.destroyed
+}
+
+// Zeroize overwrites buf with zeros, i.e. to wipe copies of private keys
+func Zeroize(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	runtime.KeepAlive(buf)
+}
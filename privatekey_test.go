@@ -0,0 +1,66 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestPrivateKey(t *testing.T) {
+	pub, raw, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key, err := NewPrivateKey(raw)
+	require.NoError(t, err)
+	Zeroize(raw)
+	assert.Equal(t, make([]byte, ed25519.PrivateKeySize), []byte(raw))
+
+	assert.Equal(t, pub, key.Public())
+	sig, err := key.Sign([]byte("message"))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(pub, []byte("message"), sig))
+
+	if err := key.LockMemory(); err != nil {
+		assert.True(t, errors.Is(err, ErrMemoryLockUnsupported))
+	}
+	material := key.key
+	key.Destroy()
+	assert.True(t, key.Destroyed())
+	assert.Equal(t, make([]byte, ed25519.PrivateKeySize), []byte(material), "the key material needs to be wiped")
+	_, err = key.Sign([]byte("message"))
+	assert.True(t, errors.Is(err, ErrKeyDestroyed))
+	assert.True(t, errors.Is(key.Use(func(ed25519.PrivateKey) error { return nil }), ErrKeyDestroyed))
+	assert.True(t, errors.Is(key.LockMemory(), ErrKeyDestroyed))
+	assert.Equal(t, pub, key.Public())
+	key.Destroy()
+
+	_, err = NewPrivateKey(raw[:10])
+	assert.Error(t, err)
+}
+
+func TestPrivateKeyCA(t *testing.T) {
+	key, err := GeneratePrivateKey(nil)
+	require.NoError(t, err)
+	var rootCert *Certificate
+	require.NoError(t, key.Use(func(raw ed25519.PrivateKey) (err error) {
+		rootCert, err = NewCertificateBuilder().Subject("root").PublicKey(key.Public()).SelfSigned().
+			KeyUsage(KeyUsageSignCert).SignWith(raw)
+		return
+	}))
+	ca, err := NewCAWithSigner(rootCert, key, NewMemoryStore())
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey, WithValidFor(time.Hour))
+	require.NoError(t, err)
+	assert.NoError(t, NewCertPool(rootCert).Validate(cert))
+
+	key.Destroy()
+	_, err = ca.Issue("device", pubKey, WithValidFor(time.Hour))
+	assert.True(t, errors.Is(err, ErrKeyDestroyed))
+	_, err = ca.GenerateCRL()
+	assert.True(t, errors.Is(err, ErrKeyDestroyed))
+}
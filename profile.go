@@ -0,0 +1,123 @@
+package smolcert
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Profile describes a class of certificates, i.e. server certificates. Issuing certificates via
+// profiles makes sure that all certificates of a class get the same KeyUsage, extensions and
+// validity. Custom profiles can be defined by creating new Profile values.
+type Profile struct {
+	// Name describes the profile
+	Name string
+	// KeyUsage is the KeyUsage of certificates of this profile
+	KeyUsage KeyUsage
+	// Validity is the default validity of certificates without explicit Validity. Zero means
+	// certificates don't expire.
+	Validity time.Duration
+	// MaxValidity is the maximum allowed validity of certificates of this profile, zero means unlimited
+	MaxValidity time.Duration
+	// Extensions are added to all certificates of this profile
+	Extensions []Extension
+}
+
+// Predefined profiles
+var (
+	// ProfileRootCA is used for self signed root certificates
+	ProfileRootCA = &Profile{
+		Name:     "RootCA",
+		KeyUsage: KeyUsageSignCert,
+		Validity: time.Hour * 24 * 365 * 20,
+	}
+	// ProfileIntermediateCA is used for intermediate certificates issuing further certificates
+	ProfileIntermediateCA = &Profile{
+		Name:     "IntermediateCA",
+		KeyUsage: KeyUsageSignCert,
+		Validity: time.Hour * 24 * 365 * 5,
+	}
+	// ProfileServer is used for servers
+	ProfileServer = &Profile{
+		Name:        "Server",
+		KeyUsage:    KeyUsageServerIdentification,
+		Validity:    time.Hour * 24 * 90,
+		MaxValidity: time.Hour * 24 * 398,
+	}
+	// ProfileClient is used for short lived client certificates, i.e. of users
+	ProfileClient = &Profile{
+		Name:        "Client",
+		KeyUsage:    KeyUsageClientIdentification,
+		Validity:    time.Hour * 24 * 30,
+		MaxValidity: time.Hour * 24 * 398,
+	}
+	// ProfileDevice is used for long lived device identities, i.e. provisioned during manufacturing
+	ProfileDevice = &Profile{
+		Name:     "Device",
+		KeyUsage: KeyUsageClientIdentification,
+		Validity: time.Hour * 24 * 365 * 10,
+	}
+)
+
+// IsCA is true if certificates of this profile can issue certificates
+func (p *Profile) IsCA() bool {
+	return p.KeyUsage == KeyUsageSignCert
+}
+
+// Apply adds the KeyUsage and extensions of the profile to cert and sets the default validity if
+// cert has no Validity. The result is checked against the profile.
+func (p *Profile) Apply(cert *Certificate) error {
+	return p.apply(cert, time.Now())
+}
+
+func (p *Profile) apply(cert *Certificate, now time.Time) error {
+	if cert.Validity == nil {
+		cert.Validity = &Validity{NotBefore: NewTime(now)}
+		if p.Validity > 0 {
+			cert.Validity.NotAfter = NewTime(now.Add(p.Validity))
+		}
+	}
+	if cert.Extensions == nil {
+		cert.Extensions = []Extension{}
+	}
+	cert.Extensions = ensureExtension(cert.Extensions, Extension{
+		OID:      OIDKeyUsage,
+		Critical: true,
+		Value:    p.KeyUsage.ToBytes(),
+	})
+	for _, ext := range p.Extensions {
+		cert.Extensions = ensureExtension(cert.Extensions, ext)
+	}
+	return p.Check(cert)
+}
+
+// Check verifies that cert conforms to the profile
+func (p *Profile) Check(cert *Certificate) error {
+	if err := RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(p.KeyUsage)); err != nil {
+		return fmt.Errorf("Certificate doesn't conform to profile %s: %w", p.Name, err)
+	}
+	if p.MaxValidity > 0 {
+		if cert.Validity == nil || cert.Validity.NotBefore.IsZero() || cert.Validity.NotAfter.IsZero() {
+			return fmt.Errorf("Certificates of profile %s need a bounded validity", p.Name)
+		}
+		validity := cert.Validity.Duration()
+		if validity > p.MaxValidity {
+			return fmt.Errorf("Validity of %s exceeds the maximum of %s of profile %s", validity, p.MaxValidity, p.Name)
+		}
+	}
+	for _, ext := range p.Extensions {
+		if err := RequiresExtension(cert, ext.OID, func(critical bool, val []byte) error { return nil }); err != nil {
+			return fmt.Errorf("Certificate doesn't conform to profile %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// Sign applies the profile to cert and signs it with issuerKey
+func (p *Profile) Sign(cert *Certificate, issuerKey ed25519.PrivateKey) (*Certificate, error) {
+	if err := p.Apply(cert); err != nil {
+		return nil, err
+	}
+	return SignCertificate(cert, issuerKey)
+}
@@ -0,0 +1,65 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestProfiles(t *testing.T) {
+	rootPub, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	root, err := ProfileRootCA.Sign(&Certificate{SerialNumber: 1, Issuer: "root", Subject: "root", PubKey: rootPub}, rootKey)
+	require.NoError(t, err)
+	assert.True(t, ProfileRootCA.IsCA())
+	assert.True(t, root.ExpiresWithin(ProfileRootCA.Validity+time.Minute))
+	pool := NewCertPool(root)
+
+	for _, p := range []*Profile{ProfileIntermediateCA, ProfileServer, ProfileClient, ProfileDevice} {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		cert, err := p.Sign(&Certificate{SerialNumber: 2, Issuer: "root", Subject: p.Name, PubKey: pub}, rootKey)
+		require.NoError(t, err, p.Name)
+		assert.NoError(t, pool.Validate(cert), p.Name)
+		assert.NoError(t, RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(p.KeyUsage)), p.Name)
+		assert.NoError(t, p.Check(cert), p.Name)
+	}
+}
+
+func TestProfileOverridesKeyUsage(t *testing.T) {
+	// Leaf certificates can't become CA capable by accident
+	cert := &Certificate{
+		Extensions: []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}},
+	}
+	require.NoError(t, ProfileServer.Apply(cert))
+	assert.Len(t, cert.Extensions, 1)
+	assert.NoError(t, RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageServerIdentification)))
+	assert.Error(t, ProfileRootCA.Check(cert))
+}
+
+func TestCustomProfile(t *testing.T) {
+	delegation, err := DelegationExtension(time.Hour)
+	require.NoError(t, err)
+	p := &Profile{
+		Name:        "Gateway",
+		KeyUsage:    KeyUsageServerIdentification,
+		Validity:    time.Hour * 24,
+		MaxValidity: time.Hour * 48,
+		Extensions:  []Extension{delegation},
+	}
+	cert := &Certificate{}
+	require.NoError(t, p.Apply(cert))
+	_, err = findDelegation(cert)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	tooLong := &Certificate{Validity: &Validity{NotBefore: NewTime(now), NotAfter: NewTime(now.Add(time.Hour * 72))}}
+	assert.Error(t, p.Apply(tooLong))
+	unbounded := &Certificate{Validity: &Validity{}}
+	assert.Error(t, p.Apply(unbounded))
+	assert.Error(t, p.Check(&Certificate{}))
+}
@@ -0,0 +1,97 @@
+/*
+Package prommetrics exports the metrics of smolcert Validators and CAs to Prometheus:
+
+	metrics := prommetrics.New("myservice")
+	prometheus.MustRegister(metrics)
+	validator.Metrics = metrics
+	ca.Metrics = metrics
+*/
+package prommetrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/smolcert/smolcert"
+)
+
+// Metrics implements smolcert.Metrics with Prometheus collectors. It needs to be registered with
+// a prometheus.Registerer to be exported.
+type Metrics struct {
+	validations        *prometheus.CounterVec
+	validationFailures *prometheus.CounterVec
+	validationDuration prometheus.Histogram
+	issued             *prometheus.CounterVec
+	revocations        *prometheus.CounterVec
+}
+
+var _ smolcert.Metrics = &Metrics{}
+var _ prometheus.Collector = &Metrics{}
+
+// New creates Metrics with the given namespace, all metrics are part of the subsystem "smolcert"
+func New(namespace string) *Metrics {
+	opts := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{Namespace: namespace, Subsystem: "smolcert", Name: name, Help: help}
+	}
+	return &Metrics{
+		validations: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("validations_total", "Number of certificate validations by result")), []string{"result"}),
+		validationFailures: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("validation_failures_total", "Number of failed certificate validations by reason")), []string{"reason"}),
+		validationDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "smolcert",
+			Name:      "validation_duration_seconds",
+			Help:      "Duration of certificate validations",
+			Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 12),
+		}),
+		issued: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("issuances_total", "Number of certificate issuances by result")), []string{"result"}),
+		revocations: prometheus.NewCounterVec(prometheus.CounterOpts(
+			opts("revocations_total", "Number of revoked certificates by reason")), []string{"reason"}),
+	}
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// ObserveValidation implements smolcert.Metrics
+func (m *Metrics) ObserveValidation(duration time.Duration, err error) {
+	m.validations.WithLabelValues(result(err)).Inc()
+	if err != nil {
+		m.validationFailures.WithLabelValues(smolcert.FailureReason(err)).Inc()
+	}
+	m.validationDuration.Observe(duration.Seconds())
+}
+
+// ObserveIssuance implements smolcert.Metrics
+func (m *Metrics) ObserveIssuance(cert *smolcert.Certificate, err error) {
+	m.issued.WithLabelValues(result(err)).Inc()
+}
+
+// ObserveRevocation implements smolcert.Metrics
+func (m *Metrics) ObserveRevocation(entry smolcert.RevokedCertificate) {
+	m.revocations.WithLabelValues(entry.Reason.String()).Inc()
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.validations.Describe(ch)
+	m.validationFailures.Describe(ch)
+	m.validationDuration.Describe(ch)
+	m.issued.Describe(ch)
+	m.revocations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.validations.Collect(ch)
+	m.validationFailures.Collect(ch)
+	m.validationDuration.Collect(ch)
+	m.issued.Collect(ch)
+	m.revocations.Collect(ch)
+}
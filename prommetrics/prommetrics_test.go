@@ -0,0 +1,51 @@
+package prommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := New("test")
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(metrics))
+
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.Metrics = metrics
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	require.NoError(t, ca.Revoke(cert.SerialNumber, smolcert.RevocationReasonKeyCompromise))
+
+	validator := smolcert.NewValidator(smolcert.NewCertPool(rootCert))
+	validator.Metrics = metrics
+	assert.NoError(t, validator.Validate(cert))
+	_, err = validator.ValidateBundle([]*smolcert.Certificate{rootCert, {Subject: "device", Issuer: "unknown",
+		Validity: &smolcert.Validity{}}})
+	assert.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.issued.WithLabelValues("success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.revocations.WithLabelValues("KeyCompromise")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.validations.WithLabelValues("success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.validations.WithLabelValues("failure")))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	names := []string{}
+	for _, family := range families {
+		names = append(names, family.GetName())
+	}
+	assert.Contains(t, names, "test_smolcert_validation_duration_seconds")
+	assert.Contains(t, names, "test_smolcert_validation_failures_total")
+}
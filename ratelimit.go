@@ -0,0 +1,188 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned if a CA refuses to sign, because a RateLimiter has been exhausted
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RateLimitError is returned if the global rate or the quota of a caller has been exhausted. It
+// wraps ErrRateLimited.
+type RateLimitError struct {
+	// Caller is the caller whose quota has been exhausted, it is empty if the global rate has been
+	// exhausted
+	Caller string
+	// RetryAfter is the time until the request can succeed
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Caller != "" {
+		return fmt.Sprintf("%s: quota of '%s' exhausted, retry after %s", ErrRateLimited, e.Caller, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s: retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+// Unwrap returns ErrRateLimited
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RateLimit configures a token bucket: up to Burst certificates can be signed at once, afterwards
+// Rate certificates per second. A RateLimit with zero Burst doesn't limit anything.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+func (l RateLimit) unlimited() bool {
+	return l.Burst <= 0
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// refill adds the tokens since the last update and returns true if the bucket is full
+func (b *tokenBucket) refill(l RateLimit, now time.Time) bool {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(l.Burst), b.tokens+elapsed.Seconds()*l.Rate)
+		b.updated = now
+	}
+	return b.tokens >= float64(l.Burst)
+}
+
+// retryAfter returns the time until n tokens are available, the bucket needs to be refilled before
+func (b *tokenBucket) retryAfter(l RateLimit, n int) time.Duration {
+	if float64(n) > float64(l.Burst) || l.Rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((float64(n) - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// maxIdleCallers is the number of callers tracked before the callers with full buckets are
+// forgotten. A full bucket is equivalent to no bucket, so this only limits memory.
+const maxIdleCallers = 4096
+
+// RateLimiter limits the rate at which a CA signs certificates, globally and per caller, so a
+// single misbehaving client can't exhaust the signer. The caller of an issuance is set with the
+// IssueOption WithCaller, issuances without caller are only subject to the global limit.
+// A RateLimiter is safe for concurrent use and can be shared by several CAs using the same signer.
+type RateLimiter struct {
+	// Global limits all issuances
+	Global RateLimit
+	// PerCaller is the quota of every single caller
+	PerCaller RateLimit
+
+	lock    sync.Mutex
+	global  *tokenBucket
+	callers map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewRateLimiter creates a RateLimiter with the global limit and the quota per caller. Both
+// buckets are full initially.
+func NewRateLimiter(global, perCaller RateLimit) *RateLimiter {
+	return &RateLimiter{
+		Global:    global,
+		PerCaller: perCaller,
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token for caller, it returns a *RateLimitError if no token is available
+func (l *RateLimiter) Allow(caller string) error {
+	return l.AllowN(caller, 1)
+}
+
+// AllowN takes n tokens for caller, i.e. for a batch. Either all tokens are taken or none and a
+// *RateLimitError is returned.
+func (l *RateLimiter) AllowN(caller string, n int) error {
+	return l.allowQuotas([]quota{{caller: caller, n: n}})
+}
+
+// quota is the number of tokens a batch takes from the bucket of a caller
+type quota struct {
+	caller string
+	n      int
+}
+
+// allowQuotas takes the tokens of all quotas from the buckets of their callers and their sum from
+// the global bucket. The buckets are checked before any token is taken, so either all tokens are
+// taken or none. The callers of quotas need to be distinct.
+func (l *RateLimiter) allowQuotas(quotas []quota) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	if l.now != nil {
+		now = l.now()
+	}
+	if l.global == nil {
+		l.global = &tokenBucket{tokens: float64(l.Global.Burst), updated: now}
+	}
+	total := 0
+	for _, q := range quotas {
+		total += q.n
+	}
+	if !l.Global.unlimited() {
+		l.global.refill(l.Global, now)
+		if l.global.tokens < float64(total) {
+			return &RateLimitError{RetryAfter: l.global.retryAfter(l.Global, total)}
+		}
+	}
+	buckets := make([]*tokenBucket, len(quotas))
+	for i, q := range quotas {
+		if q.caller == "" || l.PerCaller.unlimited() {
+			continue
+		}
+		if l.callers == nil {
+			l.callers = map[string]*tokenBucket{}
+		}
+		bucket := l.callers[q.caller]
+		if bucket == nil {
+			l.forgetIdleCallers(now)
+			bucket = &tokenBucket{tokens: float64(l.PerCaller.Burst), updated: now}
+			l.callers[q.caller] = bucket
+		}
+		bucket.refill(l.PerCaller, now)
+		if bucket.tokens < float64(q.n) {
+			return &RateLimitError{Caller: q.caller, RetryAfter: bucket.retryAfter(l.PerCaller, q.n)}
+		}
+		buckets[i] = bucket
+	}
+	if !l.Global.unlimited() {
+		l.global.tokens -= float64(total)
+	}
+	for i, bucket := range buckets {
+		if bucket != nil {
+			bucket.tokens -= float64(quotas[i].n)
+		}
+	}
+	return nil
+}
+
+func (l *RateLimiter) forgetIdleCallers(now time.Time) {
+	if len(l.callers) < maxIdleCallers {
+		return
+	}
+	for caller, bucket := range l.callers {
+		if bucket.refill(l.PerCaller, now) {
+			delete(l.callers, caller)
+		}
+	}
+}
+
+// WithCaller records who requested the certificate, i.e. the authenticated client of a CA
+// service. The quota of the caller is charged if the CA has a RateLimiter.
+func WithCaller(caller string) IssueOption {
+	return func(b *CertificateBuilder) {
+		b.caller = caller
+	}
+}
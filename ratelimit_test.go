@@ -0,0 +1,146 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(RateLimit{Rate: 2, Burst: 4}, RateLimit{Rate: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	require.NoError(t, l.Allow("a"))
+	require.NoError(t, l.Allow("a"))
+	err := l.Allow("a")
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	var limited *RateLimitError
+	require.True(t, errors.As(err, &limited))
+	assert.Equal(t, "a", limited.Caller)
+	assert.Equal(t, time.Second, limited.RetryAfter)
+
+	// Other callers have their own quota, but share the global limit
+	require.NoError(t, l.Allow("b"))
+	require.NoError(t, l.Allow(""))
+	err = l.Allow("c")
+	require.True(t, errors.As(err, &limited))
+	assert.Equal(t, "", limited.Caller)
+	assert.Equal(t, time.Second/2, limited.RetryAfter)
+
+	now = now.Add(time.Second)
+	require.NoError(t, l.Allow("a"))
+	assert.True(t, errors.Is(l.Allow("a"), ErrRateLimited))
+
+	// AllowN takes all tokens or none
+	now = now.Add(time.Hour)
+	assert.True(t, errors.Is(l.AllowN("a", 3), ErrRateLimited))
+	require.NoError(t, l.AllowN("a", 2))
+	err = l.AllowN("b", 5)
+	require.True(t, errors.As(err, &limited))
+	assert.True(t, limited.RetryAfter > time.Hour, "a request larger than the burst never succeeds")
+
+	unlimited := NewRateLimiter(RateLimit{}, RateLimit{})
+	for i := 0; i < 100; i++ {
+		require.NoError(t, unlimited.Allow("a"))
+	}
+}
+
+func TestRateLimiterForgetsIdleCallers(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(RateLimit{}, RateLimit{Rate: 1, Burst: 1})
+	l.now = func() time.Time { return now }
+	for i := 0; i < maxIdleCallers; i++ {
+		require.NoError(t, l.Allow(fmt.Sprintf("caller-%d", i)))
+	}
+	now = now.Add(time.Second)
+	require.NoError(t, l.Allow("new"))
+	assert.Len(t, l.callers, 1)
+	assert.True(t, errors.Is(l.Allow("new"), ErrRateLimited))
+}
+
+func TestCARateLimit(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.RateLimiter = NewRateLimiter(RateLimit{Rate: 0.001, Burst: 20}, RateLimit{Rate: 0.001, Burst: 5})
+	signed := 0
+	ca.OnIssue = func(cert *Certificate) error {
+		signed++
+		return nil
+	}
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	// A misbehaving caller exhausts only its own quota
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	issued := map[string]int{}
+	for i := 0; i < 40; i++ {
+		caller := "greedy"
+		if i%4 == 0 {
+			caller = "polite"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ca.Issue("device", pubKey, WithCaller(caller))
+			if err != nil {
+				assert.True(t, errors.Is(err, ErrRateLimited))
+				return
+			}
+			lock.Lock()
+			issued[caller]++
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, map[string]int{"greedy": 5, "polite": 5}, issued)
+	assert.Equal(t, 10, signed)
+
+	// Batches are charged before signing
+	requests := make([]BatchRequest, 6)
+	for i := range requests {
+		requests[i] = BatchRequest{Subject: "device", PubKey: pubKey}
+	}
+	_, err = ca.IssueBatch(requests, WithCaller("batch"))
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	certs, err := ca.IssueBatch(requests[:5], WithCaller("batch"))
+	require.NoError(t, err)
+	assert.Len(t, certs, 5)
+	assert.Equal(t, 15, signed)
+}
+
+func TestCARateLimitRejectedBatch(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := NewCA(rootCert, rootKey)
+	require.NoError(t, err)
+	ca.RateLimiter = NewRateLimiter(RateLimit{Rate: 0.001, Burst: 9}, RateLimit{Rate: 0.001, Burst: 3})
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	batch := func(callers ...string) []BatchRequest {
+		requests := make([]BatchRequest, len(callers))
+		for i, caller := range callers {
+			requests[i] = BatchRequest{Subject: "device", PubKey: pubKey, Options: []IssueOption{WithCaller(caller)}}
+		}
+		return requests
+	}
+
+	// The quota of b is exceeded, so neither a nor the global limit are charged
+	_, err = ca.IssueBatch(batch("a", "a", "b", "b", "b", "b"))
+	var limited *RateLimitError
+	require.True(t, errors.As(err, &limited))
+	assert.Equal(t, "b", limited.Caller)
+
+	certs, err := ca.IssueBatch(batch("a", "a", "a", "b", "b", "b", "c", "c", "c"))
+	require.NoError(t, err)
+	assert.Len(t, certs, 9)
+}
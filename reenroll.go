@@ -0,0 +1,148 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+var reenrollContext = []byte("smolcert re-enrollment")
+
+// RenewalRequest is sent by a device to obtain a fresh certificate. It is authenticated by the
+// current certificate of the device and optionally requests a certificate for a new key.
+type RenewalRequest struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Certificate is the current certificate of the device
+	Certificate *Certificate `cbor:"certificate"`
+	// Subject is the requested subject of the new certificate
+	Subject string `cbor:"subject"`
+	// PubKey is the public key of the new certificate
+	PubKey    ed25519.PublicKey `cbor:"public_key"`
+	Timestamp Time              `cbor:"timestamp"`
+	// Signature is created with the key of the current certificate
+	Signature []byte `cbor:"signature"`
+	// KeySignature is created with the new key to prove its possession, empty if the key doesn't change
+	KeySignature []byte `cbor:"key_signature"`
+}
+
+// NewRenewalRequest creates a RenewalRequest authenticated by the current certificate and key of a
+// device. If newKey is nil, a certificate for the current key is requested.
+func NewRenewalRequest(current *Certificate, currentKey, newKey ed25519.PrivateKey) (*RenewalRequest, error) {
+	req := &RenewalRequest{
+		Certificate: current,
+		Subject:     current.Subject,
+		PubKey:      current.PubKey,
+		Timestamp:   NewTime(time.Now()),
+	}
+	if newKey != nil {
+		req.PubKey = newKey.Public().(ed25519.PublicKey)
+	}
+	tbs, err := req.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	req.Signature = ed25519.Sign(currentKey, tbs)
+	if newKey != nil {
+		req.KeySignature = ed25519.Sign(newKey, tbs)
+	}
+	return req, nil
+}
+
+// ParseRenewalRequest parses a RenewalRequest from an existing byte buffer
+func ParseRenewalRequest(buf []byte) (*RenewalRequest, error) {
+	req := &RenewalRequest{}
+	if err := cbor.Unmarshal(buf, req); err != nil {
+		return nil, err
+	}
+	if req.Certificate == nil {
+		return nil, errors.New("Renewal request doesn't contain a certificate")
+	}
+	return req, nil
+}
+
+// Bytes returns the CBOR encoded form of the RenewalRequest
+func (req *RenewalRequest) Bytes() ([]byte, error) {
+	return cborEm.Marshal(req)
+}
+
+func (req *RenewalRequest) tbsBytes() ([]byte, error) {
+	reqBytes, err := cborEm.Marshal(&RenewalRequest{
+		Certificate: req.Certificate,
+		Subject:     req.Subject,
+		PubKey:      req.PubKey,
+		Timestamp:   req.Timestamp,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, reenrollContext...), reqBytes...), nil
+}
+
+// RenewalPolicy restricts which RenewalRequests are accepted by a Reenroller
+type RenewalPolicy struct {
+	// Validity is the validity of issued certificates
+	Validity time.Duration
+	// MaxRequestAge is the maximum age of a renewal request, zero disables the check
+	MaxRequestAge time.Duration
+	// AllowSubjectChange allows devices to request a different subject than in their current certificate
+	AllowSubjectChange bool
+	// Check is an optional hook called for every request after it has been authenticated. The
+	// request is rejected if an error is returned.
+	Check func(current *Certificate, req *RenewalRequest) error
+}
+
+// Reenroller issues fresh certificates to devices authenticating with their current certificate.
+type Reenroller struct {
+	// Pool is used to validate the current certificates of devices
+	Pool *CertPool
+	// Issuer is the name of the issuer of newly issued certificates
+	Issuer string
+	// IssuerKey is used to sign newly issued certificates
+	IssuerKey ed25519.PrivateKey
+	Policy    RenewalPolicy
+}
+
+// Renew validates a RenewalRequest and issues a new certificate if the request is acceptable. The
+// new certificate has the same extensions as the current certificate and a random serial number.
+func (r *Reenroller) Renew(req *RenewalRequest) (*Certificate, error) {
+	if r.Policy.Validity <= 0 {
+		return nil, errors.New("Renewal policy doesn't specify a validity for new certificates")
+	}
+	current := req.Certificate
+	if current == nil {
+		return nil, errors.New("Renewal request doesn't contain a certificate")
+	}
+	if err := r.Pool.Validate(current); err != nil {
+		return nil, fmt.Errorf("Current certificate is invalid: %w", err)
+	}
+	tbs, err := req.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	if !verifySignature(current.PubKey, tbs, req.Signature) {
+		return nil, newValidationError(ErrBadSignature, current, "Renewal request is not signed by the current certificate")
+	}
+	if !bytes.Equal(current.PubKey, req.PubKey) && !verifySignature(req.PubKey, tbs, req.KeySignature) {
+		return nil, errors.New("Renewal request doesn't prove possession of the new key")
+	}
+	if r.Policy.MaxRequestAge > 0 && time.Since(req.Timestamp.StdTime()) > r.Policy.MaxRequestAge {
+		return nil, fmt.Errorf("Renewal request is older than %s", r.Policy.MaxRequestAge)
+	}
+	if !r.Policy.AllowSubjectChange && req.Subject != current.Subject {
+		return nil, fmt.Errorf("Requested subject '%s' differs from the current subject '%s'", req.Subject, current.Subject)
+	}
+	if r.Policy.Check != nil {
+		if err := r.Policy.Check(current, req); err != nil {
+			return nil, err
+		}
+	}
+
+	return Issue(req.Subject, req.PubKey, r.Issuer, r.IssuerKey,
+		WithValidFor(r.Policy.Validity),
+		WithExtensions(current.Extensions...))
+}
@@ -0,0 +1,112 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestReenrollment(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	current, currentKey, err := ClientCertificate("device", 2, now.Add(-time.Hour), now.Add(time.Minute), nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	r := &Reenroller{
+		Pool:      pool,
+		Issuer:    rootCert.Subject,
+		IssuerKey: rootKey,
+		Policy: RenewalPolicy{
+			Validity:      time.Hour * 24,
+			MaxRequestAge: time.Minute,
+		},
+	}
+
+	req, err := NewRenewalRequest(current, currentKey, nil)
+	require.NoError(t, err)
+	reqBytes, err := req.Bytes()
+	require.NoError(t, err)
+	req, err = ParseRenewalRequest(reqBytes)
+	require.NoError(t, err)
+
+	renewed, err := r.Renew(req)
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(renewed))
+	assert.Equal(t, current.PubKey, renewed.PubKey)
+	assert.Equal(t, current.Subject, renewed.Subject)
+	assert.True(t, renewed.RemainingValidity() > time.Hour*23)
+
+	// Request a certificate for a new key
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	req, err = NewRenewalRequest(current, currentKey, newKey)
+	require.NoError(t, err)
+	renewed, err = r.Renew(req)
+	require.NoError(t, err)
+	assert.Equal(t, newKey.Public(), renewed.PubKey)
+
+	// The new key needs to be proven
+	req.KeySignature = nil
+	_, err = r.Renew(req)
+	assert.Error(t, err)
+
+	// Requests need to be signed by the current key
+	req, err = NewRenewalRequest(current, newKey, nil)
+	require.NoError(t, err)
+	_, err = r.Renew(req)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+}
+
+func TestReenrollmentPolicy(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	current, currentKey, err := ClientCertificate("device", 2, now.Add(-time.Hour), now.Add(time.Minute), nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	hookErr := errors.New("device unknown")
+	r := &Reenroller{
+		Pool:      NewCertPool(rootCert),
+		Issuer:    rootCert.Subject,
+		IssuerKey: rootKey,
+		Policy: RenewalPolicy{
+			Validity:      time.Hour,
+			MaxRequestAge: time.Minute,
+		},
+	}
+
+	req, err := NewRenewalRequest(current, currentKey, nil)
+	require.NoError(t, err)
+	req.Subject = "other device"
+	tbs, err := req.tbsBytes()
+	require.NoError(t, err)
+	req.Signature = ed25519.Sign(currentKey, tbs)
+	_, err = r.Renew(req)
+	assert.Error(t, err)
+
+	r.Policy.AllowSubjectChange = true
+	_, err = r.Renew(req)
+	assert.NoError(t, err)
+
+	r.Policy.Check = func(current *Certificate, req *RenewalRequest) error {
+		return hookErr
+	}
+	_, err = r.Renew(req)
+	assert.Equal(t, hookErr, err)
+
+	// Expired certificates can't be renewed
+	expired, expiredKey, err := ClientCertificate("device", 3, now.Add(-time.Hour), now.Add(-time.Minute), nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	req, err = NewRenewalRequest(expired, expiredKey, nil)
+	require.NoError(t, err)
+	r.Policy.Check = nil
+	_, err = r.Renew(req)
+	assert.True(t, errors.Is(err, ErrExpired))
+}
@@ -0,0 +1,165 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// DefaultReloadInterval is the interval in which a Reloader checks its files, if no Interval is set
+const DefaultReloadInterval = 10 * time.Second
+
+// Credentials are the certificates, private key and trusted roots of a server, as loaded by a
+// Reloader. Credentials are never modified once loaded, so they can be shared between goroutines.
+type Credentials struct {
+	// Certificates contains the certificate of the server first, followed by intermediates
+	Certificates []*Certificate
+	Key          ed25519.PrivateKey
+	// Pool contains the trusted roots, it is nil if the Reloader has no PoolFile
+	Pool *CertPool
+}
+
+// Reloader keeps the Credentials of a running server up to date with files on disk, so that
+// certificates, keys and roots can be rotated without a restart. Files are polled while Run is
+// running, a new version is swapped in atomically once all files have been loaded and the key
+// matches the certificate. A rotation replacing the certificate and key files one after
+// another is therefore picked up once both have been written.
+type Reloader struct {
+	// CertFile contains a single certificate or a Bundle, i.e. written by WriteCertFile
+	CertFile string
+	// KeyFile contains the PEM encoded private key of the certificate, i.e. written by WriteKeyFile
+	KeyFile string
+	// PoolFile optionally contains trusted roots, i.e. written by WritePoolFile
+	PoolFile string
+	// Interval is the interval in which the files are checked for changes
+	Interval time.Duration
+	// OnReload is optionally called after new Credentials have been swapped in
+	OnReload func(creds *Credentials)
+	// OnError is optionally called when changed files can't be loaded. The previous Credentials
+	// stay in use.
+	OnError func(err error)
+
+	credentials atomic.Value
+	lock        sync.Mutex
+	fingerprint [sha256.Size]byte
+	// failed is the fingerprint of files which couldn't be loaded, so they are reported only once
+	failed [sha256.Size]byte
+}
+
+// NewReloader creates a Reloader for the given files and loads the initial Credentials. poolFile
+// may be empty if no trusted roots need to be loaded.
+func NewReloader(certFile, keyFile, poolFile string) (*Reloader, error) {
+	r := &Reloader{CertFile: certFile, KeyFile: keyFile, PoolFile: poolFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Credentials returns the current Credentials, nil if none have been loaded yet
+func (r *Reloader) Credentials() *Credentials {
+	creds, _ := r.credentials.Load().(*Credentials)
+	return creds
+}
+
+// Pool returns the current trusted roots
+func (r *Reloader) Pool() *CertPool {
+	if creds := r.Credentials(); creds != nil {
+		return creds.Pool
+	}
+	return nil
+}
+
+// Reload loads the files and swaps in new Credentials if any file has changed since the last
+// successful load. It returns true if the Credentials have been replaced. Files which failed to
+// load are not loaded again until they change.
+func (r *Reloader) Reload() (bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	files := [][]byte{}
+	h := sha256.New()
+	for _, filename := range []string{r.CertFile, r.KeyFile, r.PoolFile} {
+		var data []byte
+		if filename != "" {
+			var err error
+			if data, err = ioutil.ReadFile(filename); err != nil {
+				return false, err
+			}
+		}
+		// Hash the length too, so contents moved between files are detected
+		fmt.Fprintf(h, "%d:", len(data))
+		h.Write(data)
+		files = append(files, data)
+	}
+	var fingerprint [sha256.Size]byte
+	h.Sum(fingerprint[:0])
+	if r.Credentials() != nil && (fingerprint == r.fingerprint || fingerprint == r.failed) {
+		return false, nil
+	}
+
+	creds, err := parseCredentials(files[0], files[1], files[2], r.PoolFile != "")
+	if err != nil {
+		r.failed = fingerprint
+		return false, err
+	}
+	r.credentials.Store(creds)
+	r.fingerprint = fingerprint
+	if r.OnReload != nil {
+		r.OnReload(creds)
+	}
+	return true, nil
+}
+
+func parseCredentials(certData, keyData, poolData []byte, withPool bool) (*Credentials, error) {
+	certs, err := parseCertFile(certData)
+	if err != nil {
+		return nil, err
+	}
+	creds := &Credentials{Certificates: certs}
+	key, err := ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse key file: %w", err)
+	}
+	if !bytes.Equal(key.Public().(ed25519.PublicKey), creds.Certificates[0].PubKey) {
+		return nil, errors.New("Private key doesn't belong to the certificate")
+	}
+	creds.Key = key
+	if withPool {
+		if creds.Pool, err = LoadPool(bytes.NewReader(poolData)); err != nil {
+			return nil, fmt.Errorf("Failed to parse pool file: %w", err)
+		}
+	}
+	return creds, nil
+}
+
+// Run checks the files for changes in every Interval until ctx is done. Errors are reported to
+// OnError.
+func (r *Reloader) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reload(); err != nil && r.OnError != nil {
+				r.OnError(err)
+			}
+		}
+	}
+}
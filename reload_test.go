@@ -0,0 +1,145 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func writeServerFiles(t *testing.T, dir string, bundle []*Certificate, key ed25519.PrivateKey, pool *CertPool) {
+	require.NoError(t, WriteCertFile(filepath.Join(dir, "cert.cbor"), bundle...))
+	require.NoError(t, WriteKeyFile(filepath.Join(dir, "key.pem"), key))
+	if pool != nil {
+		require.NoError(t, WritePoolFile(filepath.Join(dir, "pool.cbor"), pool))
+	}
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smolcert-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	serverCert, serverKey, err := ServerCertificate("server", 1, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	writeServerFiles(t, dir, []*Certificate{serverCert}, serverKey, NewCertPool(rootCert))
+
+	r, err := NewReloader(filepath.Join(dir, "cert.cbor"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "pool.cbor"))
+	require.NoError(t, err)
+	creds := r.Credentials()
+	require.Len(t, creds.Certificates, 1)
+	assert.Equal(t, uint64(1), creds.Certificates[0].SerialNumber)
+	assert.Equal(t, serverKey, creds.Key)
+	assert.NoError(t, r.Pool().Validate(serverCert))
+
+	var reloaded []*Credentials
+	r.OnReload = func(creds *Credentials) { reloaded = append(reloaded, creds) }
+	changed, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, reloaded)
+
+	// Replacing only the certificate keeps the previous credentials until the key is replaced too
+	renewedCert, renewedKey, err := ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	certBytes, err := renewedCert.Bytes()
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cert.cbor"), certBytes, 0600))
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, creds, r.Credentials())
+	// Failed files are reported once
+	changed, err = r.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	keyBytes, err := MarshalPrivateKey(renewedKey)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyBytes, 0600))
+	changed, err = r.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	require.Len(t, reloaded, 1)
+	assert.Equal(t, reloaded[0], r.Credentials())
+	assert.Equal(t, uint64(2), r.Credentials().Certificates[0].SerialNumber)
+	assert.Equal(t, renewedKey, r.Credentials().Key)
+
+	// Missing files are errors, the credentials stay in use
+	require.NoError(t, os.Remove(filepath.Join(dir, "pool.cbor")))
+	_, err = r.Reload()
+	assert.Error(t, err)
+	assert.NotNil(t, r.Pool())
+
+	_, err = NewReloader(filepath.Join(dir, "cert.cbor"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "pool.cbor"))
+	assert.Error(t, err)
+	withoutPool, err := NewReloader(filepath.Join(dir, "cert.cbor"), filepath.Join(dir, "key.pem"), "")
+	require.NoError(t, err)
+	assert.Nil(t, withoutPool.Pool())
+}
+
+func TestReloaderRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smolcert-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	serverCert, serverKey, err := ServerCertificate("server", 3, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	writeServerFiles(t, dir, []*Certificate{serverCert, intermediate}, serverKey, nil)
+
+	r, err := NewReloader(filepath.Join(dir, "cert.cbor"), filepath.Join(dir, "key.pem"), "")
+	require.NoError(t, err)
+	assert.Len(t, r.Credentials().Certificates, 2)
+	r.Interval = 10 * time.Millisecond
+	reloaded := make(chan *Credentials, 1)
+	r.OnReload = func(creds *Credentials) { reloaded <- creds }
+	errs := make(chan error, 1)
+	r.OnError = func(err error) {
+		// The renewed files below are written one after another, which may be reported too
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.pem"), []byte("invalid"), 0600))
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Invalid key hasn't been reported")
+	}
+
+	renewedCert, renewedKey, err := ServerCertificate("server", 4, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	writeServerFiles(t, dir, []*Certificate{renewedCert, intermediate}, renewedKey, nil)
+	select {
+	case creds := <-reloaded:
+		assert.Equal(t, uint64(4), creds.Certificates[0].SerialNumber)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Renewed certificate hasn't been loaded")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,106 @@
+package remotesign
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// maxResponseSize limits the size of responses read from a Server
+const maxResponseSize = 1024 * 1024
+
+// Client is a smolcert.Signer signing with the key held by a Server
+type Client struct {
+	url        string
+	token      string
+	httpClient *http.Client
+	cert       *smolcert.Certificate
+	// ctx is used for signing requests, as smolcert.Signer doesn't pass a context
+	ctx context.Context
+}
+
+// NewClient creates a Client for the Server at url, authenticating with the bearer token. The CA
+// certificate is fetched from the server, ctx is used for this and all signing requests. If
+// httpClient is nil, http.DefaultClient is used.
+func NewClient(ctx context.Context, url, token string, httpClient *http.Client) (*Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	c := &Client{url: strings.TrimSuffix(url, "/"), token: token, httpClient: httpClient, ctx: ctx}
+	buf, err := c.do(ctx, http.MethodGet, PathCertificate, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.cert, err = smolcert.ParseBuf(buf); err != nil {
+		return nil, fmt.Errorf("Invalid CA certificate: %w", err)
+	}
+	if len(c.cert.PubKey) != ed25519.PublicKeySize {
+		return nil, errors.New("CA certificate has no ed25519 public key")
+	}
+	return c, nil
+}
+
+// Certificate returns the CA certificate of the Server
+func (c *Client) Certificate() *smolcert.Certificate {
+	return c.cert
+}
+
+// Public implements smolcert.Signer
+func (c *Client) Public() ed25519.PublicKey {
+	return c.cert.PubKey
+}
+
+// Sign implements smolcert.Signer. The server only signs certificates and CRLs issued by its CA.
+func (c *Client) Sign(message []byte) ([]byte, error) {
+	payload, err := cborEm.Marshal(&SignRequest{Message: message})
+	if err != nil {
+		return nil, err
+	}
+	buf, err := c.do(c.ctx, http.MethodPost, PathSign, payload)
+	if err != nil {
+		return nil, err
+	}
+	resp := &SignResponse{}
+	if err := cbor.Unmarshal(buf, resp); err != nil {
+		return nil, fmt.Errorf("Invalid signing response: %w", err)
+	}
+	return resp.Signature, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, payload []byte) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, c.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if payload != nil {
+		req.Header.Set("Content-Type", ContentType)
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Signing request failed with %s: %s", resp.Status, strings.TrimSpace(string(buf)))
+	}
+	return buf, nil
+}
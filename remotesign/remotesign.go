@@ -0,0 +1,92 @@
+/*
+Package remotesign separates the CA key from the online CA frontend. A signing daemon holds the key
+and signs on behalf of authenticated frontends, which use a Client as smolcert.Signer:
+
+	// signing daemon
+	srv := &remotesign.Server{
+		Certificate:  caCert,
+		Signer:       smolcert.Ed25519Key(caKey),
+		Authenticate: remotesign.BearerTokenAuthenticator(map[string]string{token: "frontend-1"}),
+		Logger:       slog.Default(),
+	}
+	http.ListenAndServeTLS(":8443", "daemon.crt", "daemon.key", srv)
+
+	// CA frontend
+	client, err := remotesign.NewClient(ctx, "https://signer:8443", token, nil)
+	ca, err := smolcert.NewCAWithSigner(client.Certificate(), client, store)
+
+The daemon is no signing oracle: it only signs certificates, CRLs, delta CRLs and epoch statements
+issued by its CA certificate, and every signature is recorded in an audit log. Authorize enforces
+further policies, i.e. which profiles a frontend may issue. Payloads are CBOR encoded:
+
+	GET  /certificate  returns the CA certificate
+	POST /sign         signs the message of a SignRequest and returns a SignResponse
+*/
+package remotesign
+
+import (
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Paths of the resources, relative to the mount point of the Server
+const (
+	PathCertificate = "/certificate"
+	PathSign        = "/sign"
+)
+
+// ContentType is the content type of all request and response bodies
+const ContentType = "application/cbor"
+
+// maxMessageSize limits the size of messages to sign
+const maxMessageSize = 256 * 1024
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// SignRequest is sent by a Client to have Message signed
+type SignRequest struct {
+	_ struct{} `cbor:",toarray"`
+
+	Message []byte `cbor:"message"`
+}
+
+// SignResponse contains the signature of the message of a SignRequest
+type SignResponse struct {
+	_ struct{} `cbor:",toarray"`
+
+	Signature []byte `cbor:"signature"`
+}
+
+// Kind is the kind of structure a message to sign encodes
+type Kind uint8
+
+// Kinds of messages the Server signs
+const (
+	KindCertificate Kind = iota + 1
+	KindCRL
+	KindDeltaCRL
+	KindEpochStatement
+)
+
+// String returns a String representation for logging and debugging
+func (k Kind) String() string {
+	switch k {
+	case KindCertificate:
+		return "certificate"
+	case KindCRL:
+		return "CRL"
+	case KindDeltaCRL:
+		return "delta CRL"
+	case KindEpochStatement:
+		return "epoch statement"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,136 @@
+package remotesign
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type auditLogger struct {
+	infos, warnings []string
+}
+
+func (l *auditLogger) Debug(msg string, args ...interface{}) {}
+
+func (l *auditLogger) Info(msg string, args ...interface{}) {
+	l.infos = append(l.infos, msg)
+}
+
+func (l *auditLogger) Warn(msg string, args ...interface{}) {
+	l.warnings = append(l.warnings, msg)
+}
+
+func newTestServer(t *testing.T) (*Server, *auditLogger, *httptest.Server) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	logger := &auditLogger{}
+	srv := &Server{
+		Certificate:  rootCert,
+		Signer:       smolcert.Ed25519Key(rootKey),
+		Authenticate: BearerTokenAuthenticator(map[string]string{"secret": "frontend"}),
+		Logger:       logger,
+	}
+	return srv, logger, httptest.NewServer(srv)
+}
+
+func TestRemoteCA(t *testing.T) {
+	srv, logger, httpSrv := newTestServer(t)
+	defer httpSrv.Close()
+	ctx := context.Background()
+
+	client, err := NewClient(ctx, httpSrv.URL, "secret", nil)
+	require.NoError(t, err)
+	assert.Equal(t, srv.Certificate.Signature, client.Certificate().Signature)
+	ca, err := smolcert.NewCAWithSigner(client.Certificate(), client, smolcert.NewMemoryStore())
+	require.NoError(t, err)
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey)
+	require.NoError(t, err)
+	assert.NoError(t, smolcert.NewCertPool(srv.Certificate).Validate(cert))
+
+	require.NoError(t, ca.Revoke(cert.SerialNumber, smolcert.RevocationReasonKeyCompromise))
+	crl, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	assert.NoError(t, crl.Verify(srv.Certificate))
+	delta, err := ca.GenerateDeltaCRL(crl)
+	require.NoError(t, err)
+	assert.NoError(t, delta.Verify(srv.Certificate))
+	ca.Epoch = 2
+	epoch, err := ca.GenerateEpochStatement()
+	require.NoError(t, err)
+	assert.NoError(t, epoch.Verify(srv.Certificate))
+
+	assert.Equal(t, []string{"Signed certificate", "Signed CRL", "Signed delta CRL", "Signed epoch statement"},
+		logger.infos)
+	assert.Empty(t, logger.warnings)
+}
+
+func TestServerRejects(t *testing.T) {
+	srv, logger, httpSrv := newTestServer(t)
+	defer httpSrv.Close()
+	ctx := context.Background()
+
+	_, err := NewClient(ctx, httpSrv.URL+"/unknown", "secret", nil)
+	assert.Error(t, err)
+
+	wrongToken, err := NewClient(ctx, httpSrv.URL, "wrong", nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = wrongToken.Sign([]byte("message"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+
+	client, err := NewClient(ctx, httpSrv.URL, "secret", nil)
+	require.NoError(t, err)
+	_, err = client.Sign([]byte("arbitrary message"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not a certificate, CRL or epoch statement")
+
+	// Certificates of other issuers are rejected
+	cert := &smolcert.Certificate{SerialNumber: 2, Issuer: "other", Subject: "device", PubKey: pubKey}
+	_, err = smolcert.SignCertificateWithSigner(cert, client)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "issued by 'root'")
+
+	// Signed certificates can't be presented again
+	_, err = client.Sign(mustBytes(t, srv.Certificate))
+	assert.Error(t, err)
+
+	srv.Authorize = func(req *Request) error {
+		if req.Kind == KindCertificate && req.Certificate.Subject != "device" {
+			return errors.New("Subject not allowed")
+		}
+		return nil
+	}
+	ca, err := smolcert.NewCAWithSigner(client.Certificate(), client, smolcert.NewMemoryStore())
+	require.NoError(t, err)
+	_, err = ca.Issue("admin", pubKey)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Subject not allowed")
+	_, err = ca.Issue("device", pubKey)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"Unauthenticated signing request", "Rejected signing request",
+		"Rejected signing request", "Rejected signing request", "Unauthorized signing request"}, logger.warnings)
+	assert.Equal(t, []string{"Signed certificate"}, logger.infos)
+
+	srv.Authenticate = nil
+	_, err = client.Sign([]byte("message"))
+	assert.Error(t, err)
+}
+
+func mustBytes(t *testing.T, cert *smolcert.Certificate) []byte {
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+	return buf
+}
@@ -0,0 +1,201 @@
+package remotesign
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+)
+
+// Request is a verified SignRequest, as passed to Server.Authorize. Depending on Kind it contains
+// the certificate, CRL, delta CRL or epoch statement to sign.
+type Request struct {
+	// Client is the identity of the client returned by the Authenticator
+	Client      string
+	Kind        Kind
+	Certificate *smolcert.Certificate
+	CRL         *smolcert.CRL
+	DeltaCRL    *smolcert.DeltaCRL
+	Epoch       *smolcert.EpochStatement
+}
+
+// Authenticator returns the identity of the client sending r, which is recorded in the audit log
+type Authenticator func(r *http.Request) (string, error)
+
+// BearerTokenAuthenticator authenticates clients sending one of the tokens in an Authorization
+// header. tokens maps the tokens to the identities of the clients.
+func BearerTokenAuthenticator(tokens map[string]string) Authenticator {
+	return func(r *http.Request) (string, error) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return "", errors.New("Missing token")
+		}
+		received := []byte(strings.TrimPrefix(auth, "Bearer "))
+		identity := ""
+		// Compare all tokens, so the response time doesn't depend on the matching token
+		for token, client := range tokens {
+			if subtle.ConstantTimeCompare(received, []byte(token)) == 1 {
+				identity = client
+			}
+		}
+		if identity == "" {
+			return "", errors.New("Invalid token")
+		}
+		return identity, nil
+	}
+}
+
+// Server is a http.Handler signing certificates and CRLs of the CA with Certificate
+type Server struct {
+	// Certificate is the certificate of the CA
+	Certificate *smolcert.Certificate
+	// Signer holds the key of Certificate
+	Signer smolcert.Signer
+	// Authenticate authenticates clients, signing is disabled if nil
+	Authenticate Authenticator
+	// Authorize optionally decides if a request may be signed
+	Authorize func(req *Request) error
+	// Logger receives the audit log of all signing requests
+	Logger smolcert.Logger
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case PathCertificate:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		buf, err := s.Certificate.Bytes()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeCBOR(w, buf)
+	case PathSign:
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		s.sign(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+	if s.Authenticate == nil {
+		http.Error(w, "Signing is disabled", http.StatusForbidden)
+		return
+	}
+	client, err := s.Authenticate(r)
+	if err != nil {
+		s.warn("Unauthenticated signing request", "remote_addr", r.RemoteAddr, "error", err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxMessageSize {
+		http.Error(w, "Signing request is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	signReq := &SignRequest{}
+	if err := cbor.Unmarshal(body, signReq); err != nil {
+		http.Error(w, "Invalid signing request", http.StatusBadRequest)
+		return
+	}
+	req, err := s.parse(client, signReq.Message)
+	if err != nil {
+		s.warn("Rejected signing request", "client", client, "error", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.Authorize != nil {
+		if err := s.Authorize(req); err != nil {
+			s.warn("Unauthorized signing request", append(auditArgs(req), "error", err.Error())...)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	sig, err := s.Signer.Sign(signReq.Message)
+	if err != nil {
+		s.warn("Signing failed", append(auditArgs(req), "error", err.Error())...)
+		http.Error(w, "Signing failed", http.StatusInternalServerError)
+		return
+	}
+	buf, err := cborEm.Marshal(&SignResponse{Signature: sig})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if s.Logger != nil {
+		s.Logger.Info("Signed "+req.Kind.String(), auditArgs(req)...)
+	}
+	writeCBOR(w, buf)
+}
+
+// parse determines what message encodes and checks that it is issued by the CA
+func (s *Server) parse(client string, message []byte) (*Request, error) {
+	req := &Request{Client: client}
+	issuer := ""
+	if cert, err := smolcert.ParseTBSCertificate(message); err == nil {
+		req.Kind, req.Certificate, issuer = KindCertificate, cert, cert.Issuer
+		if cert.SignatureAlgorithm != smolcert.AlgorithmEd25519 {
+			return nil, errors.New("Certificate needs to be signed with Ed25519")
+		}
+	} else if crl, err := smolcert.ParseTBSCRL(message); err == nil {
+		req.Kind, req.CRL, issuer = KindCRL, crl, crl.Issuer
+	} else if delta, err := smolcert.ParseTBSDeltaCRL(message); err == nil {
+		req.Kind, req.DeltaCRL, issuer = KindDeltaCRL, delta, delta.Issuer
+	} else if epoch, err := smolcert.ParseTBSEpochStatement(message); err == nil {
+		req.Kind, req.Epoch, issuer = KindEpochStatement, epoch, epoch.Issuer
+	} else {
+		return nil, errors.New("Message is not a certificate, CRL or epoch statement")
+	}
+	if issuer != s.Certificate.Subject {
+		return nil, errors.New("Only certificates and CRLs issued by '" + s.Certificate.Subject + "' are signed")
+	}
+	return req, nil
+}
+
+func auditArgs(req *Request) []interface{} {
+	args := []interface{}{"client", req.Client, "kind", req.Kind.String()}
+	switch req.Kind {
+	case KindCertificate:
+		args = append(args, "subject", req.Certificate.Subject, "serial_number", req.Certificate.SerialNumber)
+	case KindCRL:
+		args = append(args, "number", req.CRL.Number, "revoked", len(req.CRL.Revoked))
+	case KindDeltaCRL:
+		args = append(args, "number", req.DeltaCRL.Number, "base_number", req.DeltaCRL.BaseNumber)
+	case KindEpochStatement:
+		args = append(args, "epoch", req.Epoch.Epoch)
+	}
+	return args
+}
+
+func (s *Server) warn(msg string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Warn(msg, args...)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func writeCBOR(w http.ResponseWriter, buf []byte) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
@@ -0,0 +1,184 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationProblem is a single problem found during the validation of a certificate chain
+type ValidationProblem struct {
+	// Certificate is the certificate the problem has been found in, might be nil if the problem
+	// isn't specific to a certificate
+	Certificate *Certificate
+	// Err describes the problem, usually it is a *ValidationError
+	Err error
+}
+
+// ValidationReport lists every problem found in a certificate chain. Unlike ValidateBundle the
+// creation of a report doesn't stop at the first problem, which makes it useful to debug why
+// certificates of devices are rejected.
+type ValidationReport struct {
+	// Chain contains the certificates which have been checked, starting with the leaf certificate
+	Chain []*Certificate
+	// Problems contains every problem found in the chain
+	Problems []ValidationProblem
+}
+
+// Valid is true if no problems have been found
+func (r *ValidationReport) Valid() bool {
+	return len(r.Problems) == 0
+}
+
+// Err returns the first problem found or nil if the chain is valid
+func (r *ValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	return r.Problems[0].Err
+}
+
+// String returns a human readable listing of all problems
+func (r *ValidationReport) String() string {
+	if r.Valid() {
+		return "certificate chain is valid"
+	}
+	lines := make([]string, 0, len(r.Problems))
+	for _, p := range r.Problems {
+		if p.Certificate != nil {
+			lines = append(lines, fmt.Sprintf("%s: %s", p.Certificate.Subject, p.Err))
+		} else {
+			lines = append(lines, p.Err.Error())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *ValidationReport) add(cert *Certificate, errs ...error) {
+	for _, err := range errs {
+		r.Problems = append(r.Problems, ValidationProblem{Certificate: cert, Err: err})
+	}
+}
+
+// ValidateReport validates a single certificate against this CertPool like Validate and reports
+// all problems found.
+func (c *CertPool) ValidateReport(cert *Certificate) *ValidationReport {
+	return c.ValidateBundleReport([]*Certificate{cert})
+}
+
+// ValidateBundleReport validates a bundle of certificates like ValidateBundle, but reports all
+// problems found in the chain instead of stopping at the first problem. If multiple issuers are
+// possible for a certificate, the chain continues with the first candidate with a valid signature.
+func (c *CertPool) ValidateBundleReport(certBundle []*Certificate) *ValidationReport {
+	r := &ValidationReport{}
+	leaf, subjectMap := findLeaf(certBundle, NameMatchExact)
+	if leaf == nil {
+		r.add(nil, errors.New("Can't find non-intermediate certificate in certificate chain"))
+		return r
+	}
+
+	visited := make(map[*Certificate]bool)
+	for cert := leaf; cert != nil; {
+		visited[cert] = true
+		r.Chain = append(r.Chain, cert)
+		r.add(cert, certificateProblems(cert, verifyOptions{})...)
+		if cert != leaf {
+			if err := RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+				r.add(cert, newValidationError(ErrInvalidKeyUsage, cert,
+					"Intermediate certificate (subject '%s', does not possess KeyUsage SignCert: %s", cert.Subject, err))
+			}
+		}
+
+		if root, _, trusted := c.issuer(cert, verifyOptions{}); trusted {
+			r.addSignatureProblem(cert, root)
+			if err := checkNameConstraints(cert, root); err != nil {
+				r.add(cert, err)
+			}
+			if err := checkCapabilities(cert, root); err != nil {
+				r.add(cert, err)
+			}
+			if err := checkIssuerPolicy(cert, root); err != nil {
+				r.add(cert, err)
+			}
+			r.Chain = append(r.Chain, root)
+			r.add(root, certificateProblems(root, verifyOptions{})...)
+			r.addSignatureProblem(root, root)
+			if err := RequiresExtension(root, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+				r.add(root, newValidationError(ErrUntrustedRoot, root,
+					"Trusted root certificates need to have the KeyUsage SignCert: %s", err))
+			}
+			return r
+		}
+
+		var next *Certificate
+		candidates := subjectMap[cert.Issuer]
+		for _, candidate := range candidates {
+			if visited[candidate] {
+				continue
+			}
+			if next == nil {
+				next = candidate
+			}
+			if verifyCertificateSignature(cert, candidate) {
+				next = candidate
+				break
+			}
+		}
+		switch {
+		case next == nil && len(candidates) > 0:
+			r.add(cert, newValidationError(ErrUntrustedRoot, cert,
+				"The intermediate chain is self signed and not signed by one of the root certs of this pool"))
+		case next == nil:
+			r.add(cert, newValidationError(ErrUnknownIssuer, cert, "certificate is not signed by a known issuer"))
+		}
+		if next != nil {
+			r.addSignatureProblem(cert, next)
+			if err := checkNameConstraints(cert, next); err != nil {
+				r.add(cert, err)
+			}
+			if err := checkCapabilities(cert, next); err != nil {
+				r.add(cert, err)
+			}
+			if err := checkIssuerPolicy(cert, next); err != nil {
+				r.add(cert, err)
+			}
+		}
+		cert = next
+	}
+	return r
+}
+
+// addSignatureProblem adds the reason why the signatures of issuer on cert are invalid, if they are
+func (r *ValidationReport) addSignatureProblem(cert, issuer *Certificate) {
+	// Unsupported algorithms are already reported by certificateProblems
+	if err := checkSignature(cert, issuer); err != nil && !errors.Is(err, ErrUnsupportedAlgorithm) {
+		r.add(cert, err)
+	}
+}
+
+// verifyCertificateSignature is true if cert carries valid signatures of issuer
+func verifyCertificateSignature(cert, issuer *Certificate) bool {
+	return checkSignature(cert, issuer) == nil
+}
+
+// checkSignature verifies the signatures of issuer on cert like the validation does and returns
+// the reason if they are invalid, i.e. ErrThresholdNotMet or ErrUnsupportedAlgorithm
+func checkSignature(cert, issuer *Certificate) error {
+	if err := checkAlgorithm(cert); err != nil {
+		return err
+	}
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Failed to serialize certificate for validation")
+	}
+	sigs, err := issuerSignatures(cert, issuer)
+	if err != nil {
+		return err
+	}
+	for _, sig := range sigs {
+		if !sig.verify(certBytes) {
+			return newValidationError(ErrBadSignature, cert, "Signature validation failed")
+		}
+	}
+	return nil
+}
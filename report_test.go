@@ -0,0 +1,68 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationReportCollectsAllProblems(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	intermediate, imKey, err := SignedCertificate("intermediate", 2, now.Add(-time.Hour), now.Add(-time.Minute),
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	clientCert, _, err := ClientCertificate("client", 3, time.Time{}, time.Time{},
+		[]Extension{{OID: 0x4242, Critical: true, Value: []byte{0x01}}}, imKey, intermediate.Subject)
+	require.NoError(t, err)
+
+	pool := NewCertPool(rootCert)
+	bundle := []*Certificate{intermediate, clientCert}
+	_, err = pool.ValidateBundle(bundle)
+	require.Error(t, err)
+
+	report := pool.ValidateBundleReport(bundle)
+	assert.False(t, report.Valid())
+	assert.Equal(t, []*Certificate{clientCert, intermediate, rootCert}, report.Chain)
+	require.Len(t, report.Problems, 2)
+	assert.Equal(t, clientCert, report.Problems[0].Certificate)
+	assert.True(t, errors.Is(report.Problems[0].Err, ErrUnknownCriticalExtension))
+	assert.Equal(t, intermediate, report.Problems[1].Certificate)
+	assert.True(t, errors.Is(report.Problems[1].Err, ErrExpired))
+	assert.Error(t, report.Err())
+	assert.Contains(t, report.String(), "intermediate")
+}
+
+func TestValidationReportValidChain(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	clientCert, _, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	report := NewCertPool(rootCert).ValidateReport(clientCert)
+	assert.True(t, report.Valid())
+	assert.NoError(t, report.Err())
+
+	report = NewCertPool().ValidateReport(clientCert)
+	require.Len(t, report.Problems, 1)
+	assert.True(t, errors.Is(report.Err(), ErrUnknownIssuer))
+}
+
+func TestRegisterCriticalExtension(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	clientCert, _, err := ClientCertificate("client", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: 0x4343, Critical: true, Value: []byte{0x01}}}, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	pool := NewCertPool(rootCert)
+	assert.True(t, errors.Is(pool.Validate(clientCert), ErrUnknownCriticalExtension))
+	RegisterCriticalExtension(0x4343)
+	assert.NoError(t, pool.Validate(clientCert))
+}
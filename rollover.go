@@ -0,0 +1,66 @@
+package smolcert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// NextKeyHash returns the hash of a public key as it is used in the NextKey extension
+func NextKeyHash(pubKey ed25519.PublicKey) []byte {
+	h := sha256.Sum256(pubKey)
+	return h[:]
+}
+
+// NextKeyExtension creates an Extension which pre-announces the public key the subject will use
+// after its next key rotation. Only the hash of the key is contained in the extension, so the next
+// key doesn't need to be revealed before it is used.
+func NextKeyExtension(nextKey ed25519.PublicKey) Extension {
+	return Extension{
+		OID:      OIDNextKey,
+		Critical: false,
+		Value:    NextKeyHash(nextKey),
+	}
+}
+
+// ParseNextKey parses the key hash from the Value of a NextKey Extension
+func ParseNextKey(in []byte) ([]byte, error) {
+	if len(in) != sha256.Size {
+		return nil, fmt.Errorf("Unexpected length of input data when parsing NextKey (expected %d bytes, got %d bytes)",
+			sha256.Size, len(in))
+	}
+	return in, nil
+}
+
+// ExpectNextKey ensures that the NextKey extension announces the given key
+func ExpectNextKey(nextKey ed25519.PublicKey) ValidateExtension {
+	return func(critical bool, val []byte) error {
+		keyHash, err := ParseNextKey(val)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(keyHash, NextKeyHash(nextKey)) {
+			return errors.New("The key doesn't match the announced next key")
+		}
+		return nil
+	}
+}
+
+// ValidateKeyRollover checks if newCert is a valid successor of oldCert, i.e. both certificates have
+// the same subject and issuer and the key of newCert has been announced in the NextKey extension
+// of oldCert. This doesn't validate the certificates themselves, newCert still needs to be validated
+// against a CertPool.
+func ValidateKeyRollover(oldCert, newCert *Certificate) error {
+	if oldCert.Subject != newCert.Subject {
+		return fmt.Errorf("Subject of the new certificate '%s' doesn't match the subject '%s' of the old certificate",
+			newCert.Subject, oldCert.Subject)
+	}
+	if oldCert.Issuer != newCert.Issuer {
+		return fmt.Errorf("Issuer of the new certificate '%s' doesn't match the issuer '%s' of the old certificate",
+			newCert.Issuer, oldCert.Issuer)
+	}
+	return RequiresExtension(oldCert, OIDNextKey, ExpectNextKey(newCert.PubKey))
+}
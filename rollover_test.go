@@ -0,0 +1,55 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestKeyRollover(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	nextPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	oldCert, _, err := ClientCertificate("device", 1, now.Add(-time.Hour), now.Add(time.Hour),
+		[]Extension{NextKeyExtension(nextPub)}, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	newCert, err := Renew(oldCert, &Validity{NotBefore: NewTime(now), NotAfter: NewTime(now.Add(time.Hour))}, rootKey)
+	require.NoError(t, err)
+	newCert.PubKey = nextPub
+	newCert.Extensions = []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageClientIdentification.ToBytes()}}
+	newCert, err = SignCertificate(newCert, rootKey)
+	require.NoError(t, err)
+
+	assert.NoError(t, ValidateKeyRollover(oldCert, newCert))
+	assert.NoError(t, NewCertPool(rootCert).Validate(newCert))
+
+	// A certificate without an announced next key can't be rolled over
+	assert.Equal(t, ErrorExtensionNotFound, ValidateKeyRollover(newCert, oldCert))
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherCert := newCert.Copy()
+	otherCert.PubKey = otherPub
+	assert.Error(t, ValidateKeyRollover(oldCert, otherCert))
+
+	otherCert = newCert.Copy()
+	otherCert.Subject = "other device"
+	assert.Error(t, ValidateKeyRollover(oldCert, otherCert))
+}
+
+func TestParseNextKey(t *testing.T) {
+	_, err := ParseNextKey([]byte{0x01})
+	assert.Error(t, err)
+	h, err := ParseNextKey(make([]byte, 32))
+	require.NoError(t, err)
+	assert.Len(t, h, 32)
+}
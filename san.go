@@ -0,0 +1,287 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// SubjectAltNameType is the type of a SubjectAltName
+type SubjectAltNameType uint8
+
+// Defined SubjectAltNameTypes. Names of unknown types are preserved when parsing, so newer types
+// can be added without breaking older verifiers.
+const (
+	// SANDNSName is a DNS host name, i.e. "device.example.com"
+	SANDNSName SubjectAltNameType = iota + 1
+	// SANURI is an absolute URI, i.e. a SPIFFE ID
+	SANURI
+	// SANIPAddress is an IPv4 or IPv6 address in its 4 or 16 byte form
+	SANIPAddress
+)
+
+// String returns a String representation for logging and debugging
+func (t SubjectAltNameType) String() string {
+	switch t {
+	case SANDNSName:
+		return "DNS"
+	case SANURI:
+		return "URI"
+	case SANIPAddress:
+		return "IP"
+	default:
+		return fmt.Sprintf("SubjectAltNameType(%d)", uint8(t))
+	}
+}
+
+// SubjectAltName is an additional name of the subject of a certificate, i.e. a host name or URI.
+// Several names are carried in a SubjectAltNames extension.
+type SubjectAltName struct {
+	_ struct{} `cbor:",toarray"`
+
+	Type  SubjectAltNameType `cbor:"type"`
+	Value []byte             `cbor:"value"`
+}
+
+// DNSName creates a SubjectAltName for a DNS host name
+func DNSName(name string) SubjectAltName {
+	return SubjectAltName{Type: SANDNSName, Value: []byte(name)}
+}
+
+// URIName creates a SubjectAltName for an absolute URI
+func URIName(uri string) SubjectAltName {
+	return SubjectAltName{Type: SANURI, Value: []byte(uri)}
+}
+
+// IPAddress creates a SubjectAltName for an IP address. IPv4 addresses are stored in their 4 byte
+// form, so they match IPv4 addresses in either form.
+func IPAddress(ip net.IP) SubjectAltName {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return SubjectAltName{Type: SANIPAddress, Value: append([]byte{}, ip...)}
+}
+
+// String returns a String representation for logging and debugging
+func (n SubjectAltName) String() string {
+	switch n.Type {
+	case SANDNSName, SANURI:
+		return fmt.Sprintf("%s:%s", n.Type, n.Value)
+	case SANIPAddress:
+		if len(n.Value) == net.IPv4len || len(n.Value) == net.IPv6len {
+			return fmt.Sprintf("%s:%s", n.Type, net.IP(n.Value))
+		}
+		return fmt.Sprintf("%s:%x", n.Type, n.Value)
+	default:
+		return fmt.Sprintf("%s:%x", n.Type, n.Value)
+	}
+}
+
+func (n SubjectAltName) check() error {
+	if len(n.Value) == 0 {
+		return fmt.Errorf("%s names can't be empty", n.Type)
+	}
+	switch n.Type {
+	case SANDNSName:
+		return nil
+	case SANURI:
+		u, err := url.Parse(string(n.Value))
+		if err != nil {
+			return fmt.Errorf("Invalid URI '%s': %w", n.Value, err)
+		}
+		if !u.IsAbs() {
+			return fmt.Errorf("URI '%s' is not absolute", n.Value)
+		}
+		return nil
+	case SANIPAddress:
+		if len(n.Value) != net.IPv4len && len(n.Value) != net.IPv6len {
+			return fmt.Errorf("IP addresses need %d or %d bytes, not %d", net.IPv4len, net.IPv6len, len(n.Value))
+		}
+		return nil
+	default:
+		return fmt.Errorf("Unknown type of subject alternative name %s", n.Type)
+	}
+}
+
+// SubjectAltNamesExtension creates an Extension carrying additional names of the subject. The
+// extension is not critical, verifiers can always fall back to the subject.
+func SubjectAltNamesExtension(names ...SubjectAltName) (Extension, error) {
+	if len(names) == 0 {
+		return Extension{}, errors.New("SubjectAltNames extension needs at least one name")
+	}
+	for _, n := range names {
+		if err := n.check(); err != nil {
+			return Extension{}, err
+		}
+	}
+	val, err := cborEm.Marshal(names)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDSubjectAltNames,
+		Critical: false,
+		Value:    val,
+	}, nil
+}
+
+// ParseSubjectAltNames parses the names from a byte slice, i.e. the Value of an Extension
+func ParseSubjectAltNames(in []byte) ([]SubjectAltName, error) {
+	var names []SubjectAltName
+	if err := cbor.Unmarshal(in, &names); err != nil {
+		return nil, fmt.Errorf("Failed to parse SubjectAltNames: %w", err)
+	}
+	return names, nil
+}
+
+// SubjectAltNames returns the additional names of the subject. If the certificate has no
+// SubjectAltNames extension nil is returned.
+func (c *Certificate) SubjectAltNames() ([]SubjectAltName, error) {
+	var names []SubjectAltName
+	err := RequiresExtension(c, OIDSubjectAltNames, func(critical bool, val []byte) (err error) {
+		names, err = ParseSubjectAltNames(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return names, err
+}
+
+// URIs returns all URIs of the SubjectAltNames of the certificate
+func (c *Certificate) URIs() ([]*url.URL, error) {
+	names, err := c.SubjectAltNames()
+	if err != nil {
+		return nil, err
+	}
+	var uris []*url.URL
+	for _, n := range names {
+		if n.Type != SANURI {
+			continue
+		}
+		u, err := url.Parse(string(n.Value))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid URI '%s': %w", n.Value, err)
+		}
+		uris = append(uris, u)
+	}
+	return uris, nil
+}
+
+// IPAddresses returns all IP addresses of the SubjectAltNames of the certificate. Entries with an
+// invalid length are skipped.
+func (c *Certificate) IPAddresses() ([]net.IP, error) {
+	names, err := c.SubjectAltNames()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, n := range names {
+		if n.Type == SANIPAddress && n.check() == nil {
+			ips = append(ips, net.IP(n.Value))
+		}
+	}
+	return ips, nil
+}
+
+// VerifyIP checks that the certificate is valid for the IP address ip, by one of the IP addresses
+// of its SubjectAltNames. The subject is not considered. The certificate itself needs to be
+// validated before. If it doesn't match, an error wrapping ErrNameMismatch is returned.
+func (c *Certificate) VerifyIP(ip net.IP) error {
+	ips, err := c.IPAddresses()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, c, "Invalid SubjectAltNames: %s", err)
+	}
+	for _, candidate := range ips {
+		if candidate.Equal(ip) {
+			return nil
+		}
+	}
+	return newValidationError(ErrNameMismatch, c, "Certificate is not valid for the IP address %s", ip)
+}
+
+// URIMatch selects how VerifyURI matches URIs against the URIs of a certificate
+type URIMatch uint8
+
+const (
+	// URIMatchExact requires a URI of the certificate to be equal to the URI byte by byte, i.e.
+	// for workload identities
+	URIMatchExact URIMatch = iota
+	// URIMatchPrefix treats the URIs of the certificate as scopes, i.e. for certificates granting
+	// access to resources. A URI matches a scope with the same scheme and host if its path
+	// equals the path of the scope or lies below it. Paths are only split at '/', so the scope
+	// "https://example.com/devices" matches "https://example.com/devices/1", but not
+	// "https://example.com/devices-1". Scopes with a query or fragment only match exactly.
+	URIMatchPrefix
+)
+
+// VerifyURI checks that the certificate is valid for uri, by one of the URIs of its
+// SubjectAltNames under the URIMatch mode. The certificate itself needs to be validated before.
+// If it doesn't match, an error wrapping ErrNameMismatch is returned.
+func (c *Certificate) VerifyURI(uri string, mode URIMatch) error {
+	names, err := c.SubjectAltNames()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, c, "Invalid SubjectAltNames: %s", err)
+	}
+	var target *url.URL
+	if mode == URIMatchPrefix {
+		if target, err = parseScopedURI(uri); err != nil {
+			return newValidationError(ErrNameMismatch, c, "Certificate is not valid for '%s': %s", uri, err)
+		}
+	}
+	for _, n := range names {
+		if n.Type != SANURI {
+			continue
+		}
+		if string(n.Value) == uri {
+			return nil
+		}
+		if mode == URIMatchPrefix && withinScope(target, string(n.Value)) {
+			return nil
+		}
+	}
+	return newValidationError(ErrNameMismatch, c, "Certificate is not valid for the URI '%s'", uri)
+}
+
+// parseScopedURI parses an absolute URI which is matched against scopes
+func parseScopedURI(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Opaque != "" {
+		return nil, errors.New("URI is not absolute and hierarchical")
+	}
+	if u.User != nil {
+		return nil, errors.New("URI contains user information")
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "." || segment == ".." {
+			return nil, errors.New("URI contains relative path segments")
+		}
+	}
+	return u, nil
+}
+
+// withinScope is true if target is equal to or lies below the URI scope
+func withinScope(target *url.URL, scope string) bool {
+	s, err := parseScopedURI(scope)
+	if err != nil || s.RawQuery != "" || s.ForceQuery || s.Fragment != "" {
+		return false
+	}
+	if !strings.EqualFold(s.Scheme, target.Scheme) || !strings.EqualFold(s.Host, target.Host) {
+		return false
+	}
+	scopePath, targetPath := s.EscapedPath(), target.EscapedPath()
+	if scopePath == "" || scopePath == "/" || scopePath == targetPath {
+		return true
+	}
+	if !strings.HasSuffix(scopePath, "/") {
+		scopePath += "/"
+	}
+	return strings.HasPrefix(targetPath, scopePath)
+}
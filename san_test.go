@@ -0,0 +1,164 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSubjectAltNames(t *testing.T) {
+	names := []SubjectAltName{
+		DNSName("device.example.com"),
+		URIName("spiffe://example.com/device"),
+		{Type: 200, Value: []byte{1, 2}},
+	}
+	_, err := SubjectAltNamesExtension(names...)
+	assert.Error(t, err, "unknown types can't be issued")
+	ext, err := SubjectAltNamesExtension(names[:2]...)
+	require.NoError(t, err)
+	assert.False(t, ext.Critical)
+
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := Issue("device", pubKey, "root", rootKey, WithExtensions(ext))
+	require.NoError(t, err)
+	parsed, err := cert.SubjectAltNames()
+	require.NoError(t, err)
+	assert.Equal(t, names[:2], parsed)
+	uris, err := cert.URIs()
+	require.NoError(t, err)
+	require.Len(t, uris, 1)
+	assert.Equal(t, "example.com", uris[0].Host)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, cert.Dump(out))
+	assert.Contains(t, out.String(), "URI:spiffe://example.com/device")
+
+	// Unknown types are preserved
+	val, err := cborEm.Marshal(names)
+	require.NoError(t, err)
+	parsed, err = ParseSubjectAltNames(val)
+	require.NoError(t, err)
+	assert.Equal(t, names, parsed)
+
+	withoutSAN, err := Issue("device", pubKey, "root", rootKey)
+	require.NoError(t, err)
+	parsed, err = withoutSAN.SubjectAltNames()
+	require.NoError(t, err)
+	assert.Nil(t, parsed)
+
+	for _, invalid := range [][]SubjectAltName{
+		nil,
+		{DNSName("")},
+		{URIName("relative/path")},
+		{URIName("http://[::1")},
+	} {
+		_, err := SubjectAltNamesExtension(invalid...)
+		assert.Error(t, err, "%v", invalid)
+	}
+	_, err = ParseSubjectAltNames([]byte{0xff})
+	assert.Error(t, err)
+}
+
+func TestVerifyIP(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	ext, err := SubjectAltNamesExtension(
+		IPAddress(net.ParseIP("192.168.1.10")),
+		IPAddress(net.ParseIP("fd00::1")),
+		DNSName("10.0.0.1"),
+	)
+	require.NoError(t, err)
+	cert, err := Issue("10.0.0.2", pubKey, "root", rootKey, WithExtensions(ext))
+	require.NoError(t, err)
+
+	names, err := cert.SubjectAltNames()
+	require.NoError(t, err)
+	assert.Len(t, names[0].Value, net.IPv4len)
+	assert.Equal(t, "IP:192.168.1.10", names[0].String())
+	assert.Equal(t, "IP:fd00::1", names[1].String())
+	ips, err := cert.IPAddresses()
+	require.NoError(t, err)
+	assert.Len(t, ips, 2)
+
+	assert.NoError(t, cert.VerifyIP(net.ParseIP("192.168.1.10")))
+	assert.NoError(t, cert.VerifyIP(net.IPv4(192, 168, 1, 10).To4()))
+	assert.NoError(t, cert.VerifyIP(net.ParseIP("fd00:0::1")))
+	for _, ip := range []string{"192.168.1.11", "fd00::2", "10.0.0.1", "10.0.0.2", "::ffff:192.168.1.11"} {
+		err := cert.VerifyIP(net.ParseIP(ip))
+		assert.True(t, errors.Is(err, ErrNameMismatch), ip)
+		assert.Equal(t, "name_mismatch", FailureReason(err))
+	}
+	assert.True(t, errors.Is(cert.VerifyIP(nil), ErrNameMismatch))
+
+	withoutSAN, err := Issue("192.168.1.10", pubKey, "root", rootKey)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(withoutSAN.VerifyIP(net.ParseIP("192.168.1.10")), ErrNameMismatch))
+
+	_, err = SubjectAltNamesExtension(SubjectAltName{Type: SANIPAddress, Value: []byte{1, 2, 3}})
+	assert.Error(t, err)
+	assert.Equal(t, "IP:010203", SubjectAltName{Type: SANIPAddress, Value: []byte{1, 2, 3}}.String())
+}
+
+func TestVerifyURI(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	ext, err := SubjectAltNamesExtension(
+		URIName("spiffe://example.com/device/1"),
+		URIName("https://api.example.com/devices/1"),
+		URIName("https://files.example.com/"),
+		URIName("https://search.example.com/q?user=1"),
+		DNSName("https://dns.example.com/"),
+	)
+	require.NoError(t, err)
+	cert, err := Issue("device", pubKey, "root", rootKey, WithExtensions(ext))
+	require.NoError(t, err)
+
+	exact := []string{"spiffe://example.com/device/1", "https://api.example.com/devices/1", "https://search.example.com/q?user=1"}
+	for _, uri := range exact {
+		assert.NoError(t, cert.VerifyURI(uri, URIMatchExact), uri)
+		assert.NoError(t, cert.VerifyURI(uri, URIMatchPrefix), uri)
+	}
+	for _, uri := range []string{
+		"https://api.example.com/devices/1/config",
+		"https://API.example.com/devices/1/",
+		"https://files.example.com/a/b",
+		"spiffe://example.com/device/1/sensor",
+	} {
+		assert.True(t, errors.Is(cert.VerifyURI(uri, URIMatchExact), ErrNameMismatch), uri)
+		assert.NoError(t, cert.VerifyURI(uri, URIMatchPrefix), uri)
+	}
+	for _, uri := range []string{
+		"https://api.example.com/devices/10",
+		"https://api.example.com/devices",
+		"https://api.example.com/devices/1/../2",
+		"https://api.example.com/devices/1/%2e%2e/2",
+		"http://api.example.com/devices/1/config",
+		"https://api.example.com:8443/devices/1/config",
+		"https://user@api.example.com/devices/1/config",
+		"https://search.example.com/q?user=2",
+		"https://search.example.com/q/x",
+		"https://dns.example.com/",
+		"/devices/1/config",
+		"http://[::1",
+	} {
+		err := cert.VerifyURI(uri, URIMatchPrefix)
+		assert.True(t, errors.Is(err, ErrNameMismatch), uri)
+	}
+
+	withoutSAN, err := Issue("spiffe://example.com/device/1", pubKey, "root", rootKey)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(withoutSAN.VerifyURI("spiffe://example.com/device/1", URIMatchExact), ErrNameMismatch))
+}
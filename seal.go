@@ -0,0 +1,100 @@
+package smolcert
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"io"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// sealContext is used as HKDF info when deriving the key of a sealed box
+var sealContext = []byte("smolcert sealed box")
+
+// Encrypt encrypts data to the holder of recipient, so that only the owner of the private key of
+// recipient can decrypt it. The ed25519 key of the certificate is converted to X25519, an ephemeral
+// X25519 key is used for the key agreement and data is encrypted with ChaCha20-Poly1305. The
+// result is the ephemeral public key followed by the ciphertext. The sender is not authenticated.
+func Encrypt(data []byte, recipient *Certificate) ([]byte, error) {
+	recipientKey, err := X25519PublicKey(recipient.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	ephemeralKey := make([]byte, curve25519.ScalarSize)
+	if err := readRandom(nil, ephemeralKey); err != nil {
+		return nil, err
+	}
+	ephemeralPub, err := curve25519.X25519(ephemeralKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(ephemeralKey, recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := sealedBoxAEAD(shared, ephemeralPub, recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	// Every key is only used once, so a zero nonce is sufficient
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(ephemeralPub, nonce, data, nil), nil
+}
+
+// Decrypt decrypts data which has been encrypted via Encrypt to the certificate of priv
+func Decrypt(data []byte, priv ed25519.PrivateKey) ([]byte, error) {
+	if len(data) < curve25519.PointSize {
+		return nil, errors.New("Encrypted data is too short")
+	}
+	ephemeralPub := data[:curve25519.PointSize]
+	recipientKey, err := X25519PublicKey(priv.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(X25519PrivateKey(priv), ephemeralPub)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := sealedBoxAEAD(shared, ephemeralPub, recipientKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	plaintext, err := aead.Open(nil, nonce, data[curve25519.PointSize:], nil)
+	if err != nil {
+		return nil, errors.New("Failed to decrypt data")
+	}
+	return plaintext, nil
+}
+
+// sealedBoxAEAD derives the AEAD of a sealed box from the shared secret. Both public keys are
+// bound into the key derivation.
+func sealedBoxAEAD(shared, ephemeralPub, recipientPub []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeralPub...), recipientPub...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, sealContext), key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.New(key)
+}
+
+// X25519PrivateKey converts an ed25519 private key into the corresponding X25519 private key (RFC 8032, 5.1.5)
+func X25519PrivateKey(priv ed25519.PrivateKey) []byte {
+	h := sha512.Sum512(priv.Seed())
+	return h[:curve25519.ScalarSize]
+}
+
+// X25519PublicKey converts an ed25519 public key into the corresponding X25519 public key
+func X25519PublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	p, err := new(edwards25519.Point).SetBytes(pub)
+	if err != nil {
+		return nil, errors.New("Invalid ed25519 public key")
+	}
+	return p.BytesMontgomery(), nil
+}
@@ -0,0 +1,52 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+)
+
+func TestSealedBox(t *testing.T) {
+	cert, priv, err := SelfSignedCertificate("device", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, otherPriv, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	secret := []byte("provisioning token")
+	box, err := Encrypt(secret, cert)
+	require.NoError(t, err)
+	assert.NotContains(t, string(box), string(secret))
+
+	plaintext, err := Decrypt(box, priv)
+	require.NoError(t, err)
+	assert.Equal(t, secret, plaintext)
+
+	_, err = Decrypt(box, otherPriv)
+	assert.Error(t, err)
+	box[len(box)-1] ^= 0xff
+	_, err = Decrypt(box, priv)
+	assert.Error(t, err)
+	_, err = Decrypt(box[:20], priv)
+	assert.Error(t, err)
+
+	// Every encryption uses a new ephemeral key
+	box2, err := Encrypt(secret, cert)
+	require.NoError(t, err)
+	assert.NotEqual(t, box[:curve25519.PointSize], box2[:curve25519.PointSize])
+}
+
+func TestX25519Conversion(t *testing.T) {
+	cert, priv, err := SelfSignedCertificate("device", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pub, err := X25519PublicKey(cert.PubKey)
+	require.NoError(t, err)
+	derived, err := curve25519.X25519(X25519PrivateKey(priv), curve25519.Basepoint)
+	require.NoError(t, err)
+	assert.Equal(t, pub, derived)
+
+	_, err = X25519PublicKey(make([]byte, 5))
+	assert.Error(t, err)
+}
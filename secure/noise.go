@@ -0,0 +1,136 @@
+package secure
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// protocolName is the name of the Noise protocol implemented by this package. It is exactly
+// 32 bytes long and therefore used as initial handshake hash without hashing.
+const protocolName = "Noise_XX_25519_ChaChaPoly_SHA256"
+
+// prologue binds the handshake to this package
+var prologue = []byte("smolcert secure channel")
+
+// tagSize is the size of the authentication tag of ChaCha20-Poly1305
+const tagSize = 16
+
+// maxNonce is reserved by the Noise specification and must not be used
+const maxNonce = ^uint64(0)
+
+var errNonceExhausted = errors.New("Nonces of the secure channel are exhausted")
+
+// cipherState is the CipherState of the Noise specification
+type cipherState struct {
+	aead cipher.AEAD
+	n    uint64
+}
+
+func newCipherState(k []byte) *cipherState {
+	aead, err := chacha20poly1305.New(k)
+	if err != nil {
+		// Can't happen, the key always has the correct size
+		panic(err)
+	}
+	return &cipherState{aead: aead}
+}
+
+func (c *cipherState) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], c.n)
+	return nonce
+}
+
+func (c *cipherState) encrypt(ad, plaintext []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	if c.n == maxNonce {
+		return nil, errNonceExhausted
+	}
+	ciphertext := c.aead.Seal(nil, c.nonce(), plaintext, ad)
+	c.n++
+	return ciphertext, nil
+}
+
+func (c *cipherState) decrypt(ad, ciphertext []byte) ([]byte, error) {
+	if c == nil {
+		return ciphertext, nil
+	}
+	if c.n == maxNonce {
+		return nil, errNonceExhausted
+	}
+	plaintext, err := c.aead.Open(nil, c.nonce(), ciphertext, ad)
+	if err != nil {
+		return nil, errors.New("Failed to decrypt message of the secure channel")
+	}
+	c.n++
+	return plaintext, nil
+}
+
+// symmetricState is the SymmetricState of the Noise specification
+type symmetricState struct {
+	cs *cipherState
+	ck []byte
+	h  []byte
+}
+
+func newSymmetricState() *symmetricState {
+	s := &symmetricState{h: []byte(protocolName)}
+	s.ck = append([]byte{}, s.h...)
+	s.mixHash(prologue)
+	return s
+}
+
+func (s *symmetricState) mixHash(data []byte) {
+	h := sha256.New()
+	h.Write(s.h)
+	h.Write(data)
+	s.h = h.Sum(nil)
+}
+
+func (s *symmetricState) mixKey(ikm []byte) {
+	ck, k := noiseHKDF(s.ck, ikm)
+	s.ck = ck
+	s.cs = newCipherState(k)
+}
+
+func (s *symmetricState) encryptAndHash(plaintext []byte) ([]byte, error) {
+	ciphertext, err := s.cs.encrypt(s.h, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	s.mixHash(ciphertext)
+	return ciphertext, nil
+}
+
+func (s *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext, err := s.cs.decrypt(s.h, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	s.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+// split returns the cipher states for messages sent by the initiator and the responder
+func (s *symmetricState) split() (*cipherState, *cipherState) {
+	k1, k2 := noiseHKDF(s.ck, nil)
+	return newCipherState(k1), newCipherState(k2)
+}
+
+// noiseHKDF derives two outputs from ck and ikm. The HKDF function of the Noise specification is
+// HKDF as specified in RFC 5869 with ck as salt and empty info.
+func noiseHKDF(ck, ikm []byte) ([]byte, []byte) {
+	out := make([]byte, sha256.Size*2)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, ck, nil), out); err != nil {
+		panic(err)
+	}
+	return out[:sha256.Size], out[sha256.Size:]
+}
@@ -0,0 +1,388 @@
+/*
+Package secure provides mutually authenticated, encrypted channels over any net.Conn.
+
+The channel is established via the Noise protocol Noise_XX_25519_ChaChaPoly_SHA256. The static
+keys of the handshake are the X25519 keys corresponding to the ed25519 keys of the certificates of
+both peers. The certificate bundles are exchanged encrypted during the handshake and validated
+against a CertPool, the initiator only reveals its certificate after it validated the certificate
+of the responder.
+
+	conn, err := secure.Dial("tcp", "device.local:4242", &secure.Config{
+		Bundle:     []*smolcert.Certificate{cert},
+		PrivateKey: key,
+		Pool:       pool,
+	})
+	peer := conn.PeerCertificate()
+*/
+package secure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/ed25519"
+)
+
+// maxMessageSize is the maximum size of a Noise message
+const maxMessageSize = 65535
+
+// maxPayloadSize is the maximum amount of data sent in a single transport message
+const maxPayloadSize = maxMessageSize - tagSize
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// Config configures one side of a secure channel
+type Config struct {
+	// Bundle contains the certificate of this peer first, followed by intermediate certificates if
+	// necessary
+	Bundle []*smolcert.Certificate
+	// PrivateKey is the private key belonging to the first certificate of Bundle
+	PrivateKey ed25519.PrivateKey
+	// Pool is used to validate the certificate of the remote peer
+	Pool *smolcert.CertPool
+	// VerifyPeer is called with the validated certificate of the remote peer if set. Returning an
+	// error aborts the handshake, i.e. if the peer is not authorized to connect.
+	VerifyPeer func(cert *smolcert.Certificate) error
+}
+
+// Conn is a secure channel over a net.Conn. Reads and writes of a Conn may happen concurrently.
+type Conn struct {
+	net.Conn
+	peer *smolcert.Certificate
+
+	readLock sync.Mutex
+	recv     *cipherState
+	buf      []byte
+
+	writeLock sync.Mutex
+	send      *cipherState
+}
+
+// Client performs the handshake as initiator over conn and returns the established channel. The
+// caller is responsible for setting deadlines on conn to limit the duration of the handshake.
+func Client(conn net.Conn, config *Config) (*Conn, error) {
+	h, err := newHandshake(conn, config)
+	if err != nil {
+		return nil, err
+	}
+	return h.initiate()
+}
+
+// Server performs the handshake as responder over conn and returns the established channel
+func Server(conn net.Conn, config *Config) (*Conn, error) {
+	h, err := newHandshake(conn, config)
+	if err != nil {
+		return nil, err
+	}
+	return h.respond()
+}
+
+// Dial connects to address and performs the handshake as initiator
+func Dial(network, address string, config *Config) (*Conn, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Client(conn, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// PeerCertificate returns the validated certificate of the remote peer
+func (c *Conn) PeerCertificate() *smolcert.Certificate {
+	return c.peer
+}
+
+// Read reads decrypted data from the channel
+func (c *Conn) Read(b []byte) (int, error) {
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+	for len(c.buf) == 0 {
+		msg, err := readMessage(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		if c.buf, err = c.recv.decrypt(nil, msg); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// Write encrypts and writes data to the channel
+func (c *Conn) Write(b []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > maxPayloadSize {
+			chunk = chunk[:maxPayloadSize]
+		}
+		msg, err := c.send.encrypt(nil, chunk)
+		if err != nil {
+			return written, err
+		}
+		if err := writeMessage(c.Conn, msg); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+	return written, nil
+}
+
+type handshake struct {
+	conn      net.Conn
+	config    *Config
+	ss        *symmetricState
+	static    []byte
+	staticPub []byte
+	eph       []byte
+	ephPub    []byte
+	payload   []byte
+}
+
+func newHandshake(conn net.Conn, config *Config) (*handshake, error) {
+	if len(config.Bundle) == 0 {
+		return nil, errors.New("Certificate bundle is empty")
+	}
+	if !bytes.Equal(config.PrivateKey.Public().(ed25519.PublicKey), config.Bundle[0].PubKey) {
+		return nil, errors.New("Private key doesn't belong to the first certificate of the bundle")
+	}
+	if config.Pool == nil {
+		return nil, errors.New("No CertPool configured to validate the peer")
+	}
+	payload, err := cborEm.Marshal(config.Bundle)
+	if err != nil {
+		return nil, err
+	}
+	staticPub, err := smolcert.X25519PublicKey(config.Bundle[0].PubKey)
+	if err != nil {
+		return nil, err
+	}
+	eph := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(eph); err != nil {
+		return nil, err
+	}
+	ephPub, err := curve25519.X25519(eph, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &handshake{
+		conn:      conn,
+		config:    config,
+		ss:        newSymmetricState(),
+		static:    smolcert.X25519PrivateKey(config.PrivateKey),
+		staticPub: staticPub,
+		eph:       eph,
+		ephPub:    ephPub,
+		payload:   payload,
+	}, nil
+}
+
+func (h *handshake) mixDH(priv, pub []byte) error {
+	shared, err := curve25519.X25519(priv, pub)
+	if err != nil {
+		return errors.New("Invalid key of the peer")
+	}
+	h.ss.mixKey(shared)
+	return nil
+}
+
+// initiate performs the handshake pattern XX as initiator:
+//
+//	-> e
+//	<- e, ee, s, es
+//	-> s, se
+func (h *handshake) initiate() (*Conn, error) {
+	h.ss.mixHash(h.ephPub)
+	empty, err := h.ss.encryptAndHash(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeMessage(h.conn, append(append([]byte{}, h.ephPub...), empty...)); err != nil {
+		return nil, err
+	}
+
+	msg, err := readMessage(h.conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) < curve25519.PointSize*2+tagSize*2 {
+		return nil, errors.New("Invalid handshake message")
+	}
+	remoteEph := msg[:curve25519.PointSize]
+	h.ss.mixHash(remoteEph)
+	if err := h.mixDH(h.eph, remoteEph); err != nil {
+		return nil, err
+	}
+	remoteStatic, err := h.ss.decryptAndHash(msg[curve25519.PointSize : curve25519.PointSize*2+tagSize])
+	if err != nil {
+		return nil, err
+	}
+	if err := h.mixDH(h.eph, remoteStatic); err != nil {
+		return nil, err
+	}
+	remotePayload, err := h.ss.decryptAndHash(msg[curve25519.PointSize*2+tagSize:])
+	if err != nil {
+		return nil, err
+	}
+	peer, err := h.verifyPeer(remoteStatic, remotePayload)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedStatic, err := h.ss.encryptAndHash(h.staticPub)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.mixDH(h.static, remoteEph); err != nil {
+		return nil, err
+	}
+	encryptedPayload, err := h.ss.encryptAndHash(h.payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeMessage(h.conn, append(encryptedStatic, encryptedPayload...)); err != nil {
+		return nil, err
+	}
+
+	send, recv := h.ss.split()
+	return &Conn{Conn: h.conn, peer: peer, send: send, recv: recv}, nil
+}
+
+// respond performs the handshake pattern XX as responder
+func (h *handshake) respond() (*Conn, error) {
+	msg, err := readMessage(h.conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) < curve25519.PointSize {
+		return nil, errors.New("Invalid handshake message")
+	}
+	remoteEph := msg[:curve25519.PointSize]
+	h.ss.mixHash(remoteEph)
+	if _, err := h.ss.decryptAndHash(msg[curve25519.PointSize:]); err != nil {
+		return nil, err
+	}
+
+	h.ss.mixHash(h.ephPub)
+	if err := h.mixDH(h.eph, remoteEph); err != nil {
+		return nil, err
+	}
+	encryptedStatic, err := h.ss.encryptAndHash(h.staticPub)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.mixDH(h.static, remoteEph); err != nil {
+		return nil, err
+	}
+	encryptedPayload, err := h.ss.encryptAndHash(h.payload)
+	if err != nil {
+		return nil, err
+	}
+	reply := append(append([]byte{}, h.ephPub...), encryptedStatic...)
+	if err := writeMessage(h.conn, append(reply, encryptedPayload...)); err != nil {
+		return nil, err
+	}
+
+	msg, err = readMessage(h.conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) < curve25519.PointSize+tagSize*2 {
+		return nil, errors.New("Invalid handshake message")
+	}
+	remoteStatic, err := h.ss.decryptAndHash(msg[:curve25519.PointSize+tagSize])
+	if err != nil {
+		return nil, err
+	}
+	if err := h.mixDH(h.eph, remoteStatic); err != nil {
+		return nil, err
+	}
+	remotePayload, err := h.ss.decryptAndHash(msg[curve25519.PointSize+tagSize:])
+	if err != nil {
+		return nil, err
+	}
+	peer, err := h.verifyPeer(remoteStatic, remotePayload)
+	if err != nil {
+		return nil, err
+	}
+
+	recv, send := h.ss.split()
+	return &Conn{Conn: h.conn, peer: peer, send: send, recv: recv}, nil
+}
+
+// verifyPeer validates the certificate bundle of the peer and ensures that the static key used
+// in the handshake belongs to its certificate
+func (h *handshake) verifyPeer(remoteStatic, payload []byte) (*smolcert.Certificate, error) {
+	var bundle []*smolcert.Certificate
+	if err := cbor.Unmarshal(payload, &bundle); err != nil {
+		return nil, fmt.Errorf("Invalid certificate bundle of the peer: %w", err)
+	}
+	if len(bundle) == 0 {
+		return nil, errors.New("Peer didn't present a certificate")
+	}
+	peer, err := smolcert.NewBundle(bundle...).Validate(h.config.Pool)
+	if err != nil {
+		return nil, err
+	}
+	peerStatic, err := smolcert.X25519PublicKey(peer.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(peerStatic, remoteStatic) {
+		return nil, errors.New("Peer doesn't possess the key of its certificate")
+	}
+	if h.config.VerifyPeer != nil {
+		if err := h.config.VerifyPeer(peer); err != nil {
+			return nil, err
+		}
+	}
+	return peer, nil
+}
+
+func readMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeMessage(w io.Writer, msg []byte) error {
+	if len(msg) > maxMessageSize {
+		return errors.New("Message exceeds the maximum size of the secure channel")
+	}
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
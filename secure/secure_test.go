@@ -0,0 +1,156 @@
+package secure
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type testPKI struct {
+	pool    *smolcert.CertPool
+	root    string
+	rootKey ed25519.PrivateKey
+}
+
+func newTestPKI(t *testing.T, root string) *testPKI {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate(root, time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	return &testPKI{pool: smolcert.NewCertPool(rootCert), root: root, rootKey: rootKey}
+}
+
+func (p *testPKI) config(t *testing.T, subject string, serial uint64) *Config {
+	cert, key, err := smolcert.ClientCertificate(subject, serial, time.Time{}, time.Time{}, nil, p.rootKey, p.root)
+	require.NoError(t, err)
+	return &Config{Bundle: []*smolcert.Certificate{cert}, PrivateKey: key, Pool: p.pool}
+}
+
+func connect(clientConfig, serverConfig *Config) (client, server *Conn, clientErr, serverErr error) {
+	clientConn, serverConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server, serverErr = Server(serverConn, serverConfig)
+		if serverErr != nil {
+			serverConn.Close()
+		}
+	}()
+	client, clientErr = Client(clientConn, clientConfig)
+	if clientErr != nil {
+		clientConn.Close()
+	}
+	<-done
+	return
+}
+
+func TestSecureChannel(t *testing.T) {
+	pki := newTestPKI(t, "root")
+	client, server, clientErr, serverErr := connect(pki.config(t, "client", 2), pki.config(t, "server", 3))
+	require.NoError(t, clientErr)
+	require.NoError(t, serverErr)
+	defer client.Close()
+	defer server.Close()
+	assert.Equal(t, "server", client.PeerCertificate().Subject)
+	assert.Equal(t, "client", server.PeerCertificate().Subject)
+
+	// Messages larger than the maximum Noise message size are split
+	data := make([]byte, maxMessageSize*2+42)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	go func() {
+		client.Write(data)
+		client.Write([]byte("pong"))
+	}()
+	received := make([]byte, len(data))
+	_, err = io.ReadFull(server, received)
+	require.NoError(t, err)
+	assert.Equal(t, data, received)
+	pong := make([]byte, 4)
+	_, err = io.ReadFull(server, pong)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(pong))
+
+	go server.Write([]byte("ping"))
+	ping := make([]byte, 4)
+	_, err = io.ReadFull(client, ping)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(ping))
+}
+
+func TestUntrustedPeer(t *testing.T) {
+	pki := newTestPKI(t, "root")
+	otherPKI := newTestPKI(t, "other root")
+
+	// The client doesn't trust the server and never reveals its certificate
+	_, _, clientErr, serverErr := connect(otherPKI.config(t, "client", 2), pki.config(t, "server", 3))
+	assert.True(t, errors.Is(clientErr, smolcert.ErrUnknownIssuer))
+	assert.Error(t, serverErr)
+
+	clientConfig := otherPKI.config(t, "client", 2)
+	clientConfig.Pool = pki.pool
+	client, _, clientErr, serverErr := connect(clientConfig, pki.config(t, "server", 3))
+	assert.True(t, errors.Is(serverErr, smolcert.ErrUnknownIssuer))
+	// The initiator finishes the handshake before the responder verifies its certificate, the
+	// rejection is noticed when reading from the channel
+	require.NoError(t, clientErr)
+	_, err := client.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	serverConfig := pki.config(t, "server", 3)
+	serverConfig.VerifyPeer = func(cert *smolcert.Certificate) error {
+		return errors.New("Unknown client")
+	}
+	client, _, clientErr, serverErr = connect(pki.config(t, "client", 2), serverConfig)
+	assert.Error(t, serverErr)
+	require.NoError(t, clientErr)
+	_, err = client.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestStolenCertificate(t *testing.T) {
+	pki := newTestPKI(t, "root")
+	victim := pki.config(t, "server", 3)
+	attacker := pki.config(t, "attacker", 4)
+
+	clientConn, serverConn := net.Pipe()
+	done := make(chan error)
+	go func() {
+		h, err := newHandshake(serverConn, attacker)
+		if err == nil {
+			// Present the certificate of the victim with the key of the attacker
+			h.payload, err = cborEm.Marshal(victim.Bundle)
+		}
+		if err == nil {
+			_, err = h.respond()
+		}
+		serverConn.Close()
+		done <- err
+	}()
+	_, err := Client(clientConn, pki.config(t, "client", 2))
+	assert.Error(t, err)
+	clientConn.Close()
+	<-done
+}
+
+func TestInvalidConfig(t *testing.T) {
+	pki := newTestPKI(t, "root")
+	config := pki.config(t, "client", 2)
+	other := pki.config(t, "other", 3)
+	conn := &bytes.Buffer{}
+
+	_, err := newHandshake(nil, &Config{PrivateKey: config.PrivateKey, Pool: pki.pool})
+	assert.Error(t, err)
+	_, err = newHandshake(nil, &Config{Bundle: config.Bundle, PrivateKey: other.PrivateKey, Pool: pki.pool})
+	assert.Error(t, err)
+	_, err = newHandshake(nil, &Config{Bundle: config.Bundle, PrivateKey: config.PrivateKey})
+	assert.Error(t, err)
+	assert.Error(t, writeMessage(conn, make([]byte, maxMessageSize+1)))
+}
@@ -0,0 +1,129 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Signer creates ed25519 signatures with a private key which doesn't need to be held in memory,
+// i.e. because it is kept in a key management service. A CA created with NewCAWithSigner signs
+// certificates and CRLs with a Signer.
+type Signer interface {
+	// Public returns the public key of the signer
+	Public() ed25519.PublicKey
+	// Sign signs message with ed25519
+	Sign(message []byte) ([]byte, error)
+}
+
+// Ed25519Key is a Signer for an ed25519 private key held in memory
+type Ed25519Key ed25519.PrivateKey
+
+// Public returns the public key of k
+func (k Ed25519Key) Public() ed25519.PublicKey {
+	return ed25519.PrivateKey(k).Public().(ed25519.PublicKey)
+}
+
+// Sign signs message with k
+func (k Ed25519Key) Sign(message []byte) ([]byte, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid ed25519 private key")
+	}
+	return ed25519.Sign(ed25519.PrivateKey(k), message), nil
+}
+
+// signWith signs message with signer and verifies the signature, so that a faulty remote signer
+// can't produce unverifiable certificates or CRLs
+func signWith(signer Signer, message []byte) ([]byte, error) {
+	sig, err := signer.Sign(message)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create signature: %w", err)
+	}
+	if !verifySignature(signer.Public(), message, sig) {
+		return nil, errors.New("Signer created an invalid signature")
+	}
+	return sig, nil
+}
+
+// SignCertificateWithSigner signs cert with signer, like SignCertificate does with a private key
+func SignCertificateWithSigner(cert *Certificate, signer Signer) (*Certificate, error) {
+	cert.SignatureAlgorithm = AlgorithmEd25519
+	cert.Signature = nil
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signWith(signer, certBytes)
+	if err != nil {
+		return nil, err
+	}
+	cert.Signature = sig
+	return cert, nil
+}
+
+// ParseTBSCertificate parses the to-be-signed encoding of a certificate, as passed to Signer.Sign
+// when a certificate is signed. Remote signers can use it to check what they are asked to sign.
+// An error is returned unless message is exactly the to-be-signed encoding of the certificate.
+func ParseTBSCertificate(message []byte) (*Certificate, error) {
+	cert, err := ParseBuf(bytes.TrimPrefix(message, signatureContext))
+	if err != nil {
+		return nil, err
+	}
+	if len(cert.Signature) > 0 {
+		return nil, errors.New("Message is a signed certificate")
+	}
+	if tbs, err := cert.TBSBytes(); err != nil || !bytes.Equal(tbs, message) {
+		return nil, errors.New("Message is not the to-be-signed encoding of a certificate")
+	}
+	return cert, nil
+}
+
+// ParseTBSCRL parses the to-be-signed encoding of a CRL, like ParseTBSCertificate does for
+// certificates
+func ParseTBSCRL(message []byte) (*CRL, error) {
+	if !bytes.HasPrefix(message, crlContext) {
+		return nil, errors.New("Message is not the to-be-signed encoding of a CRL")
+	}
+	crl, err := ParseCRL(message[len(crlContext):])
+	if err != nil {
+		return nil, err
+	}
+	if tbs, err := crl.tbsBytes(); err != nil || len(crl.Signature) > 0 || !bytes.Equal(tbs, message) {
+		return nil, errors.New("Message is not the to-be-signed encoding of a CRL")
+	}
+	return crl, nil
+}
+
+// ParseTBSDeltaCRL parses the to-be-signed encoding of a DeltaCRL, like ParseTBSCertificate does
+// for certificates
+func ParseTBSDeltaCRL(message []byte) (*DeltaCRL, error) {
+	if !bytes.HasPrefix(message, deltaCRLContext) {
+		return nil, errors.New("Message is not the to-be-signed encoding of a delta CRL")
+	}
+	delta, err := ParseDeltaCRL(message[len(deltaCRLContext):])
+	if err != nil {
+		return nil, err
+	}
+	if tbs, err := delta.tbsBytes(); err != nil || len(delta.Signature) > 0 || !bytes.Equal(tbs, message) {
+		return nil, errors.New("Message is not the to-be-signed encoding of a delta CRL")
+	}
+	return delta, nil
+}
+
+// ParseTBSEpochStatement parses the to-be-signed encoding of an EpochStatement, like
+// ParseTBSCertificate does for certificates
+func ParseTBSEpochStatement(message []byte) (*EpochStatement, error) {
+	if !bytes.HasPrefix(message, epochContext) {
+		return nil, errors.New("Message is not the to-be-signed encoding of an epoch statement")
+	}
+	s, err := ParseEpochStatement(message[len(epochContext):])
+	if err != nil {
+		return nil, err
+	}
+	if tbs, err := s.tbsBytes(); err != nil || len(s.Signature) > 0 || !bytes.Equal(tbs, message) {
+		return nil, errors.New("Message is not the to-be-signed encoding of an epoch statement")
+	}
+	return s, nil
+}
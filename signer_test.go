@@ -0,0 +1,138 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// faultySigner signs with the wrong key
+type faultySigner struct {
+	public ed25519.PublicKey
+	key    Ed25519Key
+}
+
+func (s *faultySigner) Public() ed25519.PublicKey { return s.public }
+
+func (s *faultySigner) Sign(message []byte) ([]byte, error) { return s.key.Sign(message) }
+
+func TestSignCertificateWithSigner(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := NewCertificateBuilder().Subject("device").Issuer("root").PublicKey(pubKey).Build()
+	require.NoError(t, err)
+
+	cert, err = SignCertificateWithSigner(cert, Ed25519Key(rootKey))
+	require.NoError(t, err)
+	assert.NoError(t, NewCertPool(rootCert).Validate(cert))
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = SignCertificateWithSigner(cert, &faultySigner{public: rootCert.PubKey, key: Ed25519Key(otherKey)})
+	assert.Error(t, err)
+	_, err = SignCertificateWithSigner(cert, Ed25519Key(rootKey[:32]))
+	assert.Error(t, err)
+}
+
+func TestCAWithSigner(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, err = NewCAWithSigner(rootCert, Ed25519Key(otherKey), NewMemoryStore())
+	assert.Error(t, err)
+
+	ca, err := NewCAWithSigner(rootCert, Ed25519Key(rootKey), NewMemoryStore())
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey, WithKeyUsage(KeyUsageClientIdentification))
+	require.NoError(t, err)
+	assert.NoError(t, NewCertPool(rootCert).Validate(cert))
+	require.NoError(t, ca.Revoke(cert.SerialNumber, RevocationReasonKeyCompromise))
+	crl, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	require.NoError(t, crl.Verify(rootCert))
+	assert.True(t, errors.Is(crl.Check(cert), ErrRevoked))
+
+	// A misbehaving signer doesn't produce certificates
+	ca, err = NewCAWithSigner(rootCert, &faultySigner{public: rootCert.PubKey, key: Ed25519Key(otherKey)}, NewMemoryStore())
+	require.NoError(t, err)
+	_, err = ca.Issue("device", pubKey)
+	assert.Error(t, err)
+	_, err = ca.GenerateCRL()
+	assert.Error(t, err)
+}
+
+// recordingSigner records the messages it signs
+type recordingSigner struct {
+	Ed25519Key
+	messages [][]byte
+}
+
+func (s *recordingSigner) Sign(message []byte) ([]byte, error) {
+	s.messages = append(s.messages, message)
+	return s.Ed25519Key.Sign(message)
+}
+
+func TestParseTBS(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	signer := &recordingSigner{Ed25519Key: Ed25519Key(rootKey)}
+	ca, err := NewCAWithSigner(rootCert, signer, NewMemoryStore())
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	for _, version := range []uint64{Version1, Version4} {
+		cert, err := ca.Issue("device", pubKey, WithVersion(version))
+		require.NoError(t, err)
+		tbs, err := ParseTBSCertificate(signer.messages[len(signer.messages)-1])
+		require.NoError(t, err)
+		assert.Equal(t, cert.SerialNumber, tbs.SerialNumber)
+		assert.Equal(t, "device", tbs.Subject)
+		_, err = ParseTBSCRL(signer.messages[len(signer.messages)-1])
+		assert.Error(t, err)
+
+		// Signed certificates are no to-be-signed encodings
+		buf, err := cert.Bytes()
+		require.NoError(t, err)
+		_, err = ParseTBSCertificate(buf)
+		assert.Error(t, err)
+	}
+
+	crl, err := ca.GenerateCRL()
+	require.NoError(t, err)
+	tbsCRL, err := ParseTBSCRL(signer.messages[len(signer.messages)-1])
+	require.NoError(t, err)
+	assert.Equal(t, crl.Number, tbsCRL.Number)
+	_, err = ParseTBSCertificate(signer.messages[len(signer.messages)-1])
+	assert.Error(t, err)
+	_, err = ParseTBSDeltaCRL(signer.messages[len(signer.messages)-1])
+	assert.Error(t, err)
+
+	delta, err := ca.GenerateDeltaCRL(crl)
+	require.NoError(t, err)
+	tbsDelta, err := ParseTBSDeltaCRL(signer.messages[len(signer.messages)-1])
+	require.NoError(t, err)
+	assert.Equal(t, delta.Number, tbsDelta.Number)
+
+	ca.Epoch = 3
+	_, err = ca.GenerateEpochStatement()
+	require.NoError(t, err)
+	tbsEpoch, err := ParseTBSEpochStatement(signer.messages[len(signer.messages)-1])
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), tbsEpoch.Epoch)
+	_, err = ParseTBSCRL(signer.messages[len(signer.messages)-1])
+	assert.Error(t, err)
+
+	// Trailing data is rejected
+	_, err = ParseTBSDeltaCRL(append(signer.messages[len(signer.messages)-1], 0))
+	assert.Error(t, err)
+}
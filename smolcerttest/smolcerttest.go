@@ -0,0 +1,123 @@
+/*
+Package smolcerttest creates throwaway certificates for tests of code handling smolcerts. All
+functions fail the test via t.Fatal if a certificate can't be created, so fixtures need a single
+line each:
+
+	h := smolcerttest.NewHierarchy(t)
+	leaf, err := h.Pool.ValidateBundle(h.Bundle())
+
+	expired, _ := smolcerttest.Expired(t, h.Intermediate, h.IntermediateKey)
+	broken := smolcerttest.CorruptSignature(t, h.Leaf)
+*/
+package smolcerttest
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Validity is the validity of the certificates created by this package, unless stated otherwise
+const Validity = 24 * time.Hour
+
+// Hierarchy is a root, an intermediate and a client certificate issued by the intermediate
+type Hierarchy struct {
+	Root            *smolcert.Certificate
+	RootKey         ed25519.PrivateKey
+	Intermediate    *smolcert.Certificate
+	IntermediateKey ed25519.PrivateKey
+	Leaf            *smolcert.Certificate
+	LeafKey         ed25519.PrivateKey
+	// Pool contains the Root
+	Pool *smolcert.CertPool
+}
+
+// NewHierarchy creates a Hierarchy with the subjects "root", "intermediate" and "leaf"
+func NewHierarchy(t testing.TB) *Hierarchy {
+	t.Helper()
+	h := &Hierarchy{}
+	h.Root, h.RootKey = NewRoot(t, "root")
+	h.Intermediate, h.IntermediateKey = NewIntermediate(t, "intermediate", h.Root, h.RootKey)
+	h.Leaf, h.LeafKey = NewLeaf(t, "leaf", h.Intermediate, h.IntermediateKey)
+	h.Pool = smolcert.NewCertPool(h.Root)
+	return h
+}
+
+// Bundle returns the leaf and intermediate certificate as bundle for CertPool.ValidateBundle
+func (h *Hierarchy) Bundle() []*smolcert.Certificate {
+	return []*smolcert.Certificate{h.Leaf, h.Intermediate}
+}
+
+// NewRoot creates a self-signed root certificate
+func NewRoot(t testing.TB, subject string, opts ...smolcert.IssueOption) (*smolcert.Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	pubKey, key := newKey(t)
+	opts = append([]smolcert.IssueOption{smolcert.WithKeyUsage(smolcert.KeyUsageSignCert), smolcert.WithValidFor(Validity)}, opts...)
+	return issue(t, subject, pubKey, subject, key, opts), key
+}
+
+// NewIntermediate creates a certificate allowed to sign certificates, issued by issuer
+func NewIntermediate(t testing.TB, subject string, issuer *smolcert.Certificate, issuerKey ed25519.PrivateKey,
+	opts ...smolcert.IssueOption) (*smolcert.Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	opts = append([]smolcert.IssueOption{smolcert.WithKeyUsage(smolcert.KeyUsageSignCert)}, opts...)
+	return NewLeaf(t, subject, issuer, issuerKey, opts...)
+}
+
+// NewLeaf creates a client certificate issued by issuer. The options may override the KeyUsage
+// and validity.
+func NewLeaf(t testing.TB, subject string, issuer *smolcert.Certificate, issuerKey ed25519.PrivateKey,
+	opts ...smolcert.IssueOption) (*smolcert.Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	pubKey, key := newKey(t)
+	opts = append([]smolcert.IssueOption{smolcert.WithKeyUsage(smolcert.KeyUsageClientIdentification),
+		smolcert.WithValidFor(Validity)}, opts...)
+	return issue(t, subject, pubKey, issuer.Subject, issuerKey, opts), key
+}
+
+// Expired creates a client certificate issued by issuer, which expired an hour ago
+func Expired(t testing.TB, issuer *smolcert.Certificate, issuerKey ed25519.PrivateKey) (*smolcert.Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	now := time.Now()
+	return NewLeaf(t, "expired", issuer, issuerKey, smolcert.WithValidity(now.Add(-Validity), now.Add(-time.Hour)))
+}
+
+// NotYetValid creates a client certificate issued by issuer, which becomes valid in an hour
+func NotYetValid(t testing.TB, issuer *smolcert.Certificate, issuerKey ed25519.PrivateKey) (*smolcert.Certificate, ed25519.PrivateKey) {
+	t.Helper()
+	now := time.Now()
+	return NewLeaf(t, "not-yet-valid", issuer, issuerKey, smolcert.WithValidity(now.Add(time.Hour), now.Add(Validity)))
+}
+
+// CorruptSignature returns a copy of cert with an invalid signature
+func CorruptSignature(t testing.TB, cert *smolcert.Certificate) *smolcert.Certificate {
+	t.Helper()
+	if len(cert.Signature) == 0 {
+		t.Fatal("Certificate has no signature to corrupt")
+	}
+	corrupt := cert.Copy()
+	corrupt.Signature[len(corrupt.Signature)-1] ^= 0x01
+	return corrupt
+}
+
+func newKey(t testing.TB) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pubKey, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	return pubKey, key
+}
+
+func issue(t testing.TB, subject string, pubKey ed25519.PublicKey, issuer string, issuerKey ed25519.PrivateKey,
+	opts []smolcert.IssueOption) *smolcert.Certificate {
+	t.Helper()
+	cert, err := smolcert.Issue(subject, pubKey, issuer, issuerKey, opts...)
+	if err != nil {
+		t.Fatalf("Failed to issue certificate for %s: %s", subject, err)
+	}
+	return cert
+}
@@ -0,0 +1,44 @@
+package smolcerttest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHierarchy(t *testing.T) {
+	h := NewHierarchy(t)
+	leaf, err := h.Pool.ValidateBundle(h.Bundle())
+	require.NoError(t, err)
+	assert.Equal(t, h.Leaf, leaf)
+	assert.NoError(t, h.Pool.Validate(h.Intermediate))
+	assert.NoError(t, smolcert.RequiresExtension(h.Leaf, smolcert.OIDKeyUsage,
+		smolcert.ExpectKeyUsage(smolcert.KeyUsageClientIdentification)))
+	assert.Equal(t, h.Leaf.PubKey, h.LeafKey.Public())
+
+	server, _ := NewLeaf(t, "server", h.Root, h.RootKey, smolcert.WithKeyUsage(smolcert.KeyUsageServerIdentification))
+	assert.NoError(t, smolcert.RequiresExtension(server, smolcert.OIDKeyUsage,
+		smolcert.ExpectKeyUsage(smolcert.KeyUsageServerIdentification)))
+	assert.NoError(t, h.Pool.Validate(server))
+}
+
+func TestFixtures(t *testing.T) {
+	h := NewHierarchy(t)
+
+	expired, _ := Expired(t, h.Intermediate, h.IntermediateKey)
+	_, err := h.Pool.ValidateBundle([]*smolcert.Certificate{expired, h.Intermediate})
+	assert.True(t, errors.Is(err, smolcert.ErrExpired))
+
+	notYetValid, _ := NotYetValid(t, h.Intermediate, h.IntermediateKey)
+	_, err = h.Pool.ValidateBundle([]*smolcert.Certificate{notYetValid, h.Intermediate})
+	assert.True(t, errors.Is(err, smolcert.ErrNotYetValid))
+
+	corrupt := CorruptSignature(t, h.Leaf)
+	_, err = h.Pool.ValidateBundle([]*smolcert.Certificate{corrupt, h.Intermediate})
+	assert.True(t, errors.Is(err, smolcert.ErrBadSignature))
+	_, err = h.Pool.ValidateBundle(h.Bundle())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,89 @@
+package spiffe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/smolcert/smolcert"
+)
+
+// BundleSet holds the roots of trust domains, the local one as well as federated ones. It is
+// safe for concurrent use.
+type BundleSet struct {
+	lock  sync.RWMutex
+	pools map[TrustDomain]*smolcert.CertPool
+}
+
+// NewBundleSet creates an empty BundleSet
+func NewBundleSet() *BundleSet {
+	return &BundleSet{pools: map[TrustDomain]*smolcert.CertPool{}}
+}
+
+// Add adds roots to the pool of the trust domain td. Like CertPool.Add it ignores certificates
+// which are not allowed to sign certificates.
+func (s *BundleSet) Add(td TrustDomain, roots ...*smolcert.Certificate) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	pool := s.pools[td]
+	if pool == nil {
+		pool = smolcert.NewCertPool()
+	}
+	// Pools are replaced instead of modified, so returned pools never change
+	updated := smolcert.NewCertPool(append(pool.All(), roots...)...)
+	s.pools[td] = updated
+}
+
+// Set replaces the roots of the trust domain td, i.e. after fetching a new federation bundle.
+// A trust domain without roots is removed.
+func (s *BundleSet) Set(td TrustDomain, roots ...*smolcert.Certificate) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(roots) == 0 {
+		delete(s.pools, td)
+		return
+	}
+	s.pools[td] = smolcert.NewCertPool(roots...)
+}
+
+// Pool returns the roots of the trust domain td or nil. The pool must not be modified.
+func (s *BundleSet) Pool(td TrustDomain) *smolcert.CertPool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.pools[td]
+}
+
+// TrustDomains returns all trust domains with roots, sorted by name
+func (s *BundleSet) TrustDomains() []TrustDomain {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	tds := make([]TrustDomain, 0, len(s.pools))
+	for td := range s.pools {
+		tds = append(tds, td)
+	}
+	sort.Slice(tds, func(i, j int) bool { return tds[i] < tds[j] })
+	return tds
+}
+
+// Validate validates the chain of certs, starting with the leaf, against the roots of the trust
+// domain of the SPIFFE ID of the leaf and returns the ID. Roots of other trust domains are never
+// trusted for the ID.
+func (s *BundleSet) Validate(certs []*smolcert.Certificate, opts ...smolcert.VerifyOption) (ID, error) {
+	if len(certs) == 0 {
+		return ID{}, errors.New("No certificate to validate")
+	}
+	id, err := IDFromCertificate(certs[0])
+	if err != nil {
+		return ID{}, err
+	}
+	pool := s.Pool(id.TrustDomain)
+	if pool == nil {
+		return ID{}, fmt.Errorf("%w: %s", ErrUnknownTrustDomain, id.TrustDomain)
+	}
+	v := &smolcert.Validator{Pool: pool, Options: opts}
+	if _, err := smolcert.NewBundle(certs...).ValidateWith(v); err != nil {
+		return ID{}, err
+	}
+	return id, nil
+}
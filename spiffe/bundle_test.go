@@ -0,0 +1,70 @@
+package spiffe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newTestCA(t *testing.T, subject string) *smolcert.CA {
+	root, key, err := smolcert.SelfSignedCertificate(subject, time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCA(root, key)
+	require.NoError(t, err)
+	return ca
+}
+
+func issueSVID(t *testing.T, ca *smolcert.CA, id string) *smolcert.Certificate {
+	parsed, err := ParseID(id)
+	require.NoError(t, err)
+	ext, err := Extension(parsed)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("workload", pubKey, smolcert.WithExtensions(ext))
+	require.NoError(t, err)
+	return cert
+}
+
+func TestBundleSet(t *testing.T) {
+	caA := newTestCA(t, "root-a")
+	caB := newTestCA(t, "root-b")
+	set := NewBundleSet()
+	set.Add("a.example.org", caA.Certificate)
+	assert.Equal(t, []TrustDomain{"a.example.org"}, set.TrustDomains())
+
+	leaf := issueSVID(t, caA, "spiffe://a.example.org/device/1")
+	id, err := set.Validate([]*smolcert.Certificate{leaf})
+	require.NoError(t, err)
+	assert.Equal(t, ID{TrustDomain: "a.example.org", Path: "/device/1"}, id)
+
+	// Roots of one trust domain are never trusted for IDs of another
+	forged := issueSVID(t, caB, "spiffe://a.example.org/device/1")
+	set.Add("b.example.org", caB.Certificate)
+	assert.Equal(t, []TrustDomain{"a.example.org", "b.example.org"}, set.TrustDomains())
+	_, err = set.Validate([]*smolcert.Certificate{forged})
+	assert.Error(t, err)
+
+	unknown := issueSVID(t, caA, "spiffe://c.example.org/device/1")
+	_, err = set.Validate([]*smolcert.Certificate{unknown})
+	assert.True(t, errors.Is(err, ErrUnknownTrustDomain))
+
+	_, err = set.Validate(nil)
+	assert.Error(t, err)
+
+	// Returned pools aren't modified by later additions
+	pool := set.Pool("a.example.org")
+	set.Add("a.example.org", caB.Certificate)
+	assert.Equal(t, 1, pool.Len())
+	assert.Equal(t, 2, set.Pool("a.example.org").Len())
+
+	set.Set("a.example.org")
+	assert.Nil(t, set.Pool("a.example.org"))
+	_, err = set.Validate([]*smolcert.Certificate{leaf})
+	assert.True(t, errors.Is(err, ErrUnknownTrustDomain))
+}
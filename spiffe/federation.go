@@ -0,0 +1,130 @@
+//go:build !tinygo
+// +build !tinygo
+
+package spiffe
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smolcert/smolcert"
+)
+
+// useX509SVID is the use of keys in SPIFFE bundles which are roots of X.509 SVIDs
+const useX509SVID = "x509-svid"
+
+// FederationBundle is the content of a SPIFFE bundle of a trust domain, as published on its
+// bundle endpoint
+type FederationBundle struct {
+	// Roots are the X.509 authorities of the bundle, converted via smolcert.FromX509
+	Roots []*smolcert.Certificate
+	// Sequence is incremented by the trust domain whenever the bundle changes
+	Sequence uint64
+	// RefreshHint is the interval in which the bundle should be fetched again, zero if unset
+	RefreshHint time.Duration
+	// Skipped is the number of X.509 authorities which can't be used with smolcert, i.e.
+	// because they don't have ed25519 keys
+	Skipped int
+}
+
+type bundleJSON struct {
+	Keys        []bundleKeyJSON `json:"keys"`
+	Sequence    uint64          `json:"spiffe_sequence,omitempty"`
+	RefreshHint int64           `json:"spiffe_refresh_hint,omitempty"`
+}
+
+type bundleKeyJSON struct {
+	Use string `json:"use"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	// X5c contains the DER encoded certificate, standard base64 encoded
+	X5c []string `json:"x5c,omitempty"`
+}
+
+// ParseFederationBundle parses a SPIFFE bundle in its JWK set form. X.509 authorities with
+// ed25519 keys are converted to smolcert roots, other keys are skipped.
+func ParseFederationBundle(buf []byte) (*FederationBundle, error) {
+	var parsed bundleJSON
+	if err := json.Unmarshal(buf, &parsed); err != nil {
+		return nil, fmt.Errorf("Failed to parse SPIFFE bundle: %w", err)
+	}
+	if parsed.Keys == nil {
+		return nil, errors.New("SPIFFE bundle has no keys")
+	}
+	b := &FederationBundle{
+		Sequence:    parsed.Sequence,
+		RefreshHint: time.Duration(parsed.RefreshHint) * time.Second,
+	}
+	for _, key := range parsed.Keys {
+		if key.Use != useX509SVID {
+			continue
+		}
+		if len(key.X5c) != 1 {
+			return nil, errors.New("X.509 authorities of SPIFFE bundles need exactly one certificate")
+		}
+		der, err := base64.StdEncoding.DecodeString(key.X5c[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid X.509 authority in SPIFFE bundle: %w", err)
+		}
+		x509Cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid X.509 authority in SPIFFE bundle: %w", err)
+		}
+		if x509Cert.PublicKeyAlgorithm != x509.Ed25519 {
+			b.Skipped++
+			continue
+		}
+		root, err := smolcert.FromX509(x509Cert)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to convert X.509 authority '%s': %w", x509Cert.Subject, err)
+		}
+		b.Roots = append(b.Roots, root)
+	}
+	return b, nil
+}
+
+// Marshal encodes the bundle in its JWK set form. Only roots converted from X.509 certificates
+// can be published, smolcert roots need to be exported with smolcert.X509Root and converted back
+// with smolcert.FromX509 before.
+func (b *FederationBundle) Marshal() ([]byte, error) {
+	out := bundleJSON{
+		Keys:        []bundleKeyJSON{},
+		Sequence:    b.Sequence,
+		RefreshHint: int64(b.RefreshHint / time.Second),
+	}
+	for _, root := range b.Roots {
+		x509Cert, err := root.X509Certificate()
+		if err != nil {
+			return nil, fmt.Errorf("Root '%s' can't be published: %w", root.Subject, err)
+		}
+		jwk := smolcert.NewPublicJWK(root.PubKey)
+		out.Keys = append(out.Keys, bundleKeyJSON{
+			Use: useX509SVID,
+			Kty: jwk.KeyType,
+			Crv: jwk.Curve,
+			X:   jwk.X,
+			X5c: []string{base64.StdEncoding.EncodeToString(x509Cert.Raw)},
+		})
+	}
+	return json.Marshal(out)
+}
+
+// ImportFederationBundle parses a SPIFFE bundle of the trust domain td and replaces the roots of
+// td with its X.509 authorities. A bundle without usable authorities is rejected, so a bundle of
+// a trust domain without ed25519 keys doesn't silently remove the trust domain.
+func (s *BundleSet) ImportFederationBundle(td TrustDomain, buf []byte) (*FederationBundle, error) {
+	b, err := ParseFederationBundle(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.Roots) == 0 {
+		return nil, fmt.Errorf("SPIFFE bundle of '%s' has no ed25519 X.509 authorities", td)
+	}
+	s.Set(td, b.Roots...)
+	return b, nil
+}
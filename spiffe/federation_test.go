@@ -0,0 +1,92 @@
+//go:build !tinygo
+// +build !tinygo
+
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestFederationBundle(t *testing.T) {
+	root, rootKey, err := smolcert.SelfSignedCertificate("partner-root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	x509Root, err := smolcert.X509Root(root, rootKey)
+	require.NoError(t, err)
+	anchor, err := smolcert.FromX509(x509Root)
+	require.NoError(t, err)
+
+	bundle := &FederationBundle{Roots: []*smolcert.Certificate{anchor}, Sequence: 3, RefreshHint: time.Hour}
+	buf, err := bundle.Marshal()
+	require.NoError(t, err)
+	_, err = (&FederationBundle{Roots: []*smolcert.Certificate{root}}).Marshal()
+	assert.Error(t, err, "smolcert roots need to be converted first")
+
+	set := NewBundleSet()
+	imported, err := set.ImportFederationBundle("partner.example.org", buf)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, imported)
+
+	ext, err := Extension(ID{TrustDomain: "partner.example.org", Path: "/device"})
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	leaf, err := smolcert.Issue("device", pubKey, "partner-root", rootKey, smolcert.WithExtensions(ext))
+	require.NoError(t, err)
+	id, err := set.Validate([]*smolcert.Certificate{leaf})
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://partner.example.org/device", id.String())
+
+	// Authorities without ed25519 keys and JWT authorities are skipped
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ec-root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &ecKey.PublicKey, ecKey)
+	require.NoError(t, err)
+	ecBundle, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{"use": "x509-svid", "kty": "EC", "crv": "P-256", "x5c": []string{base64.StdEncoding.EncodeToString(der)}},
+			{"use": "jwt-svid", "kty": "OKP", "crv": "Ed25519", "kid": "key-1"},
+		},
+	})
+	require.NoError(t, err)
+	parsed, err := ParseFederationBundle(ecBundle)
+	require.NoError(t, err)
+	assert.Equal(t, 1, parsed.Skipped)
+	assert.Empty(t, parsed.Roots)
+	_, err = set.ImportFederationBundle("partner.example.org", ecBundle)
+	assert.Error(t, err)
+	assert.NotNil(t, set.Pool("partner.example.org"), "a rejected bundle keeps the previous roots")
+
+	for name, invalid := range map[string]string{
+		"json":    `{`,
+		"no keys": `{}`,
+		"base64":  `{"keys":[{"use":"x509-svid","kty":"OKP","x5c":["!"]}]}`,
+		"der":     `{"keys":[{"use":"x509-svid","kty":"OKP","x5c":["AAAA"]}]}`,
+		"chain":   `{"keys":[{"use":"x509-svid","kty":"OKP","x5c":[]}]}`,
+	} {
+		_, err := ParseFederationBundle([]byte(invalid))
+		assert.Error(t, err, name)
+	}
+}
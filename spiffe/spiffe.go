@@ -0,0 +1,177 @@
+/*
+Package spiffe maps smolcerts to SPIFFE workload identities, so smolcert devices can participate
+in SPIFFE based meshes.
+
+A smolcert is an SVID if it carries exactly one SPIFFE ID as URI in its SubjectAltNames:
+
+	id, _ := spiffe.ParseID("spiffe://example.org/device/1")
+	ext, _ := spiffe.Extension(id)
+	cert, _ := ca.Issue("device-1", pubKey, smolcert.WithExtensions(ext))
+
+Certificates are validated against the roots of the trust domain of their SPIFFE ID, which are
+kept in a BundleSet. Roots of federated trust domains can be imported from SPIFFE bundles, as far
+as they are ed25519 X.509 certificates:
+
+	set := spiffe.NewBundleSet()
+	set.Add(localDomain, rootCert)
+	set.ImportFederationBundle(partnerDomain, bundleJSON)
+	id, err := set.Validate(peerCerts)
+*/
+package spiffe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/smolcert/smolcert"
+)
+
+const (
+	// scheme is the prefix of all SPIFFE IDs
+	scheme = "spiffe://"
+	// maxIDLength is the maximum length of SPIFFE IDs in bytes
+	maxIDLength = 2048
+)
+
+var (
+	// ErrNoSPIFFEID is returned if a certificate doesn't carry exactly one SPIFFE ID
+	ErrNoSPIFFEID = errors.New("certificate has no SPIFFE ID")
+	// ErrUnknownTrustDomain is returned if a certificate belongs to a trust domain without roots
+	ErrUnknownTrustDomain = errors.New("unknown trust domain")
+)
+
+// TrustDomain is the name of a SPIFFE trust domain, i.e. "example.org"
+type TrustDomain string
+
+// ParseTrustDomain parses the name of a trust domain. The SPIFFE ID of the trust domain, i.e.
+// "spiffe://example.org", is accepted as well.
+func ParseTrustDomain(s string) (TrustDomain, error) {
+	if strings.HasPrefix(s, scheme) {
+		id, err := ParseID(s)
+		if err != nil {
+			return "", err
+		}
+		if id.Path != "" {
+			return "", fmt.Errorf("'%s' is a workload ID, not a trust domain", s)
+		}
+		return id.TrustDomain, nil
+	}
+	if err := checkTrustDomain(s); err != nil {
+		return "", err
+	}
+	return TrustDomain(s), nil
+}
+
+// ID returns the SPIFFE ID of the trust domain itself
+func (td TrustDomain) ID() ID {
+	return ID{TrustDomain: td}
+}
+
+// String returns the name of the trust domain
+func (td TrustDomain) String() string {
+	return string(td)
+}
+
+func checkTrustDomain(s string) error {
+	if s == "" {
+		return errors.New("Trust domain can't be empty")
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("Trust domain '%s' contains the invalid character %q", s, c)
+		}
+	}
+	return nil
+}
+
+// ID is a SPIFFE ID, identifying a workload within a trust domain
+type ID struct {
+	TrustDomain TrustDomain
+	// Path is empty or starts with '/', i.e. "/device/1"
+	Path string
+}
+
+// ParseID parses a SPIFFE ID, i.e. "spiffe://example.org/device/1"
+func ParseID(s string) (ID, error) {
+	if len(s) > maxIDLength {
+		return ID{}, fmt.Errorf("SPIFFE ID exceeds %d bytes", maxIDLength)
+	}
+	if !strings.HasPrefix(s, scheme) {
+		return ID{}, fmt.Errorf("'%s' is not a SPIFFE ID", s)
+	}
+	rest := s[len(scheme):]
+	td, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		td, path = rest[:i], rest[i:]
+	}
+	if err := checkTrustDomain(td); err != nil {
+		return ID{}, fmt.Errorf("Invalid SPIFFE ID '%s': %w", s, err)
+	}
+	if err := checkPath(path); err != nil {
+		return ID{}, fmt.Errorf("Invalid SPIFFE ID '%s': %w", s, err)
+	}
+	return ID{TrustDomain: TrustDomain(td), Path: path}, nil
+}
+
+func checkPath(path string) error {
+	if path == "" {
+		return nil
+	}
+	for _, segment := range strings.Split(path[1:], "/") {
+		switch segment {
+		case "":
+			return errors.New("Path segments can't be empty")
+		case ".", "..":
+			return errors.New("Path segments can't be relative")
+		}
+		for _, c := range segment {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+				return fmt.Errorf("Path contains the invalid character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the canonical form of the ID
+func (id ID) String() string {
+	return scheme + string(id.TrustDomain) + id.Path
+}
+
+// MemberOf is true if the ID belongs to td
+func (id ID) MemberOf(td TrustDomain) bool {
+	return id.TrustDomain == td
+}
+
+// Extension creates the SubjectAltNames extension carrying id
+func Extension(id ID) (smolcert.Extension, error) {
+	if _, err := ParseID(id.String()); err != nil {
+		return smolcert.Extension{}, err
+	}
+	return smolcert.SubjectAltNamesExtension(smolcert.URIName(id.String()))
+}
+
+// IDFromCertificate returns the SPIFFE ID of cert. Like X.509 SVIDs, the certificate needs to
+// carry exactly one URI in its SubjectAltNames, which is a SPIFFE ID. The certificate needs to
+// be validated before, i.e. with BundleSet.Validate.
+func IDFromCertificate(cert *smolcert.Certificate) (ID, error) {
+	names, err := cert.SubjectAltNames()
+	if err != nil {
+		return ID{}, err
+	}
+	var uris []string
+	for _, n := range names {
+		if n.Type == smolcert.SANURI {
+			uris = append(uris, string(n.Value))
+		}
+	}
+	if len(uris) != 1 {
+		return ID{}, fmt.Errorf("%w: certificate '%s' has %d URIs", ErrNoSPIFFEID, cert.Subject, len(uris))
+	}
+	id, err := ParseID(uris[0])
+	if err != nil {
+		return ID{}, fmt.Errorf("%w: %s", ErrNoSPIFFEID, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,93 @@
+package spiffe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("spiffe://example.org/device/1")
+	require.NoError(t, err)
+	assert.Equal(t, ID{TrustDomain: "example.org", Path: "/device/1"}, id)
+	assert.Equal(t, "spiffe://example.org/device/1", id.String())
+	assert.True(t, id.MemberOf("example.org"))
+	assert.False(t, id.MemberOf("example.com"))
+
+	id, err = ParseID("spiffe://example.org")
+	require.NoError(t, err)
+	assert.Equal(t, TrustDomain("example.org").ID(), id)
+
+	for _, invalid := range []string{
+		"",
+		"https://example.org/device",
+		"SPIFFE://example.org/device",
+		"spiffe://",
+		"spiffe:///device",
+		"spiffe://Example.org/device",
+		"spiffe://example.org:8443/device",
+		"spiffe://user@example.org/device",
+		"spiffe://example.org/",
+		"spiffe://example.org//device",
+		"spiffe://example.org/device/../admin",
+		"spiffe://example.org/device?x=1",
+		"spiffe://example.org/device#x",
+		"spiffe://example.org/dev%20ice",
+	} {
+		_, err := ParseID(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	td, err := ParseTrustDomain("spiffe://example.org")
+	require.NoError(t, err)
+	assert.Equal(t, TrustDomain("example.org"), td)
+	td, err = ParseTrustDomain("example.org")
+	require.NoError(t, err)
+	assert.Equal(t, "example.org", td.String())
+	for _, invalid := range []string{"", "Example.org", "spiffe://example.org/device", "example.org/device"} {
+		_, err := ParseTrustDomain(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestIDFromCertificate(t *testing.T) {
+	_, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	id, err := ParseID("spiffe://example.org/device/1")
+	require.NoError(t, err)
+
+	ext, err := Extension(id)
+	require.NoError(t, err)
+	cert, err := smolcert.Issue("device-1", pubKey, "root", rootKey, smolcert.WithExtensions(ext))
+	require.NoError(t, err)
+	parsed, err := IDFromCertificate(cert)
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	_, err = Extension(ID{TrustDomain: "Invalid"})
+	assert.Error(t, err)
+
+	for name, names := range map[string][]smolcert.SubjectAltName{
+		"two URIs":   {smolcert.URIName(id.String()), smolcert.URIName("spiffe://example.org/device/2")},
+		"no URI":     {smolcert.DNSName("device.example.org")},
+		"not SPIFFE": {smolcert.URIName("https://example.org/device")},
+	} {
+		ext, err := smolcert.SubjectAltNamesExtension(names...)
+		require.NoError(t, err)
+		cert, err := smolcert.Issue("device-1", pubKey, "root", rootKey, smolcert.WithExtensions(ext))
+		require.NoError(t, err)
+		_, err = IDFromCertificate(cert)
+		assert.True(t, errors.Is(err, ErrNoSPIFFEID), name)
+	}
+	cert, err = smolcert.Issue("device-1", pubKey, "root", rootKey)
+	require.NoError(t, err)
+	_, err = IDFromCertificate(cert)
+	assert.True(t, errors.Is(err, ErrNoSPIFFEID))
+}
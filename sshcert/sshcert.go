@@ -0,0 +1,104 @@
+/*
+Package sshcert converts smolcerts into OpenSSH certificates, so that identities issued as smolcerts
+can also be used to authenticate SSH users and hosts.
+
+The ed25519 key of the smolcert becomes the key of the SSH certificate, the subject is used as key
+id and principal and the validity is carried over. The SSH certificate is signed by an SSH
+authority, which can be created from the ed25519 key of the smolcert issuer:
+
+	authority, _ := ssh.NewSignerFromKey(issuerKey)
+	sshCert, _ := sshcert.UserCertificate(cert, authority)
+	authorizedKey := ssh.MarshalAuthorizedKey(sshCert)
+
+The public key of the authority needs to be configured as TrustedUserCAKeys on SSH servers or as
+@cert-authority in known_hosts files on SSH clients.
+*/
+package sshcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultUserPermissions are the permissions ssh-keygen grants to user certificates by default
+var DefaultUserPermissions = ssh.Permissions{
+	Extensions: map[string]string{
+		"permit-X11-forwarding":   "",
+		"permit-agent-forwarding": "",
+		"permit-port-forwarding":  "",
+		"permit-pty":              "",
+		"permit-user-rc":          "",
+	},
+}
+
+// UserCertificate converts cert into an SSH user certificate with the default permissions, signed by authority
+func UserCertificate(cert *smolcert.Certificate, authority ssh.Signer) (*ssh.Certificate, error) {
+	return Convert(cert, ssh.UserCert, DefaultUserPermissions, authority)
+}
+
+// HostCertificate converts cert into an SSH host certificate, signed by authority. The subject
+// of cert needs to be the host name.
+func HostCertificate(cert *smolcert.Certificate, authority ssh.Signer) (*ssh.Certificate, error) {
+	return Convert(cert, ssh.HostCert, ssh.Permissions{}, authority)
+}
+
+// Convert converts cert into an SSH certificate of the given type (ssh.UserCert or ssh.HostCert)
+// with the given permissions and signs it with authority. The smolcert itself is not validated,
+// callers should validate it before converting it.
+func Convert(cert *smolcert.Certificate, certType uint32, permissions ssh.Permissions,
+	authority ssh.Signer) (*ssh.Certificate, error) {
+	if certType != ssh.UserCert && certType != ssh.HostCert {
+		return nil, fmt.Errorf("Unknown SSH certificate type %d", certType)
+	}
+	if cert.Subject == "" {
+		return nil, errors.New("Certificates without subject can't be converted")
+	}
+	pubKey, err := ssh.NewPublicKey(cert.PubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sshCert := &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          cert.SerialNumber,
+		CertType:        certType,
+		KeyId:           cert.Subject,
+		ValidPrincipals: []string{cert.Subject},
+		ValidAfter:      0,
+		ValidBefore:     ssh.CertTimeInfinity,
+		Permissions:     copyPermissions(permissions),
+	}
+	if cert.Validity != nil {
+		if !cert.Validity.NotBefore.IsZero() {
+			sshCert.ValidAfter = uint64(cert.Validity.NotBefore.StdTime().Unix())
+		}
+		if !cert.Validity.NotAfter.IsZero() {
+			sshCert.ValidBefore = uint64(cert.Validity.NotAfter.StdTime().Unix())
+		}
+	}
+	if err := sshCert.SignCert(rand.Reader, authority); err != nil {
+		return nil, err
+	}
+	return sshCert, nil
+}
+
+func copyPermissions(p ssh.Permissions) ssh.Permissions {
+	c := ssh.Permissions{}
+	if p.CriticalOptions != nil {
+		c.CriticalOptions = make(map[string]string, len(p.CriticalOptions))
+		for k, v := range p.CriticalOptions {
+			c.CriticalOptions[k] = v
+		}
+	}
+	if p.Extensions != nil {
+		c.Extensions = make(map[string]string, len(p.Extensions))
+		for k, v := range p.Extensions {
+			c.Extensions[k] = v
+		}
+	}
+	return c
+}
@@ -0,0 +1,82 @@
+package sshcert
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestUserCertificate(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	notBefore := time.Now().Add(-time.Minute).Truncate(time.Second)
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	cert, _, err := smolcert.ClientCertificate("alice", 42, notBefore, notAfter, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	authority, err := ssh.NewSignerFromKey(rootKey)
+	require.NoError(t, err)
+	sshCert, err := UserCertificate(cert, authority)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(42), sshCert.Serial)
+	assert.Equal(t, "alice", sshCert.KeyId)
+	assert.Equal(t, []string{"alice"}, sshCert.ValidPrincipals)
+	assert.Equal(t, uint64(notBefore.Unix()), sshCert.ValidAfter)
+	assert.Equal(t, uint64(notAfter.Unix()), sshCert.ValidBefore)
+	assert.Contains(t, sshCert.Permissions.Extensions, "permit-pty")
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(authority.PublicKey().Marshal())
+		},
+	}
+	_, err = checker.Authenticate(connMetadata("alice"), sshCert)
+	assert.NoError(t, err)
+	_, err = checker.Authenticate(connMetadata("bob"), sshCert)
+	assert.Error(t, err)
+
+	// The certificate survives the authorized_keys format
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey(ssh.MarshalAuthorizedKey(sshCert))
+	require.NoError(t, err)
+	assert.Equal(t, sshCert.Marshal(), parsed.Marshal())
+}
+
+func TestHostCertificate(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := smolcert.ServerCertificate("host.example.com", 1, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+
+	authority, err := ssh.NewSignerFromKey(rootKey)
+	require.NoError(t, err)
+	sshCert, err := HostCertificate(cert, authority)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(ssh.HostCert), sshCert.CertType)
+	assert.Equal(t, uint64(ssh.CertTimeInfinity), sshCert.ValidBefore)
+
+	checker := &ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, address string) bool {
+			return string(auth.Marshal()) == string(authority.PublicKey().Marshal())
+		},
+	}
+	assert.NoError(t, checker.CheckHostKey("host.example.com:22", &net.TCPAddr{}, sshCert))
+	assert.Error(t, checker.CheckHostKey("other.example.com:22", &net.TCPAddr{}, sshCert))
+
+	_, err = Convert(cert, 3, ssh.Permissions{}, authority)
+	assert.Error(t, err)
+}
+
+type connMetadata string
+
+func (c connMetadata) User() string          { return string(c) }
+func (c connMetadata) SessionID() []byte     { return nil }
+func (c connMetadata) ClientVersion() []byte { return nil }
+func (c connMetadata) ServerVersion() []byte { return nil }
+func (c connMetadata) RemoteAddr() net.Addr  { return &net.TCPAddr{} }
+func (c connMetadata) LocalAddr() net.Addr   { return &net.TCPAddr{} }
@@ -0,0 +1,123 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// revocationStatusContext is prepended to signed revocation statuses, so that their signatures
+// can't be mistaken for signatures of other structures.
+var revocationStatusContext = []byte("smolcert revocation status")
+
+var (
+	// ErrStaleRevocationStatus indicates that a stapled revocation status is not current
+	ErrStaleRevocationStatus = errors.New("revocation status is not current")
+)
+
+// RevocationStatus is a statement signed by the issuer of a certificate, that the certificate has
+// not been revoked as of ProducedAt. Servers can staple a recent RevocationStatus to their
+// certificate, so that clients can skip revocation checks requiring online connectivity.
+type RevocationStatus struct {
+	_ struct{} `cbor:",toarray"`
+
+	Issuer       string `cbor:"issuer"`
+	SerialNumber uint64 `cbor:"serial_number"`
+	ProducedAt   Time   `cbor:"produced_at"`
+	NextUpdate   Time   `cbor:"next_update"`
+	Signature    []byte `cbor:"signature"`
+}
+
+// NewRevocationStatus creates a RevocationStatus for cert which is valid for validFor and signs
+// it with the key of the issuer of cert.
+func NewRevocationStatus(cert *Certificate, validFor time.Duration, issuerKey ed25519.PrivateKey) (*RevocationStatus, error) {
+	now := time.Now()
+	s := &RevocationStatus{
+		Issuer:       cert.Issuer,
+		SerialNumber: cert.SerialNumber,
+		ProducedAt:   NewTime(now),
+		NextUpdate:   NewTime(now.Add(validFor)),
+	}
+	tbs, err := s.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	s.Signature = ed25519.Sign(issuerKey, tbs)
+	return s, nil
+}
+
+// ParseRevocationStatus parses a RevocationStatus from an existing byte buffer
+func ParseRevocationStatus(buf []byte) (*RevocationStatus, error) {
+	s := &RevocationStatus{}
+	if err := cbor.Unmarshal(buf, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Bytes returns the CBOR encoded form of the RevocationStatus
+func (s *RevocationStatus) Bytes() ([]byte, error) {
+	return cborEm.Marshal(s)
+}
+
+func (s *RevocationStatus) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&RevocationStatus{
+		Issuer:       s.Issuer,
+		SerialNumber: s.SerialNumber,
+		ProducedAt:   s.ProducedAt,
+		NextUpdate:   s.NextUpdate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, revocationStatusContext...), buf...), nil
+}
+
+// Verify checks that this RevocationStatus is current, belongs to cert and is signed by issuerKey
+func (s *RevocationStatus) Verify(cert *Certificate, issuerKey ed25519.PublicKey) error {
+	if s.Issuer != cert.Issuer || s.SerialNumber != cert.SerialNumber {
+		return fmt.Errorf("Revocation status for serial %d from '%s' doesn't belong to this certificate",
+			s.SerialNumber, s.Issuer)
+	}
+	nowUnix := time.Now().Unix()
+	if int64(s.ProducedAt) > nowUnix {
+		return fmt.Errorf("%w: produced in the future at %s", ErrStaleRevocationStatus,
+			s.ProducedAt.StdTime().Format(time.RFC3339))
+	}
+	if int64(s.NextUpdate) < nowUnix {
+		return fmt.Errorf("%w: expired at %s", ErrStaleRevocationStatus, s.NextUpdate.StdTime().Format(time.RFC3339))
+	}
+	tbs, err := s.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(issuerKey, tbs, s.Signature) {
+		return newValidationError(ErrBadSignature, cert, "Signature validation of revocation status failed")
+	}
+	return nil
+}
+
+// ValidateStapled validates a bundle of certificates like ValidateBundle and additionally verifies
+// the stapled RevocationStatus of the leaf certificate against its issuer in the validated chain,
+// which is either an intermediate of the bundle or a root of the CertPool.
+func (c *CertPool) ValidateStapled(certBundle []*Certificate, status *RevocationStatus) (*Certificate, error) {
+	v := &chainVerifier{}
+	leaf, err := c.validateBundle(certBundle, v)
+	if err != nil {
+		return nil, err
+	}
+	if status == nil {
+		return nil, errors.New("No revocation status has been stapled")
+	}
+	issuerCert := v.issuerOf(leaf)
+	if issuerCert == nil {
+		return nil, newValidationError(ErrUnknownIssuer, leaf, "certificate is not signed by a known issuer")
+	}
+	if err := status.Verify(leaf, issuerCert.PubKey); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
@@ -0,0 +1,84 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// validateStapled validates cert on its own with ValidateStapled
+func validateStapled(pool *CertPool, cert *Certificate, status *RevocationStatus) error {
+	_, err := pool.ValidateStapled([]*Certificate{cert}, status)
+	return err
+}
+
+func TestStapledRevocationStatus(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, otherKey, err := SelfSignedCertificate("other", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	serverCert, _, err := ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	otherCert, _, err := ServerCertificate("server2", 3, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	status, err := NewRevocationStatus(serverCert, time.Hour, rootKey)
+	require.NoError(t, err)
+
+	buf, err := status.Bytes()
+	require.NoError(t, err)
+	status, err = ParseRevocationStatus(buf)
+	require.NoError(t, err)
+
+	assert.NoError(t, validateStapled(pool, serverCert, status))
+	assert.Error(t, validateStapled(pool, otherCert, status))
+	assert.Error(t, validateStapled(pool, serverCert, nil))
+
+	forged, err := NewRevocationStatus(serverCert, time.Hour, otherKey)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(validateStapled(pool, serverCert, forged), ErrBadSignature))
+
+	// Signatures are bound to the revocation status context
+	tbs, err := status.tbsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, revocationStatusContext, tbs[:len(revocationStatusContext)])
+	bare := *status
+	bare.Signature = ed25519.Sign(rootKey, tbs[len(revocationStatusContext):])
+	assert.True(t, errors.Is(validateStapled(pool, serverCert, &bare), ErrBadSignature))
+
+	stale, err := NewRevocationStatus(serverCert, -time.Minute, rootKey)
+	require.NoError(t, err)
+	assert.True(t, errors.Is(validateStapled(pool, serverCert, stale), ErrStaleRevocationStatus))
+}
+
+func TestStapledRevocationStatusOfIntermediateIssuer(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	serverCert, _, err := ServerCertificate("server", 3, time.Time{}, time.Time{}, nil, intermediateKey, intermediate.Subject)
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+	bundle := []*Certificate{serverCert, intermediate}
+
+	status, err := NewRevocationStatus(serverCert, time.Hour, intermediateKey)
+	require.NoError(t, err)
+	leaf, err := pool.ValidateStapled(bundle, status)
+	require.NoError(t, err)
+	assert.Equal(t, serverCert, leaf)
+
+	// The status needs to be signed by the issuer of the leaf, not by the root
+	byRoot, err := NewRevocationStatus(serverCert, time.Hour, rootKey)
+	require.NoError(t, err)
+	_, err = pool.ValidateStapled(bundle, byRoot)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+
+	_, err = pool.ValidateStapled([]*Certificate{serverCert}, status)
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNotFound is returned by a Store if the requested entry doesn't exist
+	ErrNotFound = errors.New("entry not found in store")
+	// ErrSerialNumberExists is returned by a Store if a certificate with the same serial number
+	// has already been stored
+	ErrSerialNumberExists = errors.New("serial number has already been issued")
+)
+
+// SerialNumberCollisionError is returned if a serial number has already been used by an issuer,
+// i.e. because of a misconfigured serial source. It wraps ErrSerialNumberExists.
+type SerialNumberCollisionError struct {
+	Issuer       string
+	SerialNumber uint64
+	// Existing is the certificate which has been issued with the serial number before, if known
+	Existing *Certificate
+}
+
+func (e *SerialNumberCollisionError) Error() string {
+	if e.Existing != nil {
+		return fmt.Sprintf("%s: %d of '%s' has been issued to '%s'", ErrSerialNumberExists, e.SerialNumber, e.Issuer,
+			e.Existing.Subject)
+	}
+	return fmt.Sprintf("%s: %d of '%s'", ErrSerialNumberExists, e.SerialNumber, e.Issuer)
+}
+
+// Unwrap returns ErrSerialNumberExists
+func (e *SerialNumberCollisionError) Unwrap() error {
+	return ErrSerialNumberExists
+}
+
+// Store persists the state of a CA. Serial numbers are unique per issuer, so every issuer needs
+// its own Store. Implementations need to be safe for concurrent use, so that they can be shared
+// by several instances of the same CA, i.e. by database transactions.
+type Store interface {
+	// StoreCertificate persists an issued certificate. It must fail with an error wrapping
+	// ErrSerialNumberExists, preferably a *SerialNumberCollisionError, if a certificate with the
+	// same serial number has been stored before.
+	StoreCertificate(cert *Certificate) error
+	// Certificate returns the certificate with the given serial number or ErrNotFound
+	Certificate(serialNumber uint64) (*Certificate, error)
+	// CertificatesBySubject returns all certificates issued for subject, ordered by serial number
+	CertificatesBySubject(subject string) ([]*Certificate, error)
+	// StoreRevocation persists the revocation of a certificate
+	StoreRevocation(entry RevokedCertificate) error
+	// Revocation returns the revocation of the certificate with the given serial number or ErrNotFound
+	Revocation(serialNumber uint64) (RevokedCertificate, error)
+	// Revocations returns all revocations, ordered by serial number
+	Revocations() ([]RevokedCertificate, error)
+	// NextCRLNumber returns a number higher than all numbers returned before
+	NextCRLNumber() (uint64, error)
+}
+
+// BatchStore is a Store which can persist many certificates with a single write. CA.IssueBatch
+// uses it to amortize the cost of writes, i.e. of database transactions.
+type BatchStore interface {
+	Store
+	// StoreCertificates persists all certs or none of them. Like StoreCertificate it must fail
+	// with an error wrapping ErrSerialNumberExists if any of the serial numbers has been stored
+	// before or is used twice in certs.
+	StoreCertificates(certs []*Certificate) error
+}
+
+// MemoryStore is a Store keeping all entries in memory. It is used by CAs by default.
+type MemoryStore struct {
+	lock      sync.RWMutex
+	certs     map[uint64]*Certificate
+	revoked   map[uint64]RevokedCertificate
+	crlNumber uint64
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		certs:   map[uint64]*Certificate{},
+		revoked: map[uint64]RevokedCertificate{},
+	}
+}
+
+// StoreCertificate implements Store
+func (s *MemoryStore) StoreCertificate(cert *Certificate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if existing, exists := s.certs[cert.SerialNumber]; exists {
+		return &SerialNumberCollisionError{Issuer: cert.Issuer, SerialNumber: cert.SerialNumber, Existing: existing}
+	}
+	s.certs[cert.SerialNumber] = cert
+	return nil
+}
+
+// StoreCertificates implements BatchStore
+func (s *MemoryStore) StoreCertificates(certs []*Certificate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	batch := make(map[uint64]*Certificate, len(certs))
+	for _, cert := range certs {
+		existing, exists := s.certs[cert.SerialNumber]
+		if !exists {
+			existing, exists = batch[cert.SerialNumber]
+		}
+		if exists {
+			return &SerialNumberCollisionError{Issuer: cert.Issuer, SerialNumber: cert.SerialNumber, Existing: existing}
+		}
+		batch[cert.SerialNumber] = cert
+	}
+	for serialNumber, cert := range batch {
+		s.certs[serialNumber] = cert
+	}
+	return nil
+}
+
+// Certificate implements Store
+func (s *MemoryStore) Certificate(serialNumber uint64) (*Certificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	cert, found := s.certs[serialNumber]
+	if !found {
+		return nil, ErrNotFound
+	}
+	return cert, nil
+}
+
+// CertificatesBySubject implements Store
+func (s *MemoryStore) CertificatesBySubject(subject string) ([]*Certificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	certs := []*Certificate{}
+	for _, cert := range s.certs {
+		if cert.Subject == subject {
+			certs = append(certs, cert)
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].SerialNumber < certs[j].SerialNumber
+	})
+	return certs, nil
+}
+
+// StoreRevocation implements Store
+func (s *MemoryStore) StoreRevocation(entry RevokedCertificate) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.revoked[entry.SerialNumber] = entry
+	return nil
+}
+
+// Revocation implements Store
+func (s *MemoryStore) Revocation(serialNumber uint64) (RevokedCertificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	entry, found := s.revoked[serialNumber]
+	if !found {
+		return RevokedCertificate{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+// Revocations implements Store
+func (s *MemoryStore) Revocations() ([]RevokedCertificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	revoked := make([]RevokedCertificate, 0, len(s.revoked))
+	for _, entry := range s.revoked {
+		revoked = append(revoked, entry)
+	}
+	sort.Slice(revoked, func(i, j int) bool {
+		return revoked[i].SerialNumber < revoked[j].SerialNumber
+	})
+	return revoked, nil
+}
+
+// NextCRLNumber implements Store
+func (s *MemoryStore) NextCRLNumber() (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.crlNumber++
+	return s.crlNumber, nil
+}
@@ -0,0 +1,80 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	store := NewMemoryStore()
+	var certs []*Certificate
+	for _, serial := range []uint64{5, 2, 9} {
+		subject := "device"
+		if serial == 9 {
+			subject = "other"
+		}
+		cert, _, err := ClientCertificate(subject, serial, time.Time{}, time.Time{}, nil, rootKey, "root")
+		require.NoError(t, err)
+		require.NoError(t, store.StoreCertificate(cert))
+		certs = append(certs, cert)
+	}
+	err = store.StoreCertificate(certs[0])
+	assert.True(t, errors.Is(err, ErrSerialNumberExists))
+	var collision *SerialNumberCollisionError
+	require.True(t, errors.As(err, &collision))
+	assert.Equal(t, uint64(5), collision.SerialNumber)
+	assert.Equal(t, "root", collision.Issuer)
+	assert.Equal(t, certs[0], collision.Existing)
+
+	// Batches are stored completely or not at all
+	batch := make([]*Certificate, 2)
+	for i, serial := range []uint64{11, 12} {
+		batch[i], _, err = ClientCertificate("batch", serial, time.Time{}, time.Time{}, nil, rootKey, "root")
+		require.NoError(t, err)
+	}
+	assert.True(t, errors.Is(store.StoreCertificates(append(batch, certs[1])), ErrSerialNumberExists))
+	assert.True(t, errors.Is(store.StoreCertificates([]*Certificate{batch[0], batch[0]}), ErrSerialNumberExists))
+	_, err = store.Certificate(11)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	require.NoError(t, store.StoreCertificates(batch))
+	bySubject, err := store.CertificatesBySubject("batch")
+	require.NoError(t, err)
+	assert.Equal(t, batch, bySubject)
+
+	cert, err := store.Certificate(2)
+	require.NoError(t, err)
+	assert.Equal(t, certs[1], cert)
+	_, err = store.Certificate(3)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	bySubject, err = store.CertificatesBySubject("device")
+	require.NoError(t, err)
+	assert.Equal(t, []*Certificate{certs[1], certs[0]}, bySubject)
+	bySubject, err = store.CertificatesBySubject("unknown")
+	require.NoError(t, err)
+	assert.Empty(t, bySubject)
+
+	require.NoError(t, store.StoreRevocation(RevokedCertificate{SerialNumber: 9}))
+	require.NoError(t, store.StoreRevocation(RevokedCertificate{SerialNumber: 2}))
+	_, err = store.Revocation(5)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	entry, err := store.Revocation(9)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(9), entry.SerialNumber)
+	revoked, err := store.Revocations()
+	require.NoError(t, err)
+	require.Len(t, revoked, 2)
+	assert.Equal(t, uint64(2), revoked[0].SerialNumber)
+
+	n1, err := store.NextCRLNumber()
+	require.NoError(t, err)
+	n2, err := store.NextCRLNumber()
+	require.NoError(t, err)
+	assert.True(t, n2 > n1)
+}
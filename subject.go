@@ -0,0 +1,154 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Well known keys of SubjectAttributes
+const (
+	SubjectOrganization = "org"
+	SubjectUnit         = "unit"
+	SubjectDeviceID     = "device-id"
+	SubjectSerial       = "serial"
+)
+
+// SubjectAttribute is a single key value pair of SubjectAttributes
+type SubjectAttribute struct {
+	_ struct{} `cbor:",toarray"`
+
+	Key   string `cbor:"key"`
+	Value string `cbor:"value"`
+}
+
+// SubjectAttributes is a structured subject, attached to a certificate in addition to the flat
+// Subject string via a SubjectAttributes extension. The attributes are ordered, every key may only
+// occur once. Keys should be short, i.e. SubjectOrganization or SubjectDeviceID.
+type SubjectAttributes []SubjectAttribute
+
+// NewSubjectAttributes creates SubjectAttributes from alternating keys and values
+func NewSubjectAttributes(keyValues ...string) (SubjectAttributes, error) {
+	if len(keyValues)%2 != 0 {
+		return nil, errors.New("SubjectAttributes require a value for every key")
+	}
+	attrs := SubjectAttributes{}
+	for i := 0; i < len(keyValues); i += 2 {
+		attrs = append(attrs, SubjectAttribute{Key: keyValues[i], Value: keyValues[i+1]})
+	}
+	if err := attrs.check(); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+func (s SubjectAttributes) check() error {
+	seen := make(map[string]bool, len(s))
+	for _, attr := range s {
+		if attr.Key == "" {
+			return errors.New("SubjectAttributes can't contain empty keys")
+		}
+		if seen[attr.Key] {
+			return fmt.Errorf("SubjectAttributes contain the key %q more than once", attr.Key)
+		}
+		seen[attr.Key] = true
+	}
+	return nil
+}
+
+// Get returns the value of the attribute with the given key
+func (s SubjectAttributes) Get(key string) (string, bool) {
+	for _, attr := range s {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// Equal is true if both SubjectAttributes contain the same attributes in the same order
+func (s SubjectAttributes) Equal(other SubjectAttributes) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for i := range s {
+		if s[i].Key != other[i].Key || s[i].Value != other[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains is true if every attribute of other is also part of s, regardless of the order
+func (s SubjectAttributes) Contains(other SubjectAttributes) bool {
+	for _, attr := range other {
+		val, found := s.Get(attr.Key)
+		if !found || val != attr.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the attributes in the form key=value,key=value. Commas, equal signs and
+// backslashes in keys and values are escaped with a backslash.
+func (s SubjectAttributes) String() string {
+	escaper := strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`)
+	parts := make([]string, len(s))
+	for i, attr := range s {
+		parts[i] = escaper.Replace(attr.Key) + "=" + escaper.Replace(attr.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Bytes returns the canonical CBOR encoding of the attributes
+func (s SubjectAttributes) Bytes() ([]byte, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	if s == nil {
+		s = SubjectAttributes{}
+	}
+	return cborEm.Marshal(s)
+}
+
+// SubjectAttributesExtension creates a SubjectAttributes Extension
+func SubjectAttributesExtension(attrs SubjectAttributes) (Extension, error) {
+	val, err := attrs.Bytes()
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDSubjectAttributes,
+		Critical: false,
+		Value:    val,
+	}, nil
+}
+
+// ParseSubjectAttributes parses SubjectAttributes from a byte slice, i.e. the Value of an Extension
+func ParseSubjectAttributes(in []byte) (SubjectAttributes, error) {
+	var attrs SubjectAttributes
+	if err := cbor.Unmarshal(in, &attrs); err != nil {
+		return nil, fmt.Errorf("Failed to parse SubjectAttributes: %w", err)
+	}
+	if err := attrs.check(); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// SubjectAttributes returns the structured subject of the certificate. If the certificate has no
+// SubjectAttributes extension nil is returned.
+func (c *Certificate) SubjectAttributes() (SubjectAttributes, error) {
+	var attrs SubjectAttributes
+	err := RequiresExtension(c, OIDSubjectAttributes, func(critical bool, val []byte) (err error) {
+		attrs, err = ParseSubjectAttributes(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return attrs, err
+}
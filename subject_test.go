@@ -0,0 +1,77 @@
+package smolcert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectAttributes(t *testing.T) {
+	attrs, err := NewSubjectAttributes(SubjectOrganization, "acme", SubjectUnit, "sensors", SubjectDeviceID, "42")
+	require.NoError(t, err)
+
+	val, found := attrs.Get(SubjectUnit)
+	assert.True(t, found)
+	assert.Equal(t, "sensors", val)
+	_, found = attrs.Get(SubjectSerial)
+	assert.False(t, found)
+	assert.Equal(t, "org=acme,unit=sensors,device-id=42", attrs.String())
+
+	reordered, err := NewSubjectAttributes(SubjectUnit, "sensors", SubjectOrganization, "acme", SubjectDeviceID, "42")
+	require.NoError(t, err)
+	assert.True(t, attrs.Equal(attrs))
+	assert.False(t, attrs.Equal(reordered))
+	assert.True(t, attrs.Contains(reordered))
+	assert.True(t, reordered.Contains(SubjectAttributes{{Key: SubjectOrganization, Value: "acme"}}))
+	assert.False(t, reordered.Contains(SubjectAttributes{{Key: SubjectOrganization, Value: "other"}}))
+
+	// The encoding keeps the order of the attributes
+	buf, err := attrs.Bytes()
+	require.NoError(t, err)
+	reorderedBuf, err := reordered.Bytes()
+	require.NoError(t, err)
+	assert.NotEqual(t, buf, reorderedBuf)
+	parsed, err := ParseSubjectAttributes(buf)
+	require.NoError(t, err)
+	assert.True(t, attrs.Equal(parsed))
+
+	_, err = NewSubjectAttributes(SubjectOrganization, "acme", SubjectOrganization, "other")
+	assert.Error(t, err)
+	_, err = NewSubjectAttributes(SubjectOrganization)
+	assert.Error(t, err)
+	_, err = NewSubjectAttributes("", "value")
+	assert.Error(t, err)
+	dup, err := cborEm.Marshal([]SubjectAttribute{{Key: "org", Value: "a"}, {Key: "org", Value: "b"}})
+	require.NoError(t, err)
+	_, err = ParseSubjectAttributes(dup)
+	assert.Error(t, err)
+
+	escaped := SubjectAttributes{{Key: SubjectOrganization, Value: "a,b=c"}}
+	assert.Equal(t, `org=a\,b\=c`, escaped.String())
+}
+
+func TestCertificateSubjectAttributes(t *testing.T) {
+	attrs, err := NewSubjectAttributes(SubjectOrganization, "acme", SubjectSerial, "0815")
+	require.NoError(t, err)
+	ext, err := SubjectAttributesExtension(attrs)
+	require.NoError(t, err)
+	cert, _, err := SelfSignedCertificate("device", time.Time{}, time.Time{}, []Extension{ext})
+	require.NoError(t, err)
+
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+	parsedCert, err := ParseBuf(buf)
+	require.NoError(t, err)
+	parsed, err := parsedCert.SubjectAttributes()
+	require.NoError(t, err)
+	assert.True(t, attrs.Equal(parsed))
+	assert.NoError(t, NewCertPool(cert).Validate(parsedCert))
+
+	plain, _, err := SelfSignedCertificate("device", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	parsed, err = plain.SubjectAttributes()
+	assert.NoError(t, err)
+	assert.Nil(t, parsed)
+}
@@ -0,0 +1,190 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Tenant is an independent CA hierarchy of a MultiTenantCA. Serial numbers, policies and state of
+// a tenant are those of its CA, so every tenant needs its own CA with its own Store.
+type Tenant struct {
+	// ID identifies the tenant, it may contain letters, digits, '.', '-' and '_'
+	ID string
+	// CA issues the certificates of the tenant. Its options, ValidityPolicy and hooks are the
+	// issuance policy of the tenant.
+	CA *CA
+	// Intermediates are the certificates between the certificate of the CA and the roots
+	Intermediates []*Certificate
+	// Roots are trusted for certificates of the tenant. If empty, the certificate of the CA is
+	// the only root.
+	Roots []*Certificate
+
+	pool *CertPool
+}
+
+// Chain returns the certificate of the CA followed by the intermediates, as needed by clients to
+// build a chain to the roots of the tenant
+func (t *Tenant) Chain() []*Certificate {
+	return append([]*Certificate{t.CA.Certificate}, t.Intermediates...)
+}
+
+// Pool returns a CertPool of the roots of the tenant
+func (t *Tenant) Pool() *CertPool {
+	return t.pool
+}
+
+// MultiTenantCA manages the CA hierarchies of several tenants, i.e. for platforms issuing device
+// certificates on behalf of their customers. Tenants are isolated: every tenant has its own CA key,
+// serial numbers and Store, and certificates are only valid within the hierarchy of their tenant.
+type MultiTenantCA struct {
+	lock    sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// NewMultiTenantCA creates a MultiTenantCA without tenants
+func NewMultiTenantCA() *MultiTenantCA {
+	return &MultiTenantCA{tenants: map[string]*Tenant{}}
+}
+
+// AddTenant adds tenant. The certificate of its CA needs to chain to the roots via the
+// intermediates, and the CA key can't be used by another tenant.
+func (m *MultiTenantCA) AddTenant(tenant *Tenant) error {
+	if tenant.CA == nil {
+		return errors.New("Tenant needs a CA")
+	}
+	if err := checkTenantID(tenant.ID); err != nil {
+		return err
+	}
+	t := &Tenant{
+		ID:            tenant.ID,
+		CA:            tenant.CA,
+		Intermediates: append([]*Certificate{}, tenant.Intermediates...),
+		Roots:         append([]*Certificate{}, tenant.Roots...),
+	}
+	if len(t.Roots) == 0 {
+		t.Roots = []*Certificate{t.CA.Certificate}
+	}
+	t.pool = NewCertPool(t.Roots...)
+	if !t.isRoot(t.CA.Certificate) {
+		if _, err := NewBundle(t.Chain()...).Validate(t.pool); err != nil {
+			return fmt.Errorf("CA certificate of tenant '%s' doesn't chain to its roots: %w", t.ID, err)
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.tenants[t.ID]; exists {
+		return fmt.Errorf("Tenant '%s' already exists", t.ID)
+	}
+	for _, other := range m.tenants {
+		if other.CA == t.CA || bytes.Equal(other.CA.Certificate.PubKey, t.CA.Certificate.PubKey) {
+			return fmt.Errorf("CA of tenant '%s' is already used by tenant '%s'", t.ID, other.ID)
+		}
+	}
+	m.tenants[t.ID] = t
+	return nil
+}
+
+func (t *Tenant) isRoot(cert *Certificate) bool {
+	for _, root := range t.Roots {
+		if bytes.Equal(root.Signature, cert.Signature) && bytes.Equal(root.PubKey, cert.PubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveTenant removes the tenant with id. Its Store is left untouched.
+func (m *MultiTenantCA) RemoveTenant(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exists := m.tenants[id]; !exists {
+		return fmt.Errorf("%w: tenant '%s'", ErrNotFound, id)
+	}
+	delete(m.tenants, id)
+	return nil
+}
+
+// Tenant returns the tenant with id, or ErrNotFound
+func (m *MultiTenantCA) Tenant(id string) (*Tenant, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	t, exists := m.tenants[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: tenant '%s'", ErrNotFound, id)
+	}
+	return t, nil
+}
+
+// Tenants returns the IDs of all tenants in ascending order
+func (m *MultiTenantCA) Tenants() []string {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	ids := make([]string, 0, len(m.tenants))
+	for id := range m.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Issue issues a certificate with the CA of tenant, like CA.Issue
+func (m *MultiTenantCA) Issue(tenant, subject string, pubKey ed25519.PublicKey, opts ...IssueOption) (*Certificate, error) {
+	t, err := m.Tenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return t.CA.Issue(subject, pubKey, opts...)
+}
+
+// IssueFromCSR issues a certificate for csr with the CA of tenant, like CA.IssueFromCSR
+func (m *MultiTenantCA) IssueFromCSR(tenant string, csr *CertificateRequest, opts ...IssueOption) (*Certificate, error) {
+	t, err := m.Tenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return t.CA.IssueFromCSR(csr, opts...)
+}
+
+// Revoke revokes a certificate issued by the CA of tenant
+func (m *MultiTenantCA) Revoke(tenant string, serialNumber uint64, reason RevocationReason) error {
+	t, err := m.Tenant(tenant)
+	if err != nil {
+		return err
+	}
+	return t.CA.Revoke(serialNumber, reason)
+}
+
+// GenerateCRL creates a CRL of the CA of tenant
+func (m *MultiTenantCA) GenerateCRL(tenant string) (*CRL, error) {
+	t, err := m.Tenant(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return t.CA.GenerateCRL()
+}
+
+// Validate validates cert against the hierarchy of tenant. Certificates of other tenants are
+// rejected, even if their subjects and issuers have the same names.
+func (m *MultiTenantCA) Validate(tenant string, cert *Certificate) error {
+	t, err := m.Tenant(tenant)
+	if err != nil {
+		return err
+	}
+	_, err = NewBundle(append([]*Certificate{cert}, t.Chain()...)...).Validate(t.pool)
+	return err
+}
+
+// checkTenantID restricts tenant IDs like the names of a DirKeyStore, so they can be used to name
+// files and key store entries
+func checkTenantID(id string) error {
+	if err := checkKeyStoreName(id); err != nil {
+		return fmt.Errorf("Invalid tenant ID '%s'", id)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestMultiTenantCA(t *testing.T) {
+	m := NewMultiTenantCA()
+
+	// Tenant a issues with its root, tenant b with an intermediate. Both roots have the same name.
+	rootA, keyA, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	caA, err := NewCA(rootA, keyA)
+	require.NoError(t, err)
+	require.NoError(t, m.AddTenant(&Tenant{ID: "tenant-a", CA: caA}))
+
+	rootB, keyB, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	rootCAB, err := NewCA(rootB, keyB)
+	require.NoError(t, err)
+	intPub, intKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	intCert, err := rootCAB.Issue("issuing", intPub, WithKeyUsage(KeyUsageSignCert))
+	require.NoError(t, err)
+	caB, err := NewCA(intCert, intKey)
+	require.NoError(t, err)
+	assert.Error(t, m.AddTenant(&Tenant{ID: "tenant-b", CA: caB, Roots: []*Certificate{rootA}}))
+	require.NoError(t, m.AddTenant(&Tenant{ID: "tenant-b", CA: caB, Roots: []*Certificate{rootB}}))
+
+	assert.Error(t, m.AddTenant(&Tenant{ID: "tenant-a", CA: rootCAB}), "duplicate ID")
+	assert.Error(t, m.AddTenant(&Tenant{ID: "tenant-c", CA: caA}), "CA shared with tenant-a")
+	assert.Error(t, m.AddTenant(&Tenant{ID: "../c", CA: rootCAB}))
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, m.Tenants())
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	certA, err := m.Issue("tenant-a", "device", pubKey)
+	require.NoError(t, err)
+	certB, err := m.Issue("tenant-b", "device", pubKey)
+	require.NoError(t, err)
+	assert.Equal(t, "issuing", certB.Issuer)
+
+	assert.NoError(t, m.Validate("tenant-a", certA))
+	assert.NoError(t, m.Validate("tenant-b", certB))
+	assert.Error(t, m.Validate("tenant-b", certA))
+	assert.Error(t, m.Validate("tenant-a", certB))
+
+	// Serial numbers and revocations are kept per tenant
+	_, err = m.Issue("tenant-b", "device", pubKey, WithSerialNumber(certA.SerialNumber))
+	assert.NoError(t, err)
+	assert.Error(t, m.Revoke("tenant-b", certB.SerialNumber+1, RevocationReasonUnspecified))
+	require.NoError(t, m.Revoke("tenant-a", certA.SerialNumber, RevocationReasonKeyCompromise))
+	crlA, err := m.GenerateCRL("tenant-a")
+	require.NoError(t, err)
+	assert.Len(t, crlA.Revoked, 1)
+	crlB, err := m.GenerateCRL("tenant-b")
+	require.NoError(t, err)
+	assert.Empty(t, crlB.Revoked)
+	assert.NoError(t, crlB.Verify(intCert))
+
+	tenantB, err := m.Tenant("tenant-b")
+	require.NoError(t, err)
+	assert.Equal(t, []*Certificate{intCert}, tenantB.Chain())
+	assert.NoError(t, tenantB.Pool().Validate(intCert))
+
+	require.NoError(t, m.RemoveTenant("tenant-a"))
+	_, err = m.Issue("tenant-a", "device", pubKey)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(m.RemoveTenant("tenant-a"), ErrNotFound))
+	assert.Equal(t, []string{"tenant-b"}, m.Tenants())
+}
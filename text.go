@@ -0,0 +1,222 @@
+package smolcert
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Prefixes of the compact textual encoding, which identify the alphabet of the encoded data
+const (
+	// TextPrefixBase45 prefixes Base45 encoded data. Like the data the prefix only uses characters
+	// of the alphanumeric mode of QR codes.
+	TextPrefixBase45 = "SC45:"
+	// TextPrefixBase64 prefixes unpadded base64url encoded data
+	TextPrefixBase64 = "SC64:"
+)
+
+// MaxTextSize is the maximum size of decoded data, limiting the memory used to decompress untrusted
+// input
+const MaxTextSize = 64 * 1024
+
+// zlibHeader is the first byte written by zlib with the default window size. CBOR encoded
+// certificates and bundles are arrays, so compressed data can't be confused with uncompressed CBOR.
+const zlibHeader = 0x78
+
+type textOptions struct {
+	base64   bool
+	compress bool
+}
+
+// TextOption configures the compact textual encoding
+type TextOption func(o *textOptions)
+
+// Base64Text encodes data with unpadded base64url instead of Base45. The result is about 20%
+// shorter, but can't be stored in the alphanumeric mode of QR codes. It suits text which is copied
+// manually or sent in URLs.
+func Base64Text() TextOption {
+	return func(o *textOptions) {
+		o.base64 = true
+	}
+}
+
+// CompressedText compresses data with zlib before encoding it. This pays off for bundles and
+// certificates with larger extensions, DecodeText detects compressed data by itself.
+func CompressedText() TextOption {
+	return func(o *textOptions) {
+		o.compress = true
+	}
+}
+
+// EncodeText encodes CBOR data, i.e. an encoded certificate or bundle, into a compact textual form
+// suited for QR codes, for out-of-band provisioning of devices. By default the data is Base45
+// (RFC 9285) encoded, which is stored efficiently in the alphanumeric mode of QR codes.
+func EncodeText(data []byte, opts ...TextOption) (string, error) {
+	o := &textOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if len(data) > 0 && data[0] == zlibHeader {
+		return "", errors.New("Only CBOR arrays and maps can be encoded as text")
+	}
+	if o.compress {
+		buf := &bytes.Buffer{}
+		w, err := zlib.NewWriterLevel(buf, zlib.BestCompression)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(data); err != nil {
+			return "", err
+		}
+		if err := w.Close(); err != nil {
+			return "", err
+		}
+		data = buf.Bytes()
+	}
+	if o.base64 {
+		return TextPrefixBase64 + base64.RawURLEncoding.EncodeToString(data), nil
+	}
+	return TextPrefixBase45 + encodeBase45(data), nil
+}
+
+// DecodeText decodes data encoded with EncodeText and decompresses it if necessary
+func DecodeText(s string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case strings.HasPrefix(s, TextPrefixBase45):
+		data, err = decodeBase45(s[len(TextPrefixBase45):])
+	case strings.HasPrefix(s, TextPrefixBase64):
+		data, err = base64.RawURLEncoding.DecodeString(s[len(TextPrefixBase64):])
+	default:
+		return nil, errors.New("Text doesn't start with a known prefix")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || data[0] != zlibHeader {
+		return data, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid compressed data: %w", err)
+	}
+	defer r.Close()
+	data, err = ioutil.ReadAll(io.LimitReader(r, MaxTextSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid compressed data: %w", err)
+	}
+	if len(data) > MaxTextSize {
+		return nil, fmt.Errorf("Decompressed data exceeds %d bytes", MaxTextSize)
+	}
+	return data, nil
+}
+
+// EncodeText encodes the certificate with EncodeText
+func (c *Certificate) EncodeText(opts ...TextOption) (string, error) {
+	buf, err := c.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return EncodeText(buf, opts...)
+}
+
+// MarshalText implements encoding.TextMarshaler with the compact textual encoding, so
+// certificates can be embedded in JSON, YAML or command line flags. Base64url is used, since
+// unlike Base45 it contains no spaces.
+func (c *Certificate) MarshalText() ([]byte, error) {
+	s, err := c.EncodeText(Base64Text())
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler and accepts every form produced by
+// Certificate.EncodeText. If the text is invalid, c is left unchanged.
+func (c *Certificate) UnmarshalText(text []byte) error {
+	buf, err := DecodeText(string(text))
+	if err != nil {
+		return err
+	}
+	return c.UnmarshalBinary(buf)
+}
+
+// ParseCertificateText parses a certificate encoded with Certificate.EncodeText
+func ParseCertificateText(s string) (*Certificate, error) {
+	buf, err := DecodeText(s)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBuf(buf)
+}
+
+// EncodeText encodes the Bundle with EncodeText
+func (b *Bundle) EncodeText(opts ...TextOption) (string, error) {
+	buf, err := b.Bytes()
+	if err != nil {
+		return "", err
+	}
+	return EncodeText(buf, opts...)
+}
+
+// ParseBundleText parses a Bundle encoded with Bundle.EncodeText
+func ParseBundleText(s string) (*Bundle, error) {
+	buf, err := DecodeText(s)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBundleBuf(buf)
+}
+
+const base45Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// encodeBase45 encodes data as specified by RFC 9285, every two bytes are encoded as three
+// characters
+func encodeBase45(data []byte) string {
+	out := make([]byte, 0, (len(data)+1)/2*3)
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])<<8 | int(data[i+1])
+		out = append(out, base45Alphabet[n%45], base45Alphabet[n/45%45], base45Alphabet[n/2025])
+	}
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		out = append(out, base45Alphabet[n%45], base45Alphabet[n/45])
+	}
+	return string(out)
+}
+
+func decodeBase45(s string) ([]byte, error) {
+	if len(s)%3 == 1 {
+		return nil, errors.New("Invalid length of Base45 data")
+	}
+	digits := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		digits[i] = strings.IndexByte(base45Alphabet, s[i])
+		if digits[i] < 0 {
+			return nil, fmt.Errorf("Invalid Base45 character '%c'", s[i])
+		}
+	}
+	out := make([]byte, 0, len(s)/3*2+1)
+	for i := 0; i < len(digits); i += 3 {
+		if i+2 >= len(digits) {
+			n := digits[i] + digits[i+1]*45
+			if n > 0xff {
+				return nil, errors.New("Invalid Base45 data")
+			}
+			out = append(out, byte(n))
+			break
+		}
+		n := digits[i] + digits[i+1]*45 + digits[i+2]*2025
+		if n > 0xffff {
+			return nil, errors.New("Invalid Base45 data")
+		}
+		out = append(out, byte(n>>8), byte(n))
+	}
+	return out, nil
+}
@@ -0,0 +1,132 @@
+package smolcert
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBase45(t *testing.T) {
+	// Test vectors of RFC 9285
+	for decoded, encoded := range map[string]string{
+		"AB":      "BB8",
+		"Hello!!": "%69 VD92EX0",
+		"base-45": "UJCLQE7W581",
+		"ietf!":   "QED8WEX0",
+		"":        "",
+	} {
+		assert.Equal(t, encoded, encodeBase45([]byte(decoded)))
+		buf, err := decodeBase45(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, decoded, string(buf))
+	}
+
+	for _, invalid := range []string{"A", "GGW", "ZZ", "ab8", "BB8A"} {
+		_, err := decodeBase45(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCertificateText(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	caps, err := CapabilitiesExtension("sensor/temperature/read", "sensor/temperature/write", "sensor/humidity/read")
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 42, time.Time{}, time.Time{}, []Extension{caps}, rootKey, "root")
+	require.NoError(t, err)
+
+	for _, opts := range [][]TextOption{nil, {CompressedText()}, {Base64Text()}, {Base64Text(), CompressedText()}} {
+		text, err := cert.EncodeText(opts...)
+		require.NoError(t, err)
+		parsed, err := ParseCertificateText(text)
+		require.NoError(t, err)
+		assert.Equal(t, cert.Signature, parsed.Signature)
+		assert.NoError(t, NewCertPool(rootCert).Validate(parsed))
+	}
+
+	text, err := cert.EncodeText()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(text, TextPrefixBase45))
+	// Base45 text only uses the characters of the alphanumeric mode of QR codes
+	for _, c := range text {
+		assert.True(t, strings.ContainsRune(base45Alphabet, c), "%c", c)
+	}
+	compressed, err := cert.EncodeText(CompressedText())
+	require.NoError(t, err)
+	assert.Less(t, len(compressed), len(text))
+	b64, err := cert.EncodeText(Base64Text())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(b64, TextPrefixBase64))
+	assert.Less(t, len(b64), len(text))
+
+	bundleText, err := NewBundle(cert).EncodeText(CompressedText())
+	require.NoError(t, err)
+	bundle, err := ParseBundleText(bundleText)
+	require.NoError(t, err)
+	require.Len(t, bundle.Certificates, 1)
+	assert.Equal(t, cert.Signature, bundle.Certificates[0].Signature)
+}
+
+func TestCertificateTextMarshaler(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 42, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	text, err := cert.MarshalText()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(text), TextPrefixBase64))
+	assert.NotContains(t, string(text), " ")
+
+	type config struct {
+		Root  *Certificate   `json:"root"`
+		Certs []*Certificate `json:"certs"`
+	}
+	buf, err := json.Marshal(config{Root: rootCert, Certs: []*Certificate{cert}})
+	require.NoError(t, err)
+	var parsed config
+	require.NoError(t, json.Unmarshal(buf, &parsed))
+	require.Len(t, parsed.Certs, 1)
+	assert.NoError(t, NewCertPool(parsed.Root).Validate(parsed.Certs[0]))
+
+	// All forms of EncodeText are accepted
+	base45, err := cert.EncodeText(CompressedText())
+	require.NoError(t, err)
+	unmarshaled := &Certificate{}
+	require.NoError(t, unmarshaled.UnmarshalText([]byte(base45)))
+	assert.Equal(t, cert.Signature, unmarshaled.Signature)
+
+	assert.Error(t, unmarshaled.UnmarshalText([]byte("invalid")))
+	assert.Error(t, json.Unmarshal([]byte(`{"root":"SC64:AAAA"}`), &parsed))
+}
+
+func TestDecodeTextRejects(t *testing.T) {
+	_, err := DecodeText("HC1:BB8")
+	assert.Error(t, err)
+	_, err = DecodeText(TextPrefixBase45 + "A")
+	assert.Error(t, err)
+	_, err = DecodeText(TextPrefixBase64 + "!")
+	assert.Error(t, err)
+	_, err = EncodeText([]byte{zlibHeader, 0x01})
+	assert.Error(t, err)
+
+	// Compressed data is limited, so small texts can't expand to large amounts of memory
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	_, err = w.Write(make([]byte, MaxTextSize+1))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	_, err = DecodeText(TextPrefixBase64 + base64.RawURLEncoding.EncodeToString(buf.Bytes()))
+	assert.Error(t, err)
+
+	// Broken compressed data
+	_, err = DecodeText(TextPrefixBase45 + encodeBase45([]byte{zlibHeader, 0x9c, 0x01}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ThresholdPolicy is the Value of a Threshold extension. Certificates issued by the subject of a
+// certificate with a ThresholdPolicy need to be signed by at least Threshold of the Keys instead of
+// the PubKey of the issuer, so high value roots can require the sign-off of several key holders.
+// The Signature of those certificates is a CBOR encoded array of ThresholdSignatures.
+type ThresholdPolicy struct {
+	_ struct{} `cbor:",toarray"`
+
+	Threshold uint64              `cbor:"threshold"`
+	Keys      []ed25519.PublicKey `cbor:"keys"`
+}
+
+// ThresholdSignature is the signature of one of the keys of a ThresholdPolicy
+type ThresholdSignature struct {
+	_ struct{} `cbor:",toarray"`
+
+	// KeyIndex is the index of the signing key in the Keys of the ThresholdPolicy
+	KeyIndex  uint64 `cbor:"key_index"`
+	Signature []byte `cbor:"signature"`
+}
+
+// ThresholdExtension creates a critical Extension requiring threshold of keys to sign certificates
+// issued by the subject.
+func ThresholdExtension(threshold uint64, keys ...ed25519.PublicKey) (Extension, error) {
+	p := &ThresholdPolicy{Threshold: threshold, Keys: keys}
+	if err := p.check(); err != nil {
+		return Extension{}, err
+	}
+	val, err := cborEm.Marshal(p)
+	if err != nil {
+		return Extension{}, err
+	}
+	return Extension{
+		OID:      OIDThreshold,
+		Critical: true,
+		Value:    val,
+	}, nil
+}
+
+// ParseThresholdPolicy parses a ThresholdPolicy from a byte slice, i.e. the Value of an Extension
+func ParseThresholdPolicy(in []byte) (*ThresholdPolicy, error) {
+	p := &ThresholdPolicy{}
+	if err := cbor.Unmarshal(in, p); err != nil {
+		return nil, fmt.Errorf("Failed to parse ThresholdPolicy: %w", err)
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *ThresholdPolicy) check() error {
+	if p.Threshold == 0 || p.Threshold > uint64(len(p.Keys)) {
+		return fmt.Errorf("Threshold of %d can't be met with %d keys", p.Threshold, len(p.Keys))
+	}
+	for _, key := range p.Keys {
+		if len(key) != ed25519.PublicKeySize {
+			return errors.New("ThresholdPolicy contains an invalid key")
+		}
+	}
+	return nil
+}
+
+// findThresholdPolicy returns the ThresholdPolicy of cert or nil if cert doesn't have one
+func findThresholdPolicy(cert *Certificate) (*ThresholdPolicy, error) {
+	var p *ThresholdPolicy
+	err := RequiresExtension(cert, OIDThreshold, func(critical bool, val []byte) (err error) {
+		p, err = ParseThresholdPolicy(val)
+		return
+	})
+	if errors.Is(err, ErrorExtensionNotFound) {
+		return nil, nil
+	}
+	return p, err
+}
+
+// ParseThresholdSignatures parses the ThresholdSignatures of a certificate issued under a ThresholdPolicy
+func ParseThresholdSignatures(cert *Certificate) ([]ThresholdSignature, error) {
+	return parseThresholdSignatures(cert.Signature)
+}
+
+func parseThresholdSignatures(buf []byte) ([]ThresholdSignature, error) {
+	var sigs []ThresholdSignature
+	if len(buf) == 0 {
+		return sigs, nil
+	}
+	if err := cbor.Unmarshal(buf, &sigs); err != nil {
+		return nil, fmt.Errorf("Failed to parse threshold signatures: %w", err)
+	}
+	return sigs, nil
+}
+
+// AddThresholdSignature signs cert with priv, which needs to be one of the keys of the
+// ThresholdPolicy of issuer, and adds the signature to the signatures already present. The
+// certificate must not be modified between the signatures of the different key holders.
+func AddThresholdSignature(cert, issuer *Certificate, priv ed25519.PrivateKey) (*Certificate, error) {
+	policy, err := findThresholdPolicy(issuer)
+	if err != nil {
+		return nil, err
+	}
+	if policy == nil {
+		return nil, errors.New("Issuer certificate has no ThresholdPolicy")
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	index := -1
+	for i, key := range policy.Keys {
+		if bytes.Equal(key, pub) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, errors.New("Key is not part of the ThresholdPolicy of the issuer")
+	}
+
+	if cert.SignatureAlgorithm != AlgorithmEd25519 {
+		// Changing the algorithm invalidates existing signatures
+		cert.SignatureAlgorithm = AlgorithmEd25519
+		cert.Signature = nil
+	}
+	sigs, err := ParseThresholdSignatures(cert)
+	if err != nil {
+		return nil, err
+	}
+	for _, sig := range sigs {
+		if sig.KeyIndex == uint64(index) {
+			return nil, errors.New("Certificate has already been signed with this key")
+		}
+	}
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return nil, err
+	}
+	sigs = append(sigs, ThresholdSignature{
+		KeyIndex:  uint64(index),
+		Signature: ed25519.Sign(priv, certBytes),
+	})
+	sigBytes, err := cborEm.Marshal(sigs)
+	if err != nil {
+		return nil, err
+	}
+	cert.Signature = sigBytes
+	return cert, nil
+}
+
+// issuerSignature is a single signature which needs to be valid for a certificate to be valid
+type issuerSignature struct {
+	pubKey    ed25519.PublicKey
+	signature []byte
+	// prehashed is true for Ed25519ph signatures
+	prehashed bool
+}
+
+func (s issuerSignature) verify(message []byte) bool {
+	if s.prehashed {
+		return verifyPrehashedSignature(s.pubKey, message, s.signature)
+	}
+	return verifySignature(s.pubKey, message, s.signature)
+}
+
+// issuerSignatures returns the ed25519 signatures of cert which need to be verified against issuer.
+// This is the signature of the certificate for ordinary issuers and all ThresholdSignatures for
+// issuers with a ThresholdPolicy. Post-quantum signatures of hybrid certificates are verified
+// immediately, as are the X.509 signatures of trust anchors converted via FromX509.
+func issuerSignatures(cert, issuer *Certificate) ([]issuerSignature, error) {
+	if cert.SignatureAlgorithm == AlgorithmX509 {
+		return nil, verifyX509Anchor(cert, issuer)
+	}
+	signature, err := classicalSignature(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := findThresholdPolicy(issuer)
+	if err != nil {
+		return nil, newValidationError(ErrMalformedCertificate, issuer, "Invalid ThresholdPolicy: %s", err)
+	}
+	if policy == nil {
+		return []issuerSignature{{pubKey: issuer.PubKey, signature: signature,
+			prehashed: cert.SignatureAlgorithm == AlgorithmEd25519ph}}, nil
+	}
+
+	sigs, err := parseThresholdSignatures(signature)
+	if err != nil {
+		return nil, newValidationError(ErrMalformedCertificate, cert, "%s", err)
+	}
+	signed := make(map[uint64]bool)
+	var result []issuerSignature
+	for _, sig := range sigs {
+		if sig.KeyIndex >= uint64(len(policy.Keys)) || signed[sig.KeyIndex] {
+			return nil, newValidationError(ErrMalformedCertificate, cert, "Invalid or repeated threshold signature key index %d", sig.KeyIndex)
+		}
+		signed[sig.KeyIndex] = true
+		result = append(result, issuerSignature{pubKey: policy.Keys[sig.KeyIndex], signature: sig.Signature,
+			prehashed: cert.SignatureAlgorithm == AlgorithmEd25519ph})
+	}
+	if uint64(len(result)) < policy.Threshold {
+		return nil, newValidationError(ErrThresholdNotMet, cert, "Certificate has %d of %d required signatures",
+			len(result), policy.Threshold)
+	}
+	return result, nil
+}
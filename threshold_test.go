@@ -0,0 +1,124 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newThresholdRoot(t *testing.T, threshold uint64, n int) (*Certificate, []ed25519.PrivateKey) {
+	var pubs []ed25519.PublicKey
+	var privs []ed25519.PrivateKey
+	for i := 0; i < n; i++ {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		pubs = append(pubs, pub)
+		privs = append(privs, priv)
+	}
+	ext, err := ThresholdExtension(threshold, pubs...)
+	require.NoError(t, err)
+	root := &Certificate{
+		SerialNumber: 1,
+		Issuer:       "root",
+		Subject:      "root",
+		Validity:     &Validity{},
+		PubKey:       pubs[0],
+		Extensions: []Extension{
+			{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()},
+			ext,
+		},
+	}
+	for _, priv := range privs[:threshold] {
+		_, err = AddThresholdSignature(root, root, priv)
+		require.NoError(t, err)
+	}
+	return root, privs
+}
+
+func TestThresholdSignatures(t *testing.T) {
+	root, privs := newThresholdRoot(t, 2, 3)
+	pool := NewCertPool(root)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cert := &Certificate{
+		SerialNumber: 2,
+		Issuer:       "root",
+		Subject:      "device",
+		Validity:     &Validity{},
+		PubKey:       pub,
+	}
+
+	_, err = AddThresholdSignature(cert, root, privs[2])
+	require.NoError(t, err)
+	err = pool.Validate(cert)
+	assert.True(t, errors.Is(err, ErrThresholdNotMet))
+	report := pool.ValidateReport(cert)
+	require.Len(t, report.Problems, 1)
+	assert.True(t, errors.Is(report.Err(), ErrThresholdNotMet))
+
+	// Signing twice with the same key doesn't count
+	_, err = AddThresholdSignature(cert, root, privs[2])
+	assert.Error(t, err)
+
+	_, err = AddThresholdSignature(cert, root, privs[0])
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(cert))
+	assert.True(t, pool.ValidateReport(cert).Valid())
+
+	sigs, err := ParseThresholdSignatures(cert)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+	assert.Equal(t, uint64(2), sigs[0].KeyIndex)
+	assert.Equal(t, uint64(0), sigs[1].KeyIndex)
+
+	// All present signatures need to be valid
+	sigs[0].Signature[0] ^= 0xff
+	buf, err := cborEm.Marshal(sigs)
+	require.NoError(t, err)
+	cert.Signature = buf
+	assert.True(t, errors.Is(pool.Validate(cert), ErrBadSignature))
+
+	// Keys outside of the policy can't sign
+	_, outsider, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = AddThresholdSignature(cert, root, outsider)
+	assert.Error(t, err)
+}
+
+func TestThresholdRootSignature(t *testing.T) {
+	root, _ := newThresholdRoot(t, 2, 2)
+	// The root itself has been signed by both keys, with only one it fails
+	sigs, err := ParseThresholdSignatures(root)
+	require.NoError(t, err)
+	buf, err := cborEm.Marshal(sigs[:1])
+	require.NoError(t, err)
+	root.Signature = buf
+
+	cert := &Certificate{Issuer: "root", Subject: "device", Validity: &Validity{}, PubKey: root.PubKey}
+	assert.True(t, errors.Is(NewCertPool(root).Validate(cert), ErrThresholdNotMet))
+}
+
+func TestInvalidThresholdPolicy(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = ThresholdExtension(0, pub)
+	assert.Error(t, err)
+	_, err = ThresholdExtension(2, pub)
+	assert.Error(t, err)
+	_, err = ThresholdExtension(1, ed25519.PublicKey{1, 2, 3})
+	assert.Error(t, err)
+	_, err = ParseThresholdPolicy([]byte{0x01})
+	assert.Error(t, err)
+
+	// Issuers without policy can't be used
+	root, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, err = AddThresholdSignature(root, root, rootKey)
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package smolcert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// timestampContext is prepended to signed timestamps, so that timestamp signatures can't be
+// mistaken for signatures of other structures.
+var timestampContext = []byte("smolcert timestamp")
+
+// timestampNonceSize is the size of the random nonce of a TimestampRequest
+const timestampNonceSize = 16
+
+// TimestampRequest asks a TimestampAuthority to attest that data with the given digest existed at
+// the current time. Only the digest of the data is sent to the authority.
+type TimestampRequest struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Digest is the SHA-256 hash of the timestamped data
+	Digest []byte `cbor:"digest"`
+	// Nonce is returned in the TimestampToken to match responses to requests
+	Nonce []byte `cbor:"nonce"`
+}
+
+// TimestampToken is issued by a TimestampAuthority in response to a TimestampRequest. It proves
+// that the data with the digest existed at Time. The signer is referenced by issuer and serial
+// number of its certificate, which is embedded together with intermediate certificates.
+type TimestampToken struct {
+	_ struct{} `cbor:",toarray"`
+
+	Digest       []byte `cbor:"digest"`
+	Nonce        []byte `cbor:"nonce"`
+	Time         Time   `cbor:"time"`
+	Issuer       string `cbor:"issuer"`
+	SerialNumber uint64 `cbor:"serial_number"`
+	// Certificates contains the certificate of the authority first, followed by intermediates
+	Certificates []*Certificate `cbor:"certificates"`
+	Signature    []byte         `cbor:"signature"`
+}
+
+// NewTimestampRequest creates a TimestampRequest for data with a random nonce
+func NewTimestampRequest(data []byte) (*TimestampRequest, error) {
+	nonce := make([]byte, timestampNonceSize)
+	if err := readRandom(nil, nonce); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	return &TimestampRequest{Digest: digest[:], Nonce: nonce}, nil
+}
+
+// ParseTimestampRequest parses a TimestampRequest from an existing byte buffer
+func ParseTimestampRequest(buf []byte) (*TimestampRequest, error) {
+	req := &TimestampRequest{}
+	if err := cbor.Unmarshal(buf, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Bytes returns the CBOR encoded form of the TimestampRequest
+func (req *TimestampRequest) Bytes() ([]byte, error) {
+	return cborEm.Marshal(req)
+}
+
+// CheckToken ensures that token has been issued in response to this request. The signature of the
+// token is not verified.
+func (req *TimestampRequest) CheckToken(token *TimestampToken) error {
+	if !bytes.Equal(req.Digest, token.Digest) {
+		return errors.New("Timestamp has been issued for a different digest")
+	}
+	if !bytes.Equal(req.Nonce, token.Nonce) {
+		return errors.New("Timestamp has been issued for a different request")
+	}
+	return nil
+}
+
+// ParseTimestampToken parses a TimestampToken from an existing byte buffer
+func ParseTimestampToken(buf []byte) (*TimestampToken, error) {
+	token := &TimestampToken{}
+	if err := cbor.Unmarshal(buf, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Bytes returns the CBOR encoded form of the TimestampToken
+func (t *TimestampToken) Bytes() ([]byte, error) {
+	return cborEm.Marshal(t)
+}
+
+func (t *TimestampToken) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&TimestampToken{
+		Digest:       t.Digest,
+		Nonce:        t.Nonce,
+		Time:         t.Time,
+		Issuer:       t.Issuer,
+		SerialNumber: t.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, timestampContext...), buf...), nil
+}
+
+// Verify checks that the token has been signed by signer for data. signer needs to have the
+// KeyUsage Timestamping and has to be valid at the time of the token. The certificate chain of
+// the signer is not validated.
+func (t *TimestampToken) Verify(signer *Certificate, data []byte) error {
+	digest := sha256.Sum256(data)
+	return t.VerifyDigest(signer, digest[:])
+}
+
+// VerifyDigest checks the token like Verify for the SHA-256 digest of the data
+func (t *TimestampToken) VerifyDigest(signer *Certificate, digest []byte) error {
+	if t.Issuer != signer.Issuer || t.SerialNumber != signer.SerialNumber {
+		return fmt.Errorf("Timestamp has been signed by serial %d from '%s', not by the given certificate",
+			t.SerialNumber, t.Issuer)
+	}
+	if !bytes.Equal(digest, t.Digest) {
+		return errors.New("Data doesn't match the digest of the timestamp")
+	}
+	if err := RequiresExtension(signer, OIDKeyUsage, ExpectKeyUsage(KeyUsageTimestamping)); err != nil {
+		return newValidationError(ErrInvalidKeyUsage, signer, "Timestamp signer needs the KeyUsage Timestamping: %s", err)
+	}
+	if signer.Validity != nil {
+		if !signer.Validity.NotBefore.IsZero() && t.Time < signer.Validity.NotBefore {
+			return errors.New("Timestamp has been issued before the signer certificate became valid")
+		}
+		if !signer.Validity.NotAfter.IsZero() && t.Time > signer.Validity.NotAfter {
+			return errors.New("Timestamp has been issued after the signer certificate expired")
+		}
+	}
+	tbs, err := t.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(signer.PubKey, tbs, t.Signature) {
+		return newValidationError(ErrBadSignature, signer, "Signature validation of timestamp failed")
+	}
+	return nil
+}
+
+// VerifyTimestamp validates the certificates embedded in t against the CertPool and verifies t
+// for data. The certificate of the signer is returned on success.
+func (c *CertPool) VerifyTimestamp(t *TimestampToken, data []byte) (*Certificate, error) {
+	if len(t.Certificates) == 0 {
+		return nil, errors.New("Timestamp doesn't contain the certificate of the signer")
+	}
+	signer, err := c.ValidateBundle(t.Certificates)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid timestamp signer certificate: %w", err)
+	}
+	if err := t.Verify(signer, data); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// TimestampAuthority issues TimestampTokens
+type TimestampAuthority struct {
+	// Certificates contains the certificate of the authority first, followed by intermediates
+	Certificates []*Certificate
+	// PrivateKey belongs to the first certificate
+	PrivateKey ed25519.PrivateKey
+	// Now returns the current time, time.Now is used if nil
+	Now func() time.Time
+}
+
+// NewTimestampAuthority creates a TimestampAuthority signing with priv. chain contains the
+// certificate of the authority first, which needs the KeyUsage Timestamping.
+func NewTimestampAuthority(chain []*Certificate, priv ed25519.PrivateKey) (*TimestampAuthority, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("Certificate chain of the timestamp authority is empty")
+	}
+	if !bytes.Equal(priv.Public().(ed25519.PublicKey), chain[0].PubKey) {
+		return nil, errors.New("Private key doesn't belong to the certificate of the timestamp authority")
+	}
+	if err := RequiresExtension(chain[0], OIDKeyUsage, ExpectKeyUsage(KeyUsageTimestamping)); err != nil {
+		return nil, fmt.Errorf("Timestamp authority needs the KeyUsage Timestamping: %w", err)
+	}
+	return &TimestampAuthority{Certificates: chain, PrivateKey: priv}, nil
+}
+
+// Timestamp issues a TimestampToken for req
+func (a *TimestampAuthority) Timestamp(req *TimestampRequest) (*TimestampToken, error) {
+	if len(req.Digest) != sha256.Size {
+		return nil, fmt.Errorf("Invalid digest size %d, expected a SHA-256 digest", len(req.Digest))
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	signer := a.Certificates[0]
+	t := &TimestampToken{
+		Digest:       req.Digest,
+		Nonce:        req.Nonce,
+		Time:         NewTime(now()),
+		Issuer:       signer.Issuer,
+		SerialNumber: signer.SerialNumber,
+		Certificates: a.Certificates,
+	}
+	tbs, err := t.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	t.Signature = ed25519.Sign(a.PrivateKey, tbs)
+	return t, nil
+}
@@ -0,0 +1,84 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTimestampAuthority(t *testing.T, notBefore, notAfter time.Time) (*CertPool, *TimestampAuthority) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	tsaCert, tsaKey, err := SignedCertificate("tsa", 2, notBefore, notAfter,
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageTimestamping.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	tsa, err := NewTimestampAuthority([]*Certificate{tsaCert}, tsaKey)
+	require.NoError(t, err)
+	return NewCertPool(rootCert), tsa
+}
+
+func TestTimestamp(t *testing.T) {
+	pool, tsa := newTestTimestampAuthority(t, time.Time{}, time.Time{})
+	data := []byte("firmware image")
+	req, err := NewTimestampRequest(data)
+	require.NoError(t, err)
+	reqBytes, err := req.Bytes()
+	require.NoError(t, err)
+	req2, err := ParseTimestampRequest(reqBytes)
+	require.NoError(t, err)
+
+	before := time.Now().Add(-time.Second)
+	token, err := tsa.Timestamp(req2)
+	require.NoError(t, err)
+	tokenBytes, err := token.Bytes()
+	require.NoError(t, err)
+	token, err = ParseTimestampToken(tokenBytes)
+	require.NoError(t, err)
+
+	assert.NoError(t, req.CheckToken(token))
+	assert.True(t, token.Time.StdTime().After(before))
+	signer, err := pool.VerifyTimestamp(token, data)
+	require.NoError(t, err)
+	assert.Equal(t, "tsa", signer.Subject)
+
+	_, err = pool.VerifyTimestamp(token, []byte("other image"))
+	assert.Error(t, err)
+	otherReq, err := NewTimestampRequest(data)
+	require.NoError(t, err)
+	assert.Error(t, otherReq.CheckToken(token))
+
+	token.Time += 60
+	_, err = pool.VerifyTimestamp(token, data)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+
+	_, err = tsa.Timestamp(&TimestampRequest{Digest: []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestTimestampOutsideSignerValidity(t *testing.T) {
+	now := time.Now()
+	pool, tsa := newTestTimestampAuthority(t, now.Add(-time.Hour), now.Add(time.Hour))
+	tsa.Now = func() time.Time { return now.Add(time.Hour * 2) }
+	req, err := NewTimestampRequest([]byte("data"))
+	require.NoError(t, err)
+	token, err := tsa.Timestamp(req)
+	require.NoError(t, err)
+	_, err = pool.VerifyTimestamp(token, []byte("data"))
+	assert.Error(t, err)
+}
+
+func TestTimestampAuthorityNeedsKeyUsage(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, err = NewTimestampAuthority([]*Certificate{rootCert}, rootKey)
+	assert.Error(t, err)
+	clientCert, _, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	_, err = NewTimestampAuthority([]*Certificate{clientCert}, rootKey)
+	assert.Error(t, err)
+	_, err = NewTimestampAuthority(nil, rootKey)
+	assert.Error(t, err)
+}
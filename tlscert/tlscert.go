@@ -0,0 +1,219 @@
+/*
+Package tlscert allows to authenticate (D)TLS handshakes with smolcerts.
+
+TLS and DTLS implementations expect X.509 certificates during the handshake. To use smolcerts, the
+certificate bundle of a peer is embedded into a self signed X.509 certificate for the same
+ed25519 key. The handshake proves that the peer possesses the key, while the trust decision is
+based solely on the embedded smolcerts, which are validated against a smolcert.CertPool.
+
+The functions of this package match the certificate hooks of crypto/tls as well as of
+github.com/pion/dtls, i.e. for a DTLS server:
+
+	tlsCert, _ := tlscert.Certificate(bundle, privateKey)
+	config := &dtls.Config{
+		Certificates:          []tls.Certificate{tlsCert},
+		ClientAuth:            dtls.RequireAnyClientCert,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: tlscert.VerifyPeerCertificate(pool),
+	}
+
+After the handshake PeerCertificate(conn.ConnectionState().PeerCertificates) returns the validated
+smolcert of the peer.
+*/
+package tlscert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// ExtensionOID is the OID of the X.509 extension carrying the smolcert bundle
+var ExtensionOID = asn1.ObjectIdentifier{2, 25, 1784201190, 1}
+
+var cborEm cbor.EncMode
+
+func init() {
+	var err error
+	cborEm, err = cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		panic("Failed to setup CBOR encoder")
+	}
+}
+
+// Certificate creates a tls.Certificate which can be used in (D)TLS handshakes. bundle contains the
+// certificate of this peer first, followed by intermediate certificates if necessary. key is the
+// private key belonging to the first certificate.
+func Certificate(bundle []*smolcert.Certificate, key ed25519.PrivateKey) (tls.Certificate, error) {
+	if len(bundle) == 0 {
+		return tls.Certificate{}, errors.New("Certificate bundle is empty")
+	}
+	if !bytes.Equal(key.Public().(ed25519.PublicKey), bundle[0].PubKey) {
+		return tls.Certificate{}, errors.New("Private key doesn't belong to the first certificate of the bundle")
+	}
+	bundleBytes, err := cborEm.Marshal(bundle)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: new(big.Int).SetUint64(bundle[0].SerialNumber),
+		Subject:      pkix.Name{CommonName: bundle[0].Subject},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24 * 365),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{
+			{Id: ExtensionOID, Value: bundleBytes},
+		},
+	}
+	if bundle[0].Validity != nil {
+		if !bundle[0].Validity.NotBefore.IsZero() {
+			template.NotBefore = bundle[0].Validity.NotBefore.StdTime()
+		}
+		if !bundle[0].Validity.NotAfter.IsZero() {
+			template.NotAfter = bundle[0].Validity.NotAfter.StdTime()
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
+
+// Bundle extracts the smolcert bundle embedded in a X.509 certificate created via Certificate. The
+// bundle isn't validated.
+func Bundle(x509Cert *x509.Certificate) ([]*smolcert.Certificate, error) {
+	for _, ext := range x509Cert.Extensions {
+		if !ext.Id.Equal(ExtensionOID) {
+			continue
+		}
+		var bundle []*smolcert.Certificate
+		if err := cbor.Unmarshal(ext.Value, &bundle); err != nil {
+			return nil, err
+		}
+		if len(bundle) == 0 {
+			return nil, errors.New("Embedded certificate bundle is empty")
+		}
+		pubKey, ok := x509Cert.PublicKey.(ed25519.PublicKey)
+		if !ok || !bytes.Equal(pubKey, bundle[0].PubKey) {
+			return nil, errors.New("Key of the X.509 certificate doesn't match the embedded certificate")
+		}
+		return bundle, nil
+	}
+	return nil, errors.New("X.509 certificate doesn't contain a smolcert")
+}
+
+// Validate extracts the smolcert bundle from a X.509 certificate and validates it against pool.
+// The validated certificate of the peer is returned.
+func Validate(pool *smolcert.CertPool, x509Cert *x509.Certificate) (*smolcert.Certificate, error) {
+	bundle, err := Bundle(x509Cert)
+	if err != nil {
+		return nil, err
+	}
+	return smolcert.NewBundle(bundle...).Validate(pool)
+}
+
+// PeerCertificate parses the raw certificates presented by a peer and returns its validated smolcert
+func PeerCertificate(pool *smolcert.CertPool, rawCerts [][]byte) (*smolcert.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("Peer didn't present a certificate")
+	}
+	x509Cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return nil, err
+	}
+	return Validate(pool, x509Cert)
+}
+
+// VerifyPeerCertificate returns a function suitable as VerifyPeerCertificate hook of crypto/tls and
+// pion/dtls configurations. It validates the smolcert of the peer against pool. Since the X.509
+// certificates are self signed, the configurations need to set InsecureSkipVerify to skip the
+// X.509 chain validation.
+func VerifyPeerCertificate(pool *smolcert.CertPool) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		_, err := PeerCertificate(pool, rawCerts)
+		return err
+	}
+}
+
+// ServerConfig creates a crypto/tls configuration for a server authenticating itself with cert and
+// requiring clients to present smolcerts valid in pool.
+func ServerConfig(cert tls.Certificate, pool *smolcert.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates:          []tls.Certificate{cert},
+		ClientAuth:            tls.RequireAnyClientCert,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: VerifyPeerCertificate(pool),
+	}
+}
+
+// ClientConfig creates a crypto/tls configuration for a client authenticating itself with cert
+// and requiring the server to present a smolcert valid in pool.
+func ClientConfig(cert tls.Certificate, pool *smolcert.CertPool) *tls.Config {
+	return &tls.Config{
+		Certificates:          []tls.Certificate{cert},
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: VerifyPeerCertificate(pool),
+	}
+}
+
+// ReloadingServerConfig creates a crypto/tls configuration like ServerConfig, which uses the
+// current Credentials of r for every handshake, so rotated certificates and roots are used without
+// a restart. r needs a PoolFile with the roots trusted for clients.
+func ReloadingServerConfig(r *smolcert.Reloader) *tls.Config {
+	c := &reloadingCertificate{reloader: r}
+	return &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return c.get()
+		},
+		ClientAuth:         tls.RequireAnyClientCert,
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			pool := r.Pool()
+			if pool == nil {
+				return errors.New("No trusted roots have been loaded")
+			}
+			_, err := PeerCertificate(pool, rawCerts)
+			return err
+		},
+	}
+}
+
+// reloadingCertificate creates the tls.Certificate for the current Credentials of a Reloader once
+type reloadingCertificate struct {
+	reloader *smolcert.Reloader
+
+	lock    sync.Mutex
+	creds   *smolcert.Credentials
+	tlsCert *tls.Certificate
+}
+
+func (c *reloadingCertificate) get() (*tls.Certificate, error) {
+	creds := c.reloader.Credentials()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if creds != c.creds {
+		tlsCert, err := Certificate(creds.Certificates, creds.Key)
+		if err != nil {
+			return nil, err
+		}
+		c.creds, c.tlsCert = creds, &tlsCert
+	}
+	return c.tlsCert, nil
+}
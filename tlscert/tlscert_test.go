@@ -0,0 +1,167 @@
+package tlscert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) (serverErr, clientErr error,
+	serverState, clientState tls.ConnectionState) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := l.Accept()
+		if err != nil {
+			serverErr = err
+			return
+		}
+		defer conn.Close()
+		server := tls.Server(conn, serverConfig)
+		serverErr = server.Handshake()
+		serverState = server.ConnectionState()
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	client := tls.Client(conn, clientConfig)
+	clientErr = client.Handshake()
+	clientState = client.ConnectionState()
+	<-done
+	return
+}
+
+func TestMutualAuthentication(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := smolcert.NewCertPool(rootCert)
+
+	serverCert, serverKey, err := smolcert.ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	clientCert, clientKey, err := smolcert.ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	serverTLSCert, err := Certificate([]*smolcert.Certificate{serverCert}, serverKey)
+	require.NoError(t, err)
+	clientTLSCert, err := Certificate([]*smolcert.Certificate{clientCert}, clientKey)
+	require.NoError(t, err)
+
+	serverErr, clientErr, serverState, clientState := handshake(t,
+		ServerConfig(serverTLSCert, pool), ClientConfig(clientTLSCert, pool))
+	require.NoError(t, serverErr)
+	require.NoError(t, clientErr)
+
+	peer, err := Validate(pool, serverState.PeerCertificates[0])
+	require.NoError(t, err)
+	assert.Equal(t, "client", peer.Subject)
+	peer, err = Validate(pool, clientState.PeerCertificates[0])
+	require.NoError(t, err)
+	assert.Equal(t, "server", peer.Subject)
+}
+
+func TestUntrustedPeerIsRejected(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, otherKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := smolcert.NewCertPool(rootCert)
+
+	serverCert, serverKey, err := smolcert.ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	clientCert, clientKey, err := smolcert.ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, otherKey, "root")
+	require.NoError(t, err)
+
+	serverTLSCert, err := Certificate([]*smolcert.Certificate{serverCert}, serverKey)
+	require.NoError(t, err)
+	clientTLSCert, err := Certificate([]*smolcert.Certificate{clientCert}, clientKey)
+	require.NoError(t, err)
+
+	serverErr, _, _, _ := handshake(t, ServerConfig(serverTLSCert, pool), ClientConfig(clientTLSCert, pool))
+	assert.Error(t, serverErr)
+
+	_, err = Certificate([]*smolcert.Certificate{clientCert}, serverKey)
+	assert.Error(t, err)
+}
+
+func TestBundleWithIntermediate(t *testing.T) {
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, imKey, err := smolcert.SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]smolcert.Extension{{OID: smolcert.OIDKeyUsage, Critical: true, Value: smolcert.KeyUsageSignCert.ToBytes()}},
+		rootKey, "root")
+	require.NoError(t, err)
+	clientCert, clientKey, err := smolcert.ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, imKey, "intermediate")
+	require.NoError(t, err)
+
+	tlsCert, err := Certificate([]*smolcert.Certificate{clientCert, intermediate}, clientKey)
+	require.NoError(t, err)
+	peer, err := PeerCertificate(smolcert.NewCertPool(rootCert), tlsCert.Certificate)
+	require.NoError(t, err)
+	assert.Equal(t, "client", peer.Subject)
+}
+
+func TestReloadingServerConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlscert-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFiles := func(cert *smolcert.Certificate, key ed25519.PrivateKey, pool *smolcert.CertPool) {
+		certBytes, err := cert.Bytes()
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cert.cbor"), certBytes, 0600))
+		keyBytes, err := smolcert.MarshalPrivateKey(key)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "key.pem"), keyBytes, 0600))
+		poolBytes := &bytes.Buffer{}
+		require.NoError(t, pool.Save(poolBytes))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pool.cbor"), poolBytes.Bytes(), 0600))
+	}
+
+	rootCert, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	newRootCert, newRootKey, err := smolcert.SelfSignedCertificate("new root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	serverCert, serverKey, err := smolcert.ServerCertificate("server", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	clientCert, clientKey, err := smolcert.ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, newRootKey, "new root")
+	require.NoError(t, err)
+	clientTLSCert, err := Certificate([]*smolcert.Certificate{clientCert}, clientKey)
+	require.NoError(t, err)
+	writeFiles(serverCert, serverKey, smolcert.NewCertPool(rootCert))
+
+	r, err := smolcert.NewReloader(filepath.Join(dir, "cert.cbor"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "pool.cbor"))
+	require.NoError(t, err)
+	serverConfig := ReloadingServerConfig(r)
+
+	// The client is issued by a root the server doesn't trust yet
+	serverErr, _, _, _ := handshake(t, serverConfig, ClientConfig(clientTLSCert, smolcert.NewCertPool(rootCert, newRootCert)))
+	assert.Error(t, serverErr)
+
+	renewedCert, renewedKey, err := smolcert.ServerCertificate("renewed server", 4, time.Time{}, time.Time{}, nil, newRootKey, "new root")
+	require.NoError(t, err)
+	writeFiles(renewedCert, renewedKey, smolcert.NewCertPool(newRootCert))
+	changed, err := r.Reload()
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	serverErr, clientErr, _, clientState := handshake(t, serverConfig, ClientConfig(clientTLSCert, smolcert.NewCertPool(newRootCert)))
+	require.NoError(t, serverErr)
+	require.NoError(t, clientErr)
+	peer, err := Validate(smolcert.NewCertPool(newRootCert), clientState.PeerCertificates[0])
+	require.NoError(t, err)
+	assert.Equal(t, "renewed server", peer.Subject)
+}
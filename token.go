@@ -0,0 +1,146 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// ErrTokenExpired indicates that the expiry of a Token has passed
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrWrongAudience indicates that a Token has been issued for another audience
+	ErrWrongAudience = errors.New("token has been issued for another audience")
+)
+
+// tokenContext is prepended to signed tokens, so that token signatures can't be mistaken for
+// signatures of other structures.
+var tokenContext = []byte("smolcert access token")
+
+// Token is a lightweight alternative to JWTs. It carries claims for an audience and is signed with
+// the key of a certificate, which is referenced by issuer and serial number. The certificate and
+// intermediate certificates can optionally be embedded, so the receiver can validate the chain of
+// the signer.
+type Token struct {
+	_ struct{} `cbor:",toarray"`
+
+	Issuer       string `cbor:"issuer"`
+	SerialNumber uint64 `cbor:"serial_number"`
+	Audience     string `cbor:"audience"`
+	IssuedAt     Time   `cbor:"issued_at"`
+	Expiry       Time   `cbor:"expiry"`
+	// Claims are the CBOR encoded claims of the token, they can be decoded with DecodeClaims
+	Claims cbor.RawMessage `cbor:"claims"`
+	// Certificates optionally contains the certificate of the signer first, followed by intermediates
+	Certificates []*Certificate `cbor:"certificates"`
+	Signature    []byte         `cbor:"signature"`
+}
+
+// SignToken creates a Token for audience carrying claims, which expires after validFor. The token
+// is signed with the private key of signer. claims may be any value which can be encoded as CBOR.
+func SignToken(claims interface{}, audience string, validFor time.Duration, signer *Certificate,
+	priv ed25519.PrivateKey) (*Token, error) {
+	if !bytes.Equal(priv.Public().(ed25519.PublicKey), signer.PubKey) {
+		return nil, errors.New("Private key doesn't belong to the signing certificate")
+	}
+	if validFor <= 0 {
+		return nil, errors.New("Tokens need a positive validity")
+	}
+	encodedClaims, err := cborEm.Marshal(claims)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode claims: %w", err)
+	}
+	now := time.Now()
+	t := &Token{
+		Issuer:       signer.Issuer,
+		SerialNumber: signer.SerialNumber,
+		Audience:     audience,
+		IssuedAt:     NewTime(now),
+		Expiry:       NewTime(now.Add(validFor)),
+		Claims:       encodedClaims,
+	}
+	tbs, err := t.tbsBytes()
+	if err != nil {
+		return nil, err
+	}
+	t.Signature = ed25519.Sign(priv, tbs)
+	return t, nil
+}
+
+// ParseToken parses a Token from an existing byte buffer
+func ParseToken(buf []byte) (*Token, error) {
+	t := &Token{}
+	if err := cbor.Unmarshal(buf, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Bytes returns the CBOR encoded form of the Token
+func (t *Token) Bytes() ([]byte, error) {
+	return cborEm.Marshal(t)
+}
+
+// DecodeClaims decodes the claims of the token into v
+func (t *Token) DecodeClaims(v interface{}) error {
+	return cbor.Unmarshal(t.Claims, v)
+}
+
+// The embedded certificates are not signed, they are validated on their own
+func (t *Token) tbsBytes() ([]byte, error) {
+	buf, err := cborEm.Marshal(&Token{
+		Issuer:       t.Issuer,
+		SerialNumber: t.SerialNumber,
+		Audience:     t.Audience,
+		IssuedAt:     t.IssuedAt,
+		Expiry:       t.Expiry,
+		Claims:       t.Claims,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, tokenContext...), buf...), nil
+}
+
+// Verify checks that the token has been signed by signer for audience and hasn't expired. The
+// certificate of the signer is not validated.
+func (t *Token) Verify(signer *Certificate, audience string) error {
+	if t.Issuer != signer.Issuer || t.SerialNumber != signer.SerialNumber {
+		return fmt.Errorf("Token has been signed by serial %d from '%s', not by the given certificate",
+			t.SerialNumber, t.Issuer)
+	}
+	tbs, err := t.tbsBytes()
+	if err != nil {
+		return err
+	}
+	if !verifySignature(signer.PubKey, tbs, t.Signature) {
+		return newValidationError(ErrBadSignature, signer, "Signature validation of token failed")
+	}
+	if t.Audience != audience {
+		return fmt.Errorf("%w: expected '%s', got '%s'", ErrWrongAudience, audience, t.Audience)
+	}
+	if t.Expiry.IsZero() || !time.Now().Before(t.Expiry.StdTime()) {
+		return fmt.Errorf("%w: token expired at %s", ErrTokenExpired, t.Expiry.StdTime().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// VerifyToken validates the certificates embedded in t against the CertPool and verifies t for
+// audience. The certificate of the signer is returned on success.
+func (c *CertPool) VerifyToken(t *Token, audience string) (*Certificate, error) {
+	if len(t.Certificates) == 0 {
+		return nil, errors.New("Token doesn't contain the certificate of the signer")
+	}
+	signer, err := c.ValidateBundle(t.Certificates)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid signer certificate: %w", err)
+	}
+	if err := t.Verify(signer, audience); err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
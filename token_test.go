@@ -0,0 +1,74 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type testClaims struct {
+	Scope  string `cbor:"scope"`
+	Device uint64 `cbor:"device"`
+}
+
+func TestToken(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "root")
+	require.NoError(t, err)
+	signer, signerKey, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	other, otherKey, err := ClientCertificate("other", 4, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+
+	_, err = SignToken(testClaims{}, "api", time.Hour, signer, otherKey)
+	assert.Error(t, err)
+	_, err = SignToken(testClaims{}, "api", 0, signer, signerKey)
+	assert.Error(t, err)
+
+	token, err := SignToken(testClaims{Scope: "read", Device: 42}, "api", time.Hour, signer, signerKey)
+	require.NoError(t, err)
+	token.Certificates = []*Certificate{signer, intermediate}
+	buf, err := token.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseToken(buf)
+	require.NoError(t, err)
+
+	pool := NewCertPool(rootCert)
+	verifiedSigner, err := pool.VerifyToken(parsed, "api")
+	require.NoError(t, err)
+	assert.Equal(t, signer.Subject, verifiedSigner.Subject)
+	var claims testClaims
+	require.NoError(t, parsed.DecodeClaims(&claims))
+	assert.Equal(t, testClaims{Scope: "read", Device: 42}, claims)
+
+	assert.NoError(t, parsed.Verify(signer, "api"))
+	assert.True(t, errors.Is(parsed.Verify(signer, "admin"), ErrWrongAudience))
+	assert.Error(t, parsed.Verify(other, "api"))
+
+	// The certificates are not covered by the signature of the token
+	parsed.Certificates = nil
+	assert.NoError(t, parsed.Verify(signer, "api"))
+	_, err = pool.VerifyToken(parsed, "api")
+	assert.Error(t, err)
+
+	parsed.Claims = []byte{0xa0}
+	assert.True(t, errors.Is(parsed.Verify(signer, "api"), ErrBadSignature))
+
+	expired := *token
+	expired.Expiry = NewTime(time.Now().Add(-time.Minute))
+	tbs, err := expired.tbsBytes()
+	require.NoError(t, err)
+	expired.Signature = ed25519.Sign(signerKey, tbs)
+	assert.True(t, errors.Is(expired.Verify(signer, "api"), ErrTokenExpired))
+
+	otherRoot, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	_, err = NewCertPool(otherRoot).VerifyToken(token, "api")
+	assert.Error(t, err)
+}
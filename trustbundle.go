@@ -0,0 +1,167 @@
+package smolcert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// trustBundleContext is prepended to signed trust bundles, so that their signatures can't be
+// mistaken for signatures of other structures.
+var trustBundleContext = []byte("smolcert trust bundle")
+
+// ErrStaleTrustBundle indicates that a TrustBundle has expired or doesn't supersede the currently
+// installed TrustBundle
+var ErrStaleTrustBundle = errors.New("trust bundle is stale")
+
+// TrustBundle distributes the set of root certificates of a fleet. It is signed with a dedicated
+// bundle-signing key, which is pinned on the devices, so that roots can be added and removed
+// without trusting the transport the TrustBundle is delivered over.
+type TrustBundle struct {
+	_ struct{} `cbor:",toarray"`
+
+	// Name identifies the series of trust bundles, i.e. the fleet the roots are meant for
+	Name string `cbor:"name"`
+	// SequenceNumber increases with every TrustBundle of a series and protects against rollbacks
+	SequenceNumber uint64 `cbor:"sequence_number"`
+	IssuedAt       Time   `cbor:"issued_at"`
+	// Expires is the time after which the TrustBundle must be replaced, devices still use an
+	// installed TrustBundle after it expired, but don't accept it as an update
+	Expires Time `cbor:"expires"`
+	// Roots are the trusted root certificates
+	Roots []*Certificate `cbor:"roots"`
+	// Metadata contains arbitrary information about the TrustBundle like a description
+	Metadata  map[string]string `cbor:"metadata"`
+	Signature []byte            `cbor:"signature"`
+}
+
+// NewTrustBundle creates an unsigned TrustBundle containing roots which expires after validFor
+func NewTrustBundle(name string, sequenceNumber uint64, roots []*Certificate, validFor time.Duration) *TrustBundle {
+	now := time.Now()
+	return &TrustBundle{
+		Name:           name,
+		SequenceNumber: sequenceNumber,
+		IssuedAt:       NewTime(now),
+		Expires:        NewTime(now.Add(validFor)),
+		Roots:          append([]*Certificate{}, roots...),
+	}
+}
+
+// ParseTrustBundle parses a TrustBundle from an existing byte buffer without verifying it
+func ParseTrustBundle(buf []byte) (*TrustBundle, error) {
+	tb := &TrustBundle{}
+	if err := cbor.Unmarshal(buf, tb); err != nil {
+		return nil, err
+	}
+	return tb, nil
+}
+
+// LoadTrustBundle reads a TrustBundle from r and verifies it with the given bundle-signing keys
+func LoadTrustBundle(r io.Reader, signingKeys ...ed25519.PublicKey) (*TrustBundle, error) {
+	tb := &TrustBundle{}
+	if err := cbor.NewDecoder(r).Decode(tb); err != nil {
+		return nil, err
+	}
+	if err := tb.Verify(signingKeys...); err != nil {
+		return nil, err
+	}
+	return tb, nil
+}
+
+// Bytes returns the CBOR encoded form of the TrustBundle
+func (tb *TrustBundle) Bytes() ([]byte, error) {
+	return cborEm.Marshal(tb)
+}
+
+func (tb *TrustBundle) tbsBytes() ([]byte, error) {
+	tbs := *tb
+	tbs.Signature = nil
+	buf, err := cborEm.Marshal(&tbs)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, trustBundleContext...), buf...), nil
+}
+
+// Sign signs the TrustBundle with the bundle-signing key
+func (tb *TrustBundle) Sign(signingKey ed25519.PrivateKey) error {
+	if len(signingKey) != ed25519.PrivateKeySize {
+		return errors.New("Invalid bundle-signing key")
+	}
+	tbs, err := tb.tbsBytes()
+	if err != nil {
+		return err
+	}
+	tb.Signature = ed25519.Sign(signingKey, tbs)
+	return nil
+}
+
+// Verify checks that the TrustBundle is signed by one of signingKeys, has not been issued in the
+// future and only contains root certificates which are allowed to sign certificates. Expired
+// TrustBundles are accepted, so that devices can keep using their installed roots until an update
+// arrives, use VerifyUpdate to verify new TrustBundles.
+func (tb *TrustBundle) Verify(signingKeys ...ed25519.PublicKey) error {
+	tbs, err := tb.tbsBytes()
+	if err != nil {
+		return err
+	}
+	signed := false
+	for _, key := range signingKeys {
+		if verifySignature(key, tbs, tb.Signature) {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return fmt.Errorf("%w: trust bundle is not signed by a trusted bundle-signing key", ErrBadSignature)
+	}
+	if int64(tb.IssuedAt) > time.Now().Unix() {
+		return fmt.Errorf("Trust bundle has been issued in the future at %s",
+			tb.IssuedAt.StdTime().Format(time.RFC3339))
+	}
+	if len(tb.Roots) == 0 {
+		return errors.New("Trust bundle doesn't contain root certificates")
+	}
+	for _, root := range tb.Roots {
+		if root == nil {
+			return errors.New("Trust bundle contains an empty root certificate")
+		}
+		if err := RequiresExtension(root, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+			return newValidationError(ErrInvalidKeyUsage, root, "Root certificates of trust bundles need to have the KeyUsage SignCert: %s", err)
+		}
+	}
+	return nil
+}
+
+// VerifyUpdate verifies a TrustBundle meant to replace current, which may be nil if no TrustBundle
+// is installed yet. Besides the checks of Verify the update needs to be part of the same series,
+// have a higher sequence number and must not have expired.
+func (tb *TrustBundle) VerifyUpdate(current *TrustBundle, signingKeys ...ed25519.PublicKey) error {
+	if err := tb.Verify(signingKeys...); err != nil {
+		return err
+	}
+	if int64(tb.Expires) < time.Now().Unix() {
+		return fmt.Errorf("%w: trust bundle expired at %s", ErrStaleTrustBundle,
+			tb.Expires.StdTime().Format(time.RFC3339))
+	}
+	if current == nil {
+		return nil
+	}
+	if tb.Name != current.Name {
+		return fmt.Errorf("Trust bundle '%s' can't replace trust bundle '%s'", tb.Name, current.Name)
+	}
+	if tb.SequenceNumber <= current.SequenceNumber {
+		return fmt.Errorf("%w: sequence number %d is not newer than the installed %d",
+			ErrStaleTrustBundle, tb.SequenceNumber, current.SequenceNumber)
+	}
+	return nil
+}
+
+// Pool returns a CertPool containing the roots of the TrustBundle
+func (tb *TrustBundle) Pool() *CertPool {
+	return NewCertPool(tb.Roots...)
+}
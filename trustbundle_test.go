@@ -0,0 +1,90 @@
+package smolcert
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestTrustBundle(t *testing.T) {
+	signingPub, signingKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	otherPub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	root1, root1Key, err := SelfSignedCertificate("root1", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	root2, _, err := SelfSignedCertificate("root2", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+
+	tb := NewTrustBundle("fleet", 1, []*Certificate{root1, root2}, time.Hour)
+	tb.Metadata = map[string]string{"description": "initial roots"}
+	require.NoError(t, tb.Sign(signingKey))
+	buf, err := tb.Bytes()
+	require.NoError(t, err)
+
+	_, err = LoadTrustBundle(bytes.NewReader(buf), otherPub)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+	installed, err := LoadTrustBundle(bytes.NewReader(buf), otherPub, signingPub)
+	require.NoError(t, err)
+	assert.Equal(t, "initial roots", installed.Metadata["description"])
+	assert.NoError(t, installed.VerifyUpdate(nil, signingPub))
+
+	client, _, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, root1Key, "root1")
+	require.NoError(t, err)
+	assert.NoError(t, installed.Pool().Validate(client))
+
+	// Updates need a higher sequence number
+	update := NewTrustBundle("fleet", 2, []*Certificate{root2}, time.Hour)
+	require.NoError(t, update.Sign(signingKey))
+	assert.NoError(t, update.VerifyUpdate(installed, signingPub))
+	assert.Error(t, update.Pool().Validate(client))
+	err = installed.VerifyUpdate(update, signingPub)
+	assert.True(t, errors.Is(err, ErrStaleTrustBundle))
+	other := NewTrustBundle("other fleet", 3, []*Certificate{root2}, time.Hour)
+	require.NoError(t, other.Sign(signingKey))
+	assert.Error(t, other.VerifyUpdate(installed, signingPub))
+
+	// Expired bundles stay usable, but are no valid updates
+	expired := NewTrustBundle("fleet", 3, []*Certificate{root2}, -time.Minute)
+	require.NoError(t, expired.Sign(signingKey))
+	assert.NoError(t, expired.Verify(signingPub))
+	err = expired.VerifyUpdate(installed, signingPub)
+	assert.True(t, errors.Is(err, ErrStaleTrustBundle))
+
+	// Modifications invalidate the signature
+	parsed, err := ParseTrustBundle(buf)
+	require.NoError(t, err)
+	parsed.SequenceNumber = 10
+	assert.True(t, errors.Is(parsed.Verify(signingPub), ErrBadSignature))
+	parsed, err = ParseTrustBundle(buf)
+	require.NoError(t, err)
+	parsed.Roots = parsed.Roots[:1]
+	assert.True(t, errors.Is(parsed.Verify(signingPub), ErrBadSignature))
+}
+
+func TestTrustBundleRejectsInvalidRoots(t *testing.T) {
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signingPub := signingKey.Public().(ed25519.PublicKey)
+	_, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	client, _, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	tb := NewTrustBundle("fleet", 1, []*Certificate{client}, time.Hour)
+	require.NoError(t, tb.Sign(signingKey))
+	assert.True(t, errors.Is(tb.Verify(signingPub), ErrInvalidKeyUsage))
+
+	tb = NewTrustBundle("fleet", 1, nil, time.Hour)
+	require.NoError(t, tb.Sign(signingKey))
+	assert.Error(t, tb.Verify(signingPub))
+
+	assert.Error(t, tb.Sign(signingKey[:32]))
+	_, err = LoadTrustBundle(bytes.NewReader([]byte{0x01}), signingPub)
+	assert.Error(t, err)
+}
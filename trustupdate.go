@@ -0,0 +1,185 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// DefaultTrustBundleUpdateInterval is the interval in which a TrustBundleUpdater fetches the
+// TrustBundle, if no Interval is set
+const DefaultTrustBundleUpdateInterval = time.Hour
+
+// maxTrustBundleSize limits the size of fetched trust bundles
+const maxTrustBundleSize = 1024 * 1024
+
+type installedTrustBundle struct {
+	bundle *TrustBundle
+	pool   *CertPool
+}
+
+// TrustBundleUpdater keeps the trusted roots of a device up to date with a TrustBundle published
+// via HTTP. Fetched TrustBundles need to be signed by one of SigningKeys, must not have expired and
+// need a higher sequence number than the installed TrustBundle, so neither the server nor the
+// network can roll back the roots or keep devices on a stale root set forever. Updates replace the
+// active CertPool atomically.
+type TrustBundleUpdater struct {
+	// URL is the URL the TrustBundle is fetched from
+	URL string
+	// Client is used to send requests, http.DefaultClient is used by default
+	Client *http.Client
+	// SigningKeys are the pinned bundle-signing keys
+	SigningKeys []ed25519.PublicKey
+	// Interval is the interval in which the TrustBundle is fetched
+	Interval time.Duration
+	// StateFile optionally persists installed updates, so that the sequence number survives
+	// restarts. Updates are only installed once they have been written.
+	StateFile string
+	// OnUpdate is optionally called after an update has been installed
+	OnUpdate func(tb *TrustBundle)
+	// OnError is optionally called when fetching or verifying an update fails. The installed
+	// TrustBundle stays in use.
+	OnError func(err error)
+
+	installed atomic.Value
+	lock      sync.Mutex
+	etag      string
+}
+
+// NewTrustBundleUpdater creates a TrustBundleUpdater fetching updates of installed from u. installed
+// is typically provisioned during manufacturing or loaded with LoadTrustBundleFile and is verified
+// with signingKeys.
+func NewTrustBundleUpdater(u string, installed *TrustBundle, signingKeys ...ed25519.PublicKey) (*TrustBundleUpdater, error) {
+	if installed == nil {
+		return nil, errors.New("An installed trust bundle is required")
+	}
+	if err := installed.Verify(signingKeys...); err != nil {
+		return nil, err
+	}
+	up := &TrustBundleUpdater{URL: u, SigningKeys: signingKeys}
+	up.installed.Store(&installedTrustBundle{bundle: installed, pool: installed.Pool()})
+	return up, nil
+}
+
+// LoadTrustBundleFile loads a TrustBundle from filename, i.e. the StateFile of a TrustBundleUpdater,
+// and verifies it with signingKeys
+func LoadTrustBundleFile(filename string, signingKeys ...ed25519.PublicKey) (*TrustBundle, error) {
+	buf, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadTrustBundle(bytes.NewReader(buf), signingKeys...)
+}
+
+// TrustBundle returns the installed TrustBundle
+func (up *TrustBundleUpdater) TrustBundle() *TrustBundle {
+	return up.installed.Load().(*installedTrustBundle).bundle
+}
+
+// Pool returns the CertPool of the installed TrustBundle. Every update installs a new CertPool,
+// setting it as the Pool of a Validator drops the results of its ValidationCache.
+func (up *TrustBundleUpdater) Pool() *CertPool {
+	return up.installed.Load().(*installedTrustBundle).pool
+}
+
+// Update fetches the TrustBundle and installs it, if it is a valid update of the installed
+// TrustBundle. It returns true if an update has been installed. Fetching the installed TrustBundle
+// again is not an error.
+func (up *TrustBundleUpdater) Update(ctx context.Context) (bool, error) {
+	up.lock.Lock()
+	defer up.lock.Unlock()
+	buf, etag, err := up.fetch(ctx)
+	if err != nil || buf == nil {
+		return false, err
+	}
+	tb, err := ParseTrustBundle(buf)
+	if err != nil {
+		return false, fmt.Errorf("Invalid trust bundle at %s: %w", up.URL, err)
+	}
+	current := up.TrustBundle()
+	if tb.Name == current.Name && tb.SequenceNumber == current.SequenceNumber &&
+		bytes.Equal(tb.Signature, current.Signature) {
+		up.etag = etag
+		return false, nil
+	}
+	if err := tb.VerifyUpdate(current, up.SigningKeys...); err != nil {
+		return false, err
+	}
+	if up.StateFile != "" {
+		if err := WriteFileAtomic(up.StateFile, buf, CertFileMode); err != nil {
+			return false, fmt.Errorf("Failed to persist trust bundle: %w", err)
+		}
+	}
+	up.installed.Store(&installedTrustBundle{bundle: tb, pool: tb.Pool()})
+	up.etag = etag
+	if up.OnUpdate != nil {
+		up.OnUpdate(tb)
+	}
+	return true, nil
+}
+
+// fetch returns nil if the server reports that the TrustBundle has not been modified
+func (up *TrustBundleUpdater) fetch(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, up.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if up.etag != "" {
+		req.Header.Set("If-None-Match", up.etag)
+	}
+	client := up.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("Fetching trust bundle from %s failed with %s", up.URL, resp.Status)
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTrustBundleSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(buf) > maxTrustBundleSize {
+		return nil, "", fmt.Errorf("Trust bundle at %s is too large", up.URL)
+	}
+	return buf, resp.Header.Get("ETag"), nil
+}
+
+// Run fetches the TrustBundle in every Interval until ctx is done. Errors are reported to OnError.
+func (up *TrustBundleUpdater) Run(ctx context.Context) {
+	interval := up.Interval
+	if interval <= 0 {
+		interval = DefaultTrustBundleUpdateInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := up.Update(ctx); err != nil && up.OnError != nil {
+				up.OnError(err)
+			}
+		}
+	}
+}
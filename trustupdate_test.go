@@ -0,0 +1,168 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+// trustBundleServer serves the last published trust bundle with an ETag
+type trustBundleServer struct {
+	lock     sync.Mutex
+	bundle   []byte
+	requests int
+}
+
+func (s *trustBundleServer) publish(t *testing.T, tb *TrustBundle) {
+	buf, err := tb.Bytes()
+	require.NoError(t, err)
+	s.lock.Lock()
+	s.bundle = buf
+	s.lock.Unlock()
+}
+
+func (s *trustBundleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests++
+	if s.bundle == nil {
+		http.NotFound(w, r)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(s.bundle))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Write(s.bundle)
+}
+
+func signedTrustBundle(t *testing.T, sequenceNumber uint64, validFor time.Duration, key ed25519.PrivateKey,
+	roots ...*Certificate) *TrustBundle {
+	tb := NewTrustBundle("fleet", sequenceNumber, roots, validFor)
+	require.NoError(t, tb.Sign(key))
+	return tb
+}
+
+func TestTrustBundleUpdater(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smolcert-trust")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	signingPub, signingKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	oldRoot, oldRootKey, err := SelfSignedCertificate("old root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	newRoot, newRootKey, err := SelfSignedCertificate("new root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	oldClient, _, err := ClientCertificate("device", 1, time.Time{}, time.Time{}, nil, oldRootKey, "old root")
+	require.NoError(t, err)
+	newClient, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, newRootKey, "new root")
+	require.NoError(t, err)
+
+	installed := signedTrustBundle(t, 1, time.Hour, signingKey, oldRoot)
+	server := &trustBundleServer{}
+	server.publish(t, installed)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	_, err = NewTrustBundleUpdater(ts.URL, installed)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+	up, err := NewTrustBundleUpdater(ts.URL, installed, signingPub)
+	require.NoError(t, err)
+	up.StateFile = filepath.Join(dir, "trust.cbor")
+	assert.NoError(t, up.Pool().Validate(oldClient))
+
+	// The installed bundle is no update
+	updated, err := up.Update(context.Background())
+	require.NoError(t, err)
+	assert.False(t, updated)
+	updated, err = up.Update(context.Background())
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, 2, server.requests)
+
+	server.publish(t, signedTrustBundle(t, 2, time.Hour, signingKey, newRoot))
+	updated, err = up.Update(context.Background())
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, uint64(2), up.TrustBundle().SequenceNumber)
+	assert.NoError(t, up.Pool().Validate(newClient))
+	assert.Error(t, up.Pool().Validate(oldClient))
+	persisted, err := LoadTrustBundleFile(up.StateFile, signingPub)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), persisted.SequenceNumber)
+
+	// Rollbacks, expired bundles and bundles signed by other keys are rejected
+	for _, tb := range []*TrustBundle{
+		installed,
+		signedTrustBundle(t, 3, -time.Minute, signingKey, oldRoot),
+		signedTrustBundle(t, 3, time.Hour, otherKey, oldRoot),
+	} {
+		server.publish(t, tb)
+		updated, err = up.Update(context.Background())
+		assert.Error(t, err)
+		assert.False(t, updated)
+		assert.Equal(t, uint64(2), up.TrustBundle().SequenceNumber)
+	}
+	assert.NoError(t, up.Pool().Validate(newClient))
+
+	server.lock.Lock()
+	server.bundle = nil
+	server.lock.Unlock()
+	_, err = up.Update(context.Background())
+	assert.Error(t, err)
+}
+
+func TestTrustBundleUpdaterRun(t *testing.T) {
+	signingPub, signingKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	root, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	server := &trustBundleServer{}
+	installed := signedTrustBundle(t, 1, time.Hour, signingKey, root)
+	server.publish(t, installed)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	up, err := NewTrustBundleUpdater(ts.URL, installed, signingPub)
+	require.NoError(t, err)
+	up.Interval = 10 * time.Millisecond
+	updates := make(chan *TrustBundle, 1)
+	up.OnUpdate = func(tb *TrustBundle) { updates <- tb }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		up.Run(ctx)
+		close(done)
+	}()
+
+	server.publish(t, signedTrustBundle(t, 5, time.Hour, signingKey, root))
+	select {
+	case tb := <-updates:
+		assert.Equal(t, uint64(5), tb.SequenceNumber)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Update hasn't been installed")
+	}
+	cancel()
+	<-done
+}
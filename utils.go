@@ -1,18 +1,23 @@
 package smolcert
 
 import (
-	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
 	"time"
 
 	"golang.org/x/crypto/ed25519"
 )
 
+// ensureExtension returns a copy of extensions in which the extension with the OID of extp is
+// replaced by extp or extp is appended. The slice of the caller is left untouched.
 func ensureExtension(extensions []Extension, extp Extension) []Extension {
+	extensions = append(make([]Extension, 0, len(extensions)+1), extensions...)
 	found := false
-	for _, ext := range extensions {
-		if ext.OID == extp.OID {
-			ext.Critical = extp.Critical
-			ext.Value = extp.Value
+	for i := range extensions {
+		if extensions[i].OID == extp.OID {
+			extensions[i].Critical = extp.Critical
+			extensions[i].Value = extp.Value
 			found = true
 			break
 		}
@@ -25,8 +30,9 @@ func ensureExtension(extensions []Extension, extp Extension) []Extension {
 
 // SignCertificate takes a certificate, removes the signature and creates a new signature with the given key
 func SignCertificate(cert *Certificate, priv ed25519.PrivateKey) (*Certificate, error) {
+	cert.SignatureAlgorithm = AlgorithmEd25519
 	cert.Signature = nil
-	certBytes, err := cert.Bytes()
+	certBytes, err := cert.TBSBytes()
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +40,58 @@ func SignCertificate(cert *Certificate, priv ed25519.PrivateKey) (*Certificate,
 	return cert, nil
 }
 
+// CrossSignCertificate issues a copy of cert with the same subject, public key, validity and extensions,
+// but signed by a different issuer. This allows to issue certificates under an old and a new root
+// during a root rotation, ValidateBundle accepts chains via either of them.
+func CrossSignCertificate(cert *Certificate, serialNumber uint64, issuer string,
+	issuerKey ed25519.PrivateKey) (*Certificate, error) {
+	crossCert := cert.Copy()
+	crossCert.SerialNumber = serialNumber
+	crossCert.Issuer = issuer
+	return SignCertificate(crossCert, issuerKey)
+}
+
+// Renew reissues a certificate with the same issuer, subject, public key and extensions, but with
+// a new random serial number and the given validity. This is useful to rotate certificates without
+// the need to distribute new keys.
+func Renew(cert *Certificate, newValidity *Validity, caKey ed25519.PrivateKey) (*Certificate, error) {
+	serialNumber, err := RandomSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	renewed := cert.Copy()
+	renewed.SerialNumber = serialNumber
+	renewed.Validity = &Validity{
+		NotBefore: newValidity.NotBefore,
+		NotAfter:  newValidity.NotAfter,
+	}
+	return SignCertificate(renewed, caKey)
+}
+
+// maxSerialNumberAttempts limits the attempts to draw a serial number, so a broken random source,
+// i.e. one returning only zeros, can't block the issuance forever
+const maxSerialNumberAttempts = 16
+
+// RandomSerialNumber creates a random non zero serial number for newly issued certificates
+func RandomSerialNumber() (uint64, error) {
+	return RandomSerialNumberFrom(nil)
+}
+
+// RandomSerialNumberFrom creates a random serial number from r, or from the random source set
+// with SetRandomSource if r is nil
+func RandomSerialNumberFrom(r io.Reader) (uint64, error) {
+	var buf [8]byte
+	for i := 0; i < maxSerialNumberAttempts; i++ {
+		if err := readRandom(r, buf[:]); err != nil {
+			return 0, err
+		}
+		if serial := binary.BigEndian.Uint64(buf[:]); serial != 0 {
+			return serial, nil
+		}
+	}
+	return 0, errors.New("Random source doesn't produce usable serial numbers")
+}
+
 // ClientCertificate is a convenience function to create a valid client certificate
 func ClientCertificate(subject string, serialNumber uint64, notBefore, notAfter time.Time,
 	extensions []Extension, rootKey ed25519.PrivateKey, issuer string) (*Certificate, ed25519.PrivateKey, error) {
@@ -75,7 +133,7 @@ func SignedCertificate(subject string, serialNumber uint64, notBefore, notAfter
 	} else {
 		validity.NotAfter = NewTime(notAfter)
 	}
-	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	pub, priv, err := GenerateKey(nil)
 	if err != nil {
 		return nil, ed25519.PrivateKey{}, err
 	}
@@ -116,7 +174,7 @@ func SelfSignedCertificate(subject string,
 	} else {
 		validity.NotAfter = NewTime(notAfter)
 	}
-	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	pub, priv, err := GenerateKey(nil)
 	if err != nil {
 		return nil, ed25519.PrivateKey{}, err
 	}
@@ -1,123 +1,541 @@
 package smolcert
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"golang.org/x/crypto/ed25519"
 )
 
-// CertPool is a pool of root certificates which can be used to validate a certificate
-type CertPool map[string]*Certificate
+// CertPool is a pool of root certificates which can be used to validate a certificate. The
+// certificates are indexed by the Pin of their public key, so the pool may contain several roots
+// with the same subject, i.e. during the rotation of a root key. They are indexed by subject as
+// well, so issuers are found without looking at every root. The zero value is an empty pool.
+type CertPool struct {
+	roots map[string]*Certificate
+	// subjects holds the roots by the indexKey of their subject, sorted by Pin
+	subjects map[string][]*Certificate
+}
 
 // NewCertPool creates a new CertPool from a group of root certificates
 func NewCertPool(rootCerts ...*Certificate) *CertPool {
-	p := make(CertPool)
+	p := &CertPool{}
 	for _, c := range rootCerts {
-		if err := RequiresExtension(c, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
-			// Ignore certificates which do not specify to be used to sign certificates silently
-			continue
+		p.Add(c)
+	}
+	return p
+}
+
+// Add adds a root certificate to the pool. Certificates which are not allowed to sign
+// certificates are ignored, in this case false is returned. A certificate with the same public
+// key as a certificate already in the pool replaces the existing certificate.
+func (c *CertPool) Add(cert *Certificate) bool {
+	if err := RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+		return false
+	}
+	c.add(cert)
+	return true
+}
+
+// add adds cert to the indexes without checking its KeyUsage
+func (c *CertPool) add(cert *Certificate) {
+	if c.roots == nil {
+		c.roots = map[string]*Certificate{}
+		c.subjects = map[string][]*Certificate{}
+	}
+	pin := PinFromCertificate(cert).String()
+	if old := c.roots[pin]; old != nil {
+		c.remove(old, pin)
+	}
+	c.roots[pin] = cert
+	key := indexKey(cert.Subject)
+	certs := c.subjects[key]
+	i := sort.Search(len(certs), func(i int) bool { return PinFromCertificate(certs[i]).String() >= pin })
+	certs = append(certs, nil)
+	copy(certs[i+1:], certs[i:])
+	certs[i] = cert
+	c.subjects[key] = certs
+}
+
+func (c *CertPool) remove(cert *Certificate, pin string) {
+	delete(c.roots, pin)
+	key := indexKey(cert.Subject)
+	certs := c.subjects[key]
+	remaining := make([]*Certificate, 0, len(certs))
+	for _, other := range certs {
+		if other != cert {
+			remaining = append(remaining, other)
 		}
-		p[c.Subject] = c
 	}
-	return &p
+	if len(remaining) == 0 {
+		delete(c.subjects, key)
+		return
+	}
+	c.subjects[key] = remaining
+}
+
+// Len returns the number of certificates in the pool
+func (c *CertPool) Len() int {
+	return len(c.roots)
+}
+
+// All returns all certificates of the pool, sorted by the Pin of their public key
+func (c *CertPool) All() []*Certificate {
+	pins := make([]string, 0, len(c.roots))
+	for pin := range c.roots {
+		pins = append(pins, pin)
+	}
+	sort.Strings(pins)
+	certs := make([]*Certificate, len(pins))
+	for i, pin := range pins {
+		certs[i] = c.roots[pin]
+	}
+	return certs
+}
+
+// Certificates returns all certificates of the pool with the given subject, sorted by the Pin of
+// their public key
+func (c *CertPool) Certificates(subject string) []*Certificate {
+	return c.matching(subject, NameMatchExact)
+}
+
+// matching returns the certificates of the pool with a subject matching subject under m
+func (c *CertPool) matching(subject string, m NameMatching) []*Certificate {
+	var certs []*Certificate
+	for _, cert := range c.subjects[indexKey(subject)] {
+		if m.Match(cert.Subject, subject) {
+			certs = append(certs, cert)
+		}
+	}
+	return certs
+}
+
+// issuer returns the root certificate of the pool which issued cert. If several roots share the
+// subject of the issuer, the first one with a valid signature on cert is returned and signed is
+// true, so the signature doesn't need to be verified again. If none of them signed cert, the first
+// one is returned. The issuer is matched under the NameMatching of opts, the signatures are
+// checked by up to the concurrency of opts goroutines.
+func (c *CertPool) issuer(cert *Certificate, opts verifyOptions) (issuer *Certificate, signed, exists bool) {
+	candidates := c.matching(cert.Issuer, opts.nameMatching)
+	concurrency := opts.concurrency
+	switch len(candidates) {
+	case 0:
+		return nil, false, false
+	case 1:
+		return candidates[0], false, true
+	}
+	if concurrency < 2 {
+		for _, candidate := range candidates {
+			if verifyCertificateSignature(cert, candidate) {
+				return candidate, true, true
+			}
+		}
+		return candidates[0], false, true
+	}
+	verified := make([]bool, len(candidates))
+	parallel(len(candidates), concurrency, func(i int) {
+		verified[i] = verifyCertificateSignature(cert, candidates[i])
+	})
+	for i, ok := range verified {
+		if ok {
+			return candidates[i], true, true
+		}
+	}
+	return candidates[0], false, true
 }
 
 // Validate takes a certificate, checks if the issuer is known to the CertPool, validates
 // the issuer certificate and then validates the given certificate against the issuer certificate
 func (c *CertPool) Validate(cert *Certificate) error {
+	return c.ValidateContext(context.Background(), cert)
+}
+
+// ValidateContext validates cert like Validate, but aborts with the error of ctx once it is done
+func (c *CertPool) ValidateContext(ctx context.Context, cert *Certificate) error {
+	v := &chainVerifier{ctx: ctx}
+	if err := c.validate(cert, v); err != nil {
+		return err
+	}
+	return v.finish()
+}
 
-	issuerCert, exists := (*c)[cert.Issuer]
-	// A nil root cert shouldn't happen, but who knows
-	if !exists || issuerCert == nil {
-		return errors.New("certificate is not signed by a known issuer")
+// ValidateCertificates validates multiple certificates against this CertPool, verifying all
+// signatures in a single batch. The returned slice contains the validation result for each
+// certificate in the same order, nil meaning the certificate is valid.
+func (c *CertPool) ValidateCertificates(certs ...*Certificate) []error {
+	errs := make([]error, len(certs))
+	v := &chainVerifier{}
+	owners := []int{}
+	for i, cert := range certs {
+		before := v.checkpoint()
+		if err := c.validate(cert, v); err != nil {
+			errs[i] = err
+			// Drop the signatures queued for an already failed certificate
+			v.truncate(before)
+			continue
+		}
+		for j := before.checks; j < len(v.checks); j++ {
+			owners = append(owners, i)
+		}
 	}
+	if v.batch.Verify() {
+		return errs
+	}
+	for j, ok := range v.batch.VerifyEach() {
+		if !ok && errs[owners[j]] == nil {
+			errs[owners[j]] = v.checks[j]
+		}
+	}
+	return errs
+}
+
+func (c *CertPool) validate(cert *Certificate, v *chainVerifier) error {
+	issuerCert, signed, exists := c.issuer(cert, v.opts)
+	if !exists {
+		return newValidationError(ErrUnknownIssuer, cert, "certificate is not signed by a known issuer")
+	}
+	v.root = issuerCert
 	// Validate the issuer cert, might be invalid too (expired etc.)
-	if err := validateCertificate(issuerCert, issuerCert.PubKey); err != nil {
+	if err := v.verify(issuerCert, issuerCert, func(err error) error {
 		return fmt.Errorf("Error validating issuing root certificate: %w", err)
+	}); err != nil {
+		return err
 	}
 	if err := RequiresExtension(issuerCert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
-		return fmt.Errorf("Trusted root certificates need to have the KeyUsage SignCert: %w", err)
+		return newValidationError(ErrUntrustedRoot, issuerCert, "Trusted root certificates need to have the KeyUsage SignCert: %s", err)
 	}
 
-	return validateCertificate(cert, issuerCert.PubKey)
+	if signed {
+		return v.verifySigned(cert, issuerCert, nil)
+	}
+	return v.verify(cert, issuerCert, nil)
 }
 
 // ValidateBundle validates a given bundle of certificates. It tries to build a chain of certificates
 // within the given bundle. Uses the leaf as the client certificate and tries to validate the top
 // certificate against the CertPool.
+// The bundle may contain several certificates for the same subject (i.e. an intermediate cross
+// signed by an old and a new root), the bundle is valid if any of the possible chains is valid.
+// Use Bundle.Validate to validate ordered chains.
 func (c *CertPool) ValidateBundle(certBundle []*Certificate) (clientCert *Certificate, err error) {
-	// FIXME when we have defined extensions, validate capabilities of certificates through extensions
+	return c.validateBundle(certBundle, &chainVerifier{})
+}
+
+// ValidateBundleContext validates a bundle like ValidateBundle, but aborts with the error of ctx
+// once it is done
+func (c *CertPool) ValidateBundleContext(ctx context.Context, certBundle []*Certificate) (*Certificate, error) {
+	return c.validateBundle(certBundle, &chainVerifier{ctx: ctx})
+}
+
+func (c *CertPool) validateBundle(certBundle []*Certificate, v *chainVerifier) (clientCert *Certificate, err error) {
+	m := v.opts.nameMatching
+	clientCert, subjectMap := findLeaf(certBundle, m)
+	if clientCert == nil {
+		return nil, errors.New("Can't find non-intermediate certificate in certificate chain")
+	}
+
+	if _, found := subjectMap[m.CanonicalName(clientCert.Issuer)]; !found {
+		// Might be that the certificate is already trusted through the current pool
+		if err = c.validate(clientCert, v); err == nil {
+			if err = v.finish(); err == nil {
+				return clientCert, nil
+			}
+		}
+		return nil, fmt.Errorf("No issuer for the client certificate was found in the intermediate certificates: %w", err)
+	}
+
+	// All signatures of a possible chain are collected and verified at once
+	visited := map[*Certificate]bool{clientCert: true}
+	if err := c.buildChain(clientCert, subjectMap, visited, 0, v); err != nil {
+		return nil, err
+	}
+	return clientCert, nil
+}
+
+// findLeaf returns the certificate of the bundle which hasn't issued any other certificate of
+// the bundle and the certificates of the bundle indexed by the canonical name of their subject
+// under m.
+func findLeaf(certBundle []*Certificate, m NameMatching) (leaf *Certificate, subjectMap map[string][]*Certificate) {
 	issuerMap := make(map[string]*Certificate)
-	subjectMap := make(map[string]*Certificate)
+	subjectMap = make(map[string][]*Certificate)
 	for _, cert := range certBundle {
-		issuerMap[cert.Issuer] = cert
-		subjectMap[cert.Subject] = cert
+		issuerMap[m.CanonicalName(cert.Issuer)] = cert
+		subject := m.CanonicalName(cert.Subject)
+		subjectMap[subject] = append(subjectMap[subject], cert)
 	}
 
-	var intermediateCerts []*Certificate
 	for _, cert := range certBundle {
-		if _, found := issuerMap[cert.Subject]; found {
-			intermediateCerts = append(intermediateCerts, cert)
-			continue
-		} else {
-			clientCert = cert
+		if _, found := issuerMap[m.CanonicalName(cert.Subject)]; !found {
+			leaf = cert
 		}
 	}
+	return leaf, subjectMap
+}
 
-	if clientCert == nil {
-		return nil, errors.New("Can't find non-intermediate certificate in certificate chain")
+// Limits of the search for chains in bundles, so bundles with many intermediates sharing subjects
+// can't make the validation take exponential time
+const (
+	// maxChainDepth is the maximum number of intermediates of a chain built from a bundle
+	maxChainDepth = 8
+	// maxChainPaths is the maximum number of paths from the leaf followed for a bundle
+	maxChainPaths = 64
+)
+
+// buildChain searches for a valid chain from cert to one of the certificates in the pool, using the
+// certificates in subjectMap as intermediates. The signatures already queued in v belong to the
+// path of depth intermediates leading to cert. If cert has several candidate issuers, the
+// signature of each candidate is checked before the path is followed any further, instead of
+// being queued.
+func (c *CertPool) buildChain(cert *Certificate, subjectMap map[string][]*Certificate,
+	visited map[*Certificate]bool, depth int, v *chainVerifier) error {
+	if v.paths >= maxChainPaths {
+		return newValidationError(ErrUntrustedRoot, cert, "No valid chain found within %d candidate paths", maxChainPaths)
 	}
+	v.paths++
+	queued := v.checkpoint()
 
-	if clientIssuer, found := subjectMap[clientCert.Issuer]; found {
-		if err := validateCertificate(clientCert, clientIssuer.PubKey); err != nil {
-			return nil, err
-		}
-	} else {
-		// Might be that the certificate is already trusted through the current pool
-		if err = c.Validate(clientCert); err == nil {
-			return clientCert, nil
+	candidates := subjectMap[v.opts.nameMatching.CanonicalName(cert.Issuer)]
+	var lastErr error
+	if trusted := len(c.matching(cert.Issuer, v.opts.nameMatching)) > 0; trusted || len(candidates) == 0 {
+		if lastErr = c.validate(cert, v); lastErr == nil {
+			if lastErr = v.finish(); lastErr == nil {
+				return nil
+			}
 		}
-		return nil, errors.New("No issuer for the client certificate was found in the intermediate certificates: " + err.Error())
+		v.truncate(queued)
 	}
 
-	var chainTopCert *Certificate
-	// Validate the chain of intermediate certs
-	for _, cert := range intermediateCerts {
-		if err := RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
-			return nil, fmt.Errorf("Intermediate certificate (subject '%s', does not possess KeyUsage SignCert: %w", cert.Subject, err)
+	for _, issuerCert := range candidates {
+		if v.paths >= maxChainPaths {
+			// Stop the whole search, the remaining candidates can't be followed anyway
+			return newValidationError(ErrUntrustedRoot, cert, "No valid chain found within %d candidate paths", maxChainPaths)
 		}
-		if issuerCert, exists := subjectMap[cert.Issuer]; exists {
-			if err := validateCertificate(cert, issuerCert.PubKey); err != nil {
-				return nil, errors.New("Validation error in chain of intermediate certificates")
+		if visited[issuerCert] {
+			if lastErr == nil {
+				lastErr = newValidationError(ErrUntrustedRoot, cert,
+					"The intermediate chain is self signed and not signed by one of the root certs of this pool")
 			}
+			continue
+		}
+		if err := RequiresExtension(issuerCert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+			lastErr = newValidationError(ErrInvalidKeyUsage, issuerCert,
+				"Intermediate certificate (subject '%s', does not possess KeyUsage SignCert: %s", issuerCert.Subject, err)
+			continue
+		}
+		if depth >= maxChainDepth {
+			lastErr = newValidationError(ErrUntrustedRoot, cert, "Chain of intermediate certificates exceeds %d certificates", maxChainDepth)
+			break
+		}
+		wrap := func(err error) error {
+			return fmt.Errorf("Validation error in chain of intermediate certificates: %w", err)
+		}
+		var err error
+		if len(candidates) > 1 {
+			if !verifyCertificateSignature(cert, issuerCert) {
+				lastErr = newValidationError(ErrBadSignature, cert, "Signature validation failed")
+				continue
+			}
+			err = v.verifySigned(cert, issuerCert, wrap)
 		} else {
-			chainTopCert = cert
+			err = v.verify(cert, issuerCert, wrap)
+		}
+		if err != nil {
+			lastErr = err
+			continue
 		}
+		visited[issuerCert] = true
+		lastErr = c.buildChain(issuerCert, subjectMap, visited, depth+1, v)
+		visited[issuerCert] = false
+		if lastErr == nil {
+			return nil
+		}
+		v.truncate(queued)
 	}
+	return lastErr
+}
+
+// chainVerifier performs the cheap checks of certificates immediately and defers the signature
+// checks, so that all signatures can be verified in one batch.
+type chainVerifier struct {
+	batch  BatchVerifier
+	checks []error
+	ctx    context.Context
+	hooks  []ContextValidationHook
+	opts   verifyOptions
+	// root is the trusted root of the last chain checked
+	root *Certificate
+	// edges are the certificates of the current chain with their issuers
+	edges []chainEdge
+	// paths is the number of paths from the leaf followed by ValidateBundle
+	paths int
+}
+
+// verify checks validity and extensions of cert and queues its signatures for verification against
+// issuer. wrap may adapt the errors returned for cert, it may be nil.
+func (v *chainVerifier) verify(cert, issuer *Certificate, wrap func(error) error) error {
+	return v.verifyEdge(cert, issuer, wrap, true)
+}
+
+// verifySigned checks cert like verify, but its signatures have already been verified against
+// issuer, so they are not queued again
+func (v *chainVerifier) verifySigned(cert, issuer *Certificate, wrap func(error) error) error {
+	return v.verifyEdge(cert, issuer, wrap, false)
+}
 
-	if chainTopCert == nil {
-		return nil, errors.New("The intermediate chain is self signed and not signed by one of the root certs of this pool")
+func (v *chainVerifier) verifyEdge(cert, issuer *Certificate, wrap func(error) error, queue bool) error {
+	if wrap == nil {
+		wrap = func(err error) error { return err }
 	}
-	if err := c.Validate(chainTopCert); err != nil {
-		return nil, err
+	ctx := v.ctx
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	return clientCert, nil
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := checkCertificate(cert, v.opts); err != nil {
+		return wrap(err)
+	}
+	if err := v.opts.check(cert); err != nil {
+		return wrap(err)
+	}
+	if err := checkNameConstraints(cert, issuer); err != nil {
+		return wrap(err)
+	}
+	if err := checkCapabilities(cert, issuer); err != nil {
+		return wrap(err)
+	}
+	if err := checkIssuerPolicy(cert, issuer); err != nil {
+		return wrap(err)
+	}
+	v.edges = append(v.edges, chainEdge{cert: cert, issuer: issuer, wrap: wrap})
+	if !queue {
+		return nil
+	}
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return wrap(newValidationError(ErrMalformedCertificate, cert, "Failed to serialize certificate for validation"))
+	}
+	sigs, err := issuerSignatures(cert, issuer)
+	if err != nil {
+		return wrap(err)
+	}
+	for _, sig := range sigs {
+		if sig.prehashed {
+			v.batch.AddPrehashed(sig.pubKey, certBytes, sig.signature)
+		} else {
+			v.batch.Add(sig.pubKey, certBytes, sig.signature)
+		}
+		v.checks = append(v.checks, wrap(newValidationError(ErrBadSignature, cert, "Signature validation failed")))
+	}
+	return nil
+}
+
+// chainEdge is a certificate of a chain and its issuer, wrap adapts the errors of hooks for cert
+type chainEdge struct {
+	cert, issuer *Certificate
+	wrap         func(error) error
+}
+
+// checkpoint is a position in the queued signatures and edges of a chainVerifier
+type checkpoint struct {
+	checks, edges int
+}
+
+func (v *chainVerifier) checkpoint() checkpoint {
+	return checkpoint{checks: len(v.checks), edges: len(v.edges)}
+}
+
+// truncate drops all signatures and edges queued after p
+func (v *chainVerifier) truncate(p checkpoint) {
+	v.batch.entries = v.batch.entries[:p.checks]
+	v.checks = v.checks[:p.checks]
+	v.edges = v.edges[:p.edges]
+}
+
+// issuerOf returns the issuer of cert in the current chain or nil
+func (v *chainVerifier) issuerOf(cert *Certificate) *Certificate {
+	for _, edge := range v.edges {
+		if edge.cert == cert {
+			return edge.issuer
+		}
+	}
+	return nil
+}
+
+// finish verifies all queued signatures and returns the error of the first invalid signature. If
+// all signatures are valid, the hooks are called for the edges of the chain.
+func (v *chainVerifier) finish() error {
+	if !v.batch.Verify() {
+		for i, ok := range v.batch.VerifyEach() {
+			if !ok {
+				return v.checks[i]
+			}
+		}
+	}
+	return v.runHooks()
+}
+
+// runHooks calls the hooks for every certificate of the chain and returns the first veto
+func (v *chainVerifier) runHooks() error {
+	if len(v.hooks) == 0 {
+		return nil
+	}
+	ctx := v.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for _, edge := range v.edges {
+		for _, hook := range v.hooks {
+			if err := hook(ctx, edge.cert, edge.issuer); err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				var validationErr *ValidationError
+				if errors.As(err, &validationErr) {
+					return edge.wrap(err)
+				}
+				return edge.wrap(newValidationError(ErrRejectedByHook, edge.cert, "%s", err))
+			}
+		}
+	}
+	return nil
 }
 
+// rfc3339Millis formats times in validation errors, omitting the milliseconds if they are zero
+const rfc3339Millis = "2006-01-02T15:04:05.999Z07:00"
+
+// validateValidity checks the validity of cert. Certificates without validity, which is required
+// by the format but tolerated when parsing, are malformed.
 func validateValidity(cert *Certificate) error {
-	nowUnix := time.Now().Unix()
-	if !cert.Validity.NotBefore.IsZero() {
-		if int64(cert.Validity.NotBefore) > nowUnix {
-			return fmt.Errorf("certificate is not valid before %s (notBefore %d, now %d)",
-				cert.Validity.NotBefore.StdTime().Format(time.RFC3339), cert.Validity.NotBefore, nowUnix)
+	if cert.Validity == nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Certificate has no validity")
+	}
+	return checkValidity(cert, *cert.Validity)
+}
+
+// checkValidity checks validity, which is passed as value so it can stay on the stack
+func checkValidity(cert *Certificate, validity Validity) error {
+	now := time.Now()
+	if !validity.NotBefore.IsZero() {
+		if notBefore := validity.NotBeforeTime(); notBefore.After(now) {
+			return newValidationError(ErrNotYetValid, cert, "certificate is not valid before %s (notBefore %d, now %d)",
+				notBefore.Format(rfc3339Millis), validity.NotBefore, now.Unix())
 		}
 	}
 
-	if !cert.Validity.NotAfter.IsZero() {
-		if int64(cert.Validity.NotAfter) < nowUnix {
-			return fmt.Errorf("certificate is not valid since %s", cert.Validity.NotAfter.StdTime().Format(time.RFC3339))
+	if !validity.NotAfter.IsZero() {
+		// Certificates with second precision are valid until the end of their NotAfter second
+		notAfter := validity.NotAfterTime()
+		if cert.Version < Version3 {
+			notAfter = notAfter.Add(time.Second - time.Nanosecond)
+		}
+		if notAfter.Before(now) {
+			return newValidationError(ErrExpired, cert, "certificate is not valid since %s",
+				validity.NotAfterTime().Format(rfc3339Millis))
 		}
 	}
 	return nil
@@ -128,31 +546,65 @@ func checkForDoubleExtensions(cert *Certificate) error {
 
 	for _, ext := range cert.Extensions {
 		if seen[ext.OID] {
-			return fmt.Errorf("This certificate contains a repeated extension (OID: %X) which is invalid", ext.OID)
+			return newValidationError(ErrDuplicateExtension, cert,
+				"This certificate contains a repeated extension (OID: %X) which is invalid", ext.OID)
 		}
 		seen[ext.OID] = true
 	}
 	return nil
 }
 
-func validateCertificate(origCert *Certificate, pubKey ed25519.PublicKey) error {
-	cert := origCert.Copy()
+// checkCertificate performs all checks of a certificate, which don't require its issuer
+func checkCertificate(cert *Certificate, opts verifyOptions) error {
+	if problems := certificateProblems(cert, opts); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// certificateProblems returns all problems of a certificate, which can be found without its issuer
+func certificateProblems(cert *Certificate, opts verifyOptions) []error {
+	var problems []error
+	if err := checkVersion(cert, opts.acceptUnknownFields); err != nil {
+		problems = append(problems, err)
+	}
+	if err := checkAlgorithm(cert); err != nil {
+		problems = append(problems, err)
+	}
 	if err := validateValidity(cert); err != nil {
-		return err
+		problems = append(problems, err)
 	}
 	if err := checkForDoubleExtensions(cert); err != nil {
-		return err
+		problems = append(problems, err)
 	}
-	sig := cert.Signature
+	if err := checkCriticalExtensions(cert); err != nil {
+		problems = append(problems, err)
+	}
+	return problems
+}
+
+func checkCriticalExtensions(cert *Certificate) error {
+	for _, ext := range cert.Extensions {
+		if ext.Critical && !isKnownCriticalExtension(ext.OID) {
+			return newValidationError(ErrUnknownCriticalExtension, cert,
+				"This certificate contains an unknown critical extension (OID: %X)", ext.OID)
+		}
+	}
+	return nil
+}
 
-	cert.Signature = nil
-	certBytes, err := cert.Bytes()
+func validateCertificate(cert *Certificate, pubKey ed25519.PublicKey) error {
+	if err := checkCertificate(cert, verifyOptions{}); err != nil {
+		return err
+	}
 
+	certBytes, err := cert.TBSBytes()
 	if err != nil {
-		return errors.New("Failed to serialize certificate for validation")
+		return newValidationError(ErrMalformedCertificate, cert, "Failed to serialize certificate for validation")
 	}
-	if !ed25519.Verify(pubKey, certBytes, sig) {
-		return errors.New("Signature validation failed")
+	sig := issuerSignature{pubKey: pubKey, signature: cert.Signature, prehashed: cert.SignatureAlgorithm == AlgorithmEd25519ph}
+	if !sig.verify(certBytes) {
+		return newValidationError(ErrBadSignature, cert, "Signature validation failed")
 	}
 	return nil
 }
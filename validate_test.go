@@ -1,7 +1,9 @@
 package smolcert
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -184,6 +186,29 @@ func TestExpiredRootShouldntValidateClientCert(t *testing.T) {
 	assert.Error(t, pool.Validate(clientCert))
 }
 
+func TestCertificateWithoutValidityIsMalformed(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	clientCert, _, err := ClientCertificate("client1", 2, time.Time{}, time.Time{}, nil, rootKey, rootCert.Subject)
+	require.NoError(t, err)
+	clientCert.Validity = nil
+	clientCert, err = SignCertificate(clientCert, rootKey)
+	require.NoError(t, err)
+
+	// The validity is encoded as null, which is tolerated when parsing
+	buf, err := clientCert.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	require.Nil(t, parsed.Validity)
+
+	pool := NewCertPool(rootCert)
+	assert.True(t, errors.Is(pool.Validate(parsed), ErrMalformedCertificate))
+	_, err = pool.ValidateBundle([]*Certificate{parsed})
+	assert.True(t, errors.Is(err, ErrMalformedCertificate))
+	assert.True(t, errors.Is(VerifyRaw(buf, rootCert.PubKey), ErrMalformedCertificate))
+}
+
 func TestRootCertDoesNotValidateWithoutCorrectKeyExtension(t *testing.T) {
 	now := time.Now()
 	notBefore := now.Add(time.Minute * -1)
@@ -213,7 +238,7 @@ func TestRootCertDoesNotValidateWithoutCorrectKeyExtension(t *testing.T) {
 	require.NoError(t, err)
 
 	pool := NewCertPool()
-	map[string]*Certificate(*pool)[rootCert.Subject] = rootCert
+	pool.add(rootCert)
 
 	clientCert, _, err := ClientCertificate("client1", 2, notBefore, notAfter, nil, rootKey, rootCert.Subject)
 	require.NoError(t, err)
@@ -276,3 +301,204 @@ func TestIntermediateNeedsToBeSignedByRoot(t *testing.T) {
 	assert.Empty(t, validatesClientCert)
 	assert.Error(t, err)
 }
+
+func TestCrossSignedIntermediate(t *testing.T) {
+	now := time.Now()
+	notBefore := now.Add(time.Minute * -1)
+	notAfter := now.Add(time.Hour)
+
+	intermediateExtensions := []Extension{
+		{
+			OID:      OIDKeyUsage,
+			Critical: true,
+			Value:    KeyUsageSignCert.ToBytes(),
+		},
+	}
+
+	oldRoot, oldRootKey, err := SelfSignedCertificate("root 2019", notBefore, notAfter, nil)
+	require.NoError(t, err)
+	newRoot, newRootKey, err := SelfSignedCertificate("root 2020", notBefore, notAfter, nil)
+	require.NoError(t, err)
+
+	oldIntermediate, imKey, err := SignedCertificate("intermediate", 2, notBefore, notAfter,
+		intermediateExtensions, oldRootKey, oldRoot.Subject)
+	require.NoError(t, err)
+	newIntermediate, err := CrossSignCertificate(oldIntermediate, 3, newRoot.Subject, newRootKey)
+	require.NoError(t, err)
+	assert.Equal(t, oldIntermediate.PubKey, newIntermediate.PubKey)
+	assert.Equal(t, oldIntermediate.Subject, newIntermediate.Subject)
+	assert.Equal(t, oldRoot.Subject, oldIntermediate.Issuer)
+
+	clientCert, _, err := ClientCertificate("client", 4, notBefore, notAfter, nil, imKey, "intermediate")
+	require.NoError(t, err)
+
+	bundle := []*Certificate{oldIntermediate, newIntermediate, clientCert}
+	for _, pool := range []*CertPool{NewCertPool(oldRoot), NewCertPool(newRoot), NewCertPool(oldRoot, newRoot)} {
+		c, err := pool.ValidateBundle(bundle)
+		assert.NoError(t, err)
+		assert.Equal(t, clientCert, c)
+	}
+
+	otherRoot, _, err := SelfSignedCertificate("other root", notBefore, notAfter, nil)
+	require.NoError(t, err)
+	_, err = NewCertPool(otherRoot).ValidateBundle(bundle)
+	assert.Error(t, err)
+}
+
+func TestCertPoolWithRotatedRoot(t *testing.T) {
+	oldRoot, oldKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	newRoot, newKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(oldRoot, newRoot)
+	require.Equal(t, 2, pool.Len())
+	assert.Len(t, pool.Certificates("root"), 2)
+	assert.Empty(t, pool.Certificates("other"))
+
+	oldClient, _, err := ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, oldKey, "root")
+	require.NoError(t, err)
+	newClient, _, err := ClientCertificate("client", 3, time.Time{}, time.Time{}, nil, newKey, "root")
+	require.NoError(t, err)
+	errs := pool.ValidateCertificates(oldClient, newClient)
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.True(t, pool.ValidateReport(oldClient).Valid())
+	assert.True(t, pool.ValidateReport(newClient).Valid())
+
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 4, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, newKey, "root")
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 5, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{leaf, intermediate})
+	assert.NoError(t, err)
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	forged, _, err := ClientCertificate("client", 6, time.Time{}, time.Time{}, nil, otherKey, "root")
+	require.NoError(t, err)
+	assert.Error(t, pool.Validate(forged))
+
+	assert.False(t, pool.Add(oldClient))
+	assert.True(t, pool.Add(oldRoot))
+	assert.Equal(t, 2, pool.Len())
+	assert.Len(t, pool.Certificates("root"), 2)
+
+	// Replacing a root with the same key updates the index by subject
+	renamed, err := SignCertificate(&Certificate{
+		SerialNumber: 7,
+		Issuer:       "renamed root",
+		Validity:     &Validity{},
+		Subject:      "renamed root",
+		PubKey:       oldRoot.PubKey,
+		Extensions:   oldRoot.Extensions,
+	}, oldKey)
+	require.NoError(t, err)
+	assert.True(t, pool.Add(renamed))
+	assert.Equal(t, 2, pool.Len())
+	assert.Equal(t, []*Certificate{newRoot}, pool.Certificates("root"))
+	assert.Equal(t, []*Certificate{renamed}, pool.Certificates("renamed root"))
+	assert.Len(t, pool.All(), 2)
+}
+
+func TestValidateContext(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+	pool := NewCertPool(rootCert)
+
+	assert.NoError(t, pool.ValidateContext(context.Background(), leaf))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.True(t, errors.Is(pool.ValidateContext(ctx, leaf), context.Canceled))
+	_, err = pool.ValidateBundleContext(ctx, []*Certificate{leaf})
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestValidateBundleLimitsChainSearch(t *testing.T) {
+	root, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	pool := NewCertPool(root)
+	intermediate := func(subject string, pubKey ed25519.PublicKey, issuer string, issuerKey ed25519.PrivateKey) *Certificate {
+		cert, err := SignCertificate(&Certificate{
+			SerialNumber: 2,
+			Issuer:       issuer,
+			Validity:     &Validity{},
+			Subject:      subject,
+			PubKey:       pubKey,
+			Extensions:   []Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}},
+		}, issuerKey)
+		require.NoError(t, err)
+		return cert
+	}
+
+	// Every of the three keys of a level is certified by every key of the level above, so there
+	// are 3^7 paths from the leaf, none of them leading to the root
+	const width, levels = 3, 7
+	keys := make([][]ed25519.PrivateKey, levels+1)
+	for level := range keys {
+		for i := 0; i < width; i++ {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			require.NoError(t, err)
+			keys[level] = append(keys[level], key)
+		}
+	}
+	var bundle []*Certificate
+	for level := 0; level < levels; level++ {
+		for _, key := range keys[level] {
+			for _, issuerKey := range keys[level+1] {
+				bundle = append(bundle, intermediate(fmt.Sprintf("level %d", level), key.Public().(ed25519.PublicKey),
+					fmt.Sprintf("level %d", level+1), issuerKey))
+			}
+		}
+	}
+	leaf, _, err := ClientCertificate("leaf", 1, time.Time{}, time.Time{}, nil, keys[0][0], "level 0")
+	require.NoError(t, err)
+	bundle = append(bundle, leaf)
+	v := &chainVerifier{}
+	_, err = pool.validateBundle(bundle, v)
+	assert.True(t, errors.Is(err, ErrUntrustedRoot))
+	assert.Equal(t, maxChainPaths, v.paths)
+
+	// Intermediates whose signature doesn't match aren't followed
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	good := intermediate("intermediate", otherKey.Public().(ed25519.PublicKey), "root", rootKey)
+	forged := intermediate("intermediate", otherKey.Public().(ed25519.PublicKey), "root", otherKey)
+	leaf, _, err = ClientCertificate("leaf", 1, time.Time{}, time.Time{}, nil, otherKey, "intermediate")
+	require.NoError(t, err)
+	v = &chainVerifier{}
+	_, err = pool.validateBundle([]*Certificate{leaf, forged, good}, v)
+	assert.NoError(t, err)
+	// The signature of the leaf has been checked while selecting its issuer, only the ones of the
+	// intermediate and the root are left for the batch
+	assert.Len(t, v.checks, 2)
+
+	// The same applies to roots sharing a subject
+	rotated, _, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	v = &chainVerifier{}
+	require.NoError(t, NewCertPool(root, rotated).validate(good, v))
+	assert.Len(t, v.checks, 1)
+	assert.NoError(t, v.finish())
+
+	// Chains are limited to maxChainDepth intermediates
+	chain := func(length int) []*Certificate {
+		issuer, issuerKey := "root", rootKey
+		var certs []*Certificate
+		for i := 0; i < length; i++ {
+			_, key, err := ed25519.GenerateKey(rand.Reader)
+			require.NoError(t, err)
+			subject := fmt.Sprintf("intermediate %d", i)
+			certs = append(certs, intermediate(subject, key.Public().(ed25519.PublicKey), issuer, issuerKey))
+			issuer, issuerKey = subject, key
+		}
+		leaf, _, err := ClientCertificate("leaf", 1, time.Time{}, time.Time{}, nil, issuerKey, issuer)
+		require.NoError(t, err)
+		return append(certs, leaf)
+	}
+	_, err = pool.ValidateBundle(chain(maxChainDepth))
+	assert.NoError(t, err)
+	_, err = pool.ValidateBundle(chain(maxChainDepth + 1))
+	assert.True(t, errors.Is(err, ErrUntrustedRoot))
+}
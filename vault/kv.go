@@ -0,0 +1,174 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/smolcert/smolcert"
+	"golang.org/x/crypto/ed25519"
+)
+
+// kvEntry is the secret stored for a smolcert.KeyStoreEntry
+type kvEntry struct {
+	// Certificates are the base64 encoded certificates
+	Certificates []string `json:"certificates,omitempty"`
+	// Key is the PEM encoded private key
+	Key string `json:"key,omitempty"`
+}
+
+// KVStore is a smolcert.KeyStore keeping every entry as a secret of the KV version 2 secrets
+// engine. Deleting an entry deletes all versions of its secret.
+type KVStore struct {
+	client *Client
+	mount  string
+	prefix string
+	ctx    context.Context
+}
+
+// NewKVStore creates a KVStore storing entries below prefix in the KV engine mounted at mount. ctx
+// is used for all requests.
+func NewKVStore(ctx context.Context, client *Client, mount, prefix string) *KVStore {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &KVStore{client: client, mount: mount, prefix: prefix, ctx: ctx}
+}
+
+func (s *KVStore) path(kind, name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	return escapePath(s.mount) + "/" + kind + "/" + escapePath(s.prefix+name), nil
+}
+
+// checkName prevents names from addressing secrets outside of the prefix of a KVStore
+func checkName(name string) error {
+	if name == "" || len(name) > 128 {
+		return fmt.Errorf("Invalid key store name '%s', names need to have 1 to 128 characters", name)
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("Invalid key store name '%s', only letters, digits, '.', '-' and '_' are allowed", name)
+		}
+	}
+	return nil
+}
+
+// Get implements smolcert.KeyStore
+func (s *KVStore) Get(name string) (*smolcert.KeyStoreEntry, error) {
+	path, err := s.path("data", name)
+	if err != nil {
+		return nil, err
+	}
+	var secret struct {
+		Data *kvEntry `json:"data"`
+	}
+	err = s.client.request(s.ctx, http.MethodGet, path, nil, nil, &secret)
+	if err == errNotFound || (err == nil && secret.Data == nil) {
+		// Vault returns 404 for deleted secrets, but some versions include the metadata
+		return nil, smolcert.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &smolcert.KeyStoreEntry{}
+	for _, encoded := range secret.Data.Certificates {
+		buf, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid certificate in entry '%s': %w", name, err)
+		}
+		cert, err := smolcert.ParseBuf(buf)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid certificate in entry '%s': %w", name, err)
+		}
+		entry.Certificates = append(entry.Certificates, cert)
+	}
+	if secret.Data.Key != "" {
+		if entry.Key, err = smolcert.ParsePrivateKey([]byte(secret.Data.Key)); err != nil {
+			return nil, fmt.Errorf("Invalid key in entry '%s': %w", name, err)
+		}
+	}
+	if len(entry.Certificates) == 0 && entry.Key == nil {
+		return nil, smolcert.ErrNotFound
+	}
+	return entry, nil
+}
+
+// Put implements smolcert.KeyStore
+func (s *KVStore) Put(name string, entry *smolcert.KeyStoreEntry) error {
+	path, err := s.path("data", name)
+	if err != nil {
+		return err
+	}
+	if entry == nil || (len(entry.Certificates) == 0 && entry.Key == nil) {
+		return errors.New("Key store entry needs a key or a certificate")
+	}
+	secret := &kvEntry{}
+	for _, cert := range entry.Certificates {
+		buf, err := cert.Bytes()
+		if err != nil {
+			return err
+		}
+		secret.Certificates = append(secret.Certificates, base64.StdEncoding.EncodeToString(buf))
+	}
+	if entry.Key != nil {
+		if len(entry.Certificates) > 0 && !bytes.Equal(entry.Key.Public().(ed25519.PublicKey), entry.Certificates[0].PubKey) {
+			return errors.New("Private key doesn't belong to the certificate")
+		}
+		key, err := smolcert.MarshalPrivateKey(entry.Key)
+		if err != nil {
+			return err
+		}
+		secret.Key = string(key)
+	}
+	return s.client.request(s.ctx, http.MethodPost, path, nil, map[string]interface{}{"data": secret}, nil)
+}
+
+// Delete implements smolcert.KeyStore
+func (s *KVStore) Delete(name string) error {
+	path, err := s.path("metadata", name)
+	if err != nil {
+		return err
+	}
+	// Deleting the metadata succeeds for missing secrets too
+	err = s.client.request(s.ctx, http.MethodGet, path, nil, nil, nil)
+	if err == errNotFound {
+		return smolcert.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return s.client.request(s.ctx, http.MethodDelete, path, nil, nil, nil)
+}
+
+// List implements smolcert.KeyStore
+func (s *KVStore) List() ([]string, error) {
+	var list struct {
+		Keys []string `json:"keys"`
+	}
+	err := s.client.request(s.ctx, http.MethodGet, escapePath(s.mount)+"/metadata/"+escapePath(s.prefix),
+		url.Values{"list": []string{"true"}}, nil, &list)
+	if err == errNotFound {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, key := range list.Keys {
+		// Keys ending with a slash are folders
+		if checkName(key) == nil {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
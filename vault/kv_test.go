@@ -0,0 +1,53 @@
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVStore(t *testing.T) {
+	_, ts, client := newFakeVault()
+	defer ts.Close()
+	s := NewKVStore(context.Background(), client, "secret", "/smolcert/")
+
+	root, rootKey, err := smolcert.SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	client1, clientKey, err := smolcert.ClientCertificate("client", 2, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	names, err := s.List()
+	require.NoError(t, err)
+	assert.Empty(t, names)
+	_, err = s.Get("client")
+	assert.Equal(t, smolcert.ErrNotFound, err)
+
+	require.NoError(t, s.Put("client", &smolcert.KeyStoreEntry{Certificates: []*smolcert.Certificate{client1, root}, Key: clientKey}))
+	require.NoError(t, s.Put("root", &smolcert.KeyStoreEntry{Certificates: []*smolcert.Certificate{root}}))
+	names, err = s.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"client", "root"}, names)
+
+	entry, err := s.Get("client")
+	require.NoError(t, err)
+	require.Len(t, entry.Certificates, 2)
+	assert.Equal(t, client1.Signature, entry.Certificates[0].Signature)
+	assert.Equal(t, clientKey, entry.Key)
+	entry, err = s.Get("root")
+	require.NoError(t, err)
+	assert.Len(t, entry.Certificates, 1)
+	assert.Nil(t, entry.Key)
+
+	require.NoError(t, s.Delete("client"))
+	assert.Equal(t, smolcert.ErrNotFound, s.Delete("client"))
+	_, err = s.Get("client")
+	assert.Equal(t, smolcert.ErrNotFound, err)
+
+	assert.Error(t, s.Put("../client", &smolcert.KeyStoreEntry{Key: clientKey}))
+	assert.Error(t, s.Put("client", &smolcert.KeyStoreEntry{}))
+	assert.Error(t, s.Put("client", &smolcert.KeyStoreEntry{Certificates: []*smolcert.Certificate{client1}, Key: rootKey}))
+}
@@ -0,0 +1,112 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// TransitSigner is a smolcert.Signer signing with an ed25519 key of the transit secrets engine. It
+// is bound to the version of the key it has been created with, so rotating the key in Vault
+// doesn't change the key of certificates signed by an existing TransitSigner.
+type TransitSigner struct {
+	client  *Client
+	mount   string
+	name    string
+	version int
+	public  ed25519.PublicKey
+	// ctx is used for signing requests, as smolcert.Signer doesn't pass a context
+	ctx context.Context
+}
+
+type transitKey struct {
+	Type          string `json:"type"`
+	LatestVersion int    `json:"latest_version"`
+	Keys          map[string]struct {
+		PublicKey string `json:"public_key"`
+	} `json:"keys"`
+}
+
+// NewTransitSigner creates a TransitSigner for the latest version of the key name of the transit
+// engine mounted at mount. ctx is used to read the key and for all signing requests.
+func NewTransitSigner(ctx context.Context, client *Client, mount, name string) (*TransitSigner, error) {
+	return NewTransitSignerVersion(ctx, client, mount, name, 0)
+}
+
+// NewTransitSignerVersion creates a TransitSigner for the given version of the key name, the latest
+// version is used if version is 0
+func NewTransitSignerVersion(ctx context.Context, client *Client, mount, name string, version int) (*TransitSigner, error) {
+	key := &transitKey{}
+	err := client.request(ctx, http.MethodGet, escapePath(mount)+"/keys/"+escapePath(name), nil, nil, key)
+	if err == errNotFound {
+		return nil, fmt.Errorf("Transit key '%s' doesn't exist", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.Type != "ed25519" {
+		return nil, fmt.Errorf("Transit key '%s' is a %s key, not an ed25519 key", name, key.Type)
+	}
+	if version == 0 {
+		version = key.LatestVersion
+	}
+	keyVersion, found := key.Keys[strconv.Itoa(version)]
+	if !found {
+		return nil, fmt.Errorf("Transit key '%s' has no version %d", name, version)
+	}
+	public, err := base64.StdEncoding.DecodeString(keyVersion.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Transit key '%s' has an invalid public key", name)
+	}
+	return &TransitSigner{
+		client:  client,
+		mount:   mount,
+		name:    name,
+		version: version,
+		public:  ed25519.PublicKey(public),
+		ctx:     ctx,
+	}, nil
+}
+
+// Public implements smolcert.Signer
+func (s *TransitSigner) Public() ed25519.PublicKey {
+	return s.public
+}
+
+// Version returns the version of the transit key the TransitSigner signs with
+func (s *TransitSigner) Version() int {
+	return s.version
+}
+
+// Sign implements smolcert.Signer
+func (s *TransitSigner) Sign(message []byte) ([]byte, error) {
+	req := map[string]interface{}{
+		"input":       base64.StdEncoding.EncodeToString(message),
+		"key_version": s.version,
+	}
+	var resp struct {
+		Signature string `json:"signature"`
+	}
+	if err := s.client.request(s.ctx, http.MethodPost, escapePath(s.mount)+"/sign/"+escapePath(s.name), nil, req, &resp); err != nil {
+		if err == errNotFound {
+			return nil, fmt.Errorf("Transit key '%s' doesn't exist", s.name)
+		}
+		return nil, err
+	}
+	// Signatures are returned as vault:v<version>:<base64 signature>
+	prefix := "vault:v" + strconv.Itoa(s.version) + ":"
+	if !strings.HasPrefix(resp.Signature, prefix) {
+		return nil, errors.New("Vault returned a signature of an unexpected key version")
+	}
+	sig, err := base64.StdEncoding.DecodeString(resp.Signature[len(prefix):])
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("Vault returned an invalid signature")
+	}
+	return sig, nil
+}
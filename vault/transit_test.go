@@ -0,0 +1,53 @@
+package vault
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smolcert/smolcert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestTransitSigner(t *testing.T) {
+	v, ts, client := newFakeVault()
+	defer ts.Close()
+	v.rotate(t, "root")
+
+	_, err := NewTransitSigner(context.Background(), client, "transit", "missing")
+	assert.Error(t, err)
+	signer, err := NewTransitSigner(context.Background(), client, "transit", "root")
+	require.NoError(t, err)
+	assert.Equal(t, 1, signer.Version())
+	sig, err := signer.Sign([]byte("message"))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(signer.Public(), []byte("message"), sig))
+
+	// The CA key stays in Vault
+	rootCert, err := smolcert.NewCertificateBuilder().Subject("root").SelfSigned().PublicKey(signer.Public()).
+		KeyUsage(smolcert.KeyUsageSignCert).Build()
+	require.NoError(t, err)
+	rootCert, err = smolcert.SignCertificateWithSigner(rootCert, signer)
+	require.NoError(t, err)
+	ca, err := smolcert.NewCAWithSigner(rootCert, signer, smolcert.NewMemoryStore())
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cert, err := ca.Issue("device", pubKey, smolcert.WithValidFor(time.Hour))
+	require.NoError(t, err)
+	assert.NoError(t, smolcert.NewCertPool(rootCert).Validate(cert))
+
+	// Rotating the key in Vault doesn't affect existing signers
+	v.rotate(t, "root")
+	sig, err = signer.Sign([]byte("message"))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(signer.Public(), []byte("message"), sig))
+	rotated, err := NewTransitSigner(context.Background(), client, "transit", "root")
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotated.Version())
+	assert.NotEqual(t, signer.Public(), rotated.Public())
+	_, err = NewTransitSignerVersion(context.Background(), client, "transit", "root", 3)
+	assert.Error(t, err)
+}
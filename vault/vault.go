@@ -0,0 +1,124 @@
+/*
+Package vault keeps the keys and certificates of smolcert CAs in HashiCorp Vault.
+
+TransitSigner signs with an ed25519 key of the transit secrets engine, so the CA key never leaves
+Vault. It implements smolcert.Signer and can be used by a CA:
+
+	client := &vault.Client{Address: "https://vault.example.com:8200", Token: token}
+	signer, err := vault.NewTransitSigner(ctx, client, "transit", "smolcert-root")
+	ca, err := smolcert.NewCAWithSigner(rootCert, signer, store)
+
+KVStore implements smolcert.KeyStore on top of the KV version 2 secrets engine, i.e. to share the
+certificates of a CA between several instances.
+*/
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the timeout of requests to Vault, if Client.HTTPClient is not set
+const DefaultTimeout = 30 * time.Second
+
+// maxResponseSize limits the size of responses read from Vault
+const maxResponseSize = 1024 * 1024
+
+// errNotFound is returned by Client.request if Vault responds with 404
+var errNotFound = errors.New("not found")
+
+// Client sends requests to the HTTP API of Vault
+type Client struct {
+	// Address is the URL of the Vault server, i.e. https://vault.example.com:8200
+	Address string
+	// Token authenticates the requests
+	Token string
+	// Namespace is optionally sent with every request, it is only supported by Vault Enterprise
+	Namespace string
+	// HTTPClient is used to send requests, by default a client with DefaultTimeout is used
+	HTTPClient *http.Client
+}
+
+var defaultHTTPClient = &http.Client{Timeout: DefaultTimeout}
+
+// request sends a request with the JSON encoded body to path below /v1/ and decodes the data of the
+// response into result, if result is not nil
+func (c *Client) request(ctx context.Context, method, path string, query url.Values, body, result interface{}) error {
+	u := strings.TrimSuffix(c.Address, "/") + "/v1/" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("X-Vault-Token", c.Token)
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(respBody, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("Vault request to %s failed with %s: %s", path, resp.Status,
+				strings.Join(vaultErr.Errors, ", "))
+		}
+		return fmt.Errorf("Vault request to %s failed with %s", path, resp.Status)
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: result}
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
+		return fmt.Errorf("Invalid response of Vault to %s: %w", path, err)
+	}
+	return nil
+}
+
+// escapePath escapes the segments of a slash separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
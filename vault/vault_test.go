@@ -0,0 +1,154 @@
+package vault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+const testToken = "s.test-token"
+
+// fakeVault implements the parts of the transit and KV version 2 engines used by this package
+type fakeVault struct {
+	lock    sync.Mutex
+	keys    map[string][]ed25519.PrivateKey
+	secrets map[string]json.RawMessage
+}
+
+// newFakeVault starts a fakeVault, the returned server needs to be closed
+func newFakeVault() (*fakeVault, *httptest.Server, *Client) {
+	v := &fakeVault{keys: map[string][]ed25519.PrivateKey{}, secrets: map[string]json.RawMessage{}}
+	ts := httptest.NewServer(v)
+	return v, ts, &Client{Address: ts.URL + "/", Token: testToken}
+}
+
+func (v *fakeVault) rotate(t *testing.T, name string) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	v.lock.Lock()
+	v.keys[name] = append(v.keys[name], key)
+	v.lock.Unlock()
+}
+
+func respond(w http.ResponseWriter, data interface{}) {
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if r.Header.Get("X-Vault-Token") != testToken {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case strings.HasPrefix(path, "transit/keys/"):
+		versions, found := v.keys[strings.TrimPrefix(path, "transit/keys/")]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		keys := map[string]interface{}{}
+		for i, key := range versions {
+			keys[strconv.Itoa(i+1)] = map[string]string{
+				"public_key": base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+			}
+		}
+		respond(w, map[string]interface{}{"type": "ed25519", "latest_version": len(versions), "keys": keys})
+	case strings.HasPrefix(path, "transit/sign/"):
+		versions, found := v.keys[strings.TrimPrefix(path, "transit/sign/")]
+		var req struct {
+			Input      string `json:"input"`
+			KeyVersion int    `json:"key_version"`
+		}
+		if !found || json.NewDecoder(r.Body).Decode(&req) != nil || req.KeyVersion < 1 || req.KeyVersion > len(versions) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		input, _ := base64.StdEncoding.DecodeString(req.Input)
+		sig := ed25519.Sign(versions[req.KeyVersion-1], input)
+		respond(w, map[string]interface{}{
+			"signature": "vault:v" + strconv.Itoa(req.KeyVersion) + ":" + base64.StdEncoding.EncodeToString(sig),
+		})
+	case strings.HasPrefix(path, "secret/data/"):
+		name := strings.TrimPrefix(path, "secret/data/")
+		switch r.Method {
+		case http.MethodPost:
+			var req struct {
+				Data json.RawMessage `json:"data"`
+			}
+			if json.NewDecoder(r.Body).Decode(&req) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			v.secrets[name] = req.Data
+			respond(w, map[string]interface{}{"version": 1})
+		case http.MethodGet:
+			secret, found := v.secrets[name]
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			respond(w, map[string]interface{}{"data": secret})
+		}
+	case strings.HasPrefix(path, "secret/metadata/"):
+		name := strings.TrimPrefix(path, "secret/metadata/")
+		if r.URL.Query().Get("list") == "true" {
+			keys := []string{}
+			for secret := range v.secrets {
+				if strings.HasPrefix(secret, name) {
+					keys = append(keys, strings.SplitAfter(strings.TrimPrefix(secret, name), "/")[0])
+				}
+			}
+			if len(keys) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			sort.Strings(keys)
+			respond(w, map[string]interface{}{"keys": keys})
+			return
+		}
+		if _, found := v.secrets[name]; !found && r.Method == http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(v.secrets, name)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		respond(w, map[string]interface{}{"current_version": 1})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, ts, client := newFakeVault()
+	defer ts.Close()
+	client.Token = "invalid"
+	err := client.request(context.Background(), http.MethodGet, "transit/keys/root", nil, nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+
+	client.Address = "http://127.0.0.1:0"
+	assert.Error(t, client.request(context.Background(), http.MethodGet, "transit/keys/root", nil, nil, nil))
+}
+
+func TestEscapePath(t *testing.T) {
+	assert.Equal(t, "secret/smolcert/a%3Fb", escapePath("secret/smolcert/a?b"))
+	assert.Equal(t, "smolcert/", escapePath("smolcert/"))
+}
@@ -0,0 +1,78 @@
+package smolcert
+
+// VerifyOption enables additional checks during the validation with a Validator, i.e. for
+// deployments with stricter policies than the defaults of smolcert
+type VerifyOption func(o *verifyOptions)
+
+type verifyOptions struct {
+	requireBoundedValidity bool
+	acceptUnknownFields    bool
+	// nameMatching is used to match issuers with the subjects of the roots and intermediates
+	nameMatching NameMatching
+	// concurrency is the maximum number of goroutines verifying signatures
+	concurrency int
+	// audience is required for leaf certificates with the scopes, if set
+	audience string
+	scopes   []string
+}
+
+func newVerifyOptions(opts []VerifyOption) verifyOptions {
+	o := verifyOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// RequireBoundedValidity rejects certificates without a NotBefore or NotAfter time with
+// ErrUnboundedValidity. By default a zero time means the validity is unrestricted in that
+// direction. The check applies to every certificate of a chain including the root.
+func RequireBoundedValidity() VerifyOption {
+	return func(o *verifyOptions) {
+		o.requireBoundedValidity = true
+	}
+}
+
+// AcceptUnknownFields accepts certificates of versions newer than LatestVersion or with fields
+// unknown to this package. By default they are rejected with ErrUnsupportedVersion. The unknown
+// fields are covered by the signature, but their meaning is ignored.
+func AcceptUnknownFields() VerifyOption {
+	return func(o *verifyOptions) {
+		o.acceptUnknownFields = true
+	}
+}
+
+// MatchNames matches the issuers of certificates with the subjects of the roots of the pool and of
+// the intermediates of bundles under m. By default names are compared byte by byte. The issuers
+// of CRLs, epoch statements and delegated credentials are always compared exactly.
+func MatchNames(m NameMatching) VerifyOption {
+	return func(o *verifyOptions) {
+		o.nameMatching = m
+	}
+}
+
+// Concurrency verifies signatures with up to n goroutines, which speeds up the validation of long
+// bundles and of certificates issued by one of many roots with the same subject. Values below 1
+// use GOMAXPROCS goroutines. By default signatures are verified sequentially.
+func Concurrency(n int) VerifyOption {
+	return func(o *verifyOptions) {
+		o.concurrency = defaultConcurrency(n)
+	}
+}
+
+func (o verifyOptions) check(cert *Certificate) error {
+	if o.requireBoundedValidity {
+		if cert.Validity == nil || cert.Validity.NotBefore.IsZero() || cert.Validity.NotAfter.IsZero() {
+			return newValidationError(ErrUnboundedValidity, cert, "Certificate %s has no bounded validity", cert.Subject)
+		}
+	}
+	return nil
+}
+
+// checkLeaf applies the checks which only concern the leaf of a validated chain
+func (o verifyOptions) checkLeaf(cert *Certificate) error {
+	if o.audience != "" {
+		return CheckAudience(cert, o.audience, o.scopes...)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package smolcert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestRequireBoundedValidity(t *testing.T) {
+	now := time.Now()
+	rootCert, rootKey, err := SelfSignedCertificate("root", now.Add(-time.Hour), now.Add(time.Hour), nil)
+	require.NoError(t, err)
+	bounded, _, err := ClientCertificate("bounded", 2, now.Add(-time.Hour), now.Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+	unbounded, _, err := ClientCertificate("unbounded", 3, now.Add(-time.Hour), time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	pool := NewCertPool(rootCert)
+	assert.NoError(t, pool.Validate(unbounded))
+
+	v := NewValidator(pool)
+	v.Options = []VerifyOption{RequireBoundedValidity()}
+	assert.NoError(t, v.Validate(bounded))
+	err = v.Validate(unbounded)
+	assert.True(t, errors.Is(err, ErrUnboundedValidity))
+	assert.Equal(t, "unbounded_validity", FailureReason(err))
+	_, err = NewBundle(unbounded).ValidateWith(v)
+	assert.True(t, errors.Is(err, ErrUnboundedValidity))
+
+	// The root certificate needs a bounded validity as well
+	unboundedRoot, unboundedRootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("bounded", 4, now.Add(-time.Hour), now.Add(time.Hour), nil, unboundedRootKey, "root")
+	require.NoError(t, err)
+	v = NewValidator(NewCertPool(unboundedRoot))
+	v.Options = []VerifyOption{RequireBoundedValidity()}
+	_, err = v.ValidateBundle([]*Certificate{leaf})
+	assert.True(t, errors.Is(err, ErrUnboundedValidity))
+}
+
+func TestConcurrency(t *testing.T) {
+	now := time.Now()
+	var roots []*Certificate
+	var signingKey ed25519.PrivateKey
+	for i := 0; i < 20; i++ {
+		root, rootKey, err := SelfSignedCertificate("root", now.Add(-time.Hour), now.Add(time.Hour), nil)
+		require.NoError(t, err)
+		roots = append(roots, root)
+		if i == 13 {
+			signingKey = rootKey
+		}
+	}
+	pool := NewCertPool(roots...)
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, now.Add(-time.Hour), now.Add(time.Hour),
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, signingKey, "root")
+	require.NoError(t, err)
+	leaf, _, err := ClientCertificate("device", 3, now.Add(-time.Hour), now.Add(time.Hour), nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	other, _, err := ClientCertificate("other", 4, now.Add(-time.Hour), now.Add(time.Hour), nil, intermediateKey, "root")
+	require.NoError(t, err)
+
+	for _, n := range []int{0, 1, 8} {
+		v := NewValidator(pool)
+		v.Options = []VerifyOption{Concurrency(n)}
+		assert.NoError(t, v.Validate(intermediate))
+		assert.True(t, errors.Is(v.Validate(other), ErrBadSignature))
+		clientCert, err := v.ValidateBundle([]*Certificate{leaf, intermediate})
+		require.NoError(t, err)
+		assert.Equal(t, leaf, clientCert)
+	}
+}
@@ -0,0 +1,315 @@
+package smolcert
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"filippo.io/edwards25519"
+	"github.com/fxamacker/cbor/v2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// cborNullItem replaces the signature in the to-be-signed encoding
+var cborNullItem = []byte{cborNull}
+
+// rawCertificate holds the fields of an encoded certificate needed by VerifyRaw. Byte fields
+// point into the encoding.
+type rawCertificate struct {
+	version       uint64
+	subject       []byte
+	validity      Validity
+	hasValidity   bool
+	extensions    []byte
+	algorithm     Algorithm
+	signature     []byte
+	unknownFields int
+	// sigStart and sigEnd are the offsets of the signature in the encoding
+	sigStart, sigEnd int
+}
+
+// VerifyRaw verifies the signature and validity of the encoded certificate certBytes, which has
+// been issued by the owner of issuerPub. In contrast to ParseBuf and Validate it doesn't decode the
+// certificate into a Certificate, nor encode it again to verify the signature, so it is suited for
+// gateways verifying large numbers of certificates. Besides the signature the same properties as
+// by Validate are checked, where possible without the issuer: the version, the algorithm, the
+// validity and the extensions. The issuer isn't matched against the name in the certificate, its
+// extensions aren't evaluated and hooks and metrics aren't invoked.
+//
+// The signature is verified over the encoding as it is, so the certificate needs to be encoded
+// canonically like by Bytes. Only Ed25519 and Ed25519ph signatures are supported. Valid
+// certificates are verified without allocations, as long as no opts are given. Of the
+// VerifyOptions only AcceptUnknownFields is applied.
+func VerifyRaw(certBytes []byte, issuerPub ed25519.PublicKey, opts ...VerifyOption) error {
+	var raw rawCertificate
+	if err := raw.decode(certBytes); err != nil {
+		return fmt.Errorf("%w: %s", ErrMalformedCertificate, err)
+	}
+	var o verifyOptions
+	if len(opts) > 0 {
+		o = newVerifyOptions(opts)
+	}
+	if err := raw.check(o); err != nil {
+		// The subject is only copied when needed
+		if validationErr, ok := err.(*ValidationError); ok {
+			validationErr.Subject = string(raw.subject)
+		}
+		return err
+	}
+
+	var context []byte
+	if raw.version >= Version4 {
+		context = signatureContext
+	}
+	if !verifyRawSignature(issuerPub, raw.signature, raw.algorithm == AlgorithmEd25519ph,
+		context, certBytes[:raw.sigStart], cborNullItem, certBytes[raw.sigEnd:]) {
+		return &ValidationError{Reason: ErrBadSignature, Subject: string(raw.subject), Message: "Signature validation failed"}
+	}
+	return nil
+}
+
+// decode reads the fields of the certificate encoded in data without copying them
+func (r *rawCertificate) decode(data []byte) error {
+	d := &cborDecoder{data: data}
+	major, n, indefinite, err := d.head()
+	if err != nil {
+		return err
+	}
+	if major != majorArray || indefinite {
+		return errors.New("certificate is no array of definite length")
+	}
+	legacy := false
+	switch {
+	case n == 7:
+		r.version, legacy = Version1, true
+	case n == 8:
+		r.version = Version1
+	case n >= 9:
+		if r.version, err = d.uint(); err != nil {
+			return err
+		}
+		if r.version < Version2 {
+			return fmt.Errorf("version %d certificates have no explicit version", r.version)
+		}
+		r.unknownFields = int(n - 9)
+	default:
+		return fmt.Errorf("certificate has %d fields, expected 7, 8 or at least 9", n)
+	}
+
+	if _, err := d.uint(); err != nil {
+		return err
+	}
+	if _, err := d.rawString(majorText); err != nil {
+		return err
+	}
+	if err := r.decodeValidity(d); err != nil {
+		return err
+	}
+	if r.subject, err = d.rawString(majorText); err != nil {
+		return err
+	}
+	if _, err := d.rawString(majorBytes); err != nil {
+		return err
+	}
+	start := d.pos
+	if err := d.rawExtensions(func(uint64, bool) error { return nil }); err != nil {
+		return err
+	}
+	r.extensions = data[start:d.pos]
+	// The original format of seven fields has no algorithm and is signed with Ed25519
+	r.algorithm = AlgorithmEd25519
+	if !legacy {
+		alg, err := d.int()
+		if err != nil {
+			return err
+		}
+		r.algorithm = Algorithm(alg)
+	}
+	r.sigStart = d.pos
+	if r.signature, err = d.rawString(majorBytes); err != nil {
+		return err
+	}
+	r.sigEnd = d.pos
+	for i := 0; i < r.unknownFields; i++ {
+		if err := d.skip(0); err != nil {
+			return err
+		}
+	}
+	if d.pos != len(data) {
+		return errors.New("cbor: unexpected data after item")
+	}
+	return nil
+}
+
+func (r *rawCertificate) decodeValidity(d *cborDecoder) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	major, n, indefinite, err := d.head()
+	if err != nil {
+		return err
+	}
+	if major != majorArray || indefinite || n != 2 {
+		return errors.New("validity is no array of two times")
+	}
+	notBefore, err := d.int()
+	if err != nil {
+		return err
+	}
+	notAfter, err := d.int()
+	if err != nil {
+		return err
+	}
+	if r.version >= Version3 {
+		r.validity.NotBefore, r.validity.NotBeforeMillis = splitMillis(notBefore)
+		r.validity.NotAfter, r.validity.NotAfterMillis = splitMillis(notAfter)
+	} else {
+		r.validity.NotBefore, r.validity.NotAfter = Time(notBefore), Time(notAfter)
+	}
+	r.hasValidity = true
+	return nil
+}
+
+// check performs the checks of certificateProblems, stopping at the first problem
+func (r *rawCertificate) check(opts verifyOptions) error {
+	cert := Certificate{Version: r.version, SignatureAlgorithm: r.algorithm}
+	if r.unknownFields > 0 {
+		// Only the number of unknown fields matters
+		cert.UnknownFields = make([]cbor.RawMessage, r.unknownFields)
+	}
+	if err := checkVersion(&cert, opts.acceptUnknownFields); err != nil {
+		return err
+	}
+	if err := checkAlgorithm(&cert); err != nil {
+		return err
+	}
+	if r.algorithm != AlgorithmEd25519 && r.algorithm != AlgorithmEd25519ph {
+		return newValidationError(ErrUnsupportedAlgorithm, &cert, "%s signatures can't be verified by VerifyRaw", r.algorithm)
+	}
+	if !r.hasValidity {
+		return newValidationError(ErrMalformedCertificate, &cert, "Certificate has no validity")
+	}
+	if err := checkValidity(&cert, r.validity); err != nil {
+		return err
+	}
+	return r.checkExtensions(&cert)
+}
+
+// checkExtensions checks for repeated and unknown critical extensions like
+// checkForDoubleExtensions and checkCriticalExtensions
+func (r *rawCertificate) checkExtensions(cert *Certificate) error {
+	var oidBuf [16]uint64
+	oids := oidBuf[:0]
+	var unknownCritical uint64
+	hasUnknownCritical := false
+	err := (&cborDecoder{data: r.extensions}).rawExtensions(func(oid uint64, critical bool) error {
+		for _, seen := range oids {
+			if seen == oid {
+				return newValidationError(ErrDuplicateExtension, cert,
+					"This certificate contains a repeated extension (OID: %X) which is invalid", oid)
+			}
+		}
+		oids = append(oids, oid)
+		if critical && !hasUnknownCritical && !isKnownCriticalExtension(oid) {
+			unknownCritical, hasUnknownCritical = oid, true
+		}
+		return nil
+	})
+	if err != nil || !hasUnknownCritical {
+		return err
+	}
+	return newValidationError(ErrUnknownCriticalExtension, cert,
+		"This certificate contains an unknown critical extension (OID: %X)", unknownCritical)
+}
+
+// rawExtensions reads the array of extensions and calls fn with the OID and critical flag of
+// each extension, skipping their values
+func (d *cborDecoder) rawExtensions(fn func(oid uint64, critical bool) error) error {
+	if null, err := d.null(); null || err != nil {
+		return err
+	}
+	major, n, indefinite, err := d.head()
+	if err != nil {
+		return err
+	}
+	if major != majorArray || indefinite {
+		return errors.New("extensions are no array of definite length")
+	}
+	for i := uint64(0); i < n; i++ {
+		major, fields, indefinite, err := d.head()
+		if err != nil {
+			return err
+		}
+		if major != majorArray || indefinite || fields != 3 {
+			return errors.New("extension is no array of three elements")
+		}
+		oid, err := d.uint()
+		if err != nil {
+			return err
+		}
+		critical, err := d.bool()
+		if err != nil {
+			return err
+		}
+		if _, err := d.rawString(majorBytes); err != nil {
+			return err
+		}
+		if err := fn(oid, critical); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawString returns a byte or text string of definite length without copying it, null results in
+// nil. Text strings are checked to be valid UTF-8.
+func (d *cborDecoder) rawString(major byte) ([]byte, error) {
+	if null, err := d.null(); null || err != nil {
+		return nil, err
+	}
+	m, n, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if m != major {
+		return nil, typeError(m, typeName(major))
+	}
+	if indefinite {
+		return nil, errors.New("indefinite length strings are not supported")
+	}
+	if uint64(len(d.data)-d.pos) < n {
+		return nil, errUnexpectedEnd
+	}
+	s := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	if major == majorText && !utf8.Valid(s) {
+		return nil, errors.New("cbor: invalid UTF-8 string")
+	}
+	return s, nil
+}
+
+// verifyRawSignature verifies the Ed25519 or Ed25519ph signature sig of the message made up of
+// parts, without joining them
+func verifyRawSignature(pubKey ed25519.PublicKey, sig []byte, prehashed bool, parts ...[]byte) bool {
+	if len(pubKey) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	h := sha512.New()
+	if !prehashed {
+		h.Write(sig[:32])
+		h.Write(pubKey)
+	}
+	for _, part := range parts {
+		h.Write(part)
+	}
+	var sum [sha512.Size]byte
+	if prehashed {
+		return verifyPrehashedDigest(pubKey, h.Sum(sum[:0]), sig)
+	}
+	k, err := edwards25519.NewScalar().SetUniformBytes(h.Sum(sum[:0]))
+	if err != nil {
+		return false
+	}
+	return checkEd25519Signature(pubKey, sig, k)
+}
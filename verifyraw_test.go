@@ -0,0 +1,122 @@
+package smolcert
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestVerifyRaw(t *testing.T) {
+	issuerPub, issuerKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	now := time.Now()
+
+	build := func(version uint64, modify func(b *CertificateBuilder)) *Certificate {
+		b := NewCertificateBuilder().Version(version).SerialNumber(42).Issuer("issuer").Subject("device").
+			PublicKey(pubKey).NotBefore(now.Add(-time.Hour)).NotAfter(now.Add(time.Hour)).
+			AddExtension(Extension{OID: OIDKeyUsage, Critical: true, Value: KeyUsageClientIdentification.ToBytes()})
+		if modify != nil {
+			modify(b)
+		}
+		cert, err := b.Build()
+		require.NoError(t, err)
+		return cert
+	}
+	encode := func(cert *Certificate) []byte {
+		buf, err := cert.Bytes()
+		require.NoError(t, err)
+		return buf
+	}
+	sign := func(cert *Certificate) []byte {
+		signed, err := SignCertificate(cert, issuerKey)
+		require.NoError(t, err)
+		return encode(signed)
+	}
+
+	for _, version := range []uint64{Version1, Version2, Version3, Version4} {
+		buf := sign(build(version, nil))
+		assert.NoError(t, VerifyRaw(buf, issuerPub), "version %d", version)
+		// The result is the same as for the complete validation
+		cert, err := ParseBuf(buf)
+		require.NoError(t, err)
+		assert.NoError(t, validateCertificate(cert, issuerPub))
+	}
+
+	prehashed, err := SignCertificatePrehashed(build(LatestVersion, nil), Ed25519phKey(issuerKey))
+	require.NoError(t, err)
+	assert.NoError(t, VerifyRaw(encode(prehashed), issuerPub))
+
+	buf := sign(build(LatestVersion, nil))
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	err = VerifyRaw(buf, otherPub)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "device", validationErr.Subject)
+
+	tampered := append([]byte{}, buf...)
+	tampered[len(tampered)-1] ^= 1
+	assert.True(t, errors.Is(VerifyRaw(tampered, issuerPub), ErrBadSignature))
+	assert.True(t, errors.Is(VerifyRaw(append(buf, 0), issuerPub), ErrMalformedCertificate))
+	assert.True(t, errors.Is(VerifyRaw(buf[:len(buf)-1], issuerPub), ErrMalformedCertificate))
+	assert.True(t, errors.Is(VerifyRaw([]byte{0xa0}, issuerPub), ErrMalformedCertificate))
+
+	expired := sign(build(LatestVersion, func(b *CertificateBuilder) {
+		b.NotBefore(now.Add(-2 * time.Hour)).NotAfter(now.Add(-time.Hour))
+	}))
+	err = VerifyRaw(expired, issuerPub)
+	assert.True(t, errors.Is(err, ErrExpired))
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "device", validationErr.Subject)
+	notYetValid := sign(build(LatestVersion, func(b *CertificateBuilder) {
+		b.NotBefore(now.Add(time.Hour)).NotAfter(now.Add(2 * time.Hour))
+	}))
+	assert.True(t, errors.Is(VerifyRaw(notYetValid, issuerPub), ErrNotYetValid))
+
+	unknownCritical := sign(build(LatestVersion, func(b *CertificateBuilder) {
+		b.AddExtension(Extension{OID: 0x8000, Critical: true})
+	}))
+	assert.True(t, errors.Is(VerifyRaw(unknownCritical, issuerPub), ErrUnknownCriticalExtension))
+	duplicate := build(LatestVersion, nil)
+	duplicate.Extensions = append(duplicate.Extensions, duplicate.Extensions[0])
+	assert.True(t, errors.Is(VerifyRaw(sign(duplicate), issuerPub), ErrDuplicateExtension))
+
+	unknownFields := build(LatestVersion, nil)
+	unknownFields.UnknownFields = []cbor.RawMessage{{0x01}}
+	unknownFieldsBuf := sign(unknownFields)
+	assert.True(t, errors.Is(VerifyRaw(unknownFieldsBuf, issuerPub), ErrUnsupportedVersion))
+	assert.NoError(t, VerifyRaw(unknownFieldsBuf, issuerPub, AcceptUnknownFields()))
+
+	hybrid := build(LatestVersion, nil)
+	hybrid.SignatureAlgorithm = AlgorithmHybrid
+	assert.True(t, errors.Is(VerifyRaw(encode(hybrid), issuerPub), ErrUnsupportedAlgorithm))
+}
+
+func TestVerifyRawAllocations(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Time{}, time.Time{}, nil)
+	require.NoError(t, err)
+	cert, _, err := ClientCertificate("device", 2, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), nil, rootKey, "root")
+	require.NoError(t, err)
+	buf, err := cert.Bytes()
+	require.NoError(t, err)
+
+	require.NoError(t, VerifyRaw(buf, rootCert.PubKey))
+	raw := testing.AllocsPerRun(100, func() {
+		_ = VerifyRaw(buf, rootCert.PubKey)
+	})
+	full := testing.AllocsPerRun(100, func() {
+		parsed, _ := ParseBuf(buf)
+		_ = validateCertificate(parsed, rootCert.PubKey)
+	})
+	t.Logf("allocations: VerifyRaw %.0f, ParseBuf and validation %.0f", raw, full)
+	assert.Less(t, raw, full)
+}
@@ -0,0 +1,173 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// x509Supported is true if trust anchors converted from X.509 certificates can be validated
+const x509Supported = true
+
+// FromX509 converts a self signed ed25519 X.509 CA certificate into a trust anchor, which can be
+// added to a CertPool. Certificates signed with the key of the X.509 certificate and with its
+// common name as issuer are then trusted like certificates issued by a smolcert root.
+// The anchor has the SignatureAlgorithm AlgorithmX509 and carries the DER encoded X.509
+// certificate as signature, so its integrity is checked via the self signature of the X.509
+// certificate. The serial number of the anchor consists of the lowest 64 bits of the X.509 serial.
+func FromX509(x509Cert *x509.Certificate) (*Certificate, error) {
+	pubKey, ok := x509Cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("Only X.509 certificates with ed25519 keys can be converted")
+	}
+	if x509Cert.Subject.CommonName == "" {
+		return nil, errors.New("X.509 certificate needs a common name to be used as issuer")
+	}
+	if !bytes.Equal(x509Cert.RawSubject, x509Cert.RawIssuer) {
+		return nil, errors.New("Only self signed X.509 certificates can be converted")
+	}
+	if !x509Cert.BasicConstraintsValid || !x509Cert.IsCA {
+		return nil, errors.New("X.509 certificate is no CA certificate")
+	}
+	if x509Cert.KeyUsage != 0 && x509Cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, errors.New("X.509 certificate is not allowed to sign certificates")
+	}
+	if hasX509NameConstraints(x509Cert) {
+		return nil, errors.New("Name constraints of X.509 certificates can't be converted")
+	}
+	if err := x509Cert.CheckSignatureFrom(x509Cert); err != nil {
+		return nil, fmt.Errorf("Invalid self signature of X.509 certificate: %w", err)
+	}
+
+	serial := x509Cert.SerialNumber.Bytes()
+	if len(serial) > 8 {
+		serial = serial[len(serial)-8:]
+	}
+	cert := &Certificate{
+		// The version is fixed, so that the conversion of a X.509 certificate never changes
+		Version:      Version4,
+		SerialNumber: new(big.Int).SetBytes(serial).Uint64(),
+		Issuer:       x509Cert.Subject.CommonName,
+		Validity:     NewMillisValidity(x509Cert.NotBefore, x509Cert.NotAfter),
+		Subject:      x509Cert.Subject.CommonName,
+		PubKey:       append(ed25519.PublicKey{}, pubKey...),
+		Extensions: []Extension{
+			{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()},
+		},
+		SignatureAlgorithm: AlgorithmX509,
+		Signature:          append([]byte{}, x509Cert.Raw...),
+	}
+	return cert, nil
+}
+
+func hasX509NameConstraints(c *x509.Certificate) bool {
+	return len(c.PermittedDNSDomains) > 0 || len(c.ExcludedDNSDomains) > 0 ||
+		len(c.PermittedIPRanges) > 0 || len(c.ExcludedIPRanges) > 0 ||
+		len(c.PermittedEmailAddresses) > 0 || len(c.ExcludedEmailAddresses) > 0 ||
+		len(c.PermittedURIDomains) > 0 || len(c.ExcludedURIDomains) > 0
+}
+
+// X509Certificate returns the X.509 certificate a trust anchor has been converted from via FromX509
+func (c *Certificate) X509Certificate() (*x509.Certificate, error) {
+	if c.SignatureAlgorithm != AlgorithmX509 {
+		return nil, errors.New("Certificate has not been converted from a X.509 certificate")
+	}
+	return x509.ParseCertificate(c.Signature)
+}
+
+// AddX509 converts all X.509 root certificates via FromX509 and adds them to the pool
+func (c *CertPool) AddX509(roots ...*x509.Certificate) error {
+	for _, root := range roots {
+		cert, err := FromX509(root)
+		if err != nil {
+			return fmt.Errorf("Failed to convert X.509 root '%s': %w", root.Subject, err)
+		}
+		c.Add(cert)
+	}
+	return nil
+}
+
+// X509CertPool returns a x509.CertPool with the X.509 certificates of all roots in the pool, which
+// have been converted via FromX509. Roots which are smolcerts in the first place can be exported
+// with X509Root and added back to the pool converted via FromX509, so both pools trust the same
+// keys.
+func (c *CertPool) X509CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range c.All() {
+		if x509Cert, err := cert.X509Certificate(); err == nil {
+			pool.AddCert(x509Cert)
+		}
+	}
+	return pool
+}
+
+// X509Root creates a self signed X.509 CA certificate for the smolcert root cert, which has the
+// same key, subject, serial number and validity. key is the private key of cert.
+func X509Root(cert *Certificate, key ed25519.PrivateKey) (*x509.Certificate, error) {
+	if !bytes.Equal(key.Public().(ed25519.PublicKey), cert.PubKey) {
+		return nil, errors.New("Private key doesn't belong to the root certificate")
+	}
+	if err := RequiresExtension(cert, OIDKeyUsage, ExpectKeyUsage(KeyUsageSignCert)); err != nil {
+		return nil, fmt.Errorf("Only certificates which can sign certificates can be exported: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).SetUint64(cert.SerialNumber),
+		Subject:               pkix.Name{CommonName: cert.Subject},
+		NotBefore:             time.Unix(0, 0),
+		NotAfter:              time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if cert.Validity != nil {
+		if !cert.Validity.NotBefore.IsZero() {
+			template.NotBefore = cert.Validity.NotBeforeTime()
+		}
+		if !cert.Validity.NotAfter.IsZero() {
+			template.NotAfter = cert.Validity.NotAfterTime()
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// verifyX509Anchor verifies a trust anchor converted via FromX509. The X.509 certificate embedded
+// as signature needs to be signed by issuer and cert needs to be its exact conversion.
+func verifyX509Anchor(cert, issuer *Certificate) error {
+	x509Cert, err := cert.X509Certificate()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Invalid X.509 certificate: %s", err)
+	}
+	converted, err := FromX509(x509Cert)
+	if err != nil {
+		return newValidationError(ErrBadSignature, cert, "Invalid X.509 certificate: %s", err)
+	}
+	if !bytes.Equal(issuer.PubKey, converted.PubKey) {
+		return newValidationError(ErrBadSignature, cert, "X.509 certificate hasn't been signed by the issuer")
+	}
+	convertedBytes, err := converted.TBSBytes()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Failed to serialize certificate for validation")
+	}
+	certBytes, err := cert.TBSBytes()
+	if err != nil {
+		return newValidationError(ErrMalformedCertificate, cert, "Failed to serialize certificate for validation")
+	}
+	if !bytes.Equal(certBytes, convertedBytes) {
+		return newValidationError(ErrBadSignature, cert, "Certificate doesn't match the embedded X.509 certificate")
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+//go:build !tinygo
+// +build !tinygo
+
+package smolcert
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+func createX509Root(t *testing.T, template *x509.Certificate) (*x509.Certificate, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	require.NoError(t, err)
+	x509Cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return x509Cert, priv
+}
+
+func x509RootTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(42),
+		Subject:               pkix.Name{CommonName: "x509 root", Organization: []string{"connctd"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+}
+
+func TestFromX509(t *testing.T) {
+	x509Root, rootKey := createX509Root(t, x509RootTemplate())
+	anchor, err := FromX509(x509Root)
+	require.NoError(t, err)
+	assert.Equal(t, "x509 root", anchor.Subject)
+	assert.Equal(t, uint64(42), anchor.SerialNumber)
+	assert.Equal(t, AlgorithmX509, anchor.SignatureAlgorithm)
+	assert.Equal(t, "X.509", anchor.SignatureAlgorithm.String())
+	assert.True(t, anchor.SignatureAlgorithm.IsSupported())
+
+	pool := NewCertPool()
+	require.NoError(t, pool.AddX509(x509Root))
+	clientCert, _, err := ClientCertificate("client", 1, time.Time{}, time.Time{}, nil, rootKey, "x509 root")
+	require.NoError(t, err)
+	assert.NoError(t, pool.Validate(clientCert))
+	intermediate, intermediateKey, err := SignedCertificate("intermediate", 2, time.Time{}, time.Time{},
+		[]Extension{{OID: OIDKeyUsage, Critical: true, Value: KeyUsageSignCert.ToBytes()}}, rootKey, "x509 root")
+	require.NoError(t, err)
+	deviceCert, _, err := ClientCertificate("device", 3, time.Time{}, time.Time{}, nil, intermediateKey, "intermediate")
+	require.NoError(t, err)
+	_, err = pool.ValidateBundle([]*Certificate{deviceCert, intermediate})
+	assert.NoError(t, err)
+
+	// The anchor survives an encoding roundtrip
+	buf, err := anchor.Bytes()
+	require.NoError(t, err)
+	parsed, err := ParseBuf(buf)
+	require.NoError(t, err)
+	assert.NoError(t, NewCertPool(parsed).Validate(clientCert))
+
+	// Modified anchors don't match their X.509 certificate anymore
+	parsed.SerialNumber = 43
+	err = NewCertPool(parsed).Validate(clientCert)
+	assert.True(t, errors.Is(err, ErrBadSignature))
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	forged := *anchor
+	forged.PubKey = otherKey.Public().(ed25519.PublicKey)
+	forgedClient, _, err := ClientCertificate("client", 1, time.Time{}, time.Time{}, nil, otherKey, "x509 root")
+	require.NoError(t, err)
+	assert.True(t, errors.Is(NewCertPool(&forged).Validate(forgedClient), ErrBadSignature))
+
+	// Only roots may carry X.509 signatures
+	leaf := *clientCert
+	leaf.SignatureAlgorithm = AlgorithmX509
+	leaf.Signature = anchor.Signature
+	assert.True(t, errors.Is(pool.Validate(&leaf), ErrBadSignature))
+}
+
+func TestFromX509Rejects(t *testing.T) {
+	noCA := x509RootTemplate()
+	noCA.IsCA = false
+	noCA.KeyUsage = x509.KeyUsageDigitalSignature
+	noCommonName := x509RootTemplate()
+	noCommonName.Subject = pkix.Name{Organization: []string{"connctd"}}
+	noCertSign := x509RootTemplate()
+	noCertSign.KeyUsage = x509.KeyUsageDigitalSignature
+	constrained := x509RootTemplate()
+	constrained.PermittedDNSDomains = []string{"example.com"}
+
+	for name, template := range map[string]*x509.Certificate{
+		"no CA":          noCA,
+		"no common name": noCommonName,
+		"no cert sign":   noCertSign,
+		"constrained":    constrained,
+	} {
+		x509Cert, _ := createX509Root(t, template)
+		_, err := FromX509(x509Cert)
+		assert.Error(t, err, name)
+		assert.Error(t, NewCertPool().AddX509(x509Cert), name)
+	}
+
+	// Certificates issued by another X.509 certificate are no roots
+	x509Root, rootKey := createX509Root(t, x509RootTemplate())
+	template := x509RootTemplate()
+	template.Subject = pkix.Name{CommonName: "x509 intermediate"}
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, template, x509Root, pub, rootKey)
+	require.NoError(t, err)
+	x509Intermediate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	_, err = FromX509(x509Intermediate)
+	assert.Error(t, err)
+
+	_, err = (&Certificate{SignatureAlgorithm: AlgorithmEd25519}).X509Certificate()
+	assert.Error(t, err)
+}
+
+func TestX509Root(t *testing.T) {
+	rootCert, rootKey, err := SelfSignedCertificate("root", time.Now().Add(-time.Hour), time.Now().Add(time.Hour), nil)
+	require.NoError(t, err)
+	clientCert, _, err := ClientCertificate("client", 1, time.Time{}, time.Time{}, nil, rootKey, "root")
+	require.NoError(t, err)
+
+	x509Root, err := X509Root(rootCert, rootKey)
+	require.NoError(t, err)
+	assert.Equal(t, "root", x509Root.Subject.CommonName)
+	assert.True(t, x509Root.IsCA)
+
+	// The exported root replaces the smolcert root, since it has the same key
+	pool := NewCertPool(rootCert)
+	require.NoError(t, pool.AddX509(x509Root))
+	assert.Equal(t, 1, pool.Len())
+	assert.NoError(t, pool.Validate(clientCert))
+
+	// X.509 certificates signed by the root key are trusted by the derived x509.CertPool
+	leafPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "x509 client"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Minute),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leafTemplate, x509Root, leafPub, rootKey)
+	require.NoError(t, err)
+	x509Leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	_, err = x509Leaf.Verify(x509.VerifyOptions{
+		Roots:     pool.X509CertPool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	assert.NoError(t, err)
+	_, err = x509Leaf.Verify(x509.VerifyOptions{Roots: NewCertPool(rootCert).X509CertPool()})
+	assert.Error(t, err)
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = X509Root(rootCert, otherKey)
+	assert.Error(t, err)
+	_, err = X509Root(clientCert, rootKey)
+	assert.Error(t, err)
+}
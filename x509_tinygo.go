@@ -0,0 +1,10 @@
+//go:build tinygo
+// +build tinygo
+
+package smolcert
+
+const x509Supported = false
+
+func verifyX509Anchor(cert, issuer *Certificate) error {
+	return newValidationError(ErrUnsupportedAlgorithm, cert, "X.509 trust anchors are not supported")
+}